@@ -0,0 +1,77 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/raft"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// command is one committed transaction in the Raft log.
+type command struct {
+	ID      string              `json:"id"`
+	Changes []storage.WALChange `json:"changes"`
+}
+
+// fsm applies the Raft log to the database.
+type fsm struct {
+	node *Node
+}
+
+func (f *fsm) Apply(l *raft.Log) interface{} {
+	var cmd command
+	if err := json.Unmarshal(l.Data, &cmd); err != nil {
+		return fmt.Errorf("invalid log entry at index %d: %w", l.Index, err)
+	}
+
+	// The leader already applied its own transactions while executing them;
+	// it only needs to log them. Everyone else replays the changes.
+	if f.node.takePending(cmd.ID) {
+		return f.node.db.LogWALChanges(cmd.Changes)
+	}
+
+	lsn, err := f.node.db.ApplyWALChanges(cmd.Changes)
+	if err != nil {
+		f.node.logger.Error("failed to apply cluster log entry", "index", l.Index, "error", err)
+		return err
+	}
+	return lsn
+}
+
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	lsn, changes := f.node.db.Dump()
+	return &snapshot{LSN: lsn, Changes: changes}, nil
+}
+
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	var snap snapshot
+	if err := json.NewDecoder(rc).Decode(&snap); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+	if err := f.node.db.Restore(snap.LSN, snap.Changes); err != nil {
+		return err
+	}
+	f.node.logger.Info("restored cluster snapshot", "lsn", snap.LSN, "changes", len(snap.Changes))
+	return nil
+}
+
+// snapshot is the whole database as the changes that recreate it.
+type snapshot struct {
+	LSN     uint64              `json:"lsn"`
+	Changes []storage.WALChange `json:"changes"`
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(s); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {}
@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RequestJoin asks the member serving HTTP at leaderURL to add this node,
+// retrying until it succeeds or ctx is done. Credentials in the URL are sent
+// with HTTP Basic auth.
+func RequestJoin(ctx context.Context, leaderURL, id, raftAddr string) error {
+	u, err := url.Parse(leaderURL)
+	if err != nil {
+		return fmt.Errorf("invalid join URL: %w", err)
+	}
+	user := u.User
+	u.User = nil
+	u.Path = "/cluster/join"
+
+	body, err := json.Marshal(map[string]string{"id": id, "addr": raftAddr})
+	if err != nil {
+		return err
+	}
+
+	backoff := 500 * time.Millisecond
+	for {
+		err = postJoin(ctx, u.String(), user, body)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to join cluster: %w", err)
+		}
+		if backoff *= 2; backoff > 10*time.Second {
+			backoff = 10 * time.Second
+		}
+	}
+}
+
+func postJoin(ctx context.Context, endpoint string, user *url.Userinfo, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("join rejected: %s %s", resp.Status, e.Error)
+	}
+	return nil
+}
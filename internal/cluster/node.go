@@ -0,0 +1,301 @@
+// Package cluster runs the database as a member of a Raft group. Commits
+// are proposed to the Raft log by the leader and applied by every member in
+// log order, so all nodes agree on the order of transactions.
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+const (
+	defaultApplyTimeout      = 10 * time.Second
+	defaultSnapshotThreshold = 1024
+)
+
+type Config struct {
+	// NodeID uniquely identifies this member within the cluster.
+	NodeID string
+	// RaftAddr is the host:port used for Raft traffic between members. It
+	// must be reachable by the other members (not 0.0.0.0).
+	RaftAddr string
+	// Bootstrap starts a new single-member cluster. Set it on exactly one
+	// node; the others join it.
+	Bootstrap bool
+	// SnapshotThreshold is the number of log entries after which the log
+	// is compacted into a snapshot. Zero uses the default.
+	SnapshotThreshold uint64
+	// ApplyTimeout bounds how long a commit waits for the cluster. Zero
+	// uses the default.
+	ApplyTimeout time.Duration
+}
+
+// Node is this process's membership in the cluster.
+type Node struct {
+	db           *storage.Database
+	raft         *raft.Raft
+	transport    raft.Transport
+	config       Config
+	logger       *slog.Logger
+	applyTimeout time.Duration
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[string]bool
+	done    chan struct{}
+}
+
+type Status struct {
+	NodeID     string   `json:"node_id"`
+	State      string   `json:"state"`
+	LeaderID   string   `json:"leader_id"`
+	LeaderAddr string   `json:"leader_addr"`
+	Term       string   `json:"term"`
+	LastLSN    uint64   `json:"last_lsn"`
+	Members    []Member `json:"members"`
+}
+
+type Member struct {
+	ID       string `json:"id"`
+	Addr     string `json:"addr"`
+	Suffrage string `json:"suffrage"`
+}
+
+// NewNode starts Raft for db. The database is read-only until this node
+// becomes leader; every commit made while leader goes through the log.
+// Raft state is kept in memory like the rest of the database, so a
+// restarted node rejoins with an empty log and catches up from a snapshot.
+func NewNode(db *storage.Database, config Config, logger *slog.Logger) (*Node, error) {
+	if config.NodeID == "" {
+		return nil, fmt.Errorf("cluster node ID is required")
+	}
+	if config.RaftAddr == "" {
+		return nil, fmt.Errorf("cluster Raft address is required")
+	}
+	transport, err := raft.NewTCPTransport(config.RaftAddr, nil, 3, 10*time.Second, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start Raft transport: %w", err)
+	}
+	return newNode(db, config, logger, raftConfig(config), transport)
+}
+
+// raftConfig returns the Raft settings for config.
+func raftConfig(config Config) *raft.Config {
+	rc := raft.DefaultConfig()
+	rc.LocalID = raft.ServerID(config.NodeID)
+	rc.SnapshotThreshold = config.SnapshotThreshold
+	if rc.SnapshotThreshold == 0 {
+		rc.SnapshotThreshold = defaultSnapshotThreshold
+	}
+	rc.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:   "raft",
+		Level:  hclog.Warn,
+		Output: os.Stderr,
+	})
+	return rc
+}
+
+// newNode starts Raft for db with the settings rc over transport, which
+// it closes on failure and on Shutdown.
+func newNode(db *storage.Database, config Config, logger *slog.Logger, rc *raft.Config, transport raft.Transport) (*Node, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	n := &Node{
+		db:           db,
+		config:       config,
+		logger:       logger,
+		applyTimeout: config.ApplyTimeout,
+		transport:    transport,
+		pending:      make(map[string]bool),
+		done:         make(chan struct{}),
+	}
+	if n.applyTimeout == 0 {
+		n.applyTimeout = defaultApplyTimeout
+	}
+
+	store := raft.NewInmemStore()
+	snapshots := raft.NewInmemSnapshotStore()
+
+	db.SetReadOnly(true)
+	r, err := raft.NewRaft(rc, &fsm{node: n}, store, store, snapshots, transport)
+	if err != nil {
+		n.closeTransport()
+		return nil, fmt.Errorf("failed to start Raft: %w", err)
+	}
+	n.raft = r
+
+	if config.Bootstrap {
+		err := r.BootstrapCluster(raft.Configuration{
+			Servers: []raft.Server{{ID: rc.LocalID, Address: transport.LocalAddr()}},
+		}).Error()
+		if err != nil && err != raft.ErrCantBootstrap {
+			r.Shutdown()
+			n.closeTransport()
+			return nil, fmt.Errorf("failed to bootstrap cluster: %w", err)
+		}
+	}
+
+	db.SetCommitHook(n.commit)
+	go n.watchLeadership()
+	return n, nil
+}
+
+// watchLeadership makes the database writable only while this node leads.
+func (n *Node) watchLeadership() {
+	for {
+		select {
+		case isLeader := <-n.raft.LeaderCh():
+			if isLeader {
+				// Apply everything committed by the previous leader before
+				// accepting new writes.
+				if err := n.raft.Barrier(n.applyTimeout).Error(); err != nil {
+					n.logger.Error("cluster barrier failed", "error", err)
+					continue
+				}
+				n.db.SetReadOnly(false)
+				n.logger.Info("cluster leadership acquired", "node", n.config.NodeID)
+			} else {
+				n.db.SetReadOnly(true)
+				n.logger.Info("cluster leadership lost", "node", n.config.NodeID)
+			}
+		case <-n.done:
+			return
+		}
+	}
+}
+
+// commit is the database's commit hook. It proposes the transaction's
+// changes and waits until a quorum has stored them.
+func (n *Node) commit(changes []storage.WALChange) error {
+	if n.raft.State() != raft.Leader {
+		addr, _ := n.raft.LeaderWithID()
+		return fmt.Errorf("not the cluster leader (leader: %s)", addr)
+	}
+
+	n.mu.Lock()
+	n.nextID++
+	id := fmt.Sprintf("%s-%d", n.config.NodeID, n.nextID)
+	n.pending[id] = true
+	n.mu.Unlock()
+
+	data, err := json.Marshal(command{ID: id, Changes: changes})
+	if err == nil {
+		err = n.raft.Apply(data, n.applyTimeout).Error()
+	}
+	if err != nil {
+		n.mu.Lock()
+		delete(n.pending, id)
+		n.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// takePending reports whether id was proposed by this node and is still
+// applied locally, and forgets it.
+func (n *Node) takePending(id string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	local := n.pending[id]
+	delete(n.pending, id)
+	return local
+}
+
+// Join adds a voting member. It must be called on the leader.
+func (n *Node) Join(id, addr string) error {
+	if n.raft.State() != raft.Leader {
+		leader, _ := n.raft.LeaderWithID()
+		return fmt.Errorf("not the cluster leader (leader: %s)", leader)
+	}
+
+	future := n.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return err
+	}
+	for _, srv := range future.Configuration().Servers {
+		if srv.ID == raft.ServerID(id) && srv.Address == raft.ServerAddress(addr) {
+			return nil
+		}
+		if srv.ID == raft.ServerID(id) || srv.Address == raft.ServerAddress(addr) {
+			if err := n.raft.RemoveServer(srv.ID, 0, 0).Error(); err != nil {
+				return fmt.Errorf("failed to replace member %s: %w", srv.ID, err)
+			}
+		}
+	}
+
+	if err := n.raft.AddVoter(raft.ServerID(id), raft.ServerAddress(addr), 0, 0).Error(); err != nil {
+		return fmt.Errorf("failed to add member %s: %w", id, err)
+	}
+	n.logger.Info("cluster member joined", "id", id, "addr", addr)
+	return nil
+}
+
+// Remove drops a member from the cluster. It must be called on the leader.
+func (n *Node) Remove(id string) error {
+	if n.raft.State() != raft.Leader {
+		leader, _ := n.raft.LeaderWithID()
+		return fmt.Errorf("not the cluster leader (leader: %s)", leader)
+	}
+	if err := n.raft.RemoveServer(raft.ServerID(id), 0, 0).Error(); err != nil {
+		return fmt.Errorf("failed to remove member %s: %w", id, err)
+	}
+	n.logger.Info("cluster member removed", "id", id)
+	return nil
+}
+
+func (n *Node) IsLeader() bool {
+	return n.raft.State() == raft.Leader
+}
+
+func (n *Node) Status() Status {
+	addr, id := n.raft.LeaderWithID()
+	status := Status{
+		NodeID:     n.config.NodeID,
+		State:      n.raft.State().String(),
+		LeaderID:   string(id),
+		LeaderAddr: string(addr),
+		Term:       n.raft.Stats()["term"],
+		LastLSN:    n.db.WAL().LastLSN(),
+	}
+
+	future := n.raft.GetConfiguration()
+	if future.Error() == nil {
+		for _, srv := range future.Configuration().Servers {
+			status.Members = append(status.Members, Member{
+				ID:       string(srv.ID),
+				Addr:     string(srv.Address),
+				Suffrage: srv.Suffrage.String(),
+			})
+		}
+	}
+	return status
+}
+
+// Shutdown leaves Raft running on the other members and stops this one.
+// The database is left read-only.
+func (n *Node) Shutdown() error {
+	close(n.done)
+	n.db.SetCommitHook(nil)
+	n.db.SetReadOnly(true)
+	err := n.raft.Shutdown().Error()
+	n.closeTransport()
+	return err
+}
+
+func (n *Node) closeTransport() {
+	if t, ok := n.transport.(raft.WithClose); ok {
+		t.Close()
+	}
+}
@@ -0,0 +1,212 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// testNode is a cluster member on an in-memory transport.
+type testNode struct {
+	*Node
+	db        *storage.Database
+	addr      raft.ServerAddress
+	transport *raft.InmemTransport
+	stopped   bool
+}
+
+func (tn *testNode) stop() error {
+	tn.stopped = true
+	return tn.Shutdown()
+}
+
+// newTestCluster starts nodes on in-memory transports connected to each
+// other, with short Raft timeouts. The first bootstraps the cluster; the
+// others wait to be joined. Snapshots keep no log entries, so a node
+// joining after one catches up from it.
+func newTestCluster(t *testing.T, nodes int) []*testNode {
+	t.Helper()
+	cluster := make([]*testNode, nodes)
+	for i := range cluster {
+		addr, transport := raft.NewInmemTransport("")
+		cluster[i] = &testNode{db: storage.NewDatabase(), addr: addr, transport: transport}
+	}
+	for _, a := range cluster {
+		for _, b := range cluster {
+			if a != b {
+				a.transport.Connect(b.addr, b.transport)
+			}
+		}
+	}
+	for i, tn := range cluster {
+		config := Config{NodeID: fmt.Sprintf("node%d", i+1), Bootstrap: i == 0, ApplyTimeout: 5 * time.Second}
+		rc := raftConfig(config)
+		rc.HeartbeatTimeout = 50 * time.Millisecond
+		rc.ElectionTimeout = 50 * time.Millisecond
+		rc.LeaderLeaseTimeout = 50 * time.Millisecond
+		rc.CommitTimeout = 5 * time.Millisecond
+		rc.TrailingLogs = 0
+		rc.Logger = hclog.NewNullLogger()
+		node, err := newNode(tn.db, config, slog.New(slog.NewTextHandler(io.Discard, nil)), rc, tn.transport)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tn.Node = node
+	}
+	t.Cleanup(func() {
+		for _, tn := range cluster {
+			if tn.Node != nil && !tn.stopped {
+				tn.stop()
+			}
+		}
+	})
+	return cluster
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func execSQL(db *storage.Database, text string) (*sql.Result, error) {
+	stmt, err := sql.NewParser(sql.NewLexer(text)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	session := sql.NewSession(db)
+	defer session.Close()
+	return session.Execute(stmt)
+}
+
+// waitForLeader waits until one of nodes leads and accepts writes.
+func waitForLeader(t *testing.T, nodes []*testNode) *testNode {
+	t.Helper()
+	var leader *testNode
+	waitFor(t, "a writable leader", func() bool {
+		for _, tn := range nodes {
+			if tn.IsLeader() && !tn.db.ReadOnly() {
+				leader = tn
+				return true
+			}
+		}
+		return false
+	})
+	return leader
+}
+
+// TestCluster runs three members in one process: commits on the leader
+// reach the followers, which refuse writes, a member joining after a
+// snapshot catches up from it, and another leader takes over when the
+// first one stops.
+func TestCluster(t *testing.T) {
+	nodes := newTestCluster(t, 3)
+	leader := waitForLeader(t, nodes[:1])
+	if err := leader.Join("node2", string(nodes[1].addr)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, text := range []string{
+		"CREATE TABLE accounts (id INTEGER PRIMARY KEY, owner TEXT)",
+		"CREATE INDEX accounts_owner ON accounts (owner)",
+		"INSERT INTO accounts (id, owner) VALUES (1, 'ada'), (2, 'alan')",
+		"UPDATE accounts SET owner = 'grace' WHERE id = 2",
+	} {
+		if _, err := execSQL(leader.db, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	// The leader logs its own commits once, as it applies them.
+	if lsn := leader.db.WAL().LastLSN(); lsn != 4 {
+		t.Errorf("leader LSN = %d, want 4", lsn)
+	}
+	leader.mu.Lock()
+	if len(leader.pending) != 0 {
+		t.Errorf("leader still has pending commits %v", leader.pending)
+	}
+	leader.mu.Unlock()
+
+	const query = "SELECT id, owner FROM accounts WHERE owner != 'nobody' ORDER BY id"
+	check := func(tn *testNode) {
+		t.Helper()
+		waitFor(t, tn.config.NodeID+" to apply the log", func() bool {
+			return tn.db.WAL().LastLSN() == leader.db.WAL().LastLSN()
+		})
+		want, err := execSQL(leader.db, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := execSQL(tn.db, query)
+		if err != nil {
+			t.Fatalf("%s: %v", tn.config.NodeID, err)
+		}
+		if !reflect.DeepEqual(got.Rows, want.Rows) {
+			t.Errorf("%s: rows %v, want %v", tn.config.NodeID, got.Rows, want.Rows)
+		}
+		if table, err := tn.db.GetTable("accounts"); err != nil || len(table.SecondaryIndexes()) != 1 {
+			t.Errorf("%s: index not applied (%v)", tn.config.NodeID, err)
+		}
+	}
+	check(nodes[1])
+
+	follower := nodes[1]
+	if _, err := execSQL(follower.db, "INSERT INTO accounts (id, owner) VALUES (3, 'edsger')"); !errors.Is(err, sql.ErrReadOnly) {
+		t.Errorf("insert on a follower: got %v, want ErrReadOnly", err)
+	}
+	if err := follower.commit(nil); err == nil {
+		t.Error("a follower proposed a commit")
+	}
+	if err := follower.Join("node3", string(nodes[2].addr)); err == nil {
+		t.Error("a follower added a member")
+	}
+
+	// Compact the log, so node3 can only catch up from the snapshot.
+	if err := leader.raft.Snapshot().Error(); err != nil {
+		t.Fatal(err)
+	}
+	if err := leader.Join("node3", string(nodes[2].addr)); err != nil {
+		t.Fatal(err)
+	}
+	check(nodes[2])
+	if oldest := nodes[2].db.WAL().Oldest(); oldest == 0 {
+		t.Error("node3 replayed the log instead of restoring the snapshot")
+	}
+	if _, err := execSQL(leader.db, "INSERT INTO accounts (id, owner) VALUES (3, 'edsger')"); err != nil {
+		t.Fatal(err)
+	}
+	check(nodes[1])
+	check(nodes[2])
+
+	// The others elect a new leader, which can write and remove the old one.
+	if err := leader.stop(); err != nil {
+		t.Fatal(err)
+	}
+	leader = waitForLeader(t, nodes[1:])
+	if _, err := execSQL(leader.db, "INSERT INTO accounts (id, owner) VALUES (4, 'barbara')"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tn := range nodes[1:] {
+		check(tn)
+	}
+	if err := leader.Remove("node1"); err != nil {
+		t.Fatal(err)
+	}
+	if members := leader.Status().Members; len(members) != 2 {
+		t.Errorf("members after removing node1 = %v", members)
+	}
+}
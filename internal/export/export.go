@@ -0,0 +1,235 @@
+// Package export writes tables and query results as Apache Parquet or
+// Arrow IPC files for analytics tools.
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/parquet"
+	"github.com/apache/arrow/go/v15/parquet/compress"
+	"github.com/apache/arrow/go/v15/parquet/pqarrow"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+type Format string
+
+const (
+	FormatParquet Format = "parquet"
+	// FormatArrow is the Arrow IPC streaming format.
+	FormatArrow Format = "arrow"
+)
+
+// batchSize is the number of rows per Arrow record batch (and Parquet row
+// group).
+const batchSize = 64 * 1024
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "parquet":
+		return FormatParquet, nil
+	case "arrow", "arrows", "ipc":
+		return FormatArrow, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q (use parquet or arrow)", s)
+	}
+}
+
+// Extension returns the conventional file extension for the format.
+func (f Format) Extension() string {
+	if f == FormatArrow {
+		return ".arrows"
+	}
+	return ".parquet"
+}
+
+type Column struct {
+	Name string
+	Type storage.DataType
+}
+
+// Data is a set of typed rows ready to be written.
+type Data struct {
+	Columns []Column
+	Rows    [][]storage.Value
+}
+
+// Load returns the contents of the table named source, or the result of
+// running source as a SELECT statement.
+func Load(ctx context.Context, db *storage.Database, source string) (*Data, error) {
+	source = strings.TrimSpace(source)
+	if table, err := db.GetTable(source); err == nil {
+		return tableData(table), nil
+	}
+
+	stmt, _, err := sql.ParseContext(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := stmt.(*sql.SelectStatement); !ok {
+		return nil, fmt.Errorf("only tables and SELECT statements can be exported, got %s", stmt.Type())
+	}
+
+	session := sql.NewSession(db)
+	defer session.Close()
+	result, err := session.ExecuteContext(ctx, stmt, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resultData(result), nil
+}
+
+func tableData(table *storage.Table) *Data {
+	data := &Data{}
+	for _, col := range table.Schema().Columns {
+		data.Columns = append(data.Columns, Column{Name: col.Name, Type: col.Type})
+	}
+	for _, row := range table.Select(nil) {
+		data.Rows = append(data.Rows, row.Values)
+	}
+	return data
+}
+
+// resultData types each column from its first non-NULL value. Columns that
+// are entirely NULL are exported as text.
+func resultData(result *sql.Result) *Data {
+	data := &Data{Rows: result.Values}
+	for i, name := range result.Columns {
+		col := Column{Name: name, Type: storage.TypeText}
+		for _, row := range result.Values {
+			if i < len(row) && row[i].Type() != storage.TypeNull {
+				col.Type = row[i].Type()
+				break
+			}
+		}
+		data.Columns = append(data.Columns, col)
+	}
+	return data
+}
+
+// Write encodes data to w in the given format.
+func Write(w io.Writer, format Format, data *Data) error {
+	schema, err := arrowSchema(data.Columns)
+	if err != nil {
+		return err
+	}
+
+	mem := memory.DefaultAllocator
+	var write func(arrow.Record) error
+	var close func() error
+
+	switch format {
+	case FormatParquet:
+		props := parquet.NewWriterProperties(
+			parquet.WithCompression(compress.Codecs.Snappy),
+			parquet.WithAllocator(mem),
+		)
+		fw, err := pqarrow.NewFileWriter(schema, w, props, pqarrow.DefaultWriterProps())
+		if err != nil {
+			return fmt.Errorf("failed to create Parquet writer: %w", err)
+		}
+		write, close = fw.Write, fw.Close
+	case FormatArrow:
+		// The IPC stream format needs no seeking, so it can go straight to
+		// an HTTP response.
+		sw := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+		write, close = sw.Write, sw.Close
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+
+	for start := 0; start < len(data.Rows) || start == 0; start += batchSize {
+		end := start + batchSize
+		if end > len(data.Rows) {
+			end = len(data.Rows)
+		}
+		for _, row := range data.Rows[start:end] {
+			if err := appendRow(builder, data.Columns, row); err != nil {
+				close()
+				return err
+			}
+		}
+
+		record := builder.NewRecord()
+		err := write(record)
+		record.Release()
+		if err != nil {
+			close()
+			return fmt.Errorf("failed to write %s: %w", format, err)
+		}
+		if end == len(data.Rows) {
+			break
+		}
+	}
+
+	return close()
+}
+
+func arrowSchema(columns []Column) (*arrow.Schema, error) {
+	fields := make([]arrow.Field, len(columns))
+	for i, col := range columns {
+		var typ arrow.DataType
+		switch col.Type {
+		case storage.TypeInteger:
+			typ = arrow.PrimitiveTypes.Int64
+		case storage.TypeFloat:
+			typ = arrow.PrimitiveTypes.Float64
+		case storage.TypeText, storage.TypeInterval:
+			typ = arrow.BinaryTypes.String
+		case storage.TypeBoolean:
+			typ = arrow.FixedWidthTypes.Boolean
+		default:
+			return nil, fmt.Errorf("cannot export column %s of type %s", col.Name, col.Type)
+		}
+		fields[i] = arrow.Field{Name: col.Name, Type: typ, Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+func appendRow(builder *array.RecordBuilder, columns []Column, row []storage.Value) error {
+	for i, col := range columns {
+		field := builder.Field(i)
+		if i >= len(row) || row[i].Type() == storage.TypeNull {
+			field.AppendNull()
+			continue
+		}
+
+		switch b := field.(type) {
+		case *array.Int64Builder:
+			v, ok := row[i].(*storage.IntegerValue)
+			if !ok {
+				return fmt.Errorf("column %s: expected INTEGER, got %s", col.Name, row[i].Type())
+			}
+			b.Append(v.Value)
+		case *array.Float64Builder:
+			switch v := row[i].(type) {
+			case *storage.FloatValue:
+				b.Append(v.Value)
+			case *storage.IntegerValue:
+				b.Append(float64(v.Value))
+			default:
+				return fmt.Errorf("column %s: expected FLOAT, got %s", col.Name, row[i].Type())
+			}
+		case *array.StringBuilder:
+			b.Append(row[i].ToString())
+		case *array.BooleanBuilder:
+			v, ok := row[i].(*storage.BooleanValue)
+			if !ok {
+				return fmt.Errorf("column %s: expected BOOLEAN, got %s", col.Name, row[i].Type())
+			}
+			b.Append(v.Value)
+		}
+	}
+	return nil
+}
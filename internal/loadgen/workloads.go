@@ -0,0 +1,176 @@
+package loadgen
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// generator holds the random source shared by a load's tables.
+type generator struct {
+	rng   *rand.Rand
+	seed  int64
+	scale int
+}
+
+var (
+	firstNames = []string{"Ada", "Ben", "Chloe", "Dev", "Elena", "Femi", "Grace", "Hiro", "Ines", "Jon", "Kara", "Luis", "Mei", "Nia", "Omar", "Priya", "Quinn", "Ravi", "Sara", "Tom"}
+	lastNames  = []string{"Smith", "Okafor", "Garcia", "Chen", "Kowalski", "Ivanova", "Haddad", "Silva", "Nguyen", "Muller", "Patel", "Mwangi", "Rossi", "Kim", "Dubois"}
+	cities     = []string{"Nairobi", "Lisbon", "Osaka", "Denver", "Krakow", "Lagos", "Austin", "Lyon", "Pune", "Toronto"}
+	products   = []string{"Widget", "Gadget", "Cable", "Adapter", "Lamp", "Notebook", "Mug", "Backpack", "Charger", "Speaker", "Keyboard", "Monitor"}
+	adjectives = []string{"Basic", "Deluxe", "Compact", "Wireless", "Classic", "Pro", "Mini", "Eco"}
+	verbs      = []string{"Write", "Review", "Fix", "Deploy", "Test", "Design", "Document", "Refactor", "Plan", "Migrate"}
+	subjects   = []string{"login page", "billing report", "search API", "onboarding flow", "backup job", "release notes", "dashboard", "import script", "cache layer", "mobile layout"}
+	statuses   = []string{"pending", "in_progress", "completed"}
+	orderState = []string{"new", "paid", "shipped", "delivered", "cancelled"}
+	epoch      = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func (g *generator) pick(list []string) string { return list[g.rng.Intn(len(list))] }
+
+func (g *generator) person() (first, last string) {
+	return g.pick(firstNames), g.pick(lastNames)
+}
+
+// email is unique per id, so UNIQUE columns never collide.
+func email(first, last string, id int) string {
+	return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), id)
+}
+
+// date returns a day in the year after epoch, formatted as YYYY-MM-DD.
+func (g *generator) date() string {
+	return epoch.AddDate(0, 0, g.rng.Intn(365)).Format("2006-01-02")
+}
+
+// filler pads rows to a realistic width, as the TPC-B filler columns do.
+func filler(n int) string {
+	return strings.Repeat("x", n)
+}
+
+// TPC-B: branches, tellers and accounts with zero balances and an empty
+// history table for the transactions a benchmark will append.
+var tpcb = &workload{
+	schema: []string{
+		"CREATE TABLE branches (bid INTEGER PRIMARY KEY, bbalance INTEGER NOT NULL, filler TEXT)",
+		"CREATE TABLE tellers (tid INTEGER PRIMARY KEY, bid INTEGER NOT NULL, tbalance INTEGER NOT NULL, filler TEXT)",
+		"CREATE TABLE accounts (aid INTEGER PRIMARY KEY, bid INTEGER NOT NULL, abalance INTEGER NOT NULL, filler TEXT)",
+		"CREATE TABLE history (hid INTEGER PRIMARY KEY, tid INTEGER, bid INTEGER, aid INTEGER, delta INTEGER, mtime TEXT)",
+	},
+	tables: []table{
+		{"branches", []string{"bid", "bbalance", "filler"}, func(g *generator, emit func(...interface{})) {
+			for b := 1; b <= g.scale; b++ {
+				emit(b, 0, filler(88))
+			}
+		}},
+		{"tellers", []string{"tid", "bid", "tbalance", "filler"}, func(g *generator, emit func(...interface{})) {
+			for t := 1; t <= 10*g.scale; t++ {
+				emit(t, (t-1)/10+1, 0, filler(84))
+			}
+		}},
+		{"accounts", []string{"aid", "bid", "abalance", "filler"}, func(g *generator, emit func(...interface{})) {
+			for a := 1; a <= 1000*g.scale; a++ {
+				emit(a, (a-1)/1000+1, 0, filler(84))
+			}
+		}},
+	},
+}
+
+// orderLines returns the (item, quantity, price) lines of an order. They
+// come from a source seeded by the load's seed and the order id, so the
+// orders table can total them and order_lines can emit them again without
+// holding them in memory.
+func orderLines(seed int64, order int) [][3]int {
+	rng := rand.New(rand.NewSource(seed*1000003 + int64(order)))
+	lines := make([][3]int, 1+rng.Intn(5))
+	for i := range lines {
+		item := 1 + rng.Intn(orderItems)
+		lines[i] = [3]int{item, 1 + rng.Intn(4), itemPrice(item)}
+	}
+	return lines
+}
+
+const (
+	orderItems            = 500
+	customersPerWarehouse = 300
+	ordersPerCustomer     = 3
+)
+
+// itemPrice is an item's price in cents, fixed per item.
+func itemPrice(item int) int { return 199 + item*7919%9800 }
+
+// TPC-C style: warehouses, customers, an item catalog, and orders whose
+// totals equal the sum of their order lines.
+var orders = &workload{
+	schema: []string{
+		"CREATE TABLE warehouses (id INTEGER PRIMARY KEY, name TEXT NOT NULL, city TEXT)",
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, warehouse_id INTEGER NOT NULL, name TEXT NOT NULL, email TEXT UNIQUE, city TEXT, balance FLOAT)",
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL, price FLOAT NOT NULL)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER NOT NULL, ordered_at TEXT, status TEXT, total FLOAT)",
+		"CREATE TABLE order_lines (id INTEGER PRIMARY KEY, order_id INTEGER NOT NULL, item_id INTEGER NOT NULL, quantity INTEGER, amount FLOAT)",
+	},
+	tables: []table{
+		{"warehouses", []string{"id", "name", "city"}, func(g *generator, emit func(...interface{})) {
+			for w := 1; w <= g.scale; w++ {
+				emit(w, fmt.Sprintf("Warehouse %d", w), g.pick(cities))
+			}
+		}},
+		{"customers", []string{"id", "warehouse_id", "name", "email", "city", "balance"}, func(g *generator, emit func(...interface{})) {
+			for c := 1; c <= customersPerWarehouse*g.scale; c++ {
+				first, last := g.person()
+				emit(c, (c-1)/customersPerWarehouse+1, first+" "+last, email(first, last, c), g.pick(cities), float64(g.rng.Intn(100000))/100)
+			}
+		}},
+		{"items", []string{"id", "name", "price"}, func(g *generator, emit func(...interface{})) {
+			for i := 1; i <= orderItems; i++ {
+				emit(i, g.pick(adjectives)+" "+g.pick(products), float64(itemPrice(i))/100)
+			}
+		}},
+		{"orders", []string{"id", "customer_id", "ordered_at", "status", "total"}, func(g *generator, emit func(...interface{})) {
+			customers := customersPerWarehouse * g.scale
+			for o := 1; o <= ordersPerCustomer*customers; o++ {
+				total := 0
+				for _, line := range orderLines(g.seed, o) {
+					total += line[1] * line[2]
+				}
+				emit(o, 1+g.rng.Intn(customers), g.date(), g.pick(orderState), float64(total)/100)
+			}
+		}},
+		{"order_lines", []string{"id", "order_id", "item_id", "quantity", "amount"}, func(g *generator, emit func(...interface{})) {
+			id := 0
+			for o := 1; o <= ordersPerCustomer*customersPerWarehouse*g.scale; o++ {
+				for _, line := range orderLines(g.seed, o) {
+					id++
+					emit(id, o, line[0], line[1], float64(line[1]*line[2])/100)
+				}
+			}
+		}},
+	},
+}
+
+// The webapp's users and tasks schema, with about nine tasks per user and
+// one in ten left unassigned.
+var tasks = &workload{
+	schema: []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT UNIQUE)",
+		"CREATE TABLE tasks (id INTEGER PRIMARY KEY, title TEXT NOT NULL, description TEXT, status TEXT DEFAULT 'pending', user_id INTEGER)",
+	},
+	tables: []table{
+		{"users", []string{"id", "name", "email"}, func(g *generator, emit func(...interface{})) {
+			for u := 1; u <= 100*g.scale; u++ {
+				first, last := g.person()
+				emit(u, first+" "+last, email(first, last, u))
+			}
+		}},
+		{"tasks", []string{"id", "title", "description", "status", "user_id"}, func(g *generator, emit func(...interface{})) {
+			for t := 1; t <= 1000*g.scale; t++ {
+				var user interface{}
+				if g.rng.Intn(10) > 0 {
+					user = 1 + g.rng.Intn(100*g.scale)
+				}
+				subject := g.pick(subjects)
+				emit(t, g.pick(verbs)+" "+subject, fmt.Sprintf("Follow-up on the %s, due %s", subject, g.date()), g.pick(statuses), user)
+			}
+		}},
+	},
+}
@@ -2,10 +2,15 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/user"
 	"strings"
+	"time"
 
+	"github.com/mryan-3/rdbms/internal/export"
+	"github.com/mryan-3/rdbms/internal/schemadiff"
 	"github.com/mryan-3/rdbms/internal/sql"
 	"github.com/mryan-3/rdbms/internal/storage"
 )
@@ -14,14 +19,19 @@ type REPL struct {
 	db      *storage.Database
 	lexer   *sql.Lexer
 	parser  *sql.Parser
-	exec    *sql.Executor
+	session *sql.Session
 	scanner *bufio.Scanner
 }
 
 func NewREPL(db *storage.Database) *REPL {
+	session := sql.NewSession(db)
+	if u, err := user.Current(); err == nil {
+		session.SetUser(u.Username)
+	}
+
 	return &REPL{
 		db:      db,
-		exec:    sql.NewExecutor(db),
+		session: session,
 		scanner: bufio.NewScanner(os.Stdin),
 	}
 }
@@ -32,7 +42,11 @@ func (r *REPL) Run() error {
 	fmt.Println()
 
 	for {
-		fmt.Print("rdbms> ")
+		if r.session.InTransaction() {
+			fmt.Print("rdbms*> ")
+		} else {
+			fmt.Print("rdbms> ")
+		}
 
 		if !r.scanner.Scan() {
 			fmt.Println()
@@ -83,7 +97,7 @@ func (r *REPL) handleCommand(input string) error {
 		return nil
 
 	case "\\version", "\\v":
-		fmt.Println("RDBMS v1.0.0 - A simple relational database management system")
+		fmt.Println("RDBMS v" + sql.Version + " - A simple relational database management system")
 		return nil
 
 	case "\\clear", "\\c":
@@ -98,8 +112,15 @@ func (r *REPL) handleCommand(input string) error {
 	}
 
 	if strings.HasPrefix(lowerInput, "\\import ") {
-		filePath := strings.TrimSpace(input[8:])
-		return r.ImportFile(filePath)
+		args := strings.TrimSpace(input[8:])
+		if option, filePath, ok := strings.Cut(args, " "); ok && strings.HasPrefix(option, "--on-conflict=") {
+			policy, err := storage.ParseConflictPolicy(strings.TrimPrefix(option, "--on-conflict="))
+			if err != nil {
+				return err
+			}
+			return r.ImportFileOnConflict(strings.TrimSpace(filePath), policy)
+		}
+		return r.ImportFile(args)
 	}
 
 	if strings.HasPrefix(lowerInput, "\\export ") {
@@ -107,6 +128,23 @@ func (r *REPL) handleCommand(input string) error {
 		return r.ExportFile(filePath)
 	}
 
+	if strings.HasPrefix(lowerInput, "\\parquet ") {
+		return r.ExportData(export.FormatParquet, strings.TrimSpace(input[9:]))
+	}
+
+	if strings.HasPrefix(lowerInput, "\\arrow ") {
+		return r.ExportData(export.FormatArrow, strings.TrimSpace(input[7:]))
+	}
+
+	if strings.HasPrefix(lowerInput, "\\format-sql ") {
+		return r.FormatSQL(strings.TrimSpace(input[12:]))
+	}
+
+	if strings.HasPrefix(lowerInput, "\\audit ") {
+		filePath := strings.TrimSpace(input[7:])
+		return r.ExportAudit(filePath)
+	}
+
 	return r.ExecuteSQL(input)
 }
 
@@ -179,12 +217,18 @@ Meta Commands:
   \s, \schema           Show full database schema
   \version, \v          Show version information
   \clear, \c            Clear the screen
-  \import [file]        Import SQL from file
+  \import [file]        Import SQL from file; --on-conflict=skip|replace before the
+                        file keeps rows and tables already there (see SET on_conflict)
   \export [file]        Export database to SQL file
+  \audit [file]         Export the audit log as JSON lines
+  \format-sql [sql]     Print SQL statements formatted, without running them
+  \parquet [file] [src] Export a table or SELECT query as Parquet
+  \arrow [file] [src]   Export a table or SELECT query as an Arrow IPC stream
 
 SQL Commands:
   CREATE TABLE          Create a new table
   DROP TABLE            Drop a table
+  ALTER TABLE           Change a column's type: ALTER TABLE t ALTER COLUMN c TYPE FLOAT [USING expr]
   SELECT                Query data
   INSERT                Insert data
   UPDATE                Update data
@@ -192,6 +236,11 @@ SQL Commands:
   BEGIN TRANSACTION     Start a transaction
   COMMIT                Commit transaction
   ROLLBACK              Rollback transaction
+  PREPARE TRANSACTION   Two-phase commit: PREPARE TRANSACTION 'id', then COMMIT PREPARED 'id' or ROLLBACK PREPARED 'id'
+  LISTEN / NOTIFY       Subscribe to and publish on notification channels
+  SET name = value      Change a session setting (SHOW name | ALL to read)
+  CREATE USER           Create a login: CREATE USER name WITH PASSWORD 'pw'
+  BACKUP TO 'file'      Write a backup (load it with rdbms -restore file)
 
 Examples:
   CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT UNIQUE);
@@ -229,7 +278,7 @@ func (r *REPL) listSchema() {
 		table, _ := r.db.GetTable(tableName)
 		fmt.Printf("\nTable: %s\n", tableName)
 		fmt.Printf("  Columns:\n")
-		for _, col := range table.Schema.Columns {
+		for _, col := range table.Schema().Columns {
 			constraints := ""
 			if col.PrimaryKey {
 				constraints += "PRIMARY KEY"
@@ -258,12 +307,15 @@ func (r *REPL) DescribeTable(tableName string) {
 	fmt.Println("  Name      | Type    | Constraints")
 	fmt.Println("  ----------|---------|------------")
 
-	for _, col := range table.Schema.Columns {
+	for _, col := range table.Schema().Columns {
 		constraints := ""
 		if col.PrimaryKey {
 			constraints = "PRIMARY KEY"
 		} else if col.Unique {
 			constraints = "UNIQUE"
+			if col.NullsNotDistinct {
+				constraints += " NULLS NOT DISTINCT"
+			}
 		}
 		if col.NotNull {
 			if constraints != "" {
@@ -275,10 +327,24 @@ func (r *REPL) DescribeTable(tableName string) {
 		fmt.Printf("  %-9s | %-7s | %s\n", col.Name, col.Type.String(), constraints)
 	}
 
-	fmt.Printf("\nIndexes: %d\n", len(table.Indexes))
+	secondary := table.SecondaryIndexes()
+	fmt.Printf("\nIndexes: %d\n", len(table.Indexes)+len(secondary))
 	for colName := range table.Indexes {
 		fmt.Printf("  - %s\n", colName)
 	}
+	for _, idx := range secondary {
+		line := fmt.Sprintf("  - %s (%s)", idx.Name, idx.Column)
+		if kind := idx.Kind(); kind != "" {
+			line += " " + kind
+		}
+		if len(idx.Include) > 0 {
+			line += " INCLUDE (" + strings.Join(idx.Include, ", ") + ")"
+		}
+		if idx.Partial() {
+			line += " WHERE " + idx.Predicate
+		}
+		fmt.Println(line)
+	}
 
 	fmt.Printf("\nForeign Keys: %d\n", len(table.ForeignKeys))
 	for _, fk := range table.ForeignKeys {
@@ -290,54 +356,68 @@ func (r *REPL) DescribeTable(tableName string) {
 	}
 
 	fmt.Printf("\nRows: %d\n", table.Count())
+	fmt.Printf("Created: %s\n", table.Created().Format(time.DateTime))
 }
 
+// ExportFile writes the database to a file as a script that \import reads
+// back: see schemadiff.Dump.
 func (r *REPL) ExportFile(filePath string) error {
-	var builder strings.Builder
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	err = schemadiff.Dump(f, r.db)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
 
-	tables := r.db.ListTables()
-	for _, tableName := range tables {
-		table, _ := r.db.GetTable(tableName)
+	fmt.Printf("Exported database to %s\n", filePath)
+	return nil
+}
 
-		builder.WriteString(fmt.Sprintf("CREATE TABLE %s (", tableName))
-		for i, col := range table.Schema.Columns {
-			if i > 0 {
-				builder.WriteString(", ")
-			}
-			constraints := ""
-			if col.PrimaryKey {
-				constraints = " PRIMARY KEY"
-			} else if col.Unique {
-				constraints = " UNIQUE"
-			}
-			if col.NotNull {
-				constraints += " NOT NULL"
-			}
-			builder.WriteString(fmt.Sprintf("%s %s%s", col.Name, col.Type.String(), constraints))
-		}
-		builder.WriteString(");\n")
-
-		for _, row := range table.Rows {
-			values := make([]string, row.Len())
-			for i := 0; i < row.Len(); i++ {
-				val, _ := row.Get(i)
-				if val.Type() == storage.TypeText {
-					values[i] = fmt.Sprintf("'%s'", val.ToString())
-				} else {
-					values[i] = val.ToString()
-				}
-			}
-			builder.WriteString(fmt.Sprintf("INSERT INTO %s VALUES (%s);\n", tableName, strings.Join(values, ", ")))
-		}
+func (r *REPL) ExportAudit(filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+
+	if err := r.db.ExportAudit(f); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	fmt.Printf("Exported %d audit entries to %s\n", len(r.db.AuditEntries()), filePath)
+	return nil
+}
 
-		builder.WriteString("\n")
+// ExportData writes a table or the result of a SELECT to a file. args is
+// the file path followed by a table name or query.
+func (r *REPL) ExportData(format export.Format, args string) error {
+	filePath, source, ok := strings.Cut(args, " ")
+	if !ok || strings.TrimSpace(source) == "" {
+		return fmt.Errorf("usage: \\%s file table|SELECT ...", format)
 	}
 
-	err := os.WriteFile(filePath, []byte(builder.String()), 0644)
+	data, err := export.Load(context.Background(), r.db, source)
 	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if err := export.Write(f, format, data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
-	fmt.Printf("Exported database to %s\n", filePath)
+	fmt.Printf("Exported %d row(s) to %s\n", len(data.Rows), filePath)
 	return nil
 }
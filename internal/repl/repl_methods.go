@@ -1,11 +1,13 @@
 package repl
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
 )
 
 func (r *REPL) ExecuteSQL(input string) error {
@@ -16,30 +18,104 @@ func (r *REPL) ExecuteSQL(input string) error {
 	if err != nil {
 		return err
 	}
+	return r.execute(stmt)
+}
 
-	result, err := r.exec.Execute(stmt)
+func (r *REPL) execute(stmt sql.Node) error {
+	result, err := r.session.Execute(stmt)
 	if err != nil {
 		return err
 	}
 
 	r.printResult(result)
+	r.printNotifications()
+	return nil
+}
+
+func (r *REPL) printNotifications() {
+	for _, n := range r.session.Notifications() {
+		fmt.Printf("Asynchronous notification \"%s\" with payload \"%s\" received.\n", n.Channel, n.Payload)
+	}
+}
+
+// FormatSQL prints the statements of input laid out by sql.Format, each
+// ended with a semicolon, without running them.
+func (r *REPL) FormatSQL(input string) error {
+	statements, err := sql.NewParser(sql.NewLexer(input)).ParseAll()
+	if err != nil {
+		return err
+	}
+	for i, stmt := range statements {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(sql.Format(stmt) + ";")
+	}
 	return nil
 }
 
+// ImportFileOnConflict imports a script as ImportFile does, with the
+// session's on_conflict setting set to policy while it runs, so that a
+// dump loaded again skips or replaces the rows already in the database.
+func (r *REPL) ImportFileOnConflict(filePath string, policy storage.ConflictPolicy) error {
+	previous, _ := r.session.Setting("on_conflict")
+	r.session.Set("on_conflict", policy.String())
+	defer r.session.Set("on_conflict", previous)
+	return r.ImportFile(filePath)
+}
+
+// ImportFile runs a script of semicolon-separated statements. The whole
+// file is parsed first, so every syntax error is reported at once and
+// nothing runs unless the script parses. A script without transaction
+// control or session statements runs as one batch, committed once, so it
+// is imported entirely or not at all. While a multi-row INSERT runs, how
+// many of its rows are in is shown on stderr.
 func (r *REPL) ImportFile(filePath string) error {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
-	statements := strings.Split(string(content), ";")
-	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
+	statements, err := sql.NewParser(sql.NewLexer(string(content))).ParseAll()
+	if err != nil {
+		return fmt.Errorf("%s: %w", filePath, err)
+	}
+	progressed := false
+	r.session.SetProgress(func(p sql.Progress) {
+		if p.Rows < p.Total {
+			fmt.Fprintf(os.Stderr, "\r%d of %d rows inserted into %s", p.Rows, p.Total, p.Table)
+			progressed = true
+		}
+	})
+	defer r.session.SetProgress(nil)
+	endProgress := func() {
+		if progressed {
+			fmt.Fprintln(os.Stderr)
+			progressed = false
+		}
+	}
+
+	if sql.CanBatch(statements) {
+		results, err := r.session.ExecuteBatch(context.Background(), statements, nil)
+		endProgress()
+		var batchErr *sql.BatchError
+		if errors.As(err, &batchErr) {
+			return fmt.Errorf("error executing statement %d, nothing imported: %w", batchErr.Index+1, batchErr.Err)
+		}
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			r.printResult(result)
 		}
-		if err := r.ExecuteSQL(stmt); err != nil {
-			return fmt.Errorf("error executing statement: %w", err)
+		r.printNotifications()
+	} else {
+		for i, stmt := range statements {
+			err := r.execute(stmt)
+			endProgress()
+			if err != nil {
+				return fmt.Errorf("error executing statement %d: %w", i+1, err)
+			}
 		}
 	}
 
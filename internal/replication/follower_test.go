@@ -0,0 +1,195 @@
+package replication_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/replication"
+	"github.com/mryan-3/rdbms/internal/server"
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+var quiet = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func run(t *testing.T, session *sql.Session, text string) *sql.Result {
+	t.Helper()
+	stmt, err := sql.NewParser(sql.NewLexer(text)).Parse()
+	if err != nil {
+		t.Fatalf("%s: %v", text, err)
+	}
+	result, err := session.Execute(stmt)
+	if err != nil {
+		t.Fatalf("%s: %v", text, err)
+	}
+	return result
+}
+
+// waitFor polls cond until it holds or a few seconds pass.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// follow starts a follower of the server at url on a new database.
+func follow(t *testing.T, url string) (*storage.Database, *replication.Follower) {
+	t.Helper()
+	db := storage.NewDatabase()
+	f, err := replication.NewFollower(db, url, quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Start()
+	t.Cleanup(f.Stop)
+	return db, f
+}
+
+// TestFollower checks that followers apply the primary's commits, that
+// the primary discards the WAL entries they have confirmed, and that a
+// follower starting after that catches up from a snapshot.
+func TestFollower(t *testing.T) {
+	primary := storage.NewDatabase()
+	srv := httptest.NewServer(server.New(primary, server.Config{}).Handler())
+	// Cleanups run last first: the followers stop before Close waits for
+	// their streams.
+	t.Cleanup(srv.Close)
+	session := sql.NewSession(primary)
+	defer session.Close()
+	run(t, session, "CREATE TABLE accounts (id INTEGER PRIMARY KEY, owner TEXT)")
+	run(t, session, "CREATE INDEX accounts_owner ON accounts (owner)")
+	run(t, session, "INSERT INTO accounts (id, owner) VALUES (1, 'ada'), (2, 'alan')")
+
+	first, f := follow(t, srv.URL)
+	waitFor(t, "the first follower to catch up", func() bool {
+		return first.WAL().LastLSN() == primary.WAL().LastLSN()
+	})
+	run(t, session, "UPDATE accounts SET owner = 'grace' WHERE id = 2")
+	last := primary.WAL().LastLSN()
+	waitFor(t, "the primary to discard the confirmed entries", func() bool {
+		return primary.WAL().Oldest() == last
+	})
+	if entries := primary.WAL().Since(0); len(entries) != 0 {
+		t.Errorf("primary keeps %d confirmed entries", len(entries))
+	}
+
+	second, _ := follow(t, srv.URL)
+	run(t, session, "INSERT INTO accounts (id, owner) VALUES (3, 'edsger')")
+	want := run(t, session, "SELECT id, owner FROM accounts WHERE owner = 'grace' OR id = 3 ORDER BY id").Rows
+	for name, replica := range map[string]*storage.Database{"first": first, "second": second} {
+		waitFor(t, name+" follower to catch up", func() bool {
+			return replica.WAL().LastLSN() == primary.WAL().LastLSN()
+		})
+		replicaSession := sql.NewSession(replica)
+		got := run(t, replicaSession, "SELECT id, owner FROM accounts WHERE owner = 'grace' OR id = 3 ORDER BY id").Rows
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s follower: got %v, want %v", name, got, want)
+		}
+		if table, _ := replica.GetTable("accounts"); len(table.SecondaryIndexes()) != 1 {
+			t.Errorf("%s follower: index not replicated", name)
+		}
+		replicaSession.Close()
+	}
+	if status := f.Status(); !status.Connected || status.AppliedLSN != primary.WAL().LastLSN() {
+		t.Errorf("status = %+v", status)
+	}
+	if replicas := primary.WAL().Replicas(); len(replicas) != 2 {
+		t.Errorf("primary holds the WAL for %v, want two replicas", replicas)
+	}
+}
+
+// TestFollowerApplyFails checks that an entry the follower cannot apply
+// whole leaves none of its changes behind, so that the follower applies
+// it once what stopped it is gone.
+func TestFollowerApplyFails(t *testing.T) {
+	primary := storage.NewDatabase()
+	srv := httptest.NewServer(server.New(primary, server.Config{}).Handler())
+	t.Cleanup(srv.Close)
+	session := sql.NewSession(primary)
+	defer session.Close()
+	run(t, session, "CREATE TABLE accounts (id INTEGER PRIMARY KEY, owner TEXT)")
+
+	replica, f := follow(t, srv.URL)
+	waitFor(t, "the follower to catch up", func() bool {
+		return replica.WAL().LastLSN() == primary.WAL().LastLSN()
+	})
+	// A row written on the replica alone makes the second insert of the
+	// next entry fail there.
+	table, err := replica.GetTable("accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.Insert(storage.NewRow([]storage.Value{storage.NewIntegerValue(2), storage.NewTextValue("stray")})); err != nil {
+		t.Fatal(err)
+	}
+	run(t, session, "INSERT INTO accounts (id, owner) VALUES (1, 'ada'), (2, 'alan')")
+	waitFor(t, "the follower to fail", func() bool { return f.Status().LastError != "" })
+	if _, ok := table.GetByPK(storage.NewIntegerValue(1)); ok {
+		t.Error("the failed entry's first insert was left applied")
+	}
+	if lsn := replica.WAL().LastLSN(); lsn == primary.WAL().LastLSN() {
+		t.Errorf("replica logged the failed entry as LSN %d", lsn)
+	}
+
+	if _, err := table.Delete(func(row *storage.Row) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the follower to apply the entry", func() bool {
+		return replica.WAL().LastLSN() == primary.WAL().LastLSN()
+	})
+	replicaSession := sql.NewSession(replica)
+	defer replicaSession.Close()
+	rows := run(t, replicaSession, "SELECT id, owner FROM accounts ORDER BY id").Rows
+	if want := [][]string{{"1", "ada"}, {"2", "alan"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("replica rows = %v, want %v", rows, want)
+	}
+}
+
+// TestPromote checks that a replica refuses writes until it is promoted,
+// and stops following the primary after.
+func TestPromote(t *testing.T) {
+	primary := storage.NewDatabase()
+	srv := httptest.NewServer(server.New(primary, server.Config{}).Handler())
+	t.Cleanup(srv.Close)
+	session := sql.NewSession(primary)
+	defer session.Close()
+	run(t, session, "CREATE TABLE accounts (id INTEGER PRIMARY KEY, owner TEXT)")
+
+	replica, f := follow(t, srv.URL)
+	waitFor(t, "the follower to catch up", func() bool {
+		return replica.WAL().LastLSN() == primary.WAL().LastLSN()
+	})
+	replicaSession := sql.NewSession(replica)
+	defer replicaSession.Close()
+	insert, err := sql.NewParser(sql.NewLexer("INSERT INTO accounts (id, owner) VALUES (1, 'ada')")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replicaSession.Execute(insert); !errors.Is(err, sql.ErrReadOnly) {
+		t.Errorf("insert on the replica: got %v, want ErrReadOnly", err)
+	}
+
+	f.Promote()
+	if status := f.Status(); !status.Promoted || replica.ReadOnly() {
+		t.Errorf("after promotion: status %+v, read-only %v", status, replica.ReadOnly())
+	}
+	if _, err := replicaSession.Execute(insert); err != nil {
+		t.Errorf("insert after promotion: %v", err)
+	}
+	run(t, session, "INSERT INTO accounts (id, owner) VALUES (2, 'alan')")
+	time.Sleep(50 * time.Millisecond)
+	rows := run(t, replicaSession, "SELECT id FROM accounts ORDER BY id").Rows
+	if !reflect.DeepEqual(rows, [][]string{{"1"}}) {
+		t.Errorf("promoted replica rows = %v, want only its own insert", rows)
+	}
+}
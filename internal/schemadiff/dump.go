@@ -0,0 +1,61 @@
+package schemadiff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Dump writes db to w as a script that recreates it when run on an empty
+// database: its tables, with their defaults, constraints and foreign keys,
+// their rows and their secondary indexes. The script is one transaction
+// with its constraints deferred, so rows may refer to rows inserted after
+// them. Tables are created in the order Diff creates them.
+//
+// The database has no sequences: the next automatic primary key continues
+// from the highest one inserted, as after restoring a backup.
+func Dump(w io.Writer, db *storage.Database) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "BEGIN;")
+	fmt.Fprintln(bw, "SET CONSTRAINTS ALL DEFERRED;")
+
+	all := tables(db)
+	for _, name := range creationOrder(all) {
+		t, err := db.GetTable(name)
+		if err != nil {
+			continue // dropped meanwhile
+		}
+		fmt.Fprintf(bw, "\n%s;\n", createTable(all[name]))
+
+		names := make([]string, len(t.Schema().Columns))
+		for i, col := range t.Schema().Columns {
+			names[i] = col.Name
+		}
+		columns := strings.Join(names, ", ")
+		for _, row := range t.Select(nil) {
+			values := make([]string, len(names))
+			for i := range values {
+				v, err := row.Get(i)
+				if err != nil {
+					v = storage.NullValue{}
+				}
+				values[i] = literal(v)
+			}
+			fmt.Fprintf(bw, "INSERT INTO %s (%s) VALUES (%s);\n", name, columns, strings.Join(values, ", "))
+		}
+	}
+
+	indexes := indexes(db)
+	if len(indexes) > 0 {
+		fmt.Fprintln(bw)
+	}
+	for _, name := range sortedNames(indexes) {
+		fmt.Fprintf(bw, "%s;\n", indexes[name])
+	}
+
+	fmt.Fprintln(bw, "\nCOMMIT;")
+	return bw.Flush()
+}
@@ -0,0 +1,329 @@
+// Package schemadiff compares the schemas of two databases and writes the
+// DDL that makes the first match the second: CREATE and DROP for whole
+// tables and secondary indexes, ALTER TABLE for the columns and
+// constraints of tables both have. Rows are not compared.
+//
+// Constraints added with ALTER TABLE are named as PostgreSQL names them
+// (users_pkey, users_email_key, tasks_user_id_fkey), since this database
+// does not name its own. Of the ALTER TABLE statements it writes, the
+// engine runs only ALTER COLUMN ... TYPE, so a diff that otherwise changes
+// an existing table is a script to review and apply elsewhere, or by
+// recreating the table.
+package schemadiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Diff returns the statements, without trailing semicolons, that turn
+// the schema of from into that of to, in an order that can be run: indexes
+// and foreign keys are dropped before the tables they depend on, and
+// tables are created before the indexes and foreign keys that need them.
+// Identical schemas give no statements.
+func Diff(from, to *storage.Database) []string {
+	current, target := tables(from), tables(to)
+	var dropIndexes, dropFKs, dropTables, createTables, alters, addFKs, createIndexes []string
+
+	for _, name := range creationOrder(current) {
+		if _, kept := target[name]; !kept {
+			dropTables = append([]string{"DROP TABLE " + name}, dropTables...)
+		}
+	}
+	for _, name := range creationOrder(target) {
+		t := target[name]
+		before, exists := current[name]
+		if !exists {
+			createTables = append(createTables, createTable(t))
+			continue
+		}
+		alters = append(alters, alterColumns(name, before.columns, t.columns)...)
+		for _, fk := range before.fks {
+			if !hasFK(t.fks, fk) {
+				dropFKs = append(dropFKs, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", name, fkName(name, fk)))
+			}
+		}
+		for _, fk := range t.fks {
+			if !hasFK(before.fks, fk) {
+				addFKs = append(addFKs, fmt.Sprintf("ALTER TABLE %s ADD FOREIGN KEY (%s) %s", name, strings.Join(fk.Columns, ", "), references(fk)))
+			}
+		}
+	}
+
+	currentIndexes, targetIndexes := indexes(from), indexes(to)
+	for _, name := range sortedNames(currentIndexes) {
+		if def, ok := targetIndexes[name]; !ok || def != currentIndexes[name] {
+			dropIndexes = append(dropIndexes, "DROP INDEX "+name)
+		}
+	}
+	for _, name := range sortedNames(targetIndexes) {
+		if def, ok := currentIndexes[name]; !ok || def != targetIndexes[name] {
+			createIndexes = append(createIndexes, targetIndexes[name])
+		}
+	}
+
+	var statements []string
+	for _, group := range [][]string{dropIndexes, dropFKs, dropTables, createTables, alters, addFKs, createIndexes} {
+		statements = append(statements, group...)
+	}
+	return statements
+}
+
+type table struct {
+	name    string
+	columns []*storage.Column
+	fks     []*storage.ForeignKey
+}
+
+func tables(db *storage.Database) map[string]*table {
+	result := make(map[string]*table)
+	for _, name := range db.ListTables() {
+		t, err := db.GetTable(name)
+		if err != nil {
+			continue // dropped meanwhile
+		}
+		result[name] = &table{name: name, columns: t.Schema().Columns, fks: t.GetForeignKeys()}
+	}
+	return result
+}
+
+// creationOrder sorts the tables so that each comes after the tables its
+// foreign keys refer to, and otherwise by name. Tables in a cycle of
+// references keep name order.
+func creationOrder(all map[string]*table) []string {
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var order []string
+	done := make(map[string]bool, len(names))
+	visiting := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		if done[name] || visiting[name] {
+			return
+		}
+		visiting[name] = true
+		for _, fk := range all[name].fks {
+			if _, ok := all[fk.RefTable]; ok && fk.RefTable != name {
+				visit(fk.RefTable)
+			}
+		}
+		visiting[name] = false
+		done[name] = true
+		order = append(order, name)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return order
+}
+
+func createTable(t *table) string {
+	defs := make([]string, 0, len(t.columns))
+	inline := make(map[string]*storage.ForeignKey)
+	var tableFKs []*storage.ForeignKey
+	for _, fk := range t.fks {
+		if len(fk.Columns) == 1 && inline[fk.Columns[0]] == nil {
+			inline[fk.Columns[0]] = fk
+		} else {
+			tableFKs = append(tableFKs, fk)
+		}
+	}
+	for _, col := range t.columns {
+		def := columnDefinition(col)
+		if fk := inline[col.Name]; fk != nil {
+			def += " " + references(fk)
+		}
+		defs = append(defs, def)
+	}
+	for _, fk := range tableFKs {
+		defs = append(defs, fmt.Sprintf("FOREIGN KEY (%s) %s", strings.Join(fk.Columns, ", "), references(fk)))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", t.name, strings.Join(defs, ", "))
+}
+
+func columnDefinition(col *storage.Column) string {
+	def := col.Name + " " + col.Type.String()
+	if col.PrimaryKey {
+		def += " PRIMARY KEY"
+	}
+	if col.Unique {
+		def += " UNIQUE"
+		if col.NullsNotDistinct {
+			def += " NULLS NOT DISTINCT"
+		}
+	}
+	if col.NotNull {
+		def += " NOT NULL"
+	}
+	if col.Default != nil {
+		def += " DEFAULT " + literal(col.Default)
+	}
+	return def
+}
+
+// alterColumns compares the columns of a table both databases have, by
+// name; their order is not compared.
+func alterColumns(name string, current, target []*storage.Column) []string {
+	var statements []string
+	alter := func(format string, args ...interface{}) {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ", name)+fmt.Sprintf(format, args...))
+	}
+	currentByName := make(map[string]*storage.Column, len(current))
+	for _, col := range current {
+		currentByName[col.Name] = col
+	}
+	targetByName := make(map[string]*storage.Column, len(target))
+	for _, col := range target {
+		targetByName[col.Name] = col
+	}
+
+	for _, col := range current {
+		if targetByName[col.Name] == nil {
+			alter("DROP COLUMN %s", col.Name)
+		}
+	}
+	for _, col := range target {
+		before := currentByName[col.Name]
+		if before == nil {
+			alter("ADD COLUMN %s", columnDefinition(col))
+			continue
+		}
+		if before.Type != col.Type {
+			alter("ALTER COLUMN %s TYPE %s", col.Name, col.Type)
+		}
+		if before.NotNull != col.NotNull {
+			if col.NotNull {
+				alter("ALTER COLUMN %s SET NOT NULL", col.Name)
+			} else {
+				alter("ALTER COLUMN %s DROP NOT NULL", col.Name)
+			}
+		}
+		if !sameDefault(before.Default, col.Default) {
+			if col.Default != nil {
+				alter("ALTER COLUMN %s SET DEFAULT %s", col.Name, literal(col.Default))
+			} else {
+				alter("ALTER COLUMN %s DROP DEFAULT", col.Name)
+			}
+		}
+		if before.PrimaryKey != col.PrimaryKey {
+			if col.PrimaryKey {
+				alter("ADD PRIMARY KEY (%s)", col.Name)
+			} else {
+				alter("DROP CONSTRAINT %s_pkey", name)
+			}
+		}
+		if before.Unique != col.Unique || before.Unique && before.NullsNotDistinct != col.NullsNotDistinct {
+			if before.Unique {
+				alter("DROP CONSTRAINT %s_%s_key", name, col.Name)
+			}
+			if col.Unique {
+				nulls := ""
+				if col.NullsNotDistinct {
+					nulls = " NULLS NOT DISTINCT"
+				}
+				alter("ADD UNIQUE%s (%s)", nulls, col.Name)
+			}
+		}
+	}
+	return statements
+}
+
+func sameDefault(a, b storage.Value) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Type() == b.Type() && a.Equals(b)
+}
+
+func references(fk *storage.ForeignKey) string {
+	return fmt.Sprintf("REFERENCES %s (%s)", fk.RefTable, strings.Join(fk.RefColumns, ", "))
+}
+
+func fkName(table string, fk *storage.ForeignKey) string {
+	return table + "_" + strings.Join(fk.Columns, "_") + "_fkey"
+}
+
+func hasFK(fks []*storage.ForeignKey, fk *storage.ForeignKey) bool {
+	for _, other := range fks {
+		if other.RefTable == fk.RefTable && strings.Join(other.Columns, ",") == strings.Join(fk.Columns, ",") &&
+			strings.Join(other.RefColumns, ",") == strings.Join(fk.RefColumns, ",") {
+			return true
+		}
+	}
+	return false
+}
+
+// indexes returns the CREATE INDEX statement of each secondary index, by
+// name, so that two indexes are the same when their statements are.
+func indexes(db *storage.Database) map[string]string {
+	result := make(map[string]string)
+	for _, name := range db.ListTables() {
+		t, err := db.GetTable(name)
+		if err != nil {
+			continue
+		}
+		for _, idx := range t.SecondaryIndexes() {
+			kind := "INDEX"
+			if idx.Kind() != "" {
+				kind = idx.Kind() + " INDEX"
+			}
+			stmt := fmt.Sprintf("CREATE %s %s ON %s (%s)", kind, idx.Name, idx.Table, idx.Column)
+			if len(idx.Include) > 0 {
+				stmt += " INCLUDE (" + strings.Join(idx.Include, ", ") + ")"
+			}
+			if idx.Predicate != "" {
+				stmt += " WHERE " + idx.Predicate
+			}
+			result[idx.Name] = stmt
+		}
+	}
+	return result
+}
+
+// literal renders v as SQL that reads back as the same value. Quotes in
+// text are doubled, and a backslash before a quote or at the end is
+// written twice, as the lexer reads it back as one there.
+func literal(v storage.Value) string {
+	switch v.Type() {
+	case storage.TypeText:
+		text := v.ToString()
+		var b strings.Builder
+		for i := 0; i < len(text); i++ {
+			switch {
+			case text[i] == '\'':
+				b.WriteByte('\'')
+			case text[i] == '\\' && (i == len(text)-1 || text[i+1] == '\''):
+				b.WriteByte('\\')
+			}
+			b.WriteByte(text[i])
+		}
+		return "'" + b.String() + "'"
+	case storage.TypeFloat:
+		// 2 would read back as an INTEGER.
+		if text := v.ToString(); !strings.ContainsAny(text, ".eEIN") {
+			return text + ".0"
+		}
+	case storage.TypeBoolean:
+		return "'" + v.ToString() + "'"
+	case storage.TypeInterval:
+		return "INTERVAL '" + v.ToString() + "'"
+	case storage.TypeNull:
+		return "NULL"
+	}
+	return v.ToString()
+}
+
+func sortedNames(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
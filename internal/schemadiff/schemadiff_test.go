@@ -0,0 +1,186 @@
+package schemadiff_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/schemadiff"
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+func load(t *testing.T, script string) *storage.Database {
+	t.Helper()
+	db := storage.NewDatabase()
+	if err := run(db, script); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func run(db *storage.Database, script string) error {
+	statements, err := sql.NewParser(sql.NewLexer(script)).ParseAll()
+	if err != nil {
+		return err
+	}
+	session := sql.NewSession(db)
+	defer session.Close()
+	for _, stmt := range statements {
+		if _, err := session.ExecuteWithParams(stmt, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestDiffTables(t *testing.T) {
+	from := load(t, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE logs (id INTEGER PRIMARY KEY, users_id INTEGER REFERENCES users);
+		CREATE INDEX logs_user ON logs (users_id);
+	`)
+	to := load(t, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE tasks (id INTEGER PRIMARY KEY, title TEXT NOT NULL DEFAULT 'todo', owner_id INTEGER REFERENCES users);
+		CREATE TABLE comments (id INTEGER PRIMARY KEY, task_id INTEGER REFERENCES tasks);
+		CREATE INDEX tasks_owner ON tasks (owner_id) INCLUDE (title);
+	`)
+
+	got := schemadiff.Diff(from, to)
+	want := []string{
+		"DROP INDEX logs_user",
+		"DROP TABLE logs",
+		"CREATE TABLE tasks (id INTEGER PRIMARY KEY, title TEXT NOT NULL DEFAULT 'todo', owner_id INTEGER REFERENCES users (id))",
+		"CREATE TABLE comments (id INTEGER PRIMARY KEY, task_id INTEGER REFERENCES tasks (id))",
+		"CREATE INDEX tasks_owner ON tasks (owner_id) INCLUDE (title)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff =\n%q\nwant\n%q", got, want)
+	}
+
+	// Running the diff makes the schemas the same.
+	for _, stmt := range got {
+		if err := run(from, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if again := schemadiff.Diff(from, to); len(again) != 0 {
+		t.Errorf("Diff after applying = %q, want none", again)
+	}
+}
+
+func TestDiffColumns(t *testing.T) {
+	from := load(t, `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER, email TEXT UNIQUE)`)
+	to := load(t, `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, age FLOAT DEFAULT 0.5, email TEXT, team TEXT UNIQUE NULLS NOT DISTINCT)`)
+
+	got := schemadiff.Diff(from, to)
+	want := []string{
+		"ALTER TABLE users ALTER COLUMN name SET NOT NULL",
+		"ALTER TABLE users ALTER COLUMN age TYPE FLOAT",
+		"ALTER TABLE users ALTER COLUMN age SET DEFAULT 0.5",
+		"ALTER TABLE users DROP CONSTRAINT users_email_key",
+		"ALTER TABLE users ADD COLUMN team TEXT UNIQUE NULLS NOT DISTINCT",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff =\n%q\nwant\n%q", got, want)
+	}
+
+	if same := schemadiff.Diff(to, to); len(same) != 0 {
+		t.Errorf("Diff of a schema with itself = %q, want none", same)
+	}
+}
+
+func TestDump(t *testing.T) {
+	db := load(t, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT UNIQUE, score FLOAT DEFAULT 1.0);
+		CREATE TABLE tasks (id INTEGER PRIMARY KEY, title TEXT DEFAULT 'it''s new', done BOOLEAN, every INTERVAL,
+			owner_id INTEGER REFERENCES users, parent_id INTEGER REFERENCES tasks);
+		CREATE INDEX tasks_owner ON tasks (owner_id) INCLUDE (title);
+		CREATE FULLTEXT INDEX ON tasks (title);
+		CREATE INDEX tasks_open ON tasks (id) WHERE title != 'it''s new';
+		INSERT INTO users (id, name, email, score) VALUES (1, 'O''Brien', NULL, 2.0), (2, '12', 'true', 2.5);
+		BEGIN;
+		SET CONSTRAINTS ALL DEFERRED;
+		INSERT INTO tasks (id, title, done, every, owner_id, parent_id) VALUES (1, 'child', 'false', INTERVAL '1 mon 2 days 03:00:00', 1, 2);
+		INSERT INTO tasks (id, title, done, every, owner_id, parent_id) VALUES (2, 'don\'t', 'true', NULL, 2, NULL);
+		COMMIT;
+	`)
+	// Backslashes before a quote or at the end, which only a bind
+	// parameter can put in text as it is.
+	session := sql.NewSession(db)
+	defer session.Close()
+	insert, err := sql.NewParser(sql.NewLexer("INSERT INTO users (id, name) VALUES ($1, $2)")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{`C:\`, `a\'b`, `x\\`, `\\'`} {
+		if _, err := session.ExecuteWithParams(insert, []storage.Value{storage.NewIntegerValue(int64(10 + i)), storage.NewTextValue(name)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var b strings.Builder
+	if err := schemadiff.Dump(&b, db); err != nil {
+		t.Fatal(err)
+	}
+	script := b.String()
+	for _, want := range []string{
+		"INSERT INTO users (id, name, email, score) VALUES (1, 'O''Brien', NULL, 2.0);",
+		"INSERT INTO tasks (id, title, done, every, owner_id, parent_id) VALUES (2, 'don\\\\''t', 'true', NULL, 2, NULL);",
+		"INSERT INTO users (id, name, email, score) VALUES (10, 'C:\\\\', NULL, NULL);",
+		"CREATE INDEX tasks_open ON tasks (id) WHERE title != 'it''s new';",
+		"title TEXT DEFAULT 'it''s new'",
+		"CREATE FULLTEXT INDEX tasks_title_idx ON tasks (title);",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("dump has no %q:\n%s", want, script)
+		}
+	}
+
+	restored := storage.NewDatabase()
+	if err := run(restored, script); err != nil {
+		t.Fatalf("%v\n%s", err, script)
+	}
+	if diff := schemadiff.Diff(db, restored); len(diff) != 0 {
+		t.Fatalf("restored schema differs: %q", diff)
+	}
+	for _, name := range []string{"users", "tasks"} {
+		before, _ := db.GetTable(name)
+		after, _ := restored.GetTable(name)
+		if got, want := rows(after), rows(before); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s rows = %q, want %q", name, got, want)
+		}
+	}
+
+	// rdbms dump reads a backup, which must hold the whole schema too.
+	var backup bytes.Buffer
+	if _, err := db.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+	fromBackup := storage.NewDatabase()
+	if _, err := fromBackup.RestoreBackup(&backup); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := schemadiff.Dump(&b, fromBackup); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != script {
+		t.Errorf("dump of a backup:\n%s\nwant\n%s", b.String(), script)
+	}
+}
+
+// rows renders a table's values with their types.
+func rows(table *storage.Table) [][]string {
+	var result [][]string
+	for _, row := range table.Select(nil) {
+		var values []string
+		for _, v := range row.Values {
+			values = append(values, v.Type().String()+" "+v.ToString())
+		}
+		result = append(result, values)
+	}
+	return result
+}
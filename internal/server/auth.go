@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	if !s.config.RequireAuth {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, password, ok := req.BasicAuth()
+		if !ok || !s.db.Authenticate(user, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="rdbms"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("authentication failed"))
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// requireAdmin lets only the users of Config.AdminUsers call next. Without
+// RequireAuth or admin users it lets everyone, as every client may write.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	if !s.config.RequireAuth && len(s.admins) == 0 {
+		return next
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, password, ok := req.BasicAuth()
+		if !ok || !s.db.Authenticate(user, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="rdbms"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("authentication failed"))
+			return
+		}
+		if !s.admins[user] {
+			writeError(w, http.StatusForbidden, fmt.Errorf("user %s is not an admin user", user))
+			return
+		}
+		next(w, req)
+	}
+}
+
+type userKey struct{}
+
+// userFromContext returns the user authenticated by the gRPC interceptors.
+func userFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	user, err := s.authenticateGRPC(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, userKey{}, user), req)
+}
+
+func (s *Server) streamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	user, err := s.authenticateGRPC(ss.Context())
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(ss.Context(), userKey{}, user)
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authenticatedStream) Context() context.Context {
+	return a.ctx
+}
+
+// authenticateGRPC checks an "authorization: Basic <base64 user:password>"
+// header, the same credentials HTTP clients send, and returns the user.
+func (s *Server) authenticateGRPC(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		encoded, found := strings.CutPrefix(value, "Basic ")
+		if !found {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		user, password, found := strings.Cut(string(decoded), ":")
+		if found && s.db.Authenticate(user, password) {
+			return user, nil
+		}
+	}
+	return "", status.Error(codes.Unauthenticated, "authentication failed")
+}
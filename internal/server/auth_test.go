@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/mryan-3/rdbms/internal/server/querypb"
+)
+
+func TestRequireAuth(t *testing.T) {
+	srv := newTestServer(t, Config{RequireAuth: true})
+	query := `{"sql": "SELECT 1"}`
+
+	resp := request(t, http.MethodPost, srv.URL+"/query", "", query)
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("without credentials: status %d, WWW-Authenticate %q; want 401 and a challenge",
+			resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+	// mallory is not a user, so no password is right.
+	if got := request(t, http.MethodPost, srv.URL+"/query", "mallory", query).StatusCode; got != http.StatusUnauthorized {
+		t.Errorf("unknown user: status %d, want %d", got, http.StatusUnauthorized)
+	}
+	if got := request(t, http.MethodPost, srv.URL+"/query", "bob", query).StatusCode; got != http.StatusOK {
+		t.Errorf("valid credentials: status %d, want %d", got, http.StatusOK)
+	}
+
+	// Probes need no credentials.
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if got := request(t, http.MethodGet, srv.URL+path, "", "").StatusCode; got != http.StatusOK {
+			t.Errorf("GET %s without credentials: status %d, want %d", path, got, http.StatusOK)
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	srv := newTestServer(t, Config{RequireAuth: true, AdminUsers: []string{"root"}})
+	// Past the check, this server is neither a replica nor in a cluster.
+	for _, endpoint := range []struct {
+		path   string
+		passed int
+	}{
+		{"/replication/promote", http.StatusConflict},
+		{"/cluster/join", http.StatusNotFound},
+	} {
+		for user, want := range map[string]int{
+			"":     http.StatusUnauthorized,
+			"bob":  http.StatusForbidden,
+			"root": endpoint.passed,
+		} {
+			if got := request(t, http.MethodPost, srv.URL+endpoint.path, user, "").StatusCode; got != want {
+				t.Errorf("POST %s as %q: status %d, want %d", endpoint.path, user, got, want)
+			}
+		}
+	}
+
+	// With no admin users, no one may.
+	srv = newTestServer(t, Config{RequireAuth: true})
+	if got := request(t, http.MethodPost, srv.URL+"/replication/promote", "root", "").StatusCode; got != http.StatusForbidden {
+		t.Errorf("POST /replication/promote without admin users: status %d, want %d", got, http.StatusForbidden)
+	}
+}
+
+// TestGRPCAuth checks the interceptors that make gRPC calls send the
+// credentials HTTP clients do.
+func TestGRPCAuth(t *testing.T) {
+	g, err := New(newTestDB(t), Config{RequireAuth: true}).GRPCServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := querypb.NewQueryServiceClient(conn)
+	req := &querypb.ExecuteRequest{Sql: "SELECT 1"}
+
+	basic := func(credentials string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(),
+			"authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	for name, ctx := range map[string]context.Context{
+		"without credentials": context.Background(),
+		"wrong password":      basic("bob:root-pw"),
+		"not basic":           metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer bob-pw"),
+	} {
+		if _, err := client.Execute(ctx, req); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Execute %s: got %v, want Unauthenticated", name, err)
+		}
+		stream, err := client.ExecuteStream(ctx, req)
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("ExecuteStream %s: got %v, want Unauthenticated", name, err)
+		}
+	}
+
+	if _, err := client.Execute(basic("bob:bob-pw"), req); err != nil {
+		t.Errorf("Execute with valid credentials: %v", err)
+	}
+	stream, err := client.ExecuteStream(basic("bob:bob-pw"), req)
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if err != nil {
+		t.Errorf("ExecuteStream with valid credentials: %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+)
+
+// handleBackup streams a backup of the database, if BACKUP statements are
+// allowed. Restore it with rdbms -restore.
+func (s *Server) handleBackup(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	if !s.isAllowed(&sql.BackupStatement{}) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("BACKUP statements are not allowed"))
+		return
+	}
+
+	name := fmt.Sprintf("rdbms-%s.backup", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	if _, err := s.db.Backup(w); err != nil {
+		s.db.Logger().Error("backup failed", "error", err)
+	}
+}
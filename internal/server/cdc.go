@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+type changeEvent struct {
+	LSN    uint64                 `json:"lsn"`
+	Time   time.Time              `json:"time"`
+	Table  string                 `json:"table"`
+	Op     storage.WALOp          `json:"op"`
+	Before map[string]interface{} `json:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty"`
+}
+
+// handleCDCStream streams committed changes as JSON lines. ?table= limits
+// the stream to one table and ?from= replays changes after that LSN first;
+// without it only new changes are sent.
+func (s *Server) handleCDCStream(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	from := s.db.WAL().LastLSN()
+	if v := req.URL.Query().Get("from"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid from LSN: %s", v))
+			return
+		}
+		from = n
+	}
+
+	table := req.URL.Query().Get("table")
+	if table != "" && !s.db.TableExists(table) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("table %s not found", table))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	s.db.FollowChanges(req.Context(), table, from, func(event storage.ChangeEvent) {
+		encoder.Encode(changeEvent{
+			LSN:    event.LSN,
+			Time:   event.Time,
+			Table:  event.Table,
+			Op:     event.Op,
+			Before: encodeRow(event.Before),
+			After:  encodeRow(event.After),
+		})
+		flusher.Flush()
+	})
+}
+
+func encodeRow(row map[string]storage.Value) map[string]interface{} {
+	if row == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(row))
+	for name, v := range row {
+		out[name] = encodeValue(v)
+	}
+	return out
+}
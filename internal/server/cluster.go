@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mryan-3/rdbms/internal/cluster"
+)
+
+// SetCluster enables the /cluster endpoints for node.
+func (s *Server) SetCluster(node *cluster.Node) {
+	s.cluster = node
+}
+
+type joinRequest struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+}
+
+func (s *Server) handleClusterStatus(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+	if s.cluster == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("clustering is not enabled"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.cluster.Status())
+}
+
+// handleClusterJoin adds the node described in the body to the cluster.
+// Only the leader accepts joins; other members answer 409 naming the leader.
+func (s *Server) handleClusterJoin(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+	if s.cluster == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("clustering is not enabled"))
+		return
+	}
+
+	var body joinRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if body.ID == "" || body.Addr == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("id and addr are required"))
+		return
+	}
+
+	if !s.cluster.IsLeader() {
+		writeError(w, http.StatusConflict, fmt.Errorf("not the cluster leader (leader: %s)", s.cluster.Status().LeaderAddr))
+		return
+	}
+	if err := s.cluster.Join(body.ID, body.Addr); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.cluster.Status())
+}
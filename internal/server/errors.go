@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+)
+
+// writeQueryError reports a failed statement with the HTTP status for its
+// kind and its SQLSTATE code.
+func writeQueryError(w http.ResponseWriter, err error) {
+	writeJSON(w, queryErrorStatus(err), errorResponse{Error: err.Error(), Code: sql.SQLState(err)})
+}
+
+func queryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, sql.ErrTableNotFound), errors.Is(err, sql.ErrPreparedTxNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, sql.ErrNotNullViolation), errors.Is(err, sql.ErrTypeMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, sql.ErrConstraintViolation), errors.Is(err, sql.ErrTableExists), errors.Is(err, sql.ErrPreparedTxExists):
+		return http.StatusConflict
+	case errors.Is(err, sql.ErrReadOnly):
+		return http.StatusForbidden
+	case errors.Is(err, sql.ErrHistoryUnavailable):
+		return http.StatusGone
+	case errors.Is(err, sql.ErrInternal):
+		return http.StatusInternalServerError
+	case errors.Is(err, sql.ErrStatementTimeout):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// queryError converts a failed statement to a gRPC status.
+func queryError(err error) error {
+	code := codes.InvalidArgument
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, sql.ErrTableNotFound), errors.Is(err, sql.ErrPreparedTxNotFound):
+		code = codes.NotFound
+	case errors.Is(err, sql.ErrTableExists), errors.Is(err, sql.ErrPrimaryKeyViolation), errors.Is(err, sql.ErrUniqueViolation),
+		errors.Is(err, sql.ErrPreparedTxExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, sql.ErrConstraintViolation), errors.Is(err, sql.ErrTransaction), errors.Is(err, sql.ErrReadOnly):
+		code = codes.FailedPrecondition
+	case errors.Is(err, sql.ErrUnsupported):
+		code = codes.Unimplemented
+	case errors.Is(err, sql.ErrHistoryUnavailable):
+		code = codes.OutOfRange
+	case errors.Is(err, sql.ErrMemoryLimit):
+		code = codes.ResourceExhausted
+	case errors.Is(err, sql.ErrInternal):
+		code = codes.Internal
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mryan-3/rdbms/internal/export"
+	"github.com/mryan-3/rdbms/internal/sql"
+)
+
+// handleExport downloads a table (?table=) or SELECT result (?sql=) as
+// Parquet or an Arrow IPC stream (?format=, default parquet).
+func (s *Server) handleExport(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	if !s.isAllowed(&sql.SelectStatement{}) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("SELECT statements are not allowed"))
+		return
+	}
+
+	query := req.URL.Query()
+	format := export.FormatParquet
+	if v := query.Get("format"); v != "" {
+		var err error
+		if format, err = export.ParseFormat(v); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	table, source := query.Get("table"), query.Get("sql")
+	name := "result"
+	switch {
+	case table != "" && source != "":
+		writeError(w, http.StatusBadRequest, fmt.Errorf("use either table or sql, not both"))
+		return
+	case table != "":
+		if !s.db.TableExists(table) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("table %s not found", table))
+			return
+		}
+		source, name = table, table
+	case source == "":
+		writeError(w, http.StatusBadRequest, fmt.Errorf("table or sql is required"))
+		return
+	}
+
+	data, err := export.Load(req.Context(), s.db, source)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	if format == export.FormatArrow {
+		w.Header().Set("Content-Type", "application/vnd.apache.arrow.stream")
+	} else {
+		w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+format.Extension()))
+	if err := export.Write(w, format, data); err != nil {
+		s.db.Logger().Error("export failed", "error", err)
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"github.com/mryan-3/rdbms/internal/server/querypb"
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+const defaultBatchSize = 500
+
+type preparedStatement struct {
+	stmt       sql.Node
+	paramCount int
+}
+
+type queryService struct {
+	querypb.UnimplementedQueryServiceServer
+
+	srv      *Server
+	mu       sync.RWMutex
+	prepared map[string]*preparedStatement
+	nextID   int
+}
+
+func newQueryService(srv *Server) *queryService {
+	return &queryService{
+		srv:      srv,
+		prepared: make(map[string]*preparedStatement),
+	}
+}
+
+// GRPCServer returns a gRPC server with the QueryService registered.
+func (s *Server) GRPCServer() (*grpc.Server, error) {
+	opts := make([]grpc.ServerOption, 0)
+	if s.config.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(s.config.TLSCertFile, s.config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	unary := []grpc.UnaryServerInterceptor{s.unaryLimit}
+	stream := []grpc.StreamServerInterceptor{s.streamLimit}
+	if s.config.RequireAuth {
+		unary = append(unary, s.unaryAuth)
+		stream = append(stream, s.streamAuth)
+	}
+	opts = append(opts, grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+
+	g := grpc.NewServer(opts...)
+	querypb.RegisterQueryServiceServer(g, newQueryService(s))
+	return g, nil
+}
+
+func (s *Server) ListenAndServeGRPC() error {
+	g, err := s.GRPCServer()
+	if err != nil {
+		return err
+	}
+
+	lis, err := s.limits.listen(s.config.GRPCAddr)
+	if err != nil {
+		return err
+	}
+	s.grpcListener = lis
+	return g.Serve(lis)
+}
+
+func (q *queryService) Execute(ctx context.Context, req *querypb.ExecuteRequest) (*querypb.ExecuteResponse, error) {
+	result, err := q.execute(ctx, req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &querypb.ExecuteResponse{
+		Columns:      result.Columns,
+		Rows:         protoRows(result.Values),
+		RowsAffected: int64(result.RowsAffected),
+		Message:      result.Message,
+	}, nil
+}
+
+// ExecuteStream sends the statement's rows in batches of req.BatchSize. A
+// SELECT that can stream sends each batch as soon as the scan fills it and
+// stops scanning once the client goes away; other statements send the rows
+// of their result. The first batch carries the columns.
+func (q *queryService) ExecuteStream(req *querypb.ExecuteRequest, stream querypb.QueryService_ExecuteStreamServer) error {
+	ctx := stream.Context()
+	batchSize := int(req.BatchSize)
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	batch := &querypb.RowBatch{}
+	sent := 0
+	var sendErr error
+	send := func() error {
+		sendErr = stream.Send(batch)
+		batch = &querypb.RowBatch{}
+		sent++
+		return sendErr
+	}
+	add := func(columns []string, row []storage.Value) error {
+		if sent == 0 && batch.Columns == nil {
+			batch.Columns = columns
+		}
+		batch.Rows = append(batch.Rows, protoRow(row))
+		if len(batch.Rows) < batchSize {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return send()
+	}
+
+	result, err := q.execute(ctx, req, add)
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if sent == 0 {
+		batch.Columns = result.Columns
+		batch.RowsAffected = int64(result.RowsAffected)
+		batch.Message = result.Message
+	}
+	for _, row := range result.Values {
+		if err := add(result.Columns, row); err != nil {
+			if sendErr != nil {
+				return sendErr
+			}
+			return queryError(err)
+		}
+	}
+	if sent == 0 || len(batch.Rows) > 0 {
+		return send()
+	}
+	return nil
+}
+
+func (q *queryService) Prepare(ctx context.Context, req *querypb.PrepareRequest) (*querypb.PrepareResponse, error) {
+	stmt, paramCount, err := sql.ParseContext(ctx, req.Sql)
+	if err != nil {
+		return nil, queryError(err)
+	}
+	if !q.srv.isAllowed(stmt) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s statements are not allowed", stmt.Type())
+	}
+
+	q.mu.Lock()
+	q.nextID++
+	id := fmt.Sprintf("stmt-%d", q.nextID)
+	q.prepared[id] = &preparedStatement{stmt: stmt, paramCount: paramCount}
+	q.mu.Unlock()
+
+	return &querypb.PrepareResponse{
+		StatementId:   id,
+		ParamCount:    int32(paramCount),
+		StatementType: stmt.Type().String(),
+	}, nil
+}
+
+// execute runs the request's statement in a new session, passing the rows
+// of a SELECT that can stream to rows when it is not nil; see
+// sql.Executor.SetRowFunc.
+func (q *queryService) execute(ctx context.Context, req *querypb.ExecuteRequest, rows func([]string, []storage.Value) error) (*sql.Result, error) {
+	var stmt sql.Node
+
+	if req.StatementId != "" {
+		q.mu.RLock()
+		prepared, ok := q.prepared[req.StatementId]
+		q.mu.RUnlock()
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "prepared statement %s not found", req.StatementId)
+		}
+		if len(req.Params) != prepared.paramCount {
+			return nil, status.Errorf(codes.InvalidArgument, "statement %s expects %d parameter(s), got %d",
+				req.StatementId, prepared.paramCount, len(req.Params))
+		}
+		stmt = prepared.stmt
+	} else {
+		parsed, _, err := sql.ParseContext(ctx, req.Sql)
+		if err != nil {
+			return nil, queryError(err)
+		}
+		if !q.srv.isAllowed(parsed) {
+			return nil, status.Errorf(codes.PermissionDenied, "%s statements are not allowed", parsed.Type())
+		}
+		stmt = parsed
+	}
+
+	params := make([]storage.Value, len(req.Params))
+	for i, p := range req.Params {
+		params[i] = fromProtoValue(p)
+	}
+
+	session := sql.NewSession(q.srv.db)
+	defer session.Close()
+	session.SetUser(userFromContext(ctx))
+	session.SetRowFunc(rows)
+
+	result, err := session.ExecuteContext(ctx, stmt, params)
+	if err != nil {
+		return nil, queryError(err)
+	}
+	return result, nil
+}
+
+func protoRows(rows [][]storage.Value) []*querypb.Row {
+	out := make([]*querypb.Row, len(rows))
+	for i, row := range rows {
+		out[i] = protoRow(row)
+	}
+	return out
+}
+
+func protoRow(row []storage.Value) *querypb.Row {
+	values := make([]*querypb.Value, len(row))
+	for i, v := range row {
+		values[i] = toProtoValue(v)
+	}
+	return &querypb.Row{Values: values}
+}
+
+func toProtoValue(v storage.Value) *querypb.Value {
+	switch val := v.(type) {
+	case *storage.IntegerValue:
+		return &querypb.Value{Kind: &querypb.Value_Integer{Integer: val.Value}}
+	case *storage.FloatValue:
+		return &querypb.Value{Kind: &querypb.Value_Float{Float: val.Value}}
+	case *storage.TextValue:
+		return &querypb.Value{Kind: &querypb.Value_Text{Text: val.Value}}
+	case *storage.BooleanValue:
+		return &querypb.Value{Kind: &querypb.Value_Boolean{Boolean: val.Value}}
+	case *storage.IntervalValue:
+		return &querypb.Value{Kind: &querypb.Value_Text{Text: val.ToString()}}
+	default:
+		return &querypb.Value{Kind: &querypb.Value_Null{Null: true}}
+	}
+}
+
+func fromProtoValue(v *querypb.Value) storage.Value {
+	switch kind := v.GetKind().(type) {
+	case *querypb.Value_Integer:
+		return storage.NewIntegerValue(kind.Integer)
+	case *querypb.Value_Float:
+		return storage.NewFloatValue(kind.Float)
+	case *querypb.Value_Text:
+		return storage.NewTextValue(kind.Text)
+	case *querypb.Value_Boolean:
+		return storage.NewBooleanValue(kind.Boolean)
+	default:
+		return storage.NullValue{}
+	}
+}
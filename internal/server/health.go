@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/replication"
+)
+
+const (
+	// probeTimeout bounds each readiness check, so a stuck lock reports
+	// unready instead of hanging the probe.
+	probeTimeout = 2 * time.Second
+
+	defaultMaxReplicationLag = 1000
+)
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// handleHealthz is a liveness probe: it succeeds while the process can
+// serve HTTP.
+func (s *Server) handleHealthz(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// handleReadyz is a readiness probe. It checks that the storage engine and
+// WAL respond and, on a replica or cluster member, that replication is
+// healthy. Any failing check makes it answer 503.
+func (s *Server) handleReadyz(w http.ResponseWriter, req *http.Request) {
+	checks := map[string]string{
+		"storage": probe(func() error {
+			s.db.ListTables()
+			return nil
+		}),
+		"wal": probe(func() error {
+			s.db.WAL().LastLSN()
+			return nil
+		}),
+	}
+	if s.follower != nil {
+		checks["replication"] = s.checkReplication()
+	}
+	if s.cluster != nil {
+		checks["cluster"] = s.checkCluster()
+	}
+
+	resp := healthResponse{Status: "ok", Checks: checks}
+	status := http.StatusOK
+	for _, result := range checks {
+		if result != "ok" {
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, status, resp)
+}
+
+func (s *Server) checkReplication() string {
+	st := s.follower.Status()
+	if st.Promoted {
+		return "ok"
+	}
+	if !st.Connected {
+		if st.LastError != "" {
+			return "not connected to primary: " + st.LastError
+		}
+		return "not connected to primary"
+	}
+	if since := time.Since(st.LastContact); since > 3*replication.HeartbeatInterval {
+		return fmt.Sprintf("no contact from primary for %s", since.Round(time.Second))
+	}
+
+	maxLag := s.config.MaxReplicationLag
+	if maxLag == 0 {
+		maxLag = defaultMaxReplicationLag
+	}
+	if st.Lag > maxLag {
+		return fmt.Sprintf("replication lag %d exceeds %d", st.Lag, maxLag)
+	}
+	return "ok"
+}
+
+func (s *Server) checkCluster() string {
+	st := s.cluster.Status()
+	if st.LeaderID == "" {
+		return "no cluster leader"
+	}
+	return "ok"
+}
+
+// probe runs check with a timeout and returns "ok" or the failure.
+func probe(check func() error) string {
+	done := make(chan error, 1)
+	go func() { done <- check() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return err.Error()
+		}
+		return "ok"
+	case <-time.After(probeTimeout):
+		return fmt.Sprintf("timed out after %s", probeTimeout)
+	}
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// connLimits counts client connections across the HTTP and gRPC listeners
+// and gives each connection its own query rate limiter.
+type connLimits struct {
+	maxConns int
+	rate     float64
+	burst    int
+
+	mu     sync.Mutex
+	active int
+}
+
+func newConnLimits(config Config) *connLimits {
+	burst := config.QueryBurst
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(config.QueryRate)))
+	}
+	return &connLimits{
+		maxConns: config.MaxConnections,
+		rate:     config.QueryRate,
+		burst:    burst,
+	}
+}
+
+// admit takes a connection slot for c if one is free.
+func (cl *connLimits) admit(c *clientConn) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if c.admitted {
+		return true
+	}
+	if cl.maxConns > 0 && cl.active >= cl.maxConns {
+		return false
+	}
+	cl.active++
+	c.admitted = true
+	return true
+}
+
+func (cl *connLimits) release(c *clientConn) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if c.admitted {
+		cl.active--
+		c.admitted = false
+	}
+}
+
+// clientConn is an accepted connection. Connections over the limit are
+// still accepted but only get retriable errors until a slot frees up.
+type clientConn struct {
+	net.Conn
+	listener *limitedListener
+	limiter  *rateLimiter
+	admitted bool
+	once     sync.Once
+}
+
+func (c *clientConn) Close() error {
+	c.once.Do(func() { c.listener.remove(c) })
+	return c.Conn.Close()
+}
+
+// limitedListener tracks the connections it accepts by remote address, so
+// requests can find the connection they arrived on.
+type limitedListener struct {
+	net.Listener
+	limits *connLimits
+
+	mu    sync.Mutex
+	conns map[string]*clientConn
+}
+
+func (l *limitedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &clientConn{Conn: conn, listener: l}
+	if l.limits.rate > 0 {
+		c.limiter = newRateLimiter(l.limits.rate, l.limits.burst)
+	}
+	l.limits.admit(c)
+
+	l.mu.Lock()
+	l.conns[conn.RemoteAddr().String()] = c
+	l.mu.Unlock()
+	return c, nil
+}
+
+func (l *limitedListener) remove(c *clientConn) {
+	l.limits.release(c)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if addr := c.RemoteAddr().String(); l.conns[addr] == c {
+		delete(l.conns, addr)
+	}
+}
+
+// lookup returns the connection from remoteAddr, or nil if l is nil or the
+// request did not arrive through it (e.g. Handler mounted elsewhere).
+func (l *limitedListener) lookup(remoteAddr string) *clientConn {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.conns[remoteAddr]
+}
+
+func (cl *connLimits) listen(addr string) (*limitedListener, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &limitedListener{
+		Listener: lis,
+		limits:   cl,
+		conns:    make(map[string]*clientConn),
+	}, nil
+}
+
+// limitRequests answers requests on connections over MaxConnections with
+// 503 and closes them, and requests over the connection's query rate with
+// 429. Both responses carry Retry-After.
+func (s *Server) limitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c := s.httpListener.lookup(req.RemoteAddr)
+		if c == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if !s.limits.admit(c) {
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusServiceUnavailable, fmt.Errorf("too many connections (limit %d); retry later", s.limits.maxConns))
+			return
+		}
+		if ok, wait := c.limiter.allow(); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, fmt.Errorf("query rate limit exceeded; retry in %s", wait.Round(time.Millisecond)))
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (s *Server) unaryLimit(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.checkGRPCLimits(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamLimit(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.checkGRPCLimits(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// checkGRPCLimits returns Unavailable on connections over MaxConnections and
+// ResourceExhausted over the query rate. Clients should retry both with
+// backoff.
+func (s *Server) checkGRPCLimits(ctx context.Context) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	c := s.grpcListener.lookup(p.Addr.String())
+	if c == nil {
+		return nil
+	}
+
+	if !s.limits.admit(c) {
+		return status.Errorf(codes.Unavailable, "too many connections (limit %d); retry later", s.limits.maxConns)
+	}
+	if ok, wait := c.limiter.allow(); !ok {
+		return status.Errorf(codes.ResourceExhausted, "query rate limit exceeded; retry in %s", wait.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// rateLimiter is a token bucket refilled at rate tokens per second up to
+// burst. A nil limiter allows everything.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// allow takes a token if one is available. Otherwise it reports how long
+// until the next one.
+func (r *rateLimiter) allow() (bool, time.Duration) {
+	if r == nil {
+		return true, 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.tokens = math.Min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	r.last = now
+
+	if r.tokens >= 1 {
+		r.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// TestMaxConnections checks that a connection over MaxConnections gets a
+// retriable 503 until another closes.
+func TestMaxConnections(t *testing.T) {
+	s := New(storage.NewDatabase(), Config{MaxConnections: 1})
+	lis, err := s.limits.listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.httpListener = lis
+	srv := httptest.NewUnstartedServer(s.Handler())
+	srv.Listener.Close()
+	srv.Listener = lis
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	// Each client keeps its own connection open between requests.
+	first := &http.Client{Transport: &http.Transport{}}
+	second := &http.Client{Transport: &http.Transport{}}
+	get := func(client *http.Client) *http.Response {
+		t.Helper()
+		resp, err := client.Get(srv.URL + "/replication/status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := get(first); resp.StatusCode != http.StatusOK {
+		t.Fatalf("first connection: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	resp := get(second)
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("second connection: status %d, Retry-After %q; want 503 with Retry-After",
+			resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	first.Transport.(*http.Transport).CloseIdleConnections()
+	deadline := time.Now().Add(5 * time.Second)
+	for get(second).StatusCode != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("second connection still refused after the first closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
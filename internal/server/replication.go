@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mryan-3/rdbms/internal/replication"
+)
+
+// SetFollower marks this server as a replica so /replication/status and
+// /replication/promote act on f.
+func (s *Server) SetFollower(f *replication.Follower) {
+	s.follower = f
+}
+
+// handleReplicationStream sends WAL entries after ?from= as JSON lines, then
+// keeps the connection open and sends new entries as they are committed.
+// The WAL keeps the entries for the replica named by ?replica= until it
+// confirms them (handleReplicationAck) or disconnects; a replica whose
+// entries are already gone is sent a snapshot to restore first.
+func (s *Server) handleReplicationStream(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	var from uint64
+	if v := req.URL.Query().Get("from"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid from LSN: %s", v))
+			return
+		}
+		from = n
+	}
+	name := req.URL.Query().Get("replica")
+	if name == "" {
+		// A follower that does not name itself cannot confirm entries, and
+		// holds them until it disconnects.
+		name = req.RemoteAddr
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	wal := s.db.WAL()
+	if from > wal.LastLSN() {
+		writeError(w, http.StatusConflict, fmt.Errorf("replica is ahead of primary: LSN %d > %d", from, wal.LastLSN()))
+		return
+	}
+	release := wal.HoldReplica(name, from)
+	defer release()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	if from < wal.Oldest() {
+		lsn, changes := s.db.Dump()
+		if err := encoder.Encode(replication.Snapshot{LSN: lsn, Changes: changes}); err != nil {
+			return
+		}
+		from = lsn
+	}
+	for {
+		for _, entry := range wal.Since(from) {
+			if err := encoder.Encode(entry); err != nil {
+				return
+			}
+			from = entry.LSN
+		}
+		flusher.Flush()
+
+		// While idle, send heartbeats so the replica can tell a quiet
+		// primary from a lost connection and measure its lag.
+		ctx, cancel := context.WithTimeout(req.Context(), replication.HeartbeatInterval)
+		err := wal.Wait(ctx, from)
+		cancel()
+		if req.Context().Err() != nil {
+			return
+		}
+		if err != nil {
+			if err := encoder.Encode(replication.Heartbeat{LSN: wal.LastLSN()}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleReplicationAck records that the replica named in the body has
+// applied the entries up to its LSN, which the WAL may then discard.
+func (s *Server) handleReplicationAck(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	var body replication.Ack
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if err := s.db.WAL().ConfirmReplica(body.Replica, body.LSN); err != nil {
+		writeError(w, slotErrorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleReplicationStatus(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	if s.follower == nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"role":     "primary",
+			"lsn":      s.db.WAL().LastLSN(),
+			"replicas": s.db.WAL().Replicas(),
+		})
+		return
+	}
+
+	status := s.follower.Status()
+	role := "replica"
+	if status.Promoted {
+		role = "primary"
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"role":        role,
+		"lsn":         status.AppliedLSN,
+		"replication": status,
+	})
+}
+
+// handleReplicationPromote stops following the primary and accepts writes.
+func (s *Server) handleReplicationPromote(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	if s.follower == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("server is not a replica"))
+		return
+	}
+
+	s.follower.Promote()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"role": "primary",
+		"lsn":  s.db.WAL().LastLSN(),
+	})
+}
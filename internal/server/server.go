@@ -0,0 +1,388 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/mryan-3/rdbms/internal/cluster"
+	"github.com/mryan-3/rdbms/internal/replication"
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+const defaultAddr = ":8090"
+
+type Config struct {
+	Addr string
+	// GRPCAddr is where the gRPC QueryService listens. Empty disables it.
+	GRPCAddr string
+	// AllowedStatements restricts /query to the listed statement kinds
+	// (e.g. "SELECT", "INSERT", "CREATE TABLE"). Empty allows everything.
+	AllowedStatements []string
+	// TLSCertFile and TLSKeyFile enable TLS on both listeners when set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// RequireAuth makes every request authenticate as a user created with
+	// CREATE USER, via HTTP Basic auth or the gRPC "authorization" header.
+	// /healthz and /readyz are always open.
+	RequireAuth bool
+	// AdminUsers may promote a replica (/replication/promote) and add
+	// cluster members (/cluster/join). With RequireAuth no other user may;
+	// without it, setting AdminUsers still makes those endpoints ask for
+	// one of their passwords.
+	AdminUsers []string
+	// MaxReplicationLag is how many WAL entries a replica may trail its
+	// primary before /readyz fails. Zero uses the default of 1000.
+	MaxReplicationLag uint64
+	// MaxConnections caps concurrent client connections across the HTTP
+	// and gRPC listeners. Requests on extra connections get a retriable
+	// error (HTTP 503, gRPC Unavailable). Zero means no limit.
+	MaxConnections int
+	// QueryRate limits each connection to this many requests per second,
+	// with bursts of up to QueryBurst (default: QueryRate rounded up).
+	// Requests over the rate get HTTP 429 or gRPC ResourceExhausted. Zero
+	// means no limit.
+	QueryRate  float64
+	QueryBurst int
+}
+
+type Server struct {
+	db       *storage.Database
+	config   Config
+	allowed  map[string]bool
+	admins   map[string]bool
+	follower *replication.Follower
+	cluster  *cluster.Node
+
+	limits       *connLimits
+	httpListener *limitedListener
+	grpcListener *limitedListener
+}
+
+func New(db *storage.Database, config Config) *Server {
+	if config.Addr == "" {
+		config.Addr = defaultAddr
+	}
+
+	allowed := make(map[string]bool)
+	for _, kind := range config.AllowedStatements {
+		allowed[strings.ToUpper(strings.TrimSpace(kind))] = true
+	}
+
+	admins := make(map[string]bool)
+	for _, user := range config.AdminUsers {
+		if user = strings.TrimSpace(user); user != "" {
+			admins[user] = true
+		}
+	}
+
+	return &Server{
+		db:      db,
+		config:  config,
+		allowed: allowed,
+		admins:  admins,
+		limits:  newConnLimits(config),
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/query", s.handleQuery)
+	mux.HandleFunc("/pipeline", s.handlePipeline)
+	mux.HandleFunc("/audit", s.handleAudit)
+	mux.HandleFunc("/backup", s.handleBackup)
+	mux.HandleFunc("/export", s.handleExport)
+	mux.HandleFunc("/replication/stream", s.handleReplicationStream)
+	mux.HandleFunc("/replication/ack", s.handleReplicationAck)
+	mux.HandleFunc("/replication/status", s.handleReplicationStatus)
+	mux.HandleFunc("/replication/promote", s.requireAdmin(s.handleReplicationPromote))
+	mux.HandleFunc("/replication/slots", s.handleSlots)
+	mux.HandleFunc("/replication/slots/", s.handleSlot)
+	mux.HandleFunc("/cdc/stream", s.handleCDCStream)
+	mux.HandleFunc("/cluster/status", s.handleClusterStatus)
+	mux.HandleFunc("/cluster/join", s.requireAdmin(s.handleClusterJoin))
+
+	// Probes bypass authentication and connection limits so orchestrators
+	// can always call them.
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", s.handleHealthz)
+	root.HandleFunc("/readyz", s.handleReadyz)
+	root.Handle("/", s.limitRequests(s.requireAuth(mux)))
+	return root
+}
+
+func (s *Server) ListenAndServe() error {
+	lis, err := s.limits.listen(s.config.Addr)
+	if err != nil {
+		return err
+	}
+	s.httpListener = lis
+
+	srv := &http.Server{Handler: s.Handler()}
+	if s.config.TLSCertFile != "" {
+		return srv.ServeTLS(lis, s.config.TLSCertFile, s.config.TLSKeyFile)
+	}
+	return srv.Serve(lis)
+}
+
+func (s *Server) Addr() string {
+	return s.config.Addr
+}
+
+type queryRequest struct {
+	SQL    string        `json:"sql"`
+	Params []interface{} `json:"params"`
+}
+
+type queryResponse struct {
+	Columns      []string        `json:"columns"`
+	Rows         [][]interface{} `json:"rows"`
+	RowsAffected int             `json:"rows_affected"`
+	Message      string          `json:"message,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	// Code is the PostgreSQL SQLSTATE of a failed statement.
+	Code string `json:"code,omitempty"`
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	var body queryRequest
+	decoder := json.NewDecoder(req.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	if strings.TrimSpace(body.SQL) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("sql is required"))
+		return
+	}
+
+	params, err := decodeParams(body.Params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Continue a trace started by the caller, if it sent a traceparent header.
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+	stmt, _, err := sql.ParseContext(ctx, body.SQL)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	if !s.isAllowed(stmt) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("%s statements are not allowed", stmt.Type()))
+		return
+	}
+
+	// Each request is its own session, so a statement never sees another
+	// client's open transaction or settings.
+	session := sql.NewSession(s.db)
+	defer session.Close()
+	if user, _, ok := req.BasicAuth(); ok && s.config.RequireAuth {
+		session.SetUser(user)
+	}
+
+	result, err := session.ExecuteContext(ctx, stmt, params)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, encodeResult(result))
+}
+
+type pipelineRequest struct {
+	Statements []queryRequest `json:"statements"`
+}
+
+type pipelineResponse struct {
+	Results []queryResponse `json:"results"`
+}
+
+type pipelineError struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+	// Statement is the index, from 0, of the statement that failed; it is
+	// absent when the batch failed to commit.
+	Statement *int `json:"statement,omitempty"`
+}
+
+// handlePipeline runs a list of statements in one request as a batch:
+// one transaction with a single commit, all of whose writes are undone if
+// one statement fails.
+func (s *Server) handlePipeline(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	var body pipelineRequest
+	decoder := json.NewDecoder(req.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if len(body.Statements) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("statements are required"))
+		return
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+	stmts := make([]sql.Node, len(body.Statements))
+	params := make([][]storage.Value, len(body.Statements))
+	for i, st := range body.Statements {
+		var err error
+		if params[i], err = decodeParams(st.Params); err != nil {
+			writeJSON(w, http.StatusBadRequest, pipelineError{Error: err.Error(), Statement: &i})
+			return
+		}
+		if stmts[i], _, err = sql.ParseContext(ctx, st.SQL); err != nil {
+			writeJSON(w, queryErrorStatus(err), pipelineError{Error: err.Error(), Code: sql.SQLState(err), Statement: &i})
+			return
+		}
+		if !s.isAllowed(stmts[i]) {
+			writeJSON(w, http.StatusForbidden, pipelineError{Error: fmt.Sprintf("%s statements are not allowed", stmts[i].Type()), Statement: &i})
+			return
+		}
+	}
+
+	session := sql.NewSession(s.db)
+	defer session.Close()
+	if user, _, ok := req.BasicAuth(); ok && s.config.RequireAuth {
+		session.SetUser(user)
+	}
+
+	results, err := session.ExecuteBatch(ctx, stmts, params)
+	if err != nil {
+		failed := pipelineError{Error: err.Error(), Code: sql.SQLState(err)}
+		var batchErr *sql.BatchError
+		if errors.As(err, &batchErr) {
+			failed.Statement = &batchErr.Index
+		}
+		writeJSON(w, queryErrorStatus(err), failed)
+		return
+	}
+
+	resp := pipelineResponse{Results: make([]queryResponse, len(results))}
+	for i, result := range results {
+		resp.Results[i] = encodeResult(result)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAudit exports the audit log as JSON lines.
+func (s *Server) handleAudit(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	s.db.ExportAudit(w)
+}
+
+func (s *Server) isAllowed(stmt sql.Node) bool {
+	if len(s.allowed) == 0 {
+		return true
+	}
+	return s.allowed[stmt.Type().String()]
+}
+
+func decodeParams(raw []interface{}) ([]storage.Value, error) {
+	params := make([]storage.Value, len(raw))
+	for i, p := range raw {
+		switch v := p.(type) {
+		case nil:
+			params[i] = storage.NullValue{}
+		case bool:
+			params[i] = storage.NewBooleanValue(v)
+		case string:
+			params[i] = storage.NewTextValue(v)
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				params[i] = storage.NewIntegerValue(n)
+			} else if f, err := v.Float64(); err == nil {
+				params[i] = storage.NewFloatValue(f)
+			} else {
+				return nil, fmt.Errorf("invalid numeric parameter %d: %s", i+1, v)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported type for parameter %d: %T", i+1, p)
+		}
+	}
+	return params, nil
+}
+
+func encodeResult(result *sql.Result) queryResponse {
+	resp := queryResponse{
+		Columns:      result.Columns,
+		RowsAffected: result.RowsAffected,
+		Message:      result.Message,
+	}
+
+	if result.Columns != nil {
+		resp.Rows = make([][]interface{}, 0, len(result.Values))
+	}
+	for _, row := range result.Values {
+		values := make([]interface{}, len(row))
+		for i, v := range row {
+			values[i] = encodeValue(v)
+		}
+		resp.Rows = append(resp.Rows, values)
+	}
+	return resp
+}
+
+func encodeValue(v storage.Value) interface{} {
+	switch val := v.(type) {
+	case *storage.IntegerValue:
+		return val.Value
+	case *storage.FloatValue:
+		if math.IsNaN(val.Value) || math.IsInf(val.Value, 0) {
+			return val.ToString()
+		}
+		return val.Value
+	case *storage.TextValue:
+		return val.Value
+	case *storage.BooleanValue:
+		return val.Value
+	case *storage.IntervalValue:
+		return val.ToString()
+	default:
+		return nil
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
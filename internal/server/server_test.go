@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// newTestDB returns a database with the users root and bob (passwords
+// "root-pw" and "bob-pw").
+func newTestDB(t *testing.T) *storage.Database {
+	t.Helper()
+	db := storage.NewDatabase()
+	for _, user := range []string{"root", "bob"} {
+		if err := db.CreateUser(user, user+"-pw"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// newTestServer serves a database from newTestDB with config on a test
+// server.
+func newTestServer(t *testing.T, config Config) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(New(newTestDB(t), config).Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// request sends body, or nothing if it is "", as user (with the password
+// newTestDB gives) unless user is "", and returns the response.
+func request(t *testing.T, method, url, user, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != "" {
+		req.SetBasicAuth(user, user+"-pw")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestAllowedStatements(t *testing.T) {
+	srv := newTestServer(t, Config{AllowedStatements: []string{"select", " CREATE TABLE"}})
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/query", `{"sql": "CREATE TABLE t (id INTEGER PRIMARY KEY)"}`, http.StatusOK},
+		{http.MethodPost, "/query", `{"sql": "SELECT id FROM t"}`, http.StatusOK},
+		{http.MethodPost, "/query", `{"sql": "INSERT INTO t (id) VALUES (1)"}`, http.StatusForbidden},
+		{http.MethodPost, "/pipeline", `{"statements": [{"sql": "SELECT id FROM t"}, {"sql": "DROP TABLE t"}]}`, http.StatusForbidden},
+		{http.MethodGet, "/export?table=t", "", http.StatusOK},
+		{http.MethodGet, "/backup", "", http.StatusForbidden},
+	} {
+		if got := request(t, tc.method, srv.URL+tc.path, "", tc.body).StatusCode; got != tc.want {
+			t.Errorf("%s %s %s: status %d, want %d", tc.method, tc.path, tc.body, got, tc.want)
+		}
+	}
+
+	srv = newTestServer(t, Config{AllowedStatements: []string{"BACKUP"}})
+	if got := request(t, http.MethodGet, srv.URL+"/backup", "", "").StatusCode; got != http.StatusOK {
+		t.Errorf("GET /backup with BACKUP allowed: status %d, want %d", got, http.StatusOK)
+	}
+	if got := request(t, http.MethodGet, srv.URL+"/export?table=t", "", "").StatusCode; got != http.StatusForbidden {
+		t.Errorf("GET /export without SELECT allowed: status %d, want %d", got, http.StatusForbidden)
+	}
+}
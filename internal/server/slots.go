@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+type slotRequest struct {
+	Name string `json:"name"`
+	LSN  uint64 `json:"lsn"`
+}
+
+type slotResponse struct {
+	storage.ReplicationSlot
+	Lag uint64 `json:"lag"`
+}
+
+// handleSlots lists the replication slots (GET) or creates one named in
+// the body (POST).
+func (s *Server) handleSlots(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		last := s.db.WAL().LastLSN()
+		slots := []slotResponse{}
+		for _, slot := range s.db.ReplicationSlots() {
+			slots = append(slots, slotResponse{slot, last - slot.ConfirmedLSN})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"slots": slots})
+	case http.MethodPost:
+		var body slotRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		slot, err := s.db.CreateReplicationSlot(body.Name)
+		if err != nil {
+			writeError(w, slotErrorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, slotResponse{slot, 0})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+	}
+}
+
+// handleSlot serves /replication/slots/NAME: DELETE drops the slot,
+// GET NAME/changes sends the WAL entries after its confirmed LSN as JSON
+// lines (at most ?limit=), and POST NAME/ack with {"lsn": N} confirms the
+// entries up to N.
+func (s *Server) handleSlot(w http.ResponseWriter, req *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/replication/slots/"), "/")
+	if name == "" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("replication slot name is required"))
+		return
+	}
+
+	method := map[string]string{"": http.MethodDelete, "changes": http.MethodGet, "ack": http.MethodPost}
+	allowed, ok := method[action]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown replication slot action %q", action))
+		return
+	}
+	if req.Method != allowed {
+		w.Header().Set("Allow", allowed)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+
+	switch action {
+	case "":
+		if err := s.db.DropReplicationSlot(name); err != nil {
+			writeError(w, slotErrorStatus(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "changes":
+		limit := 0
+		if v := req.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %s", v))
+				return
+			}
+			limit = n
+		}
+		entries, err := s.db.SlotChanges(name, limit)
+		if err != nil {
+			writeError(w, slotErrorStatus(err), err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return
+			}
+		}
+	case "ack":
+		var body slotRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		if err := s.db.AdvanceReplicationSlot(name, body.LSN); err != nil {
+			writeError(w, slotErrorStatus(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func slotErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrSlotNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrSlotExists):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrHistoryUnavailable):
+		return http.StatusGone
+	}
+	return http.StatusBadRequest
+}
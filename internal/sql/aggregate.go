@@ -0,0 +1,515 @@
+package sql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// aggregateRows groups rows by the GROUP BY columns and computes one
+// output row per group. Without GROUP BY all rows form a single group, so
+// "SELECT COUNT(*) FROM t" returns one row even when t is empty. Plain
+// columns in the SELECT list must be grouped on. HAVING keeps the groups
+// it matches, and ORDER BY refers to the output columns.
+func (e *Executor) aggregateRows(stmt *SelectStatement, rows []*storage.Row, tables map[string]*storage.Table, offsets map[string]int) (*Result, error) {
+	groupIndexes := make([]int, len(stmt.GroupBy))
+	grouped := make(map[int]bool, len(stmt.GroupBy))
+	for i, name := range stmt.GroupBy {
+		idx, err := e.resolveColumnIndex(columnRef(name, positionAt(stmt.GroupByPos, i)), tables, offsets)
+		if err != nil {
+			return nil, err
+		}
+		groupIndexes[i] = idx
+		grouped[idx] = true
+	}
+
+	// Each output column is a grouped column, or an aggregate or an
+	// expression of them evaluated as HAVING is.
+	type output struct {
+		colIndex int
+		expr     Expression
+	}
+	outputs := make([]output, len(stmt.Columns))
+	for i, col := range stmt.Columns {
+		switch expr := col.Expr.(type) {
+		case nil:
+			return nil, errorf(ErrGrouping, "SELECT * cannot be used with GROUP BY or aggregate functions")
+		case *ColumnRef:
+			idx, err := e.resolveColumnIndex(expr, tables, offsets)
+			if err != nil {
+				return nil, err
+			}
+			if !grouped[idx] {
+				err := errorf(ErrGrouping, "column %s must appear in the GROUP BY clause or be used in an aggregate function", col.Name)
+				return nil, positioned(err, col.Pos, col.Name, "add it to GROUP BY or wrap it in an aggregate such as COUNT("+col.Name+")")
+			}
+			outputs[i] = output{colIndex: idx}
+		default:
+			if err := e.checkHaving(expr, grouped, tables, offsets); err != nil {
+				return nil, err
+			}
+			outputs[i] = output{expr: expr}
+		}
+	}
+	if stmt.Having != nil {
+		if err := e.checkHaving(stmt.Having, grouped, tables, offsets); err != nil {
+			return nil, err
+		}
+	}
+
+	// Groups keep the order in which they are first seen.
+	type group struct {
+		rows []*storage.Row
+	}
+	groups := make([]*group, 0)
+	byKey := make(map[string]*group)
+	if len(stmt.GroupBy) == 0 {
+		groups = append(groups, &group{rows: rows})
+	} else {
+		for i, row := range rows {
+			if err := e.checkContext(i + 1); err != nil {
+				return nil, err
+			}
+			key := groupKey(row, groupIndexes)
+			g, ok := byKey[key]
+			if !ok {
+				g = &group{}
+				byKey[key] = g
+				groups = append(groups, g)
+				if err := e.mem.grow("aggregate", groupBytes+int64(len(key))); err != nil {
+					return nil, err
+				}
+			}
+			if err := e.mem.grow("aggregate", pointerSize); err != nil {
+				return nil, err
+			}
+			g.rows = append(g.rows, row)
+		}
+	}
+
+	result := &Result{
+		Columns: stmt.ColumnNames(),
+		Rows:    make([][]string, 0, len(groups)),
+	}
+	for _, g := range groups {
+		if stmt.Having != nil {
+			v, err := e.evaluateHaving(stmt.Having, g.rows, tables, offsets)
+			if err != nil {
+				return nil, err
+			}
+			if !e.getValueAsBool(v) {
+				continue
+			}
+		}
+		values := make([]storage.Value, len(outputs))
+		for i, out := range outputs {
+			if out.expr == nil {
+				values[i], _ = g.rows[0].Get(out.colIndex)
+				continue
+			}
+			v, err := e.evaluateHaving(out.expr, g.rows, tables, offsets)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		result.Values = append(result.Values, values)
+	}
+
+	if len(stmt.OrderBy) > 0 {
+		if err := sortOutput(result, stmt.OrderBy); err != nil {
+			return nil, err
+		}
+	}
+	for _, values := range result.Values {
+		strs := make([]string, len(values))
+		for i, v := range values {
+			strs[i] = v.ToString()
+		}
+		if err := e.mem.grow("project", resultRowBytes(strs)); err != nil {
+			return nil, err
+		}
+		result.Rows = append(result.Rows, strs)
+	}
+	return result, nil
+}
+
+// countOnly reports whether stmt only counts the rows of one table, as
+// SELECT COUNT(*) FROM t does, so the count can come from Table.Count
+// instead of a scan.
+func countOnly(stmt *SelectStatement) bool {
+	if len(stmt.Tables) != 1 || stmt.Tables[0].Function != nil || len(stmt.Joins) > 0 || stmt.Where != nil ||
+		len(stmt.GroupBy) > 0 || stmt.Having != nil || len(stmt.OrderBy) > 0 || len(stmt.Aggregates) == 0 {
+		return false
+	}
+	for _, call := range stmt.Aggregates {
+		if call.Name != "COUNT" || call.Distinct || call.Filter != nil || len(call.Arguments) != 1 {
+			return false
+		}
+		if ref, ok := call.Arguments[0].(*ColumnRef); !ok || ref.Column != "*" {
+			return false
+		}
+	}
+	for _, col := range stmt.Columns {
+		if col.Name != "COUNT(*)" {
+			return false
+		}
+	}
+	return true
+}
+
+// countRows answers a countOnly statement from the table's row count.
+func (e *Executor) countRows(stmt *SelectStatement, table *storage.Table) *Result {
+	n := table.Count()
+	e.traceStep("count", "table", stmt.Tables[0].String(), "rows", n)
+
+	result := &Result{Columns: stmt.ColumnNames()}
+	values := make([]storage.Value, len(stmt.Columns))
+	strs := make([]string, len(stmt.Columns))
+	for i := range stmt.Columns {
+		values[i] = storage.NewIntegerValue(int64(n))
+		strs[i] = values[i].ToString()
+	}
+	result.Values = [][]storage.Value{values}
+	result.Rows = [][]string{strs}
+	e.limitResult(result, stmt)
+	return result
+}
+
+// aggregateArgument checks the argument of an aggregate call, a column or
+// an expression of columns such as price * quantity, and returns a
+// function reading its value from a row, or nil for COUNT(*).
+func (e *Executor) aggregateArgument(call *FunctionCall, tables map[string]*storage.Table, offsets map[string]int) (func(*storage.Row) (storage.Value, error), error) {
+	if len(call.Arguments) != 1 {
+		return nil, fmt.Errorf("%s takes exactly one argument", call.Name)
+	}
+	arg := call.Arguments[0]
+	if colRef, ok := arg.(*ColumnRef); ok {
+		if colRef.Column == "*" {
+			if call.Name != "COUNT" {
+				return nil, errorf(ErrUnsupported, "%s(*) is not supported; only COUNT accepts *", call.Name)
+			}
+			return nil, nil
+		}
+		idx, err := e.resolveColumnIndex(colRef, tables, offsets)
+		if err != nil {
+			return nil, err
+		}
+		return func(row *storage.Row) (storage.Value, error) {
+			v, _ := row.Get(idx)
+			return v, nil
+		}, nil
+	}
+
+	var err error
+	Inspect(arg, func(expr Expression) bool {
+		switch expr := expr.(type) {
+		case *FunctionCall:
+			if aggregateFunctions[expr.Name] {
+				err = errorf(ErrGrouping, "aggregate function calls cannot be nested")
+				err = positioned(err, expr.Pos, expr.String(), "")
+			}
+		case *ColumnRef:
+			_, err = e.resolveColumnIndex(expr, tables, offsets)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(row *storage.Row) (storage.Value, error) {
+		return e.evaluateExpressionForJoinedRow(arg, row, tables, offsets)
+	}, nil
+}
+
+// checkHaving rejects a HAVING condition that reads a column neither
+// grouped on nor inside an aggregate.
+func (e *Executor) checkHaving(expr Expression, grouped map[int]bool, tables map[string]*storage.Table, offsets map[string]int) error {
+	switch expr := expr.(type) {
+	case *FunctionCall:
+		if aggregateFunctions[expr.Name] {
+			_, err := e.aggregateArgument(expr, tables, offsets)
+			return positioned(err, expr.Pos, expr.String(), "")
+		}
+		for _, arg := range expr.Arguments {
+			if err := e.checkHaving(arg, grouped, tables, offsets); err != nil {
+				return err
+			}
+		}
+	case *BinaryExpression:
+		if err := e.checkHaving(expr.Left, grouped, tables, offsets); err != nil {
+			return err
+		}
+		return e.checkHaving(expr.Right, grouped, tables, offsets)
+	case *UnaryExpression:
+		return e.checkHaving(expr.Right, grouped, tables, offsets)
+	case *InExpression:
+		for _, operand := range append([]Expression{expr.Left}, expr.Values...) {
+			if err := e.checkHaving(operand, grouped, tables, offsets); err != nil {
+				return err
+			}
+		}
+	case *CastExpression:
+		return e.checkHaving(expr.Expr, grouped, tables, offsets)
+	case *ColumnRef:
+		idx, err := e.resolveColumnIndex(expr, tables, offsets)
+		if err != nil {
+			return err
+		}
+		if !grouped[idx] {
+			err := errorf(ErrGrouping, "column %s must appear in the GROUP BY clause or be used in an aggregate function", expr)
+			return positioned(err, expr.Pos, expr.String(), "add it to GROUP BY or wrap it in an aggregate such as COUNT("+expr.String()+")")
+		}
+	}
+	return nil
+}
+
+// evaluateHaving evaluates a HAVING condition for a group: its aggregates
+// over the group's rows and its grouped columns in the first of them.
+func (e *Executor) evaluateHaving(expr Expression, rows []*storage.Row, tables map[string]*storage.Table, offsets map[string]int) (storage.Value, error) {
+	switch expr := expr.(type) {
+	case *FunctionCall:
+		if aggregateFunctions[expr.Name] {
+			arg, err := e.aggregateArgument(expr, tables, offsets)
+			if err != nil {
+				return nil, positioned(err, expr.Pos, expr.String(), "")
+			}
+			return e.aggregate(expr, arg, rows, tables, offsets)
+		}
+		args := make([]storage.Value, len(expr.Arguments))
+		for i, arg := range expr.Arguments {
+			v, err := e.evaluateHaving(arg, rows, tables, offsets)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return callScalar(expr, args)
+	case *BinaryExpression:
+		left, err := e.evaluateHaving(expr.Left, rows, tables, offsets)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.evaluateHaving(expr.Right, rows, tables, offsets)
+		if err != nil {
+			return nil, err
+		}
+		val, err := e.evaluateBinaryOp(left, expr.Op, right)
+		return val, positioned(err, expr.Pos, expr.String(), "")
+	case *UnaryExpression:
+		right, err := e.evaluateHaving(expr.Right, rows, tables, offsets)
+		if err != nil {
+			return nil, err
+		}
+		val, err := e.evaluateUnaryOp(expr.Op, right)
+		return val, positioned(err, expr.Pos, expr.String(), "")
+	case *InExpression:
+		return e.evaluateIn(expr, func(operand Expression) (storage.Value, error) {
+			return e.evaluateHaving(operand, rows, tables, offsets)
+		})
+	case *CastExpression:
+		return e.evaluateCast(expr, func(operand Expression) (storage.Value, error) {
+			return e.evaluateHaving(operand, rows, tables, offsets)
+		})
+	}
+	var row *storage.Row
+	if len(rows) > 0 {
+		row = rows[0]
+	}
+	return e.evaluateExpressionForJoinedRow(expr, row, tables, offsets)
+}
+
+// aggregate computes call over the values arg reads from the rows of one
+// group that pass its FILTER, using each value once for DISTINCT; arg is
+// nil for COUNT(*). As in standard SQL, NULLs
+// are skipped: COUNT(*) counts rows but COUNT(col) only non-NULL values,
+// and over no values COUNT is 0 while SUM, AVG, MIN and MAX are NULL.
+func (e *Executor) aggregate(call *FunctionCall, arg func(*storage.Row) (storage.Value, error), rows []*storage.Row, tables map[string]*storage.Table, offsets map[string]int) (storage.Value, error) {
+	switch call.Name {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+	default:
+		return nil, errorf(ErrUnsupported, "unsupported aggregate function: %s", call.Name)
+	}
+
+	n := 0
+	var values []storage.Value
+	var seen map[string]bool
+	if call.Distinct {
+		seen = make(map[string]bool)
+	}
+	for _, row := range rows {
+		if call.Filter != nil {
+			val, err := e.evaluateExpressionForJoinedRow(call.Filter, row, tables, offsets)
+			if err != nil {
+				return nil, err
+			}
+			if !e.getValueAsBool(val) {
+				continue
+			}
+		}
+		if arg == nil {
+			n++
+			continue
+		}
+		v, err := arg(row)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil || v.Type() == storage.TypeNull {
+			continue
+		}
+		if seen != nil {
+			var b strings.Builder
+			writeValueKey(&b, v)
+			key := b.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if err := e.mem.grow("aggregate", groupBytes+int64(len(key))); err != nil {
+				return nil, err
+			}
+		}
+		n++
+		if call.Name != "COUNT" {
+			values = append(values, v)
+		}
+	}
+
+	if call.Name == "COUNT" {
+		return storage.NewIntegerValue(int64(n)), nil
+	}
+	if len(values) == 0 {
+		return storage.NullValue{}, nil
+	}
+	switch call.Name {
+	case "MIN", "MAX":
+		best := values[0]
+		for _, v := range values[1:] {
+			c := compareValues(v, best)
+			if call.Name == "MIN" && c < 0 || call.Name == "MAX" && c > 0 {
+				best = v
+			}
+		}
+		return best, nil
+	}
+	return sumValues(call, values)
+}
+
+// sumValues computes SUM or AVG of values, which must be numbers. A SUM
+// of integers is an INTEGER, failing rather than wrapping around if it
+// overflows; otherwise, and for AVG, the result is a FLOAT.
+func sumValues(call *FunctionCall, values []storage.Value) (storage.Value, error) {
+	var isum int64
+	var fsum float64
+	floats, overflow := false, false
+	for _, v := range values {
+		switch v := v.(type) {
+		case *storage.IntegerValue:
+			next := isum + v.Value
+			overflow = overflow || (next > isum) != (v.Value > 0)
+			isum = next
+			fsum += float64(v.Value)
+		case *storage.FloatValue:
+			floats = true
+			fsum += v.Value
+		default:
+			err := errorf(ErrTypeMismatch, "%s cannot be applied to %s values", call.Name, v.Type())
+			return nil, positioned(err, call.Pos, call.String(), "")
+		}
+	}
+	switch {
+	case call.Name == "AVG":
+		return storage.NewFloatValue(fsum / float64(len(values))), nil
+	case floats:
+		return storage.NewFloatValue(fsum), nil
+	case overflow:
+		err := errorf(ErrTypeMismatch, "%s is out of range for INTEGER", call.String())
+		return nil, positioned(err, call.Pos, call.String(), "")
+	}
+	return storage.NewIntegerValue(isum), nil
+}
+
+// groupKey encodes a row's GROUP BY values so that equal values, including
+// NULLs, share a key.
+func groupKey(row *storage.Row, indexes []int) string {
+	var b strings.Builder
+	for _, idx := range indexes {
+		v, _ := row.Get(idx)
+		writeValueKey(&b, v)
+	}
+	return b.String()
+}
+
+func writeValueKey(b *strings.Builder, v storage.Value) {
+	if v == nil || v.Type() == storage.TypeNull {
+		b.WriteString("N;")
+		return
+	}
+	s := v.ToString()
+	fmt.Fprintf(b, "%d:%d:%s;", v.Type(), len(s), s)
+}
+
+// sortOutput orders aggregated rows by ORDER BY clauses naming output
+// columns, with the same NULL placement as sortRows.
+func sortOutput(result *Result, orderBy []OrderByClause) error {
+	indexes := make([]int, len(orderBy))
+	for i, ob := range orderBy {
+		indexes[i] = -1
+		for j, col := range result.Columns {
+			if strings.EqualFold(col, ob.Column) {
+				indexes[i] = j
+				break
+			}
+		}
+		if indexes[i] < 0 {
+			err := errorf(ErrGrouping, "ORDER BY %s: column must be in the select list of an aggregate query", ob.Column)
+			return positioned(err, ob.Pos, ob.Column, "")
+		}
+	}
+
+	sort.SliceStable(result.Values, func(i, j int) bool {
+		for k, ob := range orderBy {
+			c := compareValues(result.Values[i][indexes[k]], result.Values[j][indexes[k]])
+			if c == 0 {
+				continue
+			}
+			if ob.Asc {
+				return c < 0
+			}
+			return c > 0
+		}
+		return false
+	})
+	return nil
+}
+
+// aggregateCalls appends to calls the aggregates expr calls.
+func aggregateCalls(expr Expression, calls []*FunctionCall) []*FunctionCall {
+	Inspect(expr, func(expr Expression) bool {
+		if call, ok := expr.(*FunctionCall); ok && aggregateFunctions[call.Name] {
+			calls = append(calls, call)
+			return false
+		}
+		return true
+	})
+	return calls
+}
+
+// positionAt returns positions[i], or the zero Position for statements
+// built without the parser.
+func positionAt(positions []Position, i int) Position {
+	if i < len(positions) {
+		return positions[i]
+	}
+	return Position{}
+}
+
+func columnRef(name string, pos Position) *ColumnRef {
+	if table, column, found := strings.Cut(name, "."); found {
+		return &ColumnRef{Table: table, Column: column, Pos: pos}
+	}
+	return &ColumnRef{Column: name, Pos: pos}
+}
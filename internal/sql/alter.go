@@ -0,0 +1,39 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// executeAlterTable changes the type of a column, giving each row the
+// value of USING, or of its old value cast to the new type, converted to
+// that type. The first row that fails to convert fails the statement
+// with its number and leaves the table as it was.
+func (e *Executor) executeAlterTable(stmt *AlterTableStatement) (*Result, error) {
+	table, err := e.writableTable(stmt.Table)
+	if err != nil {
+		return nil, positioned(err, stmt.TablePos, stmt.Table, "")
+	}
+	typ, err := e.parseDataType(stmt.DataType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data type %s for column %s: %w", stmt.DataType, stmt.Column, err)
+	}
+
+	using := stmt.Using
+	if using == nil {
+		using = &CastExpression{Expr: &ColumnRef{Column: stmt.Column}, Type: stmt.DataType}
+	}
+	convert := func(row *storage.Row) (storage.Value, error) {
+		v, err := e.evaluateExpressionForRow(using, table, row)
+		if err != nil {
+			return nil, err
+		}
+		return castValue(v, typ)
+	}
+	if err := e.tx.AlterColumnType(table, stmt.Column, typ, convert); err != nil {
+		return nil, err
+	}
+
+	return &Result{Message: fmt.Sprintf("Table %s altered", stmt.Table)}, nil
+}
@@ -2,6 +2,8 @@ package sql
 
 import (
 	"fmt"
+	"strings"
+	"time"
 )
 
 type NodeType int
@@ -16,61 +18,234 @@ const (
 	NodeBeginTransactionStmt
 	NodeCommitStmt
 	NodeRollbackStmt
+	NodeListenStmt
+	NodeUnlistenStmt
+	NodeNotifyStmt
+	NodeSetStmt
+	NodeShowStmt
+	NodeCreateUserStmt
+	NodeDropUserStmt
+	NodeBackupStmt
+	NodeExplainStmt
+	NodeAttachStmt
+	NodeDetachStmt
+	NodeCreateIndexStmt
+	NodeDropIndexStmt
+	NodeSetConstraintsStmt
+	NodeAnalyzeStmt
+	NodeAlterTableStmt
+	NodePrepareTransactionStmt
+	NodeCommitPreparedStmt
+	NodeRollbackPreparedStmt
 )
 
+func (t NodeType) String() string {
+	switch t {
+	case NodeSelectStmt:
+		return "SELECT"
+	case NodeInsertStmt:
+		return "INSERT"
+	case NodeUpdateStmt:
+		return "UPDATE"
+	case NodeDeleteStmt:
+		return "DELETE"
+	case NodeCreateTableStmt:
+		return "CREATE TABLE"
+	case NodeDropTableStmt:
+		return "DROP TABLE"
+	case NodeBeginTransactionStmt:
+		return "BEGIN"
+	case NodeCommitStmt:
+		return "COMMIT"
+	case NodeRollbackStmt:
+		return "ROLLBACK"
+	case NodeListenStmt:
+		return "LISTEN"
+	case NodeUnlistenStmt:
+		return "UNLISTEN"
+	case NodeNotifyStmt:
+		return "NOTIFY"
+	case NodeSetStmt:
+		return "SET"
+	case NodeShowStmt:
+		return "SHOW"
+	case NodeCreateUserStmt:
+		return "CREATE USER"
+	case NodeDropUserStmt:
+		return "DROP USER"
+	case NodeBackupStmt:
+		return "BACKUP"
+	case NodeExplainStmt:
+		return "EXPLAIN"
+	case NodeAttachStmt:
+		return "ATTACH"
+	case NodeDetachStmt:
+		return "DETACH"
+	case NodeCreateIndexStmt:
+		return "CREATE INDEX"
+	case NodeDropIndexStmt:
+		return "DROP INDEX"
+	case NodeSetConstraintsStmt:
+		return "SET CONSTRAINTS"
+	case NodeAnalyzeStmt:
+		return "ANALYZE"
+	case NodeAlterTableStmt:
+		return "ALTER TABLE"
+	case NodePrepareTransactionStmt:
+		return "PREPARE TRANSACTION"
+	case NodeCommitPreparedStmt:
+		return "COMMIT PREPARED"
+	case NodeRollbackPreparedStmt:
+		return "ROLLBACK PREPARED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 type Node interface {
 	Type() NodeType
 	String() string
 }
 
 type SelectStatement struct {
-	Columns  []string
-	Tables   []TableRef
-	Where    Expression
-	Joins    []*JoinClause
-	OrderBy  []OrderByClause
-	Limit    *int
-	Offset   *int
-	Distinct bool
+	Columns    []SelectItem
+	Aggregates []*FunctionCall // called anywhere in Columns
+	Tables     []TableRef
+	Where      Expression
+	Joins      []*JoinClause
+	GroupBy    []string
+	GroupByPos []Position
+	Having     Expression // filters the groups; may call aggregates
+	OrderBy    []OrderByClause
+	Limit      *int
+	Offset     *int
+	Distinct   bool
+	AsOf       *time.Time // read the tables as they were at this time
+	Hints      []Hint     // from a /*+ ... */ comment after SELECT; see hints.go
+	// Compound holds the SELECTs combined with this one, in order; the
+	// ORDER BY, LIMIT and OFFSET above then apply to the combined rows.
+	// See setops.go.
+	Compound []SetOperation
+}
+
+// SelectItem is an entry of the SELECT list: a star ("*" or "t.*"), or an
+// expression and the name of the column it computes, which is the
+// expression's SQL text.
+type SelectItem struct {
+	Name string
+	Expr Expression // nil for a star
+	Pos  Position
+}
+
+// Star reports whether the item is * or t.*.
+func (i SelectItem) Star() bool {
+	return i.Expr == nil
+}
+
+// ColumnNames returns the names of the SELECT list's items.
+func (s *SelectStatement) ColumnNames() []string {
+	names := make([]string, len(s.Columns))
+	for i, col := range s.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// SetOperation is a UNION, INTERSECT or EXCEPT and the SELECT after it.
+type SetOperation struct {
+	Op     string // UNION, INTERSECT or EXCEPT
+	All    bool   // keep duplicates
+	Select *SelectStatement
+	Pos    Position
 }
 
+func (o SetOperation) String() string {
+	if o.All {
+		return o.Op + " ALL " + o.Select.String()
+	}
+	return o.Op + " " + o.Select.String()
+}
+
+// IsAggregate reports whether the SELECT collapses rows into groups, i.e.
+// it has a GROUP BY, a HAVING or an aggregate in its column list.
+func (s *SelectStatement) IsAggregate() bool {
+	return len(s.Aggregates) > 0 || len(s.GroupBy) > 0 || s.Having != nil
+}
+
+// TableRef is a table in the FROM list. Name may be qualified with the
+// alias of an attached database ("archive.users"). For a table function,
+// Function is the call and Name the function's name.
 type TableRef struct {
-	Name  string
-	Alias string
+	Name     string
+	Alias    string
+	Function *FunctionCall
+	Pos      Position
 }
 
-func (t TableRef) String() string {
+// RefName is the name the query refers to the table by: its alias, or its
+// name without a database.
+func (t TableRef) RefName() string {
 	if t.Alias != "" {
-		return fmt.Sprintf("%s AS %s", t.Name, t.Alias)
+		return t.Alias
+	}
+	if i := strings.LastIndex(t.Name, "."); i >= 0 {
+		return t.Name[i+1:]
 	}
 	return t.Name
 }
 
+func (t TableRef) String() string {
+	name := t.Name
+	if t.Function != nil {
+		name = t.Function.String()
+	}
+	if t.Alias != "" {
+		return fmt.Sprintf("%s AS %s", name, t.Alias)
+	}
+	return name
+}
+
 func (s *SelectStatement) Type() NodeType { return NodeSelectStmt }
 func (s *SelectStatement) String() string {
 	result := "SELECT "
+	if len(s.Hints) > 0 {
+		hints := make([]string, len(s.Hints))
+		for i, hint := range s.Hints {
+			hints[i] = hint.String()
+		}
+		result += "/*+ " + strings.Join(hints, " ") + " */ "
+	}
 	if s.Distinct {
 		result += "DISTINCT "
 	}
-	for i, col := range s.Columns {
-		if i > 0 {
-			result += ", "
-		}
-		result += col
+	result += strings.Join(s.ColumnNames(), ", ")
+	if len(s.Tables) > 0 {
+		result += " FROM "
 	}
-	result += " FROM "
 	for i, table := range s.Tables {
 		if i > 0 {
 			result += ", "
 		}
 		result += table.String()
 	}
+	if s.AsOf != nil {
+		result += " AS OF TIMESTAMP '" + s.AsOf.Format(time.RFC3339Nano) + "'"
+	}
 	if s.Where != nil {
 		result += " WHERE " + s.Where.String()
 	}
 	for _, join := range s.Joins {
 		result += " " + join.String()
 	}
+	if len(s.GroupBy) > 0 {
+		result += " GROUP BY " + strings.Join(s.GroupBy, ", ")
+	}
+	if s.Having != nil {
+		result += " HAVING " + s.Having.String()
+	}
+	for _, op := range s.Compound {
+		result += " " + op.String()
+	}
 	if len(s.OrderBy) > 0 {
 		result += " ORDER BY"
 		for i, ob := range s.OrderBy {
@@ -93,14 +268,18 @@ type JoinClause struct {
 	Type       string
 	Table      string
 	Alias      string
+	Function   *FunctionCall // for a table function; Table is its name
 	Conditions []Expression
+	Pos        Position // of the table name
+}
+
+// Ref returns the joined table as a TableRef.
+func (j *JoinClause) Ref() TableRef {
+	return TableRef{Name: j.Table, Alias: j.Alias, Function: j.Function, Pos: j.Pos}
 }
 
 func (j *JoinClause) String() string {
-	result := fmt.Sprintf("%s JOIN %s", j.Type, j.Table)
-	if j.Alias != "" {
-		result += fmt.Sprintf(" AS %s", j.Alias)
-	}
+	result := fmt.Sprintf("%s JOIN %s", j.Type, j.Ref())
 	if len(j.Conditions) > 0 {
 		result += " ON "
 		for i, cond := range j.Conditions {
@@ -116,6 +295,7 @@ func (j *JoinClause) String() string {
 type OrderByClause struct {
 	Column string
 	Asc    bool
+	Pos    Position
 }
 
 func (o *OrderByClause) String() string {
@@ -127,43 +307,52 @@ func (o *OrderByClause) String() string {
 }
 
 type InsertStatement struct {
-	Table   string
-	Columns []string
-	Values  [][]Expression
+	Table    string
+	TablePos Position
+	Columns  []string
+	Values   [][]Expression
 }
 
 func (s *InsertStatement) Type() NodeType { return NodeInsertStmt }
 func (s *InsertStatement) String() string {
-	result := fmt.Sprintf("INSERT INTO %s", s.Table)
+	return s.format(len(s.Values))
+}
+
+// format renders the statement with at most rows of its VALUES rows,
+// followed by a count of them all when it leaves some out.
+func (s *InsertStatement) format(rows int) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	b.WriteString(s.Table)
 	if len(s.Columns) > 0 {
-		result += " ("
-		for i, col := range s.Columns {
-			if i > 0 {
-				result += ", "
-			}
-			result += col
-		}
-		result += ")"
+		b.WriteString(" (")
+		b.WriteString(strings.Join(s.Columns, ", "))
+		b.WriteString(")")
 	}
-	result += " VALUES "
+	b.WriteString(" VALUES ")
 	for i, row := range s.Values {
+		if i == rows {
+			fmt.Fprintf(&b, ", ... (%d rows)", len(s.Values))
+			break
+		}
 		if i > 0 {
-			result += ", "
+			b.WriteString(", ")
 		}
-		result += "("
+		b.WriteString("(")
 		for j, val := range row {
 			if j > 0 {
-				result += ", "
+				b.WriteString(", ")
 			}
-			result += val.String()
+			b.WriteString(val.String())
 		}
-		result += ")"
+		b.WriteString(")")
 	}
-	return result
+	return b.String()
 }
 
 type UpdateStatement struct {
 	Table      string
+	TablePos   Position
 	SetClauses []SetClause
 	Where      Expression
 }
@@ -193,8 +382,9 @@ func (s *UpdateStatement) String() string {
 }
 
 type DeleteStatement struct {
-	Table string
-	Where Expression
+	Table    string
+	TablePos Position
+	Where    Expression
 }
 
 func (s *DeleteStatement) Type() NodeType { return NodeDeleteStmt }
@@ -210,15 +400,20 @@ type CreateTableStatement struct {
 	Table       string
 	Columns     []ColumnDefinition
 	ForeignKeys []ForeignKeyDefinition
+	Location    string // the CSV file of a CREATE EXTERNAL TABLE
+	Temporary   bool
+	OnCommit    string // of a temporary table: "DELETE ROWS" or "DROP"; empty preserves rows
 }
 
 type ColumnDefinition struct {
-	Name    string
-	Type    string
-	Primary bool
-	Unique  bool
-	NotNull bool
-	Default *Expression
+	Name             string
+	Type             string
+	Primary          bool
+	Unique           bool
+	NullsNotDistinct bool // UNIQUE NULLS NOT DISTINCT: at most one NULL
+	NotNull          bool
+	Default          *Expression
+	References       *ForeignKeyDefinition // REFERENCES table [(column)]
 }
 
 type ForeignKeyDefinition struct {
@@ -231,7 +426,22 @@ type ForeignKeyDefinition struct {
 
 func (s *CreateTableStatement) Type() NodeType { return NodeCreateTableStmt }
 func (s *CreateTableStatement) String() string {
-	result := fmt.Sprintf("CREATE TABLE %s (", s.Table)
+	if s.Location != "" {
+		result := "CREATE EXTERNAL TABLE " + s.Table
+		if len(s.Columns) > 0 {
+			cols := make([]string, len(s.Columns))
+			for i, col := range s.Columns {
+				cols[i] = col.Name + " " + col.Type
+			}
+			result += " (" + strings.Join(cols, ", ") + ")"
+		}
+		return result + fmt.Sprintf(" LOCATION '%s'", s.Location)
+	}
+	result := "CREATE TABLE "
+	if s.Temporary {
+		result = "CREATE TEMPORARY TABLE "
+	}
+	result += s.Table + " ("
 	for i, col := range s.Columns {
 		if i > 0 {
 			result += ", "
@@ -242,12 +452,24 @@ func (s *CreateTableStatement) String() string {
 		}
 		if col.Unique {
 			result += " UNIQUE"
+			if col.NullsNotDistinct {
+				result += " NULLS NOT DISTINCT"
+			}
 		}
 		if col.NotNull {
 			result += " NOT NULL"
 		}
+		if fk := col.References; fk != nil {
+			result += " REFERENCES " + fk.RefTable
+			if len(fk.RefColumns) > 0 {
+				result += " (" + strings.Join(fk.RefColumns, ", ") + ")"
+			}
+		}
 	}
 	result += ")"
+	if s.OnCommit != "" {
+		result += " ON COMMIT " + s.OnCommit
+	}
 	return result
 }
 
@@ -281,32 +503,337 @@ func (s *RollbackStatement) String() string {
 	return "ROLLBACK"
 }
 
+// PrepareTransactionStatement ends the session's transaction without
+// committing it, keeping it under GID for COMMIT PREPARED or ROLLBACK
+// PREPARED (two-phase commit).
+type PrepareTransactionStatement struct {
+	GID string
+}
+
+func (s *PrepareTransactionStatement) Type() NodeType { return NodePrepareTransactionStmt }
+func (s *PrepareTransactionStatement) String() string {
+	return fmt.Sprintf("PREPARE TRANSACTION '%s'", s.GID)
+}
+
+type CommitPreparedStatement struct {
+	GID string
+}
+
+func (s *CommitPreparedStatement) Type() NodeType { return NodeCommitPreparedStmt }
+func (s *CommitPreparedStatement) String() string {
+	return fmt.Sprintf("COMMIT PREPARED '%s'", s.GID)
+}
+
+type RollbackPreparedStatement struct {
+	GID string
+}
+
+func (s *RollbackPreparedStatement) Type() NodeType { return NodeRollbackPreparedStmt }
+func (s *RollbackPreparedStatement) String() string {
+	return fmt.Sprintf("ROLLBACK PREPARED '%s'", s.GID)
+}
+
+type ListenStatement struct {
+	Channel string
+}
+
+func (s *ListenStatement) Type() NodeType { return NodeListenStmt }
+func (s *ListenStatement) String() string {
+	return fmt.Sprintf("LISTEN %s", s.Channel)
+}
+
+type UnlistenStatement struct {
+	Channel string
+}
+
+func (s *UnlistenStatement) Type() NodeType { return NodeUnlistenStmt }
+func (s *UnlistenStatement) String() string {
+	return fmt.Sprintf("UNLISTEN %s", s.Channel)
+}
+
+type NotifyStatement struct {
+	Channel string
+	Payload Expression
+}
+
+func (s *NotifyStatement) Type() NodeType { return NodeNotifyStmt }
+func (s *NotifyStatement) String() string {
+	if s.Payload != nil {
+		return fmt.Sprintf("NOTIFY %s, %s", s.Channel, s.Payload.String())
+	}
+	return fmt.Sprintf("NOTIFY %s", s.Channel)
+}
+
+type SetStatement struct {
+	Name  string
+	Value string
+}
+
+func (s *SetStatement) Type() NodeType { return NodeSetStmt }
+func (s *SetStatement) String() string {
+	return fmt.Sprintf("SET %s = %s", s.Name, s.Value)
+}
+
+// SetConstraintsStatement is SET CONSTRAINTS ALL DEFERRED, which makes the
+// transaction check UNIQUE and FOREIGN KEY constraints at COMMIT instead of
+// after each statement, or SET CONSTRAINTS ALL IMMEDIATE.
+type SetConstraintsStatement struct {
+	Deferred bool
+}
+
+func (s *SetConstraintsStatement) Type() NodeType { return NodeSetConstraintsStmt }
+func (s *SetConstraintsStatement) String() string {
+	if s.Deferred {
+		return "SET CONSTRAINTS ALL DEFERRED"
+	}
+	return "SET CONSTRAINTS ALL IMMEDIATE"
+}
+
+// ShowStatement reads a session setting. Name "ALL" lists every setting.
+type ShowStatement struct {
+	Name string
+}
+
+func (s *ShowStatement) Type() NodeType { return NodeShowStmt }
+func (s *ShowStatement) String() string {
+	return fmt.Sprintf("SHOW %s", s.Name)
+}
+
+type CreateUserStatement struct {
+	Name     string
+	Password string
+}
+
+func (s *CreateUserStatement) Type() NodeType { return NodeCreateUserStmt }
+func (s *CreateUserStatement) String() string {
+	return fmt.Sprintf("CREATE USER %s WITH PASSWORD '***'", s.Name)
+}
+
+type DropUserStatement struct {
+	Name string
+}
+
+func (s *DropUserStatement) Type() NodeType { return NodeDropUserStmt }
+func (s *DropUserStatement) String() string {
+	return fmt.Sprintf("DROP USER %s", s.Name)
+}
+
+// BackupStatement writes a consistent copy of the database to a file on the
+// server.
+type BackupStatement struct {
+	Path string
+}
+
+func (s *BackupStatement) Type() NodeType { return NodeBackupStmt }
+func (s *BackupStatement) String() string {
+	return fmt.Sprintf("BACKUP TO '%s'", s.Path)
+}
+
+// AttachStatement makes the tables of a database saved with BACKUP TO
+// readable as alias.table.
+type AttachStatement struct {
+	Path  string
+	Alias string
+}
+
+func (s *AttachStatement) Type() NodeType { return NodeAttachStmt }
+func (s *AttachStatement) String() string {
+	return fmt.Sprintf("ATTACH '%s' AS %s", s.Path, s.Alias)
+}
+
+type DetachStatement struct {
+	Alias string
+}
+
+func (s *DetachStatement) Type() NodeType { return NodeDetachStmt }
+func (s *DetachStatement) String() string {
+	return "DETACH " + s.Alias
+}
+
+// CreateIndexStatement indexes Column of Table. Include lists columns
+// whose values the index stores alongside the key. With a Where clause it
+// is a partial index, holding only the rows the clause matches. A
+// FullText index holds the words of Column, for MATCH, and a Trigram index
+// its trigrams, for % and similarity. A Concurrently built index does not
+// block writes to Table while it is built.
+type CreateIndexStatement struct {
+	Name         string
+	Table        string
+	TablePos     Position
+	Column       string
+	Include      []string
+	Where        Expression
+	FullText     bool
+	Trigram      bool
+	Concurrently bool
+}
+
+func (s *CreateIndexStatement) Type() NodeType { return NodeCreateIndexStmt }
+func (s *CreateIndexStatement) String() string {
+	kind := "INDEX"
+	if s.FullText {
+		kind = "FULLTEXT INDEX"
+	} else if s.Trigram {
+		kind = "TRIGRAM INDEX"
+	}
+	if s.Concurrently {
+		kind += " CONCURRENTLY"
+	}
+	result := fmt.Sprintf("CREATE %s %s ON %s (%s)", kind, s.Name, s.Table, s.Column)
+	if len(s.Include) > 0 {
+		result += " INCLUDE (" + strings.Join(s.Include, ", ") + ")"
+	}
+	if s.Where != nil {
+		result += " WHERE " + s.Where.String()
+	}
+	return result
+}
+
+// AnalyzeStatement gathers statistics for Table, or for every table when
+// Table is empty.
+type AnalyzeStatement struct {
+	Table    string
+	TablePos Position
+}
+
+func (s *AnalyzeStatement) Type() NodeType { return NodeAnalyzeStmt }
+func (s *AnalyzeStatement) String() string {
+	if s.Table == "" {
+		return "ANALYZE"
+	}
+	return "ANALYZE " + s.Table
+}
+
+type DropIndexStatement struct {
+	Name string
+}
+
+func (s *DropIndexStatement) Type() NodeType { return NodeDropIndexStmt }
+func (s *DropIndexStatement) String() string {
+	return "DROP INDEX " + s.Name
+}
+
+// AlterTableStatement is ALTER TABLE Table ALTER COLUMN Column TYPE
+// DataType [USING Using]. Using gives each row's new value, reading the
+// row as it was; without it the old value is cast to DataType.
+type AlterTableStatement struct {
+	Table    string
+	TablePos Position
+	Column   string
+	DataType string
+	Using    Expression
+}
+
+func (s *AlterTableStatement) Type() NodeType { return NodeAlterTableStmt }
+func (s *AlterTableStatement) String() string {
+	result := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", s.Table, s.Column, s.DataType)
+	if s.Using != nil {
+		result += " USING " + s.Using.String()
+	}
+	return result
+}
+
 type Expression interface {
 	String() string
 }
 
+// Expressions built by the parser record where they start (for a binary
+// expression, its operator) so that errors found while evaluating them can
+// point into the statement. Pos is zero for expressions built in code.
+
 type BinaryExpression struct {
 	Left  Expression
 	Op    string
 	Right Expression
+	Pos   Position
 }
 
+// String parenthesizes operands that bind more loosely than e's operator
+// (or as loosely, on the right), so the text parses back to the same tree.
 func (e *BinaryExpression) String() string {
-	return fmt.Sprintf("%s %s %s", e.Left.String(), e.Op, e.Right.String())
+	prec := precedence(e.Op)
+	return fmt.Sprintf("%s %s %s", operand(e.Left, prec), e.Op, operand(e.Right, prec+1))
+}
+
+// precedence ranks binary operators as the parser does, loosest first.
+func precedence(op string) int {
+	switch op {
+	case "OR":
+		return 1
+	case "AND":
+		return 2
+	case "+", "-":
+		return 4
+	case "*", "/":
+		return 5
+	}
+	return 3 // comparisons and LIKE
+}
+
+// operand renders expr, in parentheses if it is a binary expression whose
+// operator binds less tightly than min, or an IN, which binds as a
+// comparison.
+func operand(expr Expression, min int) string {
+	if b, ok := expr.(*BinaryExpression); ok && precedence(b.Op) < min {
+		return "(" + b.String() + ")"
+	}
+	if in, ok := expr.(*InExpression); ok && precedence("IN") < min {
+		return "(" + in.String() + ")"
+	}
+	return expr.String()
 }
 
 type UnaryExpression struct {
 	Op    string
 	Right Expression
+	Pos   Position
 }
 
 func (e *UnaryExpression) String() string {
-	return fmt.Sprintf("%s %s", e.Op, e.Right.String())
+	return fmt.Sprintf("%s %s", e.Op, operand(e.Right, 3))
+}
+
+// InExpression is Left [NOT] IN (Values...): whether Left equals one of
+// the values. With Subquery set, Left [NOT] IN (SELECT ...), the values
+// are the rows the subquery returns (see subquery.go).
+type InExpression struct {
+	Left     Expression
+	Values   []Expression
+	Subquery *SelectStatement
+	Not      bool
+	Pos      Position
+}
+
+func (e *InExpression) String() string {
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = v.String()
+	}
+	if e.Subquery != nil {
+		values = []string{e.Subquery.String()}
+	}
+	op := " IN ("
+	if e.Not {
+		op = " NOT IN ("
+	}
+	return operand(e.Left, precedence("IN")+1) + op + strings.Join(values, ", ") + ")"
+}
+
+// CastExpression is CAST(Expr AS Type).
+type CastExpression struct {
+	Expr Expression
+	Type string
+	Pos  Position
+}
+
+func (e *CastExpression) String() string {
+	return fmt.Sprintf("CAST(%s AS %s)", e.Expr, e.Type)
 }
 
 type ColumnRef struct {
 	Table  string
 	Column string
+	Pos    Position
 }
 
 func (e *ColumnRef) String() string {
@@ -318,25 +845,72 @@ func (e *ColumnRef) String() string {
 
 type LiteralExpression struct {
 	Value string
+	// Quoted is set for a string literal. Its value is still read as a
+	// number or boolean when it looks like one, except into a TEXT column.
+	Quoted bool
 }
 
 func (e *LiteralExpression) String() string {
 	return e.Value
 }
 
+// Parameter is a bind placeholder (? or $N). Index is 1-based.
+type Parameter struct {
+	Index int
+}
+
+func (e *Parameter) String() string {
+	return fmt.Sprintf("$%d", e.Index)
+}
+
 type NullLiteral struct{}
 
 func (e *NullLiteral) String() string {
 	return "NULL"
 }
 
+// IntervalLiteral is INTERVAL '1 week': an interval written as quantities
+// and units (see storage.ParseInterval).
+type IntervalLiteral struct {
+	Value string
+	Pos   Position
+}
+
+func (e *IntervalLiteral) String() string {
+	return "INTERVAL '" + e.Value + "'"
+}
+
+// SystemFunction is a call to one of the functions that report on the
+// server and session, such as VERSION() or CURRENT_USER (see
+// systemFunctions). Name is upper case.
+type SystemFunction struct {
+	Name string
+	Pos  Position
+}
+
+func (e *SystemFunction) String() string {
+	if e.Name == "CURRENT_USER" {
+		return e.Name
+	}
+	return e.Name + "()"
+}
+
+// FunctionCall is an aggregate call, a table function in FROM or a scalar
+// function in an expression. Distinct counts each argument value once;
+// Filter, from FILTER (WHERE ...), limits the rows an aggregate reads.
 type FunctionCall struct {
 	Name      string
 	Arguments []Expression
+	Distinct  bool
+	Filter    Expression
+	Pos       Position
 }
 
 func (e *FunctionCall) String() string {
 	result := e.Name + "("
+	if e.Distinct {
+		result += "DISTINCT "
+	}
 	for i, arg := range e.Arguments {
 		if i > 0 {
 			result += ", "
@@ -344,5 +918,30 @@ func (e *FunctionCall) String() string {
 		result += arg.String()
 	}
 	result += ")"
+	if e.Filter != nil {
+		result += " FILTER (WHERE " + e.Filter.String() + ")"
+	}
 	return result
 }
+
+// ExplainStatement shows the plan for Statement without running it, as an
+// indented tree (FORMAT TEXT, the default) or a Graphviz graph (FORMAT
+// DOT). With ANALYZE the statement is run and the rows it returned and the
+// memory it used are added below the plan.
+type ExplainStatement struct {
+	Format    string
+	Analyze   bool
+	Statement Node
+}
+
+func (s *ExplainStatement) Type() NodeType { return NodeExplainStmt }
+func (s *ExplainStatement) String() string {
+	result := "EXPLAIN "
+	if s.Format == "DOT" {
+		result += "(FORMAT DOT) "
+	}
+	if s.Analyze {
+		result += "ANALYZE "
+	}
+	return result + s.Statement.String()
+}
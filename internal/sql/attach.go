@@ -0,0 +1,54 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// executeAttach loads a database saved with BACKUP TO and attaches it
+// under the statement's alias. The attached copy is read-only: writes to
+// the file after ATTACH are not seen until it is detached and attached
+// again.
+func (e *Executor) executeAttach(stmt *AttachStatement) (*Result, error) {
+	if !e.db.FileReads() {
+		return nil, errorf(ErrUnsupported, "cannot attach %s: this server does not allow reading files", stmt.Path)
+	}
+	if _, exists := e.db.Attached(stmt.Alias); exists {
+		return nil, errorf(ErrTableExists, "database %s is already attached", stmt.Alias)
+	}
+
+	other := storage.NewDatabase()
+	info, err := other.RestoreBackupFile(stmt.Path)
+	if err != nil {
+		return nil, err
+	}
+	other.SetReadOnly(true)
+	if err := e.db.Attach(stmt.Alias, other); err != nil {
+		return nil, err
+	}
+	return &Result{Message: fmt.Sprintf("Attached %s as %s (%d tables, %d rows)", stmt.Path, stmt.Alias, info.Tables, info.Rows)}, nil
+}
+
+// resolveDatabase splits a table name qualified with a database alias
+// ("archive.users") into the database and the table's name. Unqualified
+// names and "main." refer to the executor's own database, unless the name
+// is that of a temporary table.
+func (e *Executor) resolveDatabase(name string) (*storage.Database, string, error) {
+	if table, temp := e.temporary(name); temp {
+		return e.temporaryTables(), table, nil
+	}
+	alias, table, qualified := strings.Cut(name, ".")
+	if !qualified {
+		return e.db, name, nil
+	}
+	if alias == storage.MainDatabase {
+		return e.db, table, nil
+	}
+	other, ok := e.db.Attached(alias)
+	if !ok {
+		return nil, "", errorf(ErrTableNotFound, "database %s is not attached", alias)
+	}
+	return other, table, nil
+}
@@ -0,0 +1,61 @@
+package sql
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// executeBackup writes a backup of the database to the statement's path,
+// which must be inside the backup directory when the database has one;
+// see storage.Database.SetBackupDir.
+func (e *Executor) executeBackup(stmt *BackupStatement) (*Result, error) {
+	path, err := e.backupPath(stmt.Path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := e.db.BackupToFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Message: fmt.Sprintf("Backup written to %s (%d tables, %d indexes, %d rows, LSN %d)",
+		path, info.Tables, info.Indexes, info.Rows, info.LSN)}, nil
+}
+
+// backupPath resolves where BACKUP TO path writes. With a backup
+// directory, a relative path is taken from it, and the file, once
+// symbolic links in its directory are followed, must be inside it.
+func (e *Executor) backupPath(path string) (string, error) {
+	dir, limited := e.db.BackupDir()
+	if !limited {
+		return path, nil
+	}
+	if dir == "" {
+		return "", errorf(ErrUnsupported, "cannot back up to %s: this server does not allow writing files", path)
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err == nil {
+		root, err = filepath.Abs(root)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid backup directory: %w", err)
+	}
+
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err == nil {
+		parent, err = filepath.Abs(parent)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup file: %w", err)
+	}
+	target = filepath.Join(parent, filepath.Base(target))
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errorf(ErrUnsupported, "cannot back up to %s: the file must be inside the server's backup directory", path)
+	}
+	return target, nil
+}
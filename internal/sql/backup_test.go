@@ -0,0 +1,61 @@
+package sql_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// TestBackupDir checks that BACKUP TO is refused without a backup
+// directory and writes only inside one.
+func TestBackupDir(t *testing.T) {
+	db := storage.NewDatabase()
+	session := sql.NewSession(db)
+	defer session.Close()
+	if _, err := execSQL(session, "CREATE TABLE t (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+	dir, outside := t.TempDir(), t.TempDir()
+
+	db.SetBackupDir("")
+	target := filepath.Join(dir, "refused.backup")
+	if _, err := execSQL(session, "BACKUP TO '"+target+"'"); !errors.Is(err, sql.ErrUnsupported) {
+		t.Errorf("BACKUP TO without a backup directory: got %v, want ErrUnsupported", err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("refused backup was written (%v)", err)
+	}
+
+	db.SetBackupDir(dir)
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		filepath.Join(outside, "absolute.backup"),
+		"../relative.backup",
+		"escape/linked.backup",
+		".",
+	} {
+		if _, err := execSQL(session, "BACKUP TO '"+path+"'"); !errors.Is(err, sql.ErrUnsupported) {
+			t.Errorf("BACKUP TO %s: got %v, want ErrUnsupported", path, err)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("backups written outside the backup directory: %v", entries)
+	}
+
+	for _, path := range []string{"nightly.backup", filepath.Join(dir, "absolute.backup")} {
+		if _, err := execSQL(session, "BACKUP TO '"+path+"'"); err != nil {
+			t.Errorf("BACKUP TO %s: %v", path, err)
+		}
+	}
+	for _, name := range []string{"nightly.backup", "absolute.backup"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not written: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// BatchError is the error of a batch whose statement at Index (counting
+// from 0) failed with Err.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("statement %d: %v", e.Index+1, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ExecuteBatch runs stmts in order and returns a result for each. Unless
+// the executor is already in a transaction, the batch is one transaction
+// of its own: its writes are committed together, as a single WAL entry,
+// rather than one commit per statement. The first statement to fail stops
+// the batch and undoes the writes of those before it. Transaction control
+// and session statements (BEGIN, SET, SHOW ...) are refused.
+func (e *Executor) ExecuteBatch(stmts []Node) ([]*Result, error) {
+	return e.ExecuteBatchContext(context.Background(), stmts, nil)
+}
+
+// ExecuteBatchContext is ExecuteBatch with a context, checked before each
+// statement as ExecuteContext checks it, and params[i] bound to the
+// placeholders of stmts[i].
+func (e *Executor) ExecuteBatchContext(ctx context.Context, stmts []Node, params [][]storage.Value) ([]*Result, error) {
+	for i, stmt := range stmts {
+		if !batchable(stmt) {
+			return nil, &BatchError{Index: i, Err: errorf(ErrTransaction, "%s cannot be used in a batch", stmt.Type())}
+		}
+	}
+
+	own := e.tx == nil
+	if own {
+		e.tx = e.db.Begin()
+	}
+	tx := e.tx
+	savepoint := tx.Savepoint()
+
+	results := make([]*Result, 0, len(stmts))
+	for i, stmt := range stmts {
+		var bound []storage.Value
+		if i < len(params) {
+			bound = params[i]
+		}
+		result, err := e.run(ctx, stmt, bound)
+		if err != nil {
+			if own {
+				e.tx = nil
+				tx.Rollback()
+			} else {
+				tx.RollbackTo(savepoint)
+			}
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		results = append(results, result)
+	}
+
+	if own {
+		e.tx = nil
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// CanBatch reports whether ExecuteBatch accepts all of stmts.
+func CanBatch(stmts []Node) bool {
+	for _, stmt := range stmts {
+		if !batchable(stmt) {
+			return false
+		}
+	}
+	return true
+}
+
+func batchable(stmt Node) bool {
+	switch stmt.(type) {
+	case *BeginTransactionStatement, *CommitStatement, *RollbackStatement,
+		*PrepareTransactionStatement, *CommitPreparedStatement, *RollbackPreparedStatement,
+		*SetStatement, *ShowStatement, *SetConstraintsStatement:
+		return false
+	}
+	return true
+}
+
+// ExecuteBatch runs stmts as Executor.ExecuteBatch does, in the session's
+// transaction when one is open: a failing statement then undoes the whole
+// batch but leaves the transaction open.
+func (s *Session) ExecuteBatch(ctx context.Context, stmts []Node, params [][]storage.Value) ([]*Result, error) {
+	results, err := s.exec.ExecuteBatchContext(ctx, stmts, params)
+	if errors.Is(err, ErrInternal) && s.exec.tx != nil {
+		tx := s.exec.tx
+		s.exec.tx = nil
+		tx.Rollback()
+		return nil, fmt.Errorf("%w (transaction rolled back)", err)
+	}
+	return results, err
+}
@@ -0,0 +1,152 @@
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+func TestExecuteBatch(t *testing.T) {
+	db := storage.NewDatabase()
+	session := sql.NewSession(db)
+	defer session.Close()
+
+	parse := func(texts ...string) []sql.Node {
+		t.Helper()
+		stmts := make([]sql.Node, len(texts))
+		for i, text := range texts {
+			stmt, err := sql.NewParser(sql.NewLexer(text)).Parse()
+			if err != nil {
+				t.Fatalf("%s: %v", text, err)
+			}
+			stmts[i] = stmt
+		}
+		return stmts
+	}
+	count := func() string {
+		t.Helper()
+		result, err := execSQL(session, "SELECT COUNT(*) FROM users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Rows[0][0]
+	}
+
+	// The whole batch is committed as one WAL entry.
+	before := db.WAL().LastLSN()
+	results, err := session.ExecuteBatch(context.Background(), parse(
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO users (id, name) VALUES (1, 'ada')",
+		"INSERT INTO users (id, name) VALUES (?, ?)",
+		"SELECT name FROM users ORDER BY id",
+	), [][]storage.Value{nil, nil, {storage.NewIntegerValue(2), storage.NewTextValue("bob")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 || len(results[3].Rows) != 2 || results[3].Rows[1][0] != "bob" {
+		t.Fatalf("results = %+v", results)
+	}
+	if lsn := db.WAL().LastLSN(); lsn != before+1 {
+		t.Fatalf("batch wrote %d WAL entries, want 1", lsn-before)
+	}
+
+	// A failing statement undoes the statements before it.
+	_, err = session.ExecuteBatch(context.Background(), parse(
+		"INSERT INTO users (id, name) VALUES (3, 'cy')",
+		"INSERT INTO users (id, name) VALUES (1, 'dup')",
+	), nil)
+	var batchErr *sql.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, sql.ErrPrimaryKeyViolation) {
+		t.Fatalf("err = %v, want a primary key violation in statement 2", err)
+	}
+	if got := count(); got != "2" {
+		t.Fatalf("%s users after the failed batch, want 2", got)
+	}
+
+	// In a transaction the batch joins it, and a failure leaves it open.
+	if _, err := execSQL(session, "BEGIN"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execSQL(session, "INSERT INTO users (id, name) VALUES (3, 'cy')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.ExecuteBatch(context.Background(), parse(
+		"INSERT INTO users (id, name) VALUES (4, 'di')",
+		"INSERT INTO missing (id) VALUES (1)",
+	), nil); !errors.Is(err, sql.ErrTableNotFound) {
+		t.Fatalf("err = %v, want table not found", err)
+	}
+	if !session.InTransaction() {
+		t.Fatal("the failed batch ended the transaction")
+	}
+	if got := count(); got != "3" {
+		t.Fatalf("%s users after the failed batch in a transaction, want 3", got)
+	}
+	if _, err := execSQL(session, "COMMIT"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := session.ExecuteBatch(context.Background(), parse("BEGIN"), nil); !errors.Is(err, sql.ErrTransaction) {
+		t.Fatalf("err = %v, want BEGIN refused", err)
+	}
+}
+
+func TestExecuteScript(t *testing.T) {
+	exec := sql.NewExecutor(storage.NewDatabase())
+	const script = `
+CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+INSERT INTO users (id, name) VALUES (1, 'ada');
+INSERT INTO users (id, name) VALUES (1, 'dup');
+INSERT INTO users (id, name) VALUES (2, 'bob');
+SELECT name FROM users ORDER BY id;
+`
+
+	// By default the script stops at the failing statement, keeping the
+	// ones before it.
+	results, err := exec.ExecuteScript(script)
+	var batchErr *sql.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 2 || !errors.Is(err, sql.ErrPrimaryKeyViolation) {
+		t.Fatalf("err = %v, want a primary key violation in statement 3", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("%d results, want 2", len(results))
+	}
+
+	// Continuing runs the rest, with a nil result for the failure.
+	results, err = exec.ExecuteScriptContext(context.Background(), script[strings.Index(script, "INSERT"):], sql.ScriptOptions{ContinueOnError: true})
+	if !errors.Is(err, sql.ErrPrimaryKeyViolation) {
+		t.Fatalf("err = %v, want primary key violations", err)
+	}
+	if len(results) != 4 || results[0] != nil || results[1] != nil || results[2] == nil {
+		t.Fatalf("results = %+v", results)
+	}
+	if rows := results[3].Rows; len(rows) != 2 || rows[1][0] != "bob" {
+		t.Fatalf("rows = %v", rows)
+	}
+
+	// Nothing runs when the script does not parse.
+	if _, err := exec.ExecuteScript("DELETE FROM users; SELEC 1"); err == nil {
+		t.Fatal("a script with a syntax error ran")
+	}
+	if results, err := exec.ExecuteScript("SELECT name FROM users"); err != nil || len(results[0].Rows) != 2 {
+		t.Fatalf("results = %+v, err = %v", results, err)
+	}
+
+	// A session script may hold transaction control.
+	session := sql.NewSession(storage.NewDatabase())
+	defer session.Close()
+	results, err = session.ExecuteScript(context.Background(), `
+CREATE TABLE t (id INTEGER PRIMARY KEY);
+BEGIN;
+INSERT INTO t (id) VALUES (1);
+ROLLBACK;
+SELECT COUNT(*) FROM t;
+`, sql.ScriptOptions{})
+	if err != nil || results[4].Rows[0][0] != "0" {
+		t.Fatalf("results = %+v, err = %v", results, err)
+	}
+}
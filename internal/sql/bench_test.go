@@ -0,0 +1,209 @@
+package sql_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Executor benchmarks. Statements are parsed once outside the timed loop
+// and run with parameters, so the numbers measure execution; see also
+// internal/storage/bench_test.go.
+
+var benchSizes = []int{100, 1000, 10000}
+
+func BenchmarkInsert(b *testing.B) {
+	session := benchSession(b, 0)
+	insert := mustParse(b, "INSERT INTO items (id, name, score, owner) VALUES (?, ?, ?, ?)")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := session.ExecuteWithParams(insert, itemParams(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPointLookup selects one row by its primary key (which has an
+// index) and by an unindexed column holding the same value.
+func BenchmarkPointLookup(b *testing.B) {
+	for _, n := range benchSizes {
+		session := benchSession(b, n)
+		for _, column := range []string{"id", "serial"} {
+			query := mustParse(b, fmt.Sprintf("SELECT name FROM items WHERE %s = ?", column))
+			b.Run(fmt.Sprintf("%s/rows=%d", column, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					result, err := session.ExecuteWithParams(query, []storage.Value{storage.NewIntegerValue(int64(i % n))})
+					if err != nil {
+						b.Fatal(err)
+					}
+					if len(result.Values) != 1 {
+						b.Fatalf("got %d rows", len(result.Values))
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkJoin joins items to a table of owners one tenth its size.
+func BenchmarkJoin(b *testing.B) {
+	for _, n := range benchSizes[:2] {
+		session := benchSession(b, n)
+		query := mustParse(b, "SELECT i.name, o.name FROM items i JOIN owners o ON i.owner = o.id")
+		b.Run(fmt.Sprintf("rows=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := session.Execute(query); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkOrderBy(b *testing.B) {
+	for _, n := range benchSizes {
+		session := benchSession(b, n)
+		query := mustParse(b, "SELECT id, name FROM items ORDER BY score DESC, name")
+		b.Run(fmt.Sprintf("rows=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := session.Execute(query); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// benchSession returns a session on a database with n items and n/10
+// owners.
+func benchSession(b *testing.B, n int) *sql.Session {
+	b.Helper()
+	session := sql.NewSession(storage.NewDatabase())
+	b.Cleanup(func() { session.Close() })
+	for _, text := range []string{
+		"CREATE TABLE owners (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, serial INTEGER, name TEXT, score FLOAT, owner INTEGER)",
+	} {
+		if _, err := session.Execute(mustParse(b, text)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	owner := mustParse(b, "INSERT INTO owners (id, name) VALUES (?, ?)")
+	for i := 0; i < n/10+1; i++ {
+		if _, err := session.ExecuteWithParams(owner, []storage.Value{
+			storage.NewIntegerValue(int64(i)),
+			storage.NewTextValue(fmt.Sprintf("owner-%d", i)),
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	item := mustParse(b, "INSERT INTO items (id, serial, name, score, owner) VALUES (?, ?, ?, ?, ?)")
+	for i := 0; i < n; i++ {
+		params := itemParams(i)
+		params = append(params[:1], append([]storage.Value{storage.NewIntegerValue(int64(i))}, params[1:]...)...)
+		if _, err := session.ExecuteWithParams(item, params); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return session
+}
+
+func itemParams(i int) []storage.Value {
+	return []storage.Value{
+		storage.NewIntegerValue(int64(i)),
+		storage.NewTextValue(fmt.Sprintf("item-%d", i)),
+		storage.NewFloatValue(float64(i*7919%1000) / 10),
+		storage.NewIntegerValue(int64(i % 10)),
+	}
+}
+
+func mustParse(b *testing.B, text string) sql.Node {
+	b.Helper()
+	stmt, err := sql.NewParser(sql.NewLexer(text)).Parse()
+	if err != nil {
+		b.Fatalf("%s: %v", text, err)
+	}
+	return stmt
+}
+
+// BenchmarkLimit reads the first rows of a table, which should cost the
+// same whatever its size.
+func BenchmarkLimit(b *testing.B) {
+	for _, n := range benchSizes {
+		session := benchSession(b, n)
+		query := mustParse(b, "SELECT id, name FROM items LIMIT 5")
+		b.Run(fmt.Sprintf("rows=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := session.Execute(query); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCount counts a whole table, which is answered without a scan.
+func BenchmarkCount(b *testing.B) {
+	for _, n := range benchSizes {
+		session := benchSession(b, n)
+		query := mustParse(b, "SELECT COUNT(*) FROM items")
+		b.Run(fmt.Sprintf("rows=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := session.Execute(query); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParse parses a join through ParseContext, as the servers do,
+// with and without the plan cache.
+func BenchmarkParse(b *testing.B) {
+	defer sql.SetPlanCacheSize(sql.PlanCacheSize())
+	const query = "SELECT i.name, o.name FROM items i JOIN owners o ON i.owner = o.id WHERE i.score > ? ORDER BY i.name LIMIT 10"
+	for _, size := range []int{0, 512} {
+		sql.SetPlanCacheSize(size)
+		b.Run(fmt.Sprintf("cache=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := sql.ParseContext(context.Background(), query); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkInsertValues parses and runs a single INSERT of many rows, as
+// a SQL dump would.
+func BenchmarkInsertValues(b *testing.B) {
+	for _, n := range benchSizes {
+		var text strings.Builder
+		text.WriteString("INSERT INTO items (id, serial, name, score, owner) VALUES ")
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				text.WriteString(", ")
+			}
+			fmt.Fprintf(&text, "(%d, %d, 'item-%d', %d.5, %d)", i, i, i, i%1000, i%10)
+		}
+		b.Run(fmt.Sprintf("rows=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				session := benchSession(b, 0)
+				b.StartTimer()
+				stmt, _, err := sql.ParseContext(context.Background(), text.String())
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := session.Execute(stmt); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
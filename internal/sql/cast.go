@@ -0,0 +1,69 @@
+package sql
+
+import (
+	"math"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// evaluateCast evaluates expr, reading its operand with eval as
+// evaluateIn does.
+func (e *Executor) evaluateCast(expr *CastExpression, eval func(Expression) (storage.Value, error)) (storage.Value, error) {
+	typ, err := e.parseDataType(expr.Type)
+	if err != nil {
+		return nil, positioned(err, expr.Pos, expr.String(), "cast to INTEGER, TEXT, FLOAT, BOOLEAN or INTERVAL")
+	}
+	v, err := eval(expr.Expr)
+	if err != nil {
+		return nil, err
+	}
+	v, err = castValue(v, typ)
+	return v, positioned(err, expr.Pos, expr.String(), "")
+}
+
+// castValue converts v to typ. NULL stays NULL and anything becomes its
+// text; text is read as a literal of typ, a float becomes the nearest
+// integer, and booleans and integers convert as 1 and 0.
+func castValue(v storage.Value, typ storage.DataType) (storage.Value, error) {
+	if isNull(v) {
+		return storage.NullValue{}, nil
+	}
+	if v.Type() == typ {
+		return v, nil
+	}
+	switch typ {
+	case storage.TypeText:
+		return storage.NewTextValue(v.ToString()), nil
+	case storage.TypeInteger:
+		switch v := v.(type) {
+		case *storage.FloatValue:
+			rounded := math.Round(v.Value)
+			if math.IsNaN(rounded) || rounded < math.MinInt64 || rounded >= math.MaxInt64 {
+				return nil, errorf(ErrTypeMismatch, "%s is out of range for INTEGER", v.ToString())
+			}
+			return storage.NewIntegerValue(int64(rounded)), nil
+		case *storage.BooleanValue:
+			if v.Value {
+				return storage.NewIntegerValue(1), nil
+			}
+			return storage.NewIntegerValue(0), nil
+		}
+	case storage.TypeFloat:
+		if v, ok := v.(*storage.IntegerValue); ok {
+			return storage.NewFloatValue(float64(v.Value)), nil
+		}
+	case storage.TypeBoolean:
+		if v, ok := v.(*storage.IntegerValue); ok {
+			return storage.NewBooleanValue(v.Value != 0), nil
+		}
+	}
+	if v.Type() == storage.TypeText {
+		converted, err := storage.ParseValue(typ, strings.TrimSpace(v.ToString()))
+		if err != nil {
+			return nil, errorf(ErrTypeMismatch, "cannot cast '%s' to %s", v.ToString(), typ)
+		}
+		return converted, nil
+	}
+	return nil, errorf(ErrTypeMismatch, "cannot cast %s %s to %s", v.Type(), v.ToString(), typ)
+}
@@ -0,0 +1,59 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// SET on_conflict = skip | replace lets a script such as a dump be run
+// again into a database that already holds its data. INSERT skips each
+// row whose primary key or UNIQUE value the table already has, or writes
+// it over the row holding it (storage.Tx.InsertRowsOnConflict), and
+// CREATE TABLE and CREATE INDEX keep a table or index of the same name.
+// The default, abort, fails as usual. \import --on-conflict sets it for
+// the script.
+
+// SetConflictPolicy sets what INSERT does with conflicting rows.
+func (e *Executor) SetConflictPolicy(policy storage.ConflictPolicy) {
+	e.onConflict = policy
+}
+
+// insertRowsOnConflict inserts rows under the conflict policy, returning
+// how many were inserted and how many conflicted.
+func (e *Executor) insertRowsOnConflict(table *storage.Table, rows []*storage.Row) (int, int, error) {
+	if e.onConflict == storage.ConflictAbort || e.tx == nil {
+		return len(rows), 0, e.insertRows(table, rows)
+	}
+	return e.tx.InsertRowsOnConflict(table, rows, e.onConflict)
+}
+
+// keepTable answers CREATE TABLE for a table that exists when the conflict
+// policy lets the statement keep it: only if it has the same columns, so
+// that the rows inserted next fit it.
+func (e *Executor) keepTable(stmt *CreateTableStatement, schema *storage.Schema) (*Result, bool, error) {
+	if e.onConflict == storage.ConflictAbort || stmt.Temporary {
+		return nil, false, nil
+	}
+	table, err := e.db.GetTable(stmt.Table)
+	if err != nil {
+		return nil, false, nil
+	}
+	if !table.Schema().SameColumns(schema) {
+		return nil, true, errorf(ErrTableExists, "table %s already exists with other columns", stmt.Table)
+	}
+	return &Result{Message: fmt.Sprintf("Table %s already exists, kept", stmt.Table)}, true, nil
+}
+
+// conflictMessage is the message of an INSERT that met conflicting rows.
+func (e *Executor) conflictMessage(inserted, conflicts int) string {
+	msg := fmt.Sprintf("%d row(s) inserted", inserted)
+	switch {
+	case conflicts == 0:
+	case e.onConflict == storage.ConflictSkip:
+		msg += fmt.Sprintf(", %d skipped", conflicts)
+	default:
+		msg += fmt.Sprintf(", %d replaced", conflicts)
+	}
+	return msg
+}
@@ -0,0 +1,55 @@
+package sql
+
+import (
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// foreignKeys resolves the REFERENCES constraints of CREATE TABLE, whose
+// table will have schema. A constraint without a column refers to the
+// primary key; the referenced column must be a PRIMARY KEY or UNIQUE
+// column of the same type. A table may refer to itself.
+func (e *Executor) foreignKeys(stmt *CreateTableStatement, schema *storage.Schema) ([]*storage.ForeignKey, error) {
+	var fks []*storage.ForeignKey
+	for _, def := range stmt.ForeignKeys {
+		refSchema := schema
+		if def.RefTable != stmt.Table {
+			table, err := e.db.GetTable(def.RefTable)
+			if err != nil {
+				return nil, errorf(ErrTableNotFound, "referenced table %s not found", def.RefTable)
+			}
+			refSchema = table.Schema()
+		}
+
+		refColumns := def.RefColumns
+		if len(refColumns) == 0 {
+			pk := refSchema.PrimaryKeyColumns()
+			if len(pk) != 1 {
+				return nil, errorf(ErrColumnNotFound, "table %s has no primary key to reference; use REFERENCES %s (column)", def.RefTable, def.RefTable)
+			}
+			refColumns = []string{pk[0].Name}
+		}
+
+		for i, name := range def.Columns {
+			col, _ := schema.GetColumn(name)
+			ref, ok := refSchema.GetColumn(refColumns[i])
+			if !ok {
+				return nil, errorf(ErrColumnNotFound, "referenced column %s not found in table %s", refColumns[i], def.RefTable)
+			}
+			if !ref.PrimaryKey && !ref.Unique {
+				return nil, errorf(ErrUnsupported, "referenced column %s of table %s is not a PRIMARY KEY or UNIQUE column", ref.Name, def.RefTable)
+			}
+			if col.Type != ref.Type {
+				return nil, errorf(ErrTypeMismatch, "foreign key column %s is %s but %s.%s is %s", col.Name, col.Type, def.RefTable, ref.Name, ref.Type)
+			}
+		}
+
+		fks = append(fks, &storage.ForeignKey{
+			Columns:    def.Columns,
+			RefTable:   def.RefTable,
+			RefColumns: refColumns,
+			OnDelete:   storage.FKActionNoAction,
+			OnUpdate:   storage.FKActionNoAction,
+		})
+	}
+	return fks, nil
+}
@@ -0,0 +1,141 @@
+package sql
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// crosstab runs queries, which reach tableFunctions again through
+// fromTable, so it cannot be listed in the map's initializer.
+func init() {
+	tableFunctions["crosstab"] = crosstab
+}
+
+// crosstab pivots the rows of a query, as PostgreSQL's tablefunc does:
+//
+//	crosstab('SELECT user_id, status, COUNT(*) FROM tasks GROUP BY user_id, status')
+//
+// The source query returns a row name, a category and a value. Each
+// distinct row name becomes one row, in the order the source first returns
+// it, and each category a column holding the value for that row and
+// category, or NULL if there is none. The first column takes the source's
+// first column's name and the others their category's.
+//
+// The columns are the distinct non-NULL categories in ascending order or,
+// given a second query, the values of its first column in the order it
+// returns them; source rows of other categories are then left out.
+// PostgreSQL has callers name and type the columns instead, which this
+// parser has no syntax for.
+func crosstab(e *Executor, name string, args []storage.Value) (*storage.Table, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, errorf(ErrParameter, "crosstab takes 1 or 2 arguments, the source query and a query for the categories")
+	}
+	source, err := e.crosstabQuery(args[0], "source")
+	if err != nil {
+		return nil, err
+	}
+	if len(source.Columns) != 3 {
+		return nil, errorf(ErrParameter, "crosstab source query must return 3 columns (row name, category, value), got %d", len(source.Columns))
+	}
+
+	var categories []storage.Value
+	if len(args) == 2 {
+		result, err := e.crosstabQuery(args[1], "categories")
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range result.Values {
+			categories = append(categories, row[0])
+		}
+	} else {
+		seen := make(map[string]bool)
+		for _, row := range source.Values {
+			if key := valueKey(row[1]); row[1].Type() != storage.TypeNull && !seen[key] {
+				seen[key] = true
+				categories = append(categories, row[1])
+			}
+		}
+		sort.SliceStable(categories, func(i, j int) bool { return compareValues(categories[i], categories[j]) < 0 })
+	}
+	column := make(map[string]int, len(categories))
+	for i, category := range categories {
+		key := valueKey(category)
+		if _, dup := column[key]; dup {
+			return nil, errorf(ErrParameter, "crosstab category %s is listed twice", category.ToString())
+		}
+		column[key] = i + 1
+	}
+
+	var order []string
+	rows := make(map[string][]storage.Value)
+	for _, row := range source.Values {
+		i, ok := column[valueKey(row[1])]
+		if !ok {
+			continue
+		}
+		key := valueKey(row[0])
+		values, ok := rows[key]
+		if !ok {
+			values = make([]storage.Value, len(categories)+1)
+			values[0] = row[0]
+			for j := 1; j < len(values); j++ {
+				values[j] = storage.NullValue{}
+			}
+			rows[key] = values
+			order = append(order, key)
+		} else if values[i].Type() != storage.TypeNull {
+			return nil, errorf(ErrParameter, "crosstab source query returned more than one value for row %s, category %s", row[0].ToString(), row[1].ToString())
+		}
+		values[i] = row[2]
+	}
+
+	columns := []*storage.Column{storage.NewColumn(source.Columns[0], valueType(source.Values, 0), false, false, false)}
+	valueColumnType := valueType(source.Values, 2)
+	for _, category := range categories {
+		columns = append(columns, storage.NewColumn(category.ToString(), valueColumnType, false, false, false))
+	}
+	table := newSystemTable(name, columns)
+	for _, key := range order {
+		if err := e.addFunctionRow(table, rows[key]); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// crosstabQuery runs the SELECT in arg, a text argument of crosstab.
+func (e *Executor) crosstabQuery(arg storage.Value, what string) (*Result, error) {
+	text, ok := arg.(*storage.TextValue)
+	if !ok {
+		return nil, errorf(ErrParameter, "crosstab %s must be a query in a string", what)
+	}
+	// A string literal keeps the backslash of each \' in it.
+	query := strings.ReplaceAll(text.Value, `\'`, "'")
+	stmt, err := NewParser(NewLexer(query)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(*SelectStatement)
+	if !ok {
+		return nil, errorf(ErrParameter, "crosstab %s must be a SELECT, got %s", what, stmt.Type())
+	}
+	return e.executeSelect(sel)
+}
+
+// valueKey identifies a value for grouping, keeping 1 and '1' apart.
+func valueKey(v storage.Value) string {
+	return v.Type().String() + ":" + v.ToString()
+}
+
+// valueType is the type of the first non-NULL value in column i of rows,
+// or TEXT if there is none.
+func valueType(rows [][]storage.Value, i int) storage.DataType {
+	for _, row := range rows {
+		if t := row[i].Type(); t != storage.TypeNull {
+			return t
+		}
+	}
+	return storage.TypeText
+}
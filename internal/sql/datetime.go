@@ -0,0 +1,131 @@
+package sql
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Dates and times are TEXT in one of the forms of timestampLayouts, such
+// as '2024-03-05' or '2024-03-05 14:30:00'. Adding or subtracting an
+// INTERVAL gives the moved time in the same form, except that a date moved
+// by hours, minutes or seconds gains a time of day. Subtracting one time
+// from another gives the INTERVAL between them, in days and a time of day.
+// Times without a zone are taken as they are, with no daylight saving.
+
+func (e *IntervalLiteral) parse() (storage.Value, error) {
+	v, err := storage.ParseInterval(e.Value)
+	if err != nil {
+		return nil, positioned(errorf(ErrDatetimeFormat, "%v", err), e.Pos, e.String(), "write a number and a unit, as in INTERVAL '2 weeks 3 days'")
+	}
+	return v, nil
+}
+
+// parseTimestamp reads text as a time, and returns the layout it is in.
+func parseTimestamp(v storage.Value) (time.Time, string, bool) {
+	text, ok := v.(*storage.TextValue)
+	if !ok {
+		return time.Time{}, "", false
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, text.Value); err == nil {
+			return t, layout, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// formatTimestamp writes t in layout, adding the time of day to a date
+// when t has one.
+func formatTimestamp(t time.Time, layout string) storage.Value {
+	if layout == "2006-01-02" && (t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0 || t.Nanosecond() != 0) {
+		layout = "2006-01-02 15:04:05.999999999"
+	}
+	return storage.NewTextValue(t.Format(layout))
+}
+
+// dateArithmetic implements the arithmetic of intervals and of times with
+// intervals. ok is false when neither operand is an interval and they are
+// not two times, for the numeric operators to handle.
+func dateArithmetic(left storage.Value, op string, right storage.Value) (result storage.Value, ok bool, err error) {
+	li, lInterval := left.(*storage.IntervalValue)
+	ri, rInterval := right.(*storage.IntervalValue)
+	lt, layout, lTime := parseTimestamp(left)
+	rt, _, rTime := parseTimestamp(right)
+
+	switch {
+	case lInterval && rInterval && (op == "+" || op == "-"):
+		if op == "-" {
+			ri = ri.Multiply(-1)
+		}
+		return li.Add(ri), true, nil
+	case lTime && rInterval && (op == "+" || op == "-"):
+		if op == "-" {
+			ri = ri.Multiply(-1)
+		}
+		return formatTimestamp(ri.AddTo(lt), layout), true, nil
+	case lInterval && op == "+":
+		if t, layout, ok := parseTimestamp(right); ok {
+			return formatTimestamp(li.AddTo(t), layout), true, nil
+		}
+	case lTime && rTime && op == "-":
+		d := lt.Sub(rt)
+		return storage.NewIntervalValue(0, int64(d/(24*time.Hour)), d%(24*time.Hour)), true, nil
+	case lInterval && op == "*":
+		if n, ok := right.(*storage.IntegerValue); ok {
+			return li.Multiply(n.Value), true, nil
+		}
+	case rInterval && op == "*":
+		if n, ok := left.(*storage.IntegerValue); ok {
+			return ri.Multiply(n.Value), true, nil
+		}
+	}
+	if lInterval || rInterval {
+		return nil, true, errorf(ErrTypeMismatch, "operator %s is not supported for %s and %s", op, left.Type(), right.Type())
+	}
+	return nil, false, nil
+}
+
+// dateTruncFields are the precisions DATE_TRUNC accepts, each returning
+// the start of the unit t is in. Weeks start on Monday, as in ISO 8601 and
+// PostgreSQL.
+var dateTruncFields = map[string]func(t time.Time) time.Time{
+	"second":  func(t time.Time) time.Time { return t.Truncate(time.Second) },
+	"minute":  func(t time.Time) time.Time { return t.Truncate(time.Minute) },
+	"hour":    func(t time.Time) time.Time { return t.Truncate(time.Hour) },
+	"day":     func(t time.Time) time.Time { return startOfDay(t, 0) },
+	"week":    func(t time.Time) time.Time { return startOfDay(t, -((int(t.Weekday()) + 6) % 7)) },
+	"month":   func(t time.Time) time.Time { return startOfMonth(t, t.Month()) },
+	"quarter": func(t time.Time) time.Time { return startOfMonth(t, (t.Month()-1)/3*3+1) },
+	"year":    func(t time.Time) time.Time { return startOfMonth(t, time.January) },
+}
+
+func startOfDay(t time.Time, days int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(t time.Time, month time.Month) time.Time {
+	return time.Date(t.Year(), month, 1, 0, 0, 0, 0, t.Location())
+}
+
+// dateTrunc implements DATE_TRUNC('week', created_at): the time at the
+// start of the week, or other unit, that created_at is in, in the same
+// form. It groups times into buckets for reports.
+func dateTrunc(args []storage.Value) (storage.Value, error) {
+	if len(args) != 2 {
+		return nil, errorf(ErrParameter, "date_trunc takes 2 arguments, the unit and the time, got %d", len(args))
+	}
+	if args[0].Type() == storage.TypeNull || args[1].Type() == storage.TypeNull {
+		return storage.NullValue{}, nil
+	}
+	trunc, ok := dateTruncFields[strings.ToLower(args[0].ToString())]
+	if !ok {
+		return nil, errorf(ErrParameter, "date_trunc unit %q is not one of second, minute, hour, day, week, month, quarter or year", args[0].ToString())
+	}
+	t, layout, ok := parseTimestamp(args[1])
+	if !ok {
+		return nil, errorf(ErrDatetimeFormat, "date_trunc cannot read %s as a time; use the form '2006-01-02 15:04:05'", args[1].ToString())
+	}
+	return formatTimestamp(trunc(t), layout), nil
+}
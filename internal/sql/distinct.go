@@ -0,0 +1,43 @@
+package sql
+
+import (
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// distinctRows removes the rows of result that repeat an earlier row, for
+// SELECT DISTINCT. Rows are compared on their projected values through a
+// hash set, with NULLs equal to each other, and the first of each set of
+// duplicates is kept, so an ORDER BY applied before still holds.
+func (e *Executor) distinctRows(result *Result) error {
+	before := len(result.Rows)
+	seen := make(map[string]bool, before)
+	rows := result.Rows[:0]
+	values := result.Values[:0]
+	for i, row := range result.Values {
+		key := valuesKey(row)
+		if seen[key] {
+			continue
+		}
+		if err := e.mem.grow("distinct", int64(len(key))); err != nil {
+			return err
+		}
+		seen[key] = true
+		rows = append(rows, result.Rows[i])
+		values = append(values, row)
+	}
+	result.Rows, result.Values = rows, values
+	e.traceStep("distinct", "rows_in", before, "rows_out", len(result.Rows))
+	return nil
+}
+
+// valuesKey encodes values so that equal values, including NULLs, share a
+// key.
+func valuesKey(values []storage.Value) string {
+	var b strings.Builder
+	for _, v := range values {
+		writeValueKey(&b, v)
+	}
+	return b.String()
+}
@@ -0,0 +1,134 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Errors from parsing and executing statements wrap one of these kinds, so
+// callers can branch with errors.Is. The storage kinds are repeated here
+// for callers that only import this package.
+var (
+	ErrSyntax           = errors.New("syntax error")
+	ErrAmbiguousColumn  = errors.New("ambiguous column")
+	ErrGrouping         = errors.New("grouping error")
+	ErrDivisionByZero   = errors.New("division by zero")
+	ErrDatetimeFormat   = errors.New("invalid datetime format")
+	ErrParameter        = errors.New("invalid parameter")
+	ErrTransaction      = errors.New("invalid transaction state")
+	ErrPreparedStmt     = errors.New("invalid prepared statement")
+	ErrReadOnly         = errors.New("read-only database")
+	ErrCanceled         = errors.New("query canceled")
+	ErrUnsupported      = errors.New("not supported")
+	ErrInternal         = errors.New("internal error")
+	ErrMemoryLimit      = errors.New("memory limit exceeded")
+	ErrStatementTimeout = errors.New("query canceled due to timeout")
+
+	ErrTableNotFound       = storage.ErrTableNotFound
+	ErrTableExists         = storage.ErrTableExists
+	ErrColumnNotFound      = storage.ErrColumnNotFound
+	ErrTypeMismatch        = storage.ErrTypeMismatch
+	ErrConstraintViolation = storage.ErrConstraintViolation
+	ErrNotNullViolation    = storage.ErrNotNullViolation
+	ErrPrimaryKeyViolation = storage.ErrPrimaryKeyViolation
+	ErrUniqueViolation     = storage.ErrUniqueViolation
+	ErrForeignKeyViolation = storage.ErrForeignKeyViolation
+	ErrHistoryUnavailable  = storage.ErrHistoryUnavailable
+	ErrRowNotFound         = storage.ErrRowNotFound
+	ErrPreparedTxExists    = storage.ErrPreparedTxExists
+	ErrPreparedTxNotFound  = storage.ErrPreparedTxNotFound
+)
+
+// errorf formats an error of the given kind.
+func errorf(kind error, format string, args ...interface{}) error {
+	return &storage.Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// positioned locates err at pos in the statement, quoting fragment as
+// parse errors quote their token. Errors that already have a position are
+// returned unchanged, so the innermost node wins, as are errors from nodes
+// built without the parser (zero pos).
+func positioned(err error, pos Position, fragment, suggestion string) error {
+	var located *SQLError
+	if err == nil || pos.Line == 0 || errors.As(err, &located) {
+		return err
+	}
+	return &SQLError{
+		Code:       errorCode(err),
+		Message:    err.Error(),
+		Line:       pos.Line,
+		Column:     pos.Column,
+		Context:    fmt.Sprintf("near '%s'", fragment),
+		Suggestion: suggestion,
+		Err:        err,
+	}
+}
+
+// sqlStates maps error kinds to PostgreSQL SQLSTATE codes, most specific
+// first.
+var sqlStates = []struct {
+	kind  error
+	state string
+}{
+	{ErrSyntax, "42601"},
+	{ErrTableNotFound, "42P01"},
+	{ErrTableExists, "42P07"},
+	{ErrColumnNotFound, "42703"},
+	{ErrAmbiguousColumn, "42702"},
+	{ErrGrouping, "42803"},
+	{ErrTypeMismatch, "42804"},
+	{ErrNotNullViolation, "23502"},
+	{ErrForeignKeyViolation, "23503"},
+	{ErrPrimaryKeyViolation, "23505"},
+	{ErrUniqueViolation, "23505"},
+	{ErrDivisionByZero, "22012"},
+	{ErrDatetimeFormat, "22007"},
+	{ErrParameter, "22023"},
+	{ErrTransaction, "25000"},
+	{ErrReadOnly, "25006"},
+	{ErrPreparedStmt, "26000"},
+	{ErrPreparedTxExists, "42710"},
+	{ErrPreparedTxNotFound, "42704"},
+	{ErrMemoryLimit, "53200"},
+	{ErrHistoryUnavailable, "72000"},
+	{ErrRowNotFound, "P0002"},
+	{ErrCanceled, "57014"},
+	{ErrStatementTimeout, "57014"},
+	{context.DeadlineExceeded, "57014"},
+	{ErrUnsupported, "0A000"},
+}
+
+// SQLState returns the PostgreSQL SQLSTATE code for err, or "XX000"
+// (internal error) if err is not of a known kind.
+func SQLState(err error) string {
+	for _, s := range sqlStates {
+		if errors.Is(err, s.kind) {
+			return s.state
+		}
+	}
+	return "XX000"
+}
+
+// columnSuggestion lists the columns a reference could have named, in
+// table order, e.g. "available columns: u.id, u.name, t.id". Columns of a
+// single table are not qualified.
+func columnSuggestion(tables map[string]*storage.Table, offsets map[string]int) string {
+	var columns []string
+	for _, name := range tablesInOrder(tables, offsets) {
+		for _, col := range tables[name].Schema().Columns {
+			if len(tables) > 1 {
+				columns = append(columns, name+"."+col.Name)
+			} else {
+				columns = append(columns, col.Name)
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return ""
+	}
+	return "available columns: " + strings.Join(columns, ", ")
+}
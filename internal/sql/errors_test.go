@@ -0,0 +1,156 @@
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+func TestErrorKinds(t *testing.T) {
+	session := sql.NewSession(storage.NewDatabase())
+	defer session.Close()
+	for _, text := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT UNIQUE)",
+		"INSERT INTO users (id, name, email) VALUES (1, 'ada', 'ada@example.com')",
+	} {
+		if _, err := execSQL(session, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+
+	tests := []struct {
+		sql   string
+		kind  error
+		state string
+	}{
+		{"SELEC * FROM users", sql.ErrSyntax, "42601"},
+		{"SELECT * FROM missing", sql.ErrTableNotFound, "42P01"},
+		{"CREATE TABLE users (id INTEGER)", sql.ErrTableExists, "42P07"},
+		{"SELECT nope FROM users", sql.ErrColumnNotFound, "42703"},
+		{"INSERT INTO users (id, name) VALUES ('x', 'bob')", sql.ErrTypeMismatch, "42804"},
+		{"INSERT INTO users (id, name) VALUES (2, NULL)", sql.ErrNotNullViolation, "23502"},
+		{"INSERT INTO users (id, name) VALUES (1, 'bob')", sql.ErrPrimaryKeyViolation, "23505"},
+		{"INSERT INTO users (id, name, email) VALUES (3, 'bob', 'ada@example.com')", sql.ErrUniqueViolation, "23505"},
+		{"SELECT id FROM users WHERE id / 0 = 1", sql.ErrDivisionByZero, "22012"},
+		{"COMMIT", sql.ErrTransaction, "25000"},
+	}
+	for _, tt := range tests {
+		_, err := execSQL(session, tt.sql)
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("%s: got %v, want an error of kind %q", tt.sql, err, tt.kind)
+			continue
+		}
+		if got := sql.SQLState(err); got != tt.state {
+			t.Errorf("%s: SQLSTATE %s, want %s", tt.sql, got, tt.state)
+		}
+	}
+
+	_, err := execSQL(session, "INSERT INTO users (id, name) VALUES (1, 'bob')")
+	if !errors.Is(err, sql.ErrConstraintViolation) {
+		t.Errorf("primary key violation does not match ErrConstraintViolation: %v", err)
+	}
+}
+
+// TestReadOnly checks that a read-only database refuses every statement
+// that changes it, DDL included, and still answers queries.
+func TestReadOnly(t *testing.T) {
+	db := storage.NewDatabase()
+	session := sql.NewSession(db)
+	defer session.Close()
+	for _, text := range []string{
+		"CREATE TABLE tasks (id INTEGER PRIMARY KEY, body TEXT)",
+		"CREATE INDEX tasks_body ON tasks (body)",
+	} {
+		if _, err := execSQL(session, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	db.SetReadOnly(true)
+
+	for _, text := range []string{
+		"INSERT INTO tasks (id, body) VALUES (1, 'x')",
+		"CREATE TABLE notes (id INTEGER)",
+		"ALTER TABLE tasks ALTER COLUMN id TYPE TEXT",
+		"CREATE INDEX x ON tasks (body)",
+		"DROP INDEX tasks_body",
+		"DROP TABLE tasks",
+	} {
+		if _, err := execSQL(session, text); !errors.Is(err, sql.ErrReadOnly) {
+			t.Errorf("%s: got %v, want ErrReadOnly", text, err)
+		}
+	}
+	if _, err := execSQL(session, "SELECT id FROM tasks WHERE body = 'x'"); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestStatementTimeout checks that statement_timeout and a canceled
+// context give different kinds of error.
+func TestStatementTimeout(t *testing.T) {
+	session := sql.NewSession(storage.NewDatabase())
+	defer session.Close()
+	slow, err := sql.NewParser(sql.NewLexer("SELECT COUNT(*) FROM generate_series(1, 100000000)")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execSQL(session, "SET statement_timeout = '10ms'"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = session.ExecuteContext(context.Background(), slow, nil)
+	if !errors.Is(err, sql.ErrStatementTimeout) || errors.Is(err, sql.ErrCanceled) {
+		t.Errorf("statement over statement_timeout: got %v, want an error of kind %q", err, sql.ErrStatementTimeout)
+	}
+	if got := sql.SQLState(err); got != "57014" {
+		t.Errorf("statement over statement_timeout: SQLSTATE %s, want 57014", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = session.ExecuteContext(ctx, slow, nil)
+	if !errors.Is(err, sql.ErrCanceled) || errors.Is(err, sql.ErrStatementTimeout) {
+		t.Errorf("canceled statement: got %v, want an error of kind %q", err, sql.ErrCanceled)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	session := sql.NewSession(storage.NewDatabase())
+	defer session.Close()
+	for _, text := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"BEGIN",
+		"INSERT INTO users (id, name) VALUES (1, 'ada')",
+	} {
+		if _, err := execSQL(session, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+
+	// A comparison without operands, as no parsed statement has.
+	malformed := &sql.SelectStatement{
+		Tables:  []sql.TableRef{{Name: "users"}},
+		Columns: []sql.SelectItem{{Name: "*"}},
+		Where:   &sql.BinaryExpression{Op: "="},
+	}
+	_, err := session.Execute(malformed)
+	if !errors.Is(err, sql.ErrInternal) {
+		t.Fatalf("got %v, want an error of kind %q", err, sql.ErrInternal)
+	}
+	if got := sql.SQLState(err); got != "XX000" {
+		t.Errorf("SQLSTATE %s, want XX000", got)
+	}
+
+	if _, err := execSQL(session, "COMMIT"); !errors.Is(err, sql.ErrTransaction) {
+		t.Errorf("COMMIT after the panic: got %v, want no open transaction", err)
+	}
+	result, err := execSQL(session, "SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 0 {
+		t.Errorf("got %d rows, want the insert rolled back", len(result.Rows))
+	}
+}
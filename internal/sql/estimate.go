@@ -0,0 +1,346 @@
+package sql
+
+import (
+	"math"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Row estimates come from the statistics ANALYZE gathers (see
+// storage.TableStats). A scan reads the table's current row count; a
+// comparison of a column with a constant keeps the fraction of rows the
+// column's histogram puts on that side of it, and an equality 1/distinct
+// of the non-NULL rows. An equijoin keeps 1/max(distinct) of the pairs, as
+// in System R. Conditions without statistics fall back to fixed guesses.
+// A node over a table never analyzed has no estimate.
+const (
+	defaultEqualSelectivity = 0.005
+	defaultSelectivity      = 1.0 / 3
+	defaultGroups           = 200
+)
+
+type estimator struct {
+	e     *Executor
+	stats map[string]*storage.TableStats // by table name or alias
+	order []*storage.TableStats          // in FROM and JOIN order
+	count map[string]int                 // current row counts, by table name
+	stmt  Node
+}
+
+// estimateRows fills in the Rows of the plan for stmt, for the parts of it
+// over analyzed tables.
+func (e *Executor) estimateRows(plan *PlanNode, stmt Node) {
+	est := &estimator{e: e, stats: make(map[string]*storage.TableStats), count: make(map[string]int), stmt: stmt}
+	var refs []TableRef
+	switch s := stmt.(type) {
+	case *SelectStatement:
+		refs = append(refs, s.Tables...)
+		for _, join := range s.Joins {
+			refs = append(refs, join.Ref())
+		}
+	case *UpdateStatement:
+		refs = append(refs, TableRef{Name: s.Table})
+	case *DeleteStatement:
+		refs = append(refs, TableRef{Name: s.Table})
+	default:
+		return
+	}
+	for _, ref := range refs {
+		if _, temp := e.temporary(ref.Name); ref.Function != nil || temp {
+			continue
+		}
+		stats, ok := e.db.TableStats(ref.Name)
+		if !ok {
+			continue
+		}
+		table, err := e.db.GetTable(ref.Name)
+		if err != nil {
+			continue
+		}
+		est.stats[ref.Name] = stats
+		est.stats[ref.RefName()] = stats
+		est.order = append(est.order, stats)
+		est.count[ref.Name] = table.Count()
+	}
+	if len(est.stats) > 0 {
+		est.rows(plan, false)
+	}
+}
+
+// rows sets and returns the estimate for n, reporting false when it has
+// none. With full set it only returns it, and an index scan counts as
+// reading its whole table: the rows a Filter above it starts from.
+func (est *estimator) rows(n *PlanNode, full bool) (float64, bool) {
+	var child float64
+	known := true
+	if len(n.Children) > 0 {
+		child, known = est.rows(n.Children[0], full)
+	}
+	var rows float64
+	switch n.Operator {
+	case "Seq Scan", "Index Scan", "Index Only Scan":
+		count, ok := est.count[n.Table]
+		rows, known = float64(count), ok
+		if n.Operator != "Seq Scan" && !full {
+			if s, ok := est.stmt.(*SelectStatement); ok {
+				rows *= est.selectivity(s.Where)
+			}
+		}
+	case "Nested Loop", "Hash Join":
+		inner, innerKnown := est.rows(n.Children[1], full)
+		join := est.join(n)
+		known = known && innerKnown && join != nil
+		if !known {
+			break
+		}
+		rows = child * inner
+		for _, cond := range join.Conditions {
+			rows *= est.selectivity(cond)
+		}
+		switch join.Type {
+		case "LEFT":
+			rows = math.Max(rows, child)
+		case "RIGHT":
+			rows = math.Max(rows, inner)
+		}
+	case "Filter":
+		base, _ := est.rows(n.Children[0], true)
+		rows = base * est.selectivity(est.where())
+	case "Aggregate":
+		rows = est.groups(child)
+	case "Limit":
+		s := est.stmt.(*SelectStatement)
+		offset := 0
+		if s.Offset != nil {
+			offset = *s.Offset
+		}
+		rows = math.Min(float64(*s.Limit), math.Max(child-float64(offset), 0))
+	case "Sort", "Project", "Update", "Delete", "Hash":
+		rows = child
+	case "Table Count", "Result":
+		rows = 1
+	default:
+		known = false
+	}
+	if !known {
+		return 0, false
+	}
+	// Like PostgreSQL, never estimate fewer than one row.
+	rows = math.Max(math.Round(rows), 1)
+	if !full {
+		n.Rows = int64(rows)
+	}
+	return rows, true
+}
+
+// join returns the join a Nested Loop or Hash Join performs: the joins nest in the
+// order they are written, the first innermost.
+func (est *estimator) join(n *PlanNode) *JoinClause {
+	s, ok := est.stmt.(*SelectStatement)
+	if !ok {
+		return nil
+	}
+	depth := 0
+	for outer := n.Children[0]; isJoin(outer); outer = outer.Children[0] {
+		depth++
+	}
+	if depth >= len(s.Joins) {
+		return nil
+	}
+	return s.Joins[depth]
+}
+
+func (est *estimator) where() Expression {
+	switch s := est.stmt.(type) {
+	case *SelectStatement:
+		return s.Where
+	case *UpdateStatement:
+		return s.Where
+	case *DeleteStatement:
+		return s.Where
+	}
+	return nil
+}
+
+// groups estimates how many groups GROUP BY makes of rows rows: the
+// product of the grouping columns' distinct counts, at most rows.
+func (est *estimator) groups(rows float64) float64 {
+	s := est.stmt.(*SelectStatement)
+	if len(s.GroupBy) == 0 {
+		return 1
+	}
+	groups := 1.0
+	for _, name := range s.GroupBy {
+		if col, ok := est.column(columnRef(name, Position{})); ok && col.Distinct > 0 {
+			groups *= float64(col.Distinct)
+		} else {
+			groups *= defaultGroups
+		}
+	}
+	return math.Min(groups, rows)
+}
+
+// column returns the statistics of the column ref names.
+func (est *estimator) column(ref *ColumnRef) (*storage.ColumnStats, bool) {
+	if ref.Table != "" {
+		if stats, ok := est.stats[ref.Table]; ok {
+			return stats.Column(ref.Column)
+		}
+		return nil, false
+	}
+	for _, stats := range est.order {
+		if col, ok := stats.Column(ref.Column); ok {
+			return col, true
+		}
+	}
+	return nil, false
+}
+
+// selectivity estimates the fraction of rows expr keeps.
+func (est *estimator) selectivity(expr Expression) float64 {
+	switch expr := expr.(type) {
+	case nil:
+		return 1
+	case *UnaryExpression:
+		if expr.Op == "NOT" {
+			return 1 - est.selectivity(expr.Right)
+		}
+	case *BinaryExpression:
+		switch expr.Op {
+		case "AND":
+			return est.selectivity(expr.Left) * est.selectivity(expr.Right)
+		case "OR":
+			l, r := est.selectivity(expr.Left), est.selectivity(expr.Right)
+			return l + r - l*r
+		}
+		return est.comparison(expr)
+	case *InExpression:
+		if expr.Subquery != nil {
+			break
+		}
+		// The values are taken to be distinct: each keeps what = would.
+		s := 0.0
+		for _, v := range expr.Values {
+			s += est.comparison(&BinaryExpression{Left: expr.Left, Op: "=", Right: v})
+		}
+		s = math.Min(s, 1)
+		if expr.Not {
+			return 1 - s
+		}
+		return s
+	}
+	return defaultSelectivity
+}
+
+// flipped gives the operator that keeps a comparison's meaning when its
+// sides are swapped.
+var flipped = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}
+
+// comparison estimates a comparison of a column with a constant or, for a
+// join, with another column.
+func (est *estimator) comparison(expr *BinaryExpression) float64 {
+	op := expr.Op
+	ref, ok := expr.Left.(*ColumnRef)
+	other := expr.Right
+	if !ok {
+		ref, ok = expr.Right.(*ColumnRef)
+		other = expr.Left
+		if f, swap := flipped[op]; swap {
+			op = f
+		}
+	}
+	if !ok {
+		return defaultSelectivity
+	}
+	col, hasStats := est.column(ref)
+
+	if otherRef, isRef := other.(*ColumnRef); isRef {
+		otherCol, otherStats := est.column(otherRef)
+		if (op != "=" && op != "==") || !hasStats || !otherStats {
+			if op == "=" || op == "==" {
+				return defaultEqualSelectivity
+			}
+			return defaultSelectivity
+		}
+		distinct := math.Max(float64(col.Distinct), float64(otherCol.Distinct))
+		if distinct == 0 {
+			return 0
+		}
+		return (1 - col.NullFrac) * (1 - otherCol.NullFrac) / distinct
+	}
+
+	value, isConst := est.constant(other, col)
+	if !hasStats || !isConst {
+		if op == "=" || op == "==" {
+			return defaultEqualSelectivity
+		}
+		return defaultSelectivity
+	}
+	equal := col.EqualFraction()
+	if len(col.Bounds) > 0 && (value.LessThan(col.Bounds[0]) || col.Bounds[len(col.Bounds)-1].LessThan(value)) {
+		equal = 0
+	}
+	below := col.BelowFraction(value)
+	nonNull := 1 - col.NullFrac
+	switch op {
+	case "=", "==":
+		return equal
+	case "!=":
+		return math.Max(nonNull-equal, 0)
+	case "<":
+		return below
+	case "<=":
+		return math.Min(below+equal, nonNull)
+	case ">":
+		return math.Max(nonNull-below-equal, 0)
+	case ">=":
+		return math.Max(nonNull-below, 0)
+	}
+	return defaultSelectivity
+}
+
+// constant returns the value of a literal or bound parameter, converted to
+// the type of the column col describes where the lexer could not tell,
+// as with '5' for a TEXT column.
+func (est *estimator) constant(expr Expression, col *storage.ColumnStats) (storage.Value, bool) {
+	var value storage.Value
+	var err error
+	switch expr := expr.(type) {
+	case *LiteralExpression:
+		value, err = expr.parseLiteral()
+	case *Parameter:
+		value, err = est.e.paramValue(expr)
+	default:
+		return nil, false
+	}
+	if err != nil || value.Type() == storage.TypeNull {
+		return nil, false
+	}
+	if col == nil || len(col.Bounds) == 0 || value.Type() == col.Bounds[0].Type() {
+		return value, true
+	}
+	switch col.Bounds[0].Type() {
+	case storage.TypeText:
+		return storage.NewTextValue(value.ToString()), true
+	case storage.TypeFloat:
+		if i, ok := value.(*storage.IntegerValue); ok {
+			return storage.NewFloatValue(float64(i.Value)), true
+		}
+	}
+	return nil, false
+}
+
+// executeAnalyze gathers statistics for one table or, without a name, for
+// every table of the database.
+func (e *Executor) executeAnalyze(stmt *AnalyzeStatement) (*Result, error) {
+	tables := []string{stmt.Table}
+	if stmt.Table == "" {
+		tables = e.db.ListTables()
+	}
+	for _, name := range tables {
+		if _, err := e.db.Analyze(name); err != nil {
+			return nil, positioned(err, stmt.TablePos, stmt.Table, "")
+		}
+	}
+	return &Result{Message: stmt.String()}, nil
+}
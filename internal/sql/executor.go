@@ -1,29 +1,339 @@
 package sql
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"regexp"
+	"runtime/debug"
 	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/mryan-3/rdbms/internal/storage"
 )
 
 type Executor struct {
-	db *storage.Database
+	db       *storage.Database
+	params   []storage.Value
+	listener *storage.Listener
+	tx       *storage.Tx
+	ctx      context.Context
+	logger   *slog.Logger
+	user     string
+	trace    bool
+	workMem  int64
+	timeout  time.Duration
+	temp     *storage.Database // temporary tables; see temp.go
+	mem      *memoryAccount
+	peakMem  int64
+	// lastInsertID is the LastInsertID of the last INSERT that set one.
+	lastInsertID *int64
+	progress     func(Progress)
+	rowFunc      func(columns []string, row []storage.Value) error // see stream.go
+	onConflict   storage.ConflictPolicy      // see conflict.go
+	hints        []Hint                      // of the SELECT being run; see hints.go
+	subqueries   map[*InExpression]*valueSet // run by the statement; see subquery.go
+	strict       bool                        // SET sql_mode = strict; see strict.go
+	now          time.Time                   // when the statement started, for NOW()
 }
 
 func NewExecutor(db *storage.Database) *Executor {
-	return &Executor{db: db}
+	return &Executor{db: db, trace: db.QueryTracing()}
 }
 
 type Result struct {
 	Columns      []string
 	Rows         [][]string
+	Values       [][]storage.Value
 	RowsAffected int
 	Message      string
+	// LastInsertID is the primary key of the last row an INSERT added, for
+	// tables with an INTEGER primary key (including generated ones).
+	LastInsertID int64
+}
+
+// SetLogger overrides the database's logger for this executor.
+func (e *Executor) SetLogger(logger *slog.Logger) {
+	e.logger = logger
+}
+
+func (e *Executor) log() *slog.Logger {
+	if e.logger != nil {
+		return e.logger
+	}
+	return e.db.Logger()
 }
 
 func (e *Executor) Execute(stmt Node) (*Result, error) {
+	return e.run(context.Background(), stmt, nil)
+}
+
+// ExecuteContext executes stmt inside a "rdbms.execute" span that is a
+// child of any span in ctx. Scans, joins and filters stop with an error once
+// ctx is canceled; run it through a Session so partial writes are undone.
+func (e *Executor) ExecuteContext(ctx context.Context, stmt Node) (*Result, error) {
+	return e.run(ctx, stmt, nil)
+}
+
+// ExecuteWithParams executes stmt with params bound to its placeholders in
+// order, so $1 (or the first ?) takes params[0].
+func (e *Executor) ExecuteWithParams(stmt Node, params []storage.Value) (*Result, error) {
+	return e.run(context.Background(), stmt, params)
+}
+
+func (e *Executor) run(ctx context.Context, stmt Node, params []storage.Value) (result *Result, err error) {
+	defer e.reportError(stmt, &err)
+	defer e.recoverPanic(stmt, &result, &err)
+
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "rdbms.execute")
+	span.SetAttributes(
+		attribute.String("db.operation", stmt.Type().String()),
+		attribute.String("db.statement", statementText(stmt)),
+	)
+
+	e.ctx = ctx
+	e.params = params
+	e.subqueries = nil
+	defer func() {
+		e.ctx = nil
+		e.params = nil
+		e.subqueries = nil
+	}()
+
+	start := time.Now()
+	e.now = start
+	err = e.checkContext(0)
+	if err == nil && isWrite(stmt) && e.db.ReadOnly() {
+		err = errorf(ErrReadOnly, "cannot execute %s on a read-only replica", stmt.Type())
+	}
+	if err == nil {
+		e.mem = &memoryAccount{limit: e.workMem}
+		result, err = e.executeAtomic(stmt)
+		if e.mem.peak > e.peakMem {
+			e.peakMem = e.mem.peak
+		}
+	}
+	if result != nil {
+		span.SetAttributes(
+			attribute.Int("db.rows_affected", result.RowsAffected),
+			attribute.Int("db.rows_returned", len(result.Rows)),
+		)
+	}
+	endSpan(span, err)
+
+	duration := time.Since(start)
+	if e.db.StatementLogging() {
+		e.logStatement(ctx, stmt, result, err, duration)
+	}
+	if err == nil {
+		e.recordSlowQuery(stmt, result, start, duration)
+		e.recordAudit(stmt, result, start)
+	}
+	return result, err
+}
+
+// executeAtomic runs stmt in the executor's transaction, or in one of its
+// own when none is open, so every write reaches the WAL.
+func (e *Executor) executeAtomic(stmt Node) (*Result, error) {
+	if e.tx != nil || !isWrite(stmt) {
+		return e.execute(stmt)
+	}
+
+	tx := e.db.Begin()
+	e.tx = tx
+	defer func() {
+		e.tx = nil
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	result, err := e.execute(stmt)
+	if err != nil {
+		e.tx.Rollback()
+		return nil, err
+	}
+	if err := e.tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// recoverPanic turns a panic while running stmt into an ErrInternal
+// error, so a bug hit by one statement fails that statement instead of
+// crashing the REPL or server. Writes made by the statement are rolled
+// back; a Session also rolls back its open transaction.
+func (e *Executor) recoverPanic(stmt Node, result **Result, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	e.log().Error("recovered from panic while executing statement",
+		"statement_type", fmt.Sprintf("%T", stmt), "panic", r, "stack", string(debug.Stack()))
+	*result = nil
+	*err = &SQLError{
+		Code:       CodeUnknown,
+		Message:    fmt.Sprintf("internal error: %v", r),
+		Suggestion: "the statement was aborted and its changes rolled back; please report this as a bug",
+		Err:        ErrInternal,
+	}
+}
+
+// reportError passes a statement's error, including one recovered from a
+// panic, to the database's OnError hook.
+func (e *Executor) reportError(stmt Node, err *error) {
+	if *err == nil {
+		return
+	}
+	if hook := e.db.Hooks().OnError; hook != nil {
+		hook(statementText(stmt), *err)
+	}
+}
+
+func isWrite(stmt Node) bool {
+	switch s := stmt.(type) {
+	case *InsertStatement, *UpdateStatement, *DeleteStatement,
+		*CreateTableStatement, *DropTableStatement, *AlterTableStatement,
+		*CreateIndexStatement, *DropIndexStatement,
+		*CreateUserStatement, *DropUserStatement,
+		*CommitPreparedStatement, *RollbackPreparedStatement:
+		return true
+	case *ExplainStatement:
+		return s.Analyze && isWrite(s.Statement)
+	}
+	return false
+}
+
+// Progress reports how far a multi-row INSERT has got.
+type Progress struct {
+	Table string
+	Rows  int // inserted so far
+	Total int // in the statement
+}
+
+// SetProgress sets a function called after each batch of rows a multi-row
+// INSERT inserts, so an import can show how far it has got; nil turns it
+// off.
+func (e *Executor) SetProgress(fn func(Progress)) {
+	e.progress = fn
+}
+
+// loggedInsertRows is how many VALUES rows of an INSERT the statement text
+// in traces, logs, the slow query log and the audit log keeps; a bulk
+// INSERT of thousands of rows is shown as its first rows and a count.
+const loggedInsertRows = 10
+
+func statementText(stmt Node) string {
+	if s, ok := stmt.(*InsertStatement); ok {
+		return s.format(loggedInsertRows)
+	}
+	return stmt.String()
+}
+
+// SetUser sets the user name recorded in the audit log for statements run
+// by this executor.
+func (e *Executor) SetUser(user string) {
+	e.user = user
+}
+
+func (e *Executor) recordAudit(stmt Node, result *Result, start time.Time) {
+	switch stmt.(type) {
+	case *InsertStatement, *UpdateStatement, *DeleteStatement,
+		*CreateTableStatement, *DropTableStatement, *AlterTableStatement,
+		*CreateIndexStatement, *DropIndexStatement,
+		*CreateUserStatement, *DropUserStatement:
+	default:
+		return
+	}
+
+	e.db.RecordAudit(storage.AuditEntry{
+		Time:         start,
+		User:         e.user,
+		Operation:    stmt.Type().String(),
+		Statement:    statementText(stmt),
+		RowsAffected: result.RowsAffected,
+	})
+}
+
+// cancelCheckInterval is how many rows the executor processes between
+// checks of its context, keeping the check off the per-row hot path.
+const cancelCheckInterval = 1024
+
+// checkContext returns an error once the context passed to ExecuteContext
+// is canceled or past its deadline. It only looks at the context when n is
+// a multiple of cancelCheckInterval, so loops can call it with a row count.
+func (e *Executor) checkContext(n int) error {
+	if e.ctx == nil || n%cancelCheckInterval != 0 {
+		return nil
+	}
+	if err := e.ctx.Err(); err != nil {
+		return e.canceled(err)
+	}
+	return nil
+}
+
+// cancelablePredicate stops matching rows once the context is canceled or
+// *errp holds an error, storing the cancellation in *errp, so a table-wide
+// UPDATE or DELETE can be aborted and then rolled back by the caller's
+// transaction.
+func (e *Executor) cancelablePredicate(predicate func(*storage.Row) bool, errp *error) func(*storage.Row) bool {
+	n := 0
+	return func(row *storage.Row) bool {
+		n++
+		if *errp == nil {
+			*errp = e.checkContext(n)
+		}
+		if *errp != nil {
+			return false
+		}
+		return predicate == nil || predicate(row)
+	}
+}
+
+func (e *Executor) recordSlowQuery(stmt Node, result *Result, start time.Time, duration time.Duration) {
+	threshold := e.db.SlowQueryThreshold()
+	if threshold <= 0 || duration < threshold {
+		return
+	}
+
+	e.db.RecordSlowQuery(storage.SlowQuery{
+		StartedAt:    start,
+		Duration:     duration,
+		Operation:    stmt.Type().String(),
+		Statement:    statementText(stmt),
+		RowsAffected: result.RowsAffected,
+		RowsReturned: len(result.Rows),
+		Plan:         describePlan(stmt),
+	})
+}
+
+func (e *Executor) logStatement(ctx context.Context, stmt Node, result *Result, err error, duration time.Duration) {
+	attrs := []slog.Attr{
+		slog.String("operation", stmt.Type().String()),
+		slog.String("statement", statementText(stmt)),
+		slog.Duration("duration", duration),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		e.log().LogAttrs(ctx, slog.LevelWarn, "statement failed", attrs...)
+		return
+	}
+
+	attrs = append(attrs,
+		slog.Int("rows_affected", result.RowsAffected),
+		slog.Int("rows_returned", len(result.Rows)),
+		slog.Int64("memory_bytes", e.mem.peak),
+	)
+	e.log().LogAttrs(ctx, slog.LevelInfo, "statement", attrs...)
+}
+
+func (e *Executor) execute(stmt Node) (*Result, error) {
 	switch s := stmt.(type) {
 	case *SelectStatement:
 		return e.executeSelect(s)
@@ -37,24 +347,97 @@ func (e *Executor) Execute(stmt Node) (*Result, error) {
 		return e.executeCreateTable(s)
 	case *DropTableStatement:
 		return e.executeDropTable(s)
+	case *AlterTableStatement:
+		return e.executeAlterTable(s)
 	case *BeginTransactionStatement, *CommitStatement, *RollbackStatement:
 		return &Result{Message: s.String()}, nil
+	case *PrepareTransactionStatement:
+		return nil, errorf(ErrTransaction, "PREPARE TRANSACTION can only be used in a transaction")
+	case *CommitPreparedStatement:
+		if err := e.db.CommitPrepared(s.GID); err != nil {
+			return nil, err
+		}
+		return &Result{Message: s.String()}, nil
+	case *RollbackPreparedStatement:
+		if err := e.db.RollbackPrepared(s.GID); err != nil {
+			return nil, err
+		}
+		return &Result{Message: s.String()}, nil
+	case *ListenStatement:
+		return e.executeListen(s)
+	case *UnlistenStatement:
+		return e.executeUnlisten(s)
+	case *NotifyStatement:
+		return e.executeNotify(s)
+	case *CreateUserStatement:
+		if err := e.db.CreateUser(s.Name, s.Password); err != nil {
+			return nil, err
+		}
+		return &Result{Message: fmt.Sprintf("User %s created", s.Name)}, nil
+	case *DropUserStatement:
+		if err := e.db.DropUser(s.Name); err != nil {
+			return nil, err
+		}
+		return &Result{Message: fmt.Sprintf("User %s dropped", s.Name)}, nil
+	case *BackupStatement:
+		return e.executeBackup(s)
+	case *ExplainStatement:
+		return e.executeExplain(s)
+	case *AttachStatement:
+		return e.executeAttach(s)
+	case *DetachStatement:
+		if err := e.db.Detach(s.Alias); err != nil {
+			return nil, err
+		}
+		return &Result{Message: fmt.Sprintf("Database %s detached", s.Alias)}, nil
+	case *CreateIndexStatement:
+		return e.executeCreateIndex(s)
+	case *AnalyzeStatement:
+		return e.executeAnalyze(s)
+	case *DropIndexStatement:
+		if e.temp == nil || e.temp.DropIndex(s.Name) != nil {
+			drop := e.db.DropIndex
+			if e.tx != nil {
+				drop = e.tx.DropIndex
+			}
+			if err := drop(s.Name); err != nil {
+				return nil, err
+			}
+		}
+		return &Result{Message: fmt.Sprintf("Index %s dropped", s.Name)}, nil
+	case *SetStatement, *ShowStatement, *SetConstraintsStatement:
+		return nil, fmt.Errorf("%s requires a session", s.Type())
 	default:
-		return nil, fmt.Errorf("unsupported statement type: %T", stmt)
+		return nil, errorf(ErrUnsupported, "unsupported statement type: %T", stmt)
+	}
+}
+
+func (e *Executor) paramValue(param *Parameter) (storage.Value, error) {
+	if param.Index < 1 || param.Index > len(e.params) {
+		return nil, errorf(ErrParameter, "no value supplied for parameter %s", param.String())
 	}
+	return e.params[param.Index-1], nil
 }
 
 func (e *Executor) resolveColumnIndex(colRef *ColumnRef, tables map[string]*storage.Table, offsets map[string]int) (int, error) {
+	idx, err := resolveColumn(colRef, tables, offsets)
+	if err != nil {
+		return -1, positioned(err, colRef.Pos, colRef.String(), columnSuggestion(tables, offsets))
+	}
+	return idx, nil
+}
+
+func resolveColumn(colRef *ColumnRef, tables map[string]*storage.Table, offsets map[string]int) (int, error) {
 	if colRef.Table != "" {
 		// Specific table referenced (e.g., "users.id" or "u.id")
 		table, ok := tables[colRef.Table]
 		if !ok {
-			return -1, fmt.Errorf("unknown table or alias: %s", colRef.Table)
+			return -1, errorf(ErrTableNotFound, "unknown table or alias: %s", colRef.Table)
 		}
 		offset := offsets[colRef.Table]
-		colIdx := table.Schema.ColumnIndex(colRef.Column)
+		colIdx := table.Schema().ColumnIndex(colRef.Column)
 		if colIdx < 0 {
-			return -1, fmt.Errorf("column %s not found in table %s", colRef.Column, colRef.Table)
+			return -1, errorf(ErrColumnNotFound, "column %s not found in table %s", colRef.Column, colRef.Table)
 		}
 		return offset + colIdx, nil
 	}
@@ -62,32 +445,46 @@ func (e *Executor) resolveColumnIndex(colRef *ColumnRef, tables map[string]*stor
 	// No table specified, search all tables (e.g., "id")
 	foundIdx := -1
 	for name, table := range tables {
-		colIdx := table.Schema.ColumnIndex(colRef.Column)
+		colIdx := table.Schema().ColumnIndex(colRef.Column)
 		if colIdx >= 0 {
 			if foundIdx != -1 {
-				return -1, fmt.Errorf("ambiguous column name: %s", colRef.Column)
+				return -1, errorf(ErrAmbiguousColumn, "ambiguous column name: %s", colRef.Column)
 			}
 			foundIdx = offsets[name] + colIdx
 		}
 	}
 
 	if foundIdx == -1 {
-		return -1, fmt.Errorf("column not found: %s", colRef.Column)
+		return -1, errorf(ErrColumnNotFound, "column not found: %s", colRef.Column)
 	}
 
 	return foundIdx, nil
 }
 
 func (e *Executor) executeSelect(stmt *SelectStatement) (*Result, error) {
+	if len(stmt.Compound) > 0 {
+		return e.executeCompound(stmt)
+	}
+	restore, err := e.useHints(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	if err := e.runSubqueries(selectExpressions(stmt)...); err != nil {
+		return nil, err
+	}
 	if len(stmt.Tables) == 0 {
-		return nil, fmt.Errorf("no table specified in SELECT")
+		return e.selectWithoutFrom(stmt)
 	}
 
 	// 1. Initialize context for potentially multiple tables
 	primaryTableRef := stmt.Tables[0]
-	primaryTable, err := e.db.GetTable(primaryTableRef.Name)
+	primaryTable, err := e.fromTable(primaryTableRef, stmt.AsOf)
 	if err != nil {
-		return nil, err
+		return nil, positioned(err, primaryTableRef.Pos, primaryTableRef.Name, "")
+	}
+	if countOnly(stmt) {
+		return e.countRows(stmt, primaryTable), nil
 	}
 
 	tableMap := make(map[string]*storage.Table)
@@ -95,47 +492,183 @@ func (e *Executor) executeSelect(stmt *SelectStatement) (*Result, error) {
 	currentOffset := 0
 	
 	// Register primary table (using both name and potential alias)
-	lookupName := primaryTableRef.Name
-	if primaryTableRef.Alias != "" {
-		lookupName = primaryTableRef.Alias
-	}
+	lookupName := primaryTableRef.RefName()
 	
 	tableMap[lookupName] = primaryTable
 	offsetMap[lookupName] = 0
-	currentOffset += len(primaryTable.Schema.Columns)
+	currentOffset += len(primaryTable.Schema().Columns)
 
 	var intermediateRows []*storage.Row
-	
-	primaryRows := primaryTable.Select(nil)
-	for _, r := range primaryRows {
-		intermediateRows = append(intermediateRows, r.Clone())
+
+	// With a LIMIT and no joins the scan applies WHERE itself and stops once
+	// it has kept enough rows.
+	want := rowsWanted(stmt)
+	pushFilter := want >= 0 && len(stmt.Joins) == 0
+	rejected := &rejections{e: e, step: "WHERE"}
+
+	// A LIKE with a literal prefix on an indexed column reads only the
+	// index range that can match.
+	scan := primaryTable.Scan
+	copied := false // scan yields rows of its own
+	if column, start, end, ok := e.likeIndexRange(stmt.Where, primaryTable, lookupName, len(stmt.Joins) > 0); ok {
+		scan = func(fn func(*storage.Row) bool) {
+			if !primaryTable.ScanIndexRange(column, start, end, fn) {
+				primaryTable.Scan(fn)
+			}
+		}
+		e.traceStep("index range", "table", primaryTableRef.String(), "column", column,
+			"from", start.ToString(), "to", end.ToString())
+	} else if index, terms, ok := e.fullTextIndex(stmt.Where, primaryTable, lookupName, len(stmt.Joins) > 0); ok {
+		scan = func(fn func(*storage.Row) bool) {
+			if !primaryTable.ScanFullText(index.Name, terms, func(row *storage.Row, _ float64) bool { return fn(row) }) {
+				primaryTable.Scan(fn)
+			}
+		}
+		e.traceStep("full-text scan", "table", primaryTableRef.String(), "index", index.Name, "terms", strings.Join(terms, " "))
+	} else if index, text, ok := e.trigramIndex(stmt.Where, primaryTable, lookupName, len(stmt.Joins) > 0); ok {
+		scan = func(fn func(*storage.Row) bool) {
+			if !primaryTable.ScanTrigram(index.Name, text, func(row *storage.Row, _ float64) bool { return fn(row) }) {
+				primaryTable.Scan(fn)
+			}
+		}
+		e.traceStep("trigram scan", "table", primaryTableRef.String(), "index", index.Name, "text", text)
+	} else if index, start, end, ok := e.secondaryIndexRange(stmt.Where, primaryTable, lookupName, len(stmt.Joins) > 0); ok {
+		// An index holding every column the query reads answers it
+		// without the table's rows.
+		indexScan, step := primaryTable.ScanSecondaryIndex, "index scan"
+		if columns, ok := queryColumns(stmt, primaryTable, lookupName); ok && index.Covers(columns) {
+			indexScan, step = primaryTable.ScanIndexOnly, "index only scan"
+		}
+		scan = func(fn func(*storage.Row) bool) {
+			if !indexScan(index.Name, start, end, fn) {
+				primaryTable.Scan(fn)
+			}
+		}
+		e.traceStep(step, "table", primaryTableRef.String(), "index", index.Name, "rows", primaryTable.IndexLen(index.Name))
+	} else if len(stmt.Joins) == 0 {
+		if stmt.Where != nil {
+			e.recordFullScan(stmt.Where, primaryTable, lookupName)
+		}
+		// The table tests the WHERE's col = constant conditions in place
+		// and copies only the columns the query reads.
+		opts := e.scanOptions(stmt, primaryTable, lookupName)
+		scan = func(fn func(*storage.Row) bool) {
+			primaryTable.ScanWith(opts, fn)
+		}
+		copied = true
 	}
 
-	// 2. Process Joins
-	for _, join := range stmt.Joins {
-		targetTable, err := e.db.GetTable(join.Table)
+	if e.rowFunc != nil && streams(stmt) {
+		return e.streamSelect(stmt, primaryTableRef, scan, copied, tableMap, offsetMap)
+	}
+
+	scanSpan := e.startSpan("rdbms.scan", attribute.String("db.sql.table", primaryTableRef.Name))
+	var held int64
+	scanned := 0
+	scan(func(r *storage.Row) bool {
+		if pushFilter && len(intermediateRows) >= want {
+			return false
+		}
+		scanned++
+		if err = e.checkContext(scanned); err != nil {
+			return false
+		}
+		if pushFilter && stmt.Where != nil {
+			var val storage.Value
+			val, err = e.evaluateExpressionForJoinedRow(stmt.Where, r, tableMap, offsetMap)
+			if err != nil {
+				return false
+			}
+			if !e.getValueAsBool(val) {
+				rejected.add(stmt.Where, r, val, nil)
+				return true
+			}
+		}
+		row := r
+		if !copied {
+			row = r.Clone()
+		}
+		var n int64
+		n, err = e.chargeRow("scan "+primaryTableRef.String(), row)
 		if err != nil {
-			return nil, err
+			err = positioned(err, primaryTableRef.Pos, primaryTableRef.Name, "raise work_mem with SET work_mem")
+			return false
 		}
+		held += n
+		intermediateRows = append(intermediateRows, row)
+		return true
+	})
+	if err != nil {
+		endSpan(scanSpan, err)
+		return nil, err
+	}
+	scanSpan.SetAttributes(attribute.Int("rdbms.rows", len(intermediateRows)))
+	scanSpan.End()
+	e.traceStep("scan", "table", primaryTableRef.String(), "rows", scanned)
 
-		lookupName := join.Table
-		if join.Alias != "" {
-			lookupName = join.Alias
+	// 2. Process Joins
+	for j, join := range stmt.Joins {
+		targetTable, err := e.fromTable(join.Ref(), stmt.AsOf)
+		if err != nil {
+			return nil, positioned(err, join.Pos, join.Table, "")
 		}
+
+		lookupName := join.Ref().RefName()
 		
 		tableMap[lookupName] = targetTable
 		offsetMap[lookupName] = currentOffset
 		
-		targetColsLen := len(targetTable.Schema.Columns)
+		targetColsLen := len(targetTable.Schema().Columns)
 		
+		joinSpan := e.startSpan("rdbms.join",
+			attribute.String("db.sql.table", join.Table),
+			attribute.String("rdbms.join_type", join.Type))
 		newRows := make([]*storage.Row, 0)
 
 		targetRows := targetTable.Select(nil)
+		e.traceStep("scan", "table", join.Ref().String(), "rows", len(targetRows))
+		candidates, err := e.joinCandidates(stmt, join, targetRows, currentOffset, tableMap, offsetMap)
+		if err != nil {
+			endSpan(joinSpan, err)
+			return nil, err
+		}
 
+		// The last join can stop early when nothing filters its output.
+		joinLimit := -1
+		if want >= 0 && stmt.Where == nil && j == len(stmt.Joins)-1 {
+			joinLimit = want
+		}
+
+		steps, matched, unmatched := 0, 0, 0
+		var joinHeld int64
+		charge := func(row *storage.Row) error {
+			n, err := e.chargeRow("join "+join.Table, row)
+			joinHeld += n
+			if err != nil {
+				endSpan(joinSpan, err)
+				return positioned(err, join.Pos, join.Table, "raise work_mem with SET work_mem")
+			}
+			return nil
+		}
+		rejected := &rejections{e: e, step: join.Type + " JOIN " + join.Table}
 		for _, leftRow := range intermediateRows {
+			if joinLimit >= 0 && len(newRows) >= joinLimit {
+				break
+			}
 			matchFound := false
+			rightRows, err := candidates(leftRow)
+			if err != nil {
+				endSpan(joinSpan, err)
+				return nil, err
+			}
+
+			for _, rightRow := range rightRows {
+				steps++
+				if err := e.checkContext(steps); err != nil {
+					endSpan(joinSpan, err)
+					return nil, err
+				}
 
-			for _, rightRow := range targetRows {
 				combinedValues := make([]storage.Value, len(leftRow.Values)+len(rightRow.Values))
 				copy(combinedValues, leftRow.Values)
 				copy(combinedValues[len(leftRow.Values):], rightRow.Values)
@@ -145,7 +678,12 @@ func (e *Executor) executeSelect(stmt *SelectStatement) (*Result, error) {
 				if len(join.Conditions) > 0 {
 					for _, cond := range join.Conditions {
 						val, err := e.evaluateExpressionForJoinedRow(cond, combinedRow, tableMap, offsetMap)
-						if err != nil || !e.getValueAsBool(val) {
+						if err != nil {
+							endSpan(joinSpan, err)
+							return nil, err
+						}
+						if !e.getValueAsBool(val) {
+							rejected.add(cond, combinedRow, val, nil)
 							matches = false
 							break
 						}
@@ -153,8 +691,15 @@ func (e *Executor) executeSelect(stmt *SelectStatement) (*Result, error) {
 				}
 
 				if matches {
+					if err := charge(combinedRow); err != nil {
+						return nil, err
+					}
 					newRows = append(newRows, combinedRow)
 					matchFound = true
+					matched++
+					if joinLimit >= 0 && len(newRows) >= joinLimit {
+						break
+					}
 				}
 			}
 
@@ -165,91 +710,155 @@ func (e *Executor) executeSelect(stmt *SelectStatement) (*Result, error) {
 				for k := 0; k < targetColsLen; k++ {
 					combinedValues[len(leftRow.Values)+k] = storage.NullValue{}
 				}
-				newRows = append(newRows, storage.NewRow(combinedValues))
+				nullRow := storage.NewRow(combinedValues)
+				if err := charge(nullRow); err != nil {
+					return nil, err
+				}
+				newRows = append(newRows, nullRow)
+				unmatched++
 			}
 		}
 
+		joinSpan.SetAttributes(attribute.Int("rdbms.rows", len(newRows)))
+		joinSpan.End()
+		e.traceStep("join", "type", join.Type, "table", join.Table,
+			"pairs", steps, "matched", matched, "unmatched_left", unmatched, "rows", len(newRows))
+
 		intermediateRows = newRows
+		e.mem.release(held)
+		held = joinHeld
 		currentOffset += targetColsLen
 	}
 
-	// 3. Apply WHERE clause on the fully joined rows
-	finalRows := make([]*storage.Row, 0)
-	for _, row := range intermediateRows {
-		if stmt.Where != nil {
-			val, err := e.evaluateExpressionForJoinedRow(stmt.Where, row, tableMap, offsetMap)
-			if err != nil {
+	// 3. Apply WHERE clause on the fully joined rows, unless the scan has
+	// already applied it.
+	finalRows := intermediateRows
+	rowsIn := scanned
+	if !pushFilter {
+		filterSpan := e.startSpan("rdbms.filter")
+		finalRows = make([]*storage.Row, 0)
+		rowsIn = 0
+		for i, row := range intermediateRows {
+			if want >= 0 && len(finalRows) >= want {
+				break
+			}
+			rowsIn++
+			if err := e.checkContext(i + 1); err != nil {
+				endSpan(filterSpan, err)
 				return nil, err
 			}
-			if !e.getValueAsBool(val) {
-				continue
+			if stmt.Where != nil {
+				val, err := e.evaluateExpressionForJoinedRow(stmt.Where, row, tableMap, offsetMap)
+				if err != nil {
+					endSpan(filterSpan, err)
+					return nil, err
+				}
+				if !e.getValueAsBool(val) {
+					rejected.add(stmt.Where, row, val, nil)
+					continue
+				}
+			}
+			if stmt.Where != nil {
+				if err := e.mem.grow("filter", pointerSize); err != nil {
+					endSpan(filterSpan, err)
+					return nil, err
+				}
+			}
+			finalRows = append(finalRows, row)
+		}
+		filterSpan.SetAttributes(attribute.Int("rdbms.rows", len(finalRows)))
+		filterSpan.End()
+	}
+	if stmt.Where != nil {
+		e.traceStep("filter", "predicate", stmt.Where.String(),
+			"rows_in", rowsIn, "rejected", rejected.count, "rows_out", len(finalRows))
+	}
+
+	// Grouped queries aggregate, sort and project in one step.
+	if stmt.IsAggregate() {
+		result, err := e.aggregateRows(stmt, finalRows, tableMap, offsetMap)
+		if err != nil {
+			return nil, err
+		}
+		e.traceStep("aggregate", "rows_in", len(finalRows), "groups", len(result.Rows))
+		if stmt.Distinct {
+			if err := e.distinctRows(result); err != nil {
+				return nil, err
 			}
 		}
-		finalRows = append(finalRows, row)
+		e.limitResult(result, stmt)
+		return result, nil
+	}
+
+	// 4. Order By
+	if len(stmt.OrderBy) > 0 {
+		if err := e.sortRows(finalRows, stmt.OrderBy, tableMap, offsetMap); err != nil {
+			return nil, err
+		}
 	}
 
-	// 4. Project Results
+	// 5. Project Results
+	columns, indexes, exprs, err := e.projectColumns(stmt, tableMap, offsetMap)
+	if err != nil {
+		return nil, err
+	}
 	result := &Result{
-		Columns: stmt.Columns,
+		Columns: columns,
 		Rows:    make([][]string, 0),
 	}
-	
-	if len(stmt.Columns) == 1 && stmt.Columns[0] == "*" {
-		result.Columns = make([]string, 0)
-		
-		for _, col := range primaryTable.Schema.Columns {
-			result.Columns = append(result.Columns, col.Name) 
-		}
-		for _, join := range stmt.Joins {
-			if join.Alias != "" {
-				tbl, _ := e.db.GetTable(join.Table)
-				for _, col := range tbl.Schema.Columns {
-					result.Columns = append(result.Columns, col.Name)
-				}
-			} else {
-				tbl, _ := e.db.GetTable(join.Table)
-				for _, col := range tbl.Schema.Columns {
-					result.Columns = append(result.Columns, col.Name)
-				}
-			}
+
+	for i, row := range finalRows {
+		if err := e.checkContext(i + 1); err != nil {
+			return nil, err
 		}
+		rowValues, err := e.projectRow(row, indexes, exprs, tableMap, offsetMap)
+		if err != nil {
+			return nil, err
+		}
+		rowStringValues := make([]string, len(rowValues))
+		for j, val := range rowValues {
+			rowStringValues[j] = val.ToString()
+		}
+		if err := e.mem.grow("project", resultRowBytes(rowStringValues)); err != nil {
+			return nil, err
+		}
+		result.Rows = append(result.Rows, rowStringValues)
+		result.Values = append(result.Values, rowValues)
 	}
 
-	for _, row := range finalRows {
-		rowStringValues := make([]string, 0)
-		for _, colName := range result.Columns {
-			colRef := &ColumnRef{Column: colName}
-			
-			var tablePart, colPart string
-			dotIdx := -1
-			for i, char := range colName {
-				if char == '.' {
-					dotIdx = i
-					break
-				}
-			}
-			
-			if dotIdx != -1 {
-				tablePart = colName[:dotIdx]
-				colPart = colName[dotIdx+1:]
-				colRef = &ColumnRef{Table: tablePart, Column: colPart}
-			} else {
-				colRef = &ColumnRef{Column: colName}
-			}
-			
-			idx, err := e.resolveColumnIndex(colRef, tableMap, offsetMap)
-			if err != nil {
-				return nil, err
-			}
-			
-			val, _ := row.Get(idx)
-			rowStringValues = append(rowStringValues, val.ToString())
+	if stmt.Distinct {
+		if err := e.distinctRows(result); err != nil {
+			return nil, err
 		}
-		result.Rows = append(result.Rows, rowStringValues)
 	}
 
-	// 5. Limit and Offset
+	// 6. Limit and Offset
+	e.limitResult(result, stmt)
+
+	return result, nil
+}
+
+// rowsWanted is how many rows the scan, join and filter steps must produce
+// for stmt, or -1 when they need every row: only a LIMIT without ORDER BY,
+// DISTINCT or aggregation lets them stop early.
+func rowsWanted(stmt *SelectStatement) int {
+	if stmt.Limit == nil || len(stmt.OrderBy) > 0 || stmt.Distinct || stmt.IsAggregate() {
+		return -1
+	}
+	want := *stmt.Limit
+	if stmt.Offset != nil {
+		want += *stmt.Offset
+	}
+	return want
+}
+
+// limitResult applies the statement's LIMIT and OFFSET to result.
+func (e *Executor) limitResult(result *Result, stmt *SelectStatement) {
 	if stmt.Limit != nil && len(result.Rows) > 0 {
+		before := len(result.Rows)
+		defer func() {
+			e.traceStep("limit", "rows_in", before, "rows_out", len(result.Rows))
+		}()
 		limit := *stmt.Limit
 		offset := 0
 		if stmt.Offset != nil {
@@ -258,21 +867,46 @@ func (e *Executor) executeSelect(stmt *SelectStatement) (*Result, error) {
 		
 		if offset >= len(result.Rows) {
 			result.Rows = make([][]string, 0)
+			result.Values = make([][]storage.Value, 0)
 		} else {
 			end := offset + limit
 			if end > len(result.Rows) {
 				end = len(result.Rows)
 			}
 			result.Rows = result.Rows[offset:end]
+			result.Values = result.Values[offset:end]
 		}
 	}
-
-	return result, nil
 }
 
+// insertBatchSize is how many rows of a multi-row INSERT are evaluated and
+// then inserted together.
+const insertBatchSize = cancelCheckInterval
+
 func (e *Executor) executeInsert(stmt *InsertStatement) (*Result, error) {
-	table, err := e.db.GetTable(stmt.Table)
+	table, err := e.writableTable(stmt.Table)
 	if err != nil {
+		return nil, positioned(err, stmt.TablePos, stmt.Table, "")
+	}
+
+	// Resolve the column list once rather than per row: positions[i] is
+	// where the ith value of each row goes, or -1 to drop it. Columns not
+	// given are NULL. The rows are built for the schema loaded here; the
+	// storage rejects them if an ALTER TABLE has changed a type since.
+	schema := table.Schema()
+	width := len(schema.Columns)
+	positions := make([]int, width)
+	for i := range positions {
+		positions[i] = i
+	}
+	if len(stmt.Columns) > 0 {
+		positions = positions[:0]
+		for _, name := range stmt.Columns {
+			positions = append(positions, schema.ColumnIndex(name))
+		}
+	}
+
+	if err := e.checkStrictInsert(stmt, positions); err != nil {
 		return nil, err
 	}
 
@@ -280,88 +914,135 @@ func (e *Executor) executeInsert(stmt *InsertStatement) (*Result, error) {
 		RowsAffected: 0,
 	}
 
-	for _, rowExprs := range stmt.Values {
-		rowValues := make([]storage.Value, len(table.Schema.Columns))
-
-		if len(stmt.Columns) > 0 {
-			colToExpr := make(map[string]Expression)
-			for i, colName := range stmt.Columns {
-				if i < len(rowExprs) {
-					colToExpr[colName] = rowExprs[i]
-				}
+	inserted, conflicted := 0, 0
+	batch := make([]*storage.Row, 0, min(len(stmt.Values), insertBatchSize))
+	// last holds the values of the last row, taken before it is stored:
+	// an ALTER TABLE may replace the stored row's values once the table's
+	// lock is released, but leaves these, with the key the storage gave
+	// the row, as they were.
+	var last []storage.Value
+	for start := 0; start < len(stmt.Values); start += insertBatchSize {
+		if err := e.checkContext(start); err != nil {
+			return nil, err
+		}
+		end := min(start+insertBatchSize, len(stmt.Values))
+
+		// One allocation holds the values of every row of the batch.
+		values := make([]storage.Value, (end-start)*width)
+		batch = batch[:0]
+		for i, rowExprs := range stmt.Values[start:end] {
+			rowValues := values[i*width : (i+1)*width : (i+1)*width]
+			for j := range rowValues {
+				rowValues[j] = storage.NullValue{}
 			}
-
-			for i, colDef := range table.Schema.Columns {
-				if expr, exists := colToExpr[colDef.Name]; exists {
-					val, err := e.evaluateExpression(expr, table)
-					if err != nil {
-						return nil, err
-					}
-					rowValues[i] = val
-				} else {
-					rowValues[i] = storage.NullValue{}
+			for j, expr := range rowExprs {
+				if j >= len(positions) {
+					break
 				}
-			}
-		} else {
-			for i := range table.Schema.Columns {
-				if i < len(rowExprs) {
-					val, err := e.evaluateExpression(rowExprs[i], table)
-					if err != nil {
-						return nil, err
-					}
-					rowValues[i] = val
-				} else {
-					rowValues[i] = storage.NullValue{}
+				if positions[j] < 0 {
+					continue
+				}
+				val, err := e.evaluateExpression(expr, table)
+				if err != nil {
+					return nil, err
 				}
+				val, err = e.columnValue(expr, schema.Columns[positions[j]], val)
+				if err != nil {
+					return nil, err
+				}
+				rowValues[positions[j]] = val
 			}
+			batch = append(batch, storage.NewRow(rowValues))
+			last = rowValues
 		}
 
-		row := storage.NewRow(rowValues)
-		_, err := table.Insert(row)
+		n, conflicts, err := e.insertRowsOnConflict(table, batch)
 		if err != nil {
 			return nil, err
 		}
-		result.RowsAffected++
+		inserted += n
+		conflicted += conflicts
+		result.RowsAffected += len(batch)
+		if e.progress != nil {
+			e.progress(Progress{Table: stmt.Table, Rows: result.RowsAffected, Total: len(stmt.Values)})
+		}
 	}
 
-	result.Message = fmt.Sprintf("%d row(s) inserted", result.RowsAffected)
+	if pk := schema.PrimaryKeyColumns(); len(pk) == 1 && last != nil {
+		if id, ok := last[schema.ColumnIndex(pk[0].Name)].(*storage.IntegerValue); ok {
+			result.LastInsertID = id.Value
+			e.lastInsertID = &result.LastInsertID
+		}
+	}
+
+	if e.onConflict == storage.ConflictSkip {
+		result.RowsAffected = inserted
+	}
+	result.Message = e.conflictMessage(inserted, conflicted)
 	return result, nil
 }
 
 func (e *Executor) executeUpdate(stmt *UpdateStatement) (*Result, error) {
-	table, err := e.db.GetTable(stmt.Table)
+	table, err := e.writableTable(stmt.Table)
 	if err != nil {
-		return nil, err
+		return nil, positioned(err, stmt.TablePos, stmt.Table, "")
 	}
 
 	result := &Result{
 		RowsAffected: 0,
 	}
 
-	predicate := e.buildPredicate(stmt.Where, table)
+	if err := e.checkStrictUpdate(stmt, table); err != nil {
+		return nil, err
+	}
+	exprs := []Expression{stmt.Where}
+	for _, setClause := range stmt.SetClauses {
+		exprs = append(exprs, setClause.Value)
+	}
+	if err := e.runSubqueries(exprs...); err != nil {
+		return nil, err
+	}
 
-	updater := func(row *storage.Row) {
-		updates := make(map[string]storage.Value)
-		for _, setClause := range stmt.SetClauses {
-			val, err := e.evaluateExpression(setClause.Value, table)
+	var failed error
+	rejected := &rejections{e: e, step: "WHERE"}
+	predicate := e.cancelablePredicate(e.buildPredicate(stmt.Where, table, rejected, &failed), &failed)
+
+	// The storage calls updater under the table's lock with a copy of
+	// the row, so every SET expression reads the row as it was before any
+	// of them is applied.
+	updater := func(row *storage.Row) error {
+		schema := table.Schema()
+		updates := make([]storage.Value, len(stmt.SetClauses))
+		for i, setClause := range stmt.SetClauses {
+			val, err := e.evaluateExpressionForRow(setClause.Value, table, row)
 			if err != nil {
-				return
+				return err
 			}
-			updates[setClause.Column] = val
+			if col, ok := schema.GetColumn(setClause.Column); ok {
+				if val, err = e.columnValue(setClause.Value, col, val); err != nil {
+					return err
+				}
+			}
+			updates[i] = val
 		}
 
-		for colName, val := range updates {
-			colIdx := table.Schema.ColumnIndex(colName)
+		for i, setClause := range stmt.SetClauses {
+			colIdx := schema.ColumnIndex(setClause.Column)
 			if colIdx >= 0 {
-				row.Set(colIdx, val)
+				row.Set(colIdx, updates[i])
 			}
 		}
+		return nil
 	}
 
-	updated, err := table.Update(predicate, updater)
+	updated, err := e.updateRows(table, predicate, updater)
 	if err != nil {
 		return nil, err
 	}
+	if failed != nil {
+		return nil, failed
+	}
+	e.traceWrite(stmt.Table, stmt.Where, updated, rejected)
 
 	result.RowsAffected = updated
 	result.Message = fmt.Sprintf("%d row(s) updated", updated)
@@ -369,28 +1050,70 @@ func (e *Executor) executeUpdate(stmt *UpdateStatement) (*Result, error) {
 }
 
 func (e *Executor) executeDelete(stmt *DeleteStatement) (*Result, error) {
-	table, err := e.db.GetTable(stmt.Table)
+	table, err := e.writableTable(stmt.Table)
 	if err != nil {
-		return nil, err
+		return nil, positioned(err, stmt.TablePos, stmt.Table, "")
 	}
 
 	result := &Result{
 		RowsAffected: 0,
 	}
 
-	predicate := e.buildPredicate(stmt.Where, table)
+	if err := e.runSubqueries(stmt.Where); err != nil {
+		return nil, err
+	}
+
+	var failed error
+	rejected := &rejections{e: e, step: "WHERE"}
+	predicate := e.cancelablePredicate(e.buildPredicate(stmt.Where, table, rejected, &failed), &failed)
 
-	deleted, err := table.Delete(predicate)
+	deleted, err := e.deleteRows(table, predicate)
 	if err != nil {
 		return nil, err
 	}
+	if failed != nil {
+		return nil, failed
+	}
+	e.traceWrite(stmt.Table, stmt.Where, deleted, rejected)
 
 	result.RowsAffected = deleted
 	result.Message = fmt.Sprintf("%d row(s) deleted", deleted)
 	return result, nil
 }
 
+// insertRows, updateRows and deleteRows record the write in the current
+// transaction when there is one, so it can be rolled back.
+func (e *Executor) insertRows(table *storage.Table, rows []*storage.Row) error {
+	if e.tx != nil {
+		_, err := e.tx.InsertRows(table, rows)
+		return err
+	}
+	for _, row := range rows {
+		if _, err := table.Insert(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Executor) updateRows(table *storage.Table, predicate func(*storage.Row) bool, updater func(*storage.Row) error) (int, error) {
+	if e.tx != nil {
+		return e.tx.Update(table, predicate, updater)
+	}
+	return table.Update(predicate, updater)
+}
+
+func (e *Executor) deleteRows(table *storage.Table, predicate func(*storage.Row) bool) (int, error) {
+	if e.tx != nil {
+		return e.tx.Delete(table, predicate)
+	}
+	return table.Delete(predicate)
+}
+
 func (e *Executor) executeCreateTable(stmt *CreateTableStatement) (*Result, error) {
+	if stmt.Location != "" {
+		return e.executeCreateExternalTable(stmt)
+	}
 	schema := storage.NewSchema()
 
 	for _, colDef := range stmt.Columns {
@@ -400,19 +1123,39 @@ func (e *Executor) executeCreateTable(stmt *CreateTableStatement) (*Result, erro
 		}
 
 		col := storage.NewColumn(colDef.Name, dataType, colDef.Primary, colDef.Unique, colDef.NotNull)
+		col.NullsNotDistinct = colDef.NullsNotDistinct
 
 		if colDef.Default != nil {
 			defaultValue, err := e.evaluateExpression(*colDef.Default, nil)
 			if err != nil {
 				return nil, fmt.Errorf("error evaluating default value for column %s: %w", colDef.Name, err)
 			}
-			col.Default = defaultValue
+			col.Default = quotedText(*colDef.Default, col, defaultValue)
 		}
 
 		schema.AddColumn(col)
 	}
 
-	err := e.db.CreateTable(stmt.Table, schema)
+	if result, kept, err := e.keepTable(stmt, schema); kept {
+		return result, err
+	}
+	if stmt.Temporary {
+		return e.executeCreateTemporaryTable(stmt, schema)
+	}
+
+	fks, err := e.foreignKeys(stmt, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.tx != nil {
+		err = e.tx.CreateTable(stmt.Table, schema, fks...)
+	} else {
+		err = e.db.CreateTable(stmt.Table, schema)
+		for i := 0; err == nil && i < len(fks); i++ {
+			err = e.db.AddForeignKey(stmt.Table, fks[i])
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -421,7 +1164,16 @@ func (e *Executor) executeCreateTable(stmt *CreateTableStatement) (*Result, erro
 }
 
 func (e *Executor) executeDropTable(stmt *DropTableStatement) (*Result, error) {
-	err := e.db.DropTable(stmt.Table)
+	var err error
+	if name, temp := e.temporary(stmt.Table); temp {
+		err = e.dropTemporaryTable(name)
+	} else if _, ok := e.db.ExternalTable(stmt.Table); ok {
+		err = e.db.DropExternalTable(stmt.Table)
+	} else if e.tx != nil {
+		err = e.tx.DropTable(stmt.Table)
+	} else {
+		err = e.db.DropTable(stmt.Table)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -429,6 +1181,59 @@ func (e *Executor) executeDropTable(stmt *DropTableStatement) (*Result, error) {
 	return &Result{Message: fmt.Sprintf("Table %s dropped", stmt.Table)}, nil
 }
 
+func (e *Executor) executeListen(stmt *ListenStatement) (*Result, error) {
+	if e.listener == nil {
+		e.listener = e.db.NewListener()
+	}
+	e.listener.Listen(stmt.Channel)
+
+	return &Result{Message: stmt.String()}, nil
+}
+
+func (e *Executor) executeUnlisten(stmt *UnlistenStatement) (*Result, error) {
+	if e.listener != nil {
+		if stmt.Channel == "*" {
+			e.listener.UnlistenAll()
+		} else {
+			e.listener.Unlisten(stmt.Channel)
+		}
+	}
+
+	return &Result{Message: stmt.String()}, nil
+}
+
+func (e *Executor) executeNotify(stmt *NotifyStatement) (*Result, error) {
+	payload := ""
+	if stmt.Payload != nil {
+		val, err := e.evaluateExpression(stmt.Payload, nil)
+		if err != nil {
+			return nil, err
+		}
+		payload = val.ToString()
+	}
+
+	e.db.Notify(stmt.Channel, payload)
+	return &Result{Message: "NOTIFY"}, nil
+}
+
+// Notifications drains the notifications received on channels this
+// executor is listening on.
+func (e *Executor) Notifications() []storage.Notification {
+	if e.listener == nil {
+		return nil
+	}
+
+	pending := make([]storage.Notification, 0)
+	for {
+		select {
+		case n := <-e.listener.C:
+			pending = append(pending, n)
+		default:
+			return pending
+		}
+	}
+}
+
 func (e *Executor) parseDataType(typeName string) (storage.DataType, error) {
 	switch typeName {
 	case "INTEGER":
@@ -439,12 +1244,16 @@ func (e *Executor) parseDataType(typeName string) (storage.DataType, error) {
 		return storage.TypeFloat, nil
 	case "BOOLEAN", "BOOL":
 		return storage.TypeBoolean, nil
+	case "INTERVAL":
+		return storage.TypeInterval, nil
 	default:
-		return 0, fmt.Errorf("unsupported data type: %s", typeName)
+		return 0, errorf(ErrUnsupported, "unsupported data type: %s", typeName)
 	}
 }
 
-func (e *Executor) buildPredicate(expr Expression, table *storage.Table) func(*storage.Row) bool {
+// buildPredicate returns a row filter for expr, recording the rows it
+// rejects in rejected and the first evaluation error in *errp.
+func (e *Executor) buildPredicate(expr Expression, table *storage.Table, rejected *rejections, errp *error) func(*storage.Row) bool {
 	if expr == nil {
 		return func(row *storage.Row) bool { return true }
 	}
@@ -452,13 +1261,18 @@ func (e *Executor) buildPredicate(expr Expression, table *storage.Table) func(*s
 	return func(row *storage.Row) bool {
 		val, err := e.evaluateExpressionForRow(expr, table, row)
 		if err != nil {
+			if *errp == nil {
+				*errp = err
+			}
+			rejected.add(expr, row, nil, err)
 			return false
 		}
 
-		if boolVal, ok := val.(*storage.BooleanValue); ok {
-			return boolVal.Value
+		if boolVal, ok := val.(*storage.BooleanValue); ok && boolVal.Value {
+			return true
 		}
 
+		rejected.add(expr, row, val, nil)
 		return false
 	}
 }
@@ -473,13 +1287,16 @@ func (e *Executor) evaluateExpressionForRow(expr Expression, table *storage.Tabl
 		return expr.parseLiteral()
 	case *NullLiteral:
 		return storage.NullValue{}, nil
+	case *Parameter:
+		return e.paramValue(expr)
 	case *ColumnRef:
 		if row == nil {
 			return nil, fmt.Errorf("cannot evaluate column reference without row context")
 		}
-		colIdx := table.Schema.ColumnIndex(expr.Column)
+		colIdx := table.Schema().ColumnIndex(expr.Column)
 		if colIdx < 0 {
-			return nil, fmt.Errorf("column not found: %s", expr.Column)
+			err := errorf(ErrColumnNotFound, "column not found: %s", expr.Column)
+			return nil, positioned(err, expr.Pos, expr.String(), columnSuggestion(map[string]*storage.Table{table.Name: table}, nil))
 		}
 		return row.Get(colIdx)
 	case *BinaryExpression:
@@ -491,15 +1308,42 @@ func (e *Executor) evaluateExpressionForRow(expr Expression, table *storage.Tabl
 		if err != nil {
 			return nil, err
 		}
-		return e.evaluateBinaryOp(left, expr.Op, right)
+		val, err := e.evaluateBinaryOp(left, expr.Op, right)
+		return val, positioned(err, expr.Pos, expr.String(), "")
 	case *UnaryExpression:
 		right, err := e.evaluateExpressionForRow(expr.Right, table, row)
 		if err != nil {
 			return nil, err
 		}
-		return e.evaluateUnaryOp(expr.Op, right)
+		val, err := e.evaluateUnaryOp(expr.Op, right)
+		return val, positioned(err, expr.Pos, expr.String(), "")
+	case *InExpression:
+		return e.evaluateIn(expr, func(operand Expression) (storage.Value, error) {
+			return e.evaluateExpressionForRow(operand, table, row)
+		})
+	case *CastExpression:
+		return e.evaluateCast(expr, func(operand Expression) (storage.Value, error) {
+			return e.evaluateExpressionForRow(operand, table, row)
+		})
+	case *SystemFunction:
+		return systemFunctions[expr.Name](e), nil
+	case *IntervalLiteral:
+		return expr.parse()
+	case *FunctionCall:
+		if aggregateFunctions[expr.Name] {
+			return nil, misplacedAggregate(expr)
+		}
+		args := make([]storage.Value, len(expr.Arguments))
+		for i, arg := range expr.Arguments {
+			v, err := e.evaluateExpressionForRow(arg, table, row)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return callScalar(expr, args)
 	default:
-		return nil, fmt.Errorf("unsupported expression type: %T", expr)
+		return nil, errorf(ErrUnsupported, "unsupported expression type: %T", expr)
 	}
 }
 
@@ -509,6 +1353,8 @@ func (e *Executor) evaluateExpressionForJoinedRow(expr Expression, row *storage.
 		return expr.parseLiteral()
 	case *NullLiteral:
 		return storage.NullValue{}, nil
+	case *Parameter:
+		return e.paramValue(expr)
 	case *ColumnRef:
 		if row == nil {
 			return nil, fmt.Errorf("cannot evaluate column reference without row context")
@@ -527,18 +1373,54 @@ func (e *Executor) evaluateExpressionForJoinedRow(expr Expression, row *storage.
 		if err != nil {
 			return nil, err
 		}
-		return e.evaluateBinaryOp(left, expr.Op, right)
+		val, err := e.evaluateBinaryOp(left, expr.Op, right)
+		return val, positioned(err, expr.Pos, expr.String(), "")
 	case *UnaryExpression:
 		right, err := e.evaluateExpressionForJoinedRow(expr.Right, row, tables, offsets)
 		if err != nil {
 			return nil, err
 		}
-		return e.evaluateUnaryOp(expr.Op, right)
+		val, err := e.evaluateUnaryOp(expr.Op, right)
+		return val, positioned(err, expr.Pos, expr.String(), "")
+	case *InExpression:
+		return e.evaluateIn(expr, func(operand Expression) (storage.Value, error) {
+			return e.evaluateExpressionForJoinedRow(operand, row, tables, offsets)
+		})
+	case *CastExpression:
+		return e.evaluateCast(expr, func(operand Expression) (storage.Value, error) {
+			return e.evaluateExpressionForJoinedRow(operand, row, tables, offsets)
+		})
+	case *SystemFunction:
+		return systemFunctions[expr.Name](e), nil
+	case *IntervalLiteral:
+		return expr.parse()
+	case *FunctionCall:
+		if aggregateFunctions[expr.Name] {
+			return nil, misplacedAggregate(expr)
+		}
+		args := make([]storage.Value, len(expr.Arguments))
+		for i, arg := range expr.Arguments {
+			v, err := e.evaluateExpressionForJoinedRow(arg, row, tables, offsets)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return callScalar(expr, args)
 	default:
-		return nil, fmt.Errorf("unsupported expression type: %T", expr)
+		return nil, errorf(ErrUnsupported, "unsupported expression type: %T", expr)
 	}
 }
 
+// quotedText returns v, the value of expr for col, or the text of expr when
+// it is a string literal for a TEXT column: '12' and 'true' are text there.
+func quotedText(expr Expression, col *storage.Column, v storage.Value) storage.Value {
+	if lit, ok := expr.(*LiteralExpression); ok && lit.Quoted && col.Type == storage.TypeText {
+		return storage.NewTextValue(lit.Value)
+	}
+	return v
+}
+
 func (e *LiteralExpression) parseLiteral() (storage.Value, error) {
 	if isNumericLiteral(e.Value) {
 		if containsDecimal(e.Value) {
@@ -556,7 +1438,7 @@ func (e *LiteralExpression) parseLiteral() (storage.Value, error) {
 		}
 	}
 
-	lower := toLower(e.Value)
+	lower := strings.ToLower(e.Value)
 	if lower == "true" {
 		return storage.NewBooleanValue(true), nil
 	}
@@ -567,34 +1449,32 @@ func (e *LiteralExpression) parseLiteral() (storage.Value, error) {
 	return storage.NewTextValue(e.Value), nil
 }
 
+// numericLiteral is compiled once: a bulk INSERT parses a literal for every
+// value of every row.
+var numericLiteral = regexp.MustCompile(`^-?\d+\.?\d*$`)
+
 func isNumericLiteral(s string) bool {
-	match, _ := regexp.MatchString(`^-?\d+\.?\d*$`, s)
-	return match
+	return numericLiteral.MatchString(s)
 }
 
 func containsDecimal(s string) bool {
-	return regexp.MustCompile(`\.`).MatchString(s)
-}
-
-func toLower(s string) string {
-	return regexp.MustCompile(`(?i)[A-Z]`).ReplaceAllStringFunc(s, func(c string) string {
-		return string([]byte{toLowerChar(c[0])})
-	})
-}
-
-func toLowerChar(c byte) byte {
-	if c >= 'A' && c <= 'Z' {
-		return c + ('a' - 'A')
-	}
-	return c
+	return strings.Contains(s, ".")
 }
 
 func (e *Executor) evaluateBinaryOp(left storage.Value, op string, right storage.Value) (storage.Value, error) {
+	left, right, err := e.strictOperands(left, op, right)
+	if err != nil {
+		return nil, err
+	}
 	switch op {
 	case "=", "==":
 		return storage.NewBooleanValue(left.Equals(right)), nil
 	case "!=", "<>":
 		return storage.NewBooleanValue(!left.Equals(right)), nil
+	case "<=>", "IS NOT DISTINCT FROM":
+		return storage.NewBooleanValue(notDistinct(left, right)), nil
+	case "IS DISTINCT FROM":
+		return storage.NewBooleanValue(!notDistinct(left, right)), nil
 	case "<":
 		return storage.NewBooleanValue(left.LessThan(right)), nil
 	case "<=":
@@ -612,9 +1492,21 @@ func (e *Executor) evaluateBinaryOp(left storage.Value, op string, right storage
 		rightBool := e.getValueAsBool(right)
 		return storage.NewBooleanValue(leftBool || rightBool), nil
 	case "+", "-", "*", "/":
+		if isNull(left) || isNull(right) {
+			return storage.NullValue{}, nil
+		}
+		if v, ok, err := dateArithmetic(left, op, right); ok {
+			return v, err
+		}
 		return e.evaluateArithmeticOp(left, op, right)
+	case "LIKE", "ILIKE", "NOT LIKE", "NOT ILIKE":
+		return evaluateLike(left, op, right), nil
+	case "MATCH", "NOT MATCH":
+		return evaluateMatch(left, op, right), nil
+	case "%":
+		return evaluateSimilar(left, right)
 	default:
-		return nil, fmt.Errorf("unsupported binary operator: %s", op)
+		return nil, errorf(ErrUnsupported, "unsupported binary operator: %s", op)
 	}
 }
 
@@ -629,10 +1521,10 @@ func (e *Executor) evaluateUnaryOp(op string, right storage.Value) (storage.Valu
 		case *storage.FloatValue:
 			return storage.NewFloatValue(-v.Value), nil
 		default:
-			return nil, fmt.Errorf("unary minus not supported for type %T", right)
+			return nil, errorf(ErrTypeMismatch, "unary minus not supported for type %T", right)
 		}
 	default:
-		return nil, fmt.Errorf("unsupported unary operator: %s", op)
+		return nil, errorf(ErrUnsupported, "unsupported unary operator: %s", op)
 	}
 }
 
@@ -650,7 +1542,7 @@ func (e *Executor) evaluateArithmeticOp(left storage.Value, op string, right sto
 				return storage.NewIntegerValue(l.Value * r.Value), nil
 			case "/":
 				if r.Value == 0 {
-					return nil, fmt.Errorf("division by zero")
+					return nil, errorf(ErrDivisionByZero, "division by zero")
 				}
 				return storage.NewIntegerValue(l.Value / r.Value), nil
 			}
@@ -664,7 +1556,7 @@ func (e *Executor) evaluateArithmeticOp(left storage.Value, op string, right sto
 				return storage.NewFloatValue(float64(l.Value) * r.Value), nil
 			case "/":
 				if r.Value == 0 {
-					return nil, fmt.Errorf("division by zero")
+					return nil, errorf(ErrDivisionByZero, "division by zero")
 				}
 				return storage.NewFloatValue(float64(l.Value) / r.Value), nil
 			}
@@ -681,7 +1573,7 @@ func (e *Executor) evaluateArithmeticOp(left storage.Value, op string, right sto
 				return storage.NewFloatValue(l.Value * float64(r.Value)), nil
 			case "/":
 				if r.Value == 0 {
-					return nil, fmt.Errorf("division by zero")
+					return nil, errorf(ErrDivisionByZero, "division by zero")
 				}
 				return storage.NewFloatValue(l.Value / float64(r.Value)), nil
 			}
@@ -695,14 +1587,14 @@ func (e *Executor) evaluateArithmeticOp(left storage.Value, op string, right sto
 				return storage.NewFloatValue(l.Value * r.Value), nil
 			case "/":
 				if r.Value == 0 {
-					return nil, fmt.Errorf("division by zero")
+					return nil, errorf(ErrDivisionByZero, "division by zero")
 				}
 				return storage.NewFloatValue(l.Value / r.Value), nil
 			}
 		}
 	}
 
-	return nil, fmt.Errorf("arithmetic operation not supported for types %T and %T", left, right)
+	return nil, errorf(ErrTypeMismatch, "arithmetic operation not supported for types %T and %T", left, right)
 }
 
 func (e *Executor) getValueAsBool(v storage.Value) bool {
@@ -0,0 +1,48 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// executeCreateExternalTable registers a CSV file as a read-only table.
+// The file is not read until the table is scanned, so it may be replaced
+// or created later. Without a column list the columns come from the file.
+func (e *Executor) executeCreateExternalTable(stmt *CreateTableStatement) (*Result, error) {
+	if !e.db.FileReads() {
+		return nil, errorf(ErrUnsupported, "cannot create external table %s: this server does not allow reading files", stmt.Table)
+	}
+
+	var schema *storage.Schema
+	if len(stmt.Columns) > 0 {
+		schema = storage.NewSchema()
+		for _, colDef := range stmt.Columns {
+			if colDef.Primary || colDef.Unique || colDef.NotNull || colDef.Default != nil {
+				return nil, errorf(ErrUnsupported, "column %s: external tables cannot have constraints or defaults", colDef.Name)
+			}
+			dataType, err := e.parseDataType(colDef.Type)
+			if err != nil {
+				return nil, fmt.Errorf("invalid data type %s for column %s: %w", colDef.Type, colDef.Name, err)
+			}
+			schema.AddColumn(storage.NewColumn(colDef.Name, dataType, false, false, false))
+		}
+	}
+
+	ext := &storage.ExternalTable{Name: stmt.Table, Schema: schema, Location: stmt.Location}
+	if err := e.db.CreateExternalTable(ext); err != nil {
+		return nil, err
+	}
+	return &Result{Message: fmt.Sprintf("External table %s created", stmt.Table)}, nil
+}
+
+// writableTable returns table name for INSERT, UPDATE or DELETE.
+func (e *Executor) writableTable(name string) (*storage.Table, error) {
+	if name, temp := e.temporary(name); temp {
+		return e.temporaryTables().GetTable(name)
+	}
+	if _, ok := e.db.ExternalTable(name); ok {
+		return nil, errorf(ErrReadOnly, "external table %s is read-only", name)
+	}
+	return e.db.GetTable(name)
+}
@@ -0,0 +1,304 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format lays out a statement for reading, as in the console and in dumps.
+// Each clause of a SELECT, INSERT, UPDATE or DELETE starts a line, with
+// keywords and function names in upper case. A clause longer than
+// formatWidth puts each item of its list, or each condition of its ANDs,
+// on a line of its own, indented under it; the SELECTs of a UNION,
+// INTERSECT or EXCEPT are separated by the operator on a line of its own.
+// EXPLAIN formats the statement it explains, and other statements are
+// written as String writes them.
+//
+// Unlike String, whose text also names result columns and plan steps,
+// Format quotes string literals, so the text parses back to the same
+// statement.
+
+// formatWidth is the longest a clause may be before its items are put on
+// lines of their own.
+const formatWidth = 80
+
+const formatIndent = "  "
+
+// Format returns stmt as formatted SQL, without a trailing semicolon.
+func Format(stmt Node) string {
+	var f formatter
+	switch stmt := stmt.(type) {
+	case *SelectStatement:
+		f.selectStatement(stmt)
+	case *InsertStatement:
+		f.insert(stmt)
+	case *UpdateStatement:
+		f.update(stmt)
+	case *DeleteStatement:
+		f.delete(stmt)
+	case *ExplainStatement:
+		prefix := "EXPLAIN "
+		if stmt.Format == "DOT" {
+			prefix += "(FORMAT DOT) "
+		}
+		if stmt.Analyze {
+			prefix += "ANALYZE "
+		}
+		f.lines = append(f.lines, prefix+Format(stmt.Statement))
+	default:
+		f.lines = append(f.lines, stmt.String())
+	}
+	return strings.Join(f.lines, "\n")
+}
+
+// formatter collects the lines of a formatted statement. With oneLine
+// set its clauses are not split, as for a subquery.
+type formatter struct {
+	lines   []string
+	oneLine bool
+}
+
+// list writes keyword followed by items separated by commas: on one line
+// when it fits, otherwise each item on a line of its own.
+func (f *formatter) list(keyword string, items []string) {
+	line := keyword + " " + strings.Join(items, ", ")
+	if f.oneLine || len(line) <= formatWidth || len(items) < 2 {
+		f.lines = append(f.lines, line)
+		return
+	}
+	f.lines = append(f.lines, keyword)
+	for i, item := range items {
+		if i < len(items)-1 {
+			item += ","
+		}
+		f.lines = append(f.lines, formatIndent+item)
+	}
+}
+
+// condition writes keyword followed by cond: on one line when it fits,
+// otherwise each of its ANDs on a line of its own.
+func (f *formatter) condition(keyword string, cond Expression) {
+	line := keyword + " " + sqlText(cond)
+	conjuncts := splitAnd(cond, nil)
+	if f.oneLine || len(line) <= formatWidth || len(conjuncts) < 2 {
+		f.lines = append(f.lines, line)
+		return
+	}
+	for i, conjunct := range conjuncts {
+		text := sqlOperand(conjunct, precedence("AND")+1)
+		if i == 0 {
+			f.lines = append(f.lines, keyword+" "+text)
+		} else {
+			f.lines = append(f.lines, formatIndent+"AND "+text)
+		}
+	}
+}
+
+func (f *formatter) selectStatement(s *SelectStatement) {
+	for i, operand := range compoundOperands(s) {
+		if i > 0 {
+			op := s.Compound[i-1]
+			if op.All {
+				f.lines = append(f.lines, op.Op+" ALL")
+			} else {
+				f.lines = append(f.lines, op.Op)
+			}
+		}
+		f.simpleSelect(operand)
+	}
+	if len(s.OrderBy) > 0 {
+		keys := make([]string, len(s.OrderBy))
+		for i, ob := range s.OrderBy {
+			keys[i] = ob.String()
+		}
+		f.list("ORDER BY", keys)
+	}
+	if s.Limit != nil {
+		f.lines = append(f.lines, fmt.Sprintf("LIMIT %d", *s.Limit))
+	}
+	if s.Offset != nil {
+		f.lines = append(f.lines, fmt.Sprintf("OFFSET %d", *s.Offset))
+	}
+}
+
+// simpleSelect writes the clauses of one SELECT up to its HAVING.
+func (f *formatter) simpleSelect(s *SelectStatement) {
+	keyword := "SELECT"
+	if len(s.Hints) > 0 {
+		hints := make([]string, len(s.Hints))
+		for i, hint := range s.Hints {
+			hints[i] = hint.String()
+		}
+		keyword += " /*+ " + strings.Join(hints, " ") + " */"
+	}
+	if s.Distinct {
+		keyword += " DISTINCT"
+	}
+	columns := make([]string, len(s.Columns))
+	for i, col := range s.Columns {
+		if col.Expr != nil {
+			columns[i] = sqlText(col.Expr)
+		} else {
+			columns[i] = col.Name
+		}
+	}
+	f.list(keyword, columns)
+
+	if len(s.Tables) > 0 {
+		tables := make([]string, len(s.Tables))
+		for i, table := range s.Tables {
+			tables[i] = tableText(table)
+		}
+		f.list("FROM", tables)
+	}
+	if s.AsOf != nil {
+		f.lines = append(f.lines, formatIndent+"AS OF TIMESTAMP '"+s.AsOf.Format(time.RFC3339Nano)+"'")
+	}
+	for _, join := range s.Joins {
+		line := join.Type + " JOIN " + tableText(join.Ref())
+		if join.Type == "JOIN" {
+			line = "JOIN " + tableText(join.Ref())
+		}
+		for i, cond := range join.Conditions {
+			if i == 0 {
+				line += " ON "
+			} else {
+				line += " AND "
+			}
+			line += sqlOperand(cond, precedence("AND")+1)
+		}
+		f.lines = append(f.lines, line)
+	}
+	if s.Where != nil {
+		f.condition("WHERE", s.Where)
+	}
+	if len(s.GroupBy) > 0 {
+		f.list("GROUP BY", s.GroupBy)
+	}
+	if s.Having != nil {
+		f.condition("HAVING", s.Having)
+	}
+}
+
+func (f *formatter) insert(s *InsertStatement) {
+	line := "INSERT INTO " + s.Table
+	if len(s.Columns) > 0 {
+		line += " (" + strings.Join(s.Columns, ", ") + ")"
+	}
+	f.lines = append(f.lines, line)
+	rows := make([]string, len(s.Values))
+	for i, row := range s.Values {
+		rows[i] = "(" + sqlList(row) + ")"
+	}
+	f.list("VALUES", rows)
+}
+
+func (f *formatter) update(s *UpdateStatement) {
+	f.lines = append(f.lines, "UPDATE "+s.Table)
+	sets := make([]string, len(s.SetClauses))
+	for i, set := range s.SetClauses {
+		sets[i] = set.Column + " = " + sqlText(set.Value)
+	}
+	f.list("SET", sets)
+	if s.Where != nil {
+		f.condition("WHERE", s.Where)
+	}
+}
+
+func (f *formatter) delete(s *DeleteStatement) {
+	f.lines = append(f.lines, "DELETE FROM "+s.Table)
+	if s.Where != nil {
+		f.condition("WHERE", s.Where)
+	}
+}
+
+func tableText(t TableRef) string {
+	name := t.Name
+	if t.Function != nil {
+		name = sqlText(t.Function)
+	}
+	if t.Alias != "" {
+		return name + " AS " + t.Alias
+	}
+	return name
+}
+
+// sqlText renders expr as String does, but with string literals quoted
+// and function names in upper case.
+func sqlText(expr Expression) string {
+	switch expr := expr.(type) {
+	case *LiteralExpression:
+		if expr.Quoted {
+			return quoteText(expr.Value)
+		}
+	case *BinaryExpression:
+		prec := precedence(expr.Op)
+		return sqlOperand(expr.Left, prec) + " " + expr.Op + " " + sqlOperand(expr.Right, prec+1)
+	case *UnaryExpression:
+		return expr.Op + " " + sqlOperand(expr.Right, 3)
+	case *InExpression:
+		values := sqlList(expr.Values)
+		if expr.Subquery != nil {
+			sub := formatter{oneLine: true}
+			sub.selectStatement(expr.Subquery)
+			values = strings.Join(sub.lines, " ")
+		}
+		op := " IN ("
+		if expr.Not {
+			op = " NOT IN ("
+		}
+		return sqlOperand(expr.Left, precedence("IN")+1) + op + values + ")"
+	case *CastExpression:
+		return "CAST(" + sqlText(expr.Expr) + " AS " + expr.Type + ")"
+	case *FunctionCall:
+		result := strings.ToUpper(expr.Name) + "("
+		if expr.Distinct {
+			result += "DISTINCT "
+		}
+		result += sqlList(expr.Arguments) + ")"
+		if expr.Filter != nil {
+			result += " FILTER (WHERE " + sqlText(expr.Filter) + ")"
+		}
+		return result
+	}
+	return expr.String()
+}
+
+// sqlOperand is operand for sqlText.
+func sqlOperand(expr Expression, min int) string {
+	if b, ok := expr.(*BinaryExpression); ok && precedence(b.Op) < min {
+		return "(" + sqlText(b) + ")"
+	}
+	if in, ok := expr.(*InExpression); ok && precedence("IN") < min {
+		return "(" + sqlText(in) + ")"
+	}
+	return sqlText(expr)
+}
+
+func sqlList(exprs []Expression) string {
+	texts := make([]string, len(exprs))
+	for i, expr := range exprs {
+		texts[i] = sqlText(expr)
+	}
+	return strings.Join(texts, ", ")
+}
+
+// quoteText quotes a string literal's value. Quotes in it are doubled,
+// and a backslash before a quote or at the end is written twice, as the
+// lexer reads it back as one there.
+func quoteText(text string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\'':
+			b.WriteByte('\'')
+		case text[i] == '\\' && (i == len(text)-1 || text[i+1] == '\''):
+			b.WriteByte('\\')
+		}
+		b.WriteByte(text[i])
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package sql_test
+
+import (
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			"select id, name from users where id = 1",
+			"SELECT id, name\nFROM users\nWHERE id = 1",
+		},
+		{
+			"SELECT u.name, COUNT(t.id) FROM users u LEFT JOIN tasks t ON t.user_id = u.id WHERE u.active = true AND (u.role = 'admin' OR u.role = 'owner') AND u.created_at >= '2024-01-01' GROUP BY u.name HAVING COUNT(t.id) > 2 ORDER BY u.name DESC LIMIT 10 OFFSET 5",
+			"SELECT u.name, COUNT(t.id)\n" +
+				"FROM users AS u\n" +
+				"LEFT JOIN tasks AS t ON t.user_id = u.id\n" +
+				"WHERE u.active = true\n" +
+				"  AND (u.role = 'admin' OR u.role = 'owner')\n" +
+				"  AND u.created_at >= '2024-01-01'\n" +
+				"GROUP BY u.name\n" +
+				"HAVING COUNT(t.id) > 2\n" +
+				"ORDER BY u.name DESC\n" +
+				"LIMIT 10\n" +
+				"OFFSET 5",
+		},
+		{
+			"SELECT id, title, description, priority, status, created_at, updated_at, user_id, reviewer_id FROM tasks",
+			"SELECT\n  id,\n  title,\n  description,\n  priority,\n  status,\n  created_at,\n  updated_at,\n  user_id,\n  reviewer_id\nFROM tasks",
+		},
+		{
+			"SELECT name FROM staff UNION ALL SELECT name FROM customers ORDER BY name",
+			"SELECT name\nFROM staff\nUNION ALL\nSELECT name\nFROM customers\nORDER BY name",
+		},
+		{
+			"INSERT INTO users (id, name) VALUES (1, 'ann')",
+			"INSERT INTO users (id, name)\nVALUES (1, 'ann')",
+		},
+		{
+			"INSERT INTO users (id, name, email) VALUES (1, 'ann', 'ann@example.com'), (2, 'bob', 'bob@example.com'), (3, 'O''Brien', NULL)",
+			"INSERT INTO users (id, name, email)\nVALUES\n  (1, 'ann', 'ann@example.com'),\n  (2, 'bob', 'bob@example.com'),\n  (3, 'O''Brien', NULL)",
+		},
+		{
+			"update users set name = 'ann', email = NULL where id = 1",
+			"UPDATE users\nSET name = 'ann', email = NULL\nWHERE id = 1",
+		},
+		{
+			"DELETE FROM tasks WHERE status = 'done'",
+			"DELETE FROM tasks\nWHERE status = 'done'",
+		},
+		{
+			"EXPLAIN ANALYZE SELECT * FROM users WHERE id = 1",
+			"EXPLAIN ANALYZE SELECT *\nFROM users\nWHERE id = 1",
+		},
+		{
+			"DROP TABLE users",
+			"DROP TABLE users",
+		},
+	}
+	for _, tt := range tests {
+		stmt, err := sql.NewParser(sql.NewLexer(tt.query)).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		got := sql.Format(stmt)
+		if got != tt.want {
+			t.Errorf("Format(%s) =\n%s\nwant\n%s", tt.query, got, tt.want)
+		}
+	}
+}
+
+// TestFormatParses checks that formatted statements parse back to the
+// statements they were formatted from, which format the same way.
+func TestFormatParses(t *testing.T) {
+	for _, query := range []string{
+		"SELECT /*+ HASH_JOIN(t) */ DISTINCT u.name, t.title FROM users u JOIN tasks t ON t.user_id = u.id WHERE t.priority > 2 OR t.status = 'open' AND u.id IN (1, 2, 3) ORDER BY u.name",
+		"SELECT id FROM users WHERE name LIKE 'a%' AND email IS NOT DISTINCT FROM NULL AND id NOT IN (SELECT user_id FROM tasks WHERE status = 'done') AND id * 2 - 1 > 3",
+		"SELECT id, price * quantity, UPPER(name) FROM orders INTERSECT SELECT id, price, name FROM archive.orders EXCEPT SELECT order_id, refund, 'n/a' FROM returns LIMIT 4",
+		"INSERT INTO tasks (id, title, user_id) VALUES (1, 'write the formatter and its tests', 7), (2, 'wire it into the console', 7)",
+		"UPDATE tasks SET title = 'a rather long title for a task', status = 'in progress', priority = priority + 1 WHERE id = 3 AND status != 'done'",
+		"EXPLAIN (FORMAT DOT) SELECT name FROM users WHERE id = 1",
+	} {
+		stmt, err := sql.NewParser(sql.NewLexer(query)).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		formatted := sql.Format(stmt)
+		again, err := sql.NewParser(sql.NewLexer(formatted)).Parse()
+		if err != nil {
+			t.Fatalf("formatted %s does not parse: %v\n%s", query, err, formatted)
+		}
+		if again.String() != stmt.String() || sql.Format(again) != formatted {
+			t.Errorf("formatted %s parses as\n%s\nwant\n%s", query, sql.Format(again), formatted)
+		}
+	}
+}
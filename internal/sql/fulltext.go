@@ -0,0 +1,113 @@
+package sql
+
+import (
+	"sort"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Full-text search: column MATCH 'query' is true when the column's text
+// has every word of the query, ignoring case and punctuation, and a word
+// ending in * matches the words it begins (see storage.Tokenize). A
+// FULLTEXT index on the column answers it without reading every row, and
+// fulltext_search ranks the matches.
+
+// evaluateMatch implements [NOT] MATCH. Non-text operands are matched by
+// their text form; NULL gives NULL.
+func evaluateMatch(left storage.Value, op string, right storage.Value) storage.Value {
+	if left.Type() == storage.TypeNull || right.Type() == storage.TypeNull {
+		return storage.NullValue{}
+	}
+	matched := storage.MatchesFullText(left.ToString(), storage.FullTextTerms(right.ToString()))
+	if op == "NOT MATCH" {
+		matched = !matched
+	}
+	return storage.NewBooleanValue(matched)
+}
+
+// fullTextIndex picks a FULLTEXT index of table (known in the query as
+// name) to read the rows where can match from: one on the column of a
+// column MATCH constant among where's ANDs. It returns the terms of the
+// constant. where is still applied to the rows read.
+func (e *Executor) fullTextIndex(where Expression, table *storage.Table, name string, joined bool) (*storage.SecondaryIndex, []string, bool) {
+	if where == nil || joined {
+		return nil, nil, false
+	}
+	conjuncts := splitAnd(where, nil)
+	for _, idx := range table.SecondaryIndexes() {
+		if !idx.FullText || !e.indexAllowed(name, idx.Name, idx.Column) {
+			continue
+		}
+		for _, conjunct := range conjuncts {
+			expr, ok := conjunct.(*BinaryExpression)
+			if !ok || expr.Op != "MATCH" {
+				continue
+			}
+			ref, ok := expr.Left.(*ColumnRef)
+			if !ok || ref.Column != idx.Column || ref.Table != "" && ref.Table != name {
+				continue
+			}
+			if query, ok := e.constantValue(expr.Right); ok && query.Type() == storage.TypeText {
+				return idx, storage.FullTextTerms(query.ToString()), true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// fullTextSearch returns the rows of a table whose column matches a query,
+// as fulltext_search('tasks', 'description', 'query'), best first: the
+// table's columns and a rank, higher for rows that have the query's words
+// more often, words rare in the table, and short text. The column needs a
+// FULLTEXT index.
+func fullTextSearch(e *Executor, name string, args []storage.Value) (*storage.Table, error) {
+	if len(args) != 3 {
+		return nil, errorf(ErrParameter, "fulltext_search takes 3 arguments, the table, the column and the query, got %d", len(args))
+	}
+	for _, arg := range args {
+		if arg.Type() != storage.TypeText {
+			return nil, errorf(ErrParameter, "fulltext_search arguments must be strings, got %s", arg.ToString())
+		}
+	}
+	tableName, column, query := args[0].ToString(), args[1].ToString(), args[2].ToString()
+	source, err := e.lookupTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	var index *storage.SecondaryIndex
+	for _, idx := range source.SecondaryIndexes() {
+		if idx.FullText && idx.Column == column {
+			index = idx
+			break
+		}
+	}
+	if index == nil {
+		return nil, errorf(ErrUnsupported, "%s.%s has no full-text index; create one with CREATE FULLTEXT INDEX ON %s (%s)", tableName, column, tableName, column)
+	}
+
+	type match struct {
+		values []storage.Value
+		rank   float64
+	}
+	var matches []match
+	source.ScanFullText(index.Name, storage.FullTextTerms(query), func(row *storage.Row, rank float64) bool {
+		values := make([]storage.Value, len(row.Values), len(row.Values)+1)
+		copy(values, row.Values)
+		matches = append(matches, match{values, rank})
+		return true
+	})
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].rank > matches[j].rank })
+
+	columns := make([]*storage.Column, 0, len(source.Schema().Columns)+1)
+	for _, col := range source.Schema().Columns {
+		columns = append(columns, storage.NewColumn(col.Name, col.Type, false, false, false))
+	}
+	columns = append(columns, storage.NewColumn("rank", storage.TypeFloat, false, false, true))
+	table := newSystemTable(name, columns)
+	for _, m := range matches {
+		if err := e.addFunctionRow(table, append(m.values, storage.NewFloatValue(m.rank))); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
@@ -0,0 +1,137 @@
+package sql_test
+
+import (
+	"fmt"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Fuzz targets for the lexer, parser and executor. Without -fuzz, go test
+// runs only the seeds; to search for crashes run, for example:
+//
+//	go test ./internal/sql -run '^$' -fuzz FuzzExecute -fuzztime 1m
+//
+// Any panic fails, and so does an input that takes longer than
+// fuzzTimeout, which catches parser loops that stop consuming tokens.
+
+const fuzzTimeout = 5 * time.Second
+
+var fuzzSchema = []string{
+	"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT UNIQUE, active BOOLEAN)",
+	"CREATE TABLE tasks (id INTEGER PRIMARY KEY, title TEXT, points FLOAT DEFAULT 1.5, user_id INTEGER)",
+	"INSERT INTO users (id, name, email) VALUES (1, 'Ann', 'ann@example.com'), (2, 'Bob', NULL)",
+	"INSERT INTO tasks (id, title, user_id) VALUES (1, 'a', 1), (2, 'b', 2), (3, 'c', NULL)",
+}
+
+var fuzzSeeds = []string{
+	"",
+	";",
+	"SELECT",
+	"SELECT * FROM users",
+	"SELECT id, name FROM users WHERE id = 1 AND name != 'x' OR NOT active ORDER BY name DESC LIMIT 1 OFFSET 1",
+	"SELECT u.name, COUNT(t.id) FROM users u LEFT JOIN tasks t ON t.user_id = u.id GROUP BY u.name ORDER BY COUNT(t.id) DESC",
+	"SELECT COUNT(*) FROM tasks WHERE title LIKE 'a%' OR title NOT ILIKE '_B'",
+	"SELECT * FROM users, tasks WHERE users.id = tasks.user_id",
+	"SELECT id FROM users WHERE id = ? AND name = $2",
+	"INSERT INTO users (id, name) VALUES (3, 'Cy'), (4, 'Dee')",
+	"INSERT INTO tasks VALUES (9, 'x', 2.5, 1)",
+	"UPDATE tasks SET points = points * 2 + 1, title = 'y' WHERE id >= 2",
+	"DELETE FROM users WHERE email = NULL",
+	"CREATE TABLE t (a INTEGER PRIMARY KEY, b TEXT UNIQUE NOT NULL DEFAULT 'x', c BOOLEAN)",
+	"CREATE TABLE t (a INTEGER PRIMARY, b TEXT NOT, c SELECT)",
+	"DROP TABLE tasks",
+	"BEGIN; INSERT INTO users (id, name) VALUES (5, 'E'); ROLLBACK; BEGIN TRANSACTION; COMMIT",
+	"LISTEN changes; NOTIFY changes, 'hi'; UNLISTEN *",
+	"SET statement_timeout = 10; SHOW ALL",
+	"EXPLAIN (FORMAT DOT) SELECT u.name FROM users u JOIN tasks t ON t.user_id = u.id WHERE u.id > 1",
+	"CREATE USER app WITH PASSWORD 'pw'; DROP USER app",
+	"SELECT 'unterminated",
+	"-- only a comment",
+	"SELECT /*+ HASH_JOIN(t) NO_INDEX(u) */ u.name FROM users u /* c */ JOIN tasks t ON t.user_id = u.id",
+	"SELECT /*+ USE_INDEX(users, email */ 1 /* unterminated",
+	"DELETE FROM tasks WHERE user_id NOT IN (SELECT id FROM users WHERE id IN (SELECT user_id FROM tasks))",
+	"SELECT id FROM users UNION ALL SELECT user_id FROM tasks INTERSECT SELECT id FROM users EXCEPT SELECT 1 ORDER BY id LIMIT 2",
+	"SELECT id FROM tasks WHERE user_id <=> NULL OR user_id IS NOT DISTINCT FROM 1 AND user_id IS DISTINCT FROM <=>",
+	"SELECT price * -quantity, UPPER(name), SUM(price) / COUNT(*) + 1, CAST(id AS TEXT) FROM orders GROUP BY name",
+	"SELECT (((((1",
+	"INSERT INTO users VALUES ((1, 2), ), (,",
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		within(t, input, func() {
+			sql.NewParser(sql.NewLexer(input)).Parse()
+			stmts, _ := sql.NewParser(sql.NewLexer(input)).ParseAll()
+			for _, stmt := range stmts {
+				sql.Format(stmt)
+			}
+		})
+	})
+}
+
+func FuzzExecute(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		session := sql.NewSession(fuzzDatabase(t))
+		defer session.Close()
+
+		within(t, input, func() {
+			stmts, _ := sql.NewParser(sql.NewLexer(input)).ParseAll()
+			for _, stmt := range stmts {
+				if _, ok := stmt.(*sql.BackupStatement); ok {
+					continue // writes to the file system
+				}
+				session.Execute(stmt)
+			}
+		})
+	})
+}
+
+func fuzzDatabase(t *testing.T) *storage.Database {
+	db := storage.NewDatabase()
+	session := sql.NewSession(db)
+	defer session.Close()
+	for _, text := range fuzzSchema {
+		stmt, err := sql.NewParser(sql.NewLexer(text)).Parse()
+		if err == nil {
+			_, err = session.Execute(stmt)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	return db
+}
+
+// within runs fn and fails the test if it panics or does not return
+// within fuzzTimeout.
+func within(t *testing.T, input string, fn func()) {
+	done := make(chan string, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Sprintf("panic: %v\n%s", r, debug.Stack())
+			}
+		}()
+		fn()
+		done <- ""
+	}()
+
+	select {
+	case failure := <-done:
+		if failure != "" {
+			t.Fatalf("input %q: %s", input, failure)
+		}
+	case <-time.After(fuzzTimeout):
+		t.Fatalf("input %q: no result after %v", input, fuzzTimeout)
+	}
+}
@@ -0,0 +1,316 @@
+package sql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Optimizer hints override the planner's choices for one SELECT. They are
+// written in a comment right after SELECT:
+//
+//	SELECT /*+ USE_INDEX(users users_email) HASH_JOIN */ ...
+//
+// USE_INDEX(table index...) limits the indexes the scan of table may use
+// to those named, by index name or by column; NO_INDEX(table) makes it a
+// full scan. Only the first table in FROM is read through an index today,
+// so on the others these hints change nothing. HASH_JOIN, or
+// HASH_JOIN(table...) for the joins of the tables named, builds a hash
+// table on the joined table's side of an equality in the ON clause
+// (a.x = b.y, with both columns qualified) instead of reading every row
+// of it for each outer row. The result is the same either way.
+
+// Hint is one optimizer hint. Name is upper case; Args are the table and
+// index names it takes.
+type Hint struct {
+	Name string
+	Args []string
+	Pos  Position
+}
+
+func (h Hint) String() string {
+	if len(h.Args) == 0 {
+		return h.Name
+	}
+	return h.Name + "(" + strings.Join(h.Args, " ") + ")"
+}
+
+// hintArgs is the number of arguments each hint takes, at least and at
+// most (-1 for no limit).
+var hintArgs = map[string][2]int{
+	"USE_INDEX": {2, -1},
+	"NO_INDEX":  {1, 1},
+	"HASH_JOIN": {0, -1},
+}
+
+// parseHints parses the text of a /*+ ... */ comment: hints separated by
+// spaces, each a name with, for some, arguments in parentheses separated
+// by spaces or commas.
+func parseHints(tok Token) ([]Hint, error) {
+	tokens, _ := NewLexer(tok.Value).Tokenize()
+	word := func(t Token) bool { return t.Type == TokenIdentifier || t.Type == TokenKeyword }
+	fail := func(message, suggestion string) ([]Hint, error) {
+		return nil, NewParseError(message, tok, suggestion)
+	}
+
+	var hints []Hint
+	for i := 0; i < len(tokens); {
+		if !word(tokens[i]) {
+			return fail(fmt.Sprintf("expected hint name, got '%s'", tokens[i].Value), "write hints as NAME or NAME(arguments)")
+		}
+		hint := Hint{Name: strings.ToUpper(tokens[i].Value), Pos: tok.Position}
+		limits, ok := hintArgs[hint.Name]
+		if !ok {
+			return fail("unknown hint "+tokens[i].Value, "use USE_INDEX, NO_INDEX or HASH_JOIN")
+		}
+		i++
+		if i < len(tokens) && tokens[i].Value == "(" {
+			for i++; i < len(tokens) && tokens[i].Value != ")"; i++ {
+				if tokens[i].Value == "," {
+					continue
+				}
+				if !word(tokens[i]) {
+					return fail(fmt.Sprintf("expected table or index name in %s, got '%s'", hint.Name, tokens[i].Value), "")
+				}
+				hint.Args = append(hint.Args, tokens[i].Value)
+			}
+			if i == len(tokens) {
+				return fail("expected ')' to close hint "+hint.Name, "add ')'")
+			}
+			i++
+		}
+		if len(hint.Args) < limits[0] || limits[1] >= 0 && len(hint.Args) > limits[1] {
+			usage := map[string]string{"USE_INDEX": "USE_INDEX(table index)", "NO_INDEX": "NO_INDEX(table)"}
+			return fail(fmt.Sprintf("wrong number of arguments to hint %s", hint.Name), "write "+usage[hint.Name])
+		}
+		hints = append(hints, hint)
+	}
+	return hints, nil
+}
+
+// useHints checks that stmt's hints name its tables and their indexes and
+// makes them the executor's until the returned function is called.
+func (e *Executor) useHints(stmt *SelectStatement) (func(), error) {
+	refs := make(map[string]TableRef)
+	for _, ref := range stmt.Tables {
+		refs[ref.RefName()] = ref
+	}
+	joined := make(map[string]bool)
+	for _, join := range stmt.Joins {
+		refs[join.Ref().RefName()] = join.Ref()
+		joined[join.Ref().RefName()] = true
+	}
+
+	for _, hint := range stmt.Hints {
+		if hint.Name == "HASH_JOIN" {
+			for _, name := range hint.Args {
+				if !joined[name] {
+					err := errorf(ErrParameter, "hint %s names %s, which the query does not join", hint, name)
+					return nil, positioned(err, hint.Pos, hint.String(), "name a table that follows JOIN")
+				}
+			}
+			continue
+		}
+		ref, ok := refs[hint.Args[0]]
+		if !ok {
+			err := errorf(ErrParameter, "hint %s names %s, which the query does not read", hint, hint.Args[0])
+			return nil, positioned(err, hint.Pos, hint.String(), "use the table's name or alias in the query")
+		}
+		if hint.Name != "USE_INDEX" {
+			continue
+		}
+		table, err := e.lookupTable(ref.Name)
+		if ref.Function != nil || err != nil {
+			err := errorf(ErrParameter, "hint %s: %s has no indexes", hint, hint.Args[0])
+			return nil, positioned(err, hint.Pos, hint.String(), "")
+		}
+		for _, index := range hint.Args[1:] {
+			if !hasIndexNamed(table, index) {
+				err := errorf(ErrParameter, "hint %s: table %s has no index %s", hint, ref.Name, index)
+				return nil, positioned(err, hint.Pos, hint.String(), "name a secondary index or an indexed column")
+			}
+		}
+	}
+
+	saved := e.hints
+	e.hints = stmt.Hints
+	return func() { e.hints = saved }, nil
+}
+
+// hasIndexNamed reports whether table has a secondary index called name or
+// an index on a column called name.
+func hasIndexNamed(table *storage.Table, name string) bool {
+	if table.HasIndex(name) {
+		return true
+	}
+	for _, idx := range table.SecondaryIndexes() {
+		if idx.Name == name || idx.Column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// indexAllowed reports whether the hints in force let the scan of the
+// table known in the query as name use index, the secondary index on
+// column or, with index empty, column's own index.
+func (e *Executor) indexAllowed(name, index, column string) bool {
+	allowed := true
+	for _, hint := range e.hints {
+		if len(hint.Args) == 0 || hint.Args[0] != name {
+			continue
+		}
+		switch hint.Name {
+		case "NO_INDEX":
+			return false
+		case "USE_INDEX":
+			allowed = false
+			for _, arg := range hint.Args[1:] {
+				if arg == column || index != "" && arg == index {
+					return true
+				}
+			}
+		}
+	}
+	return allowed
+}
+
+// hashJoinKeys returns the sides of an equality in join's conditions that
+// a hash join can use, when stmt's hints ask for one: inner reads only the
+// joined table, outer only the tables before it. Every column in them
+// must be qualified with its table.
+func hashJoinKeys(stmt *SelectStatement, join *JoinClause) (outer, inner Expression, ok bool) {
+	name := join.Ref().RefName()
+	hinted := false
+	for _, hint := range stmt.Hints {
+		if hint.Name != "HASH_JOIN" {
+			continue
+		}
+		hinted = len(hint.Args) == 0
+		for _, arg := range hint.Args {
+			hinted = hinted || arg == name
+		}
+		if hinted {
+			break
+		}
+	}
+	if !hinted {
+		return nil, nil, false
+	}
+
+	only := func(expr Expression, inner bool) bool {
+		tables, ok := qualifiedTables(expr, nil)
+		if !ok || len(tables) == 0 {
+			return false
+		}
+		for _, table := range tables {
+			if (table == name) != inner {
+				return false
+			}
+		}
+		return true
+	}
+	for _, cond := range join.Conditions {
+		for _, conj := range splitAnd(cond, nil) {
+			eq, isEq := conj.(*BinaryExpression)
+			if !isEq || eq.Op != "=" && eq.Op != "==" && eq.Op != "<=>" && eq.Op != "IS NOT DISTINCT FROM" {
+				continue
+			}
+			switch {
+			case only(eq.Left, false) && only(eq.Right, true):
+				return eq.Left, eq.Right, true
+			case only(eq.Left, true) && only(eq.Right, false):
+				return eq.Right, eq.Left, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// qualifiedTables appends to tables the table qualifiers of the columns
+// expr reads. It reports false if a column is unqualified or expr is not
+// made of columns, constants, operators, casts and function calls.
+func qualifiedTables(expr Expression, tables []string) ([]string, bool) {
+	ok := true
+	Inspect(expr, func(expr Expression) bool {
+		switch expr := expr.(type) {
+		case *ColumnRef:
+			ok = ok && expr.Table != ""
+			tables = append(tables, expr.Table)
+		case *BinaryExpression, *UnaryExpression, *CastExpression, *FunctionCall:
+			return true
+		case *LiteralExpression, *NullLiteral, *Parameter:
+		default:
+			ok = false
+		}
+		return false
+	})
+	return tables, ok
+}
+
+// joinCandidates returns a function giving, for a row of the join's outer
+// input, the rows of rows (the joined table's, at offset in the combined
+// row) that may match it: all of them, or with a hash join only those
+// whose key equals the outer row's. The join's conditions are still
+// tested on each.
+func (e *Executor) joinCandidates(stmt *SelectStatement, join *JoinClause, rows []*storage.Row, offset int,
+	tableMap map[string]*storage.Table, offsetMap map[string]int) (func(*storage.Row) ([]*storage.Row, error), error) {
+	all := func(*storage.Row) ([]*storage.Row, error) { return rows, nil }
+	outer, inner, ok := hashJoinKeys(stmt, join)
+	if !ok {
+		return all, nil
+	}
+
+	buckets := make(map[string][]*storage.Row)
+	for _, row := range rows {
+		values := make([]storage.Value, offset+len(row.Values))
+		for i := 0; i < offset; i++ {
+			values[i] = storage.NullValue{}
+		}
+		copy(values[offset:], row.Values)
+		v, err := e.evaluateExpressionForJoinedRow(inner, storage.NewRow(values), tableMap, offsetMap)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := hashKey(v)
+		if !ok {
+			return all, nil
+		}
+		buckets[key] = append(buckets[key], row)
+	}
+	e.traceStep("hash", "table", join.Ref().String(), "key", inner.String(), "buckets", len(buckets))
+
+	return func(row *storage.Row) ([]*storage.Row, error) {
+		v, err := e.evaluateExpressionForJoinedRow(outer, row, tableMap, offsetMap)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := hashKey(v)
+		if !ok {
+			return rows, nil
+		}
+		return buckets[key], nil
+	}, nil
+}
+
+// hashKey returns a string equal for two values exactly when Equals holds
+// between them. It reports false for intervals, which compare by their
+// approximate length.
+func hashKey(v storage.Value) (string, bool) {
+	switch v := v.(type) {
+	case *storage.IntervalValue:
+		return "", false
+	case *storage.FloatValue:
+		if v.Value == 0 {
+			return "float:0", true // -0 equals 0
+		}
+		if math.IsNaN(v.Value) {
+			return "", false
+		}
+		return "float:" + strconv.FormatFloat(v.Value, 'g', -1, 64), true
+	}
+	return fmt.Sprintf("%T:%s", v, v.ToString()), true
+}
@@ -0,0 +1,83 @@
+package sql_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+func TestAsOf(t *testing.T) {
+	db := storage.NewDatabase()
+	session := sql.NewSession(db)
+	defer session.Close()
+	run := func(text string) *sql.Result {
+		t.Helper()
+		result, err := execSQL(session, text)
+		if err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		return result
+	}
+	// Commits in the same clock tick cannot be told apart.
+	mark := func() time.Time {
+		time.Sleep(2 * time.Millisecond)
+		at := time.Now()
+		time.Sleep(2 * time.Millisecond)
+		return at
+	}
+	asOf := func(query string, at time.Time) string {
+		return fmt.Sprintf("%s AS OF TIMESTAMP '%s'", query, at.Format(time.RFC3339Nano))
+	}
+
+	beforeCreate := mark()
+	run("CREATE TABLE accounts (id INTEGER PRIMARY KEY, balance INTEGER)")
+	run("INSERT INTO accounts (id, balance) VALUES (1, 100), (2, 50)")
+	opened := mark()
+	run("BEGIN")
+	run("UPDATE accounts SET balance = 70 WHERE id = 1")
+	run("DELETE FROM accounts WHERE id = 2")
+	run("INSERT INTO accounts (id, balance) VALUES (3, 10)")
+	run("COMMIT")
+	moved := mark()
+	run("UPDATE accounts SET balance = 0 WHERE id = 3")
+
+	tests := []struct {
+		at   time.Time
+		want [][]string
+	}{
+		{opened, [][]string{{"1", "100"}, {"2", "50"}}},
+		{moved, [][]string{{"1", "70"}, {"3", "10"}}},
+		{time.Now(), [][]string{{"1", "70"}, {"3", "0"}}},
+	}
+	for _, tt := range tests {
+		query := asOf("SELECT id, balance FROM accounts", tt.at) + " ORDER BY id"
+		if got := run(query).Rows; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", query, got, tt.want)
+		}
+	}
+
+	joined := asOf("SELECT a.balance, b.balance FROM accounts a JOIN accounts b ON a.id = b.id", opened) + " WHERE a.id = 2"
+	if got := run(joined).Rows; !reflect.DeepEqual(got, [][]string{{"50", "50"}}) {
+		t.Errorf("%s: got %v", joined, got)
+	}
+
+	if _, err := execSQL(session, asOf("SELECT * FROM accounts", beforeCreate)); !errors.Is(err, sql.ErrTableNotFound) {
+		t.Errorf("before CREATE TABLE: got %v, want an error of kind %q", err, sql.ErrTableNotFound)
+	}
+
+	db.SetHistoryRetention(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	run("INSERT INTO accounts (id, balance) VALUES (4, 1)")
+	_, err := execSQL(session, asOf("SELECT * FROM accounts", opened))
+	if !errors.Is(err, sql.ErrHistoryUnavailable) {
+		t.Fatalf("after pruning: got %v, want an error of kind %q", err, sql.ErrHistoryUnavailable)
+	}
+	if got := sql.SQLState(err); got != "72000" {
+		t.Errorf("SQLSTATE %s, want 72000", got)
+	}
+}
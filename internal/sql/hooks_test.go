@@ -0,0 +1,76 @@
+package sql_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+func TestHooks(t *testing.T) {
+	db := storage.NewDatabase()
+	var created []string
+	var commits [][]storage.ChangeEvent
+	var failed []string
+	db.SetHooks(storage.Hooks{
+		OnCreateTable: func(name string, schema *storage.Schema) error {
+			if strings.HasPrefix(name, "tmp_") {
+				return errors.New("tmp_ tables are not allowed")
+			}
+			created = append(created, name)
+			return nil
+		},
+		OnInsert: func(table string, row map[string]storage.Value) error {
+			if qty, ok := row["qty"].(*storage.IntegerValue); ok && qty.Value > 100 {
+				return errors.New("quantity over 100")
+			}
+			return nil
+		},
+		OnCommit: func(events []storage.ChangeEvent) {
+			commits = append(commits, events)
+		},
+		OnError: func(statement string, err error) {
+			failed = append(failed, statement)
+		},
+	})
+	session := sql.NewSession(db)
+	defer session.Close()
+
+	for _, text := range []string{
+		"CREATE TABLE stock (id INTEGER PRIMARY KEY, qty INTEGER)",
+		"BEGIN",
+		"INSERT INTO stock (id, qty) VALUES (1, 5)",
+		"INSERT INTO stock (id, qty) VALUES (2, 50)",
+		"COMMIT",
+		"SELECT id FROM stock",
+	} {
+		if _, err := execSQL(session, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	if _, err := execSQL(session, "CREATE TABLE tmp_x (id INTEGER)"); err == nil {
+		t.Error("OnCreateTable did not stop CREATE TABLE")
+	}
+	if _, err := execSQL(session, "INSERT INTO stock (id, qty) VALUES (3, 1), (4, 1000)"); err == nil {
+		t.Error("OnInsert did not stop INSERT")
+	}
+
+	if len(created) != 1 || created[0] != "stock" {
+		t.Errorf("created = %v, want [stock]", created)
+	}
+	if len(commits) != 2 || len(commits[1]) != 2 || commits[1][0].After["qty"].ToString() != "5" {
+		t.Errorf("commits = %+v, want the CREATE TABLE and both inserts", commits)
+	}
+	if len(failed) != 2 || !strings.HasPrefix(failed[1], "INSERT INTO stock") {
+		t.Errorf("failed = %q, want the CREATE TABLE and INSERT", failed)
+	}
+	result, err := execSQL(session, "SELECT id FROM stock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 2 {
+		t.Errorf("rows = %v, want the rejected INSERT undone", result.Rows)
+	}
+}
@@ -0,0 +1,60 @@
+package sql
+
+import (
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// evaluateIn evaluates expr, reading its operands with eval so that each
+// evaluator resolves columns its own way. A value is in the list when it
+// equals one of its values as = compares them. As in standard SQL a NULL
+// is in no list, and NOT IN is false, not true, for a list holding a
+// NULL that the value does not equal. A subquery gives the list from its
+// rows (see subquery.go).
+func (e *Executor) evaluateIn(expr *InExpression, eval func(Expression) (storage.Value, error)) (storage.Value, error) {
+	left, err := eval(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	found, hasNull := false, false
+	if expr.Subquery != nil {
+		set, err := e.subquerySet(expr)
+		if err != nil {
+			return nil, err
+		}
+		found, hasNull = !isNull(left) && set.contains(left), set.hasNull
+	}
+	for _, item := range expr.Values {
+		v, err := eval(item)
+		if err != nil {
+			return nil, err
+		}
+		if isNull(v) {
+			hasNull = true
+			continue
+		}
+		if !found && !isNull(left) && left.Equals(v) {
+			found = true
+		}
+	}
+	if isNull(left) {
+		return storage.NewBooleanValue(false), nil
+	}
+	if expr.Not {
+		return storage.NewBooleanValue(!found && !hasNull), nil
+	}
+	return storage.NewBooleanValue(found), nil
+}
+
+// notDistinct reports whether a and b are equal, two NULLs being equal
+// and a NULL differing from every other value: a <=> b, or a IS NOT
+// DISTINCT FROM b.
+func notDistinct(a, b storage.Value) bool {
+	if isNull(a) || isNull(b) {
+		return isNull(a) && isNull(b)
+	}
+	return a.Equals(b)
+}
+
+func isNull(v storage.Value) bool {
+	return v == nil || v.Type() == storage.TypeNull
+}
@@ -0,0 +1,387 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// executeCreateIndex adds a secondary index (see storage.SecondaryIndex).
+// A partial index's condition is evaluated on each row as it is inserted
+// or updated, so it may only read the row's columns and constants. CREATE
+// INDEX CONCURRENTLY builds it without blocking writes (see
+// storage.Database.CreateIndexConcurrently).
+func (e *Executor) executeCreateIndex(stmt *CreateIndexStatement) (*Result, error) {
+	if _, ok := e.db.ExternalTable(stmt.Table); ok {
+		err := errorf(ErrUnsupported, "cannot index external table %s", stmt.Table)
+		return nil, positioned(err, stmt.TablePos, stmt.Table, "")
+	}
+	db, name := e.db, stmt.Table
+	if table, temp := e.temporary(stmt.Table); temp {
+		db, name = e.temporaryTables(), table
+	}
+	table, err := db.GetTable(name)
+	if err != nil {
+		return nil, positioned(err, stmt.TablePos, stmt.Table, "")
+	}
+
+	idx := &storage.SecondaryIndex{Name: stmt.Name, Table: name, Column: stmt.Column, Include: stmt.Include,
+		FullText: stmt.FullText, Trigram: stmt.Trigram}
+	var matches func(*storage.Row) bool
+	if stmt.Where != nil {
+		if err := checkIndexCondition(stmt.Where); err != nil {
+			return nil, err
+		}
+		// Evaluating the condition on a row of NULLs finds unknown
+		// columns before any row is indexed.
+		nulls := make([]storage.Value, len(table.Schema().Columns))
+		for i := range nulls {
+			nulls[i] = storage.NullValue{}
+		}
+		if _, err := e.evaluateExpressionForRow(stmt.Where, table, storage.NewRow(nulls)); err != nil {
+			return nil, err
+		}
+		matches = indexMatcher(e.db, stmt.Where, table)
+		idx.Predicate = sqlText(stmt.Where)
+		idx.Condition = stmt.Where
+	}
+
+	create := db.CreateIndex
+	if stmt.Concurrently {
+		create = db.CreateIndexConcurrently
+	}
+	if e.tx != nil && db == e.db {
+		create = e.tx.CreateIndex
+		if stmt.Concurrently {
+			create = e.tx.CreateIndexConcurrently
+		}
+	}
+	if err := create(idx, matches); err != nil {
+		if errors.Is(err, ErrTableExists) && e.onConflict != storage.ConflictAbort {
+			return &Result{Message: fmt.Sprintf("Index %s already exists, kept", stmt.Name)}, nil
+		}
+		return nil, err
+	}
+	return &Result{Message: fmt.Sprintf("Index %s created", stmt.Name)}, nil
+}
+
+// indexMatcher returns the function that tells whether a row of table
+// belongs in a partial index with the condition where.
+func indexMatcher(db *storage.Database, where Expression, table *storage.Table) func(*storage.Row) bool {
+	eval := NewExecutor(db)
+	return func(row *storage.Row) bool {
+		v, err := eval.evaluateExpressionForRow(where, table, row)
+		return err == nil && eval.getValueAsBool(v)
+	}
+}
+
+// A partial index is logged with the text of its condition, which storage
+// has parsed again here when it replays the index.
+func init() {
+	storage.SetConditionParser(parseIndexCondition)
+}
+
+// parseIndexCondition parses predicate, the condition of a partial index
+// of table as CREATE INDEX stored it.
+func parseIndexCondition(db *storage.Database, table *storage.Table, predicate string) (interface{}, func(*storage.Row) bool, error) {
+	p := NewParser(NewLexer(predicate))
+	where, err := p.parseExpression()
+	if err != nil {
+		return nil, nil, err
+	}
+	if tok := p.currentToken(); tok.Type != TokenEOF {
+		return nil, nil, fmt.Errorf("unexpected %s after index condition %s", tok.Value, predicate)
+	}
+	return where, indexMatcher(db, where, table), nil
+}
+
+// checkIndexCondition rejects the parts of a partial index's condition
+// whose value is not fixed by the row: parameters, system functions and
+// subqueries.
+func checkIndexCondition(expr Expression) error {
+	switch expr := expr.(type) {
+	case *BinaryExpression:
+		if err := checkIndexCondition(expr.Left); err != nil {
+			return err
+		}
+		return checkIndexCondition(expr.Right)
+	case *UnaryExpression:
+		return checkIndexCondition(expr.Right)
+	case *InExpression:
+		if expr.Subquery != nil {
+			return errorf(ErrUnsupported, "index condition cannot use a subquery; use the row's columns and constants")
+		}
+		for _, operand := range append([]Expression{expr.Left}, expr.Values...) {
+			if err := checkIndexCondition(operand); err != nil {
+				return err
+			}
+		}
+	case *Parameter, *SystemFunction:
+		return errorf(ErrUnsupported, "index condition cannot use %s; use the row's columns and constants", expr)
+	}
+	return nil
+}
+
+// secondaryIndexRange picks a secondary index of table (known in the query
+// as name) to read the rows where can match from. An index serves an
+// equality between its column and a constant in where's ANDs, reading
+// only that key; a partial index serves a where that includes its
+// condition, as one of the ANDs, even without one. The condition must be
+// written as in CREATE INDEX, though columns may be qualified with name.
+// start and end are the keys to read, nil when the whole index is read.
+// where is still applied to the rows read.
+func (e *Executor) secondaryIndexRange(where Expression, table *storage.Table, name string, joined bool) (*storage.SecondaryIndex, storage.Value, storage.Value, bool) {
+	if where == nil || joined {
+		return nil, nil, nil, false
+	}
+	conjuncts := splitAnd(where, nil)
+	var partial *storage.SecondaryIndex
+	for _, idx := range table.SecondaryIndexes() {
+		if idx.Kind() != "" || !e.indexAllowed(name, idx.Name, idx.Column) {
+			continue
+		}
+		if idx.Partial() && !impliesCondition(conjuncts, idx.Condition.(Expression), name) {
+			continue
+		}
+		if key, ok := e.equalityKey(conjuncts, table, idx.Column, name); ok {
+			return idx, key, key, true
+		}
+		if idx.Partial() && partial == nil {
+			partial = idx
+		}
+	}
+	if partial != nil {
+		return partial, nil, nil, true
+	}
+	return nil, nil, nil, false
+}
+
+// recordFullScan notes, for the index advisor, the columns that where
+// compares with a constant when it filters a full scan of table, known in
+// the query as name (see storage.Database.IndexAdvice). Scans of system,
+// external, temporary, attached and past tables are not noted.
+func (e *Executor) recordFullScan(where Expression, table *storage.Table, name string) {
+	if t, err := e.db.GetTable(table.Name); err != nil || t != table {
+		return
+	}
+	conjuncts := splitAnd(where, nil)
+	var columns []string
+	for _, col := range table.Schema().Columns {
+		if _, ok := e.equalityKey(conjuncts, table, col.Name, name); ok {
+			columns = append(columns, col.Name)
+		}
+	}
+	if len(columns) > 0 {
+		e.db.RecordFullScan(table.Name, columns, table.Count())
+	}
+}
+
+// splitAnd appends the operands of where's top-level ANDs to conjuncts.
+func splitAnd(where Expression, conjuncts []Expression) []Expression {
+	if expr, ok := where.(*BinaryExpression); ok && expr.Op == "AND" {
+		return splitAnd(expr.Right, splitAnd(expr.Left, conjuncts))
+	}
+	return append(conjuncts, where)
+}
+
+// impliesCondition reports whether one of conjuncts is condition, the
+// condition of a partial index.
+func impliesCondition(conjuncts []Expression, condition Expression, name string) bool {
+	for _, conjunct := range conjuncts {
+		if sameCondition(conjunct, condition, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameCondition reports whether a, from a query reading the table as name,
+// is the same expression as b, from CREATE INDEX on it.
+func sameCondition(a, b Expression, name string) bool {
+	switch a := a.(type) {
+	case *BinaryExpression:
+		b, ok := b.(*BinaryExpression)
+		return ok && a.Op == b.Op && sameCondition(a.Left, b.Left, name) && sameCondition(a.Right, b.Right, name)
+	case *UnaryExpression:
+		b, ok := b.(*UnaryExpression)
+		return ok && a.Op == b.Op && sameCondition(a.Right, b.Right, name)
+	case *InExpression:
+		b, ok := b.(*InExpression)
+		if !ok || a.Subquery != nil || a.Not != b.Not || len(a.Values) != len(b.Values) || !sameCondition(a.Left, b.Left, name) {
+			return false
+		}
+		for i := range a.Values {
+			if !sameCondition(a.Values[i], b.Values[i], name) {
+				return false
+			}
+		}
+		return true
+	case *ColumnRef:
+		b, ok := b.(*ColumnRef)
+		return ok && a.Column == b.Column && (a.Table == "" || a.Table == name)
+	case *LiteralExpression:
+		b, ok := b.(*LiteralExpression)
+		return ok && a.Value == b.Value
+	case *NullLiteral:
+		_, ok := b.(*NullLiteral)
+		return ok
+	}
+	return false
+}
+
+// equalityKey finds column = constant (or constant = column) among
+// conjuncts and returns the constant, when it has the column's type.
+func (e *Executor) equalityKey(conjuncts []Expression, table *storage.Table, column, name string) (storage.Value, bool) {
+	col, ok := table.Schema().GetColumn(column)
+	if !ok {
+		return nil, false
+	}
+	for _, conjunct := range conjuncts {
+		expr, ok := conjunct.(*BinaryExpression)
+		if !ok || expr.Op != "=" && expr.Op != "==" && expr.Op != "<=>" && expr.Op != "IS NOT DISTINCT FROM" {
+			continue
+		}
+		for _, sides := range [][2]Expression{{expr.Left, expr.Right}, {expr.Right, expr.Left}} {
+			ref, ok := sides[0].(*ColumnRef)
+			if !ok || ref.Column != column || ref.Table != "" && ref.Table != name {
+				continue
+			}
+			var key storage.Value
+			var err error
+			switch constant := sides[1].(type) {
+			case *LiteralExpression:
+				key, err = constant.parseLiteral()
+			case *Parameter:
+				key, err = e.paramValue(constant)
+			default:
+				continue
+			}
+			if err == nil && key.Type() == col.Type {
+				return key, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// scanOptions returns the options of a full scan of table, known in the
+// query as name, for a single-table SELECT: its col = constant conditions,
+// which rows must meet before the WHERE is evaluated, and the columns it
+// reads, or every column when queryColumns cannot tell.
+func (e *Executor) scanOptions(stmt *SelectStatement, table *storage.Table, name string) storage.ScanOptions {
+	var opts storage.ScanOptions
+	if stmt.Where != nil {
+		conjuncts := splitAnd(stmt.Where, nil)
+		for i, col := range table.Schema().Columns {
+			if key, ok := e.equalityKey(conjuncts, table, col.Name, name); ok {
+				opts.Where = append(opts.Where, storage.Predicate{Column: i, Op: storage.OpEqual, Value: key})
+			}
+		}
+	}
+	if columns, ok := queryColumns(stmt, table, name); ok {
+		opts.Columns = make([]int, 0, len(columns))
+		for _, column := range columns {
+			opts.Columns = append(opts.Columns, table.Schema().ColumnIndex(column))
+		}
+	}
+	return opts
+}
+
+// queryColumns returns the columns of table, known in the query as name,
+// that a single-table SELECT reads. It reports false when it cannot tell,
+// so that the query is not answered from an index that lacks a column.
+func queryColumns(stmt *SelectStatement, table *storage.Table, name string) ([]string, bool) {
+	var columns []string
+	add := func(ref string) bool {
+		if i := strings.LastIndex(ref, "."); i >= 0 {
+			if ref[:i] != name {
+				return false
+			}
+			ref = ref[i+1:]
+		}
+		if ref == "*" {
+			columns = append(columns, table.Schema().ColumnNames()...)
+			return true
+		}
+		if _, ok := table.Schema().GetColumn(ref); !ok {
+			return false
+		}
+		columns = append(columns, ref)
+		return true
+	}
+	var walk func(expr Expression) bool
+	walk = func(expr Expression) bool {
+		switch expr := expr.(type) {
+		case nil:
+			return true
+		case *BinaryExpression:
+			return walk(expr.Left) && walk(expr.Right)
+		case *UnaryExpression:
+			return walk(expr.Right)
+		case *CastExpression:
+			return walk(expr.Expr)
+		case *FunctionCall:
+			for _, arg := range expr.Arguments {
+				if !walk(arg) {
+					return false
+				}
+			}
+			return walk(expr.Filter)
+		case *InExpression:
+			for _, operand := range append([]Expression{expr.Left}, expr.Values...) {
+				if !walk(operand) {
+					return false
+				}
+			}
+			return true
+		case *ColumnRef:
+			return add(expr.String())
+		case *LiteralExpression, *NullLiteral, *IntervalLiteral, *Parameter, *SystemFunction:
+			return true
+		}
+		return false
+	}
+
+	aggregates := make(map[string]bool)
+	for _, call := range stmt.Aggregates {
+		aggregates[call.String()] = true
+		for _, arg := range call.Arguments {
+			if ref, ok := arg.(*ColumnRef); ok && ref.Column == "*" {
+				continue
+			}
+			if !walk(arg) {
+				return nil, false
+			}
+		}
+		if !walk(call.Filter) {
+			return nil, false
+		}
+	}
+	for _, col := range stmt.Columns {
+		if col.Expr == nil {
+			if !add(col.Name) {
+				return nil, false
+			}
+			continue
+		}
+		if call, ok := col.Expr.(*FunctionCall); ok && aggregates[call.String()] {
+			continue
+		}
+		if !walk(col.Expr) {
+			return nil, false
+		}
+	}
+	for _, col := range stmt.GroupBy {
+		if !add(col) {
+			return nil, false
+		}
+	}
+	for _, order := range stmt.OrderBy {
+		if !aggregates[order.Column] && !add(order.Column) {
+			return nil, false
+		}
+	}
+	return columns, walk(stmt.Where) && walk(stmt.Having)
+}
@@ -0,0 +1,86 @@
+package sql_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// bulkInsert returns an INSERT of rows rows into items, the row with id
+// duplicate (if not negative) repeating the id of the first.
+func bulkInsert(rows, duplicate int) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO items (name, id) VALUES ")
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		id := i + 1
+		if i == duplicate {
+			id = 1
+		}
+		fmt.Fprintf(&b, "('item-%d', %d)", i, id)
+	}
+	return b.String()
+}
+
+func TestBulkInsert(t *testing.T) {
+	db := storage.NewDatabase()
+	db.SetAuditEnabled(true)
+	session := sql.NewSession(db)
+	defer session.Close()
+	if _, err := execSQL(session, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, score FLOAT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []int
+	session.SetProgress(func(p sql.Progress) {
+		if p.Table != "items" || p.Total != 2500 {
+			t.Errorf("progress = %+v", p)
+		}
+		progress = append(progress, p.Rows)
+	})
+
+	// A duplicate key in the last batch undoes the rows already inserted.
+	if _, err := execSQL(session, bulkInsert(2500, 2100)); err == nil || !strings.Contains(err.Error(), "primary key violation") {
+		t.Fatalf("err = %v, want a primary key violation", err)
+	}
+	result, err := execSQL(session, "SELECT COUNT(*) FROM items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Rows[0][0]; got != "0" {
+		t.Fatalf("%s rows left after the failed INSERT, want 0", got)
+	}
+
+	progress = nil
+	result, err = execSQL(session, bulkInsert(2500, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RowsAffected != 2500 || result.LastInsertID != 2500 {
+		t.Fatalf("RowsAffected = %d, LastInsertID = %d, want 2500 and 2500", result.RowsAffected, result.LastInsertID)
+	}
+	if want := []int{1024, 2048, 2500}; !reflect.DeepEqual(progress, want) {
+		t.Fatalf("progress = %v, want %v", progress, want)
+	}
+
+	result, err = execSQL(session, "SELECT name, score FROM items WHERE id = 2000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"item-1999", "NULL"}}; !reflect.DeepEqual(result.Rows, want) {
+		t.Fatalf("row 2000 = %v, want %v", result.Rows, want)
+	}
+
+	// The audit log keeps the first rows and a count, not all 2500.
+	entries := db.AuditEntries()
+	audited := entries[len(entries)-1].Statement
+	if !strings.HasSuffix(audited, "(item-9, 10), ... (2500 rows)") {
+		t.Fatalf("audited statement = %q", audited)
+	}
+}
@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"unicode"
@@ -16,6 +17,8 @@ const (
 	TokenOperator
 	TokenPunctuation
 	TokenString
+	TokenParameter
+	TokenHint // the text of a /*+ ... */ comment
 )
 
 type Token struct {
@@ -43,7 +46,7 @@ func NewLexer(input string) *Lexer {
 	l := &Lexer{
 		input:  input,
 		line:   1,
-		column: 1,
+		column: 0,
 	}
 	l.readChar()
 	return l
@@ -58,9 +61,11 @@ func (l *Lexer) readChar() {
 	l.position = l.readPosition
 	l.readPosition++
 
+	// Columns are 1-based; the newline itself counts as column 0 of the
+	// line it starts.
 	if l.ch == '\n' {
 		l.line++
-		l.column = 1
+		l.column = 0
 	} else {
 		l.column++
 	}
@@ -73,26 +78,57 @@ func (l *Lexer) peekChar() rune {
 	return rune(l.input[l.readPosition])
 }
 
+// skipWhitespace skips spaces, newlines, -- comments and /* */ comments
+// other than hints, so statements can span lines in scripts.
 func (l *Lexer) skipWhitespace() {
-	for unicode.IsSpace(l.ch) && l.ch != '\n' {
-		l.readChar()
+	for {
+		for unicode.IsSpace(l.ch) {
+			l.readChar()
+		}
+		switch {
+		case l.ch == '-' && l.peekChar() == '-':
+			for l.ch != '\n' && l.ch != 0 {
+				l.readChar()
+			}
+		case l.ch == '/' && l.peekChar() == '*' && !l.atHint():
+			l.readChar()
+			l.readChar()
+			for l.ch != 0 && !(l.ch == '*' && l.peekChar() == '/') {
+				l.readChar()
+			}
+			l.readChar()
+			l.readChar()
+		default:
+			return
+		}
 	}
 }
 
-func (l *Lexer) skipComment() {
-	if l.ch == '-' && l.peekChar() == '-' {
-		for l.ch != '\n' && l.ch != 0 {
-			l.readChar()
-		}
-		l.skipWhitespace()
+// atHint reports whether the lexer is at the start of a /*+ comment.
+func (l *Lexer) atHint() bool {
+	return l.position < len(l.input) && strings.HasPrefix(l.input[l.position:], "/*+")
+}
+
+// readHint reads a /*+ ... */ comment, returning the text between the
+// markers.
+func (l *Lexer) readHint() string {
+	for i := 0; i < 3; i++ {
+		l.readChar()
+	}
+	start := l.position
+	for l.ch != 0 && !(l.ch == '*' && l.peekChar() == '/') {
+		l.readChar()
 	}
+	text := l.input[start:l.position]
+	l.readChar()
+	l.readChar()
+	return strings.TrimSpace(text)
 }
 
 func (l *Lexer) NextToken() Token {
 	var tok Token
 
 	l.skipWhitespace()
-	l.skipComment()
 
 	pos := Position{Line: l.line, Column: l.column}
 
@@ -134,6 +170,10 @@ func (l *Lexer) NextToken() Token {
 			tok = Token{Type: TokenOperator, Value: "<=", Position: pos}
 			l.readChar()
 			l.readChar()
+			if l.ch == '>' {
+				tok.Value = "<=>"
+				l.readChar()
+			}
 		} else {
 			tok = Token{Type: TokenOperator, Value: "<", Position: pos}
 			l.readChar()
@@ -149,6 +189,23 @@ func (l *Lexer) NextToken() Token {
 		}
 	case '\'':
 		tok = Token{Type: TokenString, Value: l.readString(), Position: pos}
+	case '/':
+		if l.atHint() {
+			return Token{Type: TokenHint, Value: l.readHint(), Position: pos}
+		}
+		tok = Token{Type: TokenOperator, Value: "/", Position: pos}
+		l.readChar()
+	case '?':
+		tok = Token{Type: TokenParameter, Value: "?", Position: pos}
+		l.readChar()
+	case '$':
+		if isDigit(l.peekChar()) {
+			l.readChar()
+			tok = Token{Type: TokenParameter, Value: "$" + l.readDigits(), Position: pos}
+			return tok
+		}
+		tok = Token{Type: TokenOperator, Value: "$", Position: pos}
+		l.readChar()
 	default:
 		if isLetter(l.ch) {
 			ident := l.readIdentifier()
@@ -193,20 +250,52 @@ func (l *Lexer) readNumber() string {
 	return l.input[position:l.position]
 }
 
+func (l *Lexer) readDigits() string {
+	position := l.position
+	for isDigit(l.ch) {
+		l.readChar()
+	}
+	return l.input[position:l.position]
+}
+
+// readString reads a quoted string. A quote in it is written twice, or
+// after a backslash, which is kept in the string. Two backslashes before a
+// quote stand for one, so that a string may hold a backslash followed by
+// a quote, or end with one.
 func (l *Lexer) readString() string {
 	position := l.position + 1
 	l.readChar()
 
-	for l.ch != '\'' && l.ch != 0 {
-		if l.ch == '\\' && l.peekChar() == '\'' {
+	var skipped []int // offsets of the second quote of each '', and of the second backslash of each \\'
+	for l.ch != 0 {
+		if l.ch == '\\' && l.peekChar() == '\\' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '\'' {
+			l.readChar()
+			skipped = append(skipped, l.position-position)
+		} else if l.ch == '\\' && l.peekChar() == '\'' {
 			l.readChar()
+		} else if l.ch == '\'' {
+			if l.peekChar() != '\'' {
+				break
+			}
+			l.readChar()
+			skipped = append(skipped, l.position-position)
 		}
 		l.readChar()
 	}
 
 	value := l.input[position:l.position]
 	l.readChar()
-	return value
+	if len(skipped) == 0 {
+		return value
+	}
+	var b strings.Builder
+	last := 0
+	for _, i := range skipped {
+		b.WriteString(value[last:i])
+		last = i + 1
+	}
+	b.WriteString(value[last:])
+	return b.String()
 }
 
 func isLetter(ch rune) bool {
@@ -225,6 +314,7 @@ func isKeyword(ident string) bool {
 		"DELETE":      true,
 		"CREATE":      true,
 		"DROP":        true,
+		"ALTER":       true,
 		"TABLE":       true,
 		"INTO":        true,
 		"VALUES":      true,
@@ -250,32 +340,65 @@ func isKeyword(ident string) bool {
 		"RESTRICT":    true,
 		"LIMIT":       true,
 		"OFFSET":      true,
+		"GROUP":       true,
+		"HAVING":      true,
 		"ORDER":       true,
 		"BY":          true,
 		"ASC":         true,
 		"DESC":        true,
+		"LIKE":        true,
+		"ILIKE":       true,
+		"MATCH":       true,
+		"IN":          true,
+		"UNION":       true,
+		"INTERSECT":   true,
+		"EXCEPT":      true,
 		"BEGIN":       true,
 		"COMMIT":      true,
 		"ROLLBACK":    true,
+		"PREPARE":     true,
 		"TRANSACTION": true,
+		"LISTEN":      true,
+		"UNLISTEN":    true,
+		"NOTIFY":      true,
+		"SHOW":        true,
+		"BACKUP":      true,
+		"EXPLAIN":     true,
+		"ANALYZE":     true,
+		"ATTACH":      true,
+		"DETACH":      true,
 	}
 	return keywords[strings.ToUpper(ident)]
 }
 
 func (l *Lexer) Tokenize() ([]Token, error) {
-	tokens := make([]Token, 0)
+	// Most tokens take a few bytes with the space after them; guessing
+	// saves regrowing the slice many times over for a bulk INSERT.
+	tokens := make([]Token, 0, len(l.input)/4+1)
 
 	for {
 		tok := l.NextToken()
 		if tok.Type == TokenEOF {
 			break
 		}
+		// Hints only mean something right after SELECT; elsewhere they
+		// are comments.
+		if tok.Type == TokenHint && (len(tokens) == 0 || !isSelectKeyword(tokens[len(tokens)-1])) {
+			continue
+		}
 		tokens = append(tokens, tok)
 	}
 
 	return tokens, nil
 }
 
+func isSelectKeyword(tok Token) bool {
+	return tok.Type == TokenKeyword && strings.EqualFold(tok.Value, "SELECT")
+}
+
+// SQLError is an error at a position in the statement text: a syntax
+// error, or an execution error located by the AST node that caused it, in
+// which case Err is the underlying error.
 type SQLError struct {
 	Code       int
 	Message    string
@@ -283,10 +406,14 @@ type SQLError struct {
 	Column     int
 	Context    string
 	Suggestion string
+	Err        error
 }
 
 func (e *SQLError) Error() string {
-	result := fmt.Sprintf("SQL error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+	result := "SQL error: " + e.Message
+	if e.Line > 0 {
+		result = fmt.Sprintf("SQL error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
 	if e.Context != "" {
 		result += fmt.Sprintf("\nContext: %s", e.Context)
 	}
@@ -298,7 +425,7 @@ func (e *SQLError) Error() string {
 
 func NewParseError(message string, token Token, suggestion string) *SQLError {
 	return &SQLError{
-		Code:       ErrSyntax,
+		Code:       CodeSyntax,
 		Message:    message,
 		Line:       token.Position.Line,
 		Column:     token.Position.Column,
@@ -307,13 +434,51 @@ func NewParseError(message string, token Token, suggestion string) *SQLError {
 	}
 }
 
+func (e *SQLError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the error kind matching e's Code.
+func (e *SQLError) Is(target error) bool {
+	for _, k := range codeKinds {
+		if k.code == e.Code {
+			return k.kind == target
+		}
+	}
+	return false
+}
+
 const (
-	ErrSyntax = iota
-	ErrTableNotFound
-	ErrColumnNotFound
-	ErrDuplicateKey
-	ErrConstraintViolation
-	ErrTypeMismatch
-	ErrTransaction
-	ErrUnknown
+	CodeSyntax = iota
+	CodeTableNotFound
+	CodeColumnNotFound
+	CodeDuplicateKey
+	CodeConstraintViolation
+	CodeTypeMismatch
+	CodeTransaction
+	CodeUnknown
 )
+
+// codeKinds maps codes to error kinds, most specific first.
+var codeKinds = []struct {
+	code int
+	kind error
+}{
+	{CodeSyntax, ErrSyntax},
+	{CodeTableNotFound, ErrTableNotFound},
+	{CodeColumnNotFound, ErrColumnNotFound},
+	{CodeDuplicateKey, ErrUniqueViolation},
+	{CodeConstraintViolation, ErrConstraintViolation},
+	{CodeTypeMismatch, ErrTypeMismatch},
+	{CodeTransaction, ErrTransaction},
+}
+
+// errorCode returns the code for err's kind, or CodeUnknown.
+func errorCode(err error) int {
+	for _, k := range codeKinds {
+		if errors.Is(err, k.kind) {
+			return k.code
+		}
+	}
+	return CodeUnknown
+}
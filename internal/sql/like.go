@@ -0,0 +1,155 @@
+package sql
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// evaluateLike implements [NOT] LIKE and [NOT] ILIKE. In patterns, % matches
+// any run of characters, _ matches one, and \ escapes the next character.
+// Non-text operands are compared by their text form; NULL gives NULL.
+func evaluateLike(left storage.Value, op string, right storage.Value) storage.Value {
+	if left.Type() == storage.TypeNull || right.Type() == storage.TypeNull {
+		return storage.NullValue{}
+	}
+
+	s, pattern := left.ToString(), right.ToString()
+	if strings.HasSuffix(op, "ILIKE") {
+		s, pattern = strings.ToLower(s), strings.ToLower(pattern)
+	}
+
+	matched := likeMatch(s, pattern)
+	if strings.HasPrefix(op, "NOT ") {
+		matched = !matched
+	}
+	return storage.NewBooleanValue(matched)
+}
+
+// likeMatch reports whether s matches pattern. It backtracks only to the
+// most recent %, so it runs in O(len(s) * len(pattern)) at worst.
+func likeMatch(s, pattern string) bool {
+	si, pi := 0, 0
+	starP, starS := -1, 0
+
+	for si < len(s) {
+		if pi < len(pattern) {
+			pc, pw := utf8.DecodeRuneInString(pattern[pi:])
+			switch {
+			case pc == '%':
+				starP, starS = pi+pw, si
+				pi += pw
+				continue
+			case pc == '_':
+				_, sw := utf8.DecodeRuneInString(s[si:])
+				si += sw
+				pi += pw
+				continue
+			case pc == '\\' && pi+pw < len(pattern):
+				pi += pw
+				pc, pw = utf8.DecodeRuneInString(pattern[pi:])
+			}
+			sc, sw := utf8.DecodeRuneInString(s[si:])
+			if sc == pc {
+				si += sw
+				pi += pw
+				continue
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		// Let the last % absorb one more character and retry.
+		_, sw := utf8.DecodeRuneInString(s[starS:])
+		starS += sw
+		si, pi = starS, starP
+	}
+
+	for pi < len(pattern) && pattern[pi] == '%' {
+		pi++
+	}
+	return pi == len(pattern)
+}
+
+// likeIndexRange looks in where, through its ANDs, for a case-sensitive
+// LIKE on an indexed text column of table (known in the query as name)
+// whose pattern starts with literal text, as in name LIKE 'Jo%'. It returns
+// the column and an inclusive range of keys covering every value with that
+// prefix, so a scan can read just those rows from the index; the LIKE
+// itself is still applied to them.
+func (e *Executor) likeIndexRange(where Expression, table *storage.Table, name string, joined bool) (string, storage.Value, storage.Value, bool) {
+	expr, ok := where.(*BinaryExpression)
+	if !ok {
+		return "", nil, nil, false
+	}
+	if expr.Op == "AND" {
+		if column, start, end, ok := e.likeIndexRange(expr.Left, table, name, joined); ok {
+			return column, start, end, true
+		}
+		return e.likeIndexRange(expr.Right, table, name, joined)
+	}
+	if expr.Op != "LIKE" {
+		return "", nil, nil, false
+	}
+
+	ref, ok := expr.Left.(*ColumnRef)
+	if !ok || ref.Table != "" && ref.Table != name || ref.Table == "" && joined {
+		return "", nil, nil, false
+	}
+	if col, ok := table.Schema().GetColumn(ref.Column); !ok || col.Type != storage.TypeText || !table.HasIndex(ref.Column) || !e.indexAllowed(name, "", ref.Column) {
+		return "", nil, nil, false
+	}
+
+	var pattern storage.Value
+	var err error
+	switch right := expr.Right.(type) {
+	case *LiteralExpression:
+		pattern, err = right.parseLiteral()
+	case *Parameter:
+		pattern, err = e.paramValue(right)
+	default:
+		return "", nil, nil, false
+	}
+	text, ok := pattern.(*storage.TextValue)
+	if err != nil || !ok {
+		return "", nil, nil, false
+	}
+	prefix := likePrefix(text.Value)
+	end, ok := prefixEnd(prefix)
+	if prefix == "" || !ok {
+		return "", nil, nil, false
+	}
+	return ref.Column, storage.NewTextValue(prefix), storage.NewTextValue(end), true
+}
+
+// likePrefix returns the literal text a pattern starts with, up to its
+// first % or _.
+func likePrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '%' || c == '_':
+			return b.String()
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteByte(pattern[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// prefixEnd returns the smallest string greater than every string that
+// starts with prefix, or false if there is none (prefix is all 0xFF bytes).
+func prefixEnd(prefix string) (string, bool) {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xFF {
+			b[i]++
+			return string(b[:i+1]), true
+		}
+	}
+	return "", false
+}
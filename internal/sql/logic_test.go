@@ -0,0 +1,49 @@
+package sql_test
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sqltest"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/golden/*.golden with the current output")
+
+// TestLogic runs every testdata/*.sqltest file; see package sqltest for the
+// format. Run one file with go test -run TestLogic/<name>.
+func TestLogic(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.sqltest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no testdata/*.sqltest files")
+	}
+	for _, file := range files {
+		file := file
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".sqltest"), func(t *testing.T) {
+			sqltest.RunFile(t, file)
+		})
+	}
+}
+
+// TestGolden runs every testdata/golden/*.sql script and compares its
+// transcript with the matching .golden file; go test -run TestGolden
+// -update rewrites them after an intended change.
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/golden/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no testdata/golden/*.sql files")
+	}
+	for _, file := range files {
+		file := file
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".sql"), func(t *testing.T) {
+			sqltest.RunGolden(t, file, *update)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// The executor materializes each step's rows, so every step charges what it
+// holds to the statement's memoryAccount: scans and joins their row sets,
+// the filter its output, the aggregate its hash table of groups and the
+// projection the result. Sorts reorder their input in place and add
+// nothing. Sizes are estimates of the Go heap involved, not exact
+// allocations. SET work_mem caps a statement's total and EXPLAIN ANALYZE
+// reports it.
+
+const (
+	rowOverhead   = 48 // the *Row, the Row and its Values slice header
+	valueOverhead = 24 // the interface in Values and the value it boxes
+	pointerSize   = 8
+	stringHeader  = 16
+	groupBytes    = 96 // a group, its map entry and its slot in the group list
+)
+
+func rowBytes(row *storage.Row) int64 {
+	n := int64(rowOverhead)
+	for _, v := range row.Values {
+		n += valueBytes(v)
+	}
+	return n
+}
+
+func valueBytes(v storage.Value) int64 {
+	if t, ok := v.(*storage.TextValue); ok {
+		return valueOverhead + int64(len(t.Value))
+	}
+	return valueOverhead
+}
+
+// resultRowBytes is the size of one output row: its text, and the values
+// it shares with the row it was projected from.
+func resultRowBytes(strs []string) int64 {
+	n := int64(rowOverhead)
+	for _, s := range strs {
+		n += stringHeader + int64(len(s)) + valueOverhead
+	}
+	return n
+}
+
+// memoryAccount tracks the memory one statement holds against its limit.
+type memoryAccount struct {
+	limit int64 // 0 for no limit
+	used  int64
+	peak  int64
+	steps []memoryStep
+}
+
+// memoryStep is what one step of the statement charged, in the order the
+// steps ran.
+type memoryStep struct {
+	name  string
+	bytes int64
+}
+
+// grow charges n bytes to step, failing once the statement holds more than
+// its limit.
+func (m *memoryAccount) grow(step string, n int64) error {
+	m.used += n
+	if m.used > m.peak {
+		m.peak = m.used
+	}
+	if last := len(m.steps) - 1; last >= 0 && m.steps[last].name == step {
+		m.steps[last].bytes += n
+	} else {
+		m.steps = append(m.steps, memoryStep{name: step, bytes: n})
+	}
+	if m.limit > 0 && m.used > m.limit {
+		return errorf(ErrMemoryLimit, "statement exceeded work_mem (%s) in %s", formatBytes(m.limit), step)
+	}
+	return nil
+}
+
+// release returns n bytes that a finished step no longer holds.
+func (m *memoryAccount) release(n int64) {
+	m.used -= n
+}
+
+// SetWorkMem limits the memory each statement may hold to limit bytes; 0
+// removes the limit.
+func (e *Executor) SetWorkMem(limit int64) {
+	e.workMem = limit
+}
+
+// MemoryUsage returns the peak memory, in bytes, held by the last statement
+// and by the largest statement this executor has run.
+func (e *Executor) MemoryUsage() (last, peak int64) {
+	if e.mem != nil {
+		last = e.mem.peak
+	}
+	return last, e.peakMem
+}
+
+// chargeRow charges row and its slot in a row set to step.
+func (e *Executor) chargeRow(step string, row *storage.Row) (int64, error) {
+	n := rowBytes(row) + pointerSize
+	return n, e.mem.grow(step, n)
+}
+
+// parseWorkMem accepts the values SET work_mem takes: a number of kilobytes
+// as in PostgreSQL, a size with a B, kB, MB or GB unit, or 0 or "unlimited"
+// for no limit.
+func parseWorkMem(value string) (int64, bool) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "unlimited") {
+		return 0, true
+	}
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30}, {"b", 1}}
+	size := int64(1 << 10)
+	lower := strings.ToLower(value)
+	for _, u := range units {
+		if strings.HasSuffix(lower, u.suffix) {
+			size = u.size
+			value = strings.TrimSpace(value[:len(value)-len(u.suffix)])
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n * size, true
+}
+
+// formatBytes renders n as e.g. "512 B", "12.5 kB" or "4.0 MB".
+func formatBytes(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f kB", float64(n)/(1<<10))
+	case n < 1<<30:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+}
+
+// memoryReport is the summary EXPLAIN ANALYZE adds below the plan.
+func (e *Executor) memoryReport(rows int) []string {
+	limit := "unlimited"
+	if e.mem.limit > 0 {
+		limit = formatBytes(e.mem.limit)
+	}
+	lines := []string{fmt.Sprintf("Rows: %d", rows)}
+	for _, step := range e.mem.steps {
+		lines = append(lines, fmt.Sprintf("Memory: %s %s", step.name, formatBytes(step.bytes)))
+	}
+	return append(lines, fmt.Sprintf("Peak Memory: %s (work_mem %s)", formatBytes(e.mem.peak), limit))
+}
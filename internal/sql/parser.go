@@ -1,14 +1,43 @@
 package sql
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Parser struct {
 	lexer  *Lexer
 	tokens []Token
 	pos    int
+	params int
+	// errs holds syntax errors the parser recovered from in the current
+	// statement.
+	errs ParseErrors
+}
+
+// ParseErrors is every syntax error found in one pass, in source order.
+type ParseErrors []*SQLError
+
+func (e ParseErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d syntax errors:\n%s", len(e), strings.Join(messages, "\n"))
+}
+
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
 }
 
 func NewParser(lexer *Lexer) *Parser {
@@ -20,7 +49,113 @@ func NewParser(lexer *Lexer) *Parser {
 	}
 }
 
+// Parse parses one statement. A column definition or VALUES row with a
+// syntax error is skipped so that the rest of the statement is still
+// checked; if there was more than one error, the error is a ParseErrors.
 func (p *Parser) Parse() (Node, error) {
+	p.errs = nil
+	node, err := p.parseStatement()
+	if err := p.statementErrors(err); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// ParseAll parses a script of statements separated by semicolons. After a
+// syntax error it skips to the next semicolon and carries on, so one call
+// reports every broken statement, as a ParseErrors.
+func (p *Parser) ParseAll() ([]Node, error) {
+	var nodes []Node
+	var errs ParseErrors
+	for {
+		for p.atPunctuation(";") {
+			p.advance()
+		}
+		if p.currentToken().Type == TokenEOF {
+			break
+		}
+
+		p.errs = nil
+		node, err := p.parseStatement()
+		if tok := p.currentToken(); err == nil && tok.Type != TokenEOF && !p.atPunctuation(";") {
+			err = NewParseError(fmt.Sprintf("unexpected %s after end of statement", tok.Value), tok, "end each statement with ';'")
+		}
+		if err := p.statementErrors(err); err != nil {
+			var list ParseErrors
+			if errors.As(err, &list) {
+				errs = append(errs, list...)
+			} else {
+				errs = append(errs, err.(*SQLError))
+			}
+			p.skipTo()
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+
+	if len(errs) > 0 {
+		return nodes, errs
+	}
+	return nodes, nil
+}
+
+// statementErrors combines err with the errors recovered from while
+// parsing the current statement.
+func (p *Parser) statementErrors(err error) error {
+	if err != nil {
+		p.errs = append(p.errs, err.(*SQLError))
+	}
+	switch len(p.errs) {
+	case 0:
+		return nil
+	case 1:
+		return p.errs[0]
+	}
+	return p.errs
+}
+
+// recover records err, goes back to from (the start of the list item that
+// failed) and skips the whole item, stopping at the next of stops outside
+// parentheses, so the caller can resume at a comma or closing parenthesis.
+func (p *Parser) recover(err error, from int, stops ...string) {
+	p.errs = append(p.errs, err.(*SQLError))
+	p.pos = from
+	p.skipTo(stops...)
+}
+
+// skipTo advances to the next punctuation in stops at the current nesting
+// depth, stopping early at the end of the statement.
+func (p *Parser) skipTo(stops ...string) {
+	depth := 0
+	for {
+		tok := p.currentToken()
+		if tok.Type == TokenEOF || p.atPunctuation(";") {
+			return
+		}
+		if tok.Type == TokenPunctuation {
+			if depth == 0 {
+				for _, stop := range stops {
+					if tok.Value == stop {
+						return
+					}
+				}
+			}
+			if tok.Value == "(" {
+				depth++
+			} else if tok.Value == ")" && depth > 0 {
+				depth--
+			}
+		}
+		p.advance()
+	}
+}
+
+func (p *Parser) atPunctuation(punct string) bool {
+	tok := p.currentToken()
+	return tok.Type == TokenPunctuation && tok.Value == punct
+}
+
+func (p *Parser) parseStatement() (Node, error) {
 	if p.pos >= len(p.tokens) {
 		return nil, NewParseError("unexpected end of input", p.currentToken(), "check your SQL statement")
 	}
@@ -39,15 +174,99 @@ func (p *Parser) Parse() (Node, error) {
 		case "DELETE":
 			return p.parseDelete()
 		case "CREATE":
+			if strings.EqualFold(p.peekToken().Value, "USER") {
+				return p.parseCreateUser()
+			}
+			if strings.EqualFold(p.peekToken().Value, "INDEX") {
+				return p.parseCreateIndex()
+			}
+			if kind := strings.ToUpper(p.peekToken().Value); kind == "FULLTEXT" || kind == "TRIGRAM" {
+				return p.parseCreateTextIndex(kind)
+			}
 			return p.parseCreateTable()
 		case "DROP":
+			if strings.EqualFold(p.peekToken().Value, "USER") {
+				return p.parseDropUser()
+			}
+			if strings.EqualFold(p.peekToken().Value, "INDEX") {
+				p.pos += 2
+				nameTok := p.currentToken()
+				if nameTok.Type != TokenIdentifier {
+					return nil, NewParseError("expected index name", nameTok, "use DROP INDEX name")
+				}
+				p.advance()
+				return &DropIndexStatement{Name: nameTok.Value}, nil
+			}
 			return p.parseDropTable()
+		case "ALTER":
+			return p.parseAlterTable()
 		case "BEGIN":
 			return p.parseBeginTransaction()
 		case "COMMIT":
+			p.advance()
+			if strings.EqualFold(p.currentToken().Value, "PREPARED") {
+				gid, err := p.parseTransactionID("COMMIT PREPARED")
+				if err != nil {
+					return nil, err
+				}
+				return &CommitPreparedStatement{GID: gid}, nil
+			}
 			return &CommitStatement{}, nil
 		case "ROLLBACK":
+			p.advance()
+			if strings.EqualFold(p.currentToken().Value, "PREPARED") {
+				gid, err := p.parseTransactionID("ROLLBACK PREPARED")
+				if err != nil {
+					return nil, err
+				}
+				return &RollbackPreparedStatement{GID: gid}, nil
+			}
 			return &RollbackStatement{}, nil
+		case "PREPARE":
+			if !strings.EqualFold(p.peekToken().Value, "TRANSACTION") {
+				return nil, NewParseError("expected TRANSACTION", p.peekToken(), "use PREPARE TRANSACTION 'id'")
+			}
+			p.advance()
+			gid, err := p.parseTransactionID("PREPARE TRANSACTION")
+			if err != nil {
+				return nil, err
+			}
+			return &PrepareTransactionStatement{GID: gid}, nil
+		case "LISTEN":
+			return p.parseListen()
+		case "UNLISTEN":
+			return p.parseUnlisten()
+		case "NOTIFY":
+			return p.parseNotify()
+		case "SET":
+			if strings.EqualFold(p.peekToken().Value, "CONSTRAINTS") {
+				return p.parseSetConstraints()
+			}
+			return p.parseSet()
+		case "SHOW":
+			return p.parseShow()
+		case "BACKUP":
+			return p.parseBackup()
+		case "EXPLAIN":
+			return p.parseExplain()
+		case "ANALYZE":
+			p.advance()
+			stmt := &AnalyzeStatement{}
+			if tok := p.currentToken(); tok.Type == TokenIdentifier {
+				stmt.Table, stmt.TablePos = tok.Value, tok.Position
+				p.advance()
+			}
+			return stmt, nil
+		case "ATTACH":
+			return p.parseAttach()
+		case "DETACH":
+			p.advance()
+			aliasTok := p.currentToken()
+			if aliasTok.Type != TokenIdentifier {
+				return nil, NewParseError("expected database alias", aliasTok, "use DETACH alias")
+			}
+			p.advance()
+			return &DetachStatement{Alias: aliasTok.Value}, nil
 		default:
 			return nil, NewParseError(fmt.Sprintf("unexpected keyword: %s", tok.Value), tok, "check SQL syntax")
 		}
@@ -56,6 +275,11 @@ func (p *Parser) Parse() (Node, error) {
 	}
 }
 
+// ParamCount reports how many bind parameters the parsed statement expects.
+func (p *Parser) ParamCount() int {
+	return p.params
+}
+
 func (p *Parser) currentToken() Token {
 	if p.pos >= len(p.tokens) {
 		return Token{Type: TokenEOF}
@@ -96,42 +320,118 @@ func (p *Parser) expectPunctuation(punct string) error {
 	return nil
 }
 
+// parseSelect parses a SELECT and those combined with it by UNION,
+// INTERSECT or EXCEPT. An ORDER BY, LIMIT or OFFSET may only follow the
+// last of them, and applies to the combined rows.
 func (p *Parser) parseSelect() (*SelectStatement, error) {
+	stmt, err := p.parseSimpleSelect()
+	if err != nil {
+		return nil, err
+	}
+	last := stmt
+	for p.atSetOperator() {
+		tok := p.advance()
+		if len(last.OrderBy) > 0 || last.Limit != nil || last.Offset != nil {
+			return nil, NewParseError(fmt.Sprintf("ORDER BY, LIMIT and OFFSET must follow the last SELECT of a %s", strings.ToUpper(tok.Value)), tok,
+				"move them to the end of the statement")
+		}
+		op := SetOperation{Op: strings.ToUpper(tok.Value), Pos: tok.Position}
+		if tok := p.currentToken(); tok.Type != TokenString && strings.EqualFold(tok.Value, "ALL") {
+			op.All = true
+			p.advance()
+		} else if tok.Type != TokenString && strings.EqualFold(tok.Value, "DISTINCT") {
+			p.advance()
+		}
+		if tok := p.currentToken(); tok.Type != TokenKeyword || !strings.EqualFold(tok.Value, "SELECT") {
+			return nil, NewParseError(fmt.Sprintf("expected SELECT after %s", op.Op), tok, "combine two SELECT statements")
+		}
+		if op.Select, err = p.parseSimpleSelect(); err != nil {
+			return nil, err
+		}
+		stmt.Compound = append(stmt.Compound, op)
+		last = op.Select
+	}
+	if last != stmt {
+		stmt.OrderBy, stmt.Limit, stmt.Offset = last.OrderBy, last.Limit, last.Offset
+		last.OrderBy, last.Limit, last.Offset = nil, nil, nil
+	}
+	return stmt, nil
+}
+
+func (p *Parser) atSetOperator() bool {
+	tok := p.currentToken()
+	if tok.Type != TokenKeyword {
+		return false
+	}
+	switch strings.ToUpper(tok.Value) {
+	case "UNION", "INTERSECT", "EXCEPT":
+		return true
+	}
+	return false
+}
+
+func (p *Parser) parseSimpleSelect() (*SelectStatement, error) {
 	stmt := &SelectStatement{}
 
 	if err := p.expectKeyword("SELECT"); err != nil {
 		return nil, err
 	}
+	if tok := p.currentToken(); tok.Type == TokenHint {
+		hints, err := parseHints(tok)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Hints = hints
+		p.advance()
+	}
 
-	if p.peekToken().Type == TokenKeyword && strings.ToUpper(p.peekToken().Value) == "DISTINCT" {
+	if tok := p.currentToken(); tok.Type != TokenString && strings.EqualFold(tok.Value, "DISTINCT") {
 		stmt.Distinct = true
 		p.advance()
 	}
 
-	columns, err := p.parseColumnList()
-	if err != nil {
+	if err := p.parseColumnList(stmt); err != nil {
 		return nil, err
 	}
-	stmt.Columns = columns
 
-	if err := p.expectKeyword("FROM"); err != nil {
-		return nil, err
+	// Without FROM the statement ends after its columns, or its ORDER BY,
+	// LIMIT and OFFSET: SELECT VERSION(), SELECT 1 + 1 LIMIT 0, or (SELECT
+	// 1) as a subquery.
+	tok := p.currentToken()
+	if tok.Type == TokenEOF || tok.Type == TokenPunctuation && (tok.Value == ";" || tok.Value == ")") || p.atSetOperator() {
+		return stmt, nil
 	}
-
-	tables, err := p.parseTableList()
-	if err != nil {
-		return nil, err
+	if !withoutFromClause(tok) {
+		if err := p.expectKeyword("FROM"); err != nil {
+			return nil, err
+		}
+		tables, err := p.parseTableList()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Tables = tables
 	}
-	stmt.Tables = tables
 
 	for {
 		tok := p.currentToken()
-		if tok.Type == TokenEOF || tok.Type == TokenPunctuation && tok.Value == ";" {
+		if tok.Type == TokenEOF || tok.Type == TokenPunctuation && tok.Value == ";" || p.atSetOperator() {
 			break
 		}
 
+		if p.atAsOf() {
+			at, err := p.parseAsOf()
+			if err != nil {
+				return nil, err
+			}
+			stmt.AsOf = &at
+			continue
+		}
+
 		if tok.Type == TokenKeyword {
 			keyword := strings.ToUpper(tok.Value)
+			if len(stmt.Tables) == 0 && !withoutFromClause(tok) {
+				return nil, NewParseError(fmt.Sprintf("%s needs a FROM clause", keyword), tok, "add FROM and the tables to read before "+keyword)
+			}
 			switch keyword {
 			case "WHERE":
 				p.advance()
@@ -146,6 +446,24 @@ func (p *Parser) parseSelect() (*SelectStatement, error) {
 					return nil, err
 				}
 				stmt.Joins = append(stmt.Joins, join)
+			case "GROUP":
+				p.advance()
+				if err := p.expectKeyword("BY"); err != nil {
+					return nil, err
+				}
+				groupBy, positions, err := p.parseGroupBy()
+				if err != nil {
+					return nil, err
+				}
+				stmt.GroupBy = groupBy
+				stmt.GroupByPos = positions
+			case "HAVING":
+				p.advance()
+				expr, err := p.parseExpression()
+				if err != nil {
+					return nil, err
+				}
+				stmt.Having = expr
 			case "ORDER":
 				p.advance()
 				if err := p.expectKeyword("BY"); err != nil {
@@ -171,7 +489,7 @@ func (p *Parser) parseSelect() (*SelectStatement, error) {
 				}
 				stmt.Offset = &offset
 			default:
-				break
+				return nil, NewParseError(fmt.Sprintf("unexpected keyword: %s", tok.Value), tok, "check the clause order: WHERE, JOIN, GROUP BY, HAVING, ORDER BY, LIMIT, OFFSET")
 			}
 		} else {
 			break
@@ -181,45 +499,173 @@ func (p *Parser) parseSelect() (*SelectStatement, error) {
 	return stmt, nil
 }
 
-func (p *Parser) parseColumnList() ([]string, error) {
-	columns := make([]string, 0)
+// withoutFromClause reports whether tok starts a clause a SELECT without
+// FROM may have: ORDER BY, LIMIT or OFFSET.
+func withoutFromClause(tok Token) bool {
+	if tok.Type != TokenKeyword {
+		return false
+	}
+	switch strings.ToUpper(tok.Value) {
+	case "ORDER", "LIMIT", "OFFSET":
+		return true
+	}
+	return false
+}
 
-	if p.currentToken().Value == "*" {
-		columns = append(columns, "*")
+// parseColumnList parses the SELECT list into stmt.Columns, naming each
+// expression by its SQL text and a table's star "t.*". Aggregate calls
+// such as COUNT(*), alone or inside an expression, are also listed in
+// stmt.Aggregates.
+func (p *Parser) parseColumnList(stmt *SelectStatement) error {
+	if tok := p.currentToken(); tok.Value == "*" {
+		stmt.Columns = []SelectItem{{Name: "*", Pos: tok.Position}}
 		p.advance()
-		return columns, nil
+		return nil
 	}
 
 	for {
 		tok := p.currentToken()
-		if tok.Type == TokenIdentifier {
-			colName := tok.Value
+		if tok.Type == TokenIdentifier && p.peekToken().Value == "." &&
+			p.pos+2 < len(p.tokens) && p.tokens[p.pos+2].Value == "*" {
+			p.pos += 3
+			stmt.Columns = append(stmt.Columns, SelectItem{Name: tok.Value + ".*", Pos: tok.Position})
+		} else {
+			expr, err := p.parseExpression()
+			if err != nil {
+				return err
+			}
+			stmt.Columns = append(stmt.Columns, SelectItem{Name: expr.String(), Expr: expr, Pos: tok.Position})
+			stmt.Aggregates = aggregateCalls(expr, stmt.Aggregates)
+		}
+
+		if p.currentToken().Value == "," {
 			p.advance()
+		} else {
+			break
+		}
+	}
+	return nil
+}
 
-			if p.currentToken().Type == TokenPunctuation && p.currentToken().Value == "." {
-				p.advance()
-				nextTok := p.currentToken()
-				if nextTok.Type == TokenIdentifier {
-					colName += "." + nextTok.Value
-					p.advance()
-				} else {
-					return nil, NewParseError("expected column name after '.'", nextTok, "provide a valid column name")
-				}
-			}
+// parseSystemFunction parses a call to a system function: its name and an
+// empty argument list, which CURRENT_USER may leave out as in standard SQL.
+// ok is false, and nothing is consumed, when the current token does not
+// start one.
+func (p *Parser) parseSystemFunction() (fn *SystemFunction, ok bool, err error) {
+	tok := p.currentToken()
+	name := strings.ToUpper(tok.Value)
+	if _, known := systemFunctions[name]; tok.Type != TokenIdentifier || !known {
+		return nil, false, nil
+	}
+	parens := p.peekToken().Type == TokenPunctuation && p.peekToken().Value == "("
+	if !parens && name != "CURRENT_USER" {
+		return nil, false, nil
+	}
+	p.advance()
+	if parens {
+		p.advance()
+		if !p.atPunctuation(")") {
+			return nil, true, NewParseError(name+" takes no arguments", p.currentToken(), "write "+name+"()")
+		}
+		p.advance()
+	}
+	return &SystemFunction{Name: name, Pos: tok.Position}, true, nil
+}
 
-			columns = append(columns, colName)
+// parseQualifiedName parses a column name, optionally qualified with a
+// table or alias ("t.col").
+func (p *Parser) parseQualifiedName() (string, error) {
+	tok := p.currentToken()
+	if tok.Type != TokenIdentifier {
+		return "", NewParseError("expected column name", tok, "provide a valid column name")
+	}
+	name := tok.Value
+	p.advance()
 
-			if p.currentToken().Value == "," {
-				p.advance()
-			} else {
-				break
-			}
-		} else {
-			return nil, NewParseError("expected column name or *", tok, "provide valid column names")
+	if p.currentToken().Type == TokenPunctuation && p.currentToken().Value == "." {
+		p.advance()
+		nextTok := p.currentToken()
+		if nextTok.Type != TokenIdentifier {
+			return "", NewParseError("expected column name after '.'", nextTok, "provide a valid column name")
 		}
+		name += "." + nextTok.Value
+		p.advance()
 	}
+	return name, nil
+}
 
-	return columns, nil
+// aggregateFunctions are the aggregates an expression, as in HAVING, may
+// call.
+var aggregateFunctions = map[string]bool{"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true}
+
+// parseAggregate parses an aggregate call: COUNT(*) or NAME([DISTINCT]
+// expression), such as SUM(price * quantity), optionally followed by
+// FILTER (WHERE condition).
+func (p *Parser) parseAggregate() (*FunctionCall, error) {
+	nameTok := p.advance()
+	call := &FunctionCall{Name: strings.ToUpper(nameTok.Value), Pos: nameTok.Position}
+	if err := p.expectPunctuation("("); err != nil {
+		return nil, err
+	}
+
+	if tok := p.currentToken(); strings.EqualFold(tok.Value, "DISTINCT") && p.peekToken().Value != ")" {
+		p.advance()
+		call.Distinct = true
+	}
+	if tok := p.currentToken(); tok.Value == "*" {
+		if call.Distinct {
+			return nil, NewParseError("DISTINCT requires a column", tok, "use COUNT(DISTINCT column) or COUNT(*)")
+		}
+		p.advance()
+		call.Arguments = []Expression{&ColumnRef{Column: "*", Pos: tok.Position}}
+	} else {
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		call.Arguments = []Expression{arg}
+	}
+
+	if err := p.expectPunctuation(")"); err != nil {
+		return nil, err
+	}
+
+	if tok := p.currentToken(); strings.EqualFold(tok.Value, "FILTER") && p.peekToken().Value == "(" {
+		p.advance()
+		p.advance()
+		if err := p.expectKeyword("WHERE"); err != nil {
+			return nil, err
+		}
+		filter, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunctuation(")"); err != nil {
+			return nil, err
+		}
+		call.Filter = filter
+	}
+	return call, nil
+}
+
+func (p *Parser) parseGroupBy() ([]string, []Position, error) {
+	columns := make([]string, 0)
+	var positions []Position
+	for {
+		pos := p.currentToken().Position
+		name, err := p.parseQualifiedName()
+		if err != nil {
+			return nil, nil, err
+		}
+		columns = append(columns, name)
+		positions = append(positions, pos)
+
+		if p.currentToken().Value != "," {
+			break
+		}
+		p.advance()
+	}
+	return columns, positions, nil
 }
 
 func (p *Parser) parseTableList() ([]TableRef, error) {
@@ -228,11 +674,18 @@ func (p *Parser) parseTableList() ([]TableRef, error) {
 	for {
 		tok := p.currentToken()
 		if tok.Type == TokenIdentifier {
-			ref := TableRef{Name: tok.Value}
-			p.advance()
+			ref := TableRef{Name: p.parseTableName(), Pos: tok.Position}
+			if p.atPunctuation("(") {
+				call, err := p.parseTableFunction(tok)
+				if err != nil {
+					return nil, err
+				}
+				ref.Name = call.Name
+				ref.Function = call
+			}
 
 			// Check for optional alias
-			if p.currentToken().Type == TokenKeyword && strings.ToUpper(p.currentToken().Value) == "AS" {
+			if strings.EqualFold(p.currentToken().Value, "AS") && !p.atAsOf() {
 				p.advance()
 				aliasTok := p.currentToken()
 				if aliasTok.Type == TokenIdentifier {
@@ -241,7 +694,7 @@ func (p *Parser) parseTableList() ([]TableRef, error) {
 				} else {
 					return nil, NewParseError("expected alias identifier after AS", aliasTok, "provide alias name")
 				}
-			} else if p.currentToken().Type == TokenIdentifier {
+			} else if p.currentToken().Type == TokenIdentifier && !p.atAsOf() {
 				// Implicit alias (e.g., "users u")
 				// Ensure it's not a keyword that might start the next clause (though keywords should be TokenKeyword)
 				ref.Alias = p.currentToken().Value
@@ -263,41 +716,110 @@ func (p *Parser) parseTableList() ([]TableRef, error) {
 	return tables, nil
 }
 
-func (p *Parser) parseExpression() (Expression, error) {
-	return p.parseOrExpression()
-}
-
-func (p *Parser) parseOrExpression() (Expression, error) {
-	left, err := p.parseAndExpression()
-	if err != nil {
-		return nil, err
+// parseTableName parses the table name at the current identifier, which
+// may be qualified with the alias of an attached database.
+func (p *Parser) parseTableName() string {
+	name := p.advance().Value
+	if p.atPunctuation(".") && p.peekToken().Type == TokenIdentifier {
+		p.advance()
+		name += "." + p.advance().Value
 	}
+	return name
+}
 
-	for p.currentToken().Type == TokenKeyword && strings.ToUpper(p.currentToken().Value) == "OR" {
-		p.advance()
-		right, err := p.parseAndExpression()
+// parseTableFunction parses the arguments of a function called in FROM
+// position, such as generate_series(1, 10), whose name was nameTok. It
+// parses scalar function calls in expressions too.
+func (p *Parser) parseTableFunction(nameTok Token) (*FunctionCall, error) {
+	call := &FunctionCall{Name: strings.ToLower(nameTok.Value), Pos: nameTok.Position}
+	p.advance()
+	for !p.atPunctuation(")") {
+		if len(call.Arguments) > 0 {
+			if err := p.expectPunctuation(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpression()
 		if err != nil {
 			return nil, err
 		}
-		left = &BinaryExpression{Left: left, Op: "OR", Right: right}
+		call.Arguments = append(call.Arguments, arg)
 	}
+	p.advance()
+	return call, nil
+}
 
-	return left, nil
+// atAsOf reports whether the parser is at an AS OF clause rather than a
+// table alias.
+func (p *Parser) atAsOf() bool {
+	return strings.EqualFold(p.currentToken().Value, "AS") && strings.EqualFold(p.peekToken().Value, "OF")
 }
 
-func (p *Parser) parseAndExpression() (Expression, error) {
-	left, err := p.parseNotExpression()
-	if err != nil {
-		return nil, err
-	}
+// timestampLayouts are the forms AS OF TIMESTAMP accepts. Times without a
+// zone are local.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// parseAsOf parses AS OF TIMESTAMP '<time>'.
+func (p *Parser) parseAsOf() (time.Time, error) {
+	p.advance()
+	p.advance()
+	if tok := p.currentToken(); !strings.EqualFold(tok.Value, "TIMESTAMP") {
+		return time.Time{}, NewParseError("expected TIMESTAMP after AS OF", tok, "write AS OF TIMESTAMP '2006-01-02 15:04:05'")
+	}
+	p.advance()
+	tok := p.currentToken()
+	if tok.Type != TokenString {
+		return time.Time{}, NewParseError("expected a timestamp string", tok, "write AS OF TIMESTAMP '2006-01-02 15:04:05'")
+	}
+	p.advance()
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, tok.Value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, NewParseError(fmt.Sprintf("invalid timestamp: %s", tok.Value), tok, "use the form '2006-01-02 15:04:05', optionally with fractional seconds and a zone")
+}
+
+func (p *Parser) parseExpression() (Expression, error) {
+	return p.parseOrExpression()
+}
+
+func (p *Parser) parseOrExpression() (Expression, error) {
+	left, err := p.parseAndExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.currentToken().Type == TokenKeyword && strings.ToUpper(p.currentToken().Value) == "OR" {
+		tok := p.advance()
+		right, err := p.parseAndExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpression{Left: left, Op: "OR", Right: right, Pos: tok.Position}
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseAndExpression() (Expression, error) {
+	left, err := p.parseNotExpression()
+	if err != nil {
+		return nil, err
+	}
 
 	for p.currentToken().Type == TokenKeyword && strings.ToUpper(p.currentToken().Value) == "AND" {
-		p.advance()
+		tok := p.advance()
 		right, err := p.parseNotExpression()
 		if err != nil {
 			return nil, err
 		}
-		left = &BinaryExpression{Left: left, Op: "AND", Right: right}
+		left = &BinaryExpression{Left: left, Op: "AND", Right: right, Pos: tok.Position}
 	}
 
 	return left, nil
@@ -305,12 +827,12 @@ func (p *Parser) parseAndExpression() (Expression, error) {
 
 func (p *Parser) parseNotExpression() (Expression, error) {
 	if p.currentToken().Type == TokenKeyword && strings.ToUpper(p.currentToken().Value) == "NOT" {
-		p.advance()
+		tok := p.advance()
 		expr, err := p.parseComparisonExpression()
 		if err != nil {
 			return nil, err
 		}
-		return &UnaryExpression{Op: "NOT", Right: expr}, nil
+		return &UnaryExpression{Op: "NOT", Right: expr, Pos: tok.Position}, nil
 	}
 	return p.parseComparisonExpression()
 }
@@ -329,12 +851,132 @@ func (p *Parser) parseComparisonExpression() (Expression, error) {
 		if err != nil {
 			return nil, err
 		}
-		left = &BinaryExpression{Left: left, Op: op, Right: right}
+		left = &BinaryExpression{Left: left, Op: op, Right: right, Pos: tok.Position}
+	} else if op, ok := p.parseLikeOperator(); ok {
+		right, err := p.parseAdditiveExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpression{Left: left, Op: op, Right: right, Pos: tok.Position}
+	} else if op, ok := p.parseDistinctOperator(); ok {
+		right, err := p.parseAdditiveExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpression{Left: left, Op: op, Right: right, Pos: tok.Position}
+	} else if not, ok := p.parseInOperator(); ok {
+		values, subquery, err := p.parseInList()
+		if err != nil {
+			return nil, err
+		}
+		left = &InExpression{Left: left, Values: values, Subquery: subquery, Not: not, Pos: tok.Position}
 	}
 
 	return left, nil
 }
 
+// parseInOperator consumes IN or NOT IN and reports whether it was NOT IN.
+func (p *Parser) parseInOperator() (not bool, ok bool) {
+	isIn := func(tok Token) bool {
+		return tok.Type == TokenKeyword && strings.EqualFold(tok.Value, "IN")
+	}
+	tok := p.currentToken()
+	if isIn(tok) {
+		p.advance()
+		return false, true
+	}
+	if tok.Type == TokenKeyword && strings.EqualFold(tok.Value, "NOT") && isIn(p.peekToken()) {
+		p.advance()
+		p.advance()
+		return true, true
+	}
+	return false, false
+}
+
+// parseInList parses the parenthesized, comma-separated values after IN,
+// or the SELECT there that gives them.
+func (p *Parser) parseInList() ([]Expression, *SelectStatement, error) {
+	if err := p.expectPunctuation("("); err != nil {
+		return nil, nil, err
+	}
+	if tok := p.currentToken(); tok.Type == TokenKeyword && strings.EqualFold(tok.Value, "SELECT") {
+		subquery, err := p.parseSelect()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := p.expectPunctuation(")"); err != nil {
+			return nil, nil, err
+		}
+		return nil, subquery, nil
+	}
+	var values []Expression
+	for {
+		value, err := p.parseAdditiveExpression()
+		if err != nil {
+			return nil, nil, err
+		}
+		values = append(values, value)
+		if tok := p.currentToken(); tok.Type == TokenPunctuation && tok.Value == "," {
+			p.advance()
+			continue
+		}
+		break
+	}
+	if err := p.expectPunctuation(")"); err != nil {
+		return nil, nil, err
+	}
+	return values, nil, nil
+}
+
+// parseDistinctOperator consumes IS [NOT] DISTINCT FROM and returns it as
+// one operator.
+func (p *Parser) parseDistinctOperator() (string, bool) {
+	word := func(i int, value string) bool {
+		if p.pos+i >= len(p.tokens) {
+			return false
+		}
+		tok := p.tokens[p.pos+i]
+		return tok.Type != TokenString && strings.EqualFold(tok.Value, value)
+	}
+	if !word(0, "IS") {
+		return "", false
+	}
+	op := "IS DISTINCT FROM"
+	n := 3
+	if word(1, "NOT") {
+		op = "IS NOT DISTINCT FROM"
+		n = 4
+	}
+	if !word(n-2, "DISTINCT") || !word(n-1, "FROM") {
+		return "", false
+	}
+	p.pos += n
+	return op, true
+}
+
+// parseLikeOperator consumes [NOT] LIKE, [NOT] ILIKE or [NOT] MATCH and
+// returns it as one operator, e.g. "NOT ILIKE".
+func (p *Parser) parseLikeOperator() (string, bool) {
+	isLike := func(tok Token) bool {
+		if tok.Type != TokenKeyword {
+			return false
+		}
+		kw := strings.ToUpper(tok.Value)
+		return kw == "LIKE" || kw == "ILIKE" || kw == "MATCH"
+	}
+
+	tok := p.currentToken()
+	if isLike(tok) {
+		p.advance()
+		return strings.ToUpper(tok.Value), true
+	}
+	if tok.Type == TokenKeyword && strings.ToUpper(tok.Value) == "NOT" && isLike(p.peekToken()) {
+		p.advance()
+		return "NOT " + strings.ToUpper(p.advance().Value), true
+	}
+	return "", false
+}
+
 func (p *Parser) parseAdditiveExpression() (Expression, error) {
 	left, err := p.parseMultiplicativeExpression()
 	if err != nil {
@@ -350,7 +992,7 @@ func (p *Parser) parseAdditiveExpression() (Expression, error) {
 			if err != nil {
 				return nil, err
 			}
-			left = &BinaryExpression{Left: left, Op: op, Right: right}
+			left = &BinaryExpression{Left: left, Op: op, Right: right, Pos: tok.Position}
 		} else {
 			break
 		}
@@ -374,7 +1016,7 @@ func (p *Parser) parseMultiplicativeExpression() (Expression, error) {
 			if err != nil {
 				return nil, err
 			}
-			left = &BinaryExpression{Left: left, Op: op, Right: right}
+			left = &BinaryExpression{Left: left, Op: op, Right: right, Pos: tok.Position}
 		} else {
 			break
 		}
@@ -386,10 +1028,31 @@ func (p *Parser) parseMultiplicativeExpression() (Expression, error) {
 func (p *Parser) parsePrimaryExpression() (Expression, error) {
 	tok := p.currentToken()
 
+	fn, ok, err := p.parseSystemFunction()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return fn, nil
+	}
+
 	switch tok.Type {
 	case TokenIdentifier:
+		if aggregateFunctions[strings.ToUpper(tok.Value)] && p.peekToken().Value == "(" {
+			return p.parseAggregate()
+		}
 		p.advance()
-		colRef := &ColumnRef{Column: tok.Value}
+		if _, ok := scalarFunctions[strings.ToLower(tok.Value)]; ok && p.atPunctuation("(") {
+			return p.parseTableFunction(tok)
+		}
+		if strings.EqualFold(tok.Value, "CAST") && p.atPunctuation("(") {
+			return p.parseCast(tok)
+		}
+		if value := p.currentToken(); strings.EqualFold(tok.Value, "INTERVAL") && value.Type == TokenString {
+			p.advance()
+			return &IntervalLiteral{Value: value.Value, Pos: tok.Position}, nil
+		}
+		colRef := &ColumnRef{Column: tok.Value, Pos: tok.Position}
 
 		if p.currentToken().Value == "." {
 			p.advance()
@@ -405,7 +1068,22 @@ func (p *Parser) parsePrimaryExpression() (Expression, error) {
 
 	case TokenLiteral, TokenString:
 		p.advance()
-		return &LiteralExpression{Value: tok.Value}, nil
+		return &LiteralExpression{Value: tok.Value, Quoted: tok.Type == TokenString}, nil
+
+	case TokenParameter:
+		p.advance()
+		if tok.Value == "?" {
+			p.params++
+			return &Parameter{Index: p.params}, nil
+		}
+		index, err := strconv.Atoi(tok.Value[1:])
+		if err != nil || index < 1 {
+			return nil, NewParseError(fmt.Sprintf("invalid parameter: %s", tok.Value), tok, "parameters are numbered from $1")
+		}
+		if index > p.params {
+			p.params = index
+		}
+		return &Parameter{Index: index}, nil
 
 	case TokenKeyword:
 		if strings.ToUpper(tok.Value) == "NULL" {
@@ -455,10 +1133,18 @@ func (p *Parser) parseJoin() (*JoinClause, error) {
 	if tableTok.Type != TokenIdentifier {
 		return nil, NewParseError("expected table name", tableTok, "provide a valid table name")
 	}
-	join.Table = tableTok.Value
-	p.advance()
+	join.Table = p.parseTableName()
+	join.Pos = tableTok.Position
+	if p.atPunctuation("(") {
+		call, err := p.parseTableFunction(tableTok)
+		if err != nil {
+			return nil, err
+		}
+		join.Table = call.Name
+		join.Function = call
+	}
 
-	if p.currentToken().Type == TokenKeyword && strings.ToUpper(p.currentToken().Value) == "AS" {
+	if strings.EqualFold(p.currentToken().Value, "AS") {
 		p.advance()
 		aliasTok := p.currentToken()
 		if aliasTok.Type != TokenIdentifier {
@@ -503,11 +1189,21 @@ func (p *Parser) parseOrderBy() ([]OrderByClause, error) {
 			return nil, NewParseError("expected column name", colTok, "provide valid column for ORDER BY")
 		}
 
-		ob := OrderByClause{
-			Column: colTok.Value,
-			Asc:    true,
+		ob := OrderByClause{Asc: true, Pos: colTok.Position}
+		if p.peekToken().Type == TokenPunctuation && p.peekToken().Value == "(" {
+			// An aggregate from the SELECT list, e.g. ORDER BY COUNT(*) DESC.
+			call, err := p.parseAggregate()
+			if err != nil {
+				return nil, err
+			}
+			ob.Column = call.String()
+		} else {
+			name, err := p.parseQualifiedName()
+			if err != nil {
+				return nil, err
+			}
+			ob.Column = name
 		}
-		p.advance()
 
 		nextTok := p.currentToken()
 		if nextTok.Type == TokenKeyword {
@@ -562,6 +1258,7 @@ func (p *Parser) parseInsert() (*InsertStatement, error) {
 		return nil, NewParseError("expected table name", tableTok, "provide a valid table name")
 	}
 	stmt.Table = tableTok.Value
+	stmt.TablePos = tableTok.Position
 	p.advance()
 
 	if p.currentToken().Value == "(" {
@@ -614,22 +1311,16 @@ func (p *Parser) parseValuesList() ([][]Expression, error) {
 	valuesList := make([][]Expression, 0)
 
 	for {
-		if err := p.expectPunctuation("("); err != nil {
-			return nil, err
-		}
-
-		exprs, err := p.parseExpressionList()
+		start := p.pos
+		exprs, err := p.parseValuesRow()
 		if err != nil {
-			return nil, err
-		}
-
-		if err := p.expectPunctuation(")"); err != nil {
-			return nil, err
+			// Skip this row and check the next one.
+			p.recover(err, start, ",")
+		} else {
+			valuesList = append(valuesList, exprs)
 		}
 
-		valuesList = append(valuesList, exprs)
-
-		if p.currentToken().Value != "," {
+		if !p.atPunctuation(",") {
 			break
 		}
 		p.advance()
@@ -638,6 +1329,20 @@ func (p *Parser) parseValuesList() ([][]Expression, error) {
 	return valuesList, nil
 }
 
+func (p *Parser) parseValuesRow() ([]Expression, error) {
+	if err := p.expectPunctuation("("); err != nil {
+		return nil, err
+	}
+	exprs, err := p.parseExpressionList()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectPunctuation(")"); err != nil {
+		return nil, err
+	}
+	return exprs, nil
+}
+
 func (p *Parser) parseExpressionList() ([]Expression, error) {
 	exprs := make([]Expression, 0)
 
@@ -669,6 +1374,7 @@ func (p *Parser) parseUpdate() (*UpdateStatement, error) {
 		return nil, NewParseError("expected table name", tableTok, "provide a valid table name")
 	}
 	stmt.Table = tableTok.Value
+	stmt.TablePos = tableTok.Position
 	p.advance()
 
 	if err := p.expectKeyword("SET"); err != nil {
@@ -742,6 +1448,7 @@ func (p *Parser) parseDelete() (*DeleteStatement, error) {
 		return nil, NewParseError("expected table name", tableTok, "provide a valid table name")
 	}
 	stmt.Table = tableTok.Value
+	stmt.TablePos = tableTok.Position
 	p.advance()
 
 	if p.currentToken().Type == TokenKeyword && strings.ToUpper(p.currentToken().Value) == "WHERE" {
@@ -763,6 +1470,15 @@ func (p *Parser) parseCreateTable() (*CreateTableStatement, error) {
 		return nil, err
 	}
 
+	external := strings.EqualFold(p.currentToken().Value, "EXTERNAL")
+	if external {
+		p.advance()
+	}
+	if word := p.currentToken().Value; !external && (strings.EqualFold(word, "TEMP") || strings.EqualFold(word, "TEMPORARY")) {
+		stmt.Temporary = true
+		p.advance()
+	}
+
 	if err := p.expectKeyword("TABLE"); err != nil {
 		return nil, err
 	}
@@ -774,6 +1490,10 @@ func (p *Parser) parseCreateTable() (*CreateTableStatement, error) {
 	stmt.Table = tableTok.Value
 	p.advance()
 
+	if external {
+		return p.parseExternalTable(stmt)
+	}
+
 	if err := p.expectPunctuation("("); err != nil {
 		return nil, err
 	}
@@ -783,80 +1503,91 @@ func (p *Parser) parseCreateTable() (*CreateTableStatement, error) {
 		return nil, err
 	}
 	stmt.Columns = columns
+	for _, col := range columns {
+		if col.References != nil {
+			stmt.ForeignKeys = append(stmt.ForeignKeys, *col.References)
+		}
+	}
 
 	if err := p.expectPunctuation(")"); err != nil {
 		return nil, err
 	}
 
+	if stmt.Temporary && strings.EqualFold(p.currentToken().Value, "ON") {
+		if err := p.parseOnCommit(stmt); err != nil {
+			return nil, err
+		}
+	}
+
 	return stmt, nil
 }
 
-func (p *Parser) parseColumnDefinitions() ([]ColumnDefinition, error) {
-	columns := make([]ColumnDefinition, 0)
-
-	for {
-		colTok := p.currentToken()
-		if colTok.Type != TokenIdentifier {
-			return nil, NewParseError("expected column name", colTok, "provide valid column name")
-		}
-
-		col := ColumnDefinition{Name: colTok.Value}
+// parseOnCommit parses a temporary table's ON COMMIT PRESERVE ROWS, the
+// default, ON COMMIT DELETE ROWS or ON COMMIT DROP.
+func (p *Parser) parseOnCommit(stmt *CreateTableStatement) error {
+	p.advance()
+	if err := p.expectKeyword("COMMIT"); err != nil {
+		return err
+	}
+	tok := p.currentToken()
+	rows := strings.EqualFold(p.peekToken().Value, "ROWS")
+	switch {
+	case strings.EqualFold(tok.Value, "DROP"):
+		stmt.OnCommit = "DROP"
 		p.advance()
+	case strings.EqualFold(tok.Value, "DELETE") && rows:
+		stmt.OnCommit = "DELETE ROWS"
+		p.pos += 2
+	case strings.EqualFold(tok.Value, "PRESERVE") && rows:
+		p.pos += 2
+	default:
+		return NewParseError("expected PRESERVE ROWS, DELETE ROWS or DROP after ON COMMIT", tok, "use ON COMMIT DROP to drop the table at COMMIT")
+	}
+	return nil
+}
 
-		typeTok := p.currentToken()
-		if typeTok.Type != TokenKeyword && typeTok.Type != TokenIdentifier {
-			return nil, NewParseError("expected column type", typeTok, "specify INTEGER, TEXT, FLOAT, or BOOLEAN")
-		}
-		col.Type = strings.ToUpper(typeTok.Value)
+// parseExternalTable parses the rest of CREATE EXTERNAL TABLE name: an
+// optional column list and LOCATION 'file.csv'.
+func (p *Parser) parseExternalTable(stmt *CreateTableStatement) (*CreateTableStatement, error) {
+	if p.atPunctuation("(") {
 		p.advance()
+		columns, err := p.parseColumnDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Columns = columns
+		if err := p.expectPunctuation(")"); err != nil {
+			return nil, err
+		}
+	}
 
-		for {
-			tok := p.currentToken()
-			if tok.Type == TokenPunctuation && tok.Value == ")" {
-				break
-			}
-			if tok.Type == TokenPunctuation && tok.Value == "," {
-				break
-			}
+	if tok := p.currentToken(); !strings.EqualFold(tok.Value, "LOCATION") {
+		return nil, NewParseError("expected LOCATION", tok, "name the CSV file with LOCATION 'file.csv'")
+	}
+	p.advance()
+	tok := p.currentToken()
+	if tok.Type != TokenString {
+		return nil, NewParseError("expected a file path", tok, "name the CSV file with LOCATION 'file.csv'")
+	}
+	stmt.Location = tok.Value
+	p.advance()
+	return stmt, nil
+}
 
-			if tok.Type == TokenKeyword {
-				keyword := strings.ToUpper(tok.Value)
-				switch keyword {
-				case "PRIMARY":
-					p.advance()
-					if strings.ToUpper(p.currentToken().Value) != "KEY" {
-						return nil, NewParseError("expected KEY after PRIMARY", p.currentToken(), "use PRIMARY KEY")
-					}
-					p.advance()
-					col.Primary = true
-				case "UNIQUE":
-					p.advance()
-					col.Unique = true
-				case "NOT":
-					p.advance()
-					if strings.ToUpper(p.currentToken().Value) != "NULL" {
-						return nil, NewParseError("expected NULL after NOT", p.currentToken(), "use NOT NULL")
-					}
-					p.advance()
-					col.NotNull = true
-				case "DEFAULT":
-					p.advance()
-					expr, err := p.parsePrimaryExpression()
-					if err != nil {
-						return nil, err
-					}
-					col.Default = &expr
-				default:
-					break
-				}
-			} else {
-				break
-			}
-		}
+func (p *Parser) parseColumnDefinitions() ([]ColumnDefinition, error) {
+	columns := make([]ColumnDefinition, 0)
 
-		columns = append(columns, col)
+	for {
+		start := p.pos
+		col, err := p.parseColumnDefinition()
+		if err != nil {
+			// Skip this column and check the next one.
+			p.recover(err, start, ",", ")")
+		} else {
+			columns = append(columns, col)
+		}
 
-		if p.currentToken().Value != "," {
+		if !p.atPunctuation(",") {
 			break
 		}
 		p.advance()
@@ -865,20 +1596,121 @@ func (p *Parser) parseColumnDefinitions() ([]ColumnDefinition, error) {
 	return columns, nil
 }
 
-func (p *Parser) parseDropTable() (*DropTableStatement, error) {
-	stmt := &DropTableStatement{}
+func (p *Parser) parseColumnDefinition() (ColumnDefinition, error) {
+	colTok := p.currentToken()
+	if colTok.Type != TokenIdentifier {
+		return ColumnDefinition{}, NewParseError("expected column name", colTok, "provide valid column name")
+	}
 
-	if err := p.expectKeyword("DROP"); err != nil {
-		return nil, err
+	col := ColumnDefinition{Name: colTok.Value}
+	p.advance()
+
+	typeTok := p.currentToken()
+	if typeTok.Type != TokenKeyword && typeTok.Type != TokenIdentifier {
+		return col, NewParseError("expected column type", typeTok, "specify INTEGER, TEXT, FLOAT, or BOOLEAN")
 	}
+	col.Type = strings.ToUpper(typeTok.Value)
+	p.advance()
 
-	if err := p.expectKeyword("TABLE"); err != nil {
-		return nil, err
+	for {
+		tok := p.currentToken()
+		if tok.Type == TokenEOF || p.atPunctuation(")") || p.atPunctuation(",") {
+			return col, nil
+		}
+		if tok.Type != TokenKeyword {
+			return col, NewParseError(fmt.Sprintf("unexpected %s in column definition", tok.Value), tok,
+				"use PRIMARY KEY, UNIQUE, NOT NULL, DEFAULT or REFERENCES")
+		}
+
+		switch strings.ToUpper(tok.Value) {
+		case "PRIMARY":
+			p.advance()
+			if strings.ToUpper(p.currentToken().Value) != "KEY" {
+				return col, NewParseError("expected KEY after PRIMARY", p.currentToken(), "use PRIMARY KEY")
+			}
+			p.advance()
+			col.Primary = true
+		case "UNIQUE":
+			p.advance()
+			col.Unique = true
+			if strings.EqualFold(p.currentToken().Value, "NULLS") {
+				p.advance()
+				col.NullsNotDistinct = strings.EqualFold(p.currentToken().Value, "NOT")
+				if col.NullsNotDistinct {
+					p.advance()
+				}
+				if tok := p.currentToken(); !strings.EqualFold(tok.Value, "DISTINCT") {
+					return col, NewParseError("expected DISTINCT", tok, "use UNIQUE NULLS DISTINCT or UNIQUE NULLS NOT DISTINCT")
+				}
+				p.advance()
+			}
+		case "NOT":
+			p.advance()
+			if strings.ToUpper(p.currentToken().Value) != "NULL" {
+				return col, NewParseError("expected NULL after NOT", p.currentToken(), "use NOT NULL")
+			}
+			p.advance()
+			col.NotNull = true
+		case "DEFAULT":
+			p.advance()
+			expr, err := p.parsePrimaryExpression()
+			if err != nil {
+				return col, err
+			}
+			col.Default = &expr
+		case "REFERENCES":
+			p.advance()
+			fk, err := p.parseReferences(col.Name)
+			if err != nil {
+				return col, err
+			}
+			col.References = fk
+		default:
+			return col, NewParseError(fmt.Sprintf("unexpected keyword %s in column definition", tok.Value), tok,
+				"use PRIMARY KEY, UNIQUE, NOT NULL, DEFAULT or REFERENCES")
+		}
 	}
+}
 
+// parseReferences parses the rest of a column's REFERENCES table
+// [(column)] constraint. Without a column it refers to the table's primary
+// key.
+func (p *Parser) parseReferences(column string) (*ForeignKeyDefinition, error) {
 	tableTok := p.currentToken()
 	if tableTok.Type != TokenIdentifier {
-		return nil, NewParseError("expected table name", tableTok, "provide a valid table name")
+		return nil, NewParseError("expected table name", tableTok, "use REFERENCES table (column)")
+	}
+	p.advance()
+	fk := &ForeignKeyDefinition{Columns: []string{column}, RefTable: tableTok.Value}
+	if p.atPunctuation("(") {
+		p.advance()
+		colTok := p.currentToken()
+		if colTok.Type != TokenIdentifier {
+			return nil, NewParseError("expected column name", colTok, "use REFERENCES table (column)")
+		}
+		p.advance()
+		fk.RefColumns = []string{colTok.Value}
+		if err := p.expectPunctuation(")"); err != nil {
+			return nil, err
+		}
+	}
+	return fk, nil
+}
+
+func (p *Parser) parseDropTable() (*DropTableStatement, error) {
+	stmt := &DropTableStatement{}
+
+	if err := p.expectKeyword("DROP"); err != nil {
+		return nil, err
+	}
+
+	if err := p.expectKeyword("TABLE"); err != nil {
+		return nil, err
+	}
+
+	tableTok := p.currentToken()
+	if tableTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected table name", tableTok, "provide a valid table name")
 	}
 	stmt.Table = tableTok.Value
 	p.advance()
@@ -886,6 +1718,236 @@ func (p *Parser) parseDropTable() (*DropTableStatement, error) {
 	return stmt, nil
 }
 
+// parseAlterTable parses ALTER TABLE table ALTER [COLUMN] column [SET
+// DATA] TYPE type [USING expression].
+func (p *Parser) parseAlterTable() (*AlterTableStatement, error) {
+	p.advance() // ALTER
+	if err := p.expectKeyword("TABLE"); err != nil {
+		return nil, err
+	}
+	tableTok := p.currentToken()
+	if tableTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected table name", tableTok, "use ALTER TABLE name ALTER COLUMN column TYPE type")
+	}
+	p.advance()
+	stmt := &AlterTableStatement{Table: tableTok.Value, TablePos: tableTok.Position}
+
+	if err := p.expectKeyword("ALTER"); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(p.currentToken().Value, "COLUMN") {
+		p.advance()
+	}
+	colTok := p.currentToken()
+	if colTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected column name", colTok, "use ALTER COLUMN column TYPE type")
+	}
+	stmt.Column = colTok.Value
+	p.advance()
+
+	if strings.EqualFold(p.currentToken().Value, "SET") && strings.EqualFold(p.peekToken().Value, "DATA") {
+		p.pos += 2
+	}
+	if tok := p.currentToken(); tok.Type != TokenIdentifier || !strings.EqualFold(tok.Value, "TYPE") {
+		return nil, NewParseError("expected TYPE", tok, "ALTER COLUMN can only change a column's type")
+	}
+	p.advance()
+	typeTok := p.currentToken()
+	if typeTok.Type != TokenKeyword && typeTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected column type", typeTok, "specify INTEGER, TEXT, FLOAT, or BOOLEAN")
+	}
+	stmt.DataType = strings.ToUpper(typeTok.Value)
+	p.advance()
+
+	if tok := p.currentToken(); tok.Type == TokenIdentifier && strings.EqualFold(tok.Value, "USING") {
+		p.advance()
+		using, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Using = using
+	}
+	return stmt, nil
+}
+
+// parseCast parses CAST(expression AS type) after the CAST.
+func (p *Parser) parseCast(castTok Token) (*CastExpression, error) {
+	p.advance() // (
+	expr, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.currentToken(); tok.Type != TokenIdentifier || !strings.EqualFold(tok.Value, "AS") {
+		return nil, NewParseError("expected AS", tok, "use CAST(expression AS type)")
+	}
+	p.advance()
+	typeTok := p.currentToken()
+	if typeTok.Type != TokenKeyword && typeTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected type", typeTok, "use CAST(expression AS type)")
+	}
+	p.advance()
+	if err := p.expectPunctuation(")"); err != nil {
+		return nil, err
+	}
+	return &CastExpression{Expr: expr, Type: strings.ToUpper(typeTok.Value), Pos: castTok.Position}, nil
+}
+
+// parseCreateIndex parses CREATE INDEX [CONCURRENTLY] name ON table
+// (column) [INCLUDE (column, ...)] [WHERE condition].
+func (p *Parser) parseCreateIndex() (*CreateIndexStatement, error) {
+	p.pos += 2 // CREATE INDEX
+	concurrently := false
+	if strings.EqualFold(p.currentToken().Value, "CONCURRENTLY") && !strings.EqualFold(p.peekToken().Value, "ON") {
+		concurrently = true
+		p.advance()
+	}
+	nameTok := p.currentToken()
+	if nameTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected index name", nameTok, "use CREATE INDEX name ON table (column)")
+	}
+	p.advance()
+	if err := p.expectKeyword("ON"); err != nil {
+		return nil, err
+	}
+
+	tableTok := p.currentToken()
+	if tableTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected table name", tableTok, "provide a valid table name")
+	}
+	p.advance()
+	if err := p.expectPunctuation("("); err != nil {
+		return nil, err
+	}
+	colTok := p.currentToken()
+	if colTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected column name", colTok, "indexes cover a single column")
+	}
+	p.advance()
+	if err := p.expectPunctuation(")"); err != nil {
+		return nil, err
+	}
+
+	stmt := &CreateIndexStatement{Name: nameTok.Value, Table: tableTok.Value, TablePos: tableTok.Position, Column: colTok.Value,
+		Concurrently: concurrently}
+	if strings.EqualFold(p.currentToken().Value, "INCLUDE") {
+		p.advance()
+		if err := p.expectPunctuation("("); err != nil {
+			return nil, err
+		}
+		for {
+			tok := p.currentToken()
+			if tok.Type != TokenIdentifier {
+				return nil, NewParseError("expected column name", tok, "use INCLUDE (column, ...)")
+			}
+			p.advance()
+			stmt.Include = append(stmt.Include, tok.Value)
+			if !p.atPunctuation(",") {
+				break
+			}
+			p.advance()
+		}
+		if err := p.expectPunctuation(")"); err != nil {
+			return nil, err
+		}
+	}
+	if tok := p.currentToken(); tok.Type == TokenKeyword && strings.EqualFold(tok.Value, "WHERE") {
+		p.advance()
+		where, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Where = where
+	}
+	return stmt, nil
+}
+
+// parseCreateTextIndex parses CREATE FULLTEXT INDEX or CREATE TRIGRAM
+// INDEX, as given by kind, followed by [name] ON table (column). The name
+// defaults to table_column_idx.
+func (p *Parser) parseCreateTextIndex(kind string) (*CreateIndexStatement, error) {
+	p.pos += 2 // CREATE FULLTEXT or TRIGRAM
+	if tok := p.currentToken(); !strings.EqualFold(tok.Value, "INDEX") {
+		return nil, NewParseError("expected INDEX", tok, fmt.Sprintf("use CREATE %s INDEX [name] ON table (column)", kind))
+	}
+	p.advance()
+	var name string
+	if nameTok := p.currentToken(); nameTok.Type == TokenIdentifier {
+		name = nameTok.Value
+		p.advance()
+	}
+	if err := p.expectKeyword("ON"); err != nil {
+		return nil, err
+	}
+	tableTok := p.currentToken()
+	if tableTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected table name", tableTok, "provide a valid table name")
+	}
+	p.advance()
+	if err := p.expectPunctuation("("); err != nil {
+		return nil, err
+	}
+	colTok := p.currentToken()
+	if colTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected column name", colTok, fmt.Sprintf("%s indexes cover a single TEXT column", kind))
+	}
+	p.advance()
+	if err := p.expectPunctuation(")"); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = tableTok.Value + "_" + colTok.Value + "_idx"
+	}
+	return &CreateIndexStatement{Name: name, Table: tableTok.Value, TablePos: tableTok.Position, Column: colTok.Value,
+		FullText: kind == "FULLTEXT", Trigram: kind == "TRIGRAM"}, nil
+}
+
+// parseCreateUser parses CREATE USER name [WITH] PASSWORD 'secret'.
+func (p *Parser) parseCreateUser() (*CreateUserStatement, error) {
+	if err := p.expectKeyword("CREATE"); err != nil {
+		return nil, err
+	}
+	p.advance() // USER
+
+	nameTok := p.currentToken()
+	if nameTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected user name", nameTok, "provide a valid user name")
+	}
+	p.advance()
+
+	if strings.EqualFold(p.currentToken().Value, "WITH") {
+		p.advance()
+	}
+
+	tok := p.currentToken()
+	if !strings.EqualFold(tok.Value, "PASSWORD") {
+		return nil, NewParseError("expected PASSWORD", tok, "use CREATE USER name WITH PASSWORD 'secret'")
+	}
+	p.advance()
+
+	passwordTok := p.currentToken()
+	if passwordTok.Type != TokenString {
+		return nil, NewParseError("expected password string", passwordTok, "quote the password with single quotes")
+	}
+	p.advance()
+
+	return &CreateUserStatement{Name: nameTok.Value, Password: passwordTok.Value}, nil
+}
+
+func (p *Parser) parseDropUser() (*DropUserStatement, error) {
+	if err := p.expectKeyword("DROP"); err != nil {
+		return nil, err
+	}
+	p.advance() // USER
+
+	nameTok := p.currentToken()
+	if nameTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected user name", nameTok, "provide a valid user name")
+	}
+	p.advance()
+
+	return &DropUserStatement{Name: nameTok.Value}, nil
+}
+
 func (p *Parser) parseBeginTransaction() (*BeginTransactionStatement, error) {
 	if err := p.expectKeyword("BEGIN"); err != nil {
 		return nil, err
@@ -897,3 +1959,221 @@ func (p *Parser) parseBeginTransaction() (*BeginTransactionStatement, error) {
 
 	return &BeginTransactionStatement{}, nil
 }
+
+// parseTransactionID parses the quoted identifier of a prepared
+// transaction following the current token, the last word of stmt.
+func (p *Parser) parseTransactionID(stmt string) (string, error) {
+	p.advance()
+	tok := p.currentToken()
+	if tok.Type != TokenString || tok.Value == "" {
+		return "", NewParseError("expected transaction identifier", tok, fmt.Sprintf("use %s 'id'", stmt))
+	}
+	p.advance()
+	return tok.Value, nil
+}
+
+func (p *Parser) parseChannel() (string, error) {
+	tok := p.currentToken()
+	if tok.Type != TokenIdentifier {
+		return "", NewParseError("expected channel name", tok, "provide a valid channel name")
+	}
+	p.advance()
+	return tok.Value, nil
+}
+
+func (p *Parser) parseListen() (*ListenStatement, error) {
+	if err := p.expectKeyword("LISTEN"); err != nil {
+		return nil, err
+	}
+
+	channel, err := p.parseChannel()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListenStatement{Channel: channel}, nil
+}
+
+func (p *Parser) parseUnlisten() (*UnlistenStatement, error) {
+	if err := p.expectKeyword("UNLISTEN"); err != nil {
+		return nil, err
+	}
+
+	if p.currentToken().Value == "*" {
+		p.advance()
+		return &UnlistenStatement{Channel: "*"}, nil
+	}
+
+	channel, err := p.parseChannel()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UnlistenStatement{Channel: channel}, nil
+}
+
+func (p *Parser) parseNotify() (*NotifyStatement, error) {
+	if err := p.expectKeyword("NOTIFY"); err != nil {
+		return nil, err
+	}
+
+	channel, err := p.parseChannel()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &NotifyStatement{Channel: channel}
+
+	if p.currentToken().Value == "," {
+		p.advance()
+		payload, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Payload = payload
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseSet() (*SetStatement, error) {
+	if err := p.expectKeyword("SET"); err != nil {
+		return nil, err
+	}
+
+	nameTok := p.currentToken()
+	if nameTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected setting name", nameTok, "provide a valid setting name")
+	}
+	p.advance()
+
+	tok := p.currentToken()
+	if tok.Value == "=" || strings.EqualFold(tok.Value, "TO") {
+		p.advance()
+	} else {
+		return nil, NewParseError("expected '=' or TO", tok, "use SET name = value")
+	}
+
+	valueTok := p.currentToken()
+	switch valueTok.Type {
+	case TokenIdentifier, TokenKeyword, TokenString, TokenLiteral:
+		p.advance()
+	default:
+		return nil, NewParseError("expected setting value", valueTok, "provide a value for the setting")
+	}
+
+	return &SetStatement{Name: strings.ToLower(nameTok.Value), Value: valueTok.Value}, nil
+}
+
+// parseSetConstraints parses SET CONSTRAINTS ALL DEFERRED | IMMEDIATE.
+func (p *Parser) parseSetConstraints() (*SetConstraintsStatement, error) {
+	p.pos += 2 // SET CONSTRAINTS
+	if tok := p.currentToken(); !strings.EqualFold(tok.Value, "ALL") {
+		return nil, NewParseError("expected ALL", tok, "use SET CONSTRAINTS ALL DEFERRED or SET CONSTRAINTS ALL IMMEDIATE")
+	}
+	p.advance()
+
+	tok := p.currentToken()
+	stmt := &SetConstraintsStatement{Deferred: strings.EqualFold(tok.Value, "DEFERRED")}
+	if !stmt.Deferred && !strings.EqualFold(tok.Value, "IMMEDIATE") {
+		return nil, NewParseError("expected DEFERRED or IMMEDIATE", tok, "use SET CONSTRAINTS ALL DEFERRED or SET CONSTRAINTS ALL IMMEDIATE")
+	}
+	p.advance()
+	return stmt, nil
+}
+
+func (p *Parser) parseShow() (*ShowStatement, error) {
+	if err := p.expectKeyword("SHOW"); err != nil {
+		return nil, err
+	}
+
+	tok := p.currentToken()
+	if tok.Type != TokenIdentifier {
+		return nil, NewParseError("expected setting name", tok, "use SHOW name or SHOW ALL")
+	}
+	p.advance()
+
+	return &ShowStatement{Name: strings.ToLower(tok.Value)}, nil
+}
+
+func (p *Parser) parseBackup() (*BackupStatement, error) {
+	if err := p.expectKeyword("BACKUP"); err != nil {
+		return nil, err
+	}
+
+	tok := p.currentToken()
+	if !strings.EqualFold(tok.Value, "TO") {
+		return nil, NewParseError("expected TO", tok, "use BACKUP TO 'path'")
+	}
+	p.advance()
+
+	pathTok := p.currentToken()
+	if pathTok.Type != TokenString || pathTok.Value == "" {
+		return nil, NewParseError("expected backup file path", pathTok, "quote the path with single quotes")
+	}
+	p.advance()
+
+	return &BackupStatement{Path: pathTok.Value}, nil
+}
+
+// parseAttach parses ATTACH 'path' AS alias.
+func (p *Parser) parseAttach() (*AttachStatement, error) {
+	if err := p.expectKeyword("ATTACH"); err != nil {
+		return nil, err
+	}
+
+	pathTok := p.currentToken()
+	if pathTok.Type != TokenString || pathTok.Value == "" {
+		return nil, NewParseError("expected database file path", pathTok, "quote the path with single quotes")
+	}
+	p.advance()
+
+	if tok := p.currentToken(); !strings.EqualFold(tok.Value, "AS") {
+		return nil, NewParseError("expected AS", tok, "use ATTACH 'path' AS alias")
+	}
+	p.advance()
+
+	aliasTok := p.currentToken()
+	if aliasTok.Type != TokenIdentifier {
+		return nil, NewParseError("expected database alias", aliasTok, "use ATTACH 'path' AS alias")
+	}
+	p.advance()
+
+	return &AttachStatement{Path: pathTok.Value, Alias: aliasTok.Value}, nil
+}
+
+func (p *Parser) parseExplain() (*ExplainStatement, error) {
+	if err := p.expectKeyword("EXPLAIN"); err != nil {
+		return nil, err
+	}
+
+	stmt := &ExplainStatement{Format: "TEXT"}
+	if p.atPunctuation("(") {
+		p.advance()
+		if tok := p.currentToken(); !strings.EqualFold(tok.Value, "FORMAT") {
+			return nil, NewParseError("expected FORMAT", tok, "use EXPLAIN (FORMAT TEXT) or EXPLAIN (FORMAT DOT)")
+		}
+		p.advance()
+		tok := p.currentToken()
+		format := strings.ToUpper(tok.Value)
+		if tok.Type != TokenIdentifier || format != "TEXT" && format != "DOT" {
+			return nil, NewParseError(fmt.Sprintf("unknown EXPLAIN format: %s", tok.Value), tok, "use FORMAT TEXT or FORMAT DOT")
+		}
+		p.advance()
+		if err := p.expectPunctuation(")"); err != nil {
+			return nil, err
+		}
+		stmt.Format = format
+	}
+	if strings.EqualFold(p.currentToken().Value, "ANALYZE") {
+		p.advance()
+		stmt.Analyze = true
+	}
+
+	inner, err := p.parseStatement()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Statement = inner
+	return stmt, nil
+}
@@ -0,0 +1,375 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// PlanNode is one operator in the plan the executor follows for a
+// statement. Its children produce the rows it consumes; for a join the
+// first child is the outer (left) input.
+type PlanNode struct {
+	Operator string // e.g. "Seq Scan", "Nested Loop", "Filter"
+	Detail   string // e.g. "on users", "LEFT JOIN tasks ON ...", "id > 5"
+	Table    string // the table a Seq Scan reads
+	Rows     int64  // estimated rows out, from ANALYZE statistics; 0 if none
+	Children []*PlanNode
+}
+
+func (n *PlanNode) String() string {
+	if n.Detail == "" {
+		return n.Operator
+	}
+	if strings.HasPrefix(n.Detail, "on ") || isJoin(n) {
+		return n.Operator + " " + n.Detail
+	}
+	return n.Operator + ": " + n.Detail
+}
+
+// isJoin reports whether n joins its two children.
+func isJoin(n *PlanNode) bool {
+	return n.Operator == "Nested Loop" || n.Operator == "Hash Join"
+}
+
+// BuildPlan returns the plan tree for stmt. It reflects what the executor
+// does today: every table is read with a sequential scan, except that a
+// bare COUNT(*) reads the table's row count, and joins are nested loops,
+// or hash joins when a hint asks for one, taken in the order they are
+// written.
+func BuildPlan(stmt Node) *PlanNode {
+	switch s := stmt.(type) {
+	case *SelectStatement:
+		return selectPlan(s)
+	case *InsertStatement:
+		return &PlanNode{Operator: "Insert", Detail: fmt.Sprintf("on %s (%d row(s))", s.Table, len(s.Values))}
+	case *UpdateStatement:
+		return &PlanNode{Operator: "Update", Detail: "on " + s.Table, Children: []*PlanNode{filtered(scan(s.Table), s.Where)}}
+	case *DeleteStatement:
+		return &PlanNode{Operator: "Delete", Detail: "on " + s.Table, Children: []*PlanNode{filtered(scan(s.Table), s.Where)}}
+	case *ExplainStatement:
+		return BuildPlan(s.Statement)
+	default:
+		return &PlanNode{Operator: stmt.Type().String()}
+	}
+}
+
+func selectPlan(s *SelectStatement) *PlanNode {
+	if len(s.Compound) > 0 {
+		return compoundPlan(s)
+	}
+	if countOnly(s) {
+		plan := &PlanNode{Operator: "Table Count", Detail: "on " + s.Tables[0].String(), Table: s.Tables[0].Name}
+		plan = wrap("Project", strings.Join(s.ColumnNames(), ", "), plan)
+		return limited(plan, s)
+	}
+
+	plan := &PlanNode{Operator: "Result"}
+	if len(s.Tables) > 0 {
+		plan = scanRef(s.Tables[0])
+	}
+	for _, join := range s.Joins {
+		joinType := join.Type
+		if joinType == "JOIN" {
+			joinType = "INNER"
+		}
+		target := join.Table
+		if join.Function != nil {
+			target = join.Function.String()
+		}
+		detail := fmt.Sprintf("%s JOIN %s", joinType, target)
+		if len(join.Conditions) > 0 {
+			conds := make([]string, len(join.Conditions))
+			for i, cond := range join.Conditions {
+				conds[i] = cond.String()
+			}
+			detail += " ON " + strings.Join(conds, " AND ")
+		}
+		inner := scanRef(join.Ref())
+		operator := "Nested Loop"
+		if _, key, ok := hashJoinKeys(s, join); ok {
+			operator = "Hash Join"
+			inner = wrap("Hash", key.String(), inner)
+		}
+		plan = &PlanNode{Operator: operator, Detail: detail, Children: []*PlanNode{plan, inner}}
+	}
+	plan = filtered(plan, s.Where)
+
+	if s.IsAggregate() {
+		detail := ""
+		if len(s.Aggregates) > 0 {
+			calls := make([]string, len(s.Aggregates))
+			for i, call := range s.Aggregates {
+				calls[i] = call.String()
+			}
+			detail = strings.Join(calls, ", ")
+		}
+		if len(s.GroupBy) > 0 {
+			if detail != "" {
+				detail += " "
+			}
+			detail += "group by " + strings.Join(s.GroupBy, ", ")
+		}
+		if s.Having != nil {
+			if detail != "" {
+				detail += " "
+			}
+			detail += "having " + s.Having.String()
+		}
+		plan = wrap("Aggregate", detail, plan)
+	}
+	plan = sorted(plan, s.OrderBy)
+	plan = wrap("Project", strings.Join(s.ColumnNames(), ", "), plan)
+	if s.Distinct {
+		plan = wrap("Distinct", "", plan)
+	}
+	return limited(plan, s)
+}
+
+func sorted(plan *PlanNode, orderBy []OrderByClause) *PlanNode {
+	if len(orderBy) == 0 {
+		return plan
+	}
+	keys := make([]string, len(orderBy))
+	for i, ob := range orderBy {
+		keys[i] = ob.String()
+	}
+	return wrap("Sort", strings.Join(keys, ", "), plan)
+}
+
+func limited(plan *PlanNode, s *SelectStatement) *PlanNode {
+	if s.Limit == nil {
+		return plan
+	}
+	offset := 0
+	if s.Offset != nil {
+		offset = *s.Offset
+	}
+	return wrap("Limit", fmt.Sprintf("%d offset %d", *s.Limit, offset), plan)
+}
+
+func scan(table string) *PlanNode {
+	return &PlanNode{Operator: "Seq Scan", Detail: "on " + table, Table: table}
+}
+
+// scanRef reads a FROM or JOIN item: a Seq Scan of a table, or a Function
+// Scan of a table function's rows.
+func scanRef(ref TableRef) *PlanNode {
+	if ref.Function != nil {
+		return &PlanNode{Operator: "Function Scan", Detail: "on " + ref.String()}
+	}
+	plan := scan(ref.Name)
+	plan.Detail = "on " + ref.String()
+	return plan
+}
+
+func filtered(plan *PlanNode, where Expression) *PlanNode {
+	if where == nil {
+		return plan
+	}
+	return wrap("Filter", where.String(), plan)
+}
+
+// wrap puts a new operator on top of child; a nil child (SELECT without
+// FROM) leaves the operator as a leaf.
+func wrap(operator, detail string, child *PlanNode) *PlanNode {
+	node := &PlanNode{Operator: operator, Detail: detail}
+	if child != nil {
+		node.Children = []*PlanNode{child}
+	}
+	return node
+}
+
+// describePlan renders the steps the executor takes for stmt in execution
+// order, e.g. "Seq Scan on users -> Filter: id > 5 -> Project: name". Only
+// the outer input of each join is followed; the join step names the inner
+// table.
+func describePlan(stmt Node) string {
+	steps := make([]string, 0)
+	for n := BuildPlan(stmt); n != nil; {
+		steps = append(steps, n.String())
+		if len(n.Children) == 0 {
+			break
+		}
+		n = n.Children[0]
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return strings.Join(steps, " -> ")
+}
+
+// Tree renders the plan as an indented tree, root first, in the style of
+// PostgreSQL's EXPLAIN:
+//
+//	Project: name
+//	  ->  Filter: id > 5
+//	        ->  Seq Scan on users
+func (n *PlanNode) Tree() []string {
+	lines := []string{n.label()}
+	var walk func(n *PlanNode, indent string)
+	walk = func(n *PlanNode, indent string) {
+		for _, child := range n.Children {
+			lines = append(lines, indent+"->  "+child.label())
+			walk(child, indent+"      ")
+		}
+	}
+	walk(n, "  ")
+	return lines
+}
+
+// label is the node's line in EXPLAIN, with its row estimate if it has
+// one: "Seq Scan on users  (rows=100)".
+func (n *PlanNode) label() string {
+	if n.Rows == 0 {
+		return n.String()
+	}
+	return fmt.Sprintf("%s  (rows=%d)", n.String(), n.Rows)
+}
+
+// Dot renders the plan as a Graphviz digraph, with edges pointing from
+// each operator to the one consuming its rows.
+func (n *PlanNode) Dot() []string {
+	lines := []string{"digraph plan {", "  rankdir=BT;", "  node [shape=box, fontname=\"Helvetica\"];"}
+	id := 0
+	var walk func(n *PlanNode) string
+	walk = func(n *PlanNode) string {
+		name := fmt.Sprintf("n%d", id)
+		id++
+		label := n.Operator
+		if n.Detail != "" {
+			label += "\\n" + n.Detail
+		}
+		if n.Rows > 0 {
+			label += fmt.Sprintf("\\nrows=%d", n.Rows)
+		}
+		lines = append(lines, fmt.Sprintf("  %s [label=%s];", name, dotQuote(label)))
+		for _, child := range n.Children {
+			lines = append(lines, fmt.Sprintf("  %s -> %s;", walk(child), name))
+		}
+		return name
+	}
+	walk(n)
+	return append(lines, "}")
+}
+
+// dotQuote quotes s as a DOT string, keeping the \n line breaks in labels.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func (e *Executor) executeExplain(stmt *ExplainStatement) (*Result, error) {
+	if _, ok := stmt.Statement.(*ExplainStatement); ok {
+		return nil, fmt.Errorf("EXPLAIN cannot explain another EXPLAIN")
+	}
+	plan := BuildPlan(stmt.Statement)
+	for _, table := range planTables(plan) {
+		if _, err := e.lookupTable(table); err != nil {
+			return nil, err
+		}
+	}
+	if s, ok := stmt.Statement.(*SelectStatement); ok && len(s.Compound) > 0 {
+		plans := operandPlans(plan)
+		for i, operand := range compoundOperands(s) {
+			restore, err := e.useHints(operand)
+			if err != nil {
+				return nil, err
+			}
+			e.markIndexScan(plans[i], operand)
+			restore()
+			e.estimateRows(plans[i], operand)
+		}
+	} else {
+		if ok {
+			restore, err := e.useHints(s)
+			if err != nil {
+				return nil, err
+			}
+			e.markIndexScan(plan, s)
+			restore()
+		}
+		e.estimateRows(plan, stmt.Statement)
+	}
+
+	var report []string
+	if stmt.Analyze {
+		result, err := e.execute(stmt.Statement)
+		if err != nil {
+			return nil, err
+		}
+		rows := len(result.Rows)
+		if result.RowsAffected > 0 {
+			rows = result.RowsAffected
+		}
+		report = e.memoryReport(rows)
+	}
+
+	var lines []string
+	switch stmt.Format {
+	case "DOT":
+		lines = plan.Dot()
+		for _, line := range report {
+			lines = append(lines, "// "+line)
+		}
+	default:
+		lines = append(plan.Tree(), report...)
+	}
+	result := &Result{Columns: []string{"QUERY PLAN"}}
+	for _, line := range lines {
+		result.Rows = append(result.Rows, []string{line})
+		result.Values = append(result.Values, []storage.Value{storage.NewTextValue(line)})
+	}
+	return result, nil
+}
+
+// markIndexScan turns the scan of the first table into an Index Scan when
+// the executor will read it through an index (see likeIndexRange,
+// fullTextIndex, trigramIndex and secondaryIndexRange), or an Index Only Scan when the index covers the
+// query. The hints in force must be s's (see useHints).
+func (e *Executor) markIndexScan(plan *PlanNode, s *SelectStatement) {
+	leaf := plan
+	for len(leaf.Children) > 0 {
+		leaf = leaf.Children[0]
+	}
+	if leaf.Operator != "Seq Scan" || len(s.Tables) == 0 || s.AsOf != nil {
+		return
+	}
+	table, err := e.lookupTable(s.Tables[0].Name)
+	if err != nil {
+		return
+	}
+	name := s.Tables[0].RefName()
+	if column, start, _, ok := e.likeIndexRange(s.Where, table, name, len(s.Joins) > 0); ok {
+		leaf.Operator = "Index Scan"
+		leaf.Detail += fmt.Sprintf(" using %s (prefix '%s')", column, start.ToString())
+	} else if index, terms, ok := e.fullTextIndex(s.Where, table, name, len(s.Joins) > 0); ok {
+		leaf.Operator = "Index Scan"
+		leaf.Detail += fmt.Sprintf(" using %s (%s MATCH '%s')", index.Name, index.Column, strings.Join(terms, " "))
+	} else if index, text, ok := e.trigramIndex(s.Where, table, name, len(s.Joins) > 0); ok {
+		leaf.Operator = "Index Scan"
+		leaf.Detail += fmt.Sprintf(" using %s (%s %% '%s')", index.Name, index.Column, text)
+	} else if index, start, _, ok := e.secondaryIndexRange(s.Where, table, name, len(s.Joins) > 0); ok {
+		leaf.Operator = "Index Scan"
+		if columns, ok := queryColumns(s, table, name); ok && index.Covers(columns) {
+			leaf.Operator = "Index Only Scan"
+		}
+		leaf.Detail += " using " + index.Name
+		if start != nil {
+			leaf.Detail += fmt.Sprintf(" (%s = %s)", index.Column, start.ToString())
+		}
+	}
+}
+
+// planTables returns the tables the plan scans.
+func planTables(n *PlanNode) []string {
+	var tables []string
+	if n.Table != "" {
+		tables = append(tables, n.Table)
+	}
+	for _, child := range n.Children {
+		tables = append(tables, planTables(child)...)
+	}
+	return tables
+}
@@ -0,0 +1,99 @@
+package sql
+
+import (
+	"container/list"
+	"sync"
+)
+
+// The plan cache keeps parsed statements by their SQL text, so a statement
+// run again, as the webapp runs the same queries on every page load, skips
+// lexing and parsing. The executor chooses scans and joins as it runs, so
+// the parsed statement is the whole of the plan. Statements are never
+// modified after parsing, which lets every session share one entry; they
+// do not depend on the schema, so DDL invalidates nothing. Only statements
+// that parse are cached, and not those longer than maxCachedQueryLen, such
+// as bulk INSERTs: they seldom run twice and would keep large ASTs alive.
+
+const defaultPlanCacheSize = 512
+
+const maxCachedQueryLen = 64 << 10
+
+type planCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // of *cachedPlan, most recently used first
+	entries map[string]*list.Element
+}
+
+type cachedPlan struct {
+	query      string
+	stmt       Node
+	paramCount int
+	hits       int64
+}
+
+var plans = &planCache{size: defaultPlanCacheSize, order: list.New(), entries: make(map[string]*list.Element)}
+
+// SetPlanCacheSize sets how many statements the plan cache keeps, evicting
+// the least recently used beyond it; 0 disables the cache.
+func SetPlanCacheSize(n int) {
+	plans.mu.Lock()
+	defer plans.mu.Unlock()
+	plans.size = n
+	plans.evict()
+}
+
+// PlanCacheSize returns how many statements the plan cache keeps.
+func PlanCacheSize() int {
+	plans.mu.Lock()
+	defer plans.mu.Unlock()
+	return plans.size
+}
+
+func (c *planCache) get(query string) (*cachedPlan, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[query]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	plan := elem.Value.(*cachedPlan)
+	plan.hits++
+	return plan, true
+}
+
+func (c *planCache) put(query string, stmt Node, paramCount int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 || len(query) > maxCachedQueryLen {
+		return
+	}
+	if elem, ok := c.entries[query]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[query] = c.order.PushFront(&cachedPlan{query: query, stmt: stmt, paramCount: paramCount})
+	c.evict()
+}
+
+// evict drops the least recently used statements beyond the cache's size.
+func (c *planCache) evict() {
+	for c.order.Len() > c.size && c.order.Len() > 0 {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedPlan).query)
+	}
+}
+
+// snapshot returns copies of the cached statements, most recently used
+// first.
+func (c *planCache) snapshot() []cachedPlan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := make([]cachedPlan, 0, c.order.Len())
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		cached = append(cached, *elem.Value.(*cachedPlan))
+	}
+	return cached
+}
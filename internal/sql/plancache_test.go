@@ -0,0 +1,69 @@
+package sql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+func TestPlanCache(t *testing.T) {
+	defer sql.SetPlanCacheSize(sql.PlanCacheSize())
+	sql.SetPlanCacheSize(0)
+	sql.SetPlanCacheSize(2)
+	parse := func(query string) (sql.Node, int) {
+		t.Helper()
+		stmt, params, err := sql.ParseContext(context.Background(), query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return stmt, params
+	}
+
+	const lookup = "SELECT name FROM users WHERE id = ?"
+	first, _ := parse(lookup)
+	again, params := parse(lookup)
+	if first != again || params != 1 {
+		t.Fatalf("second parse of %q was not served from the cache", lookup)
+	}
+	if _, _, err := sql.ParseContext(context.Background(), "SELECT FROM"); err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	// The cache keeps two statements: a third evicts the least recently
+	// used one, which the read of lookup above made the other.
+	parse("SELECT * FROM users")
+	parse(lookup)
+	parse("DELETE FROM users WHERE id = $1")
+	if stmt, _ := parse(lookup); stmt != first {
+		t.Fatalf("%q was evicted instead of the least recently used statement", lookup)
+	}
+	parse("SELECT * FROM users")
+
+	session := sql.NewSession(storage.NewDatabase())
+	defer session.Close()
+	result, err := execSQL(session, "SELECT query, operation, params, hits FROM rdbms_plan_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"SELECT * FROM users", "SELECT", "0", "0"},
+		{lookup, "SELECT", "1", "3"},
+	}
+	if len(result.Rows) != len(want) {
+		t.Fatalf("rdbms_plan_cache = %v, want %v", result.Rows, want)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if result.Rows[i][j] != want[i][j] {
+				t.Fatalf("rdbms_plan_cache = %v, want %v", result.Rows, want)
+			}
+		}
+	}
+
+	sql.SetPlanCacheSize(0)
+	if a, _ := parse(lookup); a == first {
+		t.Fatal("disabling the cache kept its statements")
+	}
+}
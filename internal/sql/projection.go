@@ -0,0 +1,105 @@
+package sql
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// projectColumns resolves the SELECT list to output column names and the
+// row index each reads. * expands to every column of every table and t.* to
+// the columns of t alone, in table order. When the query joins tables,
+// expanded columns are qualified with their table or alias ("u.id",
+// "t.id"), so columns the tables share stay distinguishable. A computed
+// column, such as price * quantity or VERSION(), reads no single column:
+// it gets index -1 and its expression is returned in exprs.
+func (e *Executor) projectColumns(stmt *SelectStatement, tables map[string]*storage.Table, offsets map[string]int) (names []string, indexes []int, exprs []Expression, err error) {
+	qualify := len(stmt.Joins) > 0
+	expand := func(name string, names []string, indexes []int) ([]string, []int) {
+		for i, col := range tables[name].Schema().Columns {
+			if qualify {
+				names = append(names, name+"."+col.Name)
+			} else {
+				names = append(names, col.Name)
+			}
+			indexes = append(indexes, offsets[name]+i)
+		}
+		return names, indexes
+	}
+
+	for _, col := range stmt.Columns {
+		switch expr := col.Expr.(type) {
+		case nil:
+			if col.Name == "*" {
+				for _, name := range tablesInOrder(tables, offsets) {
+					names, indexes = expand(name, names, indexes)
+				}
+				break
+			}
+			name := strings.TrimSuffix(col.Name, ".*")
+			if _, ok := tables[name]; !ok {
+				err := errorf(ErrTableNotFound, "table %s is not in the FROM clause", name)
+				return nil, nil, nil, positioned(err, col.Pos, col.Name, "use a table name or alias from the FROM clause")
+			}
+			names, indexes = expand(name, names, indexes)
+		case *ColumnRef:
+			idx, err := e.resolveColumnIndex(expr, tables, offsets)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			names = append(names, col.Name)
+			indexes = append(indexes, idx)
+		default:
+			names = append(names, col.Name)
+			indexes = append(indexes, -1)
+		}
+		for len(exprs) < len(names) {
+			exprs = append(exprs, col.computed())
+		}
+	}
+	return names, indexes, exprs, nil
+}
+
+// projectRow computes the values of the SELECT list for row, given the
+// indexes and exprs projectColumns returned.
+func (e *Executor) projectRow(row *storage.Row, indexes []int, exprs []Expression, tables map[string]*storage.Table, offsets map[string]int) ([]storage.Value, error) {
+	values := make([]storage.Value, len(indexes))
+	for j, idx := range indexes {
+		val, _ := row.Get(idx)
+		if exprs[j] != nil {
+			var err error
+			if val, err = e.evaluateExpressionForJoinedRow(exprs[j], row, tables, offsets); err != nil {
+				return nil, err
+			}
+		}
+		values[j] = val
+	}
+	return values, nil
+}
+
+// computed returns the expression the item computes, such as
+// price * quantity or UPPER(name), or nil for a star, a table column or
+// an aggregate call.
+func (i SelectItem) computed() Expression {
+	switch expr := i.Expr.(type) {
+	case nil, *ColumnRef:
+		return nil
+	case *FunctionCall:
+		if aggregateFunctions[expr.Name] {
+			return nil
+		}
+	}
+	return i.Expr
+}
+
+// tablesInOrder returns the names the query's tables are known by, in the
+// order their columns appear in joined rows.
+func tablesInOrder(tables map[string]*storage.Table, offsets map[string]int) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return offsets[names[i]] < offsets[names[j]] })
+	return names
+}
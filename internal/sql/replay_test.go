@@ -0,0 +1,109 @@
+package sql_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// TestIndexesReplayed checks that a replica built from the WAL and a
+// restored backup have the primary's indexes, and use them alike.
+func TestIndexesReplayed(t *testing.T) {
+	db := storage.NewDatabase()
+	session := sql.NewSession(db)
+	defer session.Close()
+	for _, text := range []string{
+		"CREATE TABLE tasks (id INTEGER PRIMARY KEY, status TEXT, title TEXT, body TEXT)",
+		"INSERT INTO tasks (id, status, title, body) VALUES (1, 'pending', 'it''s due', 'write the report'), (2, 'done', 'old', 'read the paper')",
+		"CREATE INDEX tasks_status ON tasks (status) INCLUDE (title)",
+		"CREATE INDEX tasks_due ON tasks (id) WHERE status = 'pending' AND title != 'it''s late'",
+		"CREATE FULLTEXT INDEX tasks_body ON tasks (body)",
+		"CREATE TRIGRAM INDEX tasks_title ON tasks (title)",
+		"CREATE INDEX dropped ON tasks (title)",
+		"DROP INDEX dropped",
+		// Rolled back, so neither logged nor kept.
+		"BEGIN",
+		"CREATE INDEX rolled_back ON tasks (body)",
+		"DROP INDEX tasks_title",
+		"ROLLBACK",
+	} {
+		if _, err := execSQL(session, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+
+	replica := storage.NewDatabase()
+	for _, entry := range db.WAL().Since(0) {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var received storage.WALEntry
+		if err := json.Unmarshal(data, &received); err != nil {
+			t.Fatal(err)
+		}
+		if err := replica.ApplyWALEntry(received); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := db.Backup(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := storage.NewDatabase()
+	if _, err := restored.RestoreBackup(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	indexes := func(db *storage.Database) string {
+		table, err := db.GetTable("tasks")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, idx := range table.SecondaryIndexes() {
+			out = append(out, strings.Join([]string{idx.Name, idx.Kind(), idx.Column, strings.Join(idx.Include, ","), idx.Predicate}, "|"))
+		}
+		return strings.Join(out, "\n")
+	}
+	explain := func(session *sql.Session, query string) string {
+		result, err := execSQL(session, "EXPLAIN "+query)
+		if err != nil {
+			t.Fatalf("EXPLAIN %s: %v", query, err)
+		}
+		var lines []string
+		for _, row := range result.Rows {
+			lines = append(lines, row[0])
+		}
+		return strings.Join(lines, "\n")
+	}
+	queries := []string{
+		"SELECT title FROM tasks WHERE status = 'pending'",
+		"SELECT id FROM tasks WHERE status = 'pending' AND title != 'it''s late'",
+	}
+
+	want := indexes(db)
+	for name, copy := range map[string]*storage.Database{"replica": replica, "restored": restored} {
+		if got := indexes(copy); got != want {
+			t.Errorf("%s: indexes\n%s\nwant\n%s", name, got, want)
+		}
+		copySession := sql.NewSession(copy)
+		for _, query := range queries {
+			if got, want := explain(copySession, query), explain(session, query); got != want {
+				t.Errorf("%s: EXPLAIN %s:\n%s\nwant\n%s", name, query, got, want)
+			}
+		}
+		result, err := execSQL(copySession, "SELECT id FROM tasks WHERE status = 'pending' AND title != 'it''s late'")
+		if err != nil || len(result.Rows) != 1 || result.Rows[0][0] != "1" {
+			t.Errorf("%s: partial index query = %v, %v", name, result, err)
+		}
+		if _, err := execSQL(copySession, "DROP INDEX tasks_status"); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		copySession.Close()
+	}
+}
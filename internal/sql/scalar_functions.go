@@ -0,0 +1,69 @@
+package sql
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// scalarFunctions compute a value from the evaluated arguments of a call
+// in an expression, such as similarity(name, 'jon') in WHERE. The parser
+// reads a call only for a name listed here or an aggregate (for HAVING);
+// any other name followed by ( is a syntax error, as before.
+var scalarFunctions = map[string]func(args []storage.Value) (storage.Value, error){
+	"date_trunc": dateTrunc,
+	"similarity": similarityFunction,
+	"upper":      textFunction("upper", strings.ToUpper),
+	"lower":      textFunction("lower", strings.ToLower),
+	"length":     lengthFunction,
+}
+
+// textFunction is a function of one TEXT argument returning TEXT, such as
+// upper; NULL gives NULL.
+func textFunction(name string, fn func(string) string) func(args []storage.Value) (storage.Value, error) {
+	return func(args []storage.Value) (storage.Value, error) {
+		if len(args) != 1 {
+			return nil, errorf(ErrParameter, "%s takes 1 argument, got %d", name, len(args))
+		}
+		if args[0].Type() == storage.TypeNull {
+			return storage.NullValue{}, nil
+		}
+		if args[0].Type() != storage.TypeText {
+			return nil, errorf(ErrTypeMismatch, "%s takes TEXT, got %s", name, args[0].Type())
+		}
+		return storage.NewTextValue(fn(args[0].ToString())), nil
+	}
+}
+
+// lengthFunction returns the number of characters in a TEXT value.
+func lengthFunction(args []storage.Value) (storage.Value, error) {
+	if len(args) != 1 {
+		return nil, errorf(ErrParameter, "length takes 1 argument, got %d", len(args))
+	}
+	if args[0].Type() == storage.TypeNull {
+		return storage.NullValue{}, nil
+	}
+	if args[0].Type() != storage.TypeText {
+		return nil, errorf(ErrTypeMismatch, "length takes TEXT, got %s", args[0].Type())
+	}
+	return storage.NewIntegerValue(int64(utf8.RuneCountInString(args[0].ToString()))), nil
+}
+
+// misplacedAggregate is the error for an aggregate called where rows are
+// read one at a time, as in WHERE.
+func misplacedAggregate(call *FunctionCall) error {
+	err := errorf(ErrGrouping, "aggregate function %s is not allowed here", call.Name)
+	return positioned(err, call.Pos, call.String(), "filter groups with HAVING instead of WHERE")
+}
+
+// callScalar calls the scalar function of call with args.
+func callScalar(call *FunctionCall, args []storage.Value) (storage.Value, error) {
+	fn, ok := scalarFunctions[call.Name]
+	if !ok {
+		err := errorf(ErrUnsupported, "unknown function: %s", call.Name)
+		return nil, positioned(err, call.Pos, call.String(), "")
+	}
+	v, err := fn(args)
+	return v, positioned(err, call.Pos, call.String(), "")
+}
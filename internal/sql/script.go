@@ -0,0 +1,61 @@
+package sql
+
+import (
+	"context"
+	"errors"
+)
+
+// ScriptOptions controls how ExecuteScript handles a failing statement.
+type ScriptOptions struct {
+	// ContinueOnError runs the statements after one that fails instead of
+	// stopping at it.
+	ContinueOnError bool
+}
+
+// ExecuteScript parses script as semicolon-separated statements and runs
+// them in order, each committed on its own as Execute commits it, and
+// returns a result for each. Nothing runs unless the whole script parses.
+// The first statement to fail stops the script with a *BatchError; the
+// statements before it stay done.
+func (e *Executor) ExecuteScript(script string) ([]*Result, error) {
+	return e.ExecuteScriptContext(context.Background(), script, ScriptOptions{})
+}
+
+// ExecuteScriptContext is ExecuteScript with a context and options.
+func (e *Executor) ExecuteScriptContext(ctx context.Context, script string, opts ScriptOptions) ([]*Result, error) {
+	return runScript(script, opts, func(stmt Node) (*Result, error) {
+		return e.run(ctx, stmt, nil)
+	})
+}
+
+// ExecuteScript runs script as Executor.ExecuteScript does, through the
+// session, so it may also hold transaction control and session statements
+// such as BEGIN and SET.
+func (s *Session) ExecuteScript(ctx context.Context, script string, opts ScriptOptions) ([]*Result, error) {
+	return runScript(script, opts, func(stmt Node) (*Result, error) {
+		return s.ExecuteContext(ctx, stmt, nil)
+	})
+}
+
+// runScript parses script and runs each statement with exec. With
+// ContinueOnError, a failed statement's result is nil and the error joins
+// a *BatchError for each failure.
+func runScript(script string, opts ScriptOptions, exec func(Node) (*Result, error)) ([]*Result, error) {
+	stmts, err := NewParser(NewLexer(script)).ParseAll()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Result, 0, len(stmts))
+	var errs []error
+	for i, stmt := range stmts {
+		result, err := exec(stmt)
+		if err != nil {
+			errs = append(errs, &BatchError{Index: i, Err: err})
+			if !opts.ContinueOnError {
+				return results, errs[0]
+			}
+		}
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,327 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+var defaultSettings = map[string]string{
+	"application_name":  "",
+	"on_conflict":       "abort",
+	"sql_mode":          "permissive",
+	"statement_timeout": "0",
+	"trace":             "off",
+	"work_mem":          "unlimited",
+}
+
+type PreparedStatement struct {
+	Name       string
+	Statement  Node
+	ParamCount int
+}
+
+// Session holds the state of a single client connection: its open
+// transaction, prepared statements, settings and LISTEN subscriptions.
+// A Session is not safe for concurrent use; give each connection its own.
+type Session struct {
+	db       *storage.Database
+	exec     *Executor
+	prepared map[string]*PreparedStatement
+	settings map[string]string
+}
+
+func NewSession(db *storage.Database) *Session {
+	settings := make(map[string]string, len(defaultSettings))
+	for name, value := range defaultSettings {
+		settings[name] = value
+	}
+
+	exec := NewExecutor(db)
+	if exec.trace {
+		settings["trace"] = "on"
+	}
+
+	return &Session{
+		db:       db,
+		exec:     exec,
+		prepared: make(map[string]*PreparedStatement),
+		settings: settings,
+	}
+}
+
+func (s *Session) Execute(stmt Node) (*Result, error) {
+	return s.ExecuteWithParams(stmt, nil)
+}
+
+func (s *Session) ExecuteWithParams(stmt Node, params []storage.Value) (*Result, error) {
+	return s.ExecuteContext(context.Background(), stmt, params)
+}
+
+// ExecuteContext runs stmt in the session's transaction. Outside an
+// explicit transaction each statement is atomic on its own; inside one, a
+// failing statement is undone but the transaction stays open.
+func (s *Session) ExecuteContext(ctx context.Context, stmt Node, params []storage.Value) (*Result, error) {
+	switch st := stmt.(type) {
+	case *BeginTransactionStatement:
+		if s.exec.tx != nil {
+			return nil, errorf(ErrTransaction, "transaction already in progress")
+		}
+		s.exec.tx = s.db.Begin()
+		return &Result{Message: st.String()}, nil
+	case *CommitStatement:
+		if s.exec.tx == nil {
+			return nil, errorf(ErrTransaction, "no transaction in progress")
+		}
+		tx := s.exec.tx
+		s.exec.tx = nil
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return &Result{Message: st.String()}, nil
+	case *RollbackStatement:
+		if s.exec.tx == nil {
+			return nil, errorf(ErrTransaction, "no transaction in progress")
+		}
+		tx := s.exec.tx
+		s.exec.tx = nil
+		if err := tx.Rollback(); err != nil {
+			return nil, err
+		}
+		return &Result{Message: st.String()}, nil
+	case *PrepareTransactionStatement:
+		if s.exec.tx == nil {
+			return nil, errorf(ErrTransaction, "no transaction in progress")
+		}
+		tx := s.exec.tx
+		s.exec.tx = nil
+		if err := tx.Prepare(st.GID, s.exec.user); err != nil {
+			return nil, err
+		}
+		return &Result{Message: st.String()}, nil
+	case *CommitPreparedStatement, *RollbackPreparedStatement:
+		if s.exec.tx != nil {
+			return nil, errorf(ErrTransaction, "%s cannot run inside a transaction block", st.Type())
+		}
+	case *SetStatement:
+		if _, ok := parseTraceSetting(st.Value); st.Name == "trace" && !ok {
+			return nil, errorf(ErrParameter, "invalid value for trace: %s (expected on or off)", st.Value)
+		}
+		if _, ok := parseWorkMem(st.Value); st.Name == "work_mem" && !ok {
+			return nil, errorf(ErrParameter, "invalid value for work_mem: %s (expected a size such as '4MB', or unlimited)", st.Value)
+		}
+		if _, ok := parseStatementTimeout(st.Value); st.Name == "statement_timeout" && !ok {
+			return nil, errorf(ErrParameter, "invalid value for statement_timeout: %s (expected milliseconds or a duration such as '5s', or 0)", st.Value)
+		}
+		if _, err := storage.ParseConflictPolicy(st.Value); st.Name == "on_conflict" && err != nil {
+			return nil, errorf(ErrParameter, "invalid value for on_conflict: %s (expected skip, replace or abort)", st.Value)
+		}
+		if _, ok := parseSQLMode(st.Value); st.Name == "sql_mode" && !ok {
+			return nil, errorf(ErrParameter, "invalid value for sql_mode: %s (expected strict or permissive)", st.Value)
+		}
+		s.Set(st.Name, st.Value)
+		return &Result{Message: "SET"}, nil
+	case *ShowStatement:
+		return s.show(st.Name)
+	case *SetConstraintsStatement:
+		if s.exec.tx == nil {
+			return nil, errorf(ErrTransaction, "SET CONSTRAINTS can only be used in a transaction")
+		}
+		if err := s.exec.tx.SetDeferred(st.Deferred); err != nil {
+			return nil, err
+		}
+		return &Result{Message: st.String()}, nil
+	}
+
+	if s.exec.tx != nil {
+		savepoint := s.exec.tx.Savepoint()
+		result, err := s.exec.run(ctx, stmt, params)
+		if errors.Is(err, ErrInternal) {
+			// The statement stopped part way through, so nothing it
+			// touched can be trusted: abort the whole transaction.
+			tx := s.exec.tx
+			s.exec.tx = nil
+			tx.Rollback()
+			return nil, fmt.Errorf("%w (transaction rolled back)", err)
+		}
+		if err != nil {
+			s.exec.tx.RollbackTo(savepoint)
+			return nil, err
+		}
+		return result, nil
+	}
+
+	tx := s.db.Begin()
+	s.exec.tx = tx
+	result, err := s.exec.run(ctx, stmt, params)
+	s.exec.tx = nil
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetUser records who is connected, for the audit log.
+func (s *Session) SetUser(user string) {
+	s.exec.SetUser(user)
+}
+
+// SetProgress sets a function called as multi-row INSERTs proceed; see
+// Executor.SetProgress.
+func (s *Session) SetProgress(fn func(Progress)) {
+	s.exec.SetProgress(fn)
+}
+
+// SetRowFunc sets a function that receives the rows of a SELECT as they
+// are found; see Executor.SetRowFunc.
+func (s *Session) SetRowFunc(fn func(columns []string, row []storage.Value) error) {
+	s.exec.SetRowFunc(fn)
+}
+
+func (s *Session) InTransaction() bool {
+	return s.exec.tx != nil
+}
+
+// Prepare parses query and stores it under name for ExecutePrepared.
+func (s *Session) Prepare(name, query string) (*PreparedStatement, error) {
+	if _, exists := s.prepared[name]; exists {
+		return nil, errorf(ErrPreparedStmt, "prepared statement %s already exists", name)
+	}
+
+	parser := NewParser(NewLexer(query))
+	stmt, err := parser.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	prepared := &PreparedStatement{Name: name, Statement: stmt, ParamCount: parser.ParamCount()}
+	s.prepared[name] = prepared
+	return prepared, nil
+}
+
+func (s *Session) ExecutePrepared(name string, params []storage.Value) (*Result, error) {
+	prepared, exists := s.prepared[name]
+	if !exists {
+		return nil, errorf(ErrPreparedStmt, "prepared statement %s not found", name)
+	}
+	if len(params) != prepared.ParamCount {
+		return nil, errorf(ErrParameter, "prepared statement %s expects %d parameter(s), got %d",
+			name, prepared.ParamCount, len(params))
+	}
+
+	return s.ExecuteWithParams(prepared.Statement, params)
+}
+
+func (s *Session) Deallocate(name string) error {
+	if _, exists := s.prepared[name]; !exists {
+		return errorf(ErrPreparedStmt, "prepared statement %s not found", name)
+	}
+	delete(s.prepared, name)
+	return nil
+}
+
+func (s *Session) Set(name, value string) {
+	if name == "trace" {
+		if on, ok := parseTraceSetting(value); ok {
+			s.exec.SetTracing(on)
+			value = "off"
+			if on {
+				value = "on"
+			}
+		}
+	}
+	if name == "work_mem" {
+		if limit, ok := parseWorkMem(value); ok {
+			s.exec.SetWorkMem(limit)
+		}
+	}
+	if name == "statement_timeout" {
+		if timeout, ok := parseStatementTimeout(value); ok {
+			s.exec.SetStatementTimeout(timeout)
+		}
+	}
+	if name == "on_conflict" {
+		if policy, err := storage.ParseConflictPolicy(value); err == nil {
+			s.exec.SetConflictPolicy(policy)
+			value = policy.String()
+		}
+	}
+	if name == "sql_mode" {
+		if strict, ok := parseSQLMode(value); ok {
+			s.exec.SetStrict(strict)
+			value = "permissive"
+			if strict {
+				value = "strict"
+			}
+		}
+	}
+	s.settings[name] = value
+}
+
+// MemoryUsage returns the peak memory, in bytes, held by the session's last
+// statement and by its largest one.
+func (s *Session) MemoryUsage() (last, peak int64) {
+	return s.exec.MemoryUsage()
+}
+
+func (s *Session) Setting(name string) (string, bool) {
+	value, exists := s.settings[name]
+	return value, exists
+}
+
+func (s *Session) show(name string) (*Result, error) {
+	if name == "all" {
+		names := make([]string, 0, len(s.settings))
+		for n := range s.settings {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		result := &Result{Columns: []string{"name", "setting"}}
+		for _, n := range names {
+			result.Rows = append(result.Rows, []string{n, s.settings[n]})
+			result.Values = append(result.Values, []storage.Value{
+				storage.NewTextValue(n), storage.NewTextValue(s.settings[n]),
+			})
+		}
+		return result, nil
+	}
+
+	value, exists := s.settings[name]
+	if !exists {
+		return nil, fmt.Errorf("unrecognized setting %s", name)
+	}
+
+	return &Result{
+		Columns: []string{name},
+		Rows:    [][]string{{value}},
+		Values:  [][]storage.Value{{storage.NewTextValue(value)}},
+	}, nil
+}
+
+// Notifications drains notifications received on channels this session is
+// listening on.
+func (s *Session) Notifications() []storage.Notification {
+	return s.exec.Notifications()
+}
+
+// Close rolls back any open transaction, drops the session's temporary
+// tables and stops listening for notifications.
+func (s *Session) Close() {
+	if s.exec.tx != nil {
+		s.exec.tx.Rollback()
+		s.exec.tx = nil
+	}
+	s.exec.temp = nil
+	if s.exec.listener != nil {
+		s.exec.listener.Close()
+		s.exec.listener = nil
+	}
+}
@@ -0,0 +1,163 @@
+package sql
+
+import (
+	"strings"
+)
+
+// A compound SELECT runs each of its SELECTs in turn and combines their
+// rows left to right, as SQLite does: INTERSECT does not bind tighter than
+// UNION and EXCEPT, as it does in PostgreSQL. Rows are compared as
+// DISTINCT compares them, NULLs being equal. UNION, INTERSECT and EXCEPT
+// return each row once; UNION ALL keeps every row, INTERSECT ALL a row as
+// many times as both sides have it, and EXCEPT ALL as many more times as
+// the left side has it. The columns take the first SELECT's names, which
+// the ORDER BY must use.
+
+// compoundOperands returns the SELECTs stmt combines: stmt itself without
+// what applies to the combined rows, then the others.
+func compoundOperands(stmt *SelectStatement) []*SelectStatement {
+	first := *stmt
+	first.Compound, first.OrderBy, first.Limit, first.Offset = nil, nil, nil, nil
+	operands := []*SelectStatement{&first}
+	for _, op := range stmt.Compound {
+		operands = append(operands, op.Select)
+	}
+	return operands
+}
+
+// executeCompound runs the compound SELECT stmt.
+func (e *Executor) executeCompound(stmt *SelectStatement) (*Result, error) {
+	operands := compoundOperands(stmt)
+	result, err := e.executeSelect(operands[0])
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range stmt.Compound {
+		right, err := e.executeSelect(operands[i+1])
+		if err != nil {
+			return nil, err
+		}
+		if len(right.Columns) != len(result.Columns) {
+			err := errorf(ErrSyntax, "each %s query must have the same number of columns, got %d and %d", op.Op, len(result.Columns), len(right.Columns))
+			return nil, positioned(err, op.Pos, op.Op, "select as many columns on both sides")
+		}
+		before := len(result.Rows) + len(right.Rows)
+		if err := e.combineRows(result, right, op); err != nil {
+			return nil, err
+		}
+		e.traceStep(strings.ToLower(op.Op), "all", op.All, "rows_in", before, "rows_out", len(result.Rows))
+	}
+
+	if len(stmt.OrderBy) > 0 {
+		for _, ob := range stmt.OrderBy {
+			if !hasColumn(result.Columns, ob.Column) {
+				err := errorf(ErrColumnNotFound, "ORDER BY %s: column must be in the select list of the first SELECT", ob.Column)
+				return nil, positioned(err, ob.Pos, ob.Column, "order a compound SELECT by the names of its columns")
+			}
+		}
+		if err := sortOutput(result, stmt.OrderBy); err != nil {
+			return nil, err
+		}
+		for i, values := range result.Values {
+			for j, v := range values {
+				result.Rows[i][j] = v.ToString()
+			}
+		}
+	}
+	e.limitResult(result, stmt)
+	return result, nil
+}
+
+// combineRows applies op to left and right, leaving the rows in left.
+func (e *Executor) combineRows(left, right *Result, op SetOperation) error {
+	if op.Op == "UNION" {
+		left.Rows = append(left.Rows, right.Rows...)
+		left.Values = append(left.Values, right.Values...)
+		if op.All {
+			return nil
+		}
+		return e.distinctRows(left)
+	}
+
+	counts := make(map[string]int, len(right.Values))
+	for _, row := range right.Values {
+		key := valuesKey(row)
+		if counts[key] == 0 {
+			if err := e.mem.grow(strings.ToLower(op.Op), int64(len(key))); err != nil {
+				return err
+			}
+		}
+		counts[key]++
+	}
+	seen := make(map[string]bool)
+	rows, values := left.Rows[:0], left.Values[:0]
+	for i, row := range left.Values {
+		key := valuesKey(row)
+		var keep bool
+		switch {
+		case op.All && op.Op == "INTERSECT":
+			keep = counts[key] > 0
+			counts[key]--
+		case op.All:
+			keep = counts[key] <= 0
+			counts[key]--
+		case op.Op == "INTERSECT":
+			keep = counts[key] > 0 && !seen[key]
+			seen[key] = true
+		default:
+			keep = counts[key] == 0 && !seen[key]
+			seen[key] = true
+		}
+		if keep {
+			rows = append(rows, left.Rows[i])
+			values = append(values, row)
+		}
+	}
+	left.Rows, left.Values = rows, values
+	return nil
+}
+
+// compoundPlan is the plan of a compound SELECT: a node for each set
+// operation, over the plan of the rows before it and that of its SELECT.
+func compoundPlan(s *SelectStatement) *PlanNode {
+	operands := compoundOperands(s)
+	plan := selectPlan(operands[0])
+	for i, op := range s.Compound {
+		operator := op.Op[:1] + strings.ToLower(op.Op[1:])
+		if op.All {
+			operator += " All"
+		}
+		plan = &PlanNode{Operator: operator, Children: []*PlanNode{plan, selectPlan(operands[i+1])}}
+	}
+	return limited(sorted(plan, s.OrderBy), s)
+}
+
+// operandPlans returns the plans of the SELECTs a compound plan combines,
+// in order.
+func operandPlans(plan *PlanNode) []*PlanNode {
+	for !isSetOperation(plan) {
+		plan = plan.Children[0]
+	}
+	var plans []*PlanNode
+	for ; isSetOperation(plan); plan = plan.Children[0] {
+		plans = append([]*PlanNode{plan.Children[1]}, plans...)
+	}
+	return append([]*PlanNode{plan}, plans...)
+}
+
+func isSetOperation(n *PlanNode) bool {
+	switch strings.TrimSuffix(n.Operator, " All") {
+	case "Union", "Intersect", "Except":
+		return true
+	}
+	return false
+}
+
+func hasColumn(columns []string, name string) bool {
+	for _, col := range columns {
+		if strings.EqualFold(col, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package sql
+
+import (
+	"sort"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// sortRows orders rows by the ORDER BY clauses. NULLs sort last in
+// ascending order and first in descending order, as in PostgreSQL. The sort
+// is stable, so rows that compare equal keep their scan order.
+func (e *Executor) sortRows(rows []*storage.Row, orderBy []OrderByClause, tables map[string]*storage.Table, offsets map[string]int) error {
+	indexes := make([]int, len(orderBy))
+	for i, ob := range orderBy {
+		idx, err := e.resolveColumnIndex(columnRef(ob.Column, ob.Pos), tables, offsets)
+		if err != nil {
+			return err
+		}
+		indexes[i] = idx
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		for k, ob := range orderBy {
+			a, _ := rows[i].Get(indexes[k])
+			b, _ := rows[j].Get(indexes[k])
+			c := compareValues(a, b)
+			if c == 0 {
+				continue
+			}
+			if ob.Asc {
+				return c < 0
+			}
+			return c > 0
+		}
+		return false
+	})
+	return nil
+}
+
+// compareValues orders two values of a column, with NULL greater than
+// everything and integers and floats compared numerically.
+func compareValues(a, b storage.Value) int {
+	aNull := a == nil || a.Type() == storage.TypeNull
+	bNull := b == nil || b.Type() == storage.TypeNull
+	switch {
+	case aNull && bNull:
+		return 0
+	case aNull:
+		return 1
+	case bNull:
+		return -1
+	}
+
+	if af, ok := numericValue(a); ok {
+		if bf, ok := numericValue(b); ok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	switch {
+	case a.LessThan(b):
+		return -1
+	case b.LessThan(a):
+		return 1
+	}
+	return 0
+}
+
+func numericValue(v storage.Value) (float64, bool) {
+	switch n := v.(type) {
+	case *storage.IntegerValue:
+		return float64(n.Value), true
+	case *storage.FloatValue:
+		return n.Value, true
+	}
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package sql
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// SetRowFunc sets a function that receives the rows of a SELECT one at a
+// time, as the scan finds them, instead of the Result holding them; nil
+// turns it off. Only a SELECT of one table that needs no sorting, grouping
+// or DISTINCT streams; other statements return their rows in the Result as
+// usual. fn runs under the table's read lock, so it must not use the
+// database and should return quickly. An error from fn stops the scan and
+// is returned from the statement.
+func (e *Executor) SetRowFunc(fn func(columns []string, row []storage.Value) error) {
+	e.rowFunc = fn
+}
+
+// streams reports whether executeSelect can pass stmt's rows to the row
+// function as the scan finds them: a query that joins, groups, sorts or
+// removes duplicates needs all of its rows first.
+func streams(stmt *SelectStatement) bool {
+	return len(stmt.Joins) == 0 && len(stmt.OrderBy) == 0 && !stmt.Distinct && !stmt.IsAggregate()
+}
+
+// streamSelect runs a SELECT that streams by projecting each row scan
+// yields and passing it to the row function, applying WHERE, OFFSET and
+// LIMIT on the way. copied is as in executeSelect.
+func (e *Executor) streamSelect(stmt *SelectStatement, ref TableRef, scan func(func(*storage.Row) bool), copied bool, tables map[string]*storage.Table, offsets map[string]int) (*Result, error) {
+	columns, indexes, exprs, err := e.projectColumns(stmt, tables, offsets)
+	if err != nil {
+		return nil, err
+	}
+	skip, limit := 0, -1
+	if stmt.Offset != nil {
+		skip = *stmt.Offset
+	}
+	if stmt.Limit != nil {
+		limit = *stmt.Limit
+	}
+
+	span := e.startSpan("rdbms.scan", attribute.String("db.sql.table", ref.Name))
+	rejected := &rejections{e: e, step: "WHERE"}
+	scanned, sent := 0, 0
+	scan(func(row *storage.Row) bool {
+		if limit >= 0 && sent >= limit {
+			return false
+		}
+		scanned++
+		if err = e.checkContext(scanned); err != nil {
+			return false
+		}
+		if stmt.Where != nil {
+			var val storage.Value
+			if val, err = e.evaluateExpressionForJoinedRow(stmt.Where, row, tables, offsets); err != nil {
+				return false
+			}
+			if !e.getValueAsBool(val) {
+				rejected.add(stmt.Where, row, val, nil)
+				return true
+			}
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		if !copied {
+			row = row.Clone()
+		}
+		var values []storage.Value
+		if values, err = e.projectRow(row, indexes, exprs, tables, offsets); err != nil {
+			return false
+		}
+		if err = e.rowFunc(columns, values); err != nil {
+			return false
+		}
+		sent++
+		return true
+	})
+	span.SetAttributes(attribute.Int("rdbms.rows", sent))
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	e.traceStep("scan", "table", ref.String(), "rows", scanned)
+	if stmt.Where != nil {
+		e.traceStep("filter", "predicate", stmt.Where.String(),
+			"rows_in", scanned, "rejected", rejected.count, "rows_out", scanned-rejected.count)
+	}
+	e.traceStep("stream", "rows", sent)
+	return &Result{Columns: columns}, nil
+}
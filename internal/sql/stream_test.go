@@ -0,0 +1,94 @@
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+func TestRowFunc(t *testing.T) {
+	db := storage.NewDatabase()
+	session := sql.NewSession(db)
+	defer session.Close()
+	for _, text := range []string{
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, score FLOAT)",
+		bulkInsert(2500, -1),
+	} {
+		if _, err := execSQL(session, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var columns []string
+	var rows [][]string
+	var fnErr error
+	session.SetRowFunc(func(c []string, row []storage.Value) error {
+		columns = c
+		values := make([]string, len(row))
+		for i, v := range row {
+			values[i] = v.ToString()
+		}
+		rows = append(rows, values)
+		return fnErr
+	})
+
+	// A scan of one table passes its rows on rather than returning them.
+	result, err := execSQL(session, "SELECT id, name FROM items WHERE id > 10 LIMIT 3 OFFSET 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Values) != 0 || len(result.Rows) != 0 {
+		t.Errorf("result holds %d rows, want none", len(result.Values))
+	}
+	if want := []string{"id", "name"}; !reflect.DeepEqual(columns, want) || !reflect.DeepEqual(result.Columns, want) {
+		t.Errorf("columns = %v, result columns = %v, want %v", columns, result.Columns, want)
+	}
+	if want := [][]string{{"13", "item-12"}, {"14", "item-13"}, {"15", "item-14"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+
+	// An error from the function stops the scan.
+	rows, fnErr = nil, errors.New("client gone")
+	if _, err := execSQL(session, "SELECT id FROM items"); !errors.Is(err, fnErr) {
+		t.Errorf("err = %v, want %v", err, fnErr)
+	}
+	if len(rows) != 1 {
+		t.Errorf("function called for %d rows after failing", len(rows))
+	}
+
+	// So does canceling the statement's context.
+	rows, fnErr = nil, nil
+	ctx, cancel := context.WithCancel(context.Background())
+	session.SetRowFunc(func([]string, []storage.Value) error {
+		rows = append(rows, nil)
+		cancel()
+		return nil
+	})
+	stmt, err := sql.NewParser(sql.NewLexer("SELECT id FROM items")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.ExecuteContext(ctx, stmt, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if len(rows) >= 2500 {
+		t.Errorf("scan read all %d rows after the context was canceled", len(rows))
+	}
+
+	// A sorted query needs all its rows first and returns them as usual.
+	rows = nil
+	result, err = execSQL(session, "SELECT id FROM items ORDER BY id DESC LIMIT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 0 {
+		t.Errorf("sorted query passed %d rows to the function", len(rows))
+	}
+	if want := [][]string{{"2500"}, {"2499"}}; !reflect.DeepEqual(result.Rows, want) {
+		t.Errorf("sorted rows = %v, want %v", result.Rows, want)
+	}
+}
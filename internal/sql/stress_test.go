@@ -0,0 +1,417 @@
+package sql_test
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// TestStress runs concurrent sessions doing mixed DML against one
+// Database, then checks that the tables hold exactly the rows each worker
+// believes it left behind and that no constraint was broken. It is most
+// useful under the race detector:
+//
+//	go test -race ./internal/sql -run TestStress -stress 30s
+//
+// Without -stress each worker runs a fixed, short sequence of operations.
+
+var stressFor = flag.Duration("stress", 0, "run TestStress for this long instead of a fixed number of operations")
+
+const (
+	stressWorkers = 8
+	stressOps     = 150
+	// Each worker owns the user ids [w*stressIDSpace, (w+1)*stressIDSpace),
+	// so workers never conflict on keys and the expected state is known.
+	stressIDSpace = 1000000
+)
+
+var stressSchema = []string{
+	"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT UNIQUE, score INTEGER)",
+	"CREATE TABLE tasks (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL, title TEXT)",
+	"CREATE INDEX users_score ON users (score)",
+}
+
+// stressWorker is one session and the users (id to score) and tasks (id
+// to owner) it has committed.
+type stressWorker struct {
+	t       *testing.T
+	id      int
+	session *sql.Session
+	rng     *rand.Rand
+	users   map[int64]int64
+	tasks   map[int64]int64
+	nextID  int64
+}
+
+func TestStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test skipped in -short mode")
+	}
+
+	db := storage.NewDatabase()
+	setup := sql.NewSession(db)
+	for _, text := range stressSchema {
+		if _, err := execSQL(setup, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	setup.Close()
+
+	deadline := time.Now().Add(*stressFor)
+	workers := make([]*stressWorker, stressWorkers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		w := &stressWorker{
+			t:       t,
+			id:      i,
+			session: sql.NewSession(db),
+			rng:     rand.New(rand.NewSource(int64(i) + 1)),
+			users:   make(map[int64]int64),
+			tasks:   make(map[int64]int64),
+			nextID:  int64(i) * stressIDSpace,
+		}
+		workers[i] = w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer w.session.Close()
+			for op := 0; op < stressOps || time.Now().Before(deadline); op++ {
+				if !w.step() {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	checkStressState(t, db, workers)
+}
+
+// step runs one random operation and reports whether the worker should
+// continue.
+func (w *stressWorker) step() bool {
+	switch r := w.rng.Intn(100); {
+	case r < 30:
+		return w.insertUser()
+	case r < 45:
+		return w.insertTask()
+	case r < 55:
+		return w.updateUser()
+	case r < 60:
+		return w.bumpUser()
+	case r < 70:
+		return w.deleteUser()
+	case r < 80:
+		return w.transaction()
+	case r < 85:
+		return w.duplicateUser()
+	default:
+		return w.read()
+	}
+}
+
+func (w *stressWorker) insertUser() bool {
+	id := w.newID()
+	score := w.rng.Int63n(100)
+	if !w.exec("INSERT INTO users (id, name, email, score) VALUES (?, ?, ?, ?)", id, w.name(id), w.email(id), score) {
+		return false
+	}
+	w.users[id] = score
+	return true
+}
+
+func (w *stressWorker) insertTask() bool {
+	owner, ok := w.someUser()
+	if !ok {
+		return true
+	}
+	id := w.newID()
+	if !w.exec("INSERT INTO tasks (id, user_id, title) VALUES (?, ?, ?)", id, owner, fmt.Sprintf("task %d", id)) {
+		return false
+	}
+	w.tasks[id] = owner
+	return true
+}
+
+func (w *stressWorker) updateUser() bool {
+	id, ok := w.someUser()
+	if !ok {
+		return true
+	}
+	score := w.rng.Int63n(100)
+	if !w.exec("UPDATE users SET score = ?, name = ? WHERE id = ?", score, w.name(id)+"'", id) {
+		return false
+	}
+	w.users[id] = score
+	return true
+}
+
+// bumpUser increments a user's score from its stored value, which
+// UPDATE reads under the table's lock.
+func (w *stressWorker) bumpUser() bool {
+	id, ok := w.someUser()
+	if !ok {
+		return true
+	}
+	if !w.exec("UPDATE users SET score = score + 1 WHERE id = ?", id) {
+		return false
+	}
+	w.users[id]++
+	return true
+}
+
+// deleteUser removes a user and, first, its tasks, so every task keeps
+// pointing at an existing user.
+func (w *stressWorker) deleteUser() bool {
+	id, ok := w.someUser()
+	if !ok {
+		return true
+	}
+	if !w.exec("DELETE FROM tasks WHERE user_id = ?", id) || !w.exec("DELETE FROM users WHERE id = ?", id) {
+		return false
+	}
+	for task, owner := range w.tasks {
+		if owner == id {
+			delete(w.tasks, task)
+		}
+	}
+	delete(w.users, id)
+	return true
+}
+
+// transaction inserts a user and a task inside BEGIN and either commits
+// or rolls back.
+func (w *stressWorker) transaction() bool {
+	user, task := w.newID(), w.newID()
+	commit := w.rng.Intn(2) == 0
+	end := "ROLLBACK"
+	if commit {
+		end = "COMMIT"
+	}
+	if !w.exec("BEGIN") ||
+		!w.exec("INSERT INTO users (id, name, email, score) VALUES (?, ?, ?, ?)", user, w.name(user), w.email(user), int64(0)) ||
+		!w.exec("INSERT INTO tasks (id, user_id, title) VALUES (?, ?, ?)", task, user, "tx") ||
+		!w.exec(end) {
+		return false
+	}
+	if commit {
+		w.users[user] = 0
+		w.tasks[task] = user
+	}
+	return true
+}
+
+// duplicateUser re-inserts an existing user, which must fail and change
+// nothing.
+func (w *stressWorker) duplicateUser() bool {
+	id, ok := w.someUser()
+	if !ok {
+		return true
+	}
+	_, err := execSQL(w.session, "INSERT INTO users (id, name, email, score) VALUES (?, ?, ?, ?)",
+		storage.NewIntegerValue(id), storage.NewTextValue("dup"), storage.NewTextValue(w.email(id)+".dup"), storage.NewIntegerValue(0))
+	if err == nil || !strings.Contains(err.Error(), "primary key violation") {
+		w.t.Errorf("worker %d: duplicate insert of user %d: got %v, want a primary key violation", w.id, id, err)
+		return false
+	}
+	return true
+}
+
+// read runs queries over rows other workers are changing; their results
+// cannot be predicted, only that they succeed.
+func (w *stressWorker) read() bool {
+	queries := []string{
+		"SELECT COUNT(*) FROM users",
+		"SELECT id, name FROM users WHERE score > 50 ORDER BY score DESC LIMIT 10",
+		"SELECT u.name, COUNT(t.id) FROM users u LEFT JOIN tasks t ON t.user_id = u.id GROUP BY u.name",
+		"SELECT t.title, u.email FROM tasks t JOIN users u ON u.id = t.user_id WHERE u.name LIKE 'w%'",
+		"SELECT id, name FROM users WHERE score = 42",
+	}
+	return w.exec(queries[w.rng.Intn(len(queries))])
+}
+
+func (w *stressWorker) exec(text string, params ...interface{}) bool {
+	values := make([]storage.Value, len(params))
+	for i, p := range params {
+		switch p := p.(type) {
+		case int64:
+			values[i] = storage.NewIntegerValue(p)
+		case string:
+			values[i] = storage.NewTextValue(p)
+		}
+	}
+	if _, err := execSQL(w.session, text, values...); err != nil {
+		w.t.Errorf("worker %d: %s: %v", w.id, text, err)
+		return false
+	}
+	return true
+}
+
+func (w *stressWorker) newID() int64 {
+	w.nextID++
+	return w.nextID
+}
+
+func (w *stressWorker) someUser() (int64, bool) {
+	for id := range w.users {
+		return id, true
+	}
+	return 0, false
+}
+
+func (w *stressWorker) name(id int64) string  { return fmt.Sprintf("w%d-%d", w.id, id) }
+func (w *stressWorker) email(id int64) string { return fmt.Sprintf("%d@w%d.example.com", id, w.id) }
+
+// checkStressState compares the tables with the workers' models and
+// checks the primary key, unique, not-null and task ownership
+// constraints.
+func checkStressState(t *testing.T, db *storage.Database, workers []*stressWorker) {
+	session := sql.NewSession(db)
+	defer session.Close()
+
+	wantUsers := make(map[int64]int64)
+	wantTasks := make(map[int64]int64)
+	for _, w := range workers {
+		for id, score := range w.users {
+			wantUsers[id] = score
+		}
+		for id, owner := range w.tasks {
+			wantTasks[id] = owner
+		}
+	}
+
+	users, err := execSQL(session, "SELECT id, name, email, score FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users.Values) != len(wantUsers) {
+		t.Errorf("users has %d rows, want %d", len(users.Values), len(wantUsers))
+	}
+	seenIDs := make(map[int64]bool)
+	seenEmails := make(map[string]bool)
+	for _, row := range users.Values {
+		id := row[0].(*storage.IntegerValue).Value
+		if seenIDs[id] {
+			t.Errorf("duplicate primary key %d in users", id)
+		}
+		seenIDs[id] = true
+		if _, null := row[1].(storage.NullValue); null {
+			t.Errorf("user %d has a NULL name", id)
+		}
+		if email := row[2].ToString(); seenEmails[email] {
+			t.Errorf("duplicate email %s in users", email)
+		} else {
+			seenEmails[email] = true
+		}
+		score, ok := wantUsers[id]
+		if !ok {
+			t.Errorf("unexpected user %d", id)
+		} else if got := row[3].(*storage.IntegerValue).Value; got != score {
+			t.Errorf("user %d has score %d, want %d", id, got, score)
+		}
+	}
+
+	tasks, err := execSQL(session, "SELECT id, user_id FROM tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks.Values) != len(wantTasks) {
+		t.Errorf("tasks has %d rows, want %d", len(tasks.Values), len(wantTasks))
+	}
+	for _, row := range tasks.Values {
+		id, owner := row[0].(*storage.IntegerValue).Value, row[1].(*storage.IntegerValue).Value
+		if want, ok := wantTasks[id]; !ok || want != owner {
+			t.Errorf("task %d owned by %d, want %d (present: %v)", id, owner, want, ok)
+		}
+		if !seenIDs[owner] {
+			t.Errorf("task %d belongs to missing user %d", id, owner)
+		}
+	}
+}
+
+// TestConcurrentUpdates has sessions increment shared counters, through
+// an indexed column, while others read them through the index. Each
+// UPDATE reads the value it increments under the table's lock, so no
+// increment is lost; run it with -race to check the readers.
+func TestConcurrentUpdates(t *testing.T) {
+	const (
+		writers    = 4
+		readers    = 4
+		increments = 100
+	)
+	db := storage.NewDatabase()
+	setup := sql.NewSession(db)
+	for _, text := range []string{
+		"CREATE TABLE counters (id INTEGER PRIMARY KEY, n INTEGER)",
+		"CREATE INDEX counters_n ON counters (n) INCLUDE (id)",
+		"INSERT INTO counters (id, n) VALUES (1, 0), (2, 0)",
+	} {
+		if _, err := execSQL(setup, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	defer setup.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session := sql.NewSession(db)
+			defer session.Close()
+			for j := 0; j < increments; j++ {
+				id := storage.NewIntegerValue(int64(1 + (i+j)%2))
+				if _, err := execSQL(session, "UPDATE counters SET n = n + 1 WHERE id = ?", id); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session := sql.NewSession(db)
+			defer session.Close()
+			for j := 0; j < increments; j++ {
+				n := storage.NewIntegerValue(int64(j))
+				if _, err := execSQL(session, "SELECT id FROM counters WHERE n = ?", n); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result, err := execSQL(setup, "SELECT n FROM counters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := int64(0)
+	for _, row := range result.Values {
+		total += row[0].(*storage.IntegerValue).Value
+	}
+	if want := int64(writers * increments); total != want {
+		t.Errorf("counters sum to %d, want %d", total, want)
+	}
+}
+
+func execSQL(session *sql.Session, text string, params ...storage.Value) (*sql.Result, error) {
+	stmt, err := sql.NewParser(sql.NewLexer(text)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	return session.ExecuteWithParams(stmt, params)
+}
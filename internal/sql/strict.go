@@ -0,0 +1,133 @@
+package sql
+
+import (
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// SET sql_mode = strict makes the session reject what the permissive
+// default lets through:
+//
+//   - an INSERT naming a column the table lacks, or whose rows have more
+//     values than the table or its column list has columns, or fewer than
+//     the column list (permissive drops the extra values and leaves the
+//     missing ones NULL);
+//   - an UPDATE setting a column the table lacks (permissive ignores it);
+//   - a string literal stored in a number column, such as '12' in an
+//     INTEGER column, and any value not of its column's type ('true' in a
+//     BOOLEAN column is still read as a boolean);
+//   - an INTEGER division with a remainder, which permissive truncates;
+//   - comparing values of different types, such as an INTEGER with TEXT,
+//     which permissive answers as if they differed.
+//
+// INTEGER and FLOAT are one family of numbers: an INTEGER stored in a
+// FLOAT column, or compared with a FLOAT, becomes a FLOAT rather than
+// failing. The checks are made on the values the statement meets, so a
+// comparison on no row does not fail. CAST makes the conversions explicit.
+
+// parseSQLMode accepts the values SET sql_mode takes.
+func parseSQLMode(value string) (strict bool, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "strict":
+		return true, true
+	case "permissive", "default", "":
+		return false, true
+	}
+	return false, false
+}
+
+// SetStrict turns strict mode on or off.
+func (e *Executor) SetStrict(strict bool) {
+	e.strict = strict
+}
+
+// checkStrictInsert checks an INSERT's columns and row widths in strict
+// mode. positions are the table columns its values go to, -1 for a
+// column the table lacks.
+func (e *Executor) checkStrictInsert(stmt *InsertStatement, positions []int) error {
+	if !e.strict {
+		return nil
+	}
+	for i, pos := range positions {
+		if pos < 0 {
+			err := errorf(ErrColumnNotFound, "column %s of table %s does not exist", stmt.Columns[i], stmt.Table)
+			return positioned(err, stmt.TablePos, stmt.Table, "")
+		}
+	}
+	for i, row := range stmt.Values {
+		if len(row) > len(positions) || len(row) < len(positions) && len(stmt.Columns) > 0 {
+			err := errorf(ErrSyntax, "row %d of INSERT has %d values for %d columns", i+1, len(row), len(positions))
+			return positioned(err, stmt.TablePos, stmt.Table, "give a value for each column, or list the columns")
+		}
+	}
+	return nil
+}
+
+// checkStrictUpdate checks an UPDATE's columns in strict mode.
+func (e *Executor) checkStrictUpdate(stmt *UpdateStatement, table *storage.Table) error {
+	if !e.strict {
+		return nil
+	}
+	for _, set := range stmt.SetClauses {
+		if table.Schema().ColumnIndex(set.Column) < 0 {
+			err := errorf(ErrColumnNotFound, "column %s of table %s does not exist", set.Column, stmt.Table)
+			return positioned(err, stmt.TablePos, stmt.Table, "")
+		}
+	}
+	return nil
+}
+
+// columnValue returns v, the value of expr, as stored in col (see
+// quotedText), checking in strict mode that it is of col's type.
+func (e *Executor) columnValue(expr Expression, col *storage.Column, v storage.Value) (storage.Value, error) {
+	v = quotedText(expr, col, v)
+	if !e.strict {
+		return v, nil
+	}
+	numeric := col.Type == storage.TypeInteger || col.Type == storage.TypeFloat
+	if lit, ok := expr.(*LiteralExpression); ok && lit.Quoted && numeric {
+		return nil, errorf(ErrTypeMismatch, "cannot store the string '%s' in %s column %s; write it without quotes or CAST it",
+			lit.Value, col.Type, col.Name)
+	}
+	if isNull(v) || v.Type() == col.Type {
+		return v, nil
+	}
+	if i, ok := v.(*storage.IntegerValue); ok && col.Type == storage.TypeFloat {
+		return storage.NewFloatValue(float64(i.Value)), nil
+	}
+	return nil, errorf(ErrTypeMismatch, "column %s is %s, but the value %s is %s", col.Name, col.Type, v.ToString(), v.Type())
+}
+
+// strictOperands returns the operands of op as strict mode compares them,
+// an INTEGER compared with a FLOAT becoming a FLOAT, and rejects the
+// comparisons of other values of different types and the INTEGER
+// divisions with a remainder.
+func (e *Executor) strictOperands(left storage.Value, op string, right storage.Value) (storage.Value, storage.Value, error) {
+	if !e.strict || isNull(left) || isNull(right) {
+		return left, right, nil
+	}
+	switch op {
+	case "=", "==", "!=", "<>", "<", "<=", ">", ">=", "<=>", "IS DISTINCT FROM", "IS NOT DISTINCT FROM":
+		left, right = promoteNumber(left, right), promoteNumber(right, left)
+		if left.Type() != right.Type() {
+			return nil, nil, errorf(ErrTypeMismatch, "cannot compare %s %s with %s %s", left.Type(), left.ToString(), right.Type(), right.ToString())
+		}
+	case "/":
+		l, lok := left.(*storage.IntegerValue)
+		r, rok := right.(*storage.IntegerValue)
+		if lok && rok && r.Value != 0 && l.Value%r.Value != 0 {
+			return nil, nil, errorf(ErrTypeMismatch, "INTEGER division %d / %d has a remainder; CAST an operand to FLOAT", l.Value, r.Value)
+		}
+	}
+	return left, right, nil
+}
+
+// promoteNumber returns v as a FLOAT if it is an INTEGER and other a
+// FLOAT, and v otherwise.
+func promoteNumber(v, other storage.Value) storage.Value {
+	if i, ok := v.(*storage.IntegerValue); ok && other.Type() == storage.TypeFloat {
+		return storage.NewFloatValue(float64(i.Value))
+	}
+	return v
+}
@@ -0,0 +1,104 @@
+package sql
+
+import (
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// A subquery in IN (SELECT ...) is run once per statement, before the
+// statement reads its tables, and its rows are kept as a valueSet that the
+// IN looks each value up in. It cannot refer to the outer query's columns.
+
+// valueSet holds the values a subquery returned.
+type valueSet struct {
+	keys    map[string]bool // by hashKey
+	others  []storage.Value // values hashKey cannot key
+	hasNull bool
+}
+
+func newValueSet(values []storage.Value) *valueSet {
+	set := &valueSet{keys: make(map[string]bool, len(values))}
+	for _, v := range values {
+		if isNull(v) {
+			set.hasNull = true
+		} else if key, ok := hashKey(v); ok {
+			set.keys[key] = true
+		} else {
+			set.others = append(set.others, v)
+		}
+	}
+	return set
+}
+
+// contains reports whether v equals one of the set's values, as =
+// compares them.
+func (s *valueSet) contains(v storage.Value) bool {
+	if key, ok := hashKey(v); ok && s.keys[key] {
+		return true
+	}
+	for _, other := range s.others {
+		if v.Equals(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectExpressions returns the expressions stmt evaluates on its rows.
+func selectExpressions(stmt *SelectStatement) []Expression {
+	exprs := []Expression{stmt.Where, stmt.Having}
+	for _, col := range stmt.Columns {
+		exprs = append(exprs, col.Expr)
+	}
+	for _, join := range stmt.Joins {
+		exprs = append(exprs, join.Conditions...)
+	}
+	return exprs
+}
+
+// runSubqueries runs the IN subqueries in exprs that the statement has
+// not run yet. Statements call it before reading their tables, since a
+// subquery run while a row is being tested would read a table the
+// statement may hold locked.
+func (e *Executor) runSubqueries(exprs ...Expression) error {
+	var err error
+	for _, expr := range exprs {
+		Inspect(expr, func(expr Expression) bool {
+			if in, ok := expr.(*InExpression); ok && in.Subquery != nil && err == nil {
+				_, err = e.subquerySet(in)
+			}
+			return err == nil
+		})
+	}
+	return err
+}
+
+// subquerySet returns the values expr's subquery returns, running it the
+// first time the statement asks.
+func (e *Executor) subquerySet(expr *InExpression) (*valueSet, error) {
+	if set, ok := e.subqueries[expr]; ok {
+		return set, nil
+	}
+	result, err := e.executeSelect(expr.Subquery)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Columns) != 1 {
+		err := errorf(ErrSyntax, "subquery in IN must return one column, got %d", len(result.Columns))
+		return nil, positioned(err, expr.Pos, expr.String(), "select a single column in the subquery")
+	}
+	values := make([]storage.Value, len(result.Values))
+	for i, row := range result.Values {
+		values[i] = row[0]
+	}
+	if _, err := e.chargeRow("subquery", storage.NewRow(values)); err != nil {
+		return nil, positioned(err, expr.Pos, expr.String(), "raise work_mem with SET work_mem")
+	}
+	e.traceStep("subquery", "query", expr.Subquery.String(), "rows", len(values))
+
+	if e.subqueries == nil {
+		e.subqueries = make(map[*InExpression]*valueSet)
+	}
+	set := newValueSet(values)
+	e.subqueries[expr] = set
+	return set, nil
+}
@@ -0,0 +1,96 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Version is the server's version, as VERSION() and the CLIs report it.
+const Version = "1.0.0"
+
+// systemFunctions report on the server and the session. Drivers and ORMs
+// call them when they connect, often without a FROM clause: SELECT
+// VERSION().
+var systemFunctions = map[string]func(e *Executor) storage.Value{
+	// VERSION names the server and its version.
+	"VERSION": func(e *Executor) storage.Value {
+		return storage.NewTextValue("RDBMS " + Version)
+	},
+	// CURRENT_USER is the user the statement runs as, or NULL when the
+	// session did not authenticate.
+	"CURRENT_USER": func(e *Executor) storage.Value {
+		if e.user == "" {
+			return storage.NullValue{}
+		}
+		return storage.NewTextValue(e.user)
+	},
+	// DATABASE is the database unqualified table names refer to.
+	"DATABASE": func(e *Executor) storage.Value {
+		return storage.NewTextValue(storage.MainDatabase)
+	},
+	// NOW is the local time the statement started, the same for every
+	// call in it, as TEXT like '2024-03-05 14:30:00' (see datetime.go).
+	"NOW": func(e *Executor) storage.Value {
+		return storage.NewTextValue(e.now.Format(time.DateTime))
+	},
+	// LAST_INSERT_ID is the primary key of the last row an INSERT on this
+	// executor added (see Result.LastInsertID), or NULL before the first.
+	"LAST_INSERT_ID": func(e *Executor) storage.Value {
+		if e.lastInsertID == nil {
+			return storage.NullValue{}
+		}
+		return storage.NewIntegerValue(*e.lastInsertID)
+	},
+}
+
+// selectWithoutFrom returns the single row of a SELECT with no FROM
+// clause, such as SELECT VERSION() or SELECT 1 + 1, whose columns cannot
+// read a table.
+func (e *Executor) selectWithoutFrom(stmt *SelectStatement) (*Result, error) {
+	result := &Result{Columns: stmt.ColumnNames()}
+	var strs []string
+	var values []storage.Value
+	if len(stmt.Aggregates) > 0 {
+		call := stmt.Aggregates[0]
+		err := errorf(ErrGrouping, "aggregate function %s needs a FROM clause", call.Name)
+		return nil, positioned(err, call.Pos, call.String(), "add a FROM clause to aggregate the rows of a table")
+	}
+	for c := range stmt.Columns {
+		v, err := e.constantColumn(stmt, c)
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, v.ToString())
+		values = append(values, v)
+	}
+	if err := e.mem.grow("project", resultRowBytes(strs)); err != nil {
+		return nil, err
+	}
+	result.Rows = [][]string{strs}
+	result.Values = [][]storage.Value{values}
+	e.limitResult(result, stmt)
+	return result, nil
+}
+
+// constantColumn evaluates column c of a SELECT without FROM: a system
+// function, or an expression of constants, parameters and functions.
+func (e *Executor) constantColumn(stmt *SelectStatement, c int) (storage.Value, error) {
+	col := stmt.Columns[c]
+	var ref *ColumnRef
+	Inspect(col.Expr, func(expr Expression) bool {
+		if r, ok := expr.(*ColumnRef); ok && ref == nil {
+			ref = r
+		}
+		return ref == nil
+	})
+	if col.Expr == nil || ref != nil {
+		pos, name := col.Pos, col.Name
+		if ref != nil {
+			pos, name = ref.Pos, ref.String()
+		}
+		err := errorf(ErrColumnNotFound, "column not found: %s", name)
+		return nil, positioned(err, pos, name, "add a FROM clause to select columns of a table")
+	}
+	return e.evaluateExpression(col.Expr, nil)
+}
@@ -0,0 +1,313 @@
+package sql
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// systemTables are read-only tables computed from database state each time
+// they are queried.
+var systemTables = map[string]func(*storage.Database) *storage.Table{
+	"rdbms_slow_queries":      slowQueriesTable,
+	"rdbms_audit_log":         auditLogTable,
+	"rdbms_plan_cache":        planCacheTable,
+	"rdbms_column_stats":      columnStatsTable,
+	"rdbms_stats":             statsTable,
+	"rdbms_index_usage":       indexUsageTable,
+	"rdbms_index_advice":      indexAdviceTable,
+	"rdbms_replication_slots": replicationSlotsTable,
+	"rdbms_prepared_xacts":    preparedXactsTable,
+}
+
+// lookupTable resolves name for reading, checking system tables first and
+// then external tables, whose file is read on each lookup. A name
+// qualified with an attached database reads that database's table.
+func (e *Executor) lookupTable(name string) (*storage.Table, error) {
+	db, name, err := e.resolveDatabase(name)
+	if err != nil {
+		return nil, err
+	}
+	if db != e.db {
+		return db.GetTable(name)
+	}
+	if build, ok := systemTables[name]; ok {
+		return build(e.db), nil
+	}
+	if ext, ok := e.db.ExternalTable(name); ok {
+		return e.readCSV(name, ext.Location, ext.Schema)
+	}
+	return e.db.GetTable(name)
+}
+
+// lookupTableAsOf resolves name for reading as it was at time at, or as it
+// is now if at is nil. The past table is a copy without indexes.
+func (e *Executor) lookupTableAsOf(name string, at *time.Time) (*storage.Table, error) {
+	if at == nil {
+		return e.lookupTable(name)
+	}
+	db, name, err := e.resolveDatabase(name)
+	if err != nil {
+		return nil, err
+	}
+	if db == e.temp {
+		return nil, errorf(ErrUnsupported, "temporary table %s has no history", name)
+	}
+	if db != e.db {
+		return db.TableAsOf(name, *at)
+	}
+	if _, ok := systemTables[name]; ok {
+		return nil, errorf(ErrUnsupported, "system table %s has no history", name)
+	}
+	if _, ok := e.db.ExternalTable(name); ok {
+		return nil, errorf(ErrUnsupported, "external table %s has no history", name)
+	}
+	return e.db.TableAsOf(name, *at)
+}
+
+func newSystemTable(name string, columns []*storage.Column) *storage.Table {
+	schema := storage.NewSchema()
+	for _, col := range columns {
+		schema.AddColumn(col)
+	}
+	return storage.NewTable(name, schema)
+}
+
+func slowQueriesTable(db *storage.Database) *storage.Table {
+	table := newSystemTable("rdbms_slow_queries", []*storage.Column{
+		storage.NewColumn("id", storage.TypeInteger, false, false, true),
+		storage.NewColumn("started_at", storage.TypeText, false, false, true),
+		storage.NewColumn("duration_ms", storage.TypeFloat, false, false, true),
+		storage.NewColumn("operation", storage.TypeText, false, false, true),
+		storage.NewColumn("statement", storage.TypeText, false, false, true),
+		storage.NewColumn("rows_affected", storage.TypeInteger, false, false, true),
+		storage.NewColumn("rows_returned", storage.TypeInteger, false, false, true),
+		storage.NewColumn("plan", storage.TypeText, false, false, true),
+	})
+
+	for _, q := range db.SlowQueries() {
+		table.Insert(storage.NewRow([]storage.Value{
+			storage.NewIntegerValue(int64(q.ID)),
+			storage.NewTextValue(q.StartedAt.Format(time.RFC3339Nano)),
+			storage.NewFloatValue(float64(q.Duration) / float64(time.Millisecond)),
+			storage.NewTextValue(q.Operation),
+			storage.NewTextValue(q.Statement),
+			storage.NewIntegerValue(int64(q.RowsAffected)),
+			storage.NewIntegerValue(int64(q.RowsReturned)),
+			storage.NewTextValue(q.Plan),
+		}))
+	}
+	return table
+}
+
+func auditLogTable(db *storage.Database) *storage.Table {
+	table := newSystemTable("rdbms_audit_log", []*storage.Column{
+		storage.NewColumn("id", storage.TypeInteger, false, false, true),
+		storage.NewColumn("logged_at", storage.TypeText, false, false, true),
+		storage.NewColumn("user_name", storage.TypeText, false, false, true),
+		storage.NewColumn("operation", storage.TypeText, false, false, true),
+		storage.NewColumn("statement", storage.TypeText, false, false, true),
+		storage.NewColumn("rows_affected", storage.TypeInteger, false, false, true),
+	})
+
+	for _, entry := range db.AuditEntries() {
+		table.Insert(storage.NewRow([]storage.Value{
+			storage.NewIntegerValue(int64(entry.ID)),
+			storage.NewTextValue(entry.Time.Format(time.RFC3339Nano)),
+			storage.NewTextValue(entry.User),
+			storage.NewTextValue(entry.Operation),
+			storage.NewTextValue(entry.Statement),
+			storage.NewIntegerValue(int64(entry.RowsAffected)),
+		}))
+	}
+	return table
+}
+
+// planCacheTable lists the statements in the plan cache, most recently
+// used first, with how often each was served from it.
+func planCacheTable(db *storage.Database) *storage.Table {
+	table := newSystemTable("rdbms_plan_cache", []*storage.Column{
+		storage.NewColumn("query", storage.TypeText, false, false, true),
+		storage.NewColumn("operation", storage.TypeText, false, false, true),
+		storage.NewColumn("params", storage.TypeInteger, false, false, true),
+		storage.NewColumn("hits", storage.TypeInteger, false, false, true),
+	})
+
+	for _, plan := range plans.snapshot() {
+		table.Insert(storage.NewRow([]storage.Value{
+			storage.NewTextValue(plan.query),
+			storage.NewTextValue(plan.stmt.Type().String()),
+			storage.NewIntegerValue(int64(plan.paramCount)),
+			storage.NewIntegerValue(plan.hits),
+		}))
+	}
+	return table
+}
+
+// statsTable lists Database.Stats: the size of each table, in creation
+// order, when it was created and last written (NULL if never) and its
+// indexes.
+func statsTable(db *storage.Database) *storage.Table {
+	table := newSystemTable("rdbms_stats", []*storage.Column{
+		storage.NewColumn("table_name", storage.TypeText, false, false, true),
+		storage.NewColumn("row_count", storage.TypeInteger, false, false, true),
+		storage.NewColumn("approx_bytes", storage.TypeInteger, false, false, true),
+		storage.NewColumn("index_count", storage.TypeInteger, false, false, true),
+		storage.NewColumn("index_depth", storage.TypeInteger, false, false, true),
+		storage.NewColumn("last_modified", storage.TypeText, false, false, false),
+		storage.NewColumn("created", storage.TypeText, false, false, true),
+	})
+
+	for _, m := range db.Stats().Tables {
+		var modified storage.Value = storage.NullValue{}
+		if !m.ModifiedAt.IsZero() {
+			modified = storage.NewTextValue(m.ModifiedAt.Format(time.RFC3339Nano))
+		}
+		table.Insert(storage.NewRow([]storage.Value{
+			storage.NewTextValue(m.Table),
+			storage.NewIntegerValue(int64(m.Rows)),
+			storage.NewIntegerValue(m.Bytes),
+			storage.NewIntegerValue(int64(m.Indexes)),
+			storage.NewIntegerValue(int64(m.IndexDepth)),
+			modified,
+			storage.NewTextValue(m.CreatedAt.Format(time.RFC3339Nano)),
+		}))
+	}
+	return table
+}
+
+// indexUsageTable lists Database.IndexUsage: each index with the reads it
+// served and the rows written to it.
+func indexUsageTable(db *storage.Database) *storage.Table {
+	table := newSystemTable("rdbms_index_usage", []*storage.Column{
+		storage.NewColumn("table_name", storage.TypeText, false, false, true),
+		storage.NewColumn("index_name", storage.TypeText, false, false, true),
+		storage.NewColumn("column_name", storage.TypeText, false, false, true),
+		storage.NewColumn("kind", storage.TypeText, false, false, true),
+		storage.NewColumn("scans", storage.TypeInteger, false, false, true),
+		storage.NewColumn("rows_written", storage.TypeInteger, false, false, true),
+		storage.NewColumn("last_scan", storage.TypeText, false, false, false),
+	})
+
+	for _, u := range db.IndexUsage() {
+		var lastScan storage.Value = storage.NullValue{}
+		if !u.LastScan.IsZero() {
+			lastScan = storage.NewTextValue(u.LastScan.Format(time.RFC3339Nano))
+		}
+		table.Insert(storage.NewRow([]storage.Value{
+			storage.NewTextValue(u.Table),
+			storage.NewTextValue(u.Index),
+			storage.NewTextValue(u.Column),
+			storage.NewTextValue(u.Kind),
+			storage.NewIntegerValue(u.Scans),
+			storage.NewIntegerValue(u.RowsWritten),
+			lastScan,
+		}))
+	}
+	return table
+}
+
+// indexAdviceTable lists Database.IndexAdvice: the indexes that would have
+// spared the most full scans, with the statement creating each.
+func indexAdviceTable(db *storage.Database) *storage.Table {
+	table := newSystemTable("rdbms_index_advice", []*storage.Column{
+		storage.NewColumn("table_name", storage.TypeText, false, false, true),
+		storage.NewColumn("column_name", storage.TypeText, false, false, true),
+		storage.NewColumn("full_scans", storage.TypeInteger, false, false, true),
+		storage.NewColumn("rows_read", storage.TypeInteger, false, false, true),
+		storage.NewColumn("suggestion", storage.TypeText, false, false, true),
+	})
+
+	for _, a := range db.IndexAdvice() {
+		table.Insert(storage.NewRow([]storage.Value{
+			storage.NewTextValue(a.Table),
+			storage.NewTextValue(a.Column),
+			storage.NewIntegerValue(a.Scans),
+			storage.NewIntegerValue(a.RowsRead),
+			storage.NewTextValue(a.Statement),
+		}))
+	}
+	return table
+}
+
+// replicationSlotsTable lists Database.ReplicationSlots, with how many
+// WAL entries each slot's consumer has yet to confirm.
+func replicationSlotsTable(db *storage.Database) *storage.Table {
+	table := newSystemTable("rdbms_replication_slots", []*storage.Column{
+		storage.NewColumn("slot_name", storage.TypeText, false, false, true),
+		storage.NewColumn("confirmed_lsn", storage.TypeInteger, false, false, true),
+		storage.NewColumn("lag", storage.TypeInteger, false, false, true),
+		storage.NewColumn("created", storage.TypeText, false, false, true),
+	})
+
+	last := db.WAL().LastLSN()
+	for _, slot := range db.ReplicationSlots() {
+		table.Insert(storage.NewRow([]storage.Value{
+			storage.NewTextValue(slot.Name),
+			storage.NewIntegerValue(int64(slot.ConfirmedLSN)),
+			storage.NewIntegerValue(int64(last - slot.ConfirmedLSN)),
+			storage.NewTextValue(slot.Created.Format(time.RFC3339Nano)),
+		}))
+	}
+	return table
+}
+
+// preparedXactsTable lists Database.PreparedTransactions: each
+// transaction waiting for COMMIT PREPARED or ROLLBACK PREPARED, with the
+// number of changes it holds.
+func preparedXactsTable(db *storage.Database) *storage.Table {
+	table := newSystemTable("rdbms_prepared_xacts", []*storage.Column{
+		storage.NewColumn("gid", storage.TypeText, false, false, true),
+		storage.NewColumn("prepared", storage.TypeText, false, false, true),
+		storage.NewColumn("owner", storage.TypeText, false, false, false),
+		storage.NewColumn("changes", storage.TypeInteger, false, false, true),
+	})
+
+	for _, p := range db.PreparedTransactions() {
+		var owner storage.Value = storage.NullValue{}
+		if p.Owner != "" {
+			owner = storage.NewTextValue(p.Owner)
+		}
+		table.Insert(storage.NewRow([]storage.Value{
+			storage.NewTextValue(p.GID),
+			storage.NewTextValue(p.Prepared.Format(time.RFC3339Nano)),
+			owner,
+			storage.NewIntegerValue(int64(len(p.Changes))),
+		}))
+	}
+	return table
+}
+
+// columnStatsTable lists the column statistics ANALYZE gathered, one row
+// per column, with the histogram's bounds as text: {1, 10, 20}.
+func columnStatsTable(db *storage.Database) *storage.Table {
+	table := newSystemTable("rdbms_column_stats", []*storage.Column{
+		storage.NewColumn("table_name", storage.TypeText, false, false, true),
+		storage.NewColumn("column_name", storage.TypeText, false, false, true),
+		storage.NewColumn("row_count", storage.TypeInteger, false, false, true),
+		storage.NewColumn("null_frac", storage.TypeFloat, false, false, true),
+		storage.NewColumn("n_distinct", storage.TypeInteger, false, false, true),
+		storage.NewColumn("histogram_bounds", storage.TypeText, false, false, true),
+		storage.NewColumn("analyzed_at", storage.TypeText, false, false, true),
+	})
+
+	for _, stats := range db.AllTableStats() {
+		for _, col := range stats.Columns {
+			bounds := make([]string, len(col.Bounds))
+			for i, v := range col.Bounds {
+				bounds[i] = v.ToString()
+			}
+			table.Insert(storage.NewRow([]storage.Value{
+				storage.NewTextValue(stats.Table),
+				storage.NewTextValue(col.Column),
+				storage.NewIntegerValue(int64(stats.Rows)),
+				storage.NewFloatValue(col.NullFrac),
+				storage.NewIntegerValue(int64(col.Distinct)),
+				storage.NewTextValue("{" + strings.Join(bounds, ", ") + "}"),
+				storage.NewTextValue(stats.AnalyzedAt.Format(time.RFC3339Nano)),
+			}))
+		}
+	}
+	return table
+}
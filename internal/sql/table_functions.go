@@ -0,0 +1,199 @@
+package sql
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// tableFunctions build the rows of a function called in FROM position from
+// its evaluated arguments. The table is given the name the query refers to
+// it by: its alias, or the function's name.
+var tableFunctions = map[string]func(e *Executor, name string, args []storage.Value) (*storage.Table, error){
+	"generate_series": generateSeries,
+	"csv_read":        csvRead,
+	"fulltext_search": fullTextSearch,
+}
+
+// fromTable resolves an item of the FROM list or a JOIN: a table function
+// call, or a table as of at (see lookupTableAsOf).
+func (e *Executor) fromTable(ref TableRef, at *time.Time) (*storage.Table, error) {
+	call := ref.Function
+	if call == nil {
+		return e.lookupTableAsOf(ref.Name, at)
+	}
+	build, ok := tableFunctions[call.Name]
+	if !ok {
+		names := make([]string, 0, len(tableFunctions))
+		for name := range tableFunctions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		err := errorf(ErrUnsupported, "unknown table function: %s", call.Name)
+		return nil, positioned(err, call.Pos, call.Name, "available table functions: "+strings.Join(names, ", "))
+	}
+
+	args := make([]storage.Value, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		v, err := e.evaluateExpression(arg, nil)
+		if err != nil {
+			return nil, positioned(err, call.Pos, call.String(), "pass constants or parameters")
+		}
+		args[i] = v
+	}
+	table, err := build(e, ref.RefName(), args)
+	return table, positioned(err, call.Pos, call.String(), "")
+}
+
+// addFunctionRow appends values to the rows of a table function or an
+// external table, charging the row to the statement.
+func (e *Executor) addFunctionRow(table *storage.Table, values []storage.Value) error {
+	row := storage.NewRow(values)
+	if _, err := e.chargeRow("read "+table.Name, row); err != nil {
+		return err
+	}
+	table.Rows = append(table.Rows, row)
+	return e.checkContext(len(table.Rows))
+}
+
+// generateSeries returns the integers from start to stop, inclusive, in
+// steps of step (default 1), in one column named after the table. Like
+// PostgreSQL's, it returns no rows if the step moves away from stop or an
+// argument is NULL.
+func generateSeries(e *Executor, name string, args []storage.Value) (*storage.Table, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, errorf(ErrParameter, "generate_series takes 2 or 3 arguments, got %d", len(args))
+	}
+	bounds := []int64{0, 0, 1}
+	for i, arg := range args {
+		if arg.Type() == storage.TypeNull {
+			return seriesTable(name), nil
+		}
+		v, ok := arg.(*storage.IntegerValue)
+		if !ok {
+			return nil, errorf(ErrTypeMismatch, "generate_series arguments must be integers, got %s", arg.Type())
+		}
+		bounds[i] = v.Value
+	}
+	start, stop, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return nil, errorf(ErrParameter, "generate_series step cannot be zero")
+	}
+
+	table := seriesTable(name)
+	for v := start; step > 0 && v <= stop || step < 0 && v >= stop; v += step {
+		if err := e.addFunctionRow(table, []storage.Value{storage.NewIntegerValue(v)}); err != nil {
+			return nil, err
+		}
+		if step > 0 && v > math.MaxInt64-step || step < 0 && v < math.MinInt64-step {
+			break
+		}
+	}
+	return table, nil
+}
+
+func seriesTable(name string) *storage.Table {
+	return newSystemTable(name, []*storage.Column{
+		storage.NewColumn(name, storage.TypeInteger, false, false, true),
+	})
+}
+
+// csvRead returns the rows of a CSV file on the server (see readCSV).
+func csvRead(e *Executor, name string, args []storage.Value) (*storage.Table, error) {
+	if len(args) != 1 || args[0].Type() != storage.TypeText {
+		return nil, errorf(ErrParameter, "csv_read takes one argument, the file's path")
+	}
+	return e.readCSV(name, args[0].(*storage.TextValue).Value, nil)
+}
+
+// readCSV reads the CSV file at path into a table named name. The file's
+// first line names its columns. With a schema the file must have as many
+// columns, which take the schema's names and types; without one each
+// column gets the narrowest type all its values parse as (INTEGER, FLOAT,
+// BOOLEAN, else TEXT). Empty fields are NULL.
+func (e *Executor) readCSV(name, path string, schema *storage.Schema) (*storage.Table, error) {
+	if !e.db.FileReads() {
+		return nil, errorf(ErrUnsupported, "cannot read %s: this server does not allow reading files", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errorf(ErrParameter, "reading CSV: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errorf(ErrParameter, "%s is empty; its first line must name the columns", path)
+	}
+	if err != nil {
+		return nil, errorf(ErrParameter, "reading %s: %w", path, err)
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errorf(ErrParameter, "reading %s: %w", path, err)
+	}
+
+	columns := make([]*storage.Column, len(header))
+	if schema != nil {
+		if len(schema.Columns) != len(header) {
+			return nil, errorf(ErrTypeMismatch, "%s has %d columns, but table %s has %d", path, len(header), name, len(schema.Columns))
+		}
+		copy(columns, schema.Columns)
+	} else {
+		for i, col := range header {
+			columns[i] = storage.NewColumn(strings.TrimSpace(col), csvColumnType(records, i), false, false, false)
+		}
+	}
+	table := newSystemTable(name, columns)
+	for line, record := range records {
+		values := make([]storage.Value, len(columns))
+		for i, col := range columns {
+			if record[i] == "" {
+				values[i] = storage.NullValue{}
+				continue
+			}
+			if values[i], err = storage.ParseValue(col.Type, record[i]); err != nil {
+				return nil, errorf(ErrTypeMismatch, "%s line %d, column %s: %w", path, line+2, col.Name, err)
+			}
+		}
+		if err := e.addFunctionRow(table, values); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// csvColumnType is the narrowest type every non-empty field of column i
+// parses as.
+func csvColumnType(records [][]string, i int) storage.DataType {
+	types := []struct {
+		dataType storage.DataType
+		parses   func(string) bool
+	}{
+		{storage.TypeInteger, func(s string) bool { _, err := strconv.ParseInt(s, 10, 64); return err == nil }},
+		{storage.TypeFloat, func(s string) bool { _, err := strconv.ParseFloat(s, 64); return err == nil }},
+		{storage.TypeBoolean, func(s string) bool { return strings.EqualFold(s, "true") || strings.EqualFold(s, "false") }},
+	}
+	for _, t := range types {
+		all := true
+		for _, record := range records {
+			if record[i] != "" && !t.parses(record[i]) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return t.dataType
+		}
+	}
+	return storage.TypeText
+}
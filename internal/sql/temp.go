@@ -0,0 +1,69 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// TempSchema qualifies the name of a temporary table in a query, as in
+// SELECT * FROM pg_temp.staging, as main does a permanent one.
+const TempSchema = "pg_temp"
+
+// Temporary tables live in a database of the executor's own (see
+// storage.NewTemporaryDatabase), so no other session sees them, and an
+// unqualified name finds a temporary table before a permanent one, as in
+// PostgreSQL. They go away when the session closes or, created with ON
+// COMMIT DROP, when the transaction that created them commits. ON COMMIT
+// DELETE ROWS empties a table at each commit instead, so outside BEGIN its
+// rows last only for the statement that writes them.
+
+// temporary reports whether name refers to a temporary table of the
+// session, and returns its name without the pg_temp qualifier.
+func (e *Executor) temporary(name string) (string, bool) {
+	if alias, table, qualified := strings.Cut(name, "."); qualified {
+		return table, alias == TempSchema
+	}
+	return name, e.temp != nil && e.temp.TableExists(name)
+}
+
+// temporaryTables returns the database of the session's temporary tables,
+// creating it on first use.
+func (e *Executor) temporaryTables() *storage.Database {
+	if e.temp == nil {
+		e.temp = storage.NewTemporaryDatabase()
+	}
+	return e.temp
+}
+
+func (e *Executor) executeCreateTemporaryTable(stmt *CreateTableStatement, schema *storage.Schema) (*Result, error) {
+	if len(stmt.ForeignKeys) > 0 {
+		return nil, errorf(ErrUnsupported, "temporary table %s cannot have foreign keys", stmt.Table)
+	}
+	name, temp := stmt.Table, e.temporaryTables()
+
+	var err error
+	if e.tx != nil {
+		err = e.tx.CreateTemporaryTable(temp, name, schema)
+		if err == nil && stmt.OnCommit == "DROP" {
+			e.tx.OnCommit(func() { temp.DropTable(name) })
+		}
+	} else if stmt.OnCommit != "DROP" {
+		err = temp.CreateTable(name, schema)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if table, err := temp.GetTable(name); err == nil {
+		table.ClearOnCommit = stmt.OnCommit == "DELETE ROWS"
+	}
+	return &Result{Message: fmt.Sprintf("Temporary table %s created", name)}, nil
+}
+
+func (e *Executor) dropTemporaryTable(name string) error {
+	if e.tx != nil {
+		return e.tx.DropTemporaryTable(e.temporaryTables(), name)
+	}
+	return e.temporaryTables().DropTable(name)
+}
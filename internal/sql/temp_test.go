@@ -0,0 +1,57 @@
+package sql_test
+
+import (
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+func TestTemporaryTablesArePrivate(t *testing.T) {
+	db := storage.NewDatabase()
+	var logged []storage.WALChange
+	db.SetCommitHook(func(changes []storage.WALChange) error {
+		logged = append(logged, changes...)
+		return nil
+	})
+	owner := sql.NewSession(db)
+	other := sql.NewSession(db)
+	defer other.Close()
+
+	for _, text := range []string{
+		"CREATE TEMP TABLE scratch (id INTEGER PRIMARY KEY, note TEXT)",
+		"INSERT INTO scratch (id, note) VALUES (1, 'a'), (2, 'b')",
+		"UPDATE scratch SET note = 'c' WHERE id = 2",
+		"DELETE FROM scratch WHERE id = 1",
+	} {
+		if _, err := execSQL(owner, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	if len(logged) != 0 {
+		t.Errorf("temporary table writes reached the WAL: %+v", logged)
+	}
+	if tables := db.ListTables(); len(tables) != 0 {
+		t.Errorf("database tables = %v, want none", tables)
+	}
+
+	if _, err := execSQL(other, "SELECT id FROM scratch"); err == nil {
+		t.Error("another session can read the temporary table")
+	}
+	if _, err := execSQL(other, "CREATE TEMP TABLE scratch (id INTEGER)"); err != nil {
+		t.Errorf("another session cannot create its own scratch: %v", err)
+	}
+
+	result, err := execSQL(owner, "SELECT note FROM scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != "c" {
+		t.Errorf("SELECT note = %v, want [[c]]", result.Rows)
+	}
+
+	owner.Close()
+	if _, err := execSQL(owner, "SELECT note FROM scratch"); err == nil {
+		t.Error("temporary table outlived its session")
+	}
+}
@@ -0,0 +1,37 @@
+-- Execution errors point at the part of the statement that caused them,
+-- with the line and column counted from the start of the statement.
+CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, score INTEGER);
+CREATE TABLE tasks (id INTEGER PRIMARY KEY, user_id INTEGER, title TEXT);
+INSERT INTO users (id, name, score) VALUES (1, 'Ada', 10), (2, 'Ben', 0);
+INSERT INTO tasks (id, user_id, title) VALUES (1, 1, 'Write');
+
+-- Unknown columns in each clause.
+SELECT name FROM users WHERE nmae = 'Ada';
+SELECT id, nam FROM users;
+SELECT name FROM users ORDER BY scor;
+SELECT u.name, t.titel
+FROM users u
+JOIN tasks t ON t.user_id = u.id;
+SELECT name FROM users u JOIN tasks t ON t.user_id = x.id;
+
+-- Unknown tables.
+SELECT * FROM userz;
+SELECT * FROM users JOIN taskz ON taskz.id = users.id;
+INSERT INTO userz (id) VALUES (1);
+UPDATE userz SET name = 'x';
+DELETE FROM userz;
+
+-- Evaluation errors point at the operator.
+SELECT name FROM users
+WHERE score / (id - 2) > 1;
+
+-- Grouping errors.
+SELECT name, COUNT(*) FROM users GROUP BY score;
+SELECT score, COUNT(*) FROM users GROUP BY score ORDER BY name;
+SELECT score, SUM(*) FROM users GROUP BY score;
+
+-- UPDATE and DELETE report errors in WHERE instead of matching nothing,
+-- and change no rows.
+UPDATE users SET score = 1 WHERE nmae = 'Ada';
+DELETE FROM users WHERE score / (id - 2) = 0;
+SELECT id, score FROM users ORDER BY id;
@@ -0,0 +1,141 @@
+CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+CREATE TABLE tasks (id INTEGER PRIMARY KEY, title TEXT, user_id INTEGER);
+
+EXPLAIN SELECT name FROM users WHERE id > 5;
+
+-- Joins nest left-deep: each Nested Loop reads its outer input first.
+EXPLAIN SELECT u.name, t.title, o.name FROM users u JOIN tasks t ON t.user_id = u.id LEFT JOIN users o ON o.id = t.id WHERE t.title LIKE 'a%' ORDER BY u.name LIMIT 10 OFFSET 5;
+
+EXPLAIN SELECT u.name, COUNT(t.id) FROM users u LEFT JOIN tasks t ON t.user_id = u.id GROUP BY u.name;
+
+EXPLAIN UPDATE tasks SET title = 'x' WHERE id = 1;
+EXPLAIN DELETE FROM users;
+
+-- A bare COUNT(*) reads the row count instead of scanning.
+EXPLAIN SELECT COUNT(*) FROM users;
+EXPLAIN SELECT COUNT(*) FROM users WHERE id > 1;
+EXPLAIN INSERT INTO users (id, name) VALUES (1, 'a'), (2, 'b');
+
+-- EXPLAIN does not run the statement.
+SELECT COUNT(*) FROM users;
+
+EXPLAIN (FORMAT DOT) SELECT u.name, t.title FROM users u JOIN tasks t ON t.user_id = u.id WHERE u.name = 'say "hi"';
+
+EXPLAIN SELECT * FROM missing;
+EXPLAIN (FORMAT JSON) SELECT * FROM users;
+EXPLAIN EXPLAIN SELECT * FROM users;
+
+-- EXPLAIN ANALYZE runs the statement and reports the memory each step held.
+INSERT INTO users (id, name) VALUES (1, 'ada'), (2, 'grace'), (3, 'linus');
+INSERT INTO tasks (id, title, user_id) VALUES (1, 'write', 1), (2, 'review', 1), (3, 'ship', 2);
+EXPLAIN ANALYZE SELECT u.name, t.title FROM users u JOIN tasks t ON t.user_id = u.id WHERE t.id > 1;
+EXPLAIN ANALYZE SELECT user_id, COUNT(*) FROM tasks GROUP BY user_id;
+
+-- Without ORDER BY, LIMIT stops the scan and the join early.
+EXPLAIN ANALYZE SELECT name FROM users LIMIT 1;
+EXPLAIN ANALYZE SELECT u.name, t.title FROM users u JOIN tasks t ON t.user_id = u.id LIMIT 1;
+
+EXPLAIN ANALYZE DELETE FROM tasks WHERE id = 3;
+SELECT COUNT(*) FROM tasks;
+EXPLAIN ANALYZE SELECT COUNT(*), COUNT(*) FROM tasks u;
+
+SET work_mem = '512B';
+SELECT u.name, t.title FROM users u JOIN tasks t ON t.user_id = u.id;
+SET work_mem = 'lots';
+SET work_mem = unlimited;
+SELECT u.name, t.title FROM users u JOIN tasks t ON t.user_id = u.id;
+
+-- LIKE with a literal prefix on an indexed column reads an index range.
+CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT UNIQUE, city TEXT);
+INSERT INTO people (id, name, city) VALUES (1, 'Joan', 'Oslo'), (2, 'Bob', 'Rome'), (3, 'John', 'Lima'), (4, 'Jo', 'Oslo'), (5, 'Jp', 'Rome'), (6, 'joe', 'Lima');
+EXPLAIN SELECT name FROM people WHERE name LIKE 'Jo%';
+SELECT name FROM people WHERE name LIKE 'Jo%';
+SELECT id FROM people WHERE city = 'Oslo' AND name LIKE 'Jo_n';
+EXPLAIN SELECT name FROM people WHERE name ILIKE 'jo%';
+EXPLAIN SELECT name FROM people WHERE city LIKE 'O%';
+EXPLAIN SELECT name FROM people WHERE name LIKE '%o';
+
+-- The index follows deletes and updates.
+DELETE FROM people WHERE id = 1;
+UPDATE people SET name = 'Jody' WHERE id = 2;
+SELECT id, name FROM people WHERE name LIKE 'Jo%';
+BEGIN;
+DELETE FROM people WHERE id = 3;
+INSERT INTO people (id, name, city) VALUES (7, 'Jonas', 'Oslo');
+ROLLBACK;
+SELECT id, name FROM people WHERE name LIKE 'Jo%';
+
+-- Functions in FROM position are read by a Function Scan.
+EXPLAIN SELECT g FROM generate_series(1, 10) g WHERE g > 5;
+EXPLAIN SELECT p.name, s.s FROM people p JOIN generate_series(1, 2) s ON s.s = p.id;
+SELECT p.name, s.s FROM people p JOIN generate_series(1, 2) s ON s.s = p.id;
+
+-- A SELECT without FROM reads one row from a Result node.
+EXPLAIN SELECT VERSION(), DATABASE();
+
+-- CREATE INDEX: an equality on the indexed column reads one key, and a
+-- partial index serves queries that repeat its condition.
+CREATE TABLE jobs (id INTEGER PRIMARY KEY, status TEXT, worker INTEGER);
+INSERT INTO jobs (id, status, worker) VALUES (1, 'done', 1), (2, 'pending', 2), (3, 'pending', NULL);
+CREATE INDEX jobs_worker ON jobs (worker);
+CREATE INDEX jobs_pending ON jobs (id) WHERE status = 'pending';
+EXPLAIN SELECT id FROM jobs WHERE worker = 2;
+EXPLAIN SELECT id FROM jobs WHERE status = 'pending';
+EXPLAIN SELECT id FROM jobs WHERE status = 'done';
+EXPLAIN SELECT j.id FROM jobs j WHERE j.status = 'pending' AND j.id = 3;
+SELECT j.id FROM jobs j WHERE j.status = 'pending' AND j.id = 3;
+
+-- An index with INCLUDE columns covering every column the query reads is
+-- read without the table's rows.
+CREATE INDEX jobs_status ON jobs (status) INCLUDE (worker);
+EXPLAIN SELECT worker FROM jobs WHERE status = 'pending';
+EXPLAIN SELECT id, worker FROM jobs WHERE status = 'pending';
+SELECT worker FROM jobs WHERE status = 'pending';
+
+-- After ANALYZE, plan nodes carry row estimates from the statistics: a
+-- histogram for ranges, distinct counts for equalities, joins and groups.
+CREATE TABLE readings (id INTEGER PRIMARY KEY, sensor INTEGER, value INTEGER);
+INSERT INTO readings (id, sensor, value) VALUES (1, 1, 3), (2, 2, 6), (3, 3, 9), (4, 4, 12), (5, 5, NULL), (6, 6, 18), (7, 7, 21), (8, 8, 24), (9, 9, 27), (10, 0, NULL), (11, 1, 33), (12, 2, 36), (13, 3, 39), (14, 4, 42), (15, 5, NULL), (16, 6, 48), (17, 7, 51), (18, 8, 54), (19, 9, 57), (20, 0, NULL), (21, 1, 63), (22, 2, 66), (23, 3, 69), (24, 4, 72), (25, 5, NULL), (26, 6, 78), (27, 7, 81), (28, 8, 84), (29, 9, 87), (30, 0, NULL), (31, 1, 93), (32, 2, 96), (33, 3, 99), (34, 4, 102), (35, 5, NULL), (36, 6, 108), (37, 7, 111), (38, 8, 114), (39, 9, 117), (40, 0, NULL);
+ANALYZE readings;
+SELECT column_name, row_count, null_frac, n_distinct, histogram_bounds FROM rdbms_column_stats WHERE table_name = 'readings';
+EXPLAIN SELECT id FROM readings WHERE value < 30;
+EXPLAIN SELECT id FROM readings WHERE value >= 60 AND sensor = 3;
+EXPLAIN SELECT sensor, COUNT(*) FROM readings GROUP BY sensor;
+EXPLAIN SELECT r.id FROM readings r JOIN readings s ON r.id = s.sensor;
+EXPLAIN DELETE FROM readings WHERE id > 1000;
+
+-- A FULLTEXT index answers MATCH on its column.
+CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT);
+INSERT INTO notes (id, body) VALUES (1, 'Fix the login bug'), (2, 'Write docs'), (3, 'Login page redesign');
+CREATE FULLTEXT INDEX ON notes (body);
+EXPLAIN SELECT id FROM notes WHERE body MATCH 'Login bug';
+EXPLAIN SELECT id FROM notes WHERE id > 1 AND body MATCH 'log*';
+
+-- A TRIGRAM index answers % and similarity comparisons on its column.
+CREATE TRIGRAM INDEX notes_trgm ON notes (body);
+EXPLAIN SELECT id FROM notes WHERE body % 'login bgu';
+EXPLAIN SELECT id FROM notes WHERE similarity(body, 'docs') >= 0.2;
+EXPLAIN SELECT id FROM notes WHERE similarity(body, 'docs') >= 0;
+
+-- Hints in a /*+ ... */ comment after SELECT override the planner:
+-- USE_INDEX and NO_INDEX choose the first table's index, HASH_JOIN joins
+-- on an equality through a hash table. Other comments are ignored.
+EXPLAIN SELECT id FROM jobs WHERE status = 'pending';
+EXPLAIN SELECT /*+ USE_INDEX(jobs jobs_pending) */ id FROM jobs WHERE status = 'pending';
+EXPLAIN SELECT /*+ USE_INDEX(j status) */ id FROM jobs j WHERE status = 'pending' AND worker = 2;
+EXPLAIN SELECT /*+ NO_INDEX(jobs) */ id FROM jobs WHERE worker = 2;
+EXPLAIN SELECT /* not a hint */ id FROM jobs /*+ NO_INDEX(jobs) */ WHERE worker = 2;
+EXPLAIN SELECT /*+ HASH_JOIN */ u.name, t.title FROM users u JOIN tasks t ON t.user_id = u.id LEFT JOIN users o ON o.id = t.id;
+EXPLAIN SELECT /*+ HASH_JOIN(o) */ u.name, t.title FROM users u JOIN tasks t ON t.user_id = u.id LEFT JOIN users o ON o.id = t.id;
+SELECT /*+ HASH_JOIN */ u.name, t.title FROM users u JOIN tasks t ON t.user_id = u.id ORDER BY t.title;
+SELECT /*+ USE_INDEX(jobs missing) */ id FROM jobs;
+SELECT /*+ NO_INDEX(people) */ id FROM jobs;
+SELECT /*+ HASH_JOIN(u) */ u.name FROM users u;
+SELECT /*+ FAST */ id FROM jobs;
+SELECT /*+ NO_INDEX(jobs */ id FROM jobs;
+
+-- A compound SELECT combines the plans of its SELECTs; a Sort and Limit
+-- above them apply to the combined rows. Each SELECT keeps its own index
+-- scans and hints.
+EXPLAIN SELECT name FROM users UNION SELECT title FROM tasks ORDER BY name LIMIT 5;
+EXPLAIN SELECT id FROM jobs WHERE status = 'pending' EXCEPT ALL SELECT /*+ NO_INDEX(jobs) */ id FROM jobs WHERE worker = 2 INTERSECT SELECT id FROM tasks;
@@ -0,0 +1,31 @@
+CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+CREATE TABLE tasks (id INTEGER PRIMARY KEY, title TEXT, user_id INTEGER);
+INSERT INTO users (id, name) VALUES (1, 'Ann'), (2, 'Bob'), (3, 'Cy');
+INSERT INTO tasks (id, title, user_id) VALUES
+    (1, 'docs', 1),
+    (2, 'bug', 2),
+    (3, 'review', 1),
+    (4, 'orphan', NULL);
+
+-- Inner join keeps matching pairs in left-table order.
+SELECT u.name, t.title FROM users u JOIN tasks t ON t.user_id = u.id;
+
+-- Left join keeps users without tasks, with NULLs on the right.
+SELECT u.name, t.title FROM users u LEFT JOIN tasks t ON t.user_id = u.id;
+
+SELECT users.name, tasks.title FROM users JOIN tasks ON tasks.user_id = users.id ORDER BY tasks.title;
+
+-- In a join, * and t.* name each column after its table or alias, so
+-- the two id columns stay apart.
+SELECT * FROM users JOIN tasks ON tasks.user_id = users.id WHERE users.id = 2;
+SELECT u.*, t.title FROM users u JOIN tasks t ON t.user_id = u.id ORDER BY t.title;
+SELECT t.*, u.name FROM users u LEFT JOIN tasks t ON t.user_id = u.id WHERE u.id = 3;
+SELECT x.* FROM users u JOIN tasks t ON t.user_id = u.id;
+
+SELECT u.name, COUNT(t.id) FROM users u LEFT JOIN tasks t ON t.user_id = u.id GROUP BY u.name;
+
+-- Unqualified names must be unique across the joined tables.
+SELECT id FROM users JOIN tasks ON tasks.user_id = users.id;
+
+-- Without a join, * and t.* keep plain column names.
+SELECT u.* FROM users u WHERE u.id = 1;
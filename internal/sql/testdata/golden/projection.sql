@@ -0,0 +1,20 @@
+-- Column order follows the select list; * expands in schema order.
+CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT UNIQUE, score FLOAT);
+INSERT INTO users (id, name, email, score) VALUES
+    (1, 'Ann', 'ann@example.com', 2.5),
+    (2, 'Bob', NULL, 10.0),
+    (3, 'Cy', '', 0.125);
+
+SELECT * FROM users;
+SELECT email, id FROM users WHERE score > 1.0;
+SELECT name FROM users ORDER BY name DESC LIMIT 2;
+SELECT id FROM users WHERE name = 'nobody';
+
+-- Writes report what they changed.
+UPDATE users SET score = 20.5, email = 'bob@example.com' WHERE id = 2;
+DELETE FROM users WHERE id = 3;
+SELECT id, score FROM users;
+
+SELECT missing FROM users;
+INSERT INTO users (id, name) VALUES (1, 'Dup');
+INSERT INTO users (id) VALUES (4);
@@ -0,0 +1,63 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errTimedOut is the cause of the context run gives a statement under a
+// statement_timeout, telling the timeout apart from a deadline the caller
+// set.
+var errTimedOut = errors.New("statement_timeout expired")
+
+// SetStatementTimeout cancels each statement that runs for longer than
+// timeout with an ErrStatementTimeout error; 0 removes the limit. Like
+// other cancellations it is noticed between rows, so a statement stops
+// shortly after the timeout rather than exactly at it.
+func (e *Executor) SetStatementTimeout(timeout time.Duration) {
+	e.timeout = timeout
+}
+
+// withTimeout returns ctx limited to the executor's statement timeout.
+func (e *Executor) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, e.timeout, errTimedOut)
+}
+
+// canceled returns the error for a statement whose context ended with err.
+func (e *Executor) canceled(err error) error {
+	if errors.Is(context.Cause(e.ctx), errTimedOut) {
+		return errorf(ErrStatementTimeout, "query canceled due to timeout: statement_timeout is %s: %w", e.timeout, err)
+	}
+	return errorf(ErrCanceled, "query canceled: %w", err)
+}
+
+// parseStatementTimeout accepts the values SET statement_timeout takes: a
+// number of milliseconds as in PostgreSQL, a duration with a ms, s, min or
+// h unit, or 0 for no timeout.
+func parseStatementTimeout(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{{"ms", time.Millisecond}, {"min", time.Minute}, {"s", time.Second}, {"h", time.Hour}}
+	unit := time.Millisecond
+	lower := strings.ToLower(value)
+	for _, u := range units {
+		if strings.HasSuffix(lower, u.suffix) {
+			unit = u.unit
+			value = strings.TrimSpace(value[:len(value)-len(u.suffix)])
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
@@ -0,0 +1,58 @@
+package sql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer reports to the global TracerProvider, so spans cost nothing until
+// an embedder installs one with otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/mryan-3/rdbms/internal/sql")
+
+// ParseContext lexes and parses query inside a "rdbms.parse" span, or
+// takes it from the plan cache when the same text was parsed before. It
+// also returns the number of bind parameters the statement expects.
+func ParseContext(ctx context.Context, query string) (Node, int, error) {
+	_, span := tracer.Start(ctx, "rdbms.parse")
+
+	if cached, ok := plans.get(query); ok {
+		span.SetAttributes(
+			attribute.String("db.operation", cached.stmt.Type().String()),
+			attribute.Bool("rdbms.plan_cache_hit", true))
+		span.End()
+		return cached.stmt, cached.paramCount, nil
+	}
+
+	parser := NewParser(NewLexer(query))
+	stmt, err := parser.Parse()
+	if err == nil {
+		span.SetAttributes(
+			attribute.String("db.operation", stmt.Type().String()),
+			attribute.Bool("rdbms.plan_cache_hit", false))
+		plans.put(query, stmt, parser.ParamCount())
+	}
+
+	endSpan(span, err)
+	return stmt, parser.ParamCount(), err
+}
+
+func (e *Executor) startSpan(name string, attrs ...attribute.KeyValue) trace.Span {
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	return span
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
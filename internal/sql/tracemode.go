@@ -0,0 +1,82 @@
+package sql
+
+import (
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Trace mode (SET trace = on, or -trace on the command line) logs each step
+// the executor takes: the rows read from every table, the rows each join
+// matched and the rows a predicate rejected, with the first few of those
+// and why. It is for working out why a query returns unexpected results,
+// not for production, and is unrelated to the OpenTelemetry spans in
+// trace.go.
+
+// traceSampleRows is how many rejected rows a step logs before only
+// counting them.
+const traceSampleRows = 5
+
+// SetTracing turns trace mode on or off for this executor.
+func (e *Executor) SetTracing(enabled bool) {
+	e.trace = enabled
+}
+
+func (e *Executor) traceStep(msg string, args ...interface{}) {
+	if !e.trace {
+		return
+	}
+	e.log().Info("trace: "+msg, args...)
+}
+
+// rejections counts the rows turned away in one step and logs the first
+// traceSampleRows of them with the predicate's result.
+type rejections struct {
+	e     *Executor
+	step  string
+	count int
+}
+
+func (r *rejections) add(pred Expression, row *storage.Row, val storage.Value, err error) {
+	r.count++
+	if !r.e.trace || r.count > traceSampleRows {
+		return
+	}
+	reason := "NULL"
+	if err != nil {
+		reason = "error: " + err.Error()
+	} else if val != nil {
+		reason = val.ToString()
+	}
+	r.e.traceStep("rejected row", "step", r.step, "predicate", pred.String(), "row", traceRow(row), "result", reason)
+}
+
+func traceRow(row *storage.Row) string {
+	values := make([]string, len(row.Values))
+	for i, v := range row.Values {
+		values[i] = v.ToString()
+	}
+	return "(" + strings.Join(values, ", ") + ")"
+}
+
+// parseTraceSetting accepts the values SET trace takes.
+func parseTraceSetting(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "on", "true", "yes", "1":
+		return true, true
+	case "off", "false", "no", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// traceWrite logs the scan and filter steps of an UPDATE or DELETE that
+// changed affected rows.
+func (e *Executor) traceWrite(table string, where Expression, affected int, rejected *rejections) {
+	scanned := affected + rejected.count
+	e.traceStep("scan", "table", table, "rows", scanned)
+	if where != nil {
+		e.traceStep("filter", "predicate", where.String(),
+			"rows_in", scanned, "rejected", rejected.count, "rows_out", affected)
+	}
+}
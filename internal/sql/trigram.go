@@ -0,0 +1,118 @@
+package sql
+
+import (
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Fuzzy matching: similarity(a, b) measures how alike two texts are by
+// their trigrams (see storage.Similarity), and a % b is true when that is
+// at least SimilarityThreshold, so 'jonh@example.com' % 'john@example.com'
+// finds a typo. A TRIGRAM index on a column answers % and similarity
+// comparisons on it by reading only the rows that share a trigram with
+// the constant.
+
+// SimilarityThreshold is the similarity from which % holds, as
+// pg_trgm.similarity_threshold defaults to in PostgreSQL.
+const SimilarityThreshold = 0.3
+
+func similarityFunction(args []storage.Value) (storage.Value, error) {
+	if len(args) != 2 {
+		return nil, errorf(ErrParameter, "similarity takes 2 arguments, got %d", len(args))
+	}
+	if args[0].Type() == storage.TypeNull || args[1].Type() == storage.TypeNull {
+		return storage.NullValue{}, nil
+	}
+	return storage.NewFloatValue(storage.Similarity(args[0].ToString(), args[1].ToString())), nil
+}
+
+// evaluateSimilar implements %, which compares texts; NULL gives NULL.
+func evaluateSimilar(left, right storage.Value) (storage.Value, error) {
+	if left.Type() == storage.TypeNull || right.Type() == storage.TypeNull {
+		return storage.NullValue{}, nil
+	}
+	if left.Type() != storage.TypeText || right.Type() != storage.TypeText {
+		return nil, errorf(ErrTypeMismatch, "%% compares TEXT values, got %s and %s", left.Type(), right.Type())
+	}
+	return storage.NewBooleanValue(storage.Similarity(left.ToString(), right.ToString()) >= SimilarityThreshold), nil
+}
+
+// trigramIndex picks a TRIGRAM index of table (known in the query as name)
+// to read the rows where can match from. Among where's ANDs it looks for
+// column % constant, or similarity(column, constant) compared with > or >=
+// to a number that leaves out rows with no trigram in common, on an
+// indexed column. It returns the text of the constant. where is still
+// applied to the rows read.
+func (e *Executor) trigramIndex(where Expression, table *storage.Table, name string, joined bool) (*storage.SecondaryIndex, string, bool) {
+	if where == nil || joined {
+		return nil, "", false
+	}
+	conjuncts := splitAnd(where, nil)
+	for _, idx := range table.SecondaryIndexes() {
+		if !idx.Trigram || !e.indexAllowed(name, idx.Name, idx.Column) {
+			continue
+		}
+		for _, conjunct := range conjuncts {
+			expr, ok := conjunct.(*BinaryExpression)
+			if !ok {
+				continue
+			}
+			var sides [2]Expression
+			switch expr.Op {
+			case "%":
+				sides = [2]Expression{expr.Left, expr.Right}
+			case ">", ">=":
+				call, ok := expr.Left.(*FunctionCall)
+				if !ok || call.Name != "similarity" || len(call.Arguments) != 2 {
+					continue
+				}
+				bound, ok := e.constantValue(expr.Right)
+				if !ok || !excludesZero(bound, expr.Op) {
+					continue
+				}
+				sides = [2]Expression{call.Arguments[0], call.Arguments[1]}
+			default:
+				continue
+			}
+			for _, pair := range [][2]Expression{sides, {sides[1], sides[0]}} {
+				ref, ok := pair[0].(*ColumnRef)
+				if !ok || ref.Column != idx.Column || ref.Table != "" && ref.Table != name {
+					continue
+				}
+				if text, ok := e.constantValue(pair[1]); ok && text.Type() == storage.TypeText {
+					return idx, text.ToString(), true
+				}
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// constantValue returns the value of a literal or parameter.
+func (e *Executor) constantValue(expr Expression) (storage.Value, bool) {
+	var v storage.Value
+	var err error
+	switch expr := expr.(type) {
+	case *LiteralExpression:
+		v, err = expr.parseLiteral()
+	case *Parameter:
+		v, err = e.paramValue(expr)
+	default:
+		return nil, false
+	}
+	return v, err == nil
+}
+
+// excludesZero reports whether similarity op bound is false for a
+// similarity of 0.
+func excludesZero(bound storage.Value, op string) bool {
+	var f float64
+	switch bound := bound.(type) {
+	case *storage.IntegerValue:
+		f = float64(bound.Value)
+	case *storage.FloatValue:
+		f = bound.Value
+	default:
+		return false
+	}
+	return f > 0 || f == 0 && op == ">"
+}
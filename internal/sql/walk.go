@@ -0,0 +1,265 @@
+package sql
+
+// Inspect and Rewrite go through the expressions of a tree, so that a pass
+// over expressions (collecting aggregates, running subqueries, replacing
+// literals with parameters) need only handle the nodes it is about.
+// InspectStatement and RewriteStatement do the same for every expression
+// of a statement, including the other SELECTs of a UNION, INTERSECT or
+// EXCEPT.
+//
+// Neither enters the SELECT of an IN subquery: its columns are resolved
+// against its own tables, so a pass that wants it handles the
+// *InExpression itself. Names kept as text, such as the stars of a
+// SELECT list and the GROUP BY and ORDER BY keys, are not expressions and
+// are not visited.
+
+// Inspect calls fn for expr and, while fn returns true, for the
+// expressions it is made of, depth first. A nil expr is skipped.
+func Inspect(expr Expression, fn func(Expression) bool) {
+	if expr == nil || !fn(expr) {
+		return
+	}
+	for _, child := range children(expr) {
+		Inspect(child, fn)
+	}
+}
+
+// children returns the expressions expr is made of, in the order they are
+// written.
+func children(expr Expression) []Expression {
+	switch expr := expr.(type) {
+	case *BinaryExpression:
+		return []Expression{expr.Left, expr.Right}
+	case *UnaryExpression:
+		return []Expression{expr.Right}
+	case *InExpression:
+		return append([]Expression{expr.Left}, expr.Values...)
+	case *CastExpression:
+		return []Expression{expr.Expr}
+	case *FunctionCall:
+		if expr.Filter != nil {
+			return append(append([]Expression(nil), expr.Arguments...), expr.Filter)
+		}
+		return expr.Arguments
+	}
+	return nil
+}
+
+// Rewrite returns expr with each of its expressions replaced by what fn
+// returns for it, bottom up: fn sees an expression after its operands
+// have been rewritten. expr is not modified; an expression whose operands
+// changed is copied, the others are shared with it.
+func Rewrite(expr Expression, fn func(Expression) Expression) Expression {
+	if expr == nil {
+		return nil
+	}
+	switch e := expr.(type) {
+	case *BinaryExpression:
+		left, right := Rewrite(e.Left, fn), Rewrite(e.Right, fn)
+		if left != e.Left || right != e.Right {
+			c := *e
+			c.Left, c.Right = left, right
+			expr = &c
+		}
+	case *UnaryExpression:
+		if right := Rewrite(e.Right, fn); right != e.Right {
+			c := *e
+			c.Right = right
+			expr = &c
+		}
+	case *InExpression:
+		left := Rewrite(e.Left, fn)
+		values, changed := rewriteList(e.Values, fn)
+		if left != e.Left || changed {
+			c := *e
+			c.Left, c.Values = left, values
+			expr = &c
+		}
+	case *CastExpression:
+		if inner := Rewrite(e.Expr, fn); inner != e.Expr {
+			c := *e
+			c.Expr = inner
+			expr = &c
+		}
+	case *FunctionCall:
+		if call := rewriteCall(e, fn); call != e {
+			expr = call
+		}
+	}
+	return fn(expr)
+}
+
+// rewriteCall rewrites the arguments and FILTER of call, returning call
+// itself when none changed.
+func rewriteCall(call *FunctionCall, fn func(Expression) Expression) *FunctionCall {
+	args, changed := rewriteList(call.Arguments, fn)
+	filter := Rewrite(call.Filter, fn)
+	if !changed && filter == call.Filter {
+		return call
+	}
+	c := *call
+	c.Arguments, c.Filter = args, filter
+	return &c
+}
+
+// rewriteList rewrites each of exprs, returning a new slice, and true,
+// when any changed.
+func rewriteList(exprs []Expression, fn func(Expression) Expression) ([]Expression, bool) {
+	var result []Expression
+	for i, expr := range exprs {
+		rewritten := Rewrite(expr, fn)
+		if rewritten != expr && result == nil {
+			result = append([]Expression(nil), exprs...)
+		}
+		if result != nil {
+			result[i] = rewritten
+		}
+	}
+	if result == nil {
+		return exprs, false
+	}
+	return result, true
+}
+
+// InspectStatement calls Inspect for each expression of stmt, in the
+// order they are written.
+func InspectStatement(stmt Node, fn func(Expression) bool) {
+	switch s := stmt.(type) {
+	case *SelectStatement:
+		for _, operand := range compoundOperands(s) {
+			for _, col := range operand.Columns {
+				Inspect(col.Expr, fn)
+			}
+			for _, table := range operand.Tables {
+				if table.Function != nil {
+					inspectList(table.Function.Arguments, fn)
+				}
+			}
+			for _, join := range operand.Joins {
+				if join.Function != nil {
+					inspectList(join.Function.Arguments, fn)
+				}
+				inspectList(join.Conditions, fn)
+			}
+			Inspect(operand.Where, fn)
+			Inspect(operand.Having, fn)
+		}
+	case *InsertStatement:
+		for _, row := range s.Values {
+			inspectList(row, fn)
+		}
+	case *UpdateStatement:
+		for _, set := range s.SetClauses {
+			Inspect(set.Value, fn)
+		}
+		Inspect(s.Where, fn)
+	case *DeleteStatement:
+		Inspect(s.Where, fn)
+	case *CreateIndexStatement:
+		Inspect(s.Where, fn)
+	case *AlterTableStatement:
+		Inspect(s.Using, fn)
+	case *NotifyStatement:
+		Inspect(s.Payload, fn)
+	case *ExplainStatement:
+		InspectStatement(s.Statement, fn)
+	}
+}
+
+func inspectList(exprs []Expression, fn func(Expression) bool) {
+	for _, expr := range exprs {
+		Inspect(expr, fn)
+	}
+}
+
+// RewriteStatement returns stmt with each of its expressions rewritten by
+// Rewrite. stmt is not modified: the statement returned is a copy, which
+// shares with it what did not change. A rewritten item of a SELECT list
+// is named by its new SQL text, as the parser names it, and the
+// aggregates listed for the SELECT are those of the new list.
+func RewriteStatement(stmt Node, fn func(Expression) Expression) Node {
+	switch s := stmt.(type) {
+	case *SelectStatement:
+		return rewriteSelect(s, fn)
+	case *InsertStatement:
+		c := *s
+		c.Values = make([][]Expression, len(s.Values))
+		for i, row := range s.Values {
+			c.Values[i], _ = rewriteList(row, fn)
+		}
+		return &c
+	case *UpdateStatement:
+		c := *s
+		c.SetClauses = make([]SetClause, len(s.SetClauses))
+		for i, set := range s.SetClauses {
+			c.SetClauses[i] = SetClause{Column: set.Column, Value: Rewrite(set.Value, fn)}
+		}
+		c.Where = Rewrite(s.Where, fn)
+		return &c
+	case *DeleteStatement:
+		c := *s
+		c.Where = Rewrite(s.Where, fn)
+		return &c
+	case *CreateIndexStatement:
+		c := *s
+		c.Where = Rewrite(s.Where, fn)
+		return &c
+	case *AlterTableStatement:
+		c := *s
+		c.Using = Rewrite(s.Using, fn)
+		return &c
+	case *NotifyStatement:
+		c := *s
+		c.Payload = Rewrite(s.Payload, fn)
+		return &c
+	case *ExplainStatement:
+		c := *s
+		c.Statement = RewriteStatement(s.Statement, fn)
+		return &c
+	}
+	return stmt
+}
+
+func rewriteSelect(s *SelectStatement, fn func(Expression) Expression) *SelectStatement {
+	c := *s
+	c.Columns = make([]SelectItem, len(s.Columns))
+	c.Aggregates = nil
+	for i, col := range s.Columns {
+		if col.Expr != nil {
+			rewritten := Rewrite(col.Expr, fn)
+			if rewritten != col.Expr {
+				col.Name, col.Expr = rewritten.String(), rewritten
+			}
+			c.Aggregates = aggregateCalls(col.Expr, c.Aggregates)
+		}
+		c.Columns[i] = col
+	}
+
+	c.Tables = make([]TableRef, len(s.Tables))
+	for i, table := range s.Tables {
+		if table.Function != nil {
+			table.Function = rewriteCall(table.Function, fn)
+		}
+		c.Tables[i] = table
+	}
+	c.Joins = make([]*JoinClause, len(s.Joins))
+	for i, join := range s.Joins {
+		j := *join
+		if j.Function != nil {
+			j.Function = rewriteCall(j.Function, fn)
+		}
+		j.Conditions, _ = rewriteList(join.Conditions, fn)
+		c.Joins[i] = &j
+	}
+	c.Where = Rewrite(s.Where, fn)
+	c.Having = Rewrite(s.Having, fn)
+
+	if len(s.Compound) > 0 {
+		c.Compound = make([]SetOperation, len(s.Compound))
+		for i, op := range s.Compound {
+			op.Select = rewriteSelect(op.Select, fn)
+			c.Compound[i] = op
+		}
+	}
+	return &c
+}
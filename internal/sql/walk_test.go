@@ -0,0 +1,156 @@
+package sql_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+func TestInspectStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string // the column references, in order
+	}{
+		{"SELECT id, price * quantity, SUM(total) FILTER (WHERE paid) FROM orders WHERE status = 'open' AND id IN (1, user_id)", "id price quantity total paid status id user_id"},
+		{"SELECT u.name FROM users u JOIN tasks t ON t.user_id = u.id HAVING COUNT(t.id) > 1 UNION SELECT name FROM staff WHERE active", "u.name t.user_id u.id t.id name active"},
+		{"SELECT id FROM users WHERE id IN (SELECT user_id FROM tasks WHERE done)", "id id"},
+		{"INSERT INTO t (a, b) VALUES (1, a + 1), (2, UPPER(b))", "a b"},
+		{"UPDATE t SET a = b * 2 WHERE c IS DISTINCT FROM d", "b c d"},
+		{"EXPLAIN DELETE FROM t WHERE NOT (a = 1 OR CAST(b AS TEXT) = 'x')", "a b"},
+		{"SELECT * FROM generate_series(1, n)", "n"},
+	}
+	for _, tt := range tests {
+		stmt, err := sql.NewParser(sql.NewLexer(tt.query)).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		var refs []string
+		sql.InspectStatement(stmt, func(expr sql.Expression) bool {
+			if ref, ok := expr.(*sql.ColumnRef); ok {
+				refs = append(refs, ref.String())
+			}
+			return true
+		})
+		if got := strings.Join(refs, " "); got != tt.want {
+			t.Errorf("%s: visited %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	stmt, err := sql.NewParser(sql.NewLexer("SELECT a FROM t WHERE UPPER(b) = c AND LOWER(d) = e")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var refs []string
+	sql.Inspect(stmt.(*sql.SelectStatement).Where, func(expr sql.Expression) bool {
+		if ref, ok := expr.(*sql.ColumnRef); ok {
+			refs = append(refs, ref.String())
+		}
+		_, call := expr.(*sql.FunctionCall)
+		return !call
+	})
+	if got := strings.Join(refs, " "); got != "c e" {
+		t.Errorf("visited %q, want the columns outside the calls", got)
+	}
+}
+
+// TestRewriteStatement replaces literals with parameters, as a query
+// normalizer would, and checks that the statement rewritten is unchanged.
+func TestRewriteStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			"SELECT id, price * 2, COUNT(*) FILTER (WHERE qty > 10) FROM orders WHERE status = 'open' AND id IN (1, 2) GROUP BY id",
+			"SELECT id, price * $1, COUNT(*) FILTER (WHERE qty > $2) FROM orders WHERE status = $3 AND id IN ($4, $5) GROUP BY id",
+		},
+		{
+			"SELECT name FROM staff WHERE id = 1 UNION SELECT name FROM customers WHERE id = 2 LIMIT 5",
+			"SELECT name FROM staff WHERE id = $1 UNION SELECT name FROM customers WHERE id = $2 LIMIT 5",
+		},
+		{
+			"INSERT INTO t (a, b) VALUES (1, 'x'), (2, NULL)",
+			"INSERT INTO t (a, b) VALUES ($1, $2), ($3, NULL)",
+		},
+		{
+			"UPDATE t SET a = a + 1 WHERE b = 'y'",
+			"UPDATE t SET a = a + $1 WHERE b = $2",
+		},
+		{
+			"DROP TABLE t",
+			"DROP TABLE t",
+		},
+	}
+	for _, tt := range tests {
+		stmt, err := sql.NewParser(sql.NewLexer(tt.query)).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		before := stmt.String()
+		n := 0
+		rewritten := sql.RewriteStatement(stmt, func(expr sql.Expression) sql.Expression {
+			if _, ok := expr.(*sql.LiteralExpression); ok {
+				n++
+				return &sql.Parameter{Index: n}
+			}
+			return expr
+		})
+		if got := rewritten.String(); got != tt.want {
+			t.Errorf("%s: rewritten to\n%s\nwant\n%s", tt.query, got, tt.want)
+		}
+		if stmt.String() != before {
+			t.Errorf("%s: modified to %s", tt.query, stmt)
+		}
+		reparsed, err := sql.NewParser(sql.NewLexer(tt.want)).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.want, err)
+		}
+		if sql.Format(rewritten) != sql.Format(reparsed) {
+			t.Errorf("%s: formats as\n%s\nwant\n%s", tt.query, sql.Format(rewritten), sql.Format(reparsed))
+		}
+	}
+}
+
+// TestRewriteSelectList checks that a rewritten SELECT list runs with the
+// names and aggregates the parser would have given it.
+func TestRewriteSelectList(t *testing.T) {
+	session := sql.NewSession(storage.NewDatabase())
+	defer session.Close()
+	for _, text := range []string{
+		"CREATE TABLE t (id INTEGER PRIMARY KEY, n INTEGER)",
+		"INSERT INTO t VALUES (1, 10), (2, 20)",
+	} {
+		if _, err := execSQL(session, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	stmt, err := sql.NewParser(sql.NewLexer("SELECT SUM(n) + 1, COUNT(*) FROM t")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewritten := sql.RewriteStatement(stmt, func(expr sql.Expression) sql.Expression {
+		if lit, ok := expr.(*sql.LiteralExpression); ok {
+			n, _ := strconv.Atoi(lit.Value)
+			return &sql.LiteralExpression{Value: strconv.Itoa(n * 100)}
+		}
+		if call, ok := expr.(*sql.FunctionCall); ok && call.Name == "COUNT" {
+			return &sql.FunctionCall{Name: "MAX", Arguments: []sql.Expression{&sql.ColumnRef{Column: "id"}}}
+		}
+		return expr
+	})
+	result, err := session.Execute(rewritten)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(result.Columns, ", "); got != "SUM(n) + 100, MAX(id)" {
+		t.Errorf("columns %s", got)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != "130" || result.Rows[0][1] != "2" {
+		t.Errorf("rows %v", result.Rows)
+	}
+}
@@ -0,0 +1,181 @@
+package sqltest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Golden tests run a script of statements, each ending with a ; at the end
+// of a line, and compare a transcript of the results with the file next to
+// it that has the .golden extension. The transcript echoes each statement
+// (and any -- comment lines before it) followed by its result: a table
+// with a header and a row count, the result message, or ERROR: and the
+// error. Rows are printed in the order the executor returns them, so a
+// change to projection, joins or sorting shows up as a diff.
+
+// RunGolden runs the script at path and compares its transcript with
+// path's .golden file, or rewrites that file when update is set. The
+// script is run twice on fresh databases to catch output that depends on
+// map iteration order or other non-determinism.
+func RunGolden(t *testing.T, path string, update bool) {
+	t.Helper()
+	script, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Transcript(string(script))
+	if again := Transcript(string(script)); again != got {
+		t.Fatalf("%s: output differs between runs:\n%s", path, diffLines(got, again))
+	}
+
+	golden := strings.TrimSuffix(path, ".sql") + ".golden"
+	if update {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s does not match (run with -update to accept the new output):\n%s", golden, diffLines(string(want), got))
+	}
+}
+
+// Transcript runs script against a new database and returns the output
+// RunGolden compares.
+func Transcript(script string) string {
+	session := sql.NewSession(storage.NewDatabase())
+	defer session.Close()
+
+	var out bytes.Buffer
+	var stmt []string
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if len(stmt) == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			if trimmed != "" {
+				fmt.Fprintln(&out, line)
+			}
+			continue
+		}
+		stmt = append(stmt, line)
+		if strings.HasSuffix(trimmed, ";") {
+			text := strings.Join(stmt, "\n")
+			fmt.Fprintln(&out, text)
+			result, err := execute(session, strings.TrimSuffix(strings.TrimSpace(text), ";"))
+			writeResult(&out, result, err)
+			out.WriteString("\n")
+			stmt = nil
+		}
+	}
+	if len(stmt) > 0 {
+		fmt.Fprintf(&out, "%s\nERROR: statement is missing its terminating ;\n", strings.Join(stmt, "\n"))
+	}
+	return out.String()
+}
+
+func writeResult(out *bytes.Buffer, result *sql.Result, err error) {
+	switch {
+	case err != nil:
+		fmt.Fprintf(out, "ERROR: %v\n", err)
+	case len(result.Columns) > 0:
+		writeTable(out, result)
+	case result.Message != "":
+		fmt.Fprintln(out, result.Message)
+	default:
+		fmt.Fprintf(out, "OK, %d rows affected\n", result.RowsAffected)
+	}
+}
+
+// writeTable prints a result as aligned columns separated by |.
+func writeTable(out *bytes.Buffer, result *sql.Result) {
+	rows := FormatRowCells(result)
+	widths := make([]int, len(result.Columns))
+	for i, c := range result.Columns {
+		widths[i] = len(c)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i < len(widths) && len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	writeRow := func(cells []string) {
+		padded := make([]string, len(cells))
+		for i, cell := range cells {
+			if i < len(widths) && i < len(cells)-1 {
+				cell += strings.Repeat(" ", widths[i]-len(cell))
+			}
+			padded[i] = cell
+		}
+		fmt.Fprintln(out, strings.Join(padded, " | "))
+	}
+	writeRow(result.Columns)
+	rules := make([]string, len(widths))
+	for i, w := range widths {
+		rules[i] = strings.Repeat("-", w)
+	}
+	fmt.Fprintln(out, strings.Join(rules, "-+-"))
+	for _, row := range rows {
+		writeRow(row)
+	}
+	if len(rows) == 1 {
+		fmt.Fprintln(out, "(1 row)")
+	} else {
+		fmt.Fprintf(out, "(%d rows)\n", len(rows))
+	}
+}
+
+// FormatRowCells returns a result's rows as strings, using the typed
+// values when the executor provides them.
+func FormatRowCells(result *sql.Result) [][]string {
+	if len(result.Values) != len(result.Rows) {
+		return result.Rows
+	}
+	cells := make([][]string, len(result.Values))
+	for i, row := range result.Values {
+		cells[i] = make([]string, len(row))
+		for j, v := range row {
+			cells[i][j] = formatValue(v)
+		}
+	}
+	return cells
+}
+
+// diffLines describes the first line where want and got differ, with a
+// little context.
+func diffLines(want, got string) string {
+	w := strings.Split(want, "\n")
+	g := strings.Split(got, "\n")
+	i := 0
+	for i < len(w) && i < len(g) && w[i] == g[i] {
+		i++
+	}
+	from := i - 3
+	if from < 0 {
+		from = 0
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "first difference at line %d\n", i+1)
+	for j := from; j < i; j++ {
+		fmt.Fprintf(&b, "  %s\n", w[j])
+	}
+	for j := i; j < i+3 && j < len(w); j++ {
+		fmt.Fprintf(&b, "- %s\n", w[j])
+	}
+	for j := i; j < i+3 && j < len(g); j++ {
+		fmt.Fprintf(&b, "+ %s\n", g[j])
+	}
+	return b.String()
+}
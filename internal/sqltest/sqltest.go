@@ -0,0 +1,203 @@
+// Package sqltest runs SQL logic test files, in the spirit of
+// sqllogictest, against a fresh Database. A file is a list of records
+// separated by blank lines; lines starting with # are comments.
+//
+//	statement ok
+//	CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)
+//
+//	statement error unique constraint
+//	INSERT INTO t (id, name) VALUES (1, 'a'), (1, 'b')
+//
+//	query rowsort
+//	SELECT id, name FROM t
+//	----
+//	1 a
+//
+// "statement ok" must succeed. "statement error" and "query error" must
+// fail, with the rest of the line (if any) in the error message. A query
+// is followed by ---- and one line per expected row, with values separated
+// by single spaces, NULL for NULL and (empty) for the empty string; rowsort
+// compares the rows in sorted order. The SQL may span several lines.
+package sqltest
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Record is one statement or query and what it should produce.
+type Record struct {
+	Line    int
+	Query   bool
+	SQL     string
+	Error   bool
+	Message string   // substring of the expected error
+	RowSort bool     // compare rows in sorted order
+	Rows    []string // expected rows, for queries
+}
+
+// ParseFile reads the records in a .sqltest file.
+func ParseFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	var rec *Record
+	inRows := false
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case rec == nil && (text == "" || strings.HasPrefix(text, "#")):
+		case rec == nil:
+			r, err := parseHeader(text)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			r.Line = line
+			rec = &r
+			inRows = false
+		case text == "":
+			records = append(records, *rec)
+			rec = nil
+		case text == "----" && rec.Query && !rec.Error && !inRows:
+			inRows = true
+		case inRows:
+			rec.Rows = append(rec.Rows, text)
+		default:
+			if rec.SQL != "" {
+				rec.SQL += "\n"
+			}
+			rec.SQL += text
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		records = append(records, *rec)
+	}
+
+	for _, r := range records {
+		if r.SQL == "" {
+			return nil, fmt.Errorf("%s:%d: record has no SQL", path, r.Line)
+		}
+	}
+	return records, nil
+}
+
+func parseHeader(text string) (Record, error) {
+	fields := strings.Fields(text)
+	var r Record
+	switch fields[0] {
+	case "statement":
+		if len(fields) < 2 || fields[1] != "ok" && fields[1] != "error" {
+			return r, fmt.Errorf("expected \"statement ok\" or \"statement error\", got %q", text)
+		}
+	case "query":
+		r.Query = true
+		if len(fields) > 1 && fields[1] == "rowsort" {
+			r.RowSort = true
+		} else if len(fields) > 1 && fields[1] != "error" {
+			return r, fmt.Errorf("unknown query option %q", fields[1])
+		}
+	default:
+		return r, fmt.Errorf("expected a statement or query record, got %q", text)
+	}
+	if len(fields) > 1 && fields[1] == "error" {
+		r.Error = true
+		r.Message = strings.TrimSpace(strings.SplitN(text, "error", 2)[1])
+	}
+	return r, nil
+}
+
+// RunFile runs the records in path, in order, against a new database and
+// reports each mismatch as a test error.
+func RunFile(t *testing.T, path string) {
+	t.Helper()
+	records, err := ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := sql.NewSession(storage.NewDatabase())
+	defer session.Close()
+	for _, r := range records {
+		if err := Run(session, r); err != nil {
+			t.Errorf("%s:%d: %v", path, r.Line, err)
+		}
+	}
+}
+
+// Run executes one record on session and describes any mismatch.
+func Run(session *sql.Session, r Record) error {
+	result, err := execute(session, r.SQL)
+	if r.Error {
+		if err == nil {
+			return fmt.Errorf("expected an error, but it succeeded\n%s", r.SQL)
+		}
+		if !strings.Contains(err.Error(), r.Message) {
+			return fmt.Errorf("expected an error containing %q, got: %v\n%s", r.Message, err, r.SQL)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%v\n%s", err, r.SQL)
+	}
+	if !r.Query {
+		return nil
+	}
+
+	got := FormatRows(result.Values)
+	want := r.Rows
+	if r.RowSort {
+		want = append([]string(nil), want...)
+		sort.Strings(got)
+		sort.Strings(want)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		return fmt.Errorf("wrong result for\n%s\n--- want\n%s\n--- got\n%s", r.SQL, strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	return nil
+}
+
+func execute(session *sql.Session, text string) (*sql.Result, error) {
+	stmt, err := sql.NewParser(sql.NewLexer(text)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	return session.Execute(stmt)
+}
+
+// FormatRows renders rows the way records write them.
+func FormatRows(rows [][]storage.Value) []string {
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		values := make([]string, len(row))
+		for j, v := range row {
+			values[j] = formatValue(v)
+		}
+		lines[i] = strings.Join(values, " ")
+	}
+	return lines
+}
+
+func formatValue(v storage.Value) string {
+	if _, null := v.(storage.NullValue); null || v == nil {
+		return "NULL"
+	}
+	if s := v.ToString(); s != "" {
+		return s
+	}
+	return "(empty)"
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// alteration undoes AlterColumnType.
+type alteration struct {
+	schema *Schema
+	values map[*Row][]Value
+}
+
+// AlterColumnType changes the type of column to typ and gives each row the
+// value convert returns for it, which must be of type typ or NULL. Every
+// row is converted before any is changed, so a failure, reported with the
+// number of the row in scan order, leaves the table as it was. The
+// converted values must keep the column's NOT NULL, UNIQUE and PRIMARY
+// KEY constraints. Columns in a foreign key, and columns with a full-text
+// or trigram index other than to TEXT, cannot change type.
+func (tx *Tx) AlterColumnType(table *Table, column string, typ DataType, convert func(*Row) (Value, error)) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	if err := tx.db.checkAlterColumn(table, column, typ); err != nil {
+		return err
+	}
+
+	table.mu.Lock()
+	defer table.mu.Unlock()
+
+	i := table.Schema().ColumnIndex(column)
+	if i < 0 {
+		return errorf(ErrColumnNotFound, "column %s not found in table %s", column, table.Name)
+	}
+	col := *table.Schema().Columns[i]
+	col.Type = typ
+	if col.Default != nil && col.Default.Type() != TypeNull && col.Default.Type() != typ {
+		return errorf(ErrTypeMismatch, "default %s of column %s is not of type %s", col.Default.ToString(), column, typ)
+	}
+
+	converted := make([]Value, len(table.Rows))
+	seen := make(map[string]int)
+	for n, row := range table.Rows {
+		v, err := convert(row)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", n+1, err)
+		}
+		if v == nil {
+			v = NullValue{}
+		}
+		if v.Type() != TypeNull && v.Type() != typ {
+			return errorf(ErrTypeMismatch, "row %d: conversion gave %s, a %s, not a %s", n+1, v.ToString(), v.Type(), typ)
+		}
+		if v.Type() == TypeNull && (col.NotNull || col.PrimaryKey) {
+			return errorf(ErrNotNullViolation, "row %d: conversion gave NULL for NOT NULL column %s", n+1, column)
+		}
+		if col.PrimaryKey || col.Unique {
+			if v.Type() != TypeNull || col.NullsNotDistinct {
+				key := fmt.Sprintf("%d:%s", v.Type(), v.ToString())
+				if first, ok := seen[key]; ok {
+					return errorf(ErrUniqueViolation, "rows %d and %d: conversion gave both %s in unique column %s", first, n+1, v.ToString(), column)
+				}
+				seen[key] = n + 1
+			}
+		}
+		converted[n] = v
+	}
+
+	schema := &Schema{Columns: make([]*Column, len(table.Schema().Columns))}
+	copy(schema.Columns, table.Schema().Columns)
+	schema.Columns[i] = &col
+
+	undo := &alteration{schema: table.Schema(), values: make(map[*Row][]Value, len(table.Rows))}
+	entry := undoEntry{table: table, altered: undo}
+	if !table.Temporary {
+		entry.wal = append(entry.wal, WALChange{Op: WALAlterColumn, Table: table.Name, Columns: []string{column}, Schema: schema})
+	}
+	columns := schema.ColumnNames()
+	for n, row := range table.Rows {
+		undo.values[row] = row.Values
+		values := make([]Value, len(row.Values))
+		copy(values, row.Values)
+		values[i] = converted[n]
+		row.Values = values
+		if !table.Temporary {
+			entry.wal = append(entry.wal, WALChange{
+				Op:      WALUpdate,
+				Table:   table.Name,
+				Columns: columns,
+				Before:  cloneValues(undo.values[row]),
+				After:   cloneValues(values),
+			})
+		}
+	}
+	table.schema.Store(schema)
+	table.reindex()
+	table.countWrites(len(table.Rows))
+	table.modified = time.Now()
+
+	tx.undo = append(tx.undo, entry)
+	return nil
+}
+
+// checkAlterColumn rejects a type change that foreign keys or text
+// indexes depend on the column's type for.
+func (db *Database) checkAlterColumn(table *Table, column string, typ DataType) error {
+	for _, fk := range table.GetForeignKeys() {
+		for _, c := range fk.Columns {
+			if c == column {
+				return errorf(ErrConstraintViolation, "cannot change the type of column %s: it is in a foreign key to %s", column, fk.RefTable)
+			}
+		}
+	}
+	for _, ref := range db.referencing(table.Name) {
+		for _, c := range ref.fk.RefColumns {
+			if c == column {
+				return errorf(ErrConstraintViolation, "cannot change the type of column %s: foreign key of %s refers to it", column, ref.table.Name)
+			}
+		}
+	}
+	if typ != TypeText {
+		for _, idx := range table.SecondaryIndexes() {
+			if idx.Column == column && idx.Kind() != "" {
+				return errorf(ErrTypeMismatch, "cannot change the type of column %s to %s: %s index %s needs TEXT", column, typ, idx.Kind(), idx.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// revertAlteration restores the schema and values AlterColumnType
+// replaced. Callers must hold t.mu.
+func (t *Table) revertAlteration(a *alteration) {
+	for row, values := range a.values {
+		row.Values = values
+	}
+	t.schema.Store(a.schema)
+	t.reindex()
+}
+
+// applyAlterColumn applies an alter_column change from the WAL. The rows
+// keep their old values until the update changes after it.
+func (t *Table) applyAlterColumn(change WALChange) error {
+	if change.Schema == nil || len(change.Schema.Columns) != len(t.Schema().Columns) {
+		return fmt.Errorf("alter_column change for table %s has no matching schema", t.Name)
+	}
+	schema := &Schema{Columns: make([]*Column, len(change.Schema.Columns))}
+	for i, col := range change.Schema.Columns {
+		c := *col
+		schema.Columns[i] = &c
+	}
+	t.schema.Store(schema)
+	return nil
+}
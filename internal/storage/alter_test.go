@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAlterColumnTypeReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rdbms.wal")
+	db := NewDatabase()
+	if _, err := db.OpenWALFile(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	schema.AddColumn(NewColumn("amount", TypeText, false, false, false))
+	tx := db.Begin()
+	if err := tx.CreateTable("payments", schema); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("payments")
+	for i, amount := range []string{"1.25", "30"} {
+		if _, err := tx.Insert(table, NewRow([]Value{NewIntegerValue(int64(i + 1)), NewTextValue(amount)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	toFloat := func(row *Row) (Value, error) {
+		v, _ := row.Get(1)
+		return ParseValue(TypeFloat, v.ToString())
+	}
+	tx = db.Begin()
+	if err := tx.AlterColumnType(table, "amount", TypeFloat, toFloat); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CloseWALFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	replica := NewDatabase()
+	if _, err := replica.OpenWALFile(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer replica.CloseWALFile()
+	replayed, err := replica.GetTable("payments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if col, _ := replayed.Schema().GetColumn("amount"); col.Type != TypeFloat {
+		t.Errorf("replayed amount is %s, want FLOAT", col.Type)
+	}
+	for i, want := range []float64{1.25, 30} {
+		v, _ := replayed.Rows[i].Get(1)
+		if f, ok := v.(*FloatValue); !ok || f.Value != want {
+			t.Errorf("row %d: amount = %s (%s), want %g", i+1, v.ToString(), v.Type(), want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+// MainDatabase is the name a database's own tables are qualified with
+// ("main.users"); it cannot be used as the alias of an attached database.
+const MainDatabase = "main"
+
+// Attach makes the tables of other readable through db as alias.table.
+// Attachments are not logged to the WAL, so replicas and backups of db do
+// not include them.
+func (db *Database) Attach(alias string, other *Database) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if alias == MainDatabase {
+		return errorf(ErrTableExists, "%s is the database's own name", MainDatabase)
+	}
+	if _, exists := db.attached[alias]; exists {
+		return errorf(ErrTableExists, "database %s is already attached", alias)
+	}
+	db.attached[alias] = other
+	return nil
+}
+
+func (db *Database) Detach(alias string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, exists := db.attached[alias]; !exists {
+		return errorf(ErrTableNotFound, "database %s is not attached", alias)
+	}
+	delete(db.attached, alias)
+	return nil
+}
+
+// Attached returns the database attached as alias.
+func (db *Database) Attached(alias string) (*Database, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	other, exists := db.attached[alias]
+	return other, exists
+}
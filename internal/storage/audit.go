@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditEntry records one DDL or DML statement: who ran it, when, and how
+// many rows it touched.
+type AuditEntry struct {
+	ID           int       `json:"id"`
+	Time         time.Time `json:"time"`
+	User         string    `json:"user"`
+	Operation    string    `json:"operation"`
+	Statement    string    `json:"statement"`
+	RowsAffected int       `json:"rows_affected"`
+}
+
+// auditLog is append-only: entries are never modified or removed.
+type auditLog struct {
+	mu      sync.Mutex
+	enabled bool
+	entries []AuditEntry
+}
+
+func (db *Database) SetAuditEnabled(enabled bool) {
+	db.audit.mu.Lock()
+	defer db.audit.mu.Unlock()
+	db.audit.enabled = enabled
+}
+
+func (db *Database) AuditEnabled() bool {
+	db.audit.mu.Lock()
+	defer db.audit.mu.Unlock()
+	return db.audit.enabled
+}
+
+// RecordAudit appends entry to the audit log if auditing is enabled,
+// assigning its ID and, when unset, its time.
+func (db *Database) RecordAudit(entry AuditEntry) {
+	db.audit.mu.Lock()
+	defer db.audit.mu.Unlock()
+
+	if !db.audit.enabled {
+		return
+	}
+
+	entry.ID = len(db.audit.entries) + 1
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	db.audit.entries = append(db.audit.entries, entry)
+}
+
+func (db *Database) AuditEntries() []AuditEntry {
+	db.audit.mu.Lock()
+	defer db.audit.mu.Unlock()
+
+	entries := make([]AuditEntry, len(db.audit.entries))
+	copy(entries, db.audit.entries)
+	return entries
+}
+
+// ExportAudit writes the audit log to w as JSON lines, oldest first.
+func (db *Database) ExportAudit(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range db.AuditEntries() {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
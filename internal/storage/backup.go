@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupFormat is written in the header of each backup. Version 2 added
+// the foreign keys of tables and create_index changes; backups of version
+// 1, which hold only tables and rows, can still be read.
+const backupFormat = "rdbms-backup/2"
+
+var readableBackupFormats = map[string]bool{"rdbms-backup/1": true, backupFormat: true}
+
+// BackupInfo describes a backup written by Backup.
+type BackupInfo struct {
+	LSN     uint64    `json:"lsn"`
+	Time    time.Time `json:"time"`
+	Tables  int       `json:"tables"`
+	Indexes int       `json:"indexes"`
+	Rows    int       `json:"rows"`
+}
+
+type backupHeader struct {
+	Format string `json:"format"`
+	BackupInfo
+}
+
+// Backup writes every committed table, with its foreign keys, and every
+// row and secondary index to w as JSON lines: a header with the LSN the
+// backup corresponds to, then one WAL change per line (see Dump).
+// The database stays available; commits wait only while tables are copied.
+func (db *Database) Backup(w io.Writer) (BackupInfo, error) {
+	lsn, changes := db.Dump()
+
+	info := BackupInfo{LSN: lsn, Time: time.Now()}
+	for _, change := range changes {
+		switch change.Op {
+		case WALCreateTable:
+			info.Tables++
+		case WALCreateIndex:
+			info.Indexes++
+		case WALInsert:
+			info.Rows++
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	if err := encoder.Encode(backupHeader{Format: backupFormat, BackupInfo: info}); err != nil {
+		return info, err
+	}
+	for _, change := range changes {
+		if err := encoder.Encode(change); err != nil {
+			return info, err
+		}
+	}
+	return info, bw.Flush()
+}
+
+// BackupToFile writes a backup to path. The file is written under a
+// temporary name and renamed, so path never holds a partial backup.
+func (db *Database) BackupToFile(path string) (BackupInfo, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	info, err := db.Backup(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return info, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return info, fmt.Errorf("failed to write backup: %w", err)
+	}
+	return info, nil
+}
+
+// RestoreBackup replaces the database contents with a backup written by
+// Backup.
+func (db *Database) RestoreBackup(r io.Reader) (BackupInfo, error) {
+	header, changes, err := readBackup(r)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	if err := db.Restore(header.LSN, changes); err != nil {
+		return BackupInfo{}, err
+	}
+	return header.BackupInfo, nil
+}
+
+// LoadBackup adds the tables and rows of a backup written by Backup to the
+// database in one transaction, keeping what is there rather than replacing
+// it as RestoreBackup does. A table the database has must have the
+// backup's columns, and an index it has by name is kept; rows whose keys
+// its rows already hold are handled by policy. It returns the backup's info and how many rows were skipped or
+// replaced.
+func (db *Database) LoadBackup(r io.Reader, policy ConflictPolicy) (BackupInfo, int, error) {
+	header, changes, err := readBackup(r)
+	if err != nil {
+		return BackupInfo{}, 0, err
+	}
+
+	tx := db.Begin()
+	conflicts := 0
+	var table *Table
+	var rows []*Row
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		_, n, err := tx.InsertRowsOnConflict(table, rows, policy)
+		conflicts += n
+		rows = rows[:0]
+		return err
+	}
+	load := func(change WALChange) error {
+		switch change.Op {
+		case WALCreateTable:
+			if err := flush(); err != nil {
+				return err
+			}
+			if existing, err := db.GetTable(change.Table); err == nil {
+				if !existing.Schema().SameColumns(change.Schema) {
+					return errorf(ErrTableExists, "table %s already exists with other columns", change.Table)
+				}
+				table = existing
+				return nil
+			}
+			if err := tx.CreateTable(change.Table, change.Schema, change.ForeignKeys...); err != nil {
+				return err
+			}
+			table, err = db.GetTable(change.Table)
+			return err
+		case WALInsert:
+			if table == nil || table.Name != change.Table {
+				return fmt.Errorf("invalid backup: row of table %s outside its table", change.Table)
+			}
+			rows = append(rows, NewRow(cloneValues(change.After)))
+			return nil
+		case WALCreateIndex:
+			if err := flush(); err != nil {
+				return err
+			}
+			idx, matches, err := db.loggedIndex(change.Index)
+			if err != nil {
+				return err
+			}
+			if err := tx.CreateIndex(idx, matches); !errors.Is(err, ErrTableExists) {
+				return err
+			}
+			return nil // an index the database has is kept
+		}
+		return fmt.Errorf("invalid backup: unexpected %s change", change.Op)
+	}
+	for _, change := range changes {
+		if err := load(change); err != nil {
+			tx.Rollback()
+			return BackupInfo{}, 0, err
+		}
+	}
+	if err := flush(); err != nil {
+		tx.Rollback()
+		return BackupInfo{}, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return BackupInfo{}, 0, err
+	}
+	return header.BackupInfo, conflicts, nil
+}
+
+func readBackup(r io.Reader) (backupHeader, []WALChange, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	var header backupHeader
+	if err := decoder.Decode(&header); err != nil {
+		return header, nil, fmt.Errorf("invalid backup: %w", err)
+	}
+	if !readableBackupFormats[header.Format] {
+		return header, nil, fmt.Errorf("unsupported backup format %q", header.Format)
+	}
+
+	var changes []WALChange
+	for {
+		var change WALChange
+		err := decoder.Decode(&change)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return header, nil, fmt.Errorf("invalid backup: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	return header, changes, nil
+}
+
+func (db *Database) RestoreBackupFile(path string) (BackupInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer f.Close()
+
+	return db.RestoreBackup(f)
+}
+
+// LoadBackupFile loads the backup at path with LoadBackup.
+func (db *Database) LoadBackupFile(path string, policy ConflictPolicy) (BackupInfo, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BackupInfo{}, 0, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer f.Close()
+
+	return db.LoadBackup(f, policy)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBackupSchema(t *testing.T) {
+	db := NewDatabase()
+	users := NewSchema()
+	users.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	users.AddColumn(NewColumn("email", TypeText, false, true, false))
+	tasks := NewSchema()
+	tasks.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	tasks.AddColumn(NewColumn("user_id", TypeInteger, false, false, false))
+	tasks.AddColumn(NewColumn("body", TypeText, false, false, false))
+	tx := db.Begin()
+	if err := tx.CreateTable("users", users); err != nil {
+		t.Fatal(err)
+	}
+	fk := &ForeignKey{Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}}
+	if err := tx.CreateTable("tasks", tasks, fk); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CreateIndex(&SecondaryIndex{Name: "tasks_user", Table: "tasks", Column: "user_id", Include: []string{"body"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CreateIndex(&SecondaryIndex{Name: "tasks_body", Table: "tasks", Column: "body", FullText: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("users")
+	if _, err := tx.Insert(table, NewRow([]Value{NewIntegerValue(1), NewTextValue("ann@example.com")})); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	info, err := db.Backup(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Tables != 2 || info.Indexes != 2 || info.Rows != 1 {
+		t.Errorf("info = %+v, want 2 tables, 2 indexes and 1 row", info)
+	}
+	var header backupHeader
+	if err := json.NewDecoder(bytes.NewReader(buf.Bytes())).Decode(&header); err != nil || header.Format != backupFormat {
+		t.Errorf("header = %+v, %v", header, err)
+	}
+
+	restored := NewDatabase()
+	if _, err := restored.RestoreBackup(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restoredTasks, _ := restored.GetTable("tasks")
+	var names []string
+	for _, idx := range restoredTasks.SecondaryIndexes() {
+		names = append(names, idx.Name+"/"+idx.Kind()+"/"+strings.Join(idx.Include, ","))
+	}
+	if got, want := strings.Join(names, " "), "tasks_body/FULLTEXT/ tasks_user//body"; got != want {
+		t.Errorf("indexes = %s, want %s", got, want)
+	}
+	if n := restoredTasks.IndexLen("tasks_body"); n != 0 {
+		t.Errorf("IndexLen(tasks_body) = %d, want 0", n)
+	}
+	tx = restored.Begin()
+	defer tx.Rollback()
+	if _, err := tx.Insert(restoredTasks, NewRow([]Value{NewIntegerValue(1), NewIntegerValue(2), NewTextValue("x")})); !errors.Is(err, ErrForeignKeyViolation) {
+		t.Errorf("orphan insert: err = %v, want ErrForeignKeyViolation", err)
+	}
+}
+
+// TestBackupFormat1 checks that backups written before foreign keys and
+// indexes were included can still be restored.
+func TestBackupFormat1(t *testing.T) {
+	backup := `{"format":"rdbms-backup/1","lsn":2,"time":"2026-10-16T19:00:37Z","tables":1,"rows":1}
+{"op":"create_table","table":"orders","schema":[{"name":"id","type":0,"primary_key":true}]}
+{"op":"insert","table":"orders","columns":["id"],"after":[{"t":0,"v":"7"}]}
+`
+	db := NewDatabase()
+	info, err := db.RestoreBackup(strings.NewReader(backup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Tables != 1 || info.Rows != 1 || db.WAL().LastLSN() != 2 {
+		t.Errorf("info = %+v, LSN %d", info, db.WAL().LastLSN())
+	}
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Storage benchmarks; see internal/sql/bench_test.go for the same
+// workloads through the parser and executor, and `make bench` for
+// comparing a run against a saved baseline.
+
+var benchSizes = []int{100, 1000, 10000}
+
+func BenchmarkBTreeInsert(b *testing.B) {
+	bt := NewBTree()
+	for i := 0; i < b.N; i++ {
+		bt.Insert(NewIntegerValue(int64(i*7919%b.N)), i)
+	}
+}
+
+func BenchmarkBTreeLookup(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("rows=%d", n), func(b *testing.B) {
+			bt := NewBTree()
+			for i := 0; i < n; i++ {
+				bt.Insert(NewIntegerValue(int64(i)), i)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, ok := bt.Lookup(NewIntegerValue(int64(i % n))); !ok {
+					b.Fatal("key not found")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTableInsert(b *testing.B) {
+	for _, indexed := range []bool{false, true} {
+		b.Run(fmt.Sprintf("indexed=%v", indexed), func(b *testing.B) {
+			table := benchTable(indexed, 0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := table.Insert(benchRow(i)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTableLookup finds one row by id, through the B-tree index or
+// by scanning every row.
+func BenchmarkTableLookup(b *testing.B) {
+	for _, n := range benchSizes {
+		table := benchTable(true, n)
+		b.Run(fmt.Sprintf("index/rows=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ptrs, ok := table.Indexes["id"].Lookup(NewIntegerValue(int64(i % n)))
+				if !ok {
+					b.Fatal("key not found")
+				}
+				if _, err := table.GetRow(ptrs[0]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("scan/rows=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				id := int64(i % n)
+				rows := table.Select(func(row *Row) bool {
+					v, _ := row.Get(0)
+					return v.(*IntegerValue).Value == id
+				})
+				if len(rows) != 1 {
+					b.Fatalf("found %d rows", len(rows))
+				}
+			}
+		})
+	}
+}
+
+// benchTable returns a (id, name, score) table holding rows 0..n-1, with
+// id as an indexed primary key or a plain column.
+func benchTable(indexed bool, n int) *Table {
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, indexed, false, false))
+	schema.AddColumn(NewColumn("name", TypeText, false, false, false))
+	schema.AddColumn(NewColumn("score", TypeFloat, false, false, false))
+	table := NewTable("bench", schema)
+	if indexed {
+		table.AddIndex("id")
+	}
+	for i := 0; i < n; i++ {
+		if _, err := table.Insert(benchRow(i)); err != nil {
+			panic(err)
+		}
+	}
+	return table
+}
+
+func benchRow(i int) *Row {
+	return NewRow([]Value{
+		NewIntegerValue(int64(i)),
+		NewTextValue(fmt.Sprintf("name-%d", i)),
+		NewFloatValue(float64(i%97) / 3),
+	})
+}
@@ -46,6 +46,13 @@ func (bt *BTree) Insert(key Value, rowPtr int) error {
 		return nil
 	}
 
+	// Keys are unique: inserting one that is already present moves it to
+	// the new row.
+	if node, i := bt.find(bt.root, key); node != nil {
+		node.rowPtrs[i] = rowPtr
+		return nil
+	}
+
 	if len(bt.root.keys) >= 2*bt.order-1 {
 		newRoot := &bTreeNode{
 			keys:     make([]Value, 0),
@@ -74,22 +81,22 @@ func (bt *BTree) splitChild(parent *bTreeNode, i int) {
 	}
 
 	midKey := t.keys[order-1]
+	midPtr := t.rowPtrs[order-1]
 
 	newNode.keys = append(newNode.keys, t.keys[order:]...)
-	if t.isLeaf {
-		newNode.rowPtrs = append(newNode.rowPtrs, t.rowPtrs[order:]...)
-	} else {
+	newNode.rowPtrs = append(newNode.rowPtrs, t.rowPtrs[order:]...)
+	if !t.isLeaf {
 		newNode.children = append(newNode.children, t.children[order:]...)
 	}
 
 	t.keys = t.keys[:order-1]
-	if t.isLeaf {
-		t.rowPtrs = t.rowPtrs[:order-1]
-	} else {
+	t.rowPtrs = t.rowPtrs[:order-1]
+	if !t.isLeaf {
 		t.children = t.children[:order]
 	}
 
 	parent.keys = append(parent.keys[:i], append([]Value{midKey}, parent.keys[i:]...)...)
+	parent.rowPtrs = append(parent.rowPtrs[:i], append([]int{midPtr}, parent.rowPtrs[i:]...)...)
 	parent.children = append(parent.children[:i+1], append([]*bTreeNode{newNode}, parent.children[i+1:]...)...)
 }
 
@@ -117,10 +124,7 @@ func (bt *BTree) insertNonFull(node *bTreeNode, key Value, rowPtr int) {
 
 		if len(node.children[i].keys) >= 2*bt.order-1 {
 			bt.splitChild(node, i)
-			if key.Equals(node.keys[i]) {
-				return
-			}
-			if key.LessThan(node.keys[i]) {
+			if node.keys[i].LessThan(key) {
 				i++
 			}
 		}
@@ -133,28 +137,26 @@ func (bt *BTree) Lookup(key Value) ([]int, bool) {
 	bt.mu.RLock()
 	defer bt.mu.RUnlock()
 
-	return bt.lookup(bt.root, key)
-}
-
-func (bt *BTree) lookup(node *bTreeNode, key Value) ([]int, bool) {
+	node, i := bt.find(bt.root, key)
 	if node == nil {
 		return nil, false
 	}
+	return []int{node.rowPtrs[i]}, true
+}
 
-	i := 0
-	for i < len(node.keys) && key.LessThan(node.keys[i]) {
-		i++
-	}
-
-	if i < len(node.keys) && key.Equals(node.keys[i]) {
-		return []int{node.rowPtrs[i]}, true
-	}
-
-	if node.isLeaf {
-		return nil, false
+// find returns the node holding key and its position there, or nil.
+func (bt *BTree) find(node *bTreeNode, key Value) (*bTreeNode, int) {
+	for node != nil {
+		i := bt.findKey(node, key)
+		if i < len(node.keys) && key.Equals(node.keys[i]) {
+			return node, i
+		}
+		if node.isLeaf {
+			return nil, 0
+		}
+		node = node.children[i]
 	}
-
-	return bt.lookup(node.children[i], key)
+	return nil, 0
 }
 
 func (bt *BTree) Range(start, end Value) []int {
@@ -192,7 +194,7 @@ func (bt *BTree) Delete(key Value) error {
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 
-	if !bt.containsKey(bt.root, key) {
+	if node, _ := bt.find(bt.root, key); node == nil {
 		return fmt.Errorf("key not found")
 	}
 
@@ -205,27 +207,6 @@ func (bt *BTree) Delete(key Value) error {
 	return nil
 }
 
-func (bt *BTree) containsKey(node *bTreeNode, key Value) bool {
-	if node == nil {
-		return false
-	}
-
-	i := 0
-	for i < len(node.keys) && key.LessThan(node.keys[i]) {
-		i++
-	}
-
-	if i < len(node.keys) && key.Equals(node.keys[i]) {
-		return true
-	}
-
-	if node.isLeaf {
-		return false
-	}
-
-	return bt.containsKey(node.children[i], key)
-}
-
 func (bt *BTree) deleteKey(node *bTreeNode, key Value) bool {
 	idx := bt.findKey(node, key)
 
@@ -255,9 +236,11 @@ func (bt *BTree) deleteKey(node *bTreeNode, key Value) bool {
 	return bt.deleteKey(node.children[idx], key)
 }
 
+// findKey returns the index of the first key in node that is not less
+// than key.
 func (bt *BTree) findKey(node *bTreeNode, key Value) int {
 	idx := 0
-	for idx < len(node.keys) && !key.LessThan(node.keys[idx]) {
+	for idx < len(node.keys) && node.keys[idx].LessThan(key) {
 		idx++
 	}
 	return idx
@@ -272,12 +255,12 @@ func (bt *BTree) removeFromInternal(node *bTreeNode, idx int) {
 	key := node.keys[idx]
 
 	if len(node.children[idx].keys) >= bt.order {
-		pred := bt.getPredecessor(node, idx)
-		node.keys[idx] = pred
+		pred, ptr := bt.getPredecessor(node, idx)
+		node.keys[idx], node.rowPtrs[idx] = pred, ptr
 		bt.deleteKey(node.children[idx], pred)
 	} else if len(node.children[idx+1].keys) >= bt.order {
-		succ := bt.getSuccessor(node, idx)
-		node.keys[idx] = succ
+		succ, ptr := bt.getSuccessor(node, idx)
+		node.keys[idx], node.rowPtrs[idx] = succ, ptr
 		bt.deleteKey(node.children[idx+1], succ)
 	} else {
 		bt.merge(node, idx)
@@ -285,20 +268,21 @@ func (bt *BTree) removeFromInternal(node *bTreeNode, idx int) {
 	}
 }
 
-func (bt *BTree) getPredecessor(node *bTreeNode, idx int) Value {
+func (bt *BTree) getPredecessor(node *bTreeNode, idx int) (Value, int) {
 	current := node.children[idx]
 	for !current.isLeaf {
 		current = current.children[len(current.keys)]
 	}
-	return current.keys[len(current.keys)-1]
+	last := len(current.keys) - 1
+	return current.keys[last], current.rowPtrs[last]
 }
 
-func (bt *BTree) getSuccessor(node *bTreeNode, idx int) Value {
+func (bt *BTree) getSuccessor(node *bTreeNode, idx int) (Value, int) {
 	current := node.children[idx+1]
 	for !current.isLeaf {
 		current = current.children[0]
 	}
-	return current.keys[0]
+	return current.keys[0], current.rowPtrs[0]
 }
 
 func (bt *BTree) fill(node *bTreeNode, idx int) {
@@ -320,7 +304,7 @@ func (bt *BTree) borrowFromPrev(node *bTreeNode, idx int) {
 	sibling := node.children[idx-1]
 
 	child.keys = append([]Value{node.keys[idx-1]}, child.keys...)
-	child.rowPtrs = append([]int{child.rowPtrs[0]}, child.rowPtrs...)
+	child.rowPtrs = append([]int{node.rowPtrs[idx-1]}, child.rowPtrs...)
 
 	if !child.isLeaf {
 		child.children = append([]*bTreeNode{sibling.children[len(sibling.children)-1]}, child.children...)
@@ -328,6 +312,7 @@ func (bt *BTree) borrowFromPrev(node *bTreeNode, idx int) {
 	}
 
 	node.keys[idx-1] = sibling.keys[len(sibling.keys)-1]
+	node.rowPtrs[idx-1] = sibling.rowPtrs[len(sibling.rowPtrs)-1]
 
 	sibling.keys = sibling.keys[:len(sibling.keys)-1]
 	sibling.rowPtrs = sibling.rowPtrs[:len(sibling.rowPtrs)-1]
@@ -338,7 +323,7 @@ func (bt *BTree) borrowFromNext(node *bTreeNode, idx int) {
 	sibling := node.children[idx+1]
 
 	child.keys = append(child.keys, node.keys[idx])
-	child.rowPtrs = append(child.rowPtrs, sibling.rowPtrs[0])
+	child.rowPtrs = append(child.rowPtrs, node.rowPtrs[idx])
 
 	if !child.isLeaf {
 		child.children = append(child.children, sibling.children[0])
@@ -346,6 +331,7 @@ func (bt *BTree) borrowFromNext(node *bTreeNode, idx int) {
 	}
 
 	node.keys[idx] = sibling.keys[0]
+	node.rowPtrs[idx] = sibling.rowPtrs[0]
 
 	sibling.keys = sibling.keys[1:]
 	sibling.rowPtrs = sibling.rowPtrs[1:]
@@ -357,6 +343,7 @@ func (bt *BTree) merge(node *bTreeNode, idx int) {
 
 	child.keys = append(child.keys, node.keys[idx])
 	child.keys = append(child.keys, sibling.keys...)
+	child.rowPtrs = append(child.rowPtrs, node.rowPtrs[idx])
 	child.rowPtrs = append(child.rowPtrs, sibling.rowPtrs...)
 
 	if !child.isLeaf {
@@ -364,9 +351,22 @@ func (bt *BTree) merge(node *bTreeNode, idx int) {
 	}
 
 	node.keys = append(node.keys[:idx], node.keys[idx+1:]...)
+	node.rowPtrs = append(node.rowPtrs[:idx], node.rowPtrs[idx+1:]...)
 	node.children = append(node.children[:idx+1], node.children[idx+2:]...)
 }
 
+// Depth returns the number of levels in the tree, 1 for a lone leaf.
+func (bt *BTree) Depth() int {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	depth := 1
+	for node := bt.root; !node.isLeaf && len(node.children) > 0; node = node.children[0] {
+		depth++
+	}
+	return depth
+}
+
 func (bt *BTree) ScanAll() []int {
 	bt.mu.RLock()
 	defer bt.mu.RUnlock()
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// The B-tree is checked against a sorted slice of keys: after every
+// operation the two must agree on lookups, ranges and the full scan, and
+// the tree must satisfy checkBTree.
+
+type modelEntry struct {
+	key int64
+	ptr int
+}
+
+type btreeModel []modelEntry
+
+func (m btreeModel) search(key int64) int {
+	return sort.Search(len(m), func(i int) bool { return m[i].key >= key })
+}
+
+func (m *btreeModel) insert(key int64, ptr int) {
+	i := m.search(key)
+	if i < len(*m) && (*m)[i].key == key {
+		(*m)[i].ptr = ptr
+		return
+	}
+	*m = append(*m, modelEntry{})
+	copy((*m)[i+1:], (*m)[i:])
+	(*m)[i] = modelEntry{key, ptr}
+}
+
+func (m *btreeModel) delete(key int64) bool {
+	i := m.search(key)
+	if i == len(*m) || (*m)[i].key != key {
+		return false
+	}
+	*m = append((*m)[:i], (*m)[i+1:]...)
+	return true
+}
+
+func (m btreeModel) rangePtrs(start, end int64) []int {
+	ptrs := make([]int, 0)
+	for _, e := range m[m.search(start):] {
+		if e.key > end {
+			break
+		}
+		ptrs = append(ptrs, e.ptr)
+	}
+	return ptrs
+}
+
+func TestBTreeMatchesModel(t *testing.T) {
+	for _, order := range []int{2, 3, 4, 8} {
+		for seed := int64(1); seed <= 20; seed++ {
+			t.Run(fmt.Sprintf("order=%d/seed=%d", order, seed), func(t *testing.T) {
+				runBTreeModel(t, order, seed, 2000)
+			})
+		}
+	}
+}
+
+func runBTreeModel(t *testing.T, order int, seed int64, steps int) {
+	rng := rand.New(rand.NewSource(seed))
+	bt := NewBTree()
+	bt.order = order
+	var model btreeModel
+	keySpace := int64(50 + rng.Intn(500))
+
+	for step := 0; step < steps; step++ {
+		key := rng.Int63n(keySpace)
+		var op string
+		switch r := rng.Intn(10); {
+		case r < 5:
+			op = fmt.Sprintf("insert %d", key)
+			ptr := rng.Intn(1 << 20)
+			if err := bt.Insert(NewIntegerValue(key), ptr); err != nil {
+				t.Fatalf("step %d: %s: %v", step, op, err)
+			}
+			model.insert(key, ptr)
+		case r < 9:
+			op = fmt.Sprintf("delete %d", key)
+			err := bt.Delete(NewIntegerValue(key))
+			if found := model.delete(key); found != (err == nil) {
+				t.Fatalf("step %d: %s: present=%v, got error %v", step, op, found, err)
+			}
+		default:
+			end := key + rng.Int63n(keySpace/4+1)
+			op = fmt.Sprintf("range %d..%d", key, end)
+			got := bt.Range(NewIntegerValue(key), NewIntegerValue(end))
+			if want := model.rangePtrs(key, end); !equalInts(got, want) {
+				t.Fatalf("step %d: %s: got %v, want %v", step, op, got, want)
+			}
+		}
+
+		if err := checkBTree(bt); err != nil {
+			t.Fatalf("step %d: after %s: %v\n%s", step, op, err, bt.Dump())
+		}
+		if got, want := bt.ScanAll(), model.rangePtrs(0, keySpace); !equalInts(got, want) {
+			t.Fatalf("step %d: after %s: scan got %v, want %v", step, op, got, want)
+		}
+		if bt.Count() != len(model) {
+			t.Fatalf("step %d: after %s: count %d, want %d", step, op, bt.Count(), len(model))
+		}
+		probe := rng.Int63n(keySpace)
+		ptrs, ok := bt.Lookup(NewIntegerValue(probe))
+		i := model.search(probe)
+		if present := i < len(model) && model[i].key == probe; ok != present || ok && ptrs[0] != model[i].ptr {
+			t.Fatalf("step %d: after %s: lookup %d got %v %v", step, op, probe, ptrs, ok)
+		}
+	}
+
+	// Drain the tree so the final merges down to an empty root are covered.
+	for len(model) > 0 {
+		key := model[rng.Intn(len(model))].key
+		if err := bt.Delete(NewIntegerValue(key)); err != nil {
+			t.Fatalf("drain: delete %d: %v", key, err)
+		}
+		model.delete(key)
+		if err := checkBTree(bt); err != nil {
+			t.Fatalf("drain: after delete %d: %v\n%s", key, err, bt.Dump())
+		}
+	}
+	if bt.Count() != 0 || !bt.root.isLeaf {
+		t.Fatalf("drained tree is not an empty leaf:\n%s", bt.Dump())
+	}
+}
+
+func TestBTreeSequentialInsertDelete(t *testing.T) {
+	bt := NewBTree()
+	const n = 1000
+	for i := 0; i < n; i++ {
+		bt.Insert(NewIntegerValue(int64(i)), i)
+	}
+	if err := checkBTree(bt); err != nil {
+		t.Fatal(err)
+	}
+	for i := n - 1; i >= 0; i -= 2 {
+		if err := bt.Delete(NewIntegerValue(int64(i))); err != nil {
+			t.Fatalf("delete %d: %v", i, err)
+		}
+	}
+	if err := checkBTree(bt); err != nil {
+		t.Fatal(err)
+	}
+	if got := bt.Count(); got != n/2 {
+		t.Fatalf("count %d, want %d", got, n/2)
+	}
+	if got := bt.Range(NewIntegerValue(10), NewIntegerValue(20)); !equalInts(got, []int{10, 12, 14, 16, 18, 20}) {
+		t.Fatalf("range got %v", got)
+	}
+	if err := bt.Delete(NewIntegerValue(1)); err == nil {
+		t.Fatal("deleting a missing key succeeded")
+	}
+}
+
+// checkBTree verifies the structural invariants: keys are in strictly
+// increasing order within each node and between a node and its children,
+// every node but the root holds between order-1 and 2*order-1 keys, an
+// internal node has one more child than keys, each key has a row pointer,
+// and all leaves are at the same depth.
+func checkBTree(bt *BTree) error {
+	if bt.root == nil {
+		return fmt.Errorf("nil root")
+	}
+	leafDepth := -1
+	var check func(n *bTreeNode, depth int, lo, hi Value) error
+	check = func(n *bTreeNode, depth int, lo, hi Value) error {
+		if len(n.rowPtrs) != len(n.keys) {
+			return fmt.Errorf("node %v has %d row pointers for %d keys", keyStrings(n), len(n.rowPtrs), len(n.keys))
+		}
+		if n != bt.root && len(n.keys) < bt.order-1 {
+			return fmt.Errorf("node %v is underfull (order %d)", keyStrings(n), bt.order)
+		}
+		if len(n.keys) > 2*bt.order-1 {
+			return fmt.Errorf("node %v is overfull (order %d)", keyStrings(n), bt.order)
+		}
+		for i, k := range n.keys {
+			if i > 0 && !n.keys[i-1].LessThan(k) {
+				return fmt.Errorf("node %v keys out of order", keyStrings(n))
+			}
+			if lo != nil && !lo.LessThan(k) || hi != nil && !k.LessThan(hi) {
+				return fmt.Errorf("node %v has key %s outside its parent's bounds", keyStrings(n), k.ToString())
+			}
+		}
+
+		if n.isLeaf {
+			if len(n.children) != 0 {
+				return fmt.Errorf("leaf %v has children", keyStrings(n))
+			}
+			if leafDepth == -1 {
+				leafDepth = depth
+			} else if depth != leafDepth {
+				return fmt.Errorf("leaf %v at depth %d, others at %d", keyStrings(n), depth, leafDepth)
+			}
+			return nil
+		}
+
+		if len(n.children) != len(n.keys)+1 {
+			return fmt.Errorf("node %v has %d children for %d keys", keyStrings(n), len(n.children), len(n.keys))
+		}
+		for i, child := range n.children {
+			childLo, childHi := lo, hi
+			if i > 0 {
+				childLo = n.keys[i-1]
+			}
+			if i < len(n.keys) {
+				childHi = n.keys[i]
+			}
+			if err := check(child, depth+1, childLo, childHi); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(bt.root, 0, nil, nil)
+}
+
+func keyStrings(n *bTreeNode) []string {
+	keys := make([]string, len(n.keys))
+	for i, k := range n.keys {
+		keys[i] = k.ToString()
+	}
+	return keys
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// ChangeEvent is one committed change delivered to subscribers. Before and
+// After map column names to values; Before is nil for inserts and After is
+// nil for deletes. Table-level events (create_table, drop_table,
+// create_index, drop_index) carry neither.
+type ChangeEvent struct {
+	LSN    uint64
+	Time   time.Time
+	Table  string
+	Op     WALOp
+	Before map[string]Value
+	After  map[string]Value
+}
+
+// Events converts the entry's changes to change events.
+func (e WALEntry) Events() []ChangeEvent {
+	events := make([]ChangeEvent, len(e.Changes))
+	for i, change := range e.Changes {
+		events[i] = ChangeEvent{
+			LSN:    e.LSN,
+			Time:   e.Time,
+			Table:  change.Table,
+			Op:     change.Op,
+			Before: rowMap(change.Columns, change.Before),
+			After:  rowMap(change.Columns, change.After),
+		}
+	}
+	return events
+}
+
+func rowMap(columns []string, values []Value) map[string]Value {
+	if values == nil {
+		return nil
+	}
+	row := make(map[string]Value, len(values))
+	for i, v := range values {
+		if i < len(columns) {
+			row[columns[i]] = v
+		}
+	}
+	return row
+}
+
+// Subscribe calls fn for every change to table committed after the call,
+// in commit order. An empty table subscribes to all tables. fn runs on a
+// goroutine owned by the subscription, so a slow callback delays only its
+// own events. The returned function cancels the subscription.
+func (db *Database) Subscribe(table string, fn func(ChangeEvent)) (unsubscribe func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go db.FollowChanges(ctx, table, db.wal.LastLSN(), fn)
+	return cancel
+}
+
+// FollowChanges calls fn for each change to table (all tables if empty)
+// committed after LSN from, waiting for new commits until ctx is done.
+func (db *Database) FollowChanges(ctx context.Context, table string, from uint64, fn func(ChangeEvent)) error {
+	for {
+		if err := db.wal.Wait(ctx, from); err != nil {
+			return err
+		}
+
+		entries := db.wal.Since(from)
+		if len(entries) == 0 {
+			// The WAL was reset by a snapshot restore; earlier changes are gone.
+			from = db.wal.LastLSN()
+			continue
+		}
+
+		for _, entry := range entries {
+			for _, event := range entry.Events() {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if table == "" || event.Table == table {
+					fn(event)
+				}
+			}
+			from = entry.LSN
+		}
+	}
+}
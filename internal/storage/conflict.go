@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConflictPolicy is what loading data does with a row whose primary key
+// or UNIQUE value a row of the table already has.
+type ConflictPolicy int
+
+const (
+	ConflictAbort   ConflictPolicy = iota // fail, as INSERT does
+	ConflictSkip                          // keep the row already there
+	ConflictReplace                       // overwrite it with the new row
+)
+
+var conflictPolicies = []string{"abort", "skip", "replace"}
+
+func (p ConflictPolicy) String() string {
+	return conflictPolicies[p]
+}
+
+// ParseConflictPolicy reads abort, skip or replace.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	for i, name := range conflictPolicies {
+		if strings.EqualFold(s, name) {
+			return ConflictPolicy(i), nil
+		}
+	}
+	return ConflictAbort, fmt.Errorf("invalid conflict policy %q (expected skip, replace or abort)", s)
+}
+
+// InsertRowsOnConflict inserts rows as InsertRows does, except that a row
+// sharing a primary key or UNIQUE value with a row of the table, including
+// one inserted before it, is skipped or replaces that row, as policy says.
+// A replacing row is written over the row it conflicts with, keeping its
+// primary key, so rows referring to it still do; one conflicting with
+// several rows replaces them all. It returns how many rows were inserted
+// and how many were skipped or replaced.
+func (tx *Tx) InsertRowsOnConflict(table *Table, rows []*Row, policy ConflictPolicy) (inserted, conflicts int, err error) {
+	if policy == ConflictAbort {
+		ids, err := tx.InsertRows(table, rows)
+		return len(ids), 0, err
+	}
+	for _, row := range rows {
+		existing := table.conflicting(row)
+		switch {
+		case len(existing) == 0:
+			if _, err := tx.InsertRows(table, []*Row{row}); err != nil {
+				return inserted, conflicts, err
+			}
+			inserted++
+			continue
+		case policy == ConflictSkip:
+		case len(existing) == 1 && table.keepsPrimaryKey(existing, row):
+			if err := tx.overwrite(table, existing, row); err != nil {
+				return inserted, conflicts, err
+			}
+		default:
+			if _, err := tx.Delete(table, func(r *Row) bool { return existing[r] }); err != nil {
+				return inserted, conflicts, err
+			}
+			if _, err := tx.InsertRows(table, []*Row{row}); err != nil {
+				return inserted, conflicts, err
+			}
+		}
+		conflicts++
+	}
+	return inserted, conflicts, nil
+}
+
+// conflicting returns the rows of t holding a primary key or UNIQUE value
+// of row.
+func (t *Table) conflicting(row *Row) map[*Row]bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	found := make(map[*Row]bool)
+	for i, col := range t.Schema().Columns {
+		if !col.PrimaryKey && !col.Unique || i >= len(row.Values) {
+			continue
+		}
+		val := row.Values[i]
+		if index, ok := t.Indexes[col.Name]; ok && val.Type() != TypeNull {
+			positions, _ := index.Lookup(val)
+			for _, pos := range positions {
+				found[t.Rows[pos]] = true
+			}
+			continue
+		}
+		if col.PrimaryKey && val.Type() == TypeNull {
+			continue // given the next id
+		}
+		for _, existing := range t.Rows {
+			if v, _ := existing.Get(i); col.duplicates(val, v) {
+				found[existing] = true
+			}
+		}
+	}
+	return found
+}
+
+// keepsPrimaryKey reports whether row can be written over the row existing
+// holds: its primary key, if any, is the same or NULL.
+func (t *Table) keepsPrimaryKey(existing map[*Row]bool, row *Row) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for old := range existing {
+		for i, col := range t.Schema().Columns {
+			if !col.PrimaryKey || i >= len(row.Values) || row.Values[i].Type() == TypeNull {
+				continue
+			}
+			if v, _ := old.Get(i); !v.Equals(row.Values[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// overwrite updates the only row of existing to the values of row but
+// for its primary key, checking them as insert does.
+func (tx *Tx) overwrite(table *Table, existing map[*Row]bool, row *Row) error {
+	_, err := tx.Update(table, func(r *Row) bool { return existing[r] }, func(r *Row) error {
+		for i, col := range table.Schema().Columns {
+			if i >= len(row.Values) || col.PrimaryKey {
+				continue
+			}
+			val := row.Values[i]
+			if val.Type() != col.Type && val.Type() != TypeNull {
+				return errorf(ErrTypeMismatch, "type mismatch for column %s: expected %s, got %s", col.Name, col.Type, val.Type())
+			}
+			if col.NotNull && val.Type() == TypeNull {
+				return errorf(ErrNotNullViolation, "column %s cannot be null", col.Name)
+			}
+			r.Values[i] = val
+		}
+		return nil
+	})
+	return err
+}
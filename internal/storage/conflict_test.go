@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestLoadBackup(t *testing.T) {
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	schema.AddColumn(NewColumn("name", TypeText, false, false, false))
+	insert := func(db *Database, rows ...[]Value) {
+		table, _ := db.GetTable("users")
+		tx := db.Begin()
+		for _, values := range rows {
+			if _, err := tx.Insert(table, NewRow(values)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows := func(db *Database) []string {
+		table, _ := db.GetTable("users")
+		var got []string
+		for _, row := range table.Select(nil) {
+			got = append(got, row.String())
+		}
+		return got
+	}
+
+	source := NewDatabase()
+	source.CreateTable("users", schema)
+	insert(source, []Value{NewIntegerValue(1), NewTextValue("Ann")}, []Value{NewIntegerValue(2), NewTextValue("Bob")})
+	var backup bytes.Buffer
+	if _, err := source.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		policy    ConflictPolicy
+		want      []string
+		conflicts int
+		fails     bool
+	}{
+		{policy: ConflictSkip, want: []string{"(1, Annie)", "(3, Cy)", "(2, Bob)"}, conflicts: 1},
+		{policy: ConflictReplace, want: []string{"(1, Ann)", "(3, Cy)", "(2, Bob)"}, conflicts: 1},
+		{policy: ConflictAbort, want: []string{"(1, Annie)", "(3, Cy)"}, fails: true},
+	} {
+		db := NewDatabase()
+		db.CreateTable("users", schema)
+		insert(db, []Value{NewIntegerValue(1), NewTextValue("Annie")}, []Value{NewIntegerValue(3), NewTextValue("Cy")})
+
+		_, conflicts, err := db.LoadBackup(bytes.NewReader(backup.Bytes()), tc.policy)
+		if (err != nil) != tc.fails || conflicts != tc.conflicts {
+			t.Errorf("%s: conflicts = %d, err = %v", tc.policy, conflicts, err)
+		}
+		if got := rows(db); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: rows = %q, want %q", tc.policy, got, tc.want)
+		}
+	}
+}
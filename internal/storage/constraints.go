@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Foreign keys are checked by the transaction that writes the rows, after
+// each write (see Tx), or at Commit when the transaction has deferred its
+// constraints. A NULL in a referencing column never violates the key.
+// Every foreign key is NO ACTION: a referenced row cannot be deleted, or
+// its key changed, while another row still refers to it.
+
+// constraintKey returns the values of row's columns at positions as a map
+// key, or false when one of them is NULL.
+func constraintKey(row *Row, positions []int) (string, bool) {
+	var b strings.Builder
+	for _, i := range positions {
+		v, err := row.Get(i)
+		if err != nil || v.Type() == TypeNull {
+			return "", false
+		}
+		fmt.Fprintf(&b, "%d:%s\x00", v.Type(), v.ToString())
+	}
+	return b.String(), true
+}
+
+// keyText formats the values of row's columns at positions for an error.
+func keyText(row *Row, positions []int) string {
+	values := make([]string, len(positions))
+	for i, pos := range positions {
+		if v, err := row.Get(pos); err == nil {
+			values[i] = v.ToString()
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+func (s *Schema) columnPositions(names []string) []int {
+	positions := make([]int, len(names))
+	for i, name := range names {
+		positions[i] = s.ColumnIndex(name)
+	}
+	return positions
+}
+
+// keySet returns the keys the table's rows hold in columns.
+func (t *Table) keySet(columns []string) map[string]bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	positions := t.Schema().columnPositions(columns)
+	keys := make(map[string]bool, len(t.Rows))
+	for _, row := range t.Rows {
+		if key, ok := constraintKey(row, positions); ok {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// checkReferences checks that rows, written to table, refer to existing
+// rows through each of the table's foreign keys; nil rows checks all of the
+// table's rows. The caller must not hold the table's lock.
+func (db *Database) checkReferences(table *Table, rows []*Row) error {
+	for _, fk := range table.GetForeignKeys() {
+		parent, err := db.GetTable(fk.RefTable)
+		if err != nil {
+			return errorf(ErrForeignKeyViolation, "foreign key constraint violation: referenced table %s not found", fk.RefTable)
+		}
+		exists := parent.keyChecker(fk.RefColumns)
+
+		check := rows
+		if check == nil {
+			table.mu.RLock()
+			check = append([]*Row(nil), table.Rows...)
+			table.mu.RUnlock()
+		}
+		positions := table.Schema().columnPositions(fk.Columns)
+		missing := ""
+		for _, row := range check {
+			if _, ok := constraintKey(row, positions); ok && !exists(row, positions) {
+				missing = keyText(row, positions)
+				break
+			}
+		}
+
+		if missing != "" {
+			return errorf(ErrForeignKeyViolation, "foreign key constraint violation: key (%s)=(%s) is not present in table %s",
+				strings.Join(fk.Columns, ", "), missing, fk.RefTable)
+		}
+	}
+	return nil
+}
+
+// checkReferenced checks that no foreign key refers to the key of one of
+// rows, as they were before being deleted from table or updated, unless
+// the table still has a row with that key.
+func (db *Database) checkReferenced(table *Table, rows []*Row) error {
+	for _, ref := range db.referencing(table.Name) {
+		remains := table.keyChecker(ref.fk.RefColumns)
+		referenced := ref.table.keyChecker(ref.fk.Columns)
+		positions := table.Schema().columnPositions(ref.fk.RefColumns)
+		for _, row := range rows {
+			if _, ok := constraintKey(row, positions); ok && !remains(row, positions) && referenced(row, positions) {
+				return errorf(ErrForeignKeyViolation, "foreign key constraint violation: key (%s)=(%s) is still referenced from table %s",
+					strings.Join(ref.fk.RefColumns, ", "), keyText(row, positions), ref.table.Name)
+			}
+		}
+	}
+	return nil
+}
+
+type reference struct {
+	table *Table
+	fk    *ForeignKey
+}
+
+// referencing returns the foreign keys that refer to the table name, by
+// the name of their table.
+func (db *Database) referencing(name string) []reference {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var refs []reference
+	for _, t := range db.tables {
+		for _, fk := range t.GetForeignKeys() {
+			if fk.RefTable == name {
+				refs = append(refs, reference{table: t, fk: fk})
+			}
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].table.Name < refs[j].table.Name })
+	return refs
+}
+
+// checkUnique checks that no two of the table's rows share a value of a
+// UNIQUE column.
+func (t *Table) checkUnique() error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for i, col := range t.Schema().Columns {
+		if !col.Unique {
+			continue
+		}
+		seen := make(map[string]bool, len(t.Rows))
+		for _, row := range t.Rows {
+			v, err := row.Get(i)
+			if err != nil || v.Type() == TypeNull && !col.NullsNotDistinct {
+				continue
+			}
+			key := fmt.Sprintf("%d:%s", v.Type(), v.ToString())
+			if seen[key] {
+				return errorf(ErrUniqueViolation, "unique constraint violation: duplicate value %s", v.ToString())
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
@@ -2,29 +2,125 @@ package storage
 
 import (
 	"fmt"
+	"log/slog"
+	"sort"
 	"sync"
+	"time"
 )
 
 type Database struct {
-	tables map[string]*Table
-	mu     sync.RWMutex
+	tables        map[string]*Table
+	tableSeq      int // numbers tables in creation order; see ListTables
+	users         map[string]*User
+	mu            sync.RWMutex
+	listeners     map[*Listener]bool
+	notifyMu      sync.Mutex
+	logger        *slog.Logger
+	logStatements bool
+	traceQueries  bool
+	slowLog       *slowQueryLog
+	audit         *auditLog
+	wal           *WAL
+	readOnly      bool
+	fileReads     bool
+	backupDir     string // see SetBackupDir
+	backupLimited bool
+	externals     map[string]*ExternalTable
+	attached      map[string]*Database
+	commitHook    func([]WALChange) error
+	hooks         Hooks // see hooks.go
+	statsMu       sync.Mutex
+	stats         map[string]*TableStats // by table; see stats.go
+	scans         fullScans              // see indexusage.go
+	temporary     bool
 }
 
 func NewDatabase() *Database {
 	return &Database{
-		tables: make(map[string]*Table),
+		tables:    make(map[string]*Table),
+		users:     make(map[string]*User),
+		listeners: make(map[*Listener]bool),
+		slowLog:   newSlowQueryLog(),
+		audit:     &auditLog{},
+		wal:       newWAL(),
+		fileReads: true,
+		externals: make(map[string]*ExternalTable),
+		attached:  make(map[string]*Database),
 	}
 }
 
+// NewTemporaryDatabase creates a database for one session's temporary
+// tables. Its tables are Temporary, so writing them adds nothing to the
+// WAL of the transaction that does it.
+func NewTemporaryDatabase() *Database {
+	db := NewDatabase()
+	db.temporary = true
+	return db
+}
+
+// SetReadOnly makes the database reject writes from SQL statements. Replicas
+// run read-only; changes still arrive through ApplyWALEntry.
+func (db *Database) SetReadOnly(readOnly bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.readOnly = readOnly
+}
+
+func (db *Database) ReadOnly() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.readOnly
+}
+
+// SetFileReads controls whether SQL may read files on the server, as
+// csv_read does. It is allowed by default; a server open to remote clients
+// should turn it off.
+func (db *Database) SetFileReads(allowed bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.fileReads = allowed
+}
+
+func (db *Database) FileReads() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.fileReads
+}
+
+// SetBackupDir limits BACKUP TO to files inside dir, or refuses it when
+// dir is "". Any path is allowed until it is called; a server open to
+// remote clients should call it.
+func (db *Database) SetBackupDir(dir string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.backupDir = dir
+	db.backupLimited = true
+}
+
+// BackupDir returns the directory set with SetBackupDir, and whether it
+// was called.
+func (db *Database) BackupDir() (dir string, limited bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.backupDir, db.backupLimited
+}
+
 func (db *Database) CreateTable(name string, schema *Schema) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
 	if _, exists := db.tables[name]; exists {
-		return fmt.Errorf("table %s already exists", name)
+		return errorf(ErrTableExists, "table %s already exists", name)
+	}
+	if _, exists := db.externals[name]; exists {
+		return errorf(ErrTableExists, "external table %s already exists", name)
 	}
 
 	table := NewTable(name, schema)
+	table.Temporary = db.temporary
+	db.tableSeq++
+	table.seq = db.tableSeq
+	table.created = time.Now()
 
 	for _, col := range schema.Columns {
 		if col.PrimaryKey {
@@ -47,10 +143,18 @@ func (db *Database) DropTable(name string) error {
 	defer db.mu.Unlock()
 
 	if _, exists := db.tables[name]; !exists {
-		return fmt.Errorf("table %s not found", name)
+		return errorf(ErrTableNotFound, "table %s not found", name)
+	}
+	for other, t := range db.tables {
+		for _, fk := range t.GetForeignKeys() {
+			if fk.RefTable == name && other != name {
+				return errorf(ErrForeignKeyViolation, "cannot drop table %s: table %s references it", name, other)
+			}
+		}
 	}
 
 	delete(db.tables, name)
+	db.dropStats(name)
 	return nil
 }
 
@@ -60,7 +164,7 @@ func (db *Database) GetTable(name string) (*Table, error) {
 
 	table, exists := db.tables[name]
 	if !exists {
-		return nil, fmt.Errorf("table %s not found", name)
+		return nil, errorf(ErrTableNotFound, "table %s not found", name)
 	}
 	return table, nil
 }
@@ -73,14 +177,28 @@ func (db *Database) TableExists(name string) bool {
 	return exists
 }
 
+// ListTables returns the names of the tables in the order they were
+// created.
 func (db *Database) ListTables() []string {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	tables := make([]string, 0, len(db.tables))
-	for name := range db.tables {
-		tables = append(tables, name)
+	tables := db.orderedTables()
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+	}
+	return names
+}
+
+// orderedTables returns the tables in the order they were created. Callers
+// must hold db.mu.
+func (db *Database) orderedTables() []*Table {
+	tables := make([]*Table, 0, len(db.tables))
+	for _, table := range db.tables {
+		tables = append(tables, table)
 	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].seq < tables[j].seq })
 	return tables
 }
 
@@ -89,7 +207,7 @@ func (db *Database) GetSchema(tableName string) (*Schema, error) {
 	if err != nil {
 		return nil, err
 	}
-	return table.Schema, nil
+	return table.Schema(), nil
 }
 
 func (db *Database) AddForeignKey(tableName string, fk *ForeignKey) error {
@@ -98,33 +216,48 @@ func (db *Database) AddForeignKey(tableName string, fk *ForeignKey) error {
 
 	table, exists := db.tables[tableName]
 	if !exists {
-		return fmt.Errorf("table %s not found", tableName)
+		return errorf(ErrTableNotFound, "table %s not found", tableName)
 	}
 
 	refTable, exists := db.tables[fk.RefTable]
 	if !exists {
-		return fmt.Errorf("referenced table %s not found", fk.RefTable)
+		return errorf(ErrTableNotFound, "referenced table %s not found", fk.RefTable)
 	}
 
 	for _, refColName := range fk.RefColumns {
-		if _, exists := refTable.Schema.GetColumn(refColName); !exists {
-			return fmt.Errorf("referenced column %s not found in table %s", refColName, fk.RefTable)
+		if _, exists := refTable.Schema().GetColumn(refColName); !exists {
+			return errorf(ErrColumnNotFound, "referenced column %s not found in table %s", refColName, fk.RefTable)
 		}
 	}
 
 	return table.AddForeignKey(fk)
 }
 
+// createTable creates a table with the foreign keys fks. If one of them
+// cannot be added, the table is dropped again.
+func (db *Database) createTable(name string, schema *Schema, fks []*ForeignKey) error {
+	if err := db.CreateTable(name, schema); err != nil {
+		return err
+	}
+	for _, fk := range fks {
+		if err := db.AddForeignKey(name, fk); err != nil {
+			db.DropTable(name)
+			return err
+		}
+	}
+	return nil
+}
+
 func (db *Database) CascadeDelete(tableName string, rowID int) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
 	table, exists := db.tables[tableName]
 	if !exists {
-		return fmt.Errorf("table %s not found", tableName)
+		return errorf(ErrTableNotFound, "table %s not found", tableName)
 	}
 
-	pkCols := table.Schema.PrimaryKeyColumns()
+	pkCols := table.Schema().PrimaryKeyColumns()
 	if len(pkCols) != 1 {
 		return fmt.Errorf("cascade delete only supported for single-column primary keys")
 	}
@@ -135,7 +268,7 @@ func (db *Database) CascadeDelete(tableName string, rowID int) error {
 		return err
 	}
 
-	pkValue, _ := row.Get(table.Schema.ColumnIndex(pkCol.Name))
+	pkValue, _ := row.Get(table.Schema().ColumnIndex(pkCol.Name))
 
 	for otherTableName, otherTable := range db.tables {
 		if otherTableName == tableName {
@@ -144,7 +277,7 @@ func (db *Database) CascadeDelete(tableName string, rowID int) error {
 
 		for _, fk := range otherTable.ForeignKeys {
 			if fk.RefTable == tableName && fk.OnDelete == FKActionCascade {
-				fkColIndex := otherTable.Schema.ColumnIndex(fk.Columns[0])
+				fkColIndex := otherTable.Schema().ColumnIndex(fk.Columns[0])
 				for idx, otherRow := range otherTable.Rows {
 					fkValue, _ := otherRow.Get(fkColIndex)
 					if pkValue.Equals(fkValue) {
@@ -165,29 +298,19 @@ func (db *Database) cascadeDeleteInternal(tableName string, rowID int) error {
 	for _, fk := range table.ForeignKeys {
 		if fk.OnDelete == FKActionCascade {
 			refTable := db.tables[fk.RefTable]
-			pkCols := refTable.Schema.PrimaryKeyColumns()
+			pkCols := refTable.Schema().PrimaryKeyColumns()
 			if len(pkCols) == 1 {
-				pkCol := pkCols[0]
-				pkValue, _ := row.Get(table.Schema.ColumnIndex(fk.Columns[0]))
-				for idx, refRow := range refTable.Rows {
-					refValue, _ := refRow.Get(refTable.Schema.ColumnIndex(pkCol.Name))
-					if pkValue.Equals(refValue) {
-						db.cascadeDeleteInternal(fk.RefTable, idx)
-					}
+				pkValue, _ := row.Get(table.Schema().ColumnIndex(fk.Columns[0]))
+				if idx, ok := refTable.pkPosition(pkValue); ok {
+					db.cascadeDeleteInternal(fk.RefTable, idx)
 				}
 			}
 		}
 	}
 
-	for colName, index := range table.Indexes {
-		colIndex := table.Schema.ColumnIndex(colName)
-		if val, err := row.Get(colIndex); err == nil {
-			_ = index
-			index.Delete(val)
-		}
-	}
-
 	table.Rows = append(table.Rows[:rowID], table.Rows[rowID+1:]...)
+	table.reindex()
+	table.modified = time.Now()
 
 	return nil
 }
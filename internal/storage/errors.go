@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the database wrap one of these kinds, so callers can
+// branch with errors.Is while the message keeps the details.
+var (
+	ErrTableNotFound       = errors.New("table not found")
+	ErrTableExists         = errors.New("table already exists")
+	ErrColumnNotFound      = errors.New("column not found")
+	ErrRowNotFound         = errors.New("row not found")
+	ErrTypeMismatch        = errors.New("type mismatch")
+	ErrNotNullViolation    = errors.New("not-null constraint violation")
+	ErrPrimaryKeyViolation = errors.New("primary key violation")
+	ErrUniqueViolation     = errors.New("unique constraint violation")
+	ErrForeignKeyViolation = errors.New("foreign key constraint violation")
+	ErrUserExists          = errors.New("user already exists")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrTxDone              = errors.New("transaction has already been committed or rolled back")
+	ErrHistoryUnavailable  = errors.New("history not available")
+	ErrSlotExists          = errors.New("replication slot already exists")
+	ErrSlotNotFound        = errors.New("replication slot not found")
+	ErrPreparedTxExists    = errors.New("prepared transaction already exists")
+	ErrPreparedTxNotFound  = errors.New("prepared transaction not found")
+
+	// ErrConstraintViolation matches any of the not-null, primary key,
+	// unique and foreign key violations.
+	ErrConstraintViolation = errors.New("constraint violation")
+)
+
+// Error is an error of a known Kind. Its message is Err's, and errors.As
+// still reaches any error Err wraps.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+func (e *Error) Is(target error) bool {
+	if target != ErrConstraintViolation {
+		return false
+	}
+	switch e.Kind {
+	case ErrNotNullViolation, ErrPrimaryKeyViolation, ErrUniqueViolation, ErrForeignKeyViolation:
+		return true
+	}
+	return false
+}
+
+// errorf formats an error of the given kind.
+func errorf(kind error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
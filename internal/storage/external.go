@@ -0,0 +1,45 @@
+package storage
+
+// ExternalTable is a read-only table whose rows live in a CSV file and are
+// read each time the table is scanned. Schema is nil when the columns are
+// taken from the file's header. External tables are not logged to the WAL,
+// so replicas and backups do not include them.
+type ExternalTable struct {
+	Name     string
+	Schema   *Schema
+	Location string
+}
+
+func (db *Database) CreateExternalTable(ext *ExternalTable) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, exists := db.tables[ext.Name]; exists {
+		return errorf(ErrTableExists, "table %s already exists", ext.Name)
+	}
+	if _, exists := db.externals[ext.Name]; exists {
+		return errorf(ErrTableExists, "external table %s already exists", ext.Name)
+	}
+	db.externals[ext.Name] = ext
+	return nil
+}
+
+func (db *Database) DropExternalTable(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, exists := db.externals[name]; !exists {
+		return errorf(ErrTableNotFound, "external table %s not found", name)
+	}
+	delete(db.externals, name)
+	return nil
+}
+
+// ExternalTable returns the definition of external table name.
+func (db *Database) ExternalTable(name string) (*ExternalTable, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	ext, exists := db.externals[name]
+	return ext, exists
+}
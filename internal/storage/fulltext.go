@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Full-text search splits text into words, runs of letters and digits
+// folded to lower case. A query is a list of terms, each a word or, ending
+// in *, a prefix of one; a row matches when its text has every term.
+// Matches are ranked with Okapi BM25, which favours rows where the terms
+// are frequent, terms that are rare in the table, and short texts.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Tokenize returns the words of text, in order.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// FullTextTerms parses a full-text query into its terms: its words, with
+// a * kept on those that end in one.
+func FullTextTerms(query string) []string {
+	var terms []string
+	for _, field := range strings.Fields(query) {
+		words := Tokenize(field)
+		if len(words) == 0 {
+			continue
+		}
+		if strings.HasSuffix(field, "*") {
+			words[len(words)-1] += "*"
+		}
+		terms = append(terms, words...)
+	}
+	return terms
+}
+
+// MatchesFullText reports whether text has every one of terms, as a
+// full-text index would find it. No terms match nothing.
+func MatchesFullText(text string, terms []string) bool {
+	if len(terms) == 0 {
+		return false
+	}
+	words := Tokenize(text)
+	for _, term := range terms {
+		found := false
+		for _, word := range words {
+			if termMatches(term, word) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func termMatches(term, word string) bool {
+	if prefix, ok := strings.CutSuffix(term, "*"); ok {
+		return strings.HasPrefix(word, prefix)
+	}
+	return term == word
+}
+
+// wordEntries returns the entries of a full-text or trigram index for the
+// row at position pos: one per distinct word or trigram, with how often it
+// occurs.
+func (idx *SecondaryIndex) wordEntries(row *Row, pos int) []indexEntry {
+	v, err := row.Get(idx.column)
+	if err != nil || v.Type() == TypeNull || idx.matches != nil && !idx.matches(row) {
+		return nil
+	}
+	words := Tokenize(v.ToString())
+	if idx.Trigram {
+		words = Trigrams(v.ToString())
+	}
+	idx.words[pos] = len(words)
+	counts := make(map[string]int, len(words))
+	for _, word := range words {
+		counts[word]++
+	}
+	entries := make([]indexEntry, 0, len(counts))
+	for word, count := range counts {
+		entries = append(entries, indexEntry{key: NewTextValue(word), pos: pos, count: count})
+	}
+	return entries
+}
+
+// termEntries returns the entries of the words term matches.
+func (idx *SecondaryIndex) termEntries(term string) []indexEntry {
+	prefix, isPrefix := strings.CutSuffix(term, "*")
+	start := NewTextValue(prefix)
+	lo := sort.Search(len(idx.entries), func(i int) bool { return !idx.entries[i].key.LessThan(start) })
+	var entries []indexEntry
+	for _, entry := range idx.entries[lo:] {
+		word := entry.key.ToString()
+		if isPrefix && !strings.HasPrefix(word, prefix) || !isPrefix && word != prefix {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// ScanFullText calls fn, in scan order, with each row of the full-text
+// index name that matches terms and the row's BM25 rank. It reports false,
+// without calling fn, if the table has no such index.
+func (t *Table) ScanFullText(name string, terms []string, fn func(row *Row, rank float64) bool) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	idx, ok := t.secondary[name]
+	if !ok || !idx.FullText {
+		return false
+	}
+	t.countScan(usageKey{secondary: true, name: name})
+	if len(terms) == 0 || len(idx.words) == 0 {
+		return true
+	}
+	total := 0
+	for _, n := range idx.words {
+		total += n
+	}
+	docs := float64(len(idx.words))
+	avgWords := float64(total) / docs
+
+	ranks := make(map[int]float64)
+	for i, term := range terms {
+		counts := make(map[int]int)
+		for _, entry := range idx.termEntries(term) {
+			counts[entry.pos] += entry.count
+		}
+		df := float64(len(counts))
+		idf := math.Log(1 + (docs-df+0.5)/(df+0.5))
+		next := make(map[int]float64, len(counts))
+		for pos, count := range counts {
+			rank, ok := ranks[pos]
+			if i > 0 && !ok {
+				continue
+			}
+			tf := float64(count)
+			norm := 1 - bm25B + bm25B*float64(idx.words[pos])/avgWords
+			next[pos] = rank + idf*tf*(bm25K1+1)/(tf+bm25K1*norm)
+		}
+		ranks = next
+	}
+
+	positions := make([]int, 0, len(ranks))
+	for pos := range ranks {
+		positions = append(positions, pos)
+	}
+	sort.Ints(positions)
+	for _, pos := range positions {
+		if pos < len(t.Rows) && !fn(t.Rows[pos], ranks[pos]) {
+			break
+		}
+	}
+	return true
+}
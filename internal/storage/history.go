@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"time"
+)
+
+// The WAL doubles as the database's history: a table's state at a past
+// time is its current state with every change committed since undone.
+// History reaches back as far as the WAL does, which SetHistoryRetention
+// bounds.
+
+// SetHistoryRetention keeps committed WAL entries for at least d; older
+// entries are discarded as new ones are committed, unless a replication
+// slot or a connected replica still needs them. Replicas and change feeds
+// cannot catch up from before the retained entries, nor AS OF queries
+// read before them. With 0, the default, there is no window: entries are
+// discarded once every slot and replica has confirmed them, and kept
+// while there are none.
+func (db *Database) SetHistoryRetention(d time.Duration) {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+	db.wal.retention = d
+	db.wal.prune(time.Now())
+}
+
+func (db *Database) HistoryRetention() time.Duration {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+	return db.wal.retention
+}
+
+// prune discards the entries committed before the retention window but
+// for those a replication slot or replica has not confirmed. Callers must
+// hold w.mu.
+func (w *WAL) prune(now time.Time) {
+	held, holders := w.heldLSN()
+	if w.retention <= 0 && !holders {
+		return
+	}
+	cutoff := now.Add(-w.retention)
+	n := 0
+	for n < len(w.entries) && (w.retention <= 0 || w.entries[n].Time.Before(cutoff)) && (!holders || w.entries[n].LSN <= held) {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	w.oldest = w.entries[n-1].LSN
+	w.horizon = w.entries[n-1].Time
+	// Slicing off the front keeps the array, which append replaces once
+	// it fills; clearing lets the discarded entries go meanwhile.
+	clear(w.entries[:n])
+	w.entries = w.entries[n:]
+}
+
+// Oldest returns the LSN the retained entries follow: Since misses entries
+// for any LSN below it.
+func (w *WAL) Oldest() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.oldest
+}
+
+// Horizon returns the earliest time history is available from, or the
+// zero time if no entry has been discarded.
+func (w *WAL) Horizon() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.horizon
+}
+
+// TableAsOf returns a copy of table name as it was at time at, rebuilt by
+// undoing the changes committed after at. Changes not yet committed are
+// part of the current state and so of the copy. The copy has no indexes.
+func (db *Database) TableAsOf(name string, at time.Time) (*Table, error) {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+
+	if at.Before(db.wal.horizon) {
+		return nil, errorf(ErrHistoryUnavailable, "history before %s is no longer retained", db.wal.horizon.Format(time.RFC3339))
+	}
+
+	table, err := db.GetTable(name)
+	if err != nil {
+		return nil, err
+	}
+
+	table.mu.RLock()
+	past := NewTable(name, table.Schema())
+	for _, row := range table.Rows {
+		past.Rows = append(past.Rows, NewRow(cloneValues(row.Values)))
+	}
+	table.mu.RUnlock()
+
+	for i := len(db.wal.entries) - 1; i >= 0 && db.wal.entries[i].Time.After(at); i-- {
+		changes := db.wal.entries[i].Changes
+		for j := len(changes) - 1; j >= 0; j-- {
+			if changes[j].Table != name {
+				continue
+			}
+			if err := past.undoWALChange(changes[j], at); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return past, nil
+}
+
+// undoWALChange reverts one committed change to t.
+func (t *Table) undoWALChange(change WALChange, at time.Time) error {
+	switch change.Op {
+	case WALCreateTable:
+		return errorf(ErrTableNotFound, "table %s did not exist at %s", t.Name, at.Format(time.RFC3339))
+	case WALDropTable:
+		return errorf(ErrHistoryUnavailable, "table %s was dropped and re-created since %s", t.Name, at.Format(time.RFC3339))
+	case WALDelete:
+		t.Rows = append(t.Rows, NewRow(cloneValues(change.Before)))
+		return nil
+	case WALAlterColumn:
+		return errorf(ErrHistoryUnavailable, "a column of table %s changed type since %s", t.Name, at.Format(time.RFC3339))
+	case WALCreateIndex, WALDropIndex:
+		return nil
+	}
+
+	row := t.findRow(change.After)
+	if row == nil {
+		return errorf(ErrHistoryUnavailable, "history of table %s does not match its current rows", t.Name)
+	}
+	switch change.Op {
+	case WALInsert:
+		for i, r := range t.Rows {
+			if r == row {
+				t.Rows = append(t.Rows[:i], t.Rows[i+1:]...)
+				break
+			}
+		}
+	case WALUpdate:
+		row.Values = cloneValues(change.Before)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"time"
+)
+
+// Hooks are callbacks a program embedding the database sets to follow or
+// police what it does, e.g. to invalidate caches, count statements or
+// reject writes. Each runs synchronously, on the goroutine of the
+// statement or transaction that triggers it and without any lock held, so
+// it may read the database but should be quick. Nil hooks are skipped.
+type Hooks struct {
+	// OnCreateTable runs before a transaction creates a permanent table;
+	// an error stops the CREATE TABLE.
+	OnCreateTable func(name string, schema *Schema) error
+	// OnInsert runs for each row a transaction inserts into a permanent
+	// table, as stored, with its defaults and generated key; an error
+	// fails the insert and removes the rows it added.
+	OnInsert func(table string, row map[string]Value) error
+	// OnCommit runs after a transaction that changed permanent tables
+	// commits, with its changes.
+	OnCommit func(events []ChangeEvent)
+	// OnError runs when an SQL statement fails to execute, with its text.
+	OnError func(statement string, err error)
+}
+
+// SetHooks replaces the database's hooks.
+func (db *Database) SetHooks(hooks Hooks) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.hooks = hooks
+}
+
+// Hooks returns the database's hooks.
+func (db *Database) Hooks() Hooks {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.hooks
+}
+
+// insertHook runs OnInsert for the rows inserted into table.
+func (db *Database) insertHook(table *Table, rows []*Row) error {
+	hook := db.Hooks().OnInsert
+	if hook == nil || table.Temporary {
+		return nil
+	}
+	columns := table.Schema().ColumnNames()
+	for _, row := range rows {
+		if err := hook(table.Name, rowMap(columns, cloneValues(row.Values))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitEvents runs OnCommit for the changes of a transaction committed as
+// entry lsn, or 0 when a commit hook logs it.
+func (db *Database) commitEvents(lsn uint64, changes []WALChange) {
+	hook := db.Hooks().OnCommit
+	if hook == nil || len(changes) == 0 {
+		return
+	}
+	hook(WALEntry{LSN: lsn, Time: time.Now(), Changes: changes}.Events())
+}
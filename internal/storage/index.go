@@ -0,0 +1,493 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SecondaryIndex is an index made with CREATE INDEX on one column. Unlike
+// the indexes of PRIMARY KEY and UNIQUE columns (Table.Indexes) it may hold
+// a value for many rows. A partial index has a predicate and holds only
+// the rows it matches: Predicate is its SQL text, Condition the caller's
+// parsed form of it, and matches evaluates it. Rows whose key is NULL are
+// kept apart from the sorted keys, so that reading the whole of a partial
+// index still finds them. The index also stores the values
+// of the Include columns, so a query needing only those and the key can
+// be answered from the index alone (ScanIndexOnly).
+//
+// A FullText index holds the words of a TEXT column instead of its value:
+// an entry for each distinct word of each row (see fulltext.go). A Trigram
+// index holds the trigrams of the column the same way (see trigram.go).
+//
+// Indexes made in a transaction (Tx.CreateIndex, Tx.DropIndex) are logged
+// by their definition, and ROLLBACK undoes them. Replaying a partial index
+// parses its Predicate again with the parser installed by
+// SetConditionParser.
+type SecondaryIndex struct {
+	Name      string
+	Table     string
+	Column    string
+	Include   []string
+	Predicate string
+	Condition interface{}
+	FullText  bool
+	Trigram   bool
+	matches   func(*Row) bool
+	column    int
+	include   []int
+	entries   []indexEntry // by key, then row position
+	nulls     []indexEntry // the rows with a NULL key
+	words     map[int]int  // of a full-text or trigram index: words in each row, by position
+}
+
+type indexEntry struct {
+	key    Value
+	pos    int
+	values []Value // of the Include columns
+	count  int     // of a full-text or trigram index: times the word occurs in the row
+}
+
+// Kind is FULLTEXT or TRIGRAM for an index of the words or trigrams of
+// its column, and empty for one of its values.
+func (idx *SecondaryIndex) Kind() string {
+	switch {
+	case idx.FullText:
+		return "FULLTEXT"
+	case idx.Trigram:
+		return "TRIGRAM"
+	}
+	return ""
+}
+
+// Partial reports whether the index has a predicate.
+func (idx *SecondaryIndex) Partial() bool {
+	return idx.matches != nil
+}
+
+// Covers reports whether the index holds the values of all of columns.
+func (idx *SecondaryIndex) Covers(columns []string) bool {
+	if idx.Kind() != "" {
+		return false
+	}
+	for _, column := range columns {
+		if column == idx.Column {
+			continue
+		}
+		found := false
+		for _, include := range idx.Include {
+			found = found || include == column
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// entry returns the index entry of the row at position pos, and whether
+// the row belongs in the index.
+func (idx *SecondaryIndex) entry(row *Row, pos int) (indexEntry, bool) {
+	key, err := row.Get(idx.column)
+	if err != nil || idx.matches != nil && !idx.matches(row) {
+		return indexEntry{}, false
+	}
+	entry := indexEntry{key: key, pos: pos}
+	for _, i := range idx.include {
+		v, err := row.Get(i)
+		if err != nil {
+			v = NullValue{}
+		}
+		entry.values = append(entry.values, v)
+	}
+	return entry, true
+}
+
+// add indexes the row at position pos, if it belongs in the index.
+func (idx *SecondaryIndex) add(row *Row, pos int) {
+	if idx.Kind() != "" {
+		for _, entry := range idx.wordEntries(row, pos) {
+			idx.insert(entry)
+		}
+		return
+	}
+	entry, ok := idx.entry(row, pos)
+	if !ok {
+		return
+	}
+	if entry.key.Type() == TypeNull {
+		idx.nulls = append(idx.nulls, entry)
+		return
+	}
+	idx.insert(entry)
+}
+
+// remove drops the entries of the row at position pos, whose values were
+// those of row.
+func (idx *SecondaryIndex) remove(row *Row, pos int) {
+	var entries []indexEntry
+	if idx.Kind() != "" {
+		entries = idx.wordEntries(row, pos)
+		delete(idx.words, pos)
+	} else if entry, ok := idx.entry(row, pos); ok {
+		if entry.key.Type() == TypeNull {
+			for i, e := range idx.nulls {
+				if e.pos == pos {
+					idx.nulls = append(idx.nulls[:i], idx.nulls[i+1:]...)
+					break
+				}
+			}
+			return
+		}
+		entries = []indexEntry{entry}
+	}
+	for _, entry := range entries {
+		i := sort.Search(len(idx.entries), func(i int) bool { return !entryLess(idx.entries[i], entry) })
+		if i < len(idx.entries) && idx.entries[i].pos == pos && idx.entries[i].key.Equals(entry.key) {
+			idx.entries = append(idx.entries[:i], idx.entries[i+1:]...)
+		}
+	}
+}
+
+func (idx *SecondaryIndex) insert(entry indexEntry) {
+	i := sort.Search(len(idx.entries), func(i int) bool { return !entryLess(idx.entries[i], entry) })
+	idx.entries = append(idx.entries, indexEntry{})
+	copy(idx.entries[i+1:], idx.entries[i:])
+	idx.entries[i] = entry
+}
+
+func (idx *SecondaryIndex) rebuild(rows []*Row) {
+	idx.entries, idx.nulls = idx.entries[:0], idx.nulls[:0]
+	if idx.Kind() != "" {
+		idx.words = make(map[int]int)
+	}
+	for i, row := range rows {
+		if idx.Kind() != "" {
+			idx.entries = append(idx.entries, idx.wordEntries(row, i)...)
+			continue
+		}
+		entry, ok := idx.entry(row, i)
+		if !ok {
+			continue
+		}
+		if entry.key.Type() == TypeNull {
+			idx.nulls = append(idx.nulls, entry)
+			continue
+		}
+		idx.entries = append(idx.entries, entry)
+	}
+	sort.Slice(idx.entries, func(i, j int) bool { return entryLess(idx.entries[i], idx.entries[j]) })
+}
+
+func entryLess(a, b indexEntry) bool {
+	if a.key.LessThan(b.key) {
+		return true
+	}
+	if b.key.LessThan(a.key) {
+		return false
+	}
+	return a.pos < b.pos
+}
+
+// between returns the entries whose key lies between start and end
+// inclusive, in row order. A nil bound leaves that end open; with both
+// open the rows with a NULL key are included too.
+func (idx *SecondaryIndex) between(start, end Value) []indexEntry {
+	lo := 0
+	if start != nil {
+		lo = sort.Search(len(idx.entries), func(i int) bool { return !idx.entries[i].key.LessThan(start) })
+	}
+	var entries []indexEntry
+	if start == nil && end == nil {
+		entries = append(entries, idx.nulls...)
+	}
+	for _, entry := range idx.entries[lo:] {
+		if end != nil && end.LessThan(entry.key) {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].pos < entries[j].pos })
+	return entries
+}
+
+// CreateIndex adds idx, a secondary index on idx.Column of idx.Table,
+// holding every row or, when matches is not nil, the rows it matches.
+// Index names are unique across the database's tables.
+func (db *Database) CreateIndex(idx *SecondaryIndex, matches func(*Row) bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, exists := db.findIndex(idx.Name); exists || db.building(idx.Name) {
+		return errorf(ErrTableExists, "index %s already exists", idx.Name)
+	}
+	t, exists := db.tables[idx.Table]
+	if !exists {
+		return errorf(ErrTableNotFound, "table %s not found", idx.Table)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.prepareIndex(idx, matches); err != nil {
+		return err
+	}
+	idx.rebuild(t.Rows)
+	t.addSecondary(idx)
+	return nil
+}
+
+// prepareIndex checks idx's columns against the table and resolves them.
+// Callers must hold t.mu.
+func (t *Table) prepareIndex(idx *SecondaryIndex, matches func(*Row) bool) error {
+	idx.column = t.Schema().ColumnIndex(idx.Column)
+	if idx.column < 0 {
+		return errorf(ErrColumnNotFound, "column %s not found in table %s", idx.Column, idx.Table)
+	}
+	if kind := idx.Kind(); kind != "" && t.Schema().Columns[idx.column].Type != TypeText {
+		return errorf(ErrTypeMismatch, "%s index %s needs a TEXT column, but %s is %s", kind, idx.Name, idx.Column, t.Schema().Columns[idx.column].Type)
+	}
+	idx.include = make([]int, len(idx.Include))
+	for i, name := range idx.Include {
+		idx.include[i] = t.Schema().ColumnIndex(name)
+		if idx.include[i] < 0 {
+			return errorf(ErrColumnNotFound, "column %s not found in table %s", name, idx.Table)
+		}
+	}
+	idx.matches = matches
+	return nil
+}
+
+// addSecondary makes idx one of the table's indexes. Callers must hold
+// t.mu.
+func (t *Table) addSecondary(idx *SecondaryIndex) {
+	if t.secondary == nil {
+		t.secondary = make(map[string]*SecondaryIndex)
+	}
+	t.secondary[idx.Name] = idx
+}
+
+// DropIndex removes the secondary index name.
+func (db *Database) DropIndex(name string) error {
+	_, err := db.dropIndex(name)
+	return err
+}
+
+func (db *Database) dropIndex(name string) (*SecondaryIndex, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	idx, exists := db.findIndex(name)
+	if !exists {
+		return nil, errorf(ErrTableNotFound, "index %s not found", name)
+	}
+	t := db.tables[idx.Table]
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.secondary, name)
+	t.forgetUsage(usageKey{secondary: true, name: name})
+	return idx, nil
+}
+
+// restoreIndex adds back idx, dropped by a transaction that rolled back.
+func (db *Database) restoreIndex(idx *SecondaryIndex) {
+	db.mu.RLock()
+	t, exists := db.tables[idx.Table]
+	db.mu.RUnlock()
+	if !exists {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	idx.rebuild(t.Rows)
+	t.addSecondary(idx)
+}
+
+// CreateIndex creates idx as Database.CreateIndex does and logs its
+// definition. Rolling back drops it again.
+func (tx *Tx) CreateIndex(idx *SecondaryIndex, matches func(*Row) bool) error {
+	return tx.createIndex(idx, matches, tx.db.CreateIndex)
+}
+
+// CreateIndexConcurrently is CreateIndex building the index as
+// Database.CreateIndexConcurrently does.
+func (tx *Tx) CreateIndexConcurrently(idx *SecondaryIndex, matches func(*Row) bool) error {
+	return tx.createIndex(idx, matches, tx.db.CreateIndexConcurrently)
+}
+
+func (tx *Tx) createIndex(idx *SecondaryIndex, matches func(*Row) bool, create func(*SecondaryIndex, func(*Row) bool) error) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	if err := create(idx, matches); err != nil {
+		return err
+	}
+
+	tx.undo = append(tx.undo, undoEntry{
+		createdIndex: idx,
+		wal:          []WALChange{{Op: WALCreateIndex, Table: idx.Table, Index: idx.definition()}},
+	})
+	return nil
+}
+
+// DropIndex drops the secondary index name and logs it. Rolling back adds
+// it back.
+func (tx *Tx) DropIndex(name string) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	idx, err := tx.db.dropIndex(name)
+	if err != nil {
+		return err
+	}
+
+	tx.undo = append(tx.undo, undoEntry{
+		droppedIndex: idx,
+		wal:          []WALChange{{Op: WALDropIndex, Table: idx.Table, Index: &SecondaryIndex{Name: name, Table: idx.Table}}},
+	})
+	return nil
+}
+
+// definition returns a new index with idx's name, table, columns, kind
+// and predicate, as it is logged.
+func (idx *SecondaryIndex) definition() *SecondaryIndex {
+	return &SecondaryIndex{
+		Name:      idx.Name,
+		Table:     idx.Table,
+		Column:    idx.Column,
+		Include:   append([]string(nil), idx.Include...),
+		Predicate: idx.Predicate,
+		FullText:  idx.FullText,
+		Trigram:   idx.Trigram,
+	}
+}
+
+// ConditionParser parses the predicate of a partial index of table, as
+// logged, into the Condition and the matching function CreateIndex takes.
+type ConditionParser func(db *Database, table *Table, predicate string) (condition interface{}, matches func(*Row) bool, err error)
+
+var conditionParser ConditionParser
+
+// SetConditionParser installs the parser that replaying a partial index
+// from the WAL, a dump or a backup uses. The SQL package installs its own.
+func SetConditionParser(parse ConditionParser) {
+	conditionParser = parse
+}
+
+// loggedIndex returns a new index made from def, a logged definition, and
+// its matching function.
+func (db *Database) loggedIndex(def *SecondaryIndex) (*SecondaryIndex, func(*Row) bool, error) {
+	idx := def.definition()
+	if idx.Predicate == "" {
+		return idx, nil, nil
+	}
+	if conditionParser == nil {
+		return nil, nil, fmt.Errorf("cannot create partial index %s: no condition parser installed", idx.Name)
+	}
+	table, err := db.GetTable(idx.Table)
+	if err != nil {
+		return nil, nil, err
+	}
+	condition, matches, err := conditionParser(db, table, idx.Predicate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create partial index %s: %w", idx.Name, err)
+	}
+	idx.Condition = condition
+	return idx, matches, nil
+}
+
+// findIndex returns the secondary index name. Callers must hold db.mu.
+func (db *Database) findIndex(name string) (*SecondaryIndex, bool) {
+	for _, t := range db.tables {
+		t.mu.RLock()
+		idx, exists := t.secondary[name]
+		t.mu.RUnlock()
+		if exists {
+			return idx, true
+		}
+	}
+	return nil, false
+}
+
+// SecondaryIndexes returns the table's secondary indexes by name.
+func (t *Table) SecondaryIndexes() []*SecondaryIndex {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.secondaryIndexes()
+}
+
+// secondaryIndexes is SecondaryIndexes for callers holding t.mu.
+func (t *Table) secondaryIndexes() []*SecondaryIndex {
+	indexes := make([]*SecondaryIndex, 0, len(t.secondary))
+	for _, idx := range t.secondary {
+		indexes = append(indexes, idx)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes
+}
+
+// IndexLen returns the number of rows in the secondary index name.
+func (t *Table) IndexLen(name string) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	idx, ok := t.secondary[name]
+	if !ok {
+		return 0
+	}
+	if idx.Kind() != "" {
+		return len(idx.words)
+	}
+	return len(idx.entries) + len(idx.nulls)
+}
+
+// ScanSecondaryIndex is Scan restricted to the rows in the secondary index
+// name whose key lies between start and end inclusive (nil for an open
+// end). The rows come in scan order. It reports false, without calling
+// fn, if the table has no such index or it is a full-text or trigram one.
+func (t *Table) ScanSecondaryIndex(name string, start, end Value, fn func(*Row) bool) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	idx, ok := t.secondary[name]
+	if !ok || idx.Kind() != "" {
+		return false
+	}
+	t.countScan(usageKey{secondary: true, name: name})
+	for _, entry := range idx.between(start, end) {
+		if entry.pos < 0 || entry.pos >= len(t.Rows) {
+			continue
+		}
+		if !fn(t.Rows[entry.pos]) {
+			break
+		}
+	}
+	return true
+}
+
+// ScanIndexOnly is ScanSecondaryIndex without reading the table's rows:
+// fn gets rows built from the index entries, holding the key and the
+// Include columns, with every other column NULL. Callers use it when the
+// index Covers the columns they read.
+func (t *Table) ScanIndexOnly(name string, start, end Value, fn func(*Row) bool) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	idx, ok := t.secondary[name]
+	if !ok || idx.Kind() != "" {
+		return false
+	}
+	t.countScan(usageKey{secondary: true, name: name})
+	for _, entry := range idx.between(start, end) {
+		values := make([]Value, len(t.Schema().Columns))
+		for i := range values {
+			values[i] = NullValue{}
+		}
+		values[idx.column] = entry.key
+		for i, col := range idx.include {
+			values[col] = entry.values[i]
+		}
+		if !fn(NewRow(values)) {
+			break
+		}
+	}
+	return true
+}
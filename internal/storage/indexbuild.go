@@ -0,0 +1,123 @@
+package storage
+
+// A concurrent index build (CREATE INDEX CONCURRENTLY) reads a copy of the
+// table's rows and builds the index from it without holding the table's
+// lock, so writes carry on meanwhile. The table logs the positions of the
+// rows inserted during the build, and the build adds those at the end,
+// under the lock, before making the index visible. Index entries hold row
+// positions, so an update or delete during the build, which moves rows or
+// changes their values, spoils the copy: the build starts over from a new
+// one, and after concurrentBuildAttempts builds under the lock instead.
+
+const concurrentBuildAttempts = 3
+
+// indexBuild is a concurrent build in progress.
+type indexBuild struct {
+	idx   *SecondaryIndex
+	added []int // positions of the rows inserted since the copy
+	stale bool  // rows moved or changed since the copy
+}
+
+// CreateIndexConcurrently adds idx as CreateIndex does, but builds it
+// while the table takes writes, which only wait while the rows are copied
+// and while the rows written meanwhile are indexed. Queries do not use the
+// index until it is complete.
+func (db *Database) CreateIndexConcurrently(idx *SecondaryIndex, matches func(*Row) bool) error {
+	t, err := db.startBuild(idx, matches)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		t.mu.Lock()
+		build := &indexBuild{idx: idx}
+		t.builds[idx.Name] = build
+		rows := make([]*Row, len(t.Rows))
+		for i, row := range t.Rows {
+			rows[i] = row.Clone()
+		}
+		t.mu.Unlock()
+
+		idx.rebuild(rows)
+
+		db.mu.Lock()
+		t.mu.Lock()
+		if db.tables[idx.Table] != t {
+			delete(t.builds, idx.Name)
+			t.mu.Unlock()
+			db.mu.Unlock()
+			return errorf(ErrTableNotFound, "table %s was dropped while index %s was built", idx.Table, idx.Name)
+		}
+		if build.stale && attempt < concurrentBuildAttempts {
+			t.mu.Unlock()
+			db.mu.Unlock()
+			continue
+		}
+		if build.stale {
+			idx.rebuild(t.Rows)
+		} else {
+			for _, pos := range build.added {
+				idx.add(t.Rows[pos], pos)
+			}
+		}
+		delete(t.builds, idx.Name)
+		t.addSecondary(idx)
+		t.mu.Unlock()
+		db.mu.Unlock()
+		return nil
+	}
+}
+
+// startBuild checks idx and reserves its name for the build.
+func (db *Database) startBuild(idx *SecondaryIndex, matches func(*Row) bool) (*Table, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, exists := db.findIndex(idx.Name); exists || db.building(idx.Name) {
+		return nil, errorf(ErrTableExists, "index %s already exists", idx.Name)
+	}
+	t, exists := db.tables[idx.Table]
+	if !exists {
+		return nil, errorf(ErrTableNotFound, "table %s not found", idx.Table)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.prepareIndex(idx, matches); err != nil {
+		return nil, err
+	}
+	if t.builds == nil {
+		t.builds = make(map[string]*indexBuild)
+	}
+	t.builds[idx.Name] = &indexBuild{idx: idx}
+	return t, nil
+}
+
+// building reports whether an index called name is being built. Callers
+// must hold db.mu.
+func (db *Database) building(name string) bool {
+	for _, t := range db.tables {
+		t.mu.RLock()
+		_, exists := t.builds[name]
+		t.mu.RUnlock()
+		if exists {
+			return true
+		}
+	}
+	return false
+}
+
+// logBuilds records the row inserted at pos for the builds in progress.
+// Callers must hold t.mu.
+func (t *Table) logBuilds(pos int) {
+	for _, build := range t.builds {
+		build.added = append(build.added, pos)
+	}
+}
+
+// invalidateBuilds marks the builds in progress stale after rows moved or
+// changed. Callers must hold t.mu.
+func (t *Table) invalidateBuilds() {
+	for _, build := range t.builds {
+		build.stale = true
+	}
+}
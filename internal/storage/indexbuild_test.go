@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCreateIndexConcurrently(t *testing.T) {
+	db := NewDatabase()
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	schema.AddColumn(NewColumn("n", TypeInteger, false, false, false))
+	if err := db.CreateTable("items", schema); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("items")
+	for i := 1; i <= 2000; i++ {
+		table.Insert(NewRow([]Value{NewIntegerValue(int64(i)), NewIntegerValue(int64(i % 10))}))
+	}
+
+	// Writes run while the index is built; whichever way the build goes,
+	// the finished index must match the table.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 2001; i <= 2200; i++ {
+			table.Insert(NewRow([]Value{NewIntegerValue(int64(i)), NewIntegerValue(int64(i % 10))}))
+			if i%50 == 0 {
+				id := NewIntegerValue(int64(i - 2000))
+				table.Delete(func(row *Row) bool { return row.Values[0].Equals(id) })
+			}
+		}
+	}()
+	idx := &SecondaryIndex{Name: "items_n", Table: "items", Column: "n"}
+	if err := db.CreateIndexConcurrently(idx, nil); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if got, want := table.IndexLen("items_n"), table.Count(); got != want {
+		t.Fatalf("index has %d rows, table %d", got, want)
+	}
+	found := 0
+	table.ScanSecondaryIndex("items_n", NewIntegerValue(3), NewIntegerValue(3), func(row *Row) bool {
+		if !row.Values[1].Equals(NewIntegerValue(3)) {
+			t.Errorf("row %v under key 3", row.Values)
+		}
+		found++
+		return true
+	})
+	want := 0
+	for _, row := range table.Select(nil) {
+		if row.Values[1].Equals(NewIntegerValue(3)) {
+			want++
+		}
+	}
+	if found != want {
+		t.Errorf("found %d rows with n = 3, want %d", found, want)
+	}
+
+	if err := db.CreateIndexConcurrently(&SecondaryIndex{Name: "items_n", Table: "items", Column: "id"}, nil); err == nil {
+		t.Error("a second index named items_n was created")
+	}
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// IndexUsage counts the reads an index served and the rows written to it
+// since the server started. An index that is written far more often than
+// it is read costs more than it saves.
+type IndexUsage struct {
+	Table       string
+	Index       string // table_pkey or table_column_key for the primary key and UNIQUE indexes
+	Column      string
+	Kind        string // PRIMARY KEY, UNIQUE, INDEX, FULLTEXT or TRIGRAM
+	Scans       int64
+	RowsWritten int64
+	LastScan    time.Time // zero if never read
+}
+
+// usageKey names an index of a table: a secondary index by its name, a
+// primary key or UNIQUE index by its column.
+type usageKey struct {
+	secondary bool
+	name      string
+}
+
+type indexCounts struct {
+	scans    int64
+	writes   int64
+	lastScan time.Time
+}
+
+// countScan records a read served by an index. Callers must hold t.mu,
+// for reading at least.
+func (t *Table) countScan(key usageKey) {
+	t.usageMu.Lock()
+	defer t.usageMu.Unlock()
+	c := t.counts(key)
+	c.scans++
+	c.lastScan = time.Now()
+}
+
+// countWrites records n rows written to every index of the table. Callers
+// must hold t.mu.
+func (t *Table) countWrites(n int) {
+	if n == 0 || len(t.Indexes) == 0 && len(t.secondary) == 0 {
+		return
+	}
+	t.usageMu.Lock()
+	defer t.usageMu.Unlock()
+	for column := range t.Indexes {
+		t.counts(usageKey{name: column}).writes += int64(n)
+	}
+	for name := range t.secondary {
+		t.counts(usageKey{secondary: true, name: name}).writes += int64(n)
+	}
+}
+
+// forgetUsage drops the counts of an index that was removed. Callers must
+// hold t.mu.
+func (t *Table) forgetUsage(key usageKey) {
+	t.usageMu.Lock()
+	defer t.usageMu.Unlock()
+	delete(t.usage, key)
+}
+
+// counts returns the counts of key. Callers must hold t.usageMu.
+func (t *Table) counts(key usageKey) *indexCounts {
+	if t.usage == nil {
+		t.usage = make(map[usageKey]*indexCounts)
+	}
+	c, ok := t.usage[key]
+	if !ok {
+		c = &indexCounts{}
+		t.usage[key] = c
+	}
+	return c
+}
+
+// IndexUsage returns the usage of each of the table's indexes, by name.
+func (t *Table) IndexUsage() []IndexUsage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.usageMu.Lock()
+	defer t.usageMu.Unlock()
+
+	var usage []IndexUsage
+	add := func(u IndexUsage, key usageKey) {
+		if c, ok := t.usage[key]; ok {
+			u.Scans, u.RowsWritten, u.LastScan = c.scans, c.writes, c.lastScan
+		}
+		usage = append(usage, u)
+	}
+	for column := range t.Indexes {
+		u := IndexUsage{Table: t.Name, Index: fmt.Sprintf("%s_%s_key", t.Name, column), Column: column, Kind: "UNIQUE"}
+		if col, ok := t.Schema().GetColumn(column); ok && col.PrimaryKey {
+			u.Index, u.Kind = t.Name+"_pkey", "PRIMARY KEY"
+		}
+		add(u, usageKey{name: column})
+	}
+	for name, idx := range t.secondary {
+		kind := idx.Kind()
+		if kind == "" {
+			kind = "INDEX"
+		}
+		add(IndexUsage{Table: t.Name, Index: name, Column: idx.Column, Kind: kind}, usageKey{secondary: true, name: name})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Index < usage[j].Index })
+	return usage
+}
+
+// IndexUsage returns the usage of the indexes of every table, in creation
+// order.
+func (db *Database) IndexUsage() []IndexUsage {
+	db.mu.RLock()
+	tables := db.orderedTables()
+	db.mu.RUnlock()
+
+	var usage []IndexUsage
+	for _, t := range tables {
+		usage = append(usage, t.IndexUsage()...)
+	}
+	return usage
+}
+
+// AdviceMinScans is how many full scans must filter on a column before
+// IndexAdvice suggests an index on it.
+const AdviceMinScans = 5
+
+// IndexAdvice suggests indexing Column of Table: Scans full scans of the
+// table, reading RowsRead rows in all, looked for rows where the column
+// equals a constant, which an index would have found directly. Statement
+// creates the index.
+type IndexAdvice struct {
+	Table     string
+	Column    string
+	Scans     int64
+	RowsRead  int64
+	Statement string
+}
+
+type fullScanKey struct {
+	table, column string
+}
+
+type fullScans struct {
+	mu     sync.Mutex
+	counts map[fullScanKey]*IndexAdvice
+}
+
+// RecordFullScan notes that a query read all rows of table to find those
+// where columns equal constants.
+func (db *Database) RecordFullScan(table string, columns []string, rows int) {
+	db.scans.mu.Lock()
+	defer db.scans.mu.Unlock()
+	if db.scans.counts == nil {
+		db.scans.counts = make(map[fullScanKey]*IndexAdvice)
+	}
+	for _, column := range columns {
+		key := fullScanKey{table, column}
+		advice, ok := db.scans.counts[key]
+		if !ok {
+			advice = &IndexAdvice{Table: table, Column: column}
+			db.scans.counts[key] = advice
+		}
+		advice.Scans++
+		advice.RowsRead += int64(rows)
+	}
+}
+
+// IndexAdvice returns the columns filtered on by at least AdviceMinScans
+// full scans that have no CREATE INDEX index of their values, the most
+// rows read first.
+func (db *Database) IndexAdvice() []IndexAdvice {
+	db.scans.mu.Lock()
+	var candidates []IndexAdvice
+	for _, advice := range db.scans.counts {
+		if advice.Scans >= AdviceMinScans {
+			candidates = append(candidates, *advice)
+		}
+	}
+	db.scans.mu.Unlock()
+
+	var advice []IndexAdvice
+	for _, a := range candidates {
+		t, err := db.GetTable(a.Table)
+		if err != nil || t.Temporary || hasValueIndex(t, a.Column) {
+			continue
+		}
+		a.Statement = fmt.Sprintf("CREATE INDEX %s_%s_idx ON %s (%s)", a.Table, a.Column, a.Table, a.Column)
+		advice = append(advice, a)
+	}
+	sort.Slice(advice, func(i, j int) bool {
+		if advice[i].RowsRead != advice[j].RowsRead {
+			return advice[i].RowsRead > advice[j].RowsRead
+		}
+		if advice[i].Table != advice[j].Table {
+			return advice[i].Table < advice[j].Table
+		}
+		return advice[i].Column < advice[j].Column
+	})
+	return advice
+}
+
+// hasValueIndex reports whether column has a secondary index of its values
+// that holds every row.
+func hasValueIndex(t *Table, column string) bool {
+	for _, idx := range t.SecondaryIndexes() {
+		if idx.Column == column && idx.Kind() == "" && !idx.Partial() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntervalValue is a span of time in months, days and a duration, kept
+// apart as in PostgreSQL because months and days vary in length: one
+// month added to January 31 gives the last day of February, and one day
+// is not always 24 hours across a daylight saving change.
+type IntervalValue struct {
+	Months   int64
+	Days     int64
+	Duration time.Duration
+}
+
+func NewIntervalValue(months, days int64, d time.Duration) *IntervalValue {
+	return &IntervalValue{Months: months, Days: days, Duration: d}
+}
+
+func (iv *IntervalValue) Type() DataType { return TypeInterval }
+
+// ToString formats the interval as PostgreSQL does, e.g. "1 year 2 mons 3
+// days 04:05:06"; ParseInterval reads it back.
+func (iv *IntervalValue) ToString() string {
+	var parts []string
+	unit := func(n int64, singular, plural string) {
+		switch {
+		case n == 1 || n == -1:
+			parts = append(parts, fmt.Sprintf("%d %s", n, singular))
+		case n != 0:
+			parts = append(parts, fmt.Sprintf("%d %s", n, plural))
+		}
+	}
+	unit(iv.Months/12, "year", "years")
+	unit(iv.Months%12, "mon", "mons")
+	unit(iv.Days, "day", "days")
+	if iv.Duration != 0 || len(parts) == 0 {
+		d, sign := iv.Duration, ""
+		if d < 0 {
+			d, sign = -d, "-"
+		}
+		clock := fmt.Sprintf("%s%02d:%02d:%02d", sign, int64(d/time.Hour), int64(d%time.Hour/time.Minute), int64(d%time.Minute/time.Second))
+		if frac := d % time.Second; frac != 0 {
+			clock += strings.TrimRight(fmt.Sprintf(".%09d", int64(frac)), "0")
+		}
+		parts = append(parts, clock)
+	}
+	return strings.Join(parts, " ")
+}
+
+// approximate is the interval's length taking a month as 30 days and a
+// day as 24 hours, by which PostgreSQL compares intervals.
+func (iv *IntervalValue) approximate() time.Duration {
+	return time.Duration(iv.Months*30+iv.Days)*24*time.Hour + iv.Duration
+}
+
+func (iv *IntervalValue) Equals(other Value) bool {
+	if o, ok := other.(*IntervalValue); ok {
+		return iv.approximate() == o.approximate()
+	}
+	return false
+}
+func (iv *IntervalValue) LessThan(other Value) bool {
+	if o, ok := other.(*IntervalValue); ok {
+		return iv.approximate() < o.approximate()
+	}
+	return false
+}
+func (iv *IntervalValue) Clone() Value {
+	return &IntervalValue{Months: iv.Months, Days: iv.Days, Duration: iv.Duration}
+}
+
+// Add returns the sum of two intervals.
+func (iv *IntervalValue) Add(other *IntervalValue) *IntervalValue {
+	return NewIntervalValue(iv.Months+other.Months, iv.Days+other.Days, iv.Duration+other.Duration)
+}
+
+// Multiply returns the interval n times over.
+func (iv *IntervalValue) Multiply(n int64) *IntervalValue {
+	return NewIntervalValue(iv.Months*n, iv.Days*n, iv.Duration*time.Duration(n))
+}
+
+// AddTo returns t moved by the interval: months first, then days, then
+// the duration.
+func (iv *IntervalValue) AddTo(t time.Time) time.Time {
+	if iv.Months != 0 {
+		// Like PostgreSQL, clamp to the end of a shorter month rather
+		// than overflow into the next one.
+		first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		first = first.AddDate(0, int(iv.Months), 0)
+		day := t.Day()
+		if last := first.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+		t = first.AddDate(0, 0, day-1)
+	}
+	return t.AddDate(0, 0, int(iv.Days)).Add(iv.Duration)
+}
+
+var intervalUnits = map[string]func(n int64) *IntervalValue{
+	"microsecond": func(n int64) *IntervalValue { return NewIntervalValue(0, 0, time.Duration(n)*time.Microsecond) },
+	"millisecond": func(n int64) *IntervalValue { return NewIntervalValue(0, 0, time.Duration(n)*time.Millisecond) },
+	"second":      func(n int64) *IntervalValue { return NewIntervalValue(0, 0, time.Duration(n)*time.Second) },
+	"sec":         func(n int64) *IntervalValue { return NewIntervalValue(0, 0, time.Duration(n)*time.Second) },
+	"minute":      func(n int64) *IntervalValue { return NewIntervalValue(0, 0, time.Duration(n)*time.Minute) },
+	"min":         func(n int64) *IntervalValue { return NewIntervalValue(0, 0, time.Duration(n)*time.Minute) },
+	"hour":        func(n int64) *IntervalValue { return NewIntervalValue(0, 0, time.Duration(n)*time.Hour) },
+	"day":         func(n int64) *IntervalValue { return NewIntervalValue(0, n, 0) },
+	"week":        func(n int64) *IntervalValue { return NewIntervalValue(0, 7*n, 0) },
+	"month":       func(n int64) *IntervalValue { return NewIntervalValue(n, 0, 0) },
+	"mon":         func(n int64) *IntervalValue { return NewIntervalValue(n, 0, 0) },
+	"year":        func(n int64) *IntervalValue { return NewIntervalValue(12*n, 0, 0) },
+}
+
+// ParseInterval reads an interval written as quantities and units, such
+// as "1 week", "2 hours 30 minutes" or "-3 days", optionally ending in a
+// clock time such as "04:05:06", as ToString writes it. Units may be
+// plural and are not case-sensitive.
+func ParseInterval(s string) (*IntervalValue, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid interval: %q", s)
+	}
+	result := NewIntervalValue(0, 0, 0)
+	for i := 0; i < len(fields); i++ {
+		if strings.Contains(fields[i], ":") {
+			d, err := parseClock(fields[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid interval: %q", s)
+			}
+			result.Duration += d
+			continue
+		}
+		n, err := strconv.ParseInt(fields[i], 10, 64)
+		if err != nil || i+1 == len(fields) {
+			return nil, fmt.Errorf("invalid interval: %q; write a number and a unit, as in '1 week'", s)
+		}
+		i++
+		unit, ok := intervalUnits[strings.TrimSuffix(fields[i], "s")]
+		if !ok {
+			return nil, fmt.Errorf("invalid interval unit %q in %q", fields[i], s)
+		}
+		result = result.Add(unit(n))
+	}
+	return result, nil
+}
+
+// parseClock reads [-]hh:mm[:ss[.fraction]].
+func parseClock(s string) (time.Duration, error) {
+	negative := strings.HasPrefix(s, "-")
+	parts := strings.Split(strings.TrimPrefix(s, "-"), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, part := range parts {
+		if i == 2 {
+			secs, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return 0, err
+			}
+			d += time.Duration(secs * float64(time.Second))
+			continue
+		}
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * units[i]
+	}
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
@@ -0,0 +1,49 @@
+package storage
+
+import "log/slog"
+
+// SetLogger sets the logger used by the database and by executors running
+// against it. A nil logger restores slog.Default().
+func (db *Database) SetLogger(logger *slog.Logger) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.logger = logger
+}
+
+func (db *Database) Logger() *slog.Logger {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.logger == nil {
+		return slog.Default()
+	}
+	return db.logger
+}
+
+// SetStatementLogging turns on a log line for every executed statement,
+// with its duration and row counts.
+func (db *Database) SetStatementLogging(enabled bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.logStatements = enabled
+}
+
+func (db *Database) StatementLogging() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.logStatements
+}
+
+// SetQueryTracing sets whether new sessions start with trace mode on, logging
+// each step of every statement they execute.
+func (db *Database) SetQueryTracing(enabled bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.traceQueries = enabled
+}
+
+func (db *Database) QueryTracing() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.traceQueries
+}
@@ -0,0 +1,101 @@
+package storage
+
+// GetByPK returns a copy of the row whose primary key is key, found
+// through the primary key's index rather than by reading every row. It
+// reports false if there is no such row or the primary key is not a
+// single column.
+func (t *Table) GetByPK(key Value) (*Row, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	pos, ok := t.pkPosition(key)
+	if !ok {
+		return nil, false
+	}
+	return t.Rows[pos].Clone(), true
+}
+
+// FindRow returns a copy of the row of table whose primary key is key, as
+// Table.GetByPK finds it.
+func (db *Database) FindRow(table string, key Value) (*Row, error) {
+	t, err := db.GetTable(table)
+	if err != nil {
+		return nil, err
+	}
+	row, ok := t.GetByPK(key)
+	if !ok {
+		return nil, errorf(ErrRowNotFound, "no row of table %s has primary key %s", table, key.ToString())
+	}
+	return row, nil
+}
+
+// pkPosition returns the position of the row whose primary key is key.
+// Callers must hold t.mu.
+func (t *Table) pkPosition(key Value) (int, bool) {
+	pk := t.Schema().PrimaryKeyColumns()
+	if len(pk) != 1 {
+		return -1, false
+	}
+	return t.position(pk[0].Name, key)
+}
+
+// position returns the position of a row whose value in column is key,
+// looked up in the column's index if it has one. NULL is never found.
+// Callers must hold t.mu.
+func (t *Table) position(column string, key Value) (int, bool) {
+	i := t.Schema().ColumnIndex(column)
+	if i < 0 || key == nil || key.Type() == TypeNull {
+		return -1, false
+	}
+	holds := func(pos int) bool {
+		if pos < 0 || pos >= len(t.Rows) {
+			return false
+		}
+		v, err := t.Rows[pos].Get(i)
+		return err == nil && v.Type() == key.Type() && v.Equals(key)
+	}
+	if index, ok := t.Indexes[column]; ok {
+		t.countScan(usageKey{name: column})
+		positions, _ := index.Lookup(key)
+		for _, pos := range positions {
+			if holds(pos) {
+				return pos, true
+			}
+		}
+		return -1, false
+	}
+	for pos := range t.Rows {
+		if holds(pos) {
+			return pos, true
+		}
+	}
+	return -1, false
+}
+
+// keyChecker returns a function reporting whether the table has a row
+// holding the values at positions of row in columns. A single indexed
+// column is looked up in its index on each call; otherwise the table's
+// keys are read once, now.
+func (t *Table) keyChecker(columns []string) func(row *Row, positions []int) bool {
+	t.mu.RLock()
+	_, indexed := t.Indexes[columns[0]]
+	t.mu.RUnlock()
+
+	if len(columns) == 1 && indexed {
+		return func(row *Row, positions []int) bool {
+			v, err := row.Get(positions[0])
+			if err != nil {
+				return false
+			}
+			t.mu.RLock()
+			defer t.mu.RUnlock()
+			_, found := t.position(columns[0], v)
+			return found
+		}
+	}
+	keys := t.keySet(columns)
+	return func(row *Row, positions []int) bool {
+		key, ok := constraintKey(row, positions)
+		return ok && keys[key]
+	}
+}
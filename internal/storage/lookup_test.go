@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetByPK(t *testing.T) {
+	db := NewDatabase()
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	schema.AddColumn(NewColumn("name", TypeText, false, false, false))
+	if err := db.CreateTable("users", schema); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("users")
+	for i, name := range []string{"ada", "bob", "cy"} {
+		if _, err := table.Insert(NewRow([]Value{NewIntegerValue(int64(i + 1)), NewTextValue(name)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := table.Delete(func(row *Row) bool { return row.Values[0].Equals(NewIntegerValue(1)) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rows after the deleted one moved; the index follows them.
+	row, ok := table.GetByPK(NewIntegerValue(3))
+	if !ok || row.Values[1].ToString() != "cy" {
+		t.Fatalf("GetByPK(3) = %v, %v", row, ok)
+	}
+	for _, key := range []Value{NewIntegerValue(1), NewTextValue("2"), NullValue{}} {
+		if row, ok := table.GetByPK(key); ok {
+			t.Errorf("GetByPK(%s) = %v", key.ToString(), row)
+		}
+	}
+
+	row, err := db.FindRow("users", NewIntegerValue(2))
+	if err != nil || row.Values[1].ToString() != "bob" {
+		t.Fatalf("FindRow(2) = %v, %v", row, err)
+	}
+	if _, err := db.FindRow("users", NewIntegerValue(9)); !errors.Is(err, ErrRowNotFound) {
+		t.Errorf("FindRow(9) error = %v, want row not found", err)
+	}
+	if _, err := db.FindRow("missing", NewIntegerValue(1)); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("FindRow on a missing table error = %v, want table not found", err)
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"time"
+)
+
+// TableMetrics describe the size of a table. Bytes is an estimate of the
+// memory its values take: 8 bytes per number, 1 per boolean, the length of
+// each text and 16 per row for the row itself. Indexes are not counted.
+type TableMetrics struct {
+	Table      string
+	Rows       int
+	Bytes      int64
+	Indexes    int       // the primary key and UNIQUE B-trees and CREATE INDEX indexes
+	IndexDepth int       // levels in the deepest B-tree; 0 without one
+	ModifiedAt time.Time // of the last write; zero if never written
+	CreatedAt  time.Time
+}
+
+// DatabaseMetrics are the metrics of every table, in creation order, and
+// their totals.
+type DatabaseMetrics struct {
+	Tables     []*TableMetrics
+	Rows       int
+	Bytes      int64
+	Indexes    int
+	ModifiedAt time.Time // the latest of the tables'
+}
+
+const rowOverhead = 16
+
+// Stats returns the current metrics of the database's tables. Each table
+// is measured by reading all of its rows.
+func (db *Database) Stats() *DatabaseMetrics {
+	db.mu.RLock()
+	tables := db.orderedTables()
+	db.mu.RUnlock()
+
+	stats := &DatabaseMetrics{}
+	for _, table := range tables {
+		m := table.Metrics()
+		stats.Tables = append(stats.Tables, m)
+		stats.Rows += m.Rows
+		stats.Bytes += m.Bytes
+		stats.Indexes += m.Indexes
+		if m.ModifiedAt.After(stats.ModifiedAt) {
+			stats.ModifiedAt = m.ModifiedAt
+		}
+	}
+	return stats
+}
+
+// Metrics measures the table.
+func (t *Table) Metrics() *TableMetrics {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	m := &TableMetrics{
+		Table:      t.Name,
+		Rows:       len(t.Rows),
+		Indexes:    len(t.Indexes) + len(t.secondary),
+		ModifiedAt: t.modified,
+		CreatedAt:  t.created,
+	}
+	for _, row := range t.Rows {
+		m.Bytes += rowOverhead
+		for _, v := range row.Values {
+			m.Bytes += valueBytes(v)
+		}
+	}
+	for _, index := range t.Indexes {
+		if bt, ok := index.(*BTree); ok && bt.Depth() > m.IndexDepth {
+			m.IndexDepth = bt.Depth()
+		}
+	}
+	return m
+}
+
+func valueBytes(v Value) int64 {
+	switch v := v.(type) {
+	case *IntegerValue, *FloatValue:
+		return 8
+	case *BooleanValue:
+		return 1
+	case *IntervalValue:
+		return 24
+	case *TextValue:
+		return int64(len(v.Value))
+	}
+	return 0
+}
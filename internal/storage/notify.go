@@ -0,0 +1,71 @@
+package storage
+
+const listenerBufferSize = 64
+
+type Notification struct {
+	Channel string
+	Payload string
+}
+
+// Listener receives notifications for the channels it listens on. Delivery
+// never blocks the notifier: if C is full the notification is dropped.
+type Listener struct {
+	C        chan Notification
+	db       *Database
+	channels map[string]bool
+}
+
+func (db *Database) NewListener() *Listener {
+	l := &Listener{
+		C:        make(chan Notification, listenerBufferSize),
+		db:       db,
+		channels: make(map[string]bool),
+	}
+
+	db.notifyMu.Lock()
+	db.listeners[l] = true
+	db.notifyMu.Unlock()
+
+	return l
+}
+
+func (l *Listener) Listen(channel string) {
+	l.db.notifyMu.Lock()
+	defer l.db.notifyMu.Unlock()
+	l.channels[channel] = true
+}
+
+func (l *Listener) Unlisten(channel string) {
+	l.db.notifyMu.Lock()
+	defer l.db.notifyMu.Unlock()
+	delete(l.channels, channel)
+}
+
+func (l *Listener) UnlistenAll() {
+	l.db.notifyMu.Lock()
+	defer l.db.notifyMu.Unlock()
+	l.channels = make(map[string]bool)
+}
+
+func (l *Listener) Close() {
+	l.db.notifyMu.Lock()
+	defer l.db.notifyMu.Unlock()
+	delete(l.db.listeners, l)
+	l.channels = make(map[string]bool)
+}
+
+func (db *Database) Notify(channel, payload string) {
+	db.notifyMu.Lock()
+	defer db.notifyMu.Unlock()
+
+	n := Notification{Channel: channel, Payload: payload}
+	for l := range db.listeners {
+		if !l.channels[channel] {
+			continue
+		}
+		select {
+		case l.C <- n:
+		default:
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Two-phase commit lets the database take part in a transaction spanning
+// several systems. The coordinator has each participant prepare its part:
+// Tx.Prepare checks the deferred constraints, the last thing that could
+// make the commit fail, and sets the transaction aside under a global
+// identifier (GID) instead of ending it. Once every participant has
+// prepared, the coordinator commits each one, from any connection, with
+// CommitPrepared, or rolls them all back with RollbackPrepared.
+//
+// A prepared transaction keeps its writes in the tables, as an open one
+// does, until it is finished. With a WAL file open it is saved next to it
+// (path + ".prepared") and prepared again when the file is replayed, so it
+// survives a restart. The WAL entry committing it carries its GID, so a
+// commit that reached the file is not prepared a second time.
+
+// PreparedTx is a transaction prepared for two-phase commit: its GID, who
+// prepared it and when, and the changes it commits.
+type PreparedTx struct {
+	GID      string      `json:"gid"`
+	Prepared time.Time   `json:"prepared"`
+	Owner    string      `json:"owner,omitempty"`
+	Changes  []WALChange `json:"changes"`
+}
+
+// Prepare checks the transaction's deferred constraints and sets it aside
+// under gid until CommitPrepared or RollbackPrepared finishes it. owner
+// is recorded for PreparedTransactions. If it fails, the transaction is
+// rolled back. Transactions that wrote to temporary tables or changed a
+// column's type cannot be prepared.
+func (tx *Tx) Prepare(gid, owner string) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	abort := func(err error) error {
+		tx.RollbackTo(0)
+		tx.done = true
+		return fmt.Errorf("%w (transaction rolled back)", err)
+	}
+
+	if gid == "" {
+		return abort(errors.New("transaction identifier is required"))
+	}
+	if len(tx.onCommit) > 0 {
+		return abort(errors.New("cannot prepare a transaction that has written to temporary tables"))
+	}
+	var changes []WALChange
+	for _, entry := range tx.undo {
+		switch {
+		case entry.db != nil || entry.table != nil && entry.table.Temporary:
+			return abort(errors.New("cannot prepare a transaction that has written to temporary tables"))
+		case entry.altered != nil:
+			return abort(errors.New("cannot prepare a transaction that has changed a column's type"))
+		}
+		changes = append(changes, entry.wal...)
+	}
+	if tx.pending {
+		if err := tx.checkDeferred(); err != nil {
+			return abort(err)
+		}
+		tx.pending = false
+	}
+
+	w := tx.db.wal
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, exists := w.prepared[gid]; exists {
+		return abort(errorf(ErrPreparedTxExists, "transaction identifier %s is already in use", gid))
+	}
+	if w.prepared == nil {
+		w.prepared = make(map[string]*Tx)
+	}
+	tx.gid = gid
+	tx.prepared = &PreparedTx{GID: gid, Prepared: time.Now(), Owner: owner, Changes: changes}
+	w.prepared[gid] = tx
+	if err := w.savePrepared(); err != nil {
+		delete(w.prepared, gid)
+		tx.gid, tx.prepared = "", nil
+		return abort(err)
+	}
+	return nil
+}
+
+// CommitPrepared commits the transaction prepared as gid, as Commit does.
+func (db *Database) CommitPrepared(gid string) error {
+	tx, err := db.takePrepared(gid)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if saveErr := db.forgetPrepared(); err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// RollbackPrepared rolls back the transaction prepared as gid.
+func (db *Database) RollbackPrepared(gid string) error {
+	tx, err := db.takePrepared(gid)
+	if err != nil {
+		return err
+	}
+	err = tx.Rollback()
+	if saveErr := db.forgetPrepared(); err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// PreparedTransactions returns the transactions waiting to be committed
+// or rolled back, oldest first.
+func (db *Database) PreparedTransactions() []PreparedTx {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+
+	out := make([]PreparedTx, 0, len(db.wal.prepared))
+	for _, tx := range db.wal.prepared {
+		out = append(out, *tx.prepared)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Prepared.Before(out[j].Prepared) })
+	return out
+}
+
+// takePrepared removes the transaction prepared as gid, so that only one
+// caller finishes it, and makes it usable again.
+func (db *Database) takePrepared(gid string) (*Tx, error) {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+
+	tx, exists := db.wal.prepared[gid]
+	if !exists {
+		return nil, errorf(ErrPreparedTxNotFound, "prepared transaction %s does not exist", gid)
+	}
+	delete(db.wal.prepared, gid)
+	tx.prepared = nil
+	return tx, nil
+}
+
+// forgetPrepared saves the prepared transactions after one is finished.
+func (db *Database) forgetPrepared() error {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+	return db.wal.savePrepared()
+}
+
+// savePrepared writes the prepared transactions next to the WAL file, if
+// one is open, removing the file when there are none. Callers must hold
+// w.mu.
+func (w *WAL) savePrepared() error {
+	if w.file == nil {
+		return nil
+	}
+	path := w.file.path + ".prepared"
+	if len(w.prepared) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to save prepared transactions: %w", err)
+		}
+		return nil
+	}
+	saved := make([]*PreparedTx, 0, len(w.prepared))
+	for _, tx := range w.prepared {
+		saved = append(saved, tx.prepared)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].GID < saved[j].GID })
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to save prepared transactions: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save prepared transactions: %w", err)
+	}
+	return nil
+}
+
+// restorePrepared prepares again the transactions saved next to the WAL
+// file at path, but for those whose commit is in the file (committed).
+func (db *Database) restorePrepared(path string, committed map[string]bool) (map[string]*Tx, error) {
+	data, err := os.ReadFile(path + ".prepared")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prepared transactions: %w", err)
+	}
+	var saved []PreparedTx
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to load prepared transactions: %w", err)
+	}
+
+	restored := make(map[string]*Tx, len(saved))
+	for i := range saved {
+		p := &saved[i]
+		if committed[p.GID] {
+			continue
+		}
+		tx := db.Begin()
+		tx.deferred = true // as checked when it was prepared
+		for _, change := range p.Changes {
+			if err := tx.redo(change); err != nil {
+				tx.Rollback()
+				for _, other := range restored {
+					other.prepared = nil
+					other.Rollback()
+				}
+				return nil, fmt.Errorf("failed to restore prepared transaction %s: %w", p.GID, err)
+			}
+		}
+		tx.deferred, tx.pending = false, false
+		tx.gid, tx.prepared = p.GID, p
+		restored[p.GID] = tx
+	}
+	return restored, nil
+}
+
+// redo makes change again in the transaction.
+func (tx *Tx) redo(change WALChange) error {
+	switch change.Op {
+	case WALCreateTable:
+		return tx.CreateTable(change.Table, change.Schema, change.ForeignKeys...)
+	case WALDropTable:
+		return tx.DropTable(change.Table)
+	case WALCreateIndex:
+		idx, matches, err := tx.db.loggedIndex(change.Index)
+		if err != nil {
+			return err
+		}
+		return tx.CreateIndex(idx, matches)
+	case WALDropIndex:
+		return tx.DropIndex(change.Index.Name)
+	}
+
+	table, err := tx.db.GetTable(change.Table)
+	if err != nil {
+		return err
+	}
+	switch change.Op {
+	case WALInsert:
+		_, err := tx.InsertRows(table, []*Row{NewRow(cloneValues(change.After))})
+		return err
+	case WALUpdate, WALDelete:
+		table.mu.RLock()
+		row := table.findRow(change.Before)
+		table.mu.RUnlock()
+		if row == nil {
+			return fmt.Errorf("row to %s not found in table %s", change.Op, change.Table)
+		}
+		match := func(r *Row) bool { return r == row }
+		if change.Op == WALDelete {
+			_, err = tx.Delete(table, match)
+			return err
+		}
+		_, err = tx.Update(table, match, func(r *Row) error {
+			r.Values = cloneValues(change.After)
+			return nil
+		})
+		return err
+	default:
+		return fmt.Errorf("cannot redo WAL operation %q", change.Op)
+	}
+}
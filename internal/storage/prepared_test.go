@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreparedTransactionRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rdbms.wal")
+	db := NewDatabase()
+	if _, err := db.OpenWALFile(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	schema.AddColumn(NewColumn("balance", TypeInteger, false, false, false))
+	tx := db.Begin()
+	if err := tx.CreateTable("accounts", schema); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("accounts")
+	for id := int64(1); id <= 2; id++ {
+		if _, err := tx.Insert(table, NewRow([]Value{NewIntegerValue(id), NewIntegerValue(100)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	prepare := func(db *Database, gid string, id int64) {
+		table, _ := db.GetTable("accounts")
+		tx := db.Begin()
+		if _, err := tx.Update(table, func(r *Row) bool { v, _ := r.Get(0); return v.Equals(NewIntegerValue(id)) }, func(r *Row) error {
+			r.Values[1] = NewIntegerValue(0)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Prepare(gid, "app"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.Insert(table, NewRow([]Value{NewIntegerValue(9), NullValue{}})); err == nil {
+			t.Errorf("%s: writing to a prepared transaction succeeded", gid)
+		}
+	}
+	balances := func(db *Database) string {
+		table, _ := db.GetTable("accounts")
+		var out string
+		for _, row := range table.Select(nil) {
+			out += row.String()
+		}
+		return out
+	}
+
+	prepare(db, "pending", 1)
+	prepare(db, "done", 2)
+	saved, err := os.ReadFile(path + ".prepared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CommitPrepared("done"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CloseWALFile(); err != nil {
+		t.Fatal(err)
+	}
+	// As if the server stopped after committing "done" but before the
+	// file of prepared transactions was rewritten: the commit in the WAL
+	// file wins.
+	if err := os.WriteFile(path+".prepared", saved, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewDatabase()
+	if _, err := restarted.OpenWALFile(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.CloseWALFile()
+	if got := restarted.PreparedTransactions(); len(got) != 1 || got[0].GID != "pending" || got[0].Owner != "app" {
+		t.Fatalf("prepared after restart = %+v, want only pending", got)
+	}
+	if got, want := balances(restarted), "(1, 0)(2, 0)"; got != want {
+		t.Errorf("rows after restart = %s, want %s", got, want)
+	}
+	if err := restarted.RollbackPrepared("pending"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := balances(restarted), "(1, 100)(2, 0)"; got != want {
+		t.Errorf("rows after rollback = %s, want %s", got, want)
+	}
+	if _, err := os.Stat(path + ".prepared"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("prepared file left behind: %v", err)
+	}
+	if err := restarted.CommitPrepared("pending"); !errors.Is(err, ErrPreparedTxNotFound) {
+		t.Errorf("second finish: err = %v, want ErrPreparedTxNotFound", err)
+	}
+}
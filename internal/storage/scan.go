@@ -0,0 +1,89 @@
+package storage
+
+// CompareOp is the comparison of a Predicate.
+type CompareOp int
+
+const (
+	OpEqual CompareOp = iota
+	OpNotEqual
+	OpLess
+	OpLessEqual
+	OpGreater
+	OpGreaterEqual
+)
+
+// Predicate compares a column, by index, with a constant of the column's
+// type. A NULL on either side never matches.
+type Predicate struct {
+	Column int
+	Op     CompareOp
+	Value  Value
+}
+
+// Matches reports whether row satisfies the predicate.
+func (p Predicate) Matches(row *Row) bool {
+	v, err := row.Get(p.Column)
+	if err != nil || v == nil || v.Type() == TypeNull || p.Value == nil || p.Value.Type() == TypeNull {
+		return false
+	}
+	switch p.Op {
+	case OpEqual:
+		return v.Equals(p.Value)
+	case OpNotEqual:
+		return !v.Equals(p.Value)
+	case OpLess:
+		return v.LessThan(p.Value)
+	case OpLessEqual:
+		return !p.Value.LessThan(v)
+	case OpGreater:
+		return p.Value.LessThan(v)
+	case OpGreaterEqual:
+		return !v.LessThan(p.Value)
+	}
+	return false
+}
+
+// ScanOptions choose the rows and columns ScanWith yields.
+type ScanOptions struct {
+	Where   []Predicate // rows must match all of them
+	Columns []int       // the columns to copy; nil copies all
+}
+
+// ScanWith calls fn with each row matching opts.Where, in insertion order,
+// until fn returns false. Rows are tested in place and only the matching
+// ones are copied, each holding just opts.Columns, with every other column
+// NULL, so positions stay those of the schema. fn owns the rows it gets;
+// like Scan, it runs under the table's read lock and must not write to
+// the table.
+func (t *Table) ScanWith(opts ScanOptions, fn func(*Row) bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	columns := opts.Columns
+	if columns == nil {
+		columns = make([]int, len(t.Schema().Columns))
+		for i := range columns {
+			columns[i] = i
+		}
+	}
+rows:
+	for _, row := range t.Rows {
+		for _, p := range opts.Where {
+			if !p.Matches(row) {
+				continue rows
+			}
+		}
+		values := make([]Value, len(t.Schema().Columns))
+		for i := range values {
+			values[i] = NullValue{}
+		}
+		for _, col := range columns {
+			if col >= 0 && col < len(row.Values) {
+				values[col] = row.Values[col].Clone()
+			}
+		}
+		if !fn(NewRow(values)) {
+			return
+		}
+	}
+}
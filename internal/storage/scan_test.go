@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestScanWith(t *testing.T) {
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	schema.AddColumn(NewColumn("status", TypeText, false, false, false))
+	schema.AddColumn(NewColumn("points", TypeInteger, false, false, false))
+	table := NewTable("tasks", schema)
+	for _, row := range [][]Value{
+		{NewIntegerValue(1), NewTextValue("open"), NewIntegerValue(3)},
+		{NewIntegerValue(2), NewTextValue("done"), NewIntegerValue(5)},
+		{NewIntegerValue(3), NewTextValue("open"), NullValue{}},
+		{NewIntegerValue(4), NewTextValue("open"), NewIntegerValue(8)},
+	} {
+		if _, err := table.Insert(NewRow(row)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	table.ScanWith(ScanOptions{
+		Where: []Predicate{
+			{Column: 1, Op: OpEqual, Value: NewTextValue("open")},
+			{Column: 2, Op: OpGreaterEqual, Value: NewIntegerValue(3)},
+		},
+		Columns: []int{0},
+	}, func(row *Row) bool {
+		got = append(got, row.String())
+		return true
+	})
+	// Row 3 has NULL points, which match no comparison; only id is copied.
+	want := []string{"(1, NULL, NULL)", "(4, NULL, NULL)"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+
+	// The rows belong to the caller.
+	table.ScanWith(ScanOptions{}, func(row *Row) bool {
+		row.Values[1] = NewTextValue("changed")
+		return false
+	})
+	if status, _ := table.Rows[0].Get(1); status.ToString() != "open" {
+		t.Errorf("ScanWith handed out a stored row: status = %s", status.ToString())
+	}
+}
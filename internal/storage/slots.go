@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// A replication slot remembers how far a consumer has read the WAL, so it
+// can disconnect and later pull only the changes committed since: an
+// incremental backup, or a downstream copy kept in sync. The WAL keeps the
+// entries a slot has not confirmed whatever the history retention, and
+// with no retention set discards those every slot has confirmed, so a
+// slot left behind holds on to them until it is dropped. With a WAL file
+// open, slots are saved next to it (path + ".slots") and survive restarts.
+
+// ReplicationSlot is a named position in the WAL: the consumer has
+// applied every entry up to ConfirmedLSN.
+type ReplicationSlot struct {
+	Name         string    `json:"name"`
+	ConfirmedLSN uint64    `json:"confirmed_lsn"`
+	Created      time.Time `json:"created"`
+}
+
+// CreateReplicationSlot adds a slot positioned at the last committed LSN,
+// so its first pull returns the changes committed after the call.
+func (db *Database) CreateReplicationSlot(name string) (ReplicationSlot, error) {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+
+	if name == "" {
+		return ReplicationSlot{}, errors.New("replication slot name is required")
+	}
+	if _, exists := db.wal.slots[name]; exists {
+		return ReplicationSlot{}, errorf(ErrSlotExists, "replication slot %s already exists", name)
+	}
+	slot := &ReplicationSlot{Name: name, ConfirmedLSN: db.wal.last, Created: time.Now()}
+	if db.wal.slots == nil {
+		db.wal.slots = make(map[string]*ReplicationSlot)
+	}
+	db.wal.slots[name] = slot
+	if err := db.wal.saveSlots(); err != nil {
+		delete(db.wal.slots, name)
+		return ReplicationSlot{}, err
+	}
+	return *slot, nil
+}
+
+// DropReplicationSlot removes a slot, releasing the entries it held.
+func (db *Database) DropReplicationSlot(name string) error {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+
+	slot, exists := db.wal.slots[name]
+	if !exists {
+		return errorf(ErrSlotNotFound, "replication slot %s not found", name)
+	}
+	delete(db.wal.slots, name)
+	if err := db.wal.saveSlots(); err != nil {
+		db.wal.slots[name] = slot
+		return err
+	}
+	db.wal.prune(time.Now())
+	return nil
+}
+
+// ReplicationSlots returns the slots sorted by name.
+func (db *Database) ReplicationSlots() []ReplicationSlot {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+
+	slots := make([]ReplicationSlot, 0, len(db.wal.slots))
+	for _, slot := range db.wal.slots {
+		slots = append(slots, *slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Name < slots[j].Name })
+	return slots
+}
+
+// SlotChanges returns up to limit WAL entries (all if limit is 0) after
+// the slot's confirmed LSN. It does not move the slot: the consumer calls
+// AdvanceReplicationSlot once it has applied them, and pulls them again if
+// it fails before that.
+func (db *Database) SlotChanges(name string, limit int) ([]WALEntry, error) {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+
+	slot, exists := db.wal.slots[name]
+	if !exists {
+		return nil, errorf(ErrSlotNotFound, "replication slot %s not found", name)
+	}
+	if slot.ConfirmedLSN < db.wal.oldest {
+		// Only a snapshot restore discards entries a slot holds.
+		return nil, errorf(ErrHistoryUnavailable, "WAL entries after LSN %d are no longer retained; drop replication slot %s and start again from a backup", slot.ConfirmedLSN, name)
+	}
+
+	tail := db.wal.entries[db.wal.after(slot.ConfirmedLSN):]
+	if limit > 0 && len(tail) > limit {
+		tail = tail[:limit]
+	}
+	if len(tail) == 0 {
+		return nil, nil
+	}
+	return append([]WALEntry(nil), tail...), nil
+}
+
+// AdvanceReplicationSlot confirms that the slot's consumer has applied the
+// entries up to lsn, which the next pull starts after. The WAL may then
+// discard them.
+func (db *Database) AdvanceReplicationSlot(name string, lsn uint64) error {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+
+	slot, exists := db.wal.slots[name]
+	if !exists {
+		return errorf(ErrSlotNotFound, "replication slot %s not found", name)
+	}
+	if lsn > db.wal.last {
+		return fmt.Errorf("cannot advance replication slot %s to LSN %d: the last LSN is %d", name, lsn, db.wal.last)
+	}
+	if lsn <= slot.ConfirmedLSN {
+		return nil // a repeated confirmation
+	}
+	previous := slot.ConfirmedLSN
+	slot.ConfirmedLSN = lsn
+	if err := db.wal.saveSlots(); err != nil {
+		slot.ConfirmedLSN = previous
+		return err
+	}
+	db.wal.prune(time.Now())
+	return nil
+}
+
+// heldLSN returns the lowest LSN a slot or a connected replica has
+// confirmed and whether there are any. Callers must hold w.mu.
+func (w *WAL) heldLSN() (uint64, bool) {
+	var lowest uint64
+	found := false
+	hold := func(lsn uint64) {
+		if !found || lsn < lowest {
+			lowest = lsn
+			found = true
+		}
+	}
+	for _, slot := range w.slots {
+		hold(slot.ConfirmedLSN)
+	}
+	for _, replica := range w.replicas {
+		hold(replica.confirmed)
+	}
+	return lowest, found
+}
+
+// A streaming replica holds the WAL as a slot does, but only while it is
+// connected: one that comes back after its entries were discarded starts
+// again from a snapshot. Replicas are named by their followers, which
+// confirm what they have applied with ConfirmReplica.
+
+type replicaHold struct {
+	confirmed uint64
+}
+
+// HoldReplica keeps the entries after lsn for the replica name until it
+// confirms more of them or release is called. A second hold under the
+// same name, from a replica that reconnected, replaces the first.
+func (w *WAL) HoldReplica(name string, lsn uint64) (release func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	hold := &replicaHold{confirmed: lsn}
+	if w.replicas == nil {
+		w.replicas = make(map[string]*replicaHold)
+	}
+	w.replicas[name] = hold
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.replicas[name] == hold {
+			delete(w.replicas, name)
+			w.prune(time.Now())
+		}
+	}
+}
+
+// ConfirmReplica records that the replica name has applied the entries
+// up to lsn, which the WAL may then discard.
+func (w *WAL) ConfirmReplica(name string, lsn uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	hold, exists := w.replicas[name]
+	if !exists {
+		return errorf(ErrSlotNotFound, "replica %s is not connected", name)
+	}
+	if lsn > w.last {
+		return fmt.Errorf("cannot confirm LSN %d for replica %s: the last LSN is %d", lsn, name, w.last)
+	}
+	if lsn > hold.confirmed {
+		hold.confirmed = lsn
+		w.prune(time.Now())
+	}
+	return nil
+}
+
+// Replicas returns the LSN each connected replica has confirmed, by name.
+func (w *WAL) Replicas() map[string]uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	replicas := make(map[string]uint64, len(w.replicas))
+	for name, hold := range w.replicas {
+		replicas[name] = hold.confirmed
+	}
+	return replicas
+}
+
+// saveSlots writes the slots next to the WAL file, if one is open.
+// Callers must hold w.mu.
+func (w *WAL) saveSlots() error {
+	if w.file == nil {
+		return nil
+	}
+	slots := make([]*ReplicationSlot, 0, len(w.slots))
+	for _, slot := range w.slots {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Name < slots[j].Name })
+	data, err := json.Marshal(slots)
+	if err != nil {
+		return err
+	}
+	path := w.file.path + ".slots"
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to save replication slots: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save replication slots: %w", err)
+	}
+	return nil
+}
+
+// loadSlots reads the slots saved next to the WAL file at path, if any.
+// Callers must hold w.mu.
+func (w *WAL) loadSlots(path string) error {
+	data, err := os.ReadFile(path + ".slots")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load replication slots: %w", err)
+	}
+	var slots []*ReplicationSlot
+	if err := json.Unmarshal(data, &slots); err != nil {
+		return fmt.Errorf("failed to load replication slots: %w", err)
+	}
+	w.slots = make(map[string]*ReplicationSlot, len(slots))
+	for _, slot := range slots {
+		w.slots[slot.Name] = slot
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplicationSlot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rdbms.wal")
+	db := NewDatabase()
+	if _, err := db.OpenWALFile(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	tx := db.Begin()
+	if err := tx.CreateTable("events", schema); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("events")
+	insert := func(id int64) {
+		tx := db.Begin()
+		if _, err := tx.Insert(table, NewRow([]Value{NewIntegerValue(id)})); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lsns := func(entries []WALEntry) []uint64 {
+		var out []uint64
+		for _, entry := range entries {
+			out = append(out, entry.LSN)
+		}
+		return out
+	}
+
+	if _, err := db.CreateReplicationSlot("backup"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateReplicationSlot("backup"); !errors.Is(err, ErrSlotExists) {
+		t.Errorf("second create: err = %v, want ErrSlotExists", err)
+	}
+
+	// Retention does not discard the entries the slot has not confirmed.
+	db.SetHistoryRetention(time.Millisecond)
+	for id := int64(1); id <= 3; id++ {
+		insert(id)
+	}
+	time.Sleep(5 * time.Millisecond)
+	insert(4)
+	entries, err := db.SlotChanges("backup", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lsns(entries); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Fatalf("first pull = %v, want [2 3]", got)
+	}
+
+	if err := db.AdvanceReplicationSlot("backup", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AdvanceReplicationSlot("backup", 9); err == nil {
+		t.Error("advancing past the last LSN succeeded")
+	}
+	if oldest := db.WAL().Oldest(); oldest != 3 {
+		t.Errorf("oldest after the ack = %d, want 3", oldest)
+	}
+	if err := db.CloseWALFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The slot's position survives a restart.
+	restarted := NewDatabase()
+	if _, err := restarted.OpenWALFile(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.CloseWALFile()
+	entries, err = restarted.SlotChanges("backup", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lsns(entries); len(got) != 2 || got[0] != 4 || got[1] != 5 {
+		t.Errorf("pull after restart = %v, want [4 5]", got)
+	}
+
+	if err := restarted.DropReplicationSlot("backup"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.SlotChanges("backup", 0); !errors.Is(err, ErrSlotNotFound) {
+		t.Errorf("pull after drop: err = %v, want ErrSlotNotFound", err)
+	}
+}
+
+// TestReplicaHold checks that, with no retention set, the WAL keeps what
+// a connected replica has not confirmed and discards the rest.
+func TestReplicaHold(t *testing.T) {
+	db := NewDatabase()
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	tx := db.Begin()
+	if err := tx.CreateTable("events", schema); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("events")
+	insert := func(id int64) {
+		tx := db.Begin()
+		if _, err := tx.Insert(table, NewRow([]Value{NewIntegerValue(id)})); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wal := db.WAL()
+
+	// Nothing reads the WAL yet, so it keeps everything.
+	for id := int64(1); id <= 3; id++ {
+		insert(id)
+	}
+	release := wal.HoldReplica("r1", 0)
+	insert(4)
+	if entries := wal.Since(0); len(entries) != 5 || wal.Oldest() != 0 {
+		t.Fatalf("before any confirmation: %d entries, oldest %d; want 5 and 0", len(entries), wal.Oldest())
+	}
+
+	if err := wal.ConfirmReplica("r1", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.ConfirmReplica("r1", 9); err == nil {
+		t.Error("confirming past the last LSN succeeded")
+	}
+	if err := wal.ConfirmReplica("r2", 3); !errors.Is(err, ErrSlotNotFound) {
+		t.Errorf("confirming for an unknown replica: err = %v, want ErrSlotNotFound", err)
+	}
+	if oldest := wal.Oldest(); oldest != 3 {
+		t.Errorf("oldest after the confirmation = %d, want 3", oldest)
+	}
+	if entries := wal.Since(3); len(entries) != 2 || entries[0].LSN != 4 || entries[1].LSN != 5 {
+		t.Errorf("Since(3) = %v, want LSNs 4 and 5", entries)
+	}
+	if entries := wal.Since(4); len(entries) != 1 || entries[0].LSN != 5 {
+		t.Errorf("Since(4) = %v, want LSN 5", entries)
+	}
+	if entries := wal.Since(5); entries != nil {
+		t.Errorf("Since(5) = %v, want none", entries)
+	}
+
+	// The lowest confirmation of the replicas and slots holds.
+	reconnected := wal.HoldReplica("r1", 4)
+	release() // of the replaced connection: no effect
+	if _, err := db.CreateReplicationSlot("feed"); err != nil {
+		t.Fatal(err)
+	}
+	insert(5)
+	if err := wal.ConfirmReplica("r1", 6); err != nil {
+		t.Fatal(err)
+	}
+	if oldest := wal.Oldest(); oldest != 5 {
+		t.Errorf("oldest with the slot at 5 = %d, want 5", oldest)
+	}
+	if got := wal.Replicas(); len(got) != 1 || got["r1"] != 6 {
+		t.Errorf("Replicas() = %v, want r1 at 6", got)
+	}
+	reconnected()
+	if got := wal.Replicas(); len(got) != 0 {
+		t.Errorf("Replicas() after release = %v, want none", got)
+	}
+}
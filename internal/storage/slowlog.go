@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const slowQueryBufferSize = 128
+
+// SlowQuery is one statement that ran longer than the slow query threshold.
+type SlowQuery struct {
+	ID           int           `json:"id"`
+	StartedAt    time.Time     `json:"started_at"`
+	Duration     time.Duration `json:"duration_ns"`
+	Operation    string        `json:"operation"`
+	Statement    string        `json:"statement"`
+	RowsAffected int           `json:"rows_affected"`
+	RowsReturned int           `json:"rows_returned"`
+	Plan         string        `json:"plan"`
+}
+
+// slowQueryLog keeps the most recent slow queries in a fixed-size ring and
+// optionally appends each one to a file as a JSON line.
+type slowQueryLog struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	entries   []SlowQuery
+	next      int
+	seq       int
+	file      *os.File
+}
+
+func newSlowQueryLog() *slowQueryLog {
+	return &slowQueryLog{entries: make([]SlowQuery, 0, slowQueryBufferSize)}
+}
+
+// SetSlowQueryThreshold enables the slow query log for statements taking at
+// least d. Zero disables it.
+func (db *Database) SetSlowQueryThreshold(d time.Duration) {
+	db.slowLog.mu.Lock()
+	defer db.slowLog.mu.Unlock()
+	db.slowLog.threshold = d
+}
+
+func (db *Database) SlowQueryThreshold() time.Duration {
+	db.slowLog.mu.Lock()
+	defer db.slowLog.mu.Unlock()
+	return db.slowLog.threshold
+}
+
+// SetSlowQueryFile also appends slow queries to path. An empty path stops
+// writing to the previous file.
+func (db *Database) SetSlowQueryFile(path string) error {
+	db.slowLog.mu.Lock()
+	defer db.slowLog.mu.Unlock()
+
+	if db.slowLog.file != nil {
+		db.slowLog.file.Close()
+		db.slowLog.file = nil
+	}
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open slow query log: %w", err)
+	}
+	db.slowLog.file = f
+	return nil
+}
+
+// RecordSlowQuery stores q if the slow query log is enabled and q ran for
+// at least the threshold. It reports whether q was recorded.
+func (db *Database) RecordSlowQuery(q SlowQuery) bool {
+	l := db.slowLog
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.threshold <= 0 || q.Duration < l.threshold {
+		return false
+	}
+
+	l.seq++
+	q.ID = l.seq
+	if len(l.entries) < slowQueryBufferSize {
+		l.entries = append(l.entries, q)
+	} else {
+		l.entries[l.next] = q
+	}
+	l.next = (l.next + 1) % slowQueryBufferSize
+
+	if l.file != nil {
+		if line, err := json.Marshal(q); err == nil {
+			l.file.Write(append(line, '\n'))
+		}
+	}
+	return true
+}
+
+// SlowQueries returns the buffered slow queries, oldest first.
+func (db *Database) SlowQueries() []SlowQuery {
+	l := db.slowLog
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]SlowQuery, 0, len(l.entries))
+	if len(l.entries) < slowQueryBufferSize {
+		return append(out, l.entries...)
+	}
+	out = append(out, l.entries[l.next:]...)
+	return append(out, l.entries[:l.next]...)
+}
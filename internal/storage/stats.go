@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// HistogramBuckets is how many buckets Analyze divides a column's values
+// into.
+const HistogramBuckets = 10
+
+// TableStats are the statistics ANALYZE gathered for a table. They are a
+// snapshot: writes after Analyze do not update them.
+type TableStats struct {
+	Table      string
+	Rows       int
+	Columns    []*ColumnStats // in schema order
+	AnalyzedAt time.Time
+}
+
+// ColumnStats describe the values of one column. Bounds is an equi-depth
+// histogram: len(Bounds)-1 buckets, each holding about the same number of
+// the non-NULL values, with Bounds[0] the smallest value and the last the
+// largest. It is empty when every value is NULL.
+type ColumnStats struct {
+	Column   string
+	NullFrac float64 // fraction of rows where the column is NULL
+	Distinct int     // distinct non-NULL values
+	Bounds   []Value
+}
+
+// Column returns the statistics of the named column.
+func (s *TableStats) Column(name string) (*ColumnStats, bool) {
+	for _, col := range s.Columns {
+		if col.Column == name {
+			return col, true
+		}
+	}
+	return nil, false
+}
+
+// EqualFraction estimates the fraction of rows where the column equals a
+// value, assuming the non-NULL values are spread evenly over the distinct
+// ones.
+func (c *ColumnStats) EqualFraction() float64 {
+	if c.Distinct == 0 {
+		return 0
+	}
+	return (1 - c.NullFrac) / float64(c.Distinct)
+}
+
+// BelowFraction estimates the fraction of rows where the column is less
+// than v. Within the bucket holding v, numbers are assumed to be spread
+// evenly between the bucket's bounds and other values to sit at its
+// middle.
+func (c *ColumnStats) BelowFraction(v Value) float64 {
+	if len(c.Bounds) == 0 || !c.Bounds[0].LessThan(v) {
+		return 0
+	}
+	last := c.Bounds[len(c.Bounds)-1]
+	if last.LessThan(v) || len(c.Bounds) == 1 {
+		return 1 - c.NullFrac
+	}
+
+	buckets := len(c.Bounds) - 1
+	i := sort.Search(buckets, func(i int) bool { return v.LessThan(c.Bounds[i+1]) })
+	if i == buckets {
+		// v is the largest value.
+		i = buckets - 1
+	}
+	within := 0.5
+	lo, loOK := numeric(c.Bounds[i])
+	hi, hiOK := numeric(c.Bounds[i+1])
+	x, xOK := numeric(v)
+	if loOK && hiOK && xOK && hi > lo {
+		within = (x - lo) / (hi - lo)
+	}
+	return (float64(i) + within) / float64(buckets) * (1 - c.NullFrac)
+}
+
+func numeric(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case *IntegerValue:
+		return float64(v.Value), true
+	case *FloatValue:
+		return v.Value, true
+	}
+	return 0, false
+}
+
+// Analyze gathers statistics for the table name and keeps them for
+// TableStats, replacing any gathered before.
+func (db *Database) Analyze(name string) (*TableStats, error) {
+	table, err := db.GetTable(name)
+	if err != nil {
+		return nil, err
+	}
+
+	table.mu.RLock()
+	stats := &TableStats{Table: name, Rows: len(table.Rows), AnalyzedAt: time.Now()}
+	for i, col := range table.Schema().Columns {
+		values := make([]Value, 0, len(table.Rows))
+		for _, row := range table.Rows {
+			if v, err := row.Get(i); err == nil && v.Type() != TypeNull {
+				values = append(values, v)
+			}
+		}
+		stats.Columns = append(stats.Columns, columnStats(col.Name, values, len(table.Rows)))
+	}
+	table.mu.RUnlock()
+
+	db.statsMu.Lock()
+	defer db.statsMu.Unlock()
+	if db.stats == nil {
+		db.stats = make(map[string]*TableStats)
+	}
+	db.stats[name] = stats
+	return stats, nil
+}
+
+// columnStats describes values, the non-NULL values of a column in rows
+// rows.
+func columnStats(name string, values []Value, rows int) *ColumnStats {
+	stats := &ColumnStats{Column: name}
+	if rows > 0 {
+		stats.NullFrac = float64(rows-len(values)) / float64(rows)
+	}
+	if len(values) == 0 {
+		return stats
+	}
+
+	sort.SliceStable(values, func(i, j int) bool { return values[i].LessThan(values[j]) })
+	stats.Distinct = 1
+	for i := 1; i < len(values); i++ {
+		if !values[i].Equals(values[i-1]) {
+			stats.Distinct++
+		}
+	}
+
+	buckets := HistogramBuckets
+	if len(values)-1 < buckets {
+		buckets = len(values) - 1
+	}
+	stats.Bounds = []Value{values[0]}
+	for b := 1; b <= buckets; b++ {
+		stats.Bounds = append(stats.Bounds, values[b*(len(values)-1)/buckets])
+	}
+	return stats
+}
+
+// TableStats returns the statistics last gathered for the table name.
+func (db *Database) TableStats(name string) (*TableStats, bool) {
+	db.statsMu.Lock()
+	defer db.statsMu.Unlock()
+	stats, ok := db.stats[name]
+	return stats, ok
+}
+
+// AllTableStats returns the statistics gathered for every table, by table
+// name.
+func (db *Database) AllTableStats() []*TableStats {
+	db.statsMu.Lock()
+	defer db.statsMu.Unlock()
+	all := make([]*TableStats, 0, len(db.stats))
+	for _, stats := range db.stats {
+		all = append(all, stats)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Table < all[j].Table })
+	return all
+}
+
+func (db *Database) dropStats(name string) {
+	db.statsMu.Lock()
+	defer db.statsMu.Unlock()
+	delete(db.stats, name)
+}
@@ -2,7 +2,10 @@ package storage
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Row struct {
@@ -53,13 +56,29 @@ func (r *Row) String() string {
 }
 
 type Table struct {
-	Name        string
-	Schema      *Schema
+	Name string
+	// schema is read without mu: a statement loads it once, with Schema,
+	// and uses that for its whole run. AlterColumnType replaces it under
+	// mu, so a writer holding mu sees the table's current schema.
+	schema      atomic.Pointer[Schema]
 	Rows        []*Row
 	Indexes     map[string]Index
+	secondary   map[string]*SecondaryIndex // by name; see index.go
+	builds      map[string]*indexBuild     // by index name; see indexbuild.go
 	RowIDSeq    int
 	ForeignKeys []*ForeignKey
-	mu          sync.RWMutex
+	// Temporary tables belong to one session (see Tx.CreateTemporaryTable)
+	// and their writes are not logged. ClearOnCommit empties one when a
+	// transaction that wrote to it commits.
+	Temporary     bool
+	ClearOnCommit bool
+	modified      time.Time // of the last write; see metrics.go
+	created       time.Time
+	seq           int // creation order in the database; see ListTables
+	mu            sync.RWMutex
+
+	usage   map[usageKey]*indexCounts // see indexusage.go
+	usageMu sync.Mutex
 }
 
 type ForeignKey struct {
@@ -78,22 +97,29 @@ const (
 )
 
 func NewTable(name string, schema *Schema) *Table {
-	return &Table{
+	t := &Table{
 		Name:        name,
-		Schema:      schema,
 		Rows:        make([]*Row, 0),
 		Indexes:     make(map[string]Index),
 		RowIDSeq:    1,
 		ForeignKeys: make([]*ForeignKey, 0),
 	}
+	t.schema.Store(schema)
+	return t
+}
+
+// Schema returns the table's columns. ALTER TABLE replaces the schema
+// rather than changing it, so the one returned stays as it was.
+func (t *Table) Schema() *Schema {
+	return t.schema.Load()
 }
 
 func (t *Table) AddIndex(columnName string) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if _, exists := t.Schema.GetColumn(columnName); !exists {
-		return fmt.Errorf("column %s not found", columnName)
+	if _, exists := t.Schema().GetColumn(columnName); !exists {
+		return errorf(ErrColumnNotFound, "column %s not found", columnName)
 	}
 
 	if _, exists := t.Indexes[columnName]; exists {
@@ -103,7 +129,7 @@ func (t *Table) AddIndex(columnName string) error {
 	index := NewIndex()
 	t.Indexes[columnName] = index
 
-	colIndex := t.Schema.ColumnIndex(columnName)
+	colIndex := t.Schema().ColumnIndex(columnName)
 	for rowID, row := range t.Rows {
 		if val, err := row.Get(colIndex); err == nil {
 			index.Insert(val, rowID)
@@ -122,6 +148,7 @@ func (t *Table) RemoveIndex(columnName string) error {
 	}
 
 	delete(t.Indexes, columnName)
+	t.forgetUsage(usageKey{name: columnName})
 	return nil
 }
 
@@ -129,9 +156,18 @@ func (t *Table) Insert(row *Row) (int, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	rowID, _, err := t.insert(row, false)
+	return rowID, err
+}
+
+// insert adds row and returns its row id along with the stored row, which
+// may be a padded copy of the one passed in. With deferUnique set, UNIQUE
+// columns are left for the transaction to check at commit. Callers must
+// hold t.mu.
+func (t *Table) insert(row *Row, deferUnique bool) (int, *Row, error) {
 	// Handle auto-incrementing primary key
 	pkColIndex := -1
-	for i, col := range t.Schema.Columns {
+	for i, col := range t.Schema().Columns {
 		if col.PrimaryKey {
 			pkColIndex = i
 			break
@@ -164,69 +200,51 @@ func (t *Table) Insert(row *Row) (int, error) {
 		row.Set(pkColIndex, NewIntegerValue(int64(t.RowIDSeq)))
 	}
 
-	if len(row.Values) > len(t.Schema.Columns) {
-		return -1, fmt.Errorf("row column count %d exceeds schema %d",
-			len(row.Values), len(t.Schema.Columns))
+	if len(row.Values) > len(t.Schema().Columns) {
+		return -1, nil, fmt.Errorf("row column count %d exceeds schema %d",
+			len(row.Values), len(t.Schema().Columns))
 	}
 
-	for i, col := range t.Schema.Columns {
+	for i, col := range t.Schema().Columns {
 		if i >= len(row.Values) {
 			continue
 		}
 
 		val := row.Values[i]
 		if val.Type() != col.Type && val.Type() != TypeNull {
-			return -1, fmt.Errorf("type mismatch for column %s: expected %s, got %s",
+			return -1, nil, errorf(ErrTypeMismatch, "type mismatch for column %s: expected %s, got %s",
 				col.Name, col.Type, val.Type())
 		}
 
 		if col.NotNull && val.Type() == TypeNull {
 			// Allow null for PK only if it gets auto-incremented. We already handled it.
 			if !col.PrimaryKey {
-				return -1, fmt.Errorf("column %s cannot be null", col.Name)
+				return -1, nil, errorf(ErrNotNullViolation, "column %s cannot be null", col.Name)
 			}
 		}
 
-		if col.PrimaryKey && val.Type() != TypeNull {
-			colIndex := t.Schema.ColumnIndex(col.Name)
-			for _, existingRow := range t.Rows {
-				existingVal, _ := existingRow.Get(colIndex)
-				if val.Equals(existingVal) {
-					// If we just assigned this, we need to advance the sequence past any manually inserted higher value
-					if intVal, ok := val.(*IntegerValue); ok {
-						if intVal.Value >= int64(t.RowIDSeq) {
-							t.RowIDSeq = int(intVal.Value)
-						}
-					}
-					return -1, fmt.Errorf("primary key violation: duplicate value %s", val.ToString())
-				}
-			}
-		}
-
-		if col.Unique && val.Type() != TypeNull {
-			colIndex := t.Schema.ColumnIndex(col.Name)
-			for _, existingRow := range t.Rows {
-				existingVal, _ := existingRow.Get(colIndex)
-				if val.Equals(existingVal) {
-					return -1, fmt.Errorf("unique constraint violation: duplicate value %s", val.ToString())
+		if col.PrimaryKey && val.Type() != TypeNull && t.holds(col, i, val) {
+			// If we just assigned this, we need to advance the sequence past any manually inserted higher value
+			if intVal, ok := val.(*IntegerValue); ok {
+				if intVal.Value >= int64(t.RowIDSeq) {
+					t.RowIDSeq = int(intVal.Value)
 				}
 			}
+			return -1, nil, errorf(ErrPrimaryKeyViolation, "primary key violation: duplicate value %s", val.ToString())
 		}
-	}
 
-	for _, fk := range t.ForeignKeys {
-		if err := t.checkForeignKey(row, fk); err != nil {
-			return -1, fmt.Errorf("foreign key constraint violation: %w", err)
+		if col.Unique && !deferUnique && t.holds(col, i, val) {
+			return -1, nil, errorf(ErrUniqueViolation, "unique constraint violation: duplicate value %s", val.ToString())
 		}
 	}
 
 	finalRow := row
-	if len(row.Values) < len(t.Schema.Columns) {
-		newValues := make([]Value, len(t.Schema.Columns))
+	if len(row.Values) < len(t.Schema().Columns) {
+		newValues := make([]Value, len(t.Schema().Columns))
 		copy(newValues, row.Values)
 
-		for i := len(row.Values); i < len(t.Schema.Columns); i++ {
-			col := t.Schema.Columns[i]
+		for i := len(row.Values); i < len(t.Schema().Columns); i++ {
+			col := t.Schema().Columns[i]
 			if col.Default != nil {
 				newValues[i] = col.Default.Clone()
 			} else {
@@ -251,43 +269,51 @@ func (t *Table) Insert(row *Row) (int, error) {
 			}
 		}
 	}
-	
+
 	rowIDToReturn := t.RowIDSeq - 1
 	if isPKNull {
 		rowIDToReturn = t.RowIDSeq
 	}
 
-
 	t.Rows = append(t.Rows, finalRow)
 	t.RowIDSeq++
 
-
 	for colName, index := range t.Indexes {
-		colIndex := t.Schema.ColumnIndex(colName)
+		colIndex := t.Schema().ColumnIndex(colName)
 		if val, err := finalRow.Get(colIndex); err == nil && val.Type() != TypeNull {
-			if err := index.Insert(val, rowIDToReturn); err != nil {
+			if err := index.Insert(val, len(t.Rows)-1); err != nil {
 				t.Rows = t.Rows[:len(t.Rows)-1]
 				t.RowIDSeq--
-				return -1, fmt.Errorf("failed to update index: %w", err)
+				return -1, nil, fmt.Errorf("failed to update index: %w", err)
 			}
 		}
 	}
+	for _, idx := range t.secondary {
+		idx.add(finalRow, len(t.Rows)-1)
+	}
+	t.logBuilds(len(t.Rows) - 1)
+	t.countWrites(1)
+	t.modified = time.Now()
 
-	return rowIDToReturn, nil
+	return rowIDToReturn, finalRow, nil
 }
 
-func (t *Table) checkForeignKey(row *Row, fk *ForeignKey) error {
-	fkValues := make([]Value, len(fk.Columns))
-	for i, colName := range fk.Columns {
-		colIndex := t.Schema.ColumnIndex(colName)
-		val, err := row.Get(colIndex)
-		if err != nil {
-			return err
+// holds reports whether a row already has a value in col, at position i,
+// that duplicates val. A non-NULL value is looked up in the column's index
+// when it has one, which keeps each row of a bulk INSERT from scanning the
+// whole table. Callers must hold t.mu.
+func (t *Table) holds(col *Column, i int, val Value) bool {
+	if index, ok := t.Indexes[col.Name]; ok && val.Type() != TypeNull {
+		ptrs, found := index.Lookup(val)
+		return found && len(ptrs) > 0
+	}
+	for _, row := range t.Rows {
+		existing, _ := row.Get(i)
+		if col.duplicates(val, existing) {
+			return true
 		}
-		fkValues[i] = val
 	}
-
-	return nil
+	return false
 }
 
 func (t *Table) Select(predicate func(*Row) bool) []*Row {
@@ -303,77 +329,289 @@ func (t *Table) Select(predicate func(*Row) bool) []*Row {
 	return result
 }
 
-func (t *Table) Update(predicate func(*Row) bool, updater func(*Row)) (int, error) {
+// Scan calls fn with each row in insertion order until fn returns false.
+// It holds the table's read lock and does not clone the rows, so fn must
+// clone any row it keeps and must not write to the table.
+func (t *Table) Scan(fn func(*Row) bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, row := range t.Rows {
+		if !fn(row) {
+			return
+		}
+	}
+}
+
+func (t *Table) Update(predicate func(*Row) bool, updater func(*Row) error) (int, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	updated := 0
+	changes, err := t.update(predicate, updater, false)
+	if err != nil {
+		return -1, err
+	}
+	return len(changes), nil
+}
+
+// update applies updater to a copy of every matching row and gives the
+// row the copy's values once they pass the constraints, so the predicate
+// and updater both see the row as it was and a stored row is never half
+// written. If updater fails or a row violates a constraint, all rows
+// changed so far are restored before returning. Both run under the
+// table's lock and must not call back into the table. deferUnique is as
+// for insert. Callers must hold t.mu.
+func (t *Table) update(predicate func(*Row) bool, updater func(*Row) error, deferUnique bool) ([]RowChange, error) {
+	changes := make([]RowChange, 0)
+	var positions []int // of the rows in changes
+	restore := func() {
+		for j := len(changes) - 1; j >= 0; j-- {
+			changes[j].Row.Values = changes[j].Before.Values
+		}
+	}
 	for i, row := range t.Rows {
 		if predicate == nil || predicate(row) {
-			oldRow := row.Clone()
-			updater(row)
+			updated := row.Clone()
+			if err := updater(updated); err != nil {
+				restore()
+				return nil, err
+			}
+			if err := t.checkUpdate(i, updated, row, deferUnique); err != nil {
+				restore()
+				return nil, err
+			}
+
+			changes = append(changes, RowChange{Kind: ChangeUpdate, Row: row, Before: NewRow(row.Values)})
+			positions = append(positions, i)
+			row.Values = updated.Values
+		}
+	}
+	if len(changes) > 0 && (len(t.Indexes) > 0 || len(t.secondary) > 0) {
+		for j, pos := range positions {
+			t.updateIndexes(pos, changes[j].Before)
+		}
+		t.countWrites(len(changes))
+	}
+	if len(changes) > 0 {
+		t.modified = time.Now()
+	}
+	return changes, nil
+}
 
-			for _, col := range t.Schema.Columns {
-				if col.PrimaryKey {
-					colIndex := t.Schema.ColumnIndex(col.Name)
-					newVal, _ := row.Get(colIndex)
-					oldVal, _ := oldRow.Get(colIndex)
+func (t *Table) checkUpdate(i int, row, oldRow *Row, deferUnique bool) error {
+	for j, col := range t.Schema().Columns {
+		// The new values were worked out from the schema the statement
+		// loaded, which an ALTER TABLE may have replaced since.
+		if j < len(row.Values) && row.Values[j].Type() != col.Type && row.Values[j].Type() != TypeNull {
+			return errorf(ErrTypeMismatch, "type mismatch for column %s: expected %s, got %s",
+				col.Name, col.Type, row.Values[j].Type())
+		}
+		if col.PrimaryKey {
+			colIndex := t.Schema().ColumnIndex(col.Name)
+			newVal, _ := row.Get(colIndex)
+			oldVal, _ := oldRow.Get(colIndex)
 
-					if !newVal.Equals(oldVal) {
-						return -1, fmt.Errorf("cannot update primary key column %s", col.Name)
-					}
-				}
+			if !newVal.Equals(oldVal) {
+				return fmt.Errorf("cannot update primary key column %s", col.Name)
 			}
+		}
+	}
 
-			for _, col := range t.Schema.Columns {
-				if col.Unique {
-					colIndex := t.Schema.ColumnIndex(col.Name)
-					newVal, _ := row.Get(colIndex)
-					oldVal, _ := oldRow.Get(colIndex)
-
-					if !newVal.Equals(oldVal) {
-						for j, otherRow := range t.Rows {
-							if j != i {
-								otherVal, _ := otherRow.Get(colIndex)
-								if newVal.Equals(otherVal) {
-									return -1, fmt.Errorf("unique constraint violation: duplicate value %s",
-										newVal.ToString())
-								}
-							}
+	for _, col := range t.Schema().Columns {
+		if col.Unique && !deferUnique {
+			colIndex := t.Schema().ColumnIndex(col.Name)
+			newVal, _ := row.Get(colIndex)
+			oldVal, _ := oldRow.Get(colIndex)
+
+			if !newVal.Equals(oldVal) {
+				for j, otherRow := range t.Rows {
+					if j != i {
+						otherVal, _ := otherRow.Get(colIndex)
+						if col.duplicates(newVal, otherVal) {
+							return errorf(ErrUniqueViolation, "unique constraint violation: duplicate value %s",
+								newVal.ToString())
 						}
 					}
 				}
 			}
-
-			updated++
 		}
 	}
-	return updated, nil
+	return nil
 }
 
 func (t *Table) Delete(predicate func(*Row) bool) (int, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	deleted := 0
+	changes, err := t.delete(predicate)
+	if err != nil {
+		return -1, err
+	}
+	return len(changes), nil
+}
+
+// delete removes every matching row. Callers must hold t.mu.
+func (t *Table) delete(predicate func(*Row) bool) ([]RowChange, error) {
+	changes := make([]RowChange, 0)
 	newRows := make([]*Row, 0)
 
 	for _, row := range t.Rows {
 		if predicate == nil || predicate(row) {
-			deleted++
-			for colName, index := range t.Indexes {
-				colIndex := t.Schema.ColumnIndex(colName)
-				if val, err := row.Get(colIndex); err == nil {
-					index.Delete(val)
-				}
-			}
+			changes = append(changes, RowChange{Kind: ChangeDelete, Row: row})
 		} else {
 			newRows = append(newRows, row)
 		}
 	}
 
 	t.Rows = newRows
-	return deleted, nil
+	if len(changes) > 0 {
+		t.reindex()
+		t.countWrites(len(changes))
+		t.modified = time.Now()
+	}
+	return changes, nil
+}
+
+// undoChanges reverses changes recorded by a transaction, the last first,
+// rebuilding the indexes once at the end rather than after each row, so
+// undoing a large INSERT stays linear. Callers must hold t.mu.
+func (t *Table) undoChanges(changes []RowChange) {
+	rebuild := false
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		switch change.Kind {
+		case ChangeInsert:
+			// Inserted rows are usually still the last ones.
+			if n := len(t.Rows); n > 0 && t.Rows[n-1] == change.Row {
+				t.Rows = t.Rows[:n-1]
+				rebuild = true
+				continue
+			}
+			for i, row := range t.Rows {
+				if row == change.Row {
+					t.Rows = append(t.Rows[:i], t.Rows[i+1:]...)
+					rebuild = true
+					break
+				}
+			}
+		case ChangeUpdate:
+			change.Row.Values = change.Before.Values
+			rebuild = true
+		case ChangeDelete:
+			t.Rows = append(t.Rows, change.Row)
+			t.indexRow(change.Row)
+		}
+	}
+	if rebuild {
+		t.reindex()
+	}
+	t.countWrites(len(changes))
+	if len(changes) > 0 {
+		t.modified = time.Now()
+	}
+}
+
+func (t *Table) indexRow(row *Row) {
+	for colName, index := range t.Indexes {
+		colIndex := t.Schema().ColumnIndex(colName)
+		if val, err := row.Get(colIndex); err == nil && val.Type() != TypeNull {
+			index.Insert(val, len(t.Rows)-1)
+		}
+	}
+	for _, idx := range t.secondary {
+		idx.add(row, len(t.Rows)-1)
+	}
+	t.logBuilds(len(t.Rows) - 1)
+}
+
+// updateIndexes moves the index entries of the row at pos, which had the
+// values of before, to its current values. Rows stay where they are on
+// update, so only that row's entries change. Callers must hold t.mu.
+func (t *Table) updateIndexes(pos int, before *Row) {
+	row := t.Rows[pos]
+	for colName, index := range t.Indexes {
+		colIndex := t.Schema().ColumnIndex(colName)
+		old, err := before.Get(colIndex)
+		if err != nil {
+			old = NullValue{}
+		}
+		val, err := row.Get(colIndex)
+		if err != nil {
+			val = NullValue{}
+		}
+		if old.Type() == val.Type() && old.Equals(val) {
+			continue
+		}
+		// With unique checks deferred another row may hold the old
+		// value too; its entry is left alone.
+		if old.Type() != TypeNull {
+			if ptrs, ok := index.Lookup(old); ok && len(ptrs) == 1 && ptrs[0] == pos {
+				index.Delete(old)
+			}
+		}
+		if val.Type() != TypeNull {
+			index.Insert(val, pos)
+		}
+	}
+	for _, idx := range t.secondary {
+		idx.remove(before, pos)
+		idx.add(row, pos)
+	}
+	t.invalidateBuilds()
+}
+
+// reindex rebuilds every index from t.Rows. Index entries point at row
+// positions, so they are rebuilt whenever rows move; updates, which leave
+// rows in place, use updateIndexes. Callers must hold t.mu.
+func (t *Table) reindex() {
+	for colName := range t.Indexes {
+		index := NewIndex()
+		colIndex := t.Schema().ColumnIndex(colName)
+		for i, row := range t.Rows {
+			if val, err := row.Get(colIndex); err == nil && val.Type() != TypeNull {
+				index.Insert(val, i)
+			}
+		}
+		t.Indexes[colName] = index
+	}
+	for _, idx := range t.secondary {
+		idx.rebuild(t.Rows)
+	}
+	t.invalidateBuilds()
+}
+
+// HasIndex reports whether column has an index.
+func (t *Table) HasIndex(column string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, ok := t.Indexes[column]
+	return ok
+}
+
+// ScanIndexRange is Scan restricted, through column's index, to the rows
+// whose value in column lies between start and end inclusive. The rows
+// come in scan order. It reports false, without calling fn, if column has
+// no index.
+func (t *Table) ScanIndexRange(column string, start, end Value, fn func(*Row) bool) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	index, ok := t.Indexes[column]
+	if !ok {
+		return false
+	}
+	t.countScan(usageKey{name: column})
+	positions := index.Range(start, end)
+	sort.Ints(positions)
+	for _, i := range positions {
+		if i < 0 || i >= len(t.Rows) {
+			continue
+		}
+		if !fn(t.Rows[i]) {
+			break
+		}
+	}
+	return true
 }
 
 func (t *Table) GetRow(rowID int) (*Row, error) {
@@ -386,6 +624,12 @@ func (t *Table) GetRow(rowID int) (*Row, error) {
 	return t.Rows[rowID].Clone(), nil
 }
 
+// Created returns when the table was created, or restored from a backup
+// or the WAL; zero for tables not in a database, such as system tables.
+func (t *Table) Created() time.Time {
+	return t.created
+}
+
 func (t *Table) Count() int {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
@@ -398,10 +642,15 @@ func (t *Table) Truncate() {
 
 	t.Rows = make([]*Row, 0)
 	t.RowIDSeq = 1
+	t.modified = time.Now()
 
 	for colName := range t.Indexes {
 		t.Indexes[colName] = NewIndex()
 	}
+	for _, idx := range t.secondary {
+		idx.rebuild(nil)
+	}
+	t.invalidateBuilds()
 }
 
 func (t *Table) AddForeignKey(fk *ForeignKey) error {
@@ -413,8 +662,8 @@ func (t *Table) AddForeignKey(fk *ForeignKey) error {
 	}
 
 	for _, colName := range fk.Columns {
-		if _, exists := t.Schema.GetColumn(colName); !exists {
-			return fmt.Errorf("column %s not found", colName)
+		if _, exists := t.Schema().GetColumn(colName); !exists {
+			return errorf(ErrColumnNotFound, "column %s not found", colName)
 		}
 	}
 
@@ -436,7 +685,7 @@ func (t *Table) String() string {
 	defer t.mu.RUnlock()
 
 	result := fmt.Sprintf("Table: %s\n", t.Name)
-	result += fmt.Sprintf("Schema: %s\n", t.Schema.String())
+	result += fmt.Sprintf("Schema: %s\n", t.Schema().String())
 	result += fmt.Sprintf("Rows: %d\n", len(t.Rows))
 	result += fmt.Sprintf("Indexes: %d\n", len(t.Indexes))
 	for colName := range t.Indexes {
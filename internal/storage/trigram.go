@@ -0,0 +1,89 @@
+package storage
+
+import "sort"
+
+// Trigram similarity, as PostgreSQL's pg_trgm measures it: each word of a
+// text (see Tokenize), padded with two spaces in front and one behind,
+// gives the runs of three characters in it, so "cat" gives "  c", " ca",
+// "cat" and "at ". Two texts are as similar as the share of their
+// distinct trigrams they have in common, from 0 to 1, which stays high
+// when a word is misspelled.
+
+// Trigrams returns the distinct trigrams of text, sorted.
+func Trigrams(text string) []string {
+	seen := make(map[string]bool)
+	var trigrams []string
+	for _, word := range Tokenize(text) {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			if trigram := string(runes[i : i+3]); !seen[trigram] {
+				seen[trigram] = true
+				trigrams = append(trigrams, trigram)
+			}
+		}
+	}
+	sort.Strings(trigrams)
+	return trigrams
+}
+
+// Similarity returns the number of trigrams a and b share over the number
+// of distinct trigrams of both: 1 for texts with the same words, 0 for
+// texts without a trigram in common or without words.
+func Similarity(a, b string) float64 {
+	ta, tb := Trigrams(a), Trigrams(b)
+	shared := 0
+	for i, j := 0, 0; i < len(ta) && j < len(tb); {
+		switch {
+		case ta[i] == tb[j]:
+			shared++
+			i++
+			j++
+		case ta[i] < tb[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return similarity(shared, len(ta), len(tb))
+}
+
+func similarity(shared, a, b int) float64 {
+	if a+b-shared == 0 {
+		return 0
+	}
+	return float64(shared) / float64(a+b-shared)
+}
+
+// ScanTrigram calls fn, in scan order, with each row of the trigram index
+// name that shares a trigram with text and the row's Similarity to it.
+// Rows sharing none have a similarity of 0 and are not read. It reports
+// false, without calling fn, if the table has no such index.
+func (t *Table) ScanTrigram(name, text string, fn func(row *Row, similarity float64) bool) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	idx, ok := t.secondary[name]
+	if !ok || !idx.Trigram {
+		return false
+	}
+	t.countScan(usageKey{secondary: true, name: name})
+	trigrams := Trigrams(text)
+	shared := make(map[int]int)
+	for _, trigram := range trigrams {
+		for _, entry := range idx.termEntries(trigram) {
+			shared[entry.pos]++
+		}
+	}
+
+	positions := make([]int, 0, len(shared))
+	for pos := range shared {
+		positions = append(positions, pos)
+	}
+	sort.Ints(positions)
+	for _, pos := range positions {
+		if pos < len(t.Rows) && !fn(t.Rows[pos], similarity(shared[pos], len(trigrams), idx.words[pos])) {
+			break
+		}
+	}
+	return true
+}
@@ -0,0 +1,499 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+)
+
+type ChangeKind int
+
+const (
+	ChangeInsert ChangeKind = iota
+	ChangeUpdate
+	ChangeDelete
+)
+
+// RowChange describes one row touched by a write. Before is only set for
+// updates and holds the row as it was prior to the change.
+type RowChange struct {
+	Kind   ChangeKind
+	Row    *Row
+	Before *Row
+}
+
+type undoEntry struct {
+	table        *Table
+	changes      []RowChange
+	created      string
+	dropped      *Table
+	altered      *alteration // of table
+	createdIndex *SecondaryIndex
+	droppedIndex *SecondaryIndex
+	db           *Database // of created or dropped, when not the transaction's
+	wal          []WALChange
+}
+
+// Tx groups writes so they can be undone together. Changes are applied to
+// the tables immediately; Rollback reverts them in reverse order.
+//
+// Each write checks the constraints it could violate before returning,
+// unless the transaction has deferred its UNIQUE and FOREIGN KEY checks
+// (SetDeferred). Those are then checked at Commit, over every table the
+// transaction wrote to, and a violation rolls the transaction back.
+// PRIMARY KEY and NOT NULL are always checked immediately. While a
+// deferred UNIQUE column holds a duplicate, index scans on it see only one
+// of the rows.
+type Tx struct {
+	db       *Database
+	undo     []undoEntry
+	done     bool
+	deferred bool
+	pending  bool // written while deferred and not yet checked
+	onCommit []func()
+	gid      string      // set by Prepare; see prepared.go
+	prepared *PreparedTx // while waiting for CommitPrepared
+}
+
+func (db *Database) Begin() *Tx {
+	return &Tx{db: db}
+}
+
+func (tx *Tx) Insert(table *Table, row *Row) (int, error) {
+	ids, err := tx.InsertRows(table, []*Row{row})
+	if err != nil {
+		return -1, err
+	}
+	return ids[0], nil
+}
+
+// InsertRows inserts rows as a single write and returns their row ids. The
+// table is locked once and foreign keys are checked once for the batch, so
+// a multi-row INSERT costs little more per row than a single one. If a row
+// fails, the rows before it are removed again.
+func (tx *Tx) InsertRows(table *Table, rows []*Row) ([]int, error) {
+	if err := tx.check(); err != nil {
+		return nil, err
+	}
+
+	table.mu.Lock()
+	columns := table.Schema().ColumnNames()
+	ids := make([]int, 0, len(rows))
+	written := make([]*Row, 0, len(rows))
+	entry := undoEntry{
+		table:   table,
+		changes: make([]RowChange, 0, len(rows)),
+		wal:     make([]WALChange, 0, len(rows)),
+	}
+	for _, row := range rows {
+		rowID, stored, err := table.insert(row, tx.deferred)
+		if err != nil {
+			table.mu.Unlock()
+			tx.revert(entry)
+			return nil, err
+		}
+		ids = append(ids, rowID)
+		written = append(written, stored)
+		entry.changes = append(entry.changes, RowChange{Kind: ChangeInsert, Row: stored})
+		if table.Temporary {
+			continue
+		}
+		entry.wal = append(entry.wal, WALChange{
+			Op:      WALInsert,
+			Table:   table.Name,
+			Columns: columns,
+			After:   cloneValues(stored.Values),
+		})
+	}
+	table.mu.Unlock()
+
+	if err := tx.db.insertHook(table, written); err != nil {
+		tx.revert(entry)
+		return nil, err
+	}
+	if err := tx.checkWrite(table, written, nil); err != nil {
+		tx.revert(entry)
+		return nil, err
+	}
+	tx.undo = append(tx.undo, entry)
+	return ids, nil
+}
+
+func (tx *Tx) Update(table *Table, predicate func(*Row) bool, updater func(*Row) error) (int, error) {
+	if err := tx.check(); err != nil {
+		return -1, err
+	}
+
+	table.mu.Lock()
+	changes, err := table.update(predicate, updater, tx.deferred)
+	if err != nil {
+		table.mu.Unlock()
+		return -1, err
+	}
+	entry := undoEntry{table: table, changes: changes, wal: walChanges(table, changes)}
+	table.mu.Unlock()
+
+	after := make([]*Row, len(changes))
+	before := make([]*Row, len(changes))
+	for i, change := range changes {
+		after[i], before[i] = change.Row, change.Before
+	}
+	if err := tx.checkWrite(table, after, before); err != nil {
+		tx.revert(entry)
+		return -1, err
+	}
+	tx.undo = append(tx.undo, entry)
+	return len(changes), nil
+}
+
+func (tx *Tx) Delete(table *Table, predicate func(*Row) bool) (int, error) {
+	if err := tx.check(); err != nil {
+		return -1, err
+	}
+
+	table.mu.Lock()
+	changes, err := table.delete(predicate)
+	if err != nil {
+		table.mu.Unlock()
+		return -1, err
+	}
+	entry := undoEntry{table: table, changes: changes, wal: walChanges(table, changes)}
+	table.mu.Unlock()
+
+	deleted := make([]*Row, len(changes))
+	for i, change := range changes {
+		deleted[i] = change.Row
+	}
+	if err := tx.checkWrite(table, nil, deleted); err != nil {
+		tx.revert(entry)
+		return -1, err
+	}
+	tx.undo = append(tx.undo, entry)
+	return len(changes), nil
+}
+
+// checkWrite checks the foreign keys of rows written to table and, for
+// removed, the rows deleted or as they were before an update, the foreign
+// keys that may refer to them. With constraints deferred it only notes
+// that Commit has to check them.
+func (tx *Tx) checkWrite(table *Table, written, removed []*Row) error {
+	if table.Temporary {
+		// Temporary tables have no foreign keys, and none refer to them.
+		if tx.deferred {
+			tx.pending = true
+		}
+		return nil
+	}
+	if tx.deferred {
+		tx.pending = true
+		return nil
+	}
+	if len(written) > 0 {
+		if err := tx.db.checkReferences(table, written); err != nil {
+			return err
+		}
+	}
+	if len(removed) > 0 {
+		return tx.db.checkReferenced(table, removed)
+	}
+	return nil
+}
+
+// SetDeferred sets whether the transaction's UNIQUE and FOREIGN KEY
+// constraints are checked at Commit rather than by each write, as SET
+// CONSTRAINTS ALL DEFERRED does. Making them immediate again checks the
+// writes made while they were deferred; on a violation they stay deferred.
+func (tx *Tx) SetDeferred(deferred bool) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	if !deferred && tx.pending {
+		if err := tx.checkDeferred(); err != nil {
+			return err
+		}
+		tx.pending = false
+	}
+	tx.deferred = deferred
+	return nil
+}
+
+// checkDeferred checks the UNIQUE columns of the tables the transaction
+// wrote to, their foreign keys and the foreign keys referring to them.
+func (tx *Tx) checkDeferred() error {
+	written := make(map[string]*Table)
+	for _, entry := range tx.undo {
+		switch {
+		case entry.table == nil:
+		case entry.table.Temporary:
+			if err := entry.table.checkUnique(); err != nil {
+				return err
+			}
+		default:
+			written[entry.table.Name] = entry.table
+		}
+	}
+	names := make([]string, 0, len(written))
+	for name := range written {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	check := make(map[string]*Table)
+	for _, name := range names {
+		table := written[name]
+		if current, err := tx.db.GetTable(name); err != nil || current != table {
+			continue // dropped since
+		}
+		if err := table.checkUnique(); err != nil {
+			return err
+		}
+		check[name] = table
+		for _, ref := range tx.db.referencing(name) {
+			check[ref.table.Name] = ref.table
+		}
+	}
+	names = names[:0]
+	for name := range check {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := tx.db.checkReferences(check[name], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateTable creates a table with the foreign keys fks, which are logged
+// with it.
+func (tx *Tx) CreateTable(name string, schema *Schema, fks ...*ForeignKey) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	if hook := tx.db.Hooks().OnCreateTable; hook != nil {
+		if err := hook(name, schema); err != nil {
+			return err
+		}
+	}
+	if err := tx.db.createTable(name, schema, fks); err != nil {
+		return err
+	}
+
+	tx.undo = append(tx.undo, undoEntry{
+		created: name,
+		wal:     []WALChange{{Op: WALCreateTable, Table: name, Schema: schema, ForeignKeys: fks}},
+	})
+	return nil
+}
+
+// CreateTemporaryTable creates a table in temp, a database made with
+// NewTemporaryDatabase. Rolling back drops it again; neither it nor its
+// rows reach the WAL.
+func (tx *Tx) CreateTemporaryTable(temp *Database, name string, schema *Schema) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	if err := temp.CreateTable(name, schema); err != nil {
+		return err
+	}
+
+	tx.undo = append(tx.undo, undoEntry{created: name, db: temp})
+	return nil
+}
+
+func (tx *Tx) DropTable(name string) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	table, err := tx.db.GetTable(name)
+	if err != nil {
+		return err
+	}
+	if err := tx.db.DropTable(name); err != nil {
+		return err
+	}
+
+	tx.undo = append(tx.undo, undoEntry{
+		dropped: table,
+		wal:     []WALChange{{Op: WALDropTable, Table: name}},
+	})
+	return nil
+}
+
+// DropTemporaryTable drops a table created with CreateTemporaryTable.
+func (tx *Tx) DropTemporaryTable(temp *Database, name string) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	table, err := temp.GetTable(name)
+	if err != nil {
+		return err
+	}
+	if err := temp.DropTable(name); err != nil {
+		return err
+	}
+
+	tx.undo = append(tx.undo, undoEntry{dropped: table, db: temp})
+	return nil
+}
+
+// OnCommit registers fn to run after the transaction commits, as ON
+// COMMIT DROP does for a temporary table.
+func (tx *Tx) OnCommit(fn func()) {
+	tx.onCommit = append(tx.onCommit, fn)
+}
+
+// Savepoint marks the current position in the transaction so later writes
+// can be undone with RollbackTo without abandoning the whole transaction.
+func (tx *Tx) Savepoint() int {
+	return len(tx.undo)
+}
+
+func (tx *Tx) RollbackTo(savepoint int) {
+	for i := len(tx.undo) - 1; i >= savepoint; i-- {
+		tx.revert(tx.undo[i])
+	}
+	if savepoint < len(tx.undo) {
+		tx.undo = tx.undo[:savepoint]
+	}
+}
+
+// Commit makes the transaction's writes permanent and appends them to the
+// WAL as a single entry. With a WAL file open, it returns once the entry is
+// synced to the file; if writing the file failed, the writes stay made but
+// Commit reports that they are not durable.
+func (tx *Tx) Commit() error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	if tx.pending {
+		if err := tx.checkDeferred(); err != nil {
+			tx.RollbackTo(0)
+			tx.done = true
+			return fmt.Errorf("%w (transaction rolled back)", err)
+		}
+	}
+
+	var changes []WALChange
+	for _, entry := range tx.undo {
+		changes = append(changes, entry.wal...)
+	}
+	var durable error
+	var lsn uint64
+	if len(changes) > 0 {
+		if hook := tx.db.getCommitHook(); hook != nil {
+			if err := hook(changes); err != nil {
+				tx.RollbackTo(0)
+				tx.done = true
+				return fmt.Errorf("commit failed, transaction rolled back: %w", err)
+			}
+		} else {
+			entry := tx.db.wal.append(changes, tx.gid)
+			lsn = entry.LSN
+			durable = tx.db.wal.sync(lsn)
+		}
+	}
+
+	cleared := make(map[*Table]bool)
+	for _, entry := range tx.undo {
+		if entry.table != nil && entry.table.ClearOnCommit && !cleared[entry.table] {
+			cleared[entry.table] = true
+			entry.table.Delete(func(*Row) bool { return true })
+		}
+	}
+	tx.done = true
+	tx.undo = nil
+	for _, fn := range tx.onCommit {
+		fn()
+	}
+	tx.db.commitEvents(lsn, changes)
+	if durable != nil {
+		return fmt.Errorf("commit is not durable: %w", durable)
+	}
+	return nil
+}
+
+func (tx *Tx) Rollback() error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	tx.RollbackTo(0)
+	tx.done = true
+	return nil
+}
+
+func (tx *Tx) check() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if tx.prepared != nil {
+		return fmt.Errorf("transaction is prepared as %s", tx.gid)
+	}
+	return nil
+}
+
+func (tx *Tx) revert(entry undoEntry) {
+	db := tx.db
+	if entry.db != nil {
+		db = entry.db
+	}
+	switch {
+	case entry.created != "":
+		db.mu.Lock()
+		delete(db.tables, entry.created)
+		db.mu.Unlock()
+	case entry.dropped != nil:
+		db.mu.Lock()
+		db.tables[entry.dropped.Name] = entry.dropped
+		db.mu.Unlock()
+	case entry.createdIndex != nil:
+		db.dropIndex(entry.createdIndex.Name)
+	case entry.droppedIndex != nil:
+		db.restoreIndex(entry.droppedIndex)
+	case entry.altered != nil:
+		entry.table.mu.Lock()
+		defer entry.table.mu.Unlock()
+
+		entry.table.revertAlteration(entry.altered)
+	default:
+		entry.table.mu.Lock()
+		defer entry.table.mu.Unlock()
+
+		entry.table.undoChanges(entry.changes)
+	}
+}
+
+// walChanges converts the row changes of an update or delete to WAL form.
+// Values are copied because the rows may be modified again before commit.
+func walChanges(table *Table, changes []RowChange) []WALChange {
+	if table.Temporary {
+		return nil
+	}
+	columns := table.Schema().ColumnNames()
+	out := make([]WALChange, 0, len(changes))
+	for _, change := range changes {
+		switch change.Kind {
+		case ChangeUpdate:
+			out = append(out, WALChange{
+				Op:      WALUpdate,
+				Table:   table.Name,
+				Columns: columns,
+				Before:  cloneValues(change.Before.Values),
+				After:   cloneValues(change.Row.Values),
+			})
+		case ChangeDelete:
+			out = append(out, WALChange{
+				Op:      WALDelete,
+				Table:   table.Name,
+				Columns: columns,
+				Before:  cloneValues(change.Row.Values),
+			})
+		}
+	}
+	return out
+}
@@ -13,6 +13,9 @@ const (
 	TypeText
 	TypeBoolean
 	TypeNull
+	// TypeInterval comes last so that the numbers of the other types,
+	// written to the WAL and backups, stay the same.
+	TypeInterval
 )
 
 func (dt DataType) String() string {
@@ -27,6 +30,8 @@ func (dt DataType) String() string {
 		return "BOOLEAN"
 	case TypeNull:
 		return "NULL"
+	case TypeInterval:
+		return "INTERVAL"
 	default:
 		return "UNKNOWN"
 	}
@@ -185,6 +190,8 @@ func ParseValue(dataType DataType, s string) (Value, error) {
 			return nil, fmt.Errorf("invalid boolean: %s", s)
 		}
 		return NewBooleanValue(v), nil
+	case TypeInterval:
+		return ParseInterval(s)
 	default:
 		return nil, fmt.Errorf("unsupported type: %s", dataType)
 	}
@@ -197,6 +204,19 @@ type Column struct {
 	Unique     bool
 	NotNull    bool
 	Default    Value
+	// NullsNotDistinct makes a UNIQUE column hold at most one NULL. By
+	// default, as in standard SQL, NULLs are distinct from each other and
+	// any number of rows may have one.
+	NullsNotDistinct bool
+}
+
+// duplicates reports whether a and b break the column's UNIQUE constraint
+// when both are in it.
+func (c *Column) duplicates(a, b Value) bool {
+	if a.Type() == TypeNull || b.Type() == TypeNull {
+		return c.NullsNotDistinct && a.Type() == b.Type()
+	}
+	return a.Equals(b)
 }
 
 func NewColumn(name string, dataType DataType, primaryKey, unique, notNull bool) *Column {
@@ -239,6 +259,28 @@ func (s *Schema) ColumnIndex(name string) int {
 	return -1
 }
 
+func (s *Schema) ColumnNames() []string {
+	names := make([]string, len(s.Columns))
+	for i, col := range s.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// SameColumns reports whether other has the same column names and types,
+// in the same order.
+func (s *Schema) SameColumns(other *Schema) bool {
+	if other == nil || len(s.Columns) != len(other.Columns) {
+		return false
+	}
+	for i, col := range s.Columns {
+		if col.Name != other.Columns[i].Name || col.Type != other.Columns[i].Type {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Schema) PrimaryKeyColumns() []*Column {
 	pks := make([]*Column, 0)
 	for _, col := range s.Columns {
@@ -261,6 +303,9 @@ func (s *Schema) String() string {
 		}
 		if col.Unique {
 			result += " UNIQUE"
+			if col.NullsNotDistinct {
+				result += " NULLS NOT DISTINCT"
+			}
 		}
 		if col.NotNull {
 			result += " NOT NULL"
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"sort"
+)
+
+const (
+	saltSize       = 16
+	hashIterations = 10000
+)
+
+// User is a login role. Only a salted hash of the password is kept.
+type User struct {
+	Name string
+	salt []byte
+	hash []byte
+}
+
+func (db *Database) CreateUser(name, password string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, exists := db.users[name]; exists {
+		return errorf(ErrUserExists, "user %s already exists", name)
+	}
+	if password == "" {
+		return fmt.Errorf("password for user %s cannot be empty", name)
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	db.users[name] = &User{Name: name, salt: salt, hash: hashPassword(salt, password)}
+	return nil
+}
+
+func (db *Database) DropUser(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, exists := db.users[name]; !exists {
+		return errorf(ErrUserNotFound, "user %s not found", name)
+	}
+	delete(db.users, name)
+	return nil
+}
+
+// Authenticate reports whether password matches the one stored for name.
+func (db *Database) Authenticate(name, password string) bool {
+	db.mu.RLock()
+	user, exists := db.users[name]
+	db.mu.RUnlock()
+
+	if !exists {
+		return false
+	}
+	return subtle.ConstantTimeCompare(hashPassword(user.salt, password), user.hash) == 1
+}
+
+func (db *Database) ListUsers() []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	users := make([]string, 0, len(db.users))
+	for name := range db.users {
+		users = append(users, name)
+	}
+	sort.Strings(users)
+	return users
+}
+
+func hashPassword(salt []byte, password string) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, salt...), password...))
+	for i := 1; i < hashIterations; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return sum[:]
+}
@@ -0,0 +1,555 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type WALOp string
+
+const (
+	WALInsert      WALOp = "insert"
+	WALUpdate      WALOp = "update"
+	WALDelete      WALOp = "delete"
+	WALCreateTable WALOp = "create_table"
+	WALDropTable   WALOp = "drop_table"
+	WALAlterColumn WALOp = "alter_column"
+	WALCreateIndex WALOp = "create_index"
+	WALDropIndex   WALOp = "drop_index"
+)
+
+// WALChange is one logical change inside a committed transaction. Before
+// holds the old row for updates and deletes, After the new row for inserts
+// and updates, and Schema the table definition for create_table and its
+// new definition for alter_column, whose Columns names the column changed
+// and which is followed by an update of every row. Columns names the
+// values of row changes. ForeignKeys holds the foreign keys of a table
+// created, and Index the definition of an index created or, for
+// drop_index, its name.
+type WALChange struct {
+	Op          WALOp
+	Table       string
+	Columns     []string
+	Before      []Value
+	After       []Value
+	Schema      *Schema
+	ForeignKeys []*ForeignKey
+	Index       *SecondaryIndex
+}
+
+// WALEntry is a committed transaction. LSNs increase by one per entry.
+// GID is set when the transaction was prepared for two-phase commit.
+type WALEntry struct {
+	LSN     uint64      `json:"lsn"`
+	Time    time.Time   `json:"time"`
+	GID     string      `json:"gid,omitempty"`
+	Changes []WALChange `json:"changes"`
+}
+
+// WAL is the in-memory log of committed changes that replicas and change
+// consumers read from.
+type WAL struct {
+	mu        sync.Mutex
+	entries   []WALEntry
+	last      uint64
+	changed   chan struct{}
+	retention time.Duration // 0 sets no window; see prune
+	oldest    uint64        // LSN of the last discarded entry
+	horizon   time.Time     // commit time of the last discarded entry
+	slots     map[string]*ReplicationSlot
+	replicas  map[string]*replicaHold // streaming replicas, by name
+	prepared  map[string]*Tx          // by GID
+	file      *walFile                // nil unless OpenWALFile was called
+}
+
+func newWAL() *WAL {
+	return &WAL{changed: make(chan struct{})}
+}
+
+func (db *Database) WAL() *WAL {
+	return db.wal
+}
+
+func (w *WAL) LastLSN() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.last
+}
+
+func (w *WAL) append(changes []WALChange, gid string) WALEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	entry := WALEntry{LSN: w.last + 1, Time: time.Now(), GID: gid, Changes: changes}
+	w.add(entry)
+	return entry
+}
+
+// add stores entry and wakes any waiters. Callers must hold w.mu.
+func (w *WAL) add(entry WALEntry) {
+	w.entries = append(w.entries, entry)
+	w.last = entry.LSN
+	w.prune(time.Now())
+	if w.file != nil {
+		w.file.enqueue(entry)
+	}
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// sync waits until the entry lsn is in the WAL file, if one is open.
+func (w *WAL) sync(lsn uint64) error {
+	w.mu.Lock()
+	file := w.file
+	w.mu.Unlock()
+	if file == nil {
+		return nil
+	}
+	return file.wait(lsn)
+}
+
+// Since returns the entries with an LSN greater than lsn.
+func (w *WAL) Since(lsn uint64) []WALEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := w.after(lsn)
+	if i == len(w.entries) {
+		return nil
+	}
+	out := make([]WALEntry, len(w.entries)-i)
+	copy(out, w.entries[i:])
+	return out
+}
+
+// after returns the position of the first entry with an LSN greater than
+// lsn. Callers must hold w.mu.
+func (w *WAL) after(lsn uint64) int {
+	return sort.Search(len(w.entries), func(i int) bool { return w.entries[i].LSN > lsn })
+}
+
+// Wait blocks until an entry after lsn exists or ctx is done.
+func (w *WAL) Wait(ctx context.Context, lsn uint64) error {
+	for {
+		w.mu.Lock()
+		last, changed := w.last, w.changed
+		w.mu.Unlock()
+
+		if last > lsn {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// ApplyWALEntry replays an entry received from another node and appends it
+// to this database's WAL with the same LSN. Entries must arrive in order.
+func (db *Database) ApplyWALEntry(entry WALEntry) error {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+
+	if entry.LSN != db.wal.last+1 {
+		return fmt.Errorf("out of order WAL entry: expected LSN %d, got %d", db.wal.last+1, entry.LSN)
+	}
+
+	if err := db.applyChanges(entry.Changes); err != nil {
+		return fmt.Errorf("failed to apply LSN %d: %w", entry.LSN, err)
+	}
+
+	db.wal.add(entry)
+	return nil
+}
+
+// ApplyWALChanges applies changes committed elsewhere and appends them to
+// the WAL under the next LSN.
+func (db *Database) ApplyWALChanges(changes []WALChange) (uint64, error) {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+
+	if err := db.applyChanges(changes); err != nil {
+		return 0, err
+	}
+
+	entry := WALEntry{LSN: db.wal.last + 1, Time: time.Now(), Changes: changes}
+	db.wal.add(entry)
+	return entry.LSN, nil
+}
+
+// LogWALChanges appends changes that are already applied to this database,
+// such as a transaction whose commit was confirmed by the cluster.
+func (db *Database) LogWALChanges(changes []WALChange) uint64 {
+	return db.wal.append(changes, "").LSN
+}
+
+// SetCommitHook installs a function that Tx.Commit calls with the
+// transaction's changes before they are logged. If it returns an error the
+// transaction is rolled back. With a hook installed, Commit leaves logging
+// to the hook's owner (see LogWALChanges).
+func (db *Database) SetCommitHook(hook func([]WALChange) error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.commitHook = hook
+}
+
+func (db *Database) getCommitHook() func([]WALChange) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.commitHook
+}
+
+// Dump returns the contents of the database as WAL changes that recreate it,
+// along with the LSN they correspond to.
+func (db *Database) Dump() (uint64, []WALChange) {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	// In creation order, so that a restore keeps it.
+	var changes []WALChange
+	for _, table := range db.orderedTables() {
+		name := table.Name
+		table.mu.RLock()
+		changes = append(changes, WALChange{Op: WALCreateTable, Table: name, Schema: table.Schema(),
+			ForeignKeys: append([]*ForeignKey(nil), table.ForeignKeys...)})
+		columns := table.Schema().ColumnNames()
+		for _, row := range table.Rows {
+			changes = append(changes, WALChange{Op: WALInsert, Table: name, Columns: columns, After: cloneValues(row.Values)})
+		}
+		// After the rows, so that a restore builds each index once.
+		for _, idx := range table.secondaryIndexes() {
+			changes = append(changes, WALChange{Op: WALCreateIndex, Table: name, Index: idx.definition()})
+		}
+		table.mu.RUnlock()
+	}
+	return db.wal.last, changes
+}
+
+// Restore replaces every table with the contents of a Dump and resets the
+// WAL to continue from lsn. Earlier WAL entries are discarded.
+func (db *Database) Restore(lsn uint64, changes []WALChange) error {
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+	if db.wal.file != nil {
+		return errors.New("cannot restore a snapshot while a WAL file is open")
+	}
+
+	db.mu.Lock()
+	db.tables = make(map[string]*Table)
+	db.mu.Unlock()
+
+	for _, change := range changes {
+		if _, err := db.applyChange(change); err != nil {
+			return fmt.Errorf("failed to restore snapshot: %w", err)
+		}
+	}
+
+	db.wal.entries = nil
+	db.wal.last = lsn
+	db.wal.oldest = lsn
+	db.wal.horizon = time.Now()
+	close(db.wal.changed)
+	db.wal.changed = make(chan struct{})
+	return nil
+}
+
+// applyChanges applies the changes of one WAL entry. If one fails, those
+// applied before it are undone, so the entry is applied whole or not at
+// all and can be applied again. Callers must hold db.wal.mu.
+func (db *Database) applyChanges(changes []WALChange) error {
+	tx := db.Begin()
+	for _, change := range changes {
+		undo, err := db.applyChange(change)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		tx.undo = append(tx.undo, undo)
+	}
+	return nil
+}
+
+// applyChange applies change and returns how to undo it.
+func (db *Database) applyChange(change WALChange) (undoEntry, error) {
+	switch change.Op {
+	case WALCreateTable:
+		return undoEntry{created: change.Table}, db.createTable(change.Table, change.Schema, change.ForeignKeys)
+	case WALDropTable:
+		table, err := db.GetTable(change.Table)
+		if err != nil {
+			return undoEntry{}, err
+		}
+		return undoEntry{dropped: table}, db.DropTable(change.Table)
+	case WALCreateIndex:
+		idx, matches, err := db.loggedIndex(change.Index)
+		if err != nil {
+			return undoEntry{}, err
+		}
+		return undoEntry{createdIndex: idx}, db.CreateIndex(idx, matches)
+	case WALDropIndex:
+		idx, err := db.dropIndex(change.Index.Name)
+		return undoEntry{droppedIndex: idx}, err
+	}
+
+	table, err := db.GetTable(change.Table)
+	if err != nil {
+		return undoEntry{}, err
+	}
+
+	table.mu.Lock()
+	defer table.mu.Unlock()
+
+	undo := undoEntry{table: table}
+	switch change.Op {
+	case WALInsert:
+		_, stored, err := table.insert(NewRow(cloneValues(change.After)), false)
+		undo.changes = []RowChange{{Kind: ChangeInsert, Row: stored}}
+		return undo, err
+	case WALUpdate:
+		pos := table.findPos(change.Before)
+		if pos < 0 {
+			return undo, fmt.Errorf("row to update not found in table %s", change.Table)
+		}
+		before := table.Rows[pos].Clone()
+		table.Rows[pos].Values = cloneValues(change.After)
+		table.updateIndexes(pos, before)
+		table.countWrites(1)
+		table.modified = time.Now()
+		undo.changes = []RowChange{{Kind: ChangeUpdate, Row: table.Rows[pos], Before: before}}
+		return undo, nil
+	case WALDelete:
+		row := table.findRow(change.Before)
+		if row == nil {
+			return undo, fmt.Errorf("row to delete not found in table %s", change.Table)
+		}
+		table.undoChanges([]RowChange{{Kind: ChangeInsert, Row: row}})
+		undo.changes = []RowChange{{Kind: ChangeDelete, Row: row}}
+		return undo, nil
+	case WALAlterColumn:
+		undo.altered = &alteration{schema: table.Schema()}
+		return undo, table.applyAlterColumn(change)
+	default:
+		return undo, fmt.Errorf("unknown WAL operation %q", change.Op)
+	}
+}
+
+// findRow returns the first row whose values equal values, or nil.
+// Callers must hold t.mu.
+func (t *Table) findRow(values []Value) *Row {
+	if pos := t.findPos(values); pos >= 0 {
+		return t.Rows[pos]
+	}
+	return nil
+}
+
+// findPos returns the position of the first row whose values equal
+// values, or -1, going straight to it through the primary key when there
+// is one. Callers must hold t.mu.
+func (t *Table) findPos(values []Value) int {
+	if pk := t.Schema().PrimaryKeyColumns(); len(pk) == 1 {
+		if i := t.Schema().ColumnIndex(pk[0].Name); i < len(values) {
+			if pos, ok := t.pkPosition(values[i]); ok && sameValues(t.Rows[pos].Values, values) {
+				return pos
+			}
+		}
+	}
+	// Without a primary key, or while undoing history, when the indexes
+	// are not kept up to date.
+	for pos, row := range t.Rows {
+		if sameValues(row.Values, values) {
+			return pos
+		}
+	}
+	return -1
+}
+
+func sameValues(a, b []Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range a {
+		if v.Type() != b[i].Type() || !v.Equals(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func cloneValues(values []Value) []Value {
+	if values == nil {
+		return nil
+	}
+	out := make([]Value, len(values))
+	for i, v := range values {
+		out[i] = v.Clone()
+	}
+	return out
+}
+
+type walValue struct {
+	Type  DataType `json:"t"`
+	Value string   `json:"v,omitempty"`
+}
+
+type walColumn struct {
+	Name             string    `json:"name"`
+	Type             DataType  `json:"type"`
+	PrimaryKey       bool      `json:"primary_key,omitempty"`
+	Unique           bool      `json:"unique,omitempty"`
+	NullsNotDistinct bool      `json:"nulls_not_distinct,omitempty"`
+	NotNull          bool      `json:"not_null,omitempty"`
+	Default          *walValue `json:"default,omitempty"`
+}
+
+type walForeignKey struct {
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns"`
+	OnDelete   string   `json:"on_delete,omitempty"`
+	OnUpdate   string   `json:"on_update,omitempty"`
+}
+
+type walIndex struct {
+	Name      string   `json:"name"`
+	Column    string   `json:"column,omitempty"`
+	Include   []string `json:"include,omitempty"`
+	Predicate string   `json:"where,omitempty"`
+	FullText  bool     `json:"fulltext,omitempty"`
+	Trigram   bool     `json:"trigram,omitempty"`
+}
+
+type walChangeJSON struct {
+	Op          WALOp           `json:"op"`
+	Table       string          `json:"table"`
+	Columns     []string        `json:"columns,omitempty"`
+	Before      []walValue      `json:"before,omitempty"`
+	After       []walValue      `json:"after,omitempty"`
+	Schema      []walColumn     `json:"schema,omitempty"`
+	ForeignKeys []walForeignKey `json:"foreign_keys,omitempty"`
+	Index       *walIndex       `json:"index,omitempty"`
+}
+
+func (c WALChange) MarshalJSON() ([]byte, error) {
+	out := walChangeJSON{
+		Op:      c.Op,
+		Table:   c.Table,
+		Columns: c.Columns,
+		Before:  encodeWALValues(c.Before),
+		After:   encodeWALValues(c.After),
+	}
+	if c.Schema != nil {
+		for _, col := range c.Schema.Columns {
+			wc := walColumn{
+				Name:             col.Name,
+				Type:             col.Type,
+				PrimaryKey:       col.PrimaryKey,
+				Unique:           col.Unique,
+				NullsNotDistinct: col.NullsNotDistinct,
+				NotNull:          col.NotNull,
+			}
+			if col.Default != nil {
+				v := encodeWALValue(col.Default)
+				wc.Default = &v
+			}
+			out.Schema = append(out.Schema, wc)
+		}
+	}
+	for _, fk := range c.ForeignKeys {
+		out.ForeignKeys = append(out.ForeignKeys, walForeignKey(*fk))
+	}
+	if idx := c.Index; idx != nil {
+		out.Index = &walIndex{Name: idx.Name, Column: idx.Column, Include: idx.Include, Predicate: idx.Predicate,
+			FullText: idx.FullText, Trigram: idx.Trigram}
+	}
+	return json.Marshal(out)
+}
+
+func (c *WALChange) UnmarshalJSON(data []byte) error {
+	var in walChangeJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	before, err := decodeWALValues(in.Before)
+	if err != nil {
+		return err
+	}
+	after, err := decodeWALValues(in.After)
+	if err != nil {
+		return err
+	}
+
+	*c = WALChange{Op: in.Op, Table: in.Table, Columns: in.Columns, Before: before, After: after}
+	if len(in.Schema) > 0 {
+		c.Schema = NewSchema()
+		for _, wc := range in.Schema {
+			col := NewColumn(wc.Name, wc.Type, wc.PrimaryKey, wc.Unique, wc.NotNull)
+			col.NullsNotDistinct = wc.NullsNotDistinct
+			if wc.Default != nil {
+				if col.Default, err = decodeWALValue(*wc.Default); err != nil {
+					return err
+				}
+			}
+			c.Schema.AddColumn(col)
+		}
+	}
+	for _, fk := range in.ForeignKeys {
+		fk := ForeignKey(fk)
+		c.ForeignKeys = append(c.ForeignKeys, &fk)
+	}
+	if wi := in.Index; wi != nil {
+		c.Index = &SecondaryIndex{Name: wi.Name, Table: in.Table, Column: wi.Column, Include: wi.Include, Predicate: wi.Predicate,
+			FullText: wi.FullText, Trigram: wi.Trigram}
+	}
+	return nil
+}
+
+func encodeWALValue(v Value) walValue {
+	if v.Type() == TypeNull {
+		return walValue{Type: TypeNull}
+	}
+	return walValue{Type: v.Type(), Value: v.ToString()}
+}
+
+func encodeWALValues(values []Value) []walValue {
+	if values == nil {
+		return nil
+	}
+	out := make([]walValue, len(values))
+	for i, v := range values {
+		out[i] = encodeWALValue(v)
+	}
+	return out
+}
+
+func decodeWALValue(v walValue) (Value, error) {
+	if v.Type == TypeNull {
+		return NullValue{}, nil
+	}
+	return ParseValue(v.Type, v.Value)
+}
+
+func decodeWALValues(values []walValue) ([]Value, error) {
+	if values == nil {
+		return nil, nil
+	}
+	out := make([]Value, len(values))
+	for i, v := range values {
+		val, err := decodeWALValue(v)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = val
+	}
+	return out, nil
+}
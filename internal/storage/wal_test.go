@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// copies returns a replica of db built from its WAL, sent as JSON as a
+// primary does, a database restored from its Dump, and one restored from
+// a backup.
+func copies(t *testing.T, db *Database) map[string]*Database {
+	t.Helper()
+	replica := NewDatabase()
+	for _, entry := range db.WAL().Since(0) {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var received WALEntry
+		if err := json.Unmarshal(data, &received); err != nil {
+			t.Fatal(err)
+		}
+		if err := replica.ApplyWALEntry(received); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restored := NewDatabase()
+	if err := restored.Restore(db.Dump()); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := db.Backup(&buf); err != nil {
+		t.Fatal(err)
+	}
+	backup := NewDatabase()
+	if _, err := backup.RestoreBackup(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]*Database{"replica": replica, "restored": restored, "backup": backup}
+}
+
+func TestForeignKeysReplayed(t *testing.T) {
+	db := NewDatabase()
+	users := NewSchema()
+	users.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	tasks := NewSchema()
+	tasks.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	tasks.AddColumn(NewColumn("user_id", TypeInteger, false, false, false))
+	tx := db.Begin()
+	if err := tx.CreateTable("users", users); err != nil {
+		t.Fatal(err)
+	}
+	fk := &ForeignKey{Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"},
+		OnDelete: FKActionNoAction, OnUpdate: FKActionNoAction}
+	if err := tx.CreateTable("tasks", tasks, fk); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("users")
+	if _, err := tx.Insert(table, NewRow([]Value{NewIntegerValue(1)})); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, copy := range copies(t, db) {
+		tasks, err := copy.GetTable("tasks")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if fks := tasks.GetForeignKeys(); len(fks) != 1 || !reflect.DeepEqual(fks[0], fk) {
+			t.Errorf("%s: foreign keys = %v, want %v", name, fks, fk)
+		}
+		tx := copy.Begin()
+		if _, err := tx.Insert(tasks, NewRow([]Value{NewIntegerValue(1), NewIntegerValue(99)})); !errors.Is(err, ErrForeignKeyViolation) {
+			t.Errorf("%s: orphan insert: err = %v, want ErrForeignKeyViolation", name, err)
+		}
+		if _, err := tx.Insert(tasks, NewRow([]Value{NewIntegerValue(2), NewIntegerValue(1)})); err != nil {
+			t.Errorf("%s: insert: %v", name, err)
+		}
+		tx.Rollback()
+	}
+}
+
+// TestUpdateIndexes checks that the indexes an UPDATE changes in place,
+// on the primary and on a replica replaying its WAL, hold what
+// rebuilding them would.
+func TestUpdateIndexes(t *testing.T) {
+	db := NewDatabase()
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	schema.AddColumn(NewColumn("code", TypeText, false, true, false))
+	schema.AddColumn(NewColumn("status", TypeText, false, false, false))
+	schema.AddColumn(NewColumn("body", TypeText, false, false, false))
+	tx := db.Begin()
+	if err := tx.CreateTable("tasks", schema); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("tasks")
+	text := func(s string) Value {
+		if s == "" {
+			return NullValue{}
+		}
+		return NewTextValue(s)
+	}
+	for _, idx := range []*SecondaryIndex{
+		{Name: "tasks_status", Table: "tasks", Column: "status", Include: []string{"code"}},
+		{Name: "tasks_body", Table: "tasks", Column: "body", FullText: true},
+		{Name: "tasks_trgm", Table: "tasks", Column: "body", Trigram: true},
+	} {
+		if err := tx.CreateIndex(idx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statuses := []string{"pending", "done", ""}
+	for i := 0; i < 30; i++ {
+		row := NewRow([]Value{NewIntegerValue(int64(i)), text(fmt.Sprintf("c%d", i)),
+			text(statuses[i%3]), text(fmt.Sprintf("task %d of the day", i%7))})
+		if _, err := tx.Insert(table, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i += 2 {
+		tx := db.Begin()
+		_, err := tx.Update(table, func(r *Row) bool { return r.Values[0].Equals(NewIntegerValue(int64(i))) }, func(r *Row) error {
+			code := ""
+			if i%4 != 0 {
+				code = fmt.Sprintf("n%d", i)
+			}
+			r.Values[1], r.Values[2], r.Values[3] = text(code), text(statuses[(i+1)%3]), text(fmt.Sprintf("note %d", i))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replica := NewDatabase()
+	for _, entry := range db.WAL().Since(0) {
+		if err := replica.ApplyWALEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, db := range map[string]*Database{"primary": db, "replica": replica} {
+		table, _ := db.GetTable("tasks")
+		table.mu.Lock()
+		got := indexState(table)
+		table.reindex()
+		want := indexState(table)
+		table.mu.Unlock()
+		for index := range want {
+			if got[index] != want[index] {
+				t.Errorf("%s: index %s after update\n%s\nwant\n%s", name, index, got[index], want[index])
+			}
+		}
+	}
+}
+
+// indexState describes the entries of t's indexes. Callers must hold t.mu.
+func indexState(t *Table) map[string]string {
+	state := make(map[string]string)
+	for column, index := range t.Indexes {
+		var entries []string
+		for _, pos := range index.ScanAll() {
+			entries = append(entries, fmt.Sprintf("%d", pos))
+		}
+		state[column] = fmt.Sprint(entries)
+	}
+	for _, idx := range t.secondary {
+		describe := func(entries []indexEntry) []string {
+			var out []string
+			for _, entry := range entries {
+				var values []string
+				for _, v := range entry.values {
+					values = append(values, v.ToString())
+				}
+				out = append(out, fmt.Sprintf("%s@%d%v*%d", entry.key.ToString(), entry.pos, values, entry.count))
+			}
+			return out
+		}
+		// The rows with a NULL key are kept in no particular order.
+		nulls := describe(idx.nulls)
+		sort.Strings(nulls)
+		state[idx.Name] = fmt.Sprint(describe(idx.entries), nulls, idx.words)
+	}
+	return state
+}
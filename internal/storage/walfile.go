@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// A WAL file makes commits durable: each WAL entry is appended to it as a
+// JSON line, and Commit returns once the file has been synced to disk.
+// Syncing dominates the cost of a small commit, so the file syncs in
+// groups (group commit): entries committed while a sync runs, or within
+// the commit window after the first of a group, are written together and
+// synced once.
+type walFile struct {
+	f      *os.File
+	path   string
+	window time.Duration
+
+	mu      sync.Mutex
+	cond    *sync.Cond // signalled when entries are queued or synced
+	pending []WALEntry
+	synced  uint64 // LSN of the last entry on disk
+	err     error  // a failed write; every later commit reports it
+	closed  bool
+	done    chan struct{}
+	stats   WALFileStats
+}
+
+// WALFileStats count the entries written to the WAL file and the syncs
+// that made them durable; with concurrent commits there are fewer syncs
+// than entries.
+type WALFileStats struct {
+	Entries int
+	Syncs   int
+}
+
+// OpenWALFile replays the entries of the WAL file at path after the
+// database's last LSN, then appends every later entry to it, syncing
+// entries committed within window of each other together. It returns the
+// number of entries replayed. A last line cut short by a crash is
+// discarded. Replication slots and prepared transactions saved with the
+// file are loaded.
+func (db *Database) OpenWALFile(path string, window time.Duration) (int, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open WAL file: %w", err)
+	}
+	replayed, committed, err := db.replayWALFile(f)
+	if err != nil {
+		f.Close()
+		return 0, fmt.Errorf("failed to replay WAL file %s: %w", path, err)
+	}
+	prepared, err := db.restorePrepared(path, committed)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+
+	wf := &walFile{f: f, path: path, window: window, done: make(chan struct{})}
+	wf.cond = sync.NewCond(&wf.mu)
+
+	fail := func(err error) (int, error) {
+		f.Close()
+		for _, tx := range prepared {
+			tx.prepared = nil
+			tx.Rollback()
+		}
+		return 0, err
+	}
+
+	db.wal.mu.Lock()
+	defer db.wal.mu.Unlock()
+	if db.wal.file != nil {
+		return fail(errors.New("a WAL file is already open"))
+	}
+	if err := db.wal.loadSlots(path); err != nil {
+		return fail(err)
+	}
+	if len(prepared) > 0 && db.wal.prepared == nil {
+		db.wal.prepared = make(map[string]*Tx, len(prepared))
+	}
+	for gid, tx := range prepared {
+		db.wal.prepared[gid] = tx
+	}
+	wf.synced = db.wal.last
+	db.wal.file = wf
+	go wf.run()
+	return replayed, nil
+}
+
+// CloseWALFile writes the entries still queued, syncs and closes the WAL
+// file. Later commits are not logged to a file.
+func (db *Database) CloseWALFile() error {
+	db.wal.mu.Lock()
+	wf := db.wal.file
+	db.wal.file = nil
+	db.wal.mu.Unlock()
+	if wf == nil {
+		return nil
+	}
+
+	wf.mu.Lock()
+	wf.closed = true
+	wf.cond.Broadcast()
+	wf.mu.Unlock()
+	<-wf.done
+
+	err := wf.err
+	if closeErr := wf.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// WALFileStats returns the counts of the open WAL file, or zeros.
+func (db *Database) WALFileStats() WALFileStats {
+	db.wal.mu.Lock()
+	wf := db.wal.file
+	db.wal.mu.Unlock()
+	if wf == nil {
+		return WALFileStats{}
+	}
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	return wf.stats
+}
+
+// replayWALFile applies the entries of f after the last LSN, returning
+// how many it applied and the GIDs of the prepared transactions committed
+// in f.
+func (db *Database) replayWALFile(f *os.File) (int, map[string]bool, error) {
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	var good int64
+	replayed := 0
+	committed := make(map[string]bool)
+	for {
+		var entry WALEntry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			if err := f.Truncate(good); err != nil {
+				return replayed, nil, err
+			}
+			break
+		}
+		if err != nil {
+			return replayed, nil, err
+		}
+		good = decoder.InputOffset()
+		if entry.GID != "" {
+			committed[entry.GID] = true
+		}
+		if entry.LSN <= db.wal.LastLSN() {
+			continue // already in a restored backup
+		}
+		if err := db.ApplyWALEntry(entry); err != nil {
+			return replayed, nil, err
+		}
+		replayed++
+	}
+	_, err := f.Seek(good, io.SeekStart)
+	return replayed, committed, err
+}
+
+// enqueue queues entry to be written. Callers must hold the WAL's lock, so
+// entries are queued in LSN order.
+func (wf *walFile) enqueue(entry WALEntry) {
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	wf.pending = append(wf.pending, entry)
+	wf.cond.Broadcast()
+}
+
+// wait blocks until the entry lsn is on disk.
+func (wf *walFile) wait(lsn uint64) error {
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	for wf.synced < lsn && wf.err == nil && !wf.closed {
+		wf.cond.Wait()
+	}
+	if wf.err != nil {
+		return wf.err
+	}
+	if wf.synced < lsn {
+		return errors.New("WAL file closed before the commit was written")
+	}
+	return nil
+}
+
+// run writes and syncs the queued entries a group at a time until the
+// file is closed.
+func (wf *walFile) run() {
+	defer close(wf.done)
+	wf.mu.Lock()
+	defer wf.mu.Unlock()
+	for {
+		for len(wf.pending) == 0 && !wf.closed {
+			wf.cond.Wait()
+		}
+		if len(wf.pending) == 0 {
+			return
+		}
+		if wf.window > 0 && !wf.closed {
+			wf.mu.Unlock()
+			time.Sleep(wf.window)
+			wf.mu.Lock()
+		}
+		group := wf.pending
+		wf.pending = nil
+
+		wf.mu.Unlock()
+		err := wf.write(group)
+		wf.mu.Lock()
+
+		if err != nil && wf.err == nil {
+			wf.err = fmt.Errorf("failed to write WAL file: %w", err)
+		}
+		if err == nil {
+			wf.synced = group[len(group)-1].LSN
+			wf.stats.Entries += len(group)
+			wf.stats.Syncs++
+		}
+		wf.cond.Broadcast()
+	}
+}
+
+func (wf *walFile) write(group []WALEntry) error {
+	bw := bufio.NewWriter(wf.f)
+	encoder := json.NewEncoder(bw)
+	for _, entry := range group {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return wf.f.Sync()
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWALFileGroupCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rdbms.wal")
+	db := NewDatabase()
+	if _, err := db.OpenWALFile(path, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	schema := NewSchema()
+	schema.AddColumn(NewColumn("id", TypeInteger, true, false, true))
+	tx := db.Begin()
+	if err := tx.CreateTable("events", schema); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	table, _ := db.GetTable("events")
+
+	const commits = 50
+	var wg sync.WaitGroup
+	for i := 0; i < commits; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tx := db.Begin()
+			if _, err := tx.Insert(table, NewRow([]Value{NewIntegerValue(int64(i + 1))})); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := db.WALFileStats()
+	if stats.Entries != commits+1 || stats.Syncs >= stats.Entries {
+		t.Errorf("stats = %+v, want %d entries in fewer syncs", stats, commits+1)
+	}
+	if err := db.CloseWALFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash may leave half a line at the end; replay drops it.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"lsn":52,"changes":[`)
+	f.Close()
+
+	replica := NewDatabase()
+	replayed, err := replica.OpenWALFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.CloseWALFile()
+	if replayed != commits+1 || replica.WAL().LastLSN() != commits+1 {
+		t.Errorf("replayed %d entries to LSN %d, want %d", replayed, replica.WAL().LastLSN(), commits+1)
+	}
+	table, err = replica.GetTable("events")
+	if err != nil || len(table.Select(nil)) != commits {
+		t.Fatalf("replayed table: %v", err)
+	}
+
+	// The file continues after the replayed entries.
+	tx = replica.Begin()
+	tx.Insert(table, NewRow([]Value{NewIntegerValue(commits + 1)}))
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := replica.Restore(0, nil); err == nil {
+		t.Error("Restore succeeded with a WAL file open")
+	}
+}
@@ -0,0 +1,33 @@
+package rdbms
+
+import "github.com/mryan-3/rdbms/internal/sql"
+
+// Errors returned by Exec, Query, FindRow and Tx wrap one of these kinds; test for
+// them with errors.Is.
+var (
+	ErrSyntax              = sql.ErrSyntax
+	ErrTableNotFound       = sql.ErrTableNotFound
+	ErrTableExists         = sql.ErrTableExists
+	ErrColumnNotFound      = sql.ErrColumnNotFound
+	ErrAmbiguousColumn     = sql.ErrAmbiguousColumn
+	ErrTypeMismatch        = sql.ErrTypeMismatch
+	ErrConstraintViolation = sql.ErrConstraintViolation
+	ErrNotNullViolation    = sql.ErrNotNullViolation
+	ErrPrimaryKeyViolation = sql.ErrPrimaryKeyViolation
+	ErrUniqueViolation     = sql.ErrUniqueViolation
+	ErrForeignKeyViolation = sql.ErrForeignKeyViolation
+	ErrDivisionByZero      = sql.ErrDivisionByZero
+	ErrTransaction         = sql.ErrTransaction
+	ErrCanceled            = sql.ErrCanceled
+	ErrStatementTimeout    = sql.ErrStatementTimeout
+	ErrInternal            = sql.ErrInternal
+	ErrMemoryLimit         = sql.ErrMemoryLimit
+	ErrHistoryUnavailable  = sql.ErrHistoryUnavailable
+	ErrRowNotFound         = sql.ErrRowNotFound
+)
+
+// SQLState returns the PostgreSQL SQLSTATE code for an error returned by
+// the database, or "XX000" if it is not of a known kind.
+func SQLState(err error) string {
+	return sql.SQLState(err)
+}
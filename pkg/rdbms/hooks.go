@@ -0,0 +1,72 @@
+package rdbms
+
+import (
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Hooks are callbacks run as the database works, e.g. to invalidate a
+// cache when a table changes, count failed statements or reject rows a
+// policy forbids. They run synchronously in the goroutine of the call that
+// triggers them; nil hooks are skipped. Rows map column names to values of
+// the types Rows.Values returns.
+type Hooks struct {
+	// OnCreateTable runs before CREATE TABLE creates a table; an error
+	// fails the statement.
+	OnCreateTable func(table string) error
+	// OnInsert runs for each row inserted, with its defaults filled in;
+	// an error fails the statement.
+	OnInsert func(table string, row map[string]interface{}) error
+	// OnCommit runs after a transaction, or a statement outside one,
+	// commits changes.
+	OnCommit func(changes []Change)
+	// OnError runs when an executed statement fails. Statements that do
+	// not parse are not reported.
+	OnError func(statement string, err error)
+}
+
+// Change is one committed change. Op is insert, update, delete,
+// create_table or drop_table; Before is nil for inserts and After for
+// deletes.
+type Change struct {
+	Table  string
+	Op     string
+	Before map[string]interface{}
+	After  map[string]interface{}
+}
+
+// SetHooks replaces the database's hooks.
+func (db *DB) SetHooks(hooks Hooks) {
+	var h storage.Hooks
+	if hooks.OnCreateTable != nil {
+		h.OnCreateTable = func(name string, _ *storage.Schema) error {
+			return hooks.OnCreateTable(name)
+		}
+	}
+	if hooks.OnInsert != nil {
+		h.OnInsert = func(table string, row map[string]storage.Value) error {
+			return hooks.OnInsert(table, fromRow(row))
+		}
+	}
+	if hooks.OnCommit != nil {
+		h.OnCommit = func(events []storage.ChangeEvent) {
+			changes := make([]Change, len(events))
+			for i, e := range events {
+				changes[i] = Change{Table: e.Table, Op: string(e.Op), Before: fromRow(e.Before), After: fromRow(e.After)}
+			}
+			hooks.OnCommit(changes)
+		}
+	}
+	h.OnError = hooks.OnError
+	db.db.SetHooks(h)
+}
+
+func fromRow(row map[string]storage.Value) map[string]interface{} {
+	if row == nil {
+		return nil
+	}
+	values := make(map[string]interface{}, len(row))
+	for column, v := range row {
+		values[column] = fromValue(v)
+	}
+	return values
+}
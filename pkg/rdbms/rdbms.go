@@ -0,0 +1,277 @@
+// Package rdbms embeds the database engine in a Go program.
+//
+//	db, err := rdbms.Open()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer db.Close()
+//
+//	db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+//	db.Exec("INSERT INTO users VALUES ($1, $2)", 1, "Ada")
+//
+//	rows, err := db.Query("SELECT id, name FROM users WHERE id = $1", 1)
+//	for rows.Next() {
+//		var id int64
+//		var name string
+//		rows.Scan(&id, &name)
+//	}
+//
+// Statements take ? or $N placeholders bound from args. Supported argument
+// types are nil, bool, string, []byte, all integer types, float32 and
+// float64.
+//
+// A DB may be used by any number of goroutines at once. Each call runs in
+// a session of its own, so no transaction state is shared between calls:
+// a transaction exists only as a Tx, and statements run outside one
+// commit on their own. A Tx may also be shared, its statements running
+// one at a time. Close waits for the statements already running.
+package rdbms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mryan-3/rdbms/internal/schemadiff"
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// DB is an in-memory database. It is safe for concurrent use; each call
+// runs in its own session.
+type DB struct {
+	db *storage.Database
+
+	mu      sync.RWMutex
+	closed  bool
+	running sync.WaitGroup // calls in progress; Close waits for them
+}
+
+// Result reports the outcome of a statement that returns no rows.
+type Result struct {
+	RowsAffected int
+	Message      string
+	// LastInsertID is the INTEGER primary key of the last inserted row.
+	LastInsertID int64
+}
+
+// Open creates an empty database.
+func Open() (*DB, error) {
+	return &DB{db: storage.NewDatabase()}, nil
+}
+
+// OpenBackup creates a database loaded from a file written by BACKUP TO or
+// the server's /backup endpoint.
+func OpenBackup(path string) (*DB, error) {
+	db, _ := Open()
+	if _, err := db.db.RestoreBackupFile(path); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// DiffSchemas returns the DDL statements that make the schema of from
+// match that of to; see the rdbms diff command.
+func DiffSchemas(from, to *DB) ([]string, error) {
+	if err := from.check(); err != nil {
+		return nil, err
+	}
+	if err := to.check(); err != nil {
+		return nil, err
+	}
+	return schemadiff.Diff(from.db, to.db), nil
+}
+
+// FindRow returns the values of the row of table whose primary key is key,
+// found through the primary key's index, or an error wrapping
+// ErrRowNotFound. key is converted as a statement argument is.
+func (db *DB) FindRow(table string, key interface{}) ([]interface{}, error) {
+	if err := db.enter(); err != nil {
+		return nil, err
+	}
+	defer db.running.Done()
+	keys, err := convertArgs([]interface{}{key})
+	if err != nil {
+		return nil, err
+	}
+	row, err := db.db.FindRow(table, keys[0])
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(row.Values))
+	for i, v := range row.Values {
+		values[i] = fromValue(v)
+	}
+	return values, nil
+}
+
+// Close releases the database once the calls in progress have returned.
+// Later calls return an error.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	db.closed = true
+	db.mu.Unlock()
+	db.running.Wait()
+	return nil
+}
+
+func (db *DB) Exec(query string, args ...interface{}) (Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext runs a statement that does not return rows. ctx cancels a
+// long-running statement.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	result, err := db.run(ctx, query, args)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{RowsAffected: result.RowsAffected, Message: result.Message, LastInsertID: result.LastInsertID}, nil
+}
+
+func (db *DB) Query(query string, args ...interface{}) (*Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext runs a statement and returns its rows.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	result, err := db.run(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(result), nil
+}
+
+// Begin starts a transaction. Writes made through the Tx are visible to
+// other callers immediately (there is no isolation) but are undone by
+// Rollback.
+func (db *DB) Begin() (*Tx, error) {
+	if err := db.check(); err != nil {
+		return nil, err
+	}
+
+	session := sql.NewSession(db.db)
+	if _, err := session.Execute(&sql.BeginTransactionStatement{}); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &Tx{db: db, session: session}, nil
+}
+
+func (db *DB) check() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return fmt.Errorf("database is closed")
+	}
+	return nil
+}
+
+// enter registers a call unless the database is closed. The caller must
+// call db.running.Done when it returns.
+func (db *DB) enter() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return fmt.Errorf("database is closed")
+	}
+	db.running.Add(1)
+	return nil
+}
+
+func (db *DB) run(ctx context.Context, query string, args []interface{}) (*sql.Result, error) {
+	if err := db.enter(); err != nil {
+		return nil, err
+	}
+	defer db.running.Done()
+
+	session := sql.NewSession(db.db)
+	defer session.Close()
+	return execute(ctx, session, query, args)
+}
+
+func execute(ctx context.Context, session *sql.Session, query string, args []interface{}) (*sql.Result, error) {
+	stmt, paramCount, err := sql.ParseContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != paramCount {
+		return nil, fmt.Errorf("statement expects %d argument(s), got %d", paramCount, len(args))
+	}
+
+	params, err := convertArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return session.ExecuteContext(ctx, stmt, params)
+}
+
+// Tx is a transaction started with DB.Begin. It is safe for concurrent
+// use; its statements run one at a time, in the order their calls take
+// its lock.
+type Tx struct {
+	db      *DB
+	mu      sync.Mutex
+	session *sql.Session
+	done    bool
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (Result, error) {
+	return tx.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext runs a statement in the transaction. A failing statement is
+// undone on its own; the transaction stays open.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	result, err := tx.run(ctx, query, args)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{RowsAffected: result.RowsAffected, Message: result.Message, LastInsertID: result.LastInsertID}, nil
+}
+
+func (tx *Tx) Query(query string, args ...interface{}) (*Rows, error) {
+	return tx.QueryContext(context.Background(), query, args...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	result, err := tx.run(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(result), nil
+}
+
+func (tx *Tx) Commit() error {
+	return tx.finish(&sql.CommitStatement{})
+}
+
+func (tx *Tx) Rollback() error {
+	return tx.finish(&sql.RollbackStatement{})
+}
+
+func (tx *Tx) run(ctx context.Context, query string, args []interface{}) (*sql.Result, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil, fmt.Errorf("transaction has already been committed or rolled back")
+	}
+	if err := tx.db.enter(); err != nil {
+		return nil, err
+	}
+	defer tx.db.running.Done()
+	return execute(ctx, tx.session, query, args)
+}
+
+func (tx *Tx) finish(stmt sql.Node) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return fmt.Errorf("transaction has already been committed or rolled back")
+	}
+	tx.done = true
+	defer tx.session.Close()
+
+	_, err := tx.session.Execute(stmt)
+	return err
+}
@@ -0,0 +1,180 @@
+package rdbms_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mryan-3/rdbms/pkg/rdbms"
+)
+
+// TestConcurrentUse shares one DB, and one Tx, between goroutines. Run it
+// under the race detector.
+func TestConcurrentUse(t *testing.T) {
+	db, _ := rdbms.Open()
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, owner INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, rows = 8, 25
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rows; i++ {
+				id := w*1000 + i
+				// Odd workers write through the shared transaction.
+				var err error
+				if w%2 == 1 {
+					_, err = tx.Exec("INSERT INTO items (id, owner) VALUES ($1, $2)", id, w)
+				} else {
+					_, err = db.Exec("INSERT INTO items (id, owner) VALUES ($1, $2)", id, w)
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := db.Query("SELECT COUNT(*) FROM items WHERE owner = $1", w); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.Query("SELECT COUNT(*) FROM items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	got.Next()
+	got.Scan(&count)
+	if want := int64(workers / 2 * rows); count != want {
+		t.Errorf("COUNT(*) = %d, want %d: only the rows written outside the rolled back Tx", count, want)
+	}
+}
+
+// TestConcurrentAlter changes a column's type back and forth while other
+// goroutines read and write the table. Run it under the race detector. A
+// write may fail with a type mismatch when the type changes under it, but
+// no row may keep a value of the old type.
+func TestConcurrentAlter(t *testing.T) {
+	db, _ := rdbms.Open()
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, qty INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, rows = 4, 50
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		types := []string{"TEXT", "INTEGER"}
+		for n := 0; ; n++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := db.Exec("ALTER TABLE items ALTER COLUMN qty TYPE " + types[n%2]); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	var writers sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < rows; i++ {
+				id := w*1000 + i
+				// A quoted number is stored as text or as an integer,
+				// whichever the column is.
+				_, err := db.Exec("INSERT INTO items (id, qty) VALUES ($1, '7')", id)
+				if err == nil {
+					_, err = db.Exec("UPDATE items SET qty = '8' WHERE id = $1", id)
+				}
+				if err != nil && !errors.Is(err, rdbms.ErrTypeMismatch) {
+					t.Error(err)
+					return
+				}
+				if _, err := db.Query("SELECT id, qty FROM items WHERE qty = '8'"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	writers.Wait()
+	close(done)
+	wg.Wait()
+
+	if _, err := db.Exec("ALTER TABLE items ALTER COLUMN qty TYPE INTEGER"); err != nil {
+		t.Fatalf("final ALTER: %v", err)
+	}
+	got, err := db.Query("SELECT id, qty FROM items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for got.Next() {
+		values := got.Values()
+		if _, ok := values[1].(int64); !ok {
+			t.Errorf("row %v: qty is %T, want int64", values[0], values[1])
+		}
+	}
+}
+
+func TestCloseWaitsForRunningStatements(t *testing.T) {
+	db, _ := rdbms.Open()
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	db.SetHooks(rdbms.Hooks{
+		OnInsert: func(string, map[string]interface{}) error {
+			close(started)
+			<-release
+			return nil
+		},
+	})
+
+	inserted := make(chan error)
+	go func() {
+		_, err := db.Exec("INSERT INTO items (id) VALUES (1)")
+		inserted <- err
+	}()
+	<-started
+	closed := make(chan struct{})
+	go func() {
+		db.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close returned while an INSERT was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-inserted; err != nil {
+		t.Errorf("INSERT: %v", err)
+	}
+	<-closed
+	if _, err := db.Exec("INSERT INTO items (id) VALUES (2)"); err == nil {
+		t.Error("Exec succeeded after Close")
+	}
+}
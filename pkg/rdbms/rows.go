@@ -0,0 +1,192 @@
+package rdbms
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mryan-3/rdbms/internal/sql"
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// Rows is the result of a query. Values are int64, float64, string, bool
+// or nil for NULL. All rows are held in memory, so Rows needs no closing.
+type Rows struct {
+	columns []string
+	values  [][]interface{}
+	pos     int
+}
+
+func newRows(result *sql.Result) *Rows {
+	rows := &Rows{columns: result.Columns, pos: -1}
+	rows.values = make([][]interface{}, len(result.Values))
+	for i, row := range result.Values {
+		values := make([]interface{}, len(row))
+		for j, v := range row {
+			values[j] = fromValue(v)
+		}
+		rows.values[i] = values
+	}
+	return rows
+}
+
+func (r *Rows) Columns() []string {
+	return r.columns
+}
+
+func (r *Rows) Len() int {
+	return len(r.values)
+}
+
+// Next advances to the next row, returning false when there are no more.
+func (r *Rows) Next() bool {
+	if r.pos+1 >= len(r.values) {
+		r.pos = len(r.values)
+		return false
+	}
+	r.pos++
+	return true
+}
+
+// Values returns the current row.
+func (r *Rows) Values() []interface{} {
+	if r.pos < 0 || r.pos >= len(r.values) {
+		return nil
+	}
+	return r.values[r.pos]
+}
+
+// All returns every row regardless of the Next position.
+func (r *Rows) All() [][]interface{} {
+	return r.values
+}
+
+// Scan copies the current row into dest, one pointer per column. Supported
+// pointer types are *interface{}, *int64, *int, *float64, *string and *bool.
+// Scanning NULL into anything but *interface{} is an error.
+func (r *Rows) Scan(dest ...interface{}) error {
+	row := r.Values()
+	if row == nil {
+		return fmt.Errorf("Scan called without a current row")
+	}
+	if len(dest) != len(row) {
+		return fmt.Errorf("expected %d destination argument(s), got %d", len(row), len(dest))
+	}
+
+	for i, v := range row {
+		if err := assign(dest[i], v); err != nil {
+			return fmt.Errorf("column %s: %w", r.columns[i], err)
+		}
+	}
+	return nil
+}
+
+func assign(dest, v interface{}) error {
+	if d, ok := dest.(*interface{}); ok {
+		*d = v
+		return nil
+	}
+	if v == nil {
+		return fmt.Errorf("cannot scan NULL into %T", dest)
+	}
+
+	switch d := dest.(type) {
+	case *int64:
+		if n, ok := v.(int64); ok {
+			*d = n
+			return nil
+		}
+	case *int:
+		if n, ok := v.(int64); ok {
+			*d = int(n)
+			return nil
+		}
+	case *float64:
+		switch n := v.(type) {
+		case float64:
+			*d = n
+			return nil
+		case int64:
+			*d = float64(n)
+			return nil
+		}
+	case *string:
+		if s, ok := v.(string); ok {
+			*d = s
+			return nil
+		}
+	case *bool:
+		if b, ok := v.(bool); ok {
+			*d = b
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported destination type %T", dest)
+	}
+	return fmt.Errorf("cannot scan %T into %T", v, dest)
+}
+
+func fromValue(v storage.Value) interface{} {
+	switch val := v.(type) {
+	case *storage.IntegerValue:
+		return val.Value
+	case *storage.FloatValue:
+		return val.Value
+	case *storage.TextValue:
+		return val.Value
+	case *storage.BooleanValue:
+		return val.Value
+	case *storage.IntervalValue:
+		return val.ToString()
+	default:
+		return nil
+	}
+}
+
+func convertArgs(args []interface{}) ([]storage.Value, error) {
+	params := make([]storage.Value, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			params[i] = storage.NullValue{}
+		case bool:
+			params[i] = storage.NewBooleanValue(v)
+		case string:
+			params[i] = storage.NewTextValue(v)
+		case []byte:
+			params[i] = storage.NewTextValue(string(v))
+		case int:
+			params[i] = storage.NewIntegerValue(int64(v))
+		case int8:
+			params[i] = storage.NewIntegerValue(int64(v))
+		case int16:
+			params[i] = storage.NewIntegerValue(int64(v))
+		case int32:
+			params[i] = storage.NewIntegerValue(int64(v))
+		case int64:
+			params[i] = storage.NewIntegerValue(v)
+		case uint:
+			if uint64(v) > math.MaxInt64 {
+				return nil, fmt.Errorf("argument %d overflows INTEGER: %d", i+1, v)
+			}
+			params[i] = storage.NewIntegerValue(int64(v))
+		case uint8:
+			params[i] = storage.NewIntegerValue(int64(v))
+		case uint16:
+			params[i] = storage.NewIntegerValue(int64(v))
+		case uint32:
+			params[i] = storage.NewIntegerValue(int64(v))
+		case uint64:
+			if v > math.MaxInt64 {
+				return nil, fmt.Errorf("argument %d overflows INTEGER: %d", i+1, v)
+			}
+			params[i] = storage.NewIntegerValue(int64(v))
+		case float32:
+			params[i] = storage.NewFloatValue(float64(v))
+		case float64:
+			params[i] = storage.NewFloatValue(v)
+		default:
+			return nil, fmt.Errorf("unsupported type for argument %d: %T", i+1, arg)
+		}
+	}
+	return params, nil
+}
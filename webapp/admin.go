@@ -0,0 +1,437 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// The admin pages are generated from each table's schema, so any table
+// gets list, create, edit and delete pages without code of its own.
+// Column types choose the input widgets and the primary key identifies
+// rows in edit URLs and delete forms; tables without one can only be listed and
+// added to.
+
+const fieldPrefix = "col:"
+
+type adminTable struct {
+	Name   string
+	Schema *storage.Schema
+	PK     []*storage.Column
+}
+
+// loadAdminTable looks up a table by name. Only names of existing tables
+// (and their columns) are ever written into SQL.
+func loadAdminTable(name string) (*adminTable, error) {
+	schema, err := db.GetSchema(name)
+	if err != nil {
+		return nil, err
+	}
+	return &adminTable{Name: name, Schema: schema, PK: schema.PrimaryKeyColumns()}, nil
+}
+
+// keyQuery identifies a row in admin URLs: ?table=...&pk=... with one pk
+// per primary key column.
+func (t *adminTable) keyQuery(row []storage.Value) url.Values {
+	q := url.Values{"table": {t.Name}}
+	for _, col := range t.PK {
+		q.Add("pk", row[t.Schema.ColumnIndex(col.Name)].ToString())
+	}
+	return q
+}
+
+// keyCondition parses pk values into a WHERE clause and its params.
+func (t *adminTable) keyCondition(pk []string) (string, []storage.Value, error) {
+	if len(t.PK) == 0 {
+		return "", nil, fmt.Errorf("table %s has no primary key", t.Name)
+	}
+	if len(pk) != len(t.PK) {
+		return "", nil, fmt.Errorf("expected %d primary key values, got %d", len(t.PK), len(pk))
+	}
+
+	conditions := make([]string, len(t.PK))
+	params := make([]storage.Value, len(t.PK))
+	for i, col := range t.PK {
+		v, err := storage.ParseValue(col.Type, pk[i])
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", col.Name, err)
+		}
+		conditions[i] = col.Name + " = ?"
+		params[i] = v
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), params, nil
+}
+
+func (t *adminTable) load(pk []string) ([]storage.Value, error) {
+	where, params, err := t.keyCondition(pk)
+	if err != nil {
+		return nil, err
+	}
+	result, err := executeSQLWithResult("SELECT * FROM "+t.Name+where, params...)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Values) == 0 {
+		return nil, fmt.Errorf("row not found")
+	}
+	return result.Values[0], nil
+}
+
+// formValue converts a submitted field to its column's type. An empty
+// field is NULL, except in NOT NULL text columns where it is "".
+func formValue(col *storage.Column, s string) (storage.Value, error) {
+	if s == "" {
+		if col.Type == storage.TypeText && col.NotNull {
+			return storage.NewTextValue(""), nil
+		}
+		return storage.NullValue{}, nil
+	}
+	v, err := storage.ParseValue(col.Type, s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", col.Name, err)
+	}
+	return v, nil
+}
+
+type adminField struct {
+	Name     string
+	Param    string
+	Type     string
+	Input    string
+	Step     string
+	Value    string
+	Required bool
+	ReadOnly bool
+}
+
+// fields describes a form for the table. row is nil for a new row; on
+// edit, primary key columns are read-only.
+func (t *adminTable) fields(row []storage.Value, submitted url.Values) []adminField {
+	fields := make([]adminField, len(t.Schema.Columns))
+	for i, col := range t.Schema.Columns {
+		f := adminField{
+			Name:     col.Name,
+			Param:    fieldPrefix + col.Name,
+			Type:     col.Type.String(),
+			Input:    "text",
+			Required: col.NotNull && col.Default == nil,
+		}
+		switch col.Type {
+		case storage.TypeInteger:
+			f.Input, f.Step = "number", "1"
+		case storage.TypeFloat:
+			f.Input, f.Step = "number", "any"
+		case storage.TypeBoolean:
+			f.Input = "bool"
+		}
+
+		switch {
+		case submitted != nil:
+			f.Value = submitted.Get(f.Param)
+		case row != nil:
+			if _, null := row[i].(storage.NullValue); !null {
+				f.Value = row[i].ToString()
+			}
+		case col.Default != nil:
+			f.Value = col.Default.ToString()
+		}
+
+		if col.PrimaryKey {
+			if row != nil {
+				f.ReadOnly = true
+			} else if col.Type == storage.TypeInteger {
+				// Left empty, the engine assigns the next id.
+				f.Required = false
+			}
+		}
+		fields[i] = f
+	}
+	return fields
+}
+
+type adminCell struct {
+	Value string
+	Null  bool
+}
+
+type adminColumn struct {
+	Name    string
+	SortURL string
+}
+
+type adminRow struct {
+	Cells   []adminCell
+	EditURL string
+	PK      []string
+}
+
+func renderAdmin(w http.ResponseWriter, status int, name string, data interface{}) {
+	render(w, status, "admin_"+name, data)
+}
+
+func handleAdminTables(w http.ResponseWriter, req *http.Request) {
+	type tableInfo struct {
+		Name    string
+		Columns int
+		Rows    int
+	}
+
+	names := db.ListTables()
+	sort.Strings(names)
+
+	tables := make([]tableInfo, 0, len(names))
+	for _, name := range names {
+		t, err := db.GetTable(name)
+		if err != nil {
+			continue
+		}
+		tables = append(tables, tableInfo{Name: name, Columns: len(t.Schema().Columns), Rows: t.Count()})
+	}
+	renderAdmin(w, http.StatusOK, "tables", tables)
+}
+
+func handleAdminRows(w http.ResponseWriter, req *http.Request) {
+	t, err := loadAdminTable(req.URL.Query().Get("table"))
+	if err != nil {
+		renderError(w, req, http.StatusNotFound, err.Error())
+		return
+	}
+	page, limit, err := parsePage(req)
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	defaultSort := ""
+	if len(t.PK) > 0 {
+		defaultSort = t.PK[0].Name
+	}
+	lq, err := parseListQuery(req, t.Schema, defaultSort)
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := executeSQLWithResult("SELECT * FROM "+t.Name+lq.Where+pageClause(lq.OrderBy, page, limit), lq.Params...)
+	if err != nil {
+		renderError(w, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+	total, err := lq.count(t.Name)
+	if err != nil {
+		renderError(w, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rows := make([]adminRow, len(result.Values))
+	for i, values := range result.Values {
+		cells := make([]adminCell, len(values))
+		for j, v := range values {
+			_, null := v.(storage.NullValue)
+			cells[j] = adminCell{Value: v.ToString(), Null: null}
+		}
+		rows[i] = adminRow{Cells: cells}
+		if len(t.PK) > 0 {
+			key := t.keyQuery(values)
+			rows[i].EditURL = "/admin/edit?" + key.Encode()
+			rows[i].PK = key["pk"]
+		}
+	}
+
+	// Paging and sorting links keep the current filters.
+	pageURL := func(p int) string {
+		q := req.URL.Query()
+		q.Set("page", fmt.Sprint(p))
+		q.Set("limit", fmt.Sprint(limit))
+		return "/admin/rows?" + q.Encode()
+	}
+	// Exports cover every page of the current filters.
+	exportURL := func(format string) string {
+		q := req.URL.Query()
+		q.Del("page")
+		q.Del("limit")
+		q.Set("format", format)
+		return "/export?" + q.Encode()
+	}
+	sortParam := req.URL.Query().Get("sort")
+	columns := make([]adminColumn, len(t.Schema.Columns))
+	for i, col := range t.Schema.Columns {
+		q := req.URL.Query()
+		q.Del("page")
+		q.Set("sort", col.Name)
+		if sortParam == col.Name {
+			q.Set("sort", "-"+col.Name)
+		}
+		columns[i] = adminColumn{Name: col.Name, SortURL: "/admin/rows?" + q.Encode()}
+	}
+
+	data := map[string]interface{}{
+		"Table":    t.Name,
+		"Columns":  columns,
+		"Search":   req.URL.Query().Get("q"),
+		"Sort":     sortParam,
+		"Rows":     rows,
+		"Editable": len(t.PK) > 0,
+		"Page":     page,
+		"Total":    total,
+		"NewURL":   "/admin/new?" + url.Values{"table": {t.Name}}.Encode(),
+		"CSRF":     csrfToken(w, req),
+		"CSVURL":   exportURL("csv"),
+		"JSONURL":  exportURL("json"),
+	}
+	if page > 1 {
+		data["PrevURL"] = pageURL(page - 1)
+	}
+	if page*limit < total {
+		data["NextURL"] = pageURL(page + 1)
+	}
+	renderAdmin(w, http.StatusOK, "rows", data)
+}
+
+func renderAdminForm(w http.ResponseWriter, req *http.Request, status int, t *adminTable, row []storage.Value, pk []string, submitted url.Values, formErr error) {
+	data := map[string]interface{}{
+		"Table":  t.Name,
+		"Title":  "New row in " + t.Name,
+		"Action": "/admin/create",
+		"PK":     pk,
+		"Fields": t.fields(row, submitted),
+		"CSRF":   csrfToken(w, req),
+	}
+	if pk != nil {
+		data["Title"] = "Edit row in " + t.Name
+		data["Action"] = "/admin/update"
+	}
+	if formErr != nil {
+		data["Error"] = formErr.Error()
+	}
+	renderAdmin(w, status, "form", data)
+}
+
+func handleAdminNew(w http.ResponseWriter, req *http.Request) {
+	t, err := loadAdminTable(req.URL.Query().Get("table"))
+	if err != nil {
+		renderError(w, req, http.StatusNotFound, err.Error())
+		return
+	}
+	renderAdminForm(w, req, http.StatusOK, t, nil, nil, nil, nil)
+}
+
+func handleAdminCreate(w http.ResponseWriter, req *http.Request) {
+	t, err := loadAdminTable(req.PostFormValue("table"))
+	if err != nil {
+		renderError(w, req, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// Empty fields are left out so column defaults and generated ids apply.
+	columns := make([]string, 0, len(t.Schema.Columns))
+	params := make([]storage.Value, 0, len(t.Schema.Columns))
+	for _, col := range t.Schema.Columns {
+		s := req.PostFormValue(fieldPrefix + col.Name)
+		if s == "" && !(col.Type == storage.TypeText && col.NotNull) {
+			continue
+		}
+		v, err := formValue(col, s)
+		if err != nil {
+			renderAdminForm(w, req, http.StatusBadRequest, t, nil, nil, req.PostForm, err)
+			return
+		}
+		columns = append(columns, col.Name)
+		params = append(params, v)
+	}
+	if len(columns) == 0 {
+		renderAdminForm(w, req, http.StatusBadRequest, t, nil, nil, req.PostForm, fmt.Errorf("fill in at least one column"))
+		return
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.Name, strings.Join(columns, ", "), placeholders)
+	if _, err := executeSQLWithResult(stmt, params...); err != nil {
+		renderAdminForm(w, req, http.StatusBadRequest, t, nil, nil, req.PostForm, err)
+		return
+	}
+	notifyChange(t.Name)
+
+	http.Redirect(w, req, "/admin/rows?"+url.Values{"table": {t.Name}}.Encode(), http.StatusSeeOther)
+}
+
+func handleAdminEdit(w http.ResponseWriter, req *http.Request) {
+	t, err := loadAdminTable(req.URL.Query().Get("table"))
+	if err != nil {
+		renderError(w, req, http.StatusNotFound, err.Error())
+		return
+	}
+
+	pk := req.URL.Query()["pk"]
+	row, err := t.load(pk)
+	if err != nil {
+		renderError(w, req, http.StatusNotFound, err.Error())
+		return
+	}
+	renderAdminForm(w, req, http.StatusOK, t, row, pk, nil, nil)
+}
+
+func handleAdminUpdate(w http.ResponseWriter, req *http.Request) {
+	t, err := loadAdminTable(req.PostFormValue("table"))
+	if err != nil {
+		renderError(w, req, http.StatusNotFound, err.Error())
+		return
+	}
+	pk := req.PostForm["pk"]
+	where, keyParams, err := t.keyCondition(pk)
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	assignments := make([]string, 0, len(t.Schema.Columns))
+	params := make([]storage.Value, 0, len(t.Schema.Columns)+len(keyParams))
+	for _, col := range t.Schema.Columns {
+		if col.PrimaryKey {
+			continue
+		}
+		v, err := formValue(col, req.PostFormValue(fieldPrefix+col.Name))
+		if err != nil {
+			renderAdminForm(w, req, http.StatusBadRequest, t, nil, pk, req.PostForm, err)
+			return
+		}
+		assignments = append(assignments, col.Name+" = ?")
+		params = append(params, v)
+	}
+
+	if len(assignments) > 0 {
+		stmt := "UPDATE " + t.Name + " SET " + strings.Join(assignments, ", ") + where
+		if _, err := executeSQLWithResult(stmt, append(params, keyParams...)...); err != nil {
+			renderAdminForm(w, req, http.StatusBadRequest, t, nil, pk, req.PostForm, err)
+			return
+		}
+		notifyChange(t.Name)
+	}
+
+	http.Redirect(w, req, "/admin/rows?"+url.Values{"table": {t.Name}}.Encode(), http.StatusSeeOther)
+}
+
+func handleAdminDelete(w http.ResponseWriter, req *http.Request) {
+	t, err := loadAdminTable(req.PostFormValue("table"))
+	if err != nil {
+		renderError(w, req, http.StatusNotFound, err.Error())
+		return
+	}
+	where, params, err := t.keyCondition(req.PostForm["pk"])
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := executeSQLWithResult("DELETE FROM "+t.Name+where, params...); err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+	notifyChange(t.Name)
+
+	http.Redirect(w, req, "/admin/rows?"+url.Values{"table": {t.Name}}.Encode(), http.StatusSeeOther)
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// The v1 API serves users and tasks to programmatic clients:
+//
+//	GET    /api/v1/tasks        list, with the paging and filters of /tasks
+//	GET    /api/v1/tasks/{id}   one task
+//	POST   /api/v1/tasks        create; 201 with Location and the new task
+//	PUT    /api/v1/tasks/{id}   replace every field; 200 with the task
+//	DELETE /api/v1/tasks/{id}   204
+//
+// Errors are {"error": "..."}, plus "fields" for validation failures
+// (422). Writes need a signed-in session or HTTP Basic credentials for a
+// database user. Instead of CSRF tokens, request bodies must be
+// application/json, which another site cannot send without a CORS
+// preflight (DELETE always needs one).
+
+const maxAPIBody = 1 << 20
+
+var errNotFound = errors.New("not found")
+
+var taskStatuses = []string{"pending", "in_progress", "completed"}
+
+func validTaskStatus(status string) bool {
+	for _, s := range taskStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validationError maps JSON field names to what is wrong with them.
+type validationError map[string]string
+
+func (e validationError) Error() string {
+	return "validation failed"
+}
+
+type apiResource struct {
+	list  http.HandlerFunc
+	load  func(id string) (interface{}, error)
+	write func(id storage.Value, body *json.Decoder) (int64, error)
+	table string
+}
+
+var apiResources = map[string]*apiResource{
+	"users": {
+		list:  handleUsers,
+		load:  func(id string) (interface{}, error) { return getUser(id) },
+		write: writeUser,
+		table: "users",
+	},
+	"tasks": {
+		list:  handleTasks,
+		load:  func(id string) (interface{}, error) { return getTask(id) },
+		write: writeTask,
+		table: "tasks",
+	},
+}
+
+func handleAPI(w http.ResponseWriter, req *http.Request) {
+	name, id, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/api/v1/"), "/")
+	res, ok := apiResources[name]
+	if !ok || strings.Contains(id, "/") {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "no such resource: " + req.URL.Path})
+		return
+	}
+
+	allow := "GET, PUT, DELETE"
+	if id == "" {
+		allow = "GET, POST"
+	}
+	switch {
+	case req.Method == http.MethodGet || req.Method == http.MethodHead:
+		if id == "" {
+			res.list(w, req)
+		} else {
+			res.get(w, id)
+		}
+		return
+	case req.Method == http.MethodPost && id == "",
+		req.Method == http.MethodPut && id != "",
+		req.Method == http.MethodDelete && id != "":
+	default:
+		w.Header().Set("Allow", allow)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: req.Method + " is not allowed here (allowed: " + allow + ")"})
+		return
+	}
+
+	user := apiUser(req)
+	if user == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="rdbms"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "sign in or send HTTP Basic credentials to make changes"})
+		return
+	}
+
+	switch req.Method {
+	case http.MethodPost:
+		res.create(w, req, name)
+	case http.MethodPut:
+		res.update(w, req, id)
+	case http.MethodDelete:
+		res.remove(w, id)
+	}
+}
+
+// apiUser returns the session user or the user named by valid HTTP Basic
+// credentials, or "".
+func apiUser(req *http.Request) string {
+	if user := currentUser(req); user != "" {
+		return user
+	}
+	if name, password, ok := req.BasicAuth(); ok && db.Authenticate(name, password) {
+		return name
+	}
+	return ""
+}
+
+func (res *apiResource) get(w http.ResponseWriter, id string) {
+	row, err := res.load(id)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, row)
+}
+
+func (res *apiResource) create(w http.ResponseWriter, req *http.Request, name string) {
+	body, err := jsonBody(w, req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	newID, err := res.write(nil, body)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	notifyChange(res.table)
+
+	id := strconv.FormatInt(newID, 10)
+	row, err := res.load(id)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/"+name+"/"+id)
+	writeJSON(w, http.StatusCreated, row)
+}
+
+func (res *apiResource) update(w http.ResponseWriter, req *http.Request, id string) {
+	if _, err := res.load(id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	body, err := jsonBody(w, req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	param, _ := parseID(id)
+	if _, err := res.write(param, body); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	notifyChange(res.table)
+	res.get(w, id)
+}
+
+func (res *apiResource) remove(w http.ResponseWriter, id string) {
+	if _, err := res.load(id); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	param, _ := parseID(id)
+	if _, err := executeSQLWithResult("DELETE FROM "+res.table+" WHERE id = ?", param); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	notifyChange(res.table)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// jsonBody checks the request is JSON and returns a decoder that rejects
+// unknown fields.
+func jsonBody(w http.ResponseWriter, req *http.Request) (*json.Decoder, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return nil, &apiError{status: http.StatusUnsupportedMediaType, message: "request body must be application/json"}
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxAPIBody))
+	decoder.DisallowUnknownFields()
+	return decoder, nil
+}
+
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// decode reads one JSON value from body into v.
+func decode(body *json.Decoder, v interface{}) error {
+	if err := body.Decode(v); err != nil {
+		return &apiError{status: http.StatusBadRequest, message: "invalid JSON body: " + err.Error()}
+	}
+	return nil
+}
+
+// writeAPIError picks the status for err; failed statements go through
+// sqlErrorStatus.
+func writeAPIError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	var invalid validationError
+	switch {
+	case errors.As(err, &apiErr):
+		writeJSON(w, apiErr.status, errorResponse{Error: apiErr.message})
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: invalid.Error(), Fields: invalid})
+	case errors.Is(err, errNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid id"):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	default:
+		status := sqlErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			logger.Error("API request failed", "error", err)
+		}
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+	}
+}
+
+type userInput struct {
+	ID    *int64 `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// writeUser inserts a user, or replaces user id if it is not nil, and
+// returns the user's id.
+func writeUser(id storage.Value, body *json.Decoder) (int64, error) {
+	var in userInput
+	if err := decode(body, &in); err != nil {
+		return 0, err
+	}
+
+	invalid := validationError{}
+	checkID(invalid, id, in.ID)
+	if strings.TrimSpace(in.Name) == "" {
+		invalid["name"] = "is required"
+	}
+	if in.Email == "" {
+		invalid["email"] = "is required"
+	} else if addr, err := mail.ParseAddress(in.Email); err != nil || addr.Address != in.Email {
+		invalid["email"] = "must be an email address"
+	}
+	if len(invalid) > 0 {
+		return 0, invalid
+	}
+
+	if id == nil {
+		result, err := executeSQLWithResult("INSERT INTO users (name, email) VALUES (?, ?)", text(in.Name), text(in.Email))
+		if err != nil {
+			return 0, err
+		}
+		return result.LastInsertID, nil
+	}
+	_, err := executeSQLWithResult("UPDATE users SET name = ?, email = ? WHERE id = ?", text(in.Name), text(in.Email), id)
+	return 0, err
+}
+
+type taskInput struct {
+	ID          *int64 `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+	UserID      *int64 `json:"user_id"`
+}
+
+// writeTask inserts a task, or replaces task id if it is not nil, and
+// returns the task's id. Status defaults to pending; a null user_id
+// leaves the task unassigned.
+func writeTask(id storage.Value, body *json.Decoder) (int64, error) {
+	var in taskInput
+	if err := decode(body, &in); err != nil {
+		return 0, err
+	}
+	if in.Status == "" {
+		in.Status = "pending"
+	}
+
+	invalid := validationError{}
+	checkID(invalid, id, in.ID)
+	if strings.TrimSpace(in.Title) == "" {
+		invalid["title"] = "is required"
+	}
+	if !validTaskStatus(in.Status) {
+		invalid["status"] = "must be one of " + strings.Join(taskStatuses, ", ")
+	}
+	var userID storage.Value = storage.NullValue{}
+	if in.UserID != nil {
+		userID = storage.NewIntegerValue(*in.UserID)
+		if _, err := getUser(strconv.FormatInt(*in.UserID, 10)); errors.Is(err, errNotFound) {
+			invalid["user_id"] = fmt.Sprintf("no user with id %d", *in.UserID)
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	if len(invalid) > 0 {
+		return 0, invalid
+	}
+
+	if id == nil {
+		result, err := executeSQLWithResult("INSERT INTO tasks (title, description, status, user_id) VALUES (?, ?, ?, ?)",
+			text(in.Title), text(in.Description), text(in.Status), userID)
+		if err != nil {
+			return 0, err
+		}
+		return result.LastInsertID, nil
+	}
+	_, err := executeSQLWithResult("UPDATE tasks SET title = ?, description = ?, status = ?, user_id = ? WHERE id = ?",
+		text(in.Title), text(in.Description), text(in.Status), userID, id)
+	return 0, err
+}
+
+// checkID rejects an "id" in the body unless it matches the URL; ids are
+// always assigned by the database.
+func checkID(invalid validationError, id storage.Value, bodyID *int64) {
+	if bodyID == nil {
+		return
+	}
+	if id == nil {
+		invalid["id"] = "is assigned by the server"
+	} else if urlID, ok := id.(*storage.IntegerValue); !ok || urlID.Value != *bodyID {
+		invalid["id"] = "does not match the URL"
+	}
+}
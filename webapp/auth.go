@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sign-in checks the database's own users (CREATE USER). Any signed-in
+// user may change data; read-only pages stay public.
+
+const (
+	sessionCookie = "rdbms_session"
+	sessionTTL    = 12 * time.Hour
+)
+
+type webSession struct {
+	user    string
+	expires time.Time
+}
+
+// sessionStore keeps signed-in sessions in memory, keyed by a random
+// token sent to the browser as a cookie.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*webSession
+}
+
+var sessions = &sessionStore{sessions: make(map[string]*webSession)}
+
+func (s *sessionStore) create(user string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[token] = &webSession{user: user, expires: time.Now().Add(sessionTTL)}
+	return token, nil
+}
+
+// lookup returns the user signed in with token and extends the session.
+func (s *sessionStore) lookup(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[token]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(session.expires) {
+		delete(s.sessions, token)
+		return "", false
+	}
+	session.expires = time.Now().Add(sessionTTL)
+	return session.user, true
+}
+
+func (s *sessionStore) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+type userContextKey struct{}
+
+// currentUser returns the signed-in user for req, or "".
+func currentUser(req *http.Request) string {
+	if user, ok := req.Context().Value(userContextKey{}).(string); ok {
+		return user
+	}
+	cookie, err := req.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+	user, _ := sessions.lookup(cookie.Value)
+	return user
+}
+
+// requireLogin sends visitors who are not signed in to /login, returning
+// them to the page they asked for afterwards.
+func requireLogin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user := currentUser(req)
+		if user == "" {
+			target := req.URL.RequestURI()
+			if req.Method != http.MethodGet || isFragment(req) {
+				target = req.Referer()
+			}
+			http.Redirect(w, req, "/login?"+url.Values{"next": {safeRedirect(target)}}.Encode(), http.StatusSeeOther)
+			return
+		}
+		next(w, req.WithContext(context.WithValue(req.Context(), userContextKey{}, user)))
+	}
+}
+
+// safeRedirect only allows local paths, so ?next= cannot send users to
+// another site.
+func safeRedirect(target string) string {
+	if u, err := url.Parse(target); err == nil && u.Host == "" && u.Scheme == "" {
+		target = u.RequestURI()
+	}
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
+
+func renderLogin(w http.ResponseWriter, req *http.Request, status int, next, user, errMsg string) {
+	csrf := csrfToken(w, req)
+	render(w, status, "login.html", map[string]string{"Next": next, "User": user, "Error": errMsg, "CSRF": csrf})
+}
+
+func handleLogin(w http.ResponseWriter, req *http.Request) {
+	next := safeRedirect(req.FormValue("next"))
+	if req.Method != "POST" {
+		renderLogin(w, req, http.StatusOK, next, "", "")
+		return
+	}
+
+	user := req.PostFormValue("user")
+	if !db.Authenticate(user, req.PostFormValue("password")) {
+		logger.Warn("failed sign-in", "user", user, "remote", req.RemoteAddr)
+		renderLogin(w, req, http.StatusUnauthorized, next, user, "Invalid user name or password")
+		return
+	}
+
+	token, err := sessions.create(user)
+	if err != nil {
+		renderError(w, req, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	logger.Info("signed in", "user", user)
+
+	http.Redirect(w, req, next, http.StatusSeeOther)
+}
+
+func handleLogout(w http.ResponseWriter, req *http.Request) {
+	if cookie, err := req.Cookie(sessionCookie); err == nil {
+		sessions.remove(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	http.Redirect(w, req, "/", http.StatusSeeOther)
+}
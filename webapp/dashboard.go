@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// The dashboard's counts come from GROUP BY/COUNT queries, so they
+// exercise the engine's aggregates rather than counting rows in Go.
+
+const recentActivityLimit = 10
+
+type statusCount struct {
+	Status string
+	Count  int
+}
+
+type userCount struct {
+	ID        int
+	Name      string
+	Count     int
+	Completed int
+}
+
+type activity struct {
+	Time    time.Time
+	Table   string
+	Op      storage.WALOp
+	Summary string
+}
+
+func handleDashboard(w http.ResponseWriter, req *http.Request) {
+	totals, err := executeSQLWithResult("SELECT COUNT(*), COUNT(user_id) FROM tasks")
+	if err != nil {
+		renderError(w, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+	total, _ := strconv.Atoi(totals.Rows[0][0])
+	assigned, _ := strconv.Atoi(totals.Rows[0][1])
+
+	byStatus, err := tasksPerStatus()
+	if err != nil {
+		renderError(w, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+	byUser, err := tasksPerUser()
+	if err != nil {
+		renderError(w, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	render(w, http.StatusOK, "dashboard.html", map[string]interface{}{
+		"Total":      total,
+		"Unassigned": total - assigned,
+		"ByStatus":   byStatus,
+		"ByUser":     byUser,
+		"Recent":     recentActivity(recentActivityLimit),
+	})
+}
+
+func tasksPerStatus() ([]statusCount, error) {
+	result, err := executeSQLWithResult("SELECT status, COUNT(*) FROM tasks GROUP BY status ORDER BY status")
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]statusCount, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		n, _ := strconv.Atoi(row[1])
+		counts = append(counts, statusCount{Status: row[0], Count: n})
+	}
+	return counts, nil
+}
+
+// tasksPerUser lists every user, including those without tasks, busiest
+// first, with how many of their tasks are completed.
+func tasksPerUser() ([]userCount, error) {
+	result, err := executeSQLWithResult("SELECT u.id, u.name, COUNT(t.id), COUNT(t.id) FILTER (WHERE t.status = 'completed') FROM users u LEFT JOIN tasks t ON t.user_id = u.id GROUP BY u.id, u.name ORDER BY COUNT(t.id) DESC")
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]userCount, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		id, _ := strconv.Atoi(row[0])
+		n, _ := strconv.Atoi(row[2])
+		completed, _ := strconv.Atoi(row[3])
+		counts = append(counts, userCount{ID: id, Name: row[1], Count: n, Completed: completed})
+	}
+	return counts, nil
+}
+
+// recentActivity returns the latest row changes to users and tasks from
+// the write-ahead log, newest first.
+func recentActivity(limit int) []activity {
+	entries := db.WAL().Since(0)
+	recent := make([]activity, 0, limit)
+	for i := len(entries) - 1; i >= 0 && len(recent) < limit; i-- {
+		events := entries[i].Events()
+		for j := len(events) - 1; j >= 0 && len(recent) < limit; j-- {
+			event := events[j]
+			if event.Table != "users" && event.Table != "tasks" || event.Before == nil && event.After == nil {
+				continue
+			}
+			row := event.After
+			if row == nil {
+				row = event.Before
+			}
+			recent = append(recent, activity{
+				Time:    event.Time,
+				Table:   event.Table,
+				Op:      event.Op,
+				Summary: activitySummary(event.Table, row),
+			})
+		}
+	}
+	return recent
+}
+
+func activitySummary(table string, row map[string]storage.Value) string {
+	label := "name"
+	if table == "tasks" {
+		label = "title"
+	}
+	summary := ""
+	if id, ok := row["id"]; ok {
+		summary = "#" + id.ToString()
+	}
+	if v, ok := row[label]; ok {
+		summary += " " + v.ToString()
+	}
+	return summary
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// The index page changes rows in place: forms and links marked
+// data-fragment are sent by /static/app.js with an X-Fragment header, and
+// the handlers answer with the HTML of the changed row (templates/rows.html) (or nothing, for a
+// delete) instead of redirecting. Errors come back as a short HTML message
+// that the script shows above the tables. Without JavaScript the same
+// forms post normally and redirect.
+
+const fragmentHeader = "X-Fragment"
+
+// rowData is what the row templates render: one user or task, the CSRF
+// token for its forms and, for edit rows, the users to assign a task to.
+type rowData struct {
+	Row   interface{}
+	CSRF  string
+	Users []User
+}
+
+// isFragment reports whether req came from app.js and wants a fragment
+// back rather than a page or a redirect.
+func isFragment(req *http.Request) bool {
+	return req.Header.Get(fragmentHeader) != ""
+}
+
+// mutationDone finishes a form submission: fragment requests get the
+// changed row (or an empty body when row is ""), others go back to the
+// index.
+func mutationDone(w http.ResponseWriter, req *http.Request, row, id string) {
+	if !isFragment(req) {
+		http.Redirect(w, req, "/", http.StatusSeeOther)
+		return
+	}
+	switch row {
+	case "user_row":
+		renderUserRow(w, req, id, row)
+	case "task_row":
+		renderTaskRow(w, req, id, row)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func handleUserRow(w http.ResponseWriter, req *http.Request) {
+	renderUserRow(w, req, req.URL.Query().Get("id"), "user_row")
+}
+
+func handleUserEditRow(w http.ResponseWriter, req *http.Request) {
+	renderUserRow(w, req, req.URL.Query().Get("id"), "user_edit_row")
+}
+
+func renderUserRow(w http.ResponseWriter, req *http.Request, id, name string) {
+	user, err := getUser(id)
+	if err != nil {
+		renderError(w, req, http.StatusNotFound, err.Error())
+		return
+	}
+	render(w, http.StatusOK, name, rowData{Row: user, CSRF: csrfToken(w, req)})
+}
+
+func handleTaskRow(w http.ResponseWriter, req *http.Request) {
+	renderTaskRow(w, req, req.URL.Query().Get("id"), "task_row")
+}
+
+func handleTaskEditRow(w http.ResponseWriter, req *http.Request) {
+	renderTaskRow(w, req, req.URL.Query().Get("id"), "task_edit_row")
+}
+
+func renderTaskRow(w http.ResponseWriter, req *http.Request, id, name string) {
+	param, err := parseID(id)
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+	tasks, err := queryTasksWithUsers(" WHERE t.id = ?", param)
+	if err != nil {
+		renderError(w, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(tasks) == 0 {
+		renderError(w, req, http.StatusNotFound, fmt.Sprintf("task %s not found", id))
+		return
+	}
+	data := rowData{Row: tasks[0], CSRF: csrfToken(w, req)}
+	if name == "task_edit_row" {
+		data.Users = getUsers()
+	}
+	render(w, http.StatusOK, name, data)
+}
+
+// handleTaskStatus changes only a task's status, from the select in each
+// task row.
+func handleTaskStatus(w http.ResponseWriter, req *http.Request) {
+	id, err := parseID(req.PostFormValue("id"))
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := req.PostFormValue("status")
+	if !validTaskStatus(status) {
+		renderError(w, req, http.StatusUnprocessableEntity, fmt.Sprintf("unknown status %q", status))
+		return
+	}
+
+	if _, err := executeSQLWithResult("UPDATE tasks SET status = ? WHERE id = ?", text(status), id); err != nil {
+		renderError(w, req, sqlErrorStatus(err), err.Error())
+		return
+	}
+	notifyChange("tasks")
+	mutationDone(w, req, "task_row", req.PostFormValue("id"))
+}
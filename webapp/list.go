@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// reservedParams are query parameters with their own meaning on list
+// pages, so they are never treated as column filters.
+var reservedParams = map[string]bool{
+	"page": true, "limit": true, "sort": true, "q": true, "table": true, "pk": true, "format": true,
+}
+
+// listQuery is the WHERE and ORDER BY for a list page, built from its
+// query string:
+//
+//	?status=pending   rows whose status column equals "pending"
+//	?q=review         rows with "review" in any text column (ILIKE)
+//	?sort=-title,id   ORDER BY title DESC, id
+//
+// Column names are checked against the schema; values are always bound.
+type listQuery struct {
+	Where   string
+	Params  []storage.Value
+	OrderBy string
+}
+
+func parseListQuery(req *http.Request, schema *storage.Schema, defaultSort string) (*listQuery, error) {
+	query := req.URL.Query()
+	lq := &listQuery{}
+
+	// Empty values, as sent by a filter form left blank, do not filter.
+	conditions := make([]string, 0)
+	for _, col := range schema.Columns {
+		if reservedParams[col.Name] || query.Get(col.Name) == "" {
+			continue
+		}
+		v, err := storage.ParseValue(col.Type, query.Get(col.Name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", col.Name, err)
+		}
+		conditions = append(conditions, col.Name+" = ?")
+		lq.Params = append(lq.Params, v)
+	}
+
+	if q := query.Get("q"); q != "" {
+		pattern := "%" + escapeLike(q) + "%"
+		matches := make([]string, 0)
+		for _, col := range schema.Columns {
+			if col.Type == storage.TypeText {
+				matches = append(matches, col.Name+" ILIKE ?")
+				lq.Params = append(lq.Params, storage.NewTextValue(pattern))
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no text columns to search")
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+
+	if len(conditions) > 0 {
+		lq.Where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = defaultSort
+	}
+	keys := make([]string, 0)
+	for _, key := range strings.Split(sort, ",") {
+		name, desc := strings.CutPrefix(strings.TrimSpace(key), "-")
+		if name == "" {
+			continue
+		}
+		if _, ok := schema.GetColumn(name); !ok {
+			return nil, fmt.Errorf("cannot sort by unknown column %q", name)
+		}
+		if desc {
+			name += " DESC"
+		}
+		keys = append(keys, name)
+	}
+	lq.OrderBy = strings.Join(keys, ", ")
+
+	return lq, nil
+}
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// count returns how many rows of table match the query's filters.
+func (lq *listQuery) count(table string) (int, error) {
+	if lq.Where == "" {
+		return countRows(table)
+	}
+	schema, err := db.GetSchema(table)
+	if err != nil {
+		return 0, err
+	}
+	result, err := executeSQLWithResult("SELECT "+schema.Columns[0].Name+" FROM "+table+lq.Where, lq.Params...)
+	if err != nil {
+		return 0, err
+	}
+	return len(result.Rows), nil
+}
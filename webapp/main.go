@@ -1,107 +1,309 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
-	"text/template"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/mryan-3/rdbms/internal/sql"
 	"github.com/mryan-3/rdbms/internal/storage"
+	"github.com/mryan-3/rdbms/internal/websocket"
+)
+
+const (
+	changesChannel = "changes"
+
+	defaultPageSize = 50
+	maxPageSize     = 500
+
+	// shutdownTimeout is how long in-flight requests get to finish after
+	// SIGINT or SIGTERM.
+	shutdownTimeout = 10 * time.Second
 )
 
 var db *storage.Database
-var exec *sql.Executor
+var logger *slog.Logger
+
+// shuttingDown is closed when the server starts shutting down, ending
+// WebSocket connections that http.Server.Shutdown does not track.
+var shuttingDown = make(chan struct{})
 
 func main() {
+	logStatements := flag.Bool("log-statements", false, "Log every SQL statement with its duration")
+	jsonLogs := flag.Bool("log-json", false, "Write logs as JSON instead of text")
+	user := flag.String("user", "admin", "User allowed to sign in and edit, with the password from $RDBMS_PASSWORD (generated if unset)")
+	addr := flag.String("addr", ":8080", "Address to listen on")
+	dbPath := flag.String("db", "", "Keep the database in this file, loading it at startup and saving changes (in-memory only if empty)")
+	saveInterval := flag.Duration("save-interval", 5*time.Second, "How often changes are saved to -db")
+	flag.Parse()
+
+	if *jsonLogs {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	} else {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+
+	if err := loadTemplates(); err != nil {
+		logger.Error("failed to load templates", "error", err)
+		os.Exit(1)
+	}
+
 	db = storage.NewDatabase()
-	exec = sql.NewExecutor(db)
-
-	initSchema()
-
-	http.HandleFunc("/", handleIndex)
-	http.HandleFunc("/favicon.ico", handleFavicon)
-	http.HandleFunc("/users", handleUsers)
-	http.HandleFunc("/tasks", handleTasks)
-	http.HandleFunc("/users/new", handleUserForm)
-	http.HandleFunc("/tasks/new", handleTaskForm)
-	http.HandleFunc("/users/create", handleCreateUser)
-	http.HandleFunc("/tasks/create", handleCreateTask)
-	http.HandleFunc("/users/edit", handleEditUserForm)
-	http.HandleFunc("/tasks/edit", handleEditTaskForm)
-	http.HandleFunc("/users/update", handleUpdateUser)
-	http.HandleFunc("/tasks/update", handleUpdateTask)
-	http.HandleFunc("/users/delete", handleDeleteUser)
-	http.HandleFunc("/tasks/delete", handleDeleteTask)
-	http.HandleFunc("/static/style.css", handleStyleCSS)
-
-	fmt.Println("Server starting on http://localhost:8080")
-	fmt.Println("Press Ctrl+C to stop")
-	fmt.Println()
-	http.ListenAndServe(":8080", nil)
+	db.SetLogger(logger)
+	db.SetStatementLogging(*logStatements)
+
+	loaded := false
+	if *dbPath != "" {
+		var err error
+		if loaded, err = loadDatabase(*dbPath); err != nil {
+			logger.Error("failed to load database", "file", *dbPath, "error", err)
+			os.Exit(1)
+		}
+	}
+	if !loaded {
+		initSchema()
+	}
+	if err := initUser(*user, os.Getenv("RDBMS_PASSWORD")); err != nil {
+		logger.Error("failed to create user", "user", *user, "error", err)
+		os.Exit(1)
+	}
+
+	get, post := http.MethodGet, http.MethodPost
+	handle("/", handleIndex, get)
+	handle("/dashboard", handleDashboard, get)
+	handle("/favicon.ico", handleFavicon, get)
+	handle("/users", handleUsers, get)
+	handle("/tasks", handleTasks, get)
+	handle("/login", handleLogin, get, post)
+	handle("/logout", handleLogout, post)
+
+	// Anything that changes data requires a signed-in user and a POST.
+	handle("/users/new", requireLogin(handleUserForm), get)
+	handle("/tasks/new", requireLogin(handleTaskForm), get)
+	handle("/users/create", requireLogin(handleCreateUser), post)
+	handle("/tasks/create", requireLogin(handleCreateTask), post)
+	handle("/users/edit", requireLogin(handleEditUserForm), get)
+	handle("/tasks/edit", requireLogin(handleEditTaskForm), get)
+	handle("/users/update", requireLogin(handleUpdateUser), post)
+	handle("/tasks/update", requireLogin(handleUpdateTask), post)
+	handle("/users/delete", requireLogin(handleDeleteUser), post)
+	handle("/tasks/delete", requireLogin(handleDeleteTask), post)
+	handle("/tasks/status", requireLogin(handleTaskStatus), post)
+	handle("/users/row", handleUserRow, get)
+	handle("/tasks/row", handleTaskRow, get)
+	handle("/users/row/edit", requireLogin(handleUserEditRow), get)
+	handle("/tasks/row/edit", requireLogin(handleTaskEditRow), get)
+	handle("/admin", handleAdminTables, get)
+	handle("/admin/rows", handleAdminRows, get)
+	handle("/export", handleExport, get)
+	handle("/admin/new", requireLogin(handleAdminNew), get)
+	handle("/admin/create", requireLogin(handleAdminCreate), post)
+	handle("/admin/edit", requireLogin(handleAdminEdit), get)
+	handle("/admin/update", requireLogin(handleAdminUpdate), post)
+	handle("/admin/delete", requireLogin(handleAdminDelete), post)
+	handle("/static/", staticHandler(), get)
+	handle("/ws", handleWebSocket, get)
+
+	// The JSON API checks methods and credentials itself and takes no CSRF
+	// token; see api.go.
+	http.HandleFunc("/api/v1/", recoverErrors(handleAPI))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var store *persister
+	if *dbPath != "" {
+		store = newPersister(*dbPath)
+		if !loaded {
+			store.save()
+		}
+		go store.run(ctx, *saveInterval)
+	}
+
+	server := &http.Server{Addr: *addr}
+	server.RegisterOnShutdown(func() { close(shuttingDown) })
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		logger.Info("shutting down, waiting for requests to finish")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("requests still running at shutdown", "error", err)
+		}
+	}()
+
+	logger.Info("server starting", "addr", *addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+	<-drained
+
+	if store != nil {
+		if err := store.save(); err != nil {
+			os.Exit(1)
+		}
+		logger.Info("saved database", "file", *dbPath)
+	}
+	logger.Info("server stopped")
 }
 
 func initSchema() {
-	statements := []string{
-		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT UNIQUE);",
-		"CREATE TABLE tasks (id INTEGER PRIMARY KEY, title TEXT NOT NULL, description TEXT, status TEXT DEFAULT 'pending', user_id INTEGER);",
-		"INSERT INTO users (id, name, email) VALUES (1, 'John Doe', 'john@example.com');",
-		"INSERT INTO users (id, name, email) VALUES (2, 'Jane Smith', 'jane@example.com');",
-		"INSERT INTO tasks (id, title, description, status, user_id) VALUES (1, 'Complete project', 'Finish RDBMS implementation', 'in_progress', 1);",
-		"INSERT INTO tasks (id, title, description, status, user_id) VALUES (2, 'Review code', 'Review pull request', 'pending', 2);",
-	}
-
-	for _, stmt := range statements {
-		_, err := executeSQLWithResult(stmt)
-		if err != nil {
-			fmt.Printf("Error initializing schema: %v\n", err)
+	const schema = `
+CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT UNIQUE);
+CREATE TABLE tasks (id INTEGER PRIMARY KEY, title TEXT NOT NULL, description TEXT, status TEXT DEFAULT 'pending', user_id INTEGER);
+INSERT INTO users (id, name, email) VALUES (1, 'John Doe', 'john@example.com');
+INSERT INTO users (id, name, email) VALUES (2, 'Jane Smith', 'jane@example.com');
+INSERT INTO tasks (id, title, description, status, user_id) VALUES (1, 'Complete project', 'Finish RDBMS implementation', 'in_progress', 1);
+INSERT INTO tasks (id, title, description, status, user_id) VALUES (2, 'Review code', 'Review pull request', 'pending', 2);
+`
+	session := sql.NewSession(db)
+	defer session.Close()
+	if _, err := session.ExecuteScript(context.Background(), schema, sql.ScriptOptions{ContinueOnError: true}); err != nil {
+		logger.Error("failed to initialize schema", "error", err)
+	}
+
+	logger.Info("database initialized with sample data")
+}
+
+// initUser creates the user that signs in to edit data. Without a
+// password, a random one is generated and logged.
+func initUser(name, password string) error {
+	if password == "" {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return err
 		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+		logger.Info("generated sign-in password; set $RDBMS_PASSWORD to choose one", "user", name, "password", password)
 	}
+	return db.CreateUser(name, password)
+}
 
-	fmt.Println("Database initialized with sample data")
-	fmt.Println()
+// executeSQL runs stmt with its ? placeholders bound to params, logging
+// any error.
+func executeSQL(stmt string, params ...storage.Value) {
+	if _, err := executeSQLWithResult(stmt, params...); err != nil {
+		logger.Error("failed to execute SQL", "statement", stmt, "error", err)
+	}
 }
 
-func executeSQL(stmt string) {
-	lexer := sql.NewLexer(stmt)
-	parser := sql.NewParser(lexer)
+// sqlErrorStatus picks the HTTP status for a failed statement from its
+// error kind.
+func sqlErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, sql.ErrNotNullViolation), errors.Is(err, sql.ErrTypeMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, sql.ErrConstraintViolation):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
 
-	node, err := parser.Parse()
+// executeSQLWithResult runs stmt with its ? placeholders bound to params.
+// Form input must always be passed as params, never formatted into stmt,
+// which also lets the plan cache reuse the parsed statement. Each call
+// runs in a session of its own, so concurrent handlers never share
+// transaction state.
+func executeSQLWithResult(stmt string, params ...storage.Value) (*sql.Result, error) {
+	node, _, err := sql.ParseContext(context.Background(), stmt)
 	if err != nil {
-		fmt.Printf("Error parsing SQL: %v\n", err)
-		return
+		return nil, err
 	}
 
-	_, err = exec.Execute(node)
+	session := sql.NewSession(db)
+	defer session.Close()
+
+	return session.ExecuteWithParams(node, params)
+}
+
+func text(s string) storage.Value {
+	return storage.NewTextValue(s)
+}
+
+// parseID converts an id from a form or query string to a parameter.
+func parseID(s string) (storage.Value, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
 	if err != nil {
-		fmt.Printf("Error executing SQL: %v\n", err)
+		return nil, fmt.Errorf("invalid id %q", s)
 	}
+	return storage.NewIntegerValue(id), nil
 }
 
-func executeSQLWithResult(stmt string) (*sql.Result, error) {
-	lexer := sql.NewLexer(stmt)
-	parser := sql.NewParser(lexer)
+// parseOptionalID is parseID with "" meaning NULL.
+func parseOptionalID(s string) (storage.Value, error) {
+	if s == "" {
+		return storage.NullValue{}, nil
+	}
+	return parseID(s)
+}
 
-	node, err := parser.Parse()
+// notifyChange tells connected browsers that table has changed.
+func notifyChange(table string) {
+	executeSQL("NOTIFY "+changesChannel+", ?", text(table))
+}
+
+func handleWebSocket(w http.ResponseWriter, req *http.Request) {
+	conn, err := websocket.Upgrade(w, req)
 	if err != nil {
-		return nil, err
+		return
+	}
+	defer conn.Close()
+
+	listener := db.NewListener()
+	defer listener.Close()
+	listener.Listen(changesChannel)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case n := <-listener.C:
+			if err := conn.WriteText(n.Payload); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-shuttingDown:
+			return
+		}
 	}
-
-	return exec.Execute(node)
 }
 
 type User struct {
-	ID    int
-	Name  string
-	Email string
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
 type Task struct {
-	ID          int
-	Title       string
-	Description string
-	Status      string
-	UserID      int
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+	UserID      int    `json:"user_id"`
 }
 
 type TaskWithUser struct {
@@ -116,11 +318,19 @@ type TaskWithUser struct {
 }
 
 func getUsers() []User {
-	result, err := executeSQLWithResult("SELECT id, name, email FROM users")
+	users, err := queryUsers("SELECT id, name, email FROM users")
 	if err != nil {
-		fmt.Printf("Error getting users: %v\n", err)
+		logger.Error("failed to load users", "error", err)
 		return []User{}
 	}
+	return users
+}
+
+func queryUsers(stmt string, params ...storage.Value) ([]User, error) {
+	result, err := executeSQLWithResult(stmt, params...)
+	if err != nil {
+		return nil, err
+	}
 
 	users := make([]User, 0)
 
@@ -133,14 +343,13 @@ func getUsers() []User {
 		})
 	}
 
-	return users
+	return users, nil
 }
 
-func getTasks() []Task {
-	result, err := executeSQLWithResult("SELECT id, title, description, status, user_id FROM tasks")
+func queryTasks(stmt string, params ...storage.Value) ([]Task, error) {
+	result, err := executeSQLWithResult(stmt, params...)
 	if err != nil {
-		fmt.Printf("Error getting tasks: %v\n", err)
-		return []Task{}
+		return nil, err
 	}
 
 	tasks := make([]Task, 0)
@@ -157,16 +366,25 @@ func getTasks() []Task {
 		})
 	}
 
-	return tasks
+	return tasks, nil
 }
 
 func getTasksWithUsers() []TaskWithUser {
-	result, err := executeSQLWithResult("SELECT t.id, t.title, t.description, t.status, t.user_id, u.name, u.email FROM tasks t LEFT JOIN users u ON t.user_id = u.id")
-
+	tasks, err := queryTasksWithUsers("")
 	if err != nil {
-		fmt.Printf("Error getting tasks with users: %v\n", err)
+		logger.Error("failed to load tasks with users", "error", err)
 		return []TaskWithUser{}
 	}
+	return tasks
+}
+
+// queryTasksWithUsers loads tasks joined with their users; where filters
+// them and may refer to the tables as t and u.
+func queryTasksWithUsers(where string, params ...storage.Value) ([]TaskWithUser, error) {
+	result, err := executeSQLWithResult("SELECT t.id, t.title, t.description, t.status, t.user_id, u.name, u.email FROM tasks t LEFT JOIN users u ON t.user_id = u.id"+where, params...)
+	if err != nil {
+		return nil, err
+	}
 
 	tasks := make([]TaskWithUser, 0)
 
@@ -203,274 +421,240 @@ func getTasksWithUsers() []TaskWithUser {
 		})
 	}
 
-	return tasks
+	return tasks, nil
 }
 
 func handleIndex(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		renderError(w, req, http.StatusNotFound, "No page at "+req.URL.Path)
+		return
+	}
+
 	users := getUsers()
 	tasks := getTasksWithUsers()
 
-	tmpl := `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Task Manager - RDBMS Demo</title>
-    <link rel="stylesheet" href="/static/style.css">
-</head>
-<body>
-    <div class="container">
-        <h1>Task Manager</h1>
-        <p class="subtitle">Built with RDBMS - A simple relational database management system</p>
-
-        <div class="section">
-            <h2>Users</h2>
-            <table>
-                <thead>
-                    <tr>
-                        <th>ID</th>
-                        <th>Name</th>
-                        <th>Email</th>
-                        <th>Actions</th>
-                    </tr>
-                </thead>
-                <tbody>
-                    {{range .Users}}
-                    <tr>
-                        <td>{{.ID}}</td>
-                        <td>{{.Name}}</td>
-                        <td>{{.Email}}</td>
-                        <td>
-                            <a href="/users/edit?id={{.ID}}">Edit</a> |
-                            <a href="/users/delete?id={{.ID}}" onclick="return confirm('Are you sure?')">Delete</a>
-                        </td>
-                    </tr>
-                    {{end}}
-                </tbody>
-            </table>
-            <a href="/users/new" class="btn">Add User</a>
-        </div>
-
-        <div class="section">
-            <h2>Tasks</h2>
-            <table>
-                <thead>
-                    <tr>
-                        <th>ID</th>
-                        <th>Title</th>
-                        <th>Description</th>
-                        <th>Status</th>
-                        <th>Assigned To</th>
-                        <th>Actions</th>
-                    </tr>
-                </thead>
-                <tbody>
-                    {{range .Tasks}}
-                    <tr>
-                        <td>{{.ID}}</td>
-                        <td>{{.Title}}</td>
-                        <td>{{.Description}}</td>
-                        <td><span class="status {{.StatusClass}}">{{.Status}}</span></td>
-                        <td>{{.UserName}}</td>
-                        <td>
-                            <a href="/tasks/edit?id={{.ID}}">Edit</a> |
-                            <a href="/tasks/delete?id={{.ID}}" onclick="return confirm('Are you sure?')">Delete</a>
-                        </td>
-                    </tr>
-                    {{end}}
-                </tbody>
-            </table>
-            <a href="/tasks/new" class="btn">Add Task</a>
-        </div>
-
-        <div class="section">
-            <h2>Database Info</h2>
-            <pre>{{.DBInfo}}</pre>
-        </div>
-    </div>
-</body>
-</html>`
-
-	t, _ := template.New("index").Parse(tmpl)
-	dbInfo := fmt.Sprintf("Tables: %d\nTotal Users: %d\nTotal Tasks: %d",
-		len(db.ListTables()), len(users), len(tasks))
 	data := struct {
 		Users  []User
 		Tasks  []TaskWithUser
 		DBInfo string
+		User   string
+		CSRF   string
 	}{
 		Users:  users,
 		Tasks:  tasks,
-		DBInfo: dbInfo,
+		DBInfo: dbInfo(),
+		User:   currentUser(req),
+		CSRF:   csrfToken(w, req),
+	}
+	render(w, http.StatusOK, "index.html", data)
+}
+
+// dbInfo summarizes Database.Stats for the index page: the totals, then
+// a line per table.
+func dbInfo() string {
+	stats := db.Stats()
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tables: %d\nRows: %d\nSize: about %s\nIndexes: %d\n",
+		len(stats.Tables), stats.Rows, formatBytes(stats.Bytes), stats.Indexes)
+	if !stats.ModifiedAt.IsZero() {
+		fmt.Fprintf(&b, "Last modified: %s\n", stats.ModifiedAt.Format("2006-01-02 15:04:05"))
 	}
-	t.Execute(w, data)
+	for _, t := range stats.Tables {
+		fmt.Fprintf(&b, "\n%-12s %6d rows  %9s  %d index(es), depth %d",
+			t.Table, t.Rows, formatBytes(t.Bytes), t.Indexes, t.IndexDepth)
+	}
+	return b.String()
 }
 
-func handleUsers(w http.ResponseWriter, req *http.Request) {
-	users := getUsers()
+// formatBytes renders n as e.g. "512 B", "12.5 kB" or "4.0 MB".
+func formatBytes(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f kB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, "[")
-	for i, user := range users {
-		if i > 0 {
-			fmt.Fprintf(w, ",")
+// pageResponse is one page of a JSON list. Total counts every row, not
+// just those on the page.
+type pageResponse struct {
+	Data  interface{} `json:"data"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+	Total int         `json:"total"`
+}
+
+type errorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// parsePage reads ?page= (from 1) and ?limit= (up to maxPageSize).
+func parsePage(req *http.Request) (page, limit int, err error) {
+	page, limit = 1, defaultPageSize
+	if v := req.URL.Query().Get("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("invalid page %q", v)
+		}
+	}
+	if v := req.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("invalid limit %q: must be between 1 and %d", v, maxPageSize)
 		}
-		fmt.Fprintf(w, "{\"id\":%d,\"name\":\"%s\",\"email\":\"%s\"}", user.ID, user.Name, user.Email)
 	}
-	fmt.Fprintf(w, "]")
+	return page, limit, nil
+}
+
+// pageClause returns ORDER BY/LIMIT/OFFSET for a page, ordered by orderBy
+// if it is not empty. LIMIT and OFFSET only take integer literals; both
+// values are validated ints.
+func pageClause(orderBy string, page, limit int) string {
+	clause := fmt.Sprintf(" LIMIT %d OFFSET %d", limit, (page-1)*limit)
+	if orderBy != "" {
+		clause = " ORDER BY " + orderBy + clause
+	}
+	return clause
+}
+
+func countRows(table string) (int, error) {
+	t, err := db.GetTable(table)
+	if err != nil {
+		return 0, err
+	}
+	return t.Count(), nil
+}
+
+func handleUsers(w http.ResponseWriter, req *http.Request) {
+	page, limit, err := parsePage(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	schema, err := db.GetSchema("users")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	lq, err := parseListQuery(req, schema, "id")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	users, err := queryUsers("SELECT id, name, email FROM users"+lq.Where+pageClause(lq.OrderBy, page, limit), lq.Params...)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	total, err := lq.count("users")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pageResponse{Data: users, Page: page, Limit: limit, Total: total})
 }
 
 func handleTasks(w http.ResponseWriter, req *http.Request) {
-	tasks := getTasks()
+	page, limit, err := parsePage(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, "[")
-	for i, task := range tasks {
-		if i > 0 {
-			fmt.Fprintf(w, ",")
-		}
-		fmt.Fprintf(w, "{\"id\":%d,\"title\":\"%s\",\"description\":\"%s\",\"status\":\"%s\",\"user_id\":%d}",
-			task.ID, task.Title, task.Description, task.Status, task.UserID)
+	schema, err := db.GetSchema("tasks")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	lq, err := parseListQuery(req, schema, "id")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
 	}
-	fmt.Fprintf(w, "]")
+
+	tasks, err := queryTasks("SELECT id, title, description, status, user_id FROM tasks"+lq.Where+pageClause(lq.OrderBy, page, limit), lq.Params...)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	total, err := lq.count("tasks")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pageResponse{Data: tasks, Page: page, Limit: limit, Total: total})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
 }
 
 func handleUserForm(w http.ResponseWriter, req *http.Request) {
-	tmpl := `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Add User - RDBMS Demo</title>
-    <link rel="stylesheet" href="/static/style.css">
-</head>
-<body>
-    <div class="container">
-        <h1>Add User</h1>
-        <form method="POST" action="/users/create">
-            <div class="form-group">
-                <label for="name">Name:</label>
-                <input type="text" id="name" name="name" required>
-            </div>
-            <div class="form-group">
-                <label for="email">Email:</label>
-                <input type="email" id="email" name="email" required>
-            </div>
-            <div class="form-group">
-                <button type="submit" class="btn">Create User</button>
-                <a href="/" class="btn btn-secondary">Cancel</a>
-            </div>
-        </form>
-    </div>
-</body>
-</html>`
-
-	t, _ := template.New("user_form").Parse(tmpl)
-	t.Execute(w, nil)
+	render(w, http.StatusOK, "user_form.html", struct{ CSRF string }{csrfToken(w, req)})
 }
 
 func handleTaskForm(w http.ResponseWriter, req *http.Request) {
 	users := getUsers()
 
-	tmpl := `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Add Task - RDBMS Demo</title>
-    <link rel="stylesheet" href="/static/style.css">
-</head>
-<body>
-    <div class="container">
-        <h1>Add Task</h1>
-        <form method="POST" action="/tasks/create">
-            <div class="form-group">
-                <label for="title">Title:</label>
-                <input type="text" id="title" name="title" required>
-            </div>
-            <div class="form-group">
-                <label for="description">Description:</label>
-                <textarea id="description" name="description"></textarea>
-            </div>
-            <div class="form-group">
-                <label for="status">Status:</label>
-                <select id="status" name="status">
-                    <option value="pending">Pending</option>
-                    <option value="in_progress">In Progress</option>
-                    <option value="completed">Completed</option>
-                </select>
-            </div>
-            <div class="form-group">
-                <label for="user_id">Assign to:</label>
-                <select id="user_id" name="user_id">
-                    <option value="">Unassigned</option>
-                    {{range .Users}}
-                    <option value="{{.ID}}">{{.Name}} ({{.Email}})</option>
-                    {{end}}
-                </select>
-            </div>
-            <div class="form-group">
-                <button type="submit" class="btn">Create Task</button>
-                <a href="/" class="btn btn-secondary">Cancel</a>
-            </div>
-        </form>
-    </div>
-</body>
-</html>`
-
-	t, _ := template.New("task_form").Parse(tmpl)
-	t.Execute(w, struct{ Users []User }{users})
+	render(w, http.StatusOK, "task_form.html", struct {
+		Users []User
+		CSRF  string
+	}{users, csrfToken(w, req)})
 }
 
 func handleCreateUser(w http.ResponseWriter, req *http.Request) {
-	if req.Method != "POST" {
-		http.Redirect(w, req, "/users/new", http.StatusSeeOther)
-		return
-	}
-
 	name := req.FormValue("name")
 	email := req.FormValue("email")
 
-	stmt := fmt.Sprintf("INSERT INTO users (name, email) VALUES ('%s', '%s')", name, email)
-	executeSQL(stmt)
+	if _, err := executeSQLWithResult("INSERT INTO users (name, email) VALUES (?, ?)", text(name), text(email)); err != nil {
+		renderError(w, req, sqlErrorStatus(err), err.Error())
+		return
+	}
+	notifyChange("users")
 
 	http.Redirect(w, req, "/", http.StatusSeeOther)
 }
 
 func handleCreateTask(w http.ResponseWriter, req *http.Request) {
-	if req.Method != "POST" {
-		http.Redirect(w, req, "/tasks/new", http.StatusSeeOther)
-		return
-	}
-
 	title := req.FormValue("title")
 	description := req.FormValue("description")
 	status := req.FormValue("status")
-	userID := req.FormValue("user_id")
-
-	var stmt string
-	if userID == "" {
-		stmt = fmt.Sprintf("INSERT INTO tasks (title, description, status) VALUES ('%s', '%s', '%s')",
-			title, description, status)
-	} else {
-		stmt = fmt.Sprintf("INSERT INTO tasks (title, description, status, user_id) VALUES ('%s', '%s', '%s', %s)",
-			title, description, status, userID)
+	userID, err := parseOptionalID(req.FormValue("user_id"))
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	executeSQL(stmt)
+	_, err = executeSQLWithResult("INSERT INTO tasks (title, description, status, user_id) VALUES (?, ?, ?, ?)",
+		text(title), text(description), text(status), userID)
+	if err != nil {
+		renderError(w, req, sqlErrorStatus(err), err.Error())
+		return
+	}
+	notifyChange("tasks")
 
 	http.Redirect(w, req, "/", http.StatusSeeOther)
 }
 
 func getUser(id string) (*User, error) {
-	stmt := fmt.Sprintf("SELECT id, name, email FROM users WHERE id = %s", id)
-	result, err := executeSQLWithResult(stmt)
+	param, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	result, err := executeSQLWithResult("SELECT id, name, email FROM users WHERE id = ?", param)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(result.Rows) == 0 {
-		return nil, fmt.Errorf("user not found")
+		return nil, fmt.Errorf("user %w", errNotFound)
 	}
 
 	row := result.Rows[0]
@@ -486,69 +670,46 @@ func handleEditUserForm(w http.ResponseWriter, req *http.Request) {
 	id := req.URL.Query().Get("id")
 	user, err := getUser(id)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		renderError(w, req, http.StatusNotFound, "User not found")
 		return
 	}
 
-	tmpl := `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Edit User - RDBMS Demo</title>
-    <link rel="stylesheet" href="/static/style.css">
-</head>
-<body>
-    <div class="container">
-        <h1>Edit User</h1>
-        <form method="POST" action="/users/update">
-            <input type="hidden" name="id" value="{{.ID}}">
-            <div class="form-group">
-                <label for="name">Name:</label>
-                <input type="text" id="name" name="name" value="{{.Name}}" required>
-            </div>
-            <div class="form-group">
-                <label for="email">Email:</label>
-                <input type="email" id="email" name="email" value="{{.Email}}" required>
-            </div>
-            <div class="form-group">
-                <button type="submit" class="btn">Update User</button>
-                <a href="/" class="btn btn-secondary">Cancel</a>
-            </div>
-        </form>
-    </div>
-</body>
-</html>`
-
-	t, _ := template.New("edit_user").Parse(tmpl)
-	t.Execute(w, user)
+	render(w, http.StatusOK, "edit_user.html", struct {
+		*User
+		CSRF string
+	}{user, csrfToken(w, req)})
 }
 
 func handleUpdateUser(w http.ResponseWriter, req *http.Request) {
-	if req.Method != "POST" {
-		http.Redirect(w, req, "/", http.StatusSeeOther)
+	id, err := parseID(req.FormValue("id"))
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	id := req.FormValue("id")
 	name := req.FormValue("name")
 	email := req.FormValue("email")
 
-	stmt := fmt.Sprintf("UPDATE users SET name = '%s', email = '%s' WHERE id = %s", name, email, id)
-	executeSQL(stmt)
+	if _, err := executeSQLWithResult("UPDATE users SET name = ?, email = ? WHERE id = ?", text(name), text(email), id); err != nil {
+		renderError(w, req, sqlErrorStatus(err), err.Error())
+		return
+	}
+	notifyChange("users")
 
-	http.Redirect(w, req, "/", http.StatusSeeOther)
+	mutationDone(w, req, "user_row", req.FormValue("id"))
 }
 
 func getTask(id string) (*Task, error) {
-	stmt := fmt.Sprintf("SELECT id, title, description, status, user_id FROM tasks WHERE id = %s", id)
-	result, err := executeSQLWithResult(stmt)
+	param, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	result, err := executeSQLWithResult("SELECT id, title, description, status, user_id FROM tasks WHERE id = ?", param)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(result.Rows) == 0 {
-		return nil, fmt.Errorf("task not found")
+		return nil, fmt.Errorf("task %w", errNotFound)
 	}
 
 	row := result.Rows[0]
@@ -568,270 +729,82 @@ func handleEditTaskForm(w http.ResponseWriter, req *http.Request) {
 	id := req.URL.Query().Get("id")
 	task, err := getTask(id)
 	if err != nil {
-		http.Error(w, "Task not found", http.StatusNotFound)
+		renderError(w, req, http.StatusNotFound, "Task not found")
 		return
 	}
 
 	users := getUsers()
 
-	tmpl := `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Edit Task - RDBMS Demo</title>
-    <link rel="stylesheet" href="/static/style.css">
-</head>
-<body>
-    <div class="container">
-        <h1>Edit Task</h1>
-        <form method="POST" action="/tasks/update">
-            <input type="hidden" name="id" value="{{.Task.ID}}">
-            <div class="form-group">
-                <label for="title">Title:</label>
-                <input type="text" id="title" name="title" value="{{.Task.Title}}" required>
-            </div>
-            <div class="form-group">
-                <label for="description">Description:</label>
-                <textarea id="description" name="description">{{.Task.Description}}</textarea>
-            </div>
-            <div class="form-group">
-                <label for="status">Status:</label>
-                <select id="status" name="status">
-                    <option value="pending" {{if eq .Task.Status "pending"}}selected{{end}}>Pending</option>
-                    <option value="in_progress" {{if eq .Task.Status "in_progress"}}selected{{end}}>In Progress</option>
-                    <option value="completed" {{if eq .Task.Status "completed"}}selected{{end}}>Completed</option>
-                </select>
-            </div>
-            <div class="form-group">
-                <label for="user_id">Assign to:</label>
-                <select id="user_id" name="user_id">
-                    <option value="">Unassigned</option>
-                    {{range .Users}}
-                    <option value="{{.ID}}" {{if eq .ID $.Task.UserID}}selected{{end}}>{{.Name}} ({{.Email}})</option>
-                    {{end}}
-                </select>
-            </div>
-            <div class="form-group">
-                <button type="submit" class="btn">Update Task</button>
-                <a href="/" class="btn btn-secondary">Cancel</a>
-            </div>
-        </form>
-    </div>
-</body>
-</html>`
-
-	t, _ := template.New("edit_task").Parse(tmpl)
 	data := struct {
 		Task  *Task
 		Users []User
+		CSRF  string
 	}{
 		Task:  task,
 		Users: users,
+		CSRF:  csrfToken(w, req),
 	}
-	t.Execute(w, data)
+	render(w, http.StatusOK, "edit_task.html", data)
 }
 
 func handleUpdateTask(w http.ResponseWriter, req *http.Request) {
-	if req.Method != "POST" {
-		http.Redirect(w, req, "/", http.StatusSeeOther)
+	id, err := parseID(req.FormValue("id"))
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	id := req.FormValue("id")
 	title := req.FormValue("title")
 	description := req.FormValue("description")
 	status := req.FormValue("status")
-	userID := req.FormValue("user_id")
-
-	var stmt string
-	if userID == "" {
-		stmt = fmt.Sprintf("UPDATE tasks SET title = '%s', description = '%s', status = '%s', user_id = NULL WHERE id = %s",
-			title, description, status, id)
-	} else {
-		stmt = fmt.Sprintf("UPDATE tasks SET title = '%s', description = '%s', status = '%s', user_id = %s WHERE id = %s",
-			title, description, status, userID, id)
+	userID, err := parseOptionalID(req.FormValue("user_id"))
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	executeSQL(stmt)
+	_, err = executeSQLWithResult("UPDATE tasks SET title = ?, description = ?, status = ?, user_id = ? WHERE id = ?",
+		text(title), text(description), text(status), userID, id)
+	if err != nil {
+		renderError(w, req, sqlErrorStatus(err), err.Error())
+		return
+	}
+	notifyChange("tasks")
 
-	http.Redirect(w, req, "/", http.StatusSeeOther)
+	mutationDone(w, req, "task_row", req.FormValue("id"))
 }
 
 func handleDeleteUser(w http.ResponseWriter, req *http.Request) {
-	id := req.URL.Query().Get("id")
-	stmt := fmt.Sprintf("DELETE FROM users WHERE id = %s", id)
-	executeSQL(stmt)
+	id, err := parseID(req.PostFormValue("id"))
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	http.Redirect(w, req, "/", http.StatusSeeOther)
+	if _, err := executeSQLWithResult("DELETE FROM users WHERE id = ?", id); err != nil {
+		renderError(w, req, sqlErrorStatus(err), err.Error())
+		return
+	}
+	notifyChange("users")
+
+	mutationDone(w, req, "", "")
 }
 
 func handleDeleteTask(w http.ResponseWriter, req *http.Request) {
-	id := req.URL.Query().Get("id")
-	stmt := fmt.Sprintf("DELETE FROM tasks WHERE id = %s", id)
-	executeSQL(stmt)
+	id, err := parseID(req.PostFormValue("id"))
+	if err != nil {
+		renderError(w, req, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	http.Redirect(w, req, "/", http.StatusSeeOther)
+	if _, err := executeSQLWithResult("DELETE FROM tasks WHERE id = ?", id); err != nil {
+		renderError(w, req, sqlErrorStatus(err), err.Error())
+		return
+	}
+	notifyChange("tasks")
+
+	mutationDone(w, req, "", "")
 }
 
 func handleFavicon(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
-
-func handleStyleCSS(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "text/css")
-	fmt.Fprint(w, `
-* {
-    margin: 0;
-    padding: 0;
-    box-sizing: border-box;
-}
-
-body {
-    font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Arial, sans-serif;
-    line-height: 1.6;
-    padding: 20px;
-    background-color: #f5f5f5;
-}
-
-.container {
-    max-width: 1200px;
-    margin: 0 auto;
-    background-color: white;
-    padding: 30px;
-    border-radius: 8px;
-    box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-}
-
-h1 {
-    color: #333;
-    margin-bottom: 5px;
-    font-size: 2em;
-}
-
-.subtitle {
-    color: #666;
-    margin-bottom: 30px;
-    font-style: italic;
-}
-
-h2 {
-    color: #555;
-    margin: 20px 0 10px 0;
-    border-bottom: 2px solid #007bff;
-    padding-bottom: 5px;
-}
-
-.section {
-    margin: 40px 0;
-}
-
-table {
-    width: 100%;
-    border-collapse: collapse;
-    margin-bottom: 20px;
-}
-
-table thead {
-    background-color: #f8f9fa;
-}
-
-table th, table td {
-    padding: 12px;
-    text-align: left;
-    border-bottom: 1px solid #ddd;
-}
-
-table tbody tr:hover {
-    background-color: #f5f5f5;
-}
-
-.btn {
-    display: inline-block;
-    padding: 10px 20px;
-    background-color: #007bff;
-    color: white;
-    text-decoration: none;
-    border-radius: 4px;
-    margin-right: 10px;
-    transition: background-color 0.3s;
-}
-
-.btn:hover {
-    background-color: #0056b3;
-}
-
-.btn-secondary {
-    background-color: #6c757d;
-}
-
-.btn-secondary:hover {
-    background-color: #545b62;
-}
-
-.form-group {
-    margin-bottom: 20px;
-}
-
-.form-group label {
-    display: block;
-    margin-bottom: 8px;
-    font-weight: bold;
-    color: #333;
-}
-
-.form-group input,
-.form-group textarea,
-.form-group select {
-    width: 100%;
-    padding: 10px;
-    border: 1px solid #ddd;
-    border-radius: 4px;
-    font-size: 14px;
-    transition: border-color 0.3s;
-}
-
-.form-group input:focus,
-.form-group textarea:focus,
-.form-group select:focus {
-    outline: none;
-    border-color: #007bff;
-}
-
-.form-group textarea {
-    min-height: 100px;
-    resize: vertical;
-}
-
-.status {
-    padding: 4px 12px;
-    border-radius: 12px;
-    font-size: 11px;
-    font-weight: bold;
-    text-transform: uppercase;
-    letter-spacing: 0.5px;
-}
-
-.status.pending {
-    background-color: #ffc107;
-    color: #000;
-}
-
-.status.in_progress {
-    background-color: #17a2b8;
-    color: white;
-}
-
-.status.completed {
-    background-color: #28a745;
-    color: white;
-}
-
-pre {
-    background-color: #f8f9fa;
-    padding: 15px;
-    border-radius: 4px;
-    overflow-x: auto;
-}
-`)
-}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Every route is registered with handle, which wraps it in the same
+// layers: panics and failures render the shared error page, methods the
+// route does not list get 405, and POSTs must carry the CSRF token.
+//
+// CSRF tokens use the double-submit pattern: a random token lives in the
+// rdbms_csrf cookie and every form repeats it in a hidden csrf_token
+// field. Another site can make the browser send the cookie but cannot
+// read it to fill in the field.
+
+const (
+	csrfCookie = "rdbms_csrf"
+	csrfField  = "csrf_token"
+)
+
+// handle registers h for pattern, accepting only the given methods (GET
+// also allows HEAD).
+func handle(pattern string, h http.HandlerFunc, methods ...string) {
+	http.HandleFunc(pattern, recoverErrors(allowMethods(methods, checkCSRF(h))))
+}
+
+func allowMethods(methods []string, next http.HandlerFunc) http.HandlerFunc {
+	allow := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, req *http.Request) {
+		for _, m := range methods {
+			if req.Method == m || (m == http.MethodGet && req.Method == http.MethodHead) {
+				next(w, req)
+				return
+			}
+		}
+		w.Header().Set("Allow", allow)
+		renderError(w, req, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not allowed here (allowed: %s)", req.Method, allow))
+	}
+}
+
+// checkCSRF rejects POSTs whose csrf_token field does not match the
+// rdbms_csrf cookie.
+func checkCSRF(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPost {
+			cookie, err := req.Cookie(csrfCookie)
+			if err != nil || cookie.Value == "" ||
+				subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(req.PostFormValue(csrfField))) != 1 {
+				logger.Warn("rejected request without a valid CSRF token", "path", req.URL.Path, "remote", req.RemoteAddr)
+				renderError(w, req, http.StatusForbidden, "The form has expired or did not come from this site. Go back, reload the page and try again.")
+				return
+			}
+		}
+		next(w, req)
+	}
+}
+
+func recoverErrors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("handler panicked", "path", req.URL.Path, "panic", r)
+				renderError(w, req, http.StatusInternalServerError, "Something went wrong.")
+			}
+		}()
+		next(w, req)
+	}
+}
+
+// csrfToken returns the token forms on this page must include, setting
+// the cookie on first visit. Call it before writing the response.
+func csrfToken(w http.ResponseWriter, req *http.Request) string {
+	if cookie, err := req.Cookie(csrfCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		logger.Error("failed to generate CSRF token", "error", err)
+		return ""
+	}
+	token := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token
+}
+
+// renderError is the one place the webapp reports failures: an HTML page
+// for browsers, {"error": ...} for clients that ask for JSON, or a short
+// message for fragment requests.
+func renderError(w http.ResponseWriter, req *http.Request, status int, message string) {
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		writeJSON(w, status, errorResponse{Error: message})
+		return
+	}
+	if isFragment(req) {
+		render(w, status, "message", message)
+		return
+	}
+
+	render(w, status, "error.html", map[string]interface{}{
+		"Status":  status,
+		"Title":   http.StatusText(status),
+		"Message": message,
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mryan-3/rdbms/internal/storage"
+)
+
+// With -db, the database lives in a backup file (the BACKUP TO format):
+// it is restored at startup, rewritten at most every -save-interval while
+// changes come in, and saved once more on shutdown. Each save replaces the
+// file atomically, so a crash loses at most the last interval of changes.
+
+type persister struct {
+	path  string
+	dirty atomic.Bool
+
+	mu sync.Mutex
+}
+
+// loadDatabase restores db from path. It reports false if the file does
+// not exist yet, so the caller can seed a new database.
+func loadDatabase(path string) (bool, error) {
+	info, err := db.RestoreBackupFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	logger.Info("loaded database", "file", path, "tables", info.Tables, "rows", info.Rows)
+	return true, nil
+}
+
+func newPersister(path string) *persister {
+	return &persister{path: path}
+}
+
+// run saves the database every interval if it has changed, until ctx is
+// done.
+func (p *persister) run(ctx context.Context, interval time.Duration) {
+	unsubscribe := db.Subscribe("", func(storage.ChangeEvent) { p.dirty.Store(true) })
+	defer unsubscribe()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if p.dirty.Swap(false) {
+				if err := p.save(); err != nil {
+					p.dirty.Store(true)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *persister) save() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := db.BackupToFile(p.path)
+	if err != nil {
+		logger.Error("failed to save database", "file", p.path, "error", err)
+		return err
+	}
+	logger.Debug("saved database", "file", p.path, "tables", info.Tables, "rows", info.Rows, "lsn", info.LSN)
+	return nil
+}
@@ -0,0 +1,68 @@
+// Forms and links marked data-fragment are sent with fetch and an
+// X-Fragment header, and the element named by data-target is replaced with
+// the HTML that comes back. Errors show in #messages. Changes made
+// elsewhere still reload the page through /ws; the notifications for this
+// page's own changes are skipped.
+(function() {
+    var pending = 0;
+
+    function showMessage(html) {
+        var messages = document.getElementById("messages");
+        if (messages) messages.innerHTML = html;
+    }
+
+    function send(method, url, body, target) {
+        var mutation = method === "POST";
+        if (mutation) pending++;
+        fetch(url, {method: method, body: body, credentials: "same-origin", headers: {"X-Fragment": "true"}})
+            .then(function(res) {
+                if (res.redirected) {
+                    location.href = res.url;
+                    return;
+                }
+                return res.text().then(function(html) {
+                    if (!res.ok) {
+                        if (mutation) pending--;
+                        showMessage(html);
+                        return;
+                    }
+                    showMessage("");
+                    if (target) target.outerHTML = html;
+                });
+            })
+            .catch(function(err) {
+                if (mutation) pending--;
+                showMessage("");
+                document.getElementById("messages").textContent = "Request failed: " + err.message;
+            });
+    }
+
+    document.addEventListener("submit", function(e) {
+        var form = e.target;
+        if (!form.hasAttribute("data-fragment")) return;
+        e.preventDefault();
+        if (form.dataset.confirm && !confirm(form.dataset.confirm)) return;
+        send("POST", form.action, new URLSearchParams(new FormData(form)), document.getElementById(form.dataset.target));
+    });
+
+    document.addEventListener("click", function(e) {
+        var link = e.target.closest("a[data-fragment]");
+        if (!link) return;
+        e.preventDefault();
+        send("GET", link.dataset.fragment, null, document.getElementById(link.dataset.target));
+    });
+
+    document.addEventListener("change", function(e) {
+        if (e.target.matches("select[data-submit]")) e.target.form.requestSubmit();
+    });
+
+    var scheme = location.protocol === "https:" ? "wss://" : "ws://";
+    var ws = new WebSocket(scheme + location.host + "/ws");
+    ws.onmessage = function() {
+        if (pending > 0) {
+            pending--;
+        } else {
+            location.reload();
+        }
+    };
+})();
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+// Pages are html/template files in templates/, compiled into the binary
+// and parsed once at startup; each is executed by its file name (or, for
+// rows.html and admin.html, by the names it defines). static/ is served
+// as is under /static/.
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+//go:embed static
+var staticFiles embed.FS
+
+var pages *template.Template
+
+var templateFuncs = template.FuncMap{
+	"row":      func(row interface{}, csrf string) rowData { return rowData{Row: row, CSRF: csrf} },
+	"statuses": func() []string { return taskStatuses },
+}
+
+func loadTemplates() error {
+	t, err := template.New("").Funcs(templateFuncs).ParseFS(templateFiles, "templates/*.html")
+	if err != nil {
+		return fmt.Errorf("failed to parse templates: %w", err)
+	}
+	pages = t
+	return nil
+}
+
+// render executes the named template into a buffer before writing it, so
+// a template error becomes a 500 rather than half a page.
+func render(w http.ResponseWriter, status int, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		logger.Error("failed to render template", "template", name, "error", err)
+		http.Error(w, "failed to render page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+func staticHandler() http.HandlerFunc {
+	return http.FileServer(http.FS(staticFiles)).ServeHTTP
+}